@@ -0,0 +1,185 @@
+// Command snippetctl runs maintenance tasks against the Snippetbox database,
+// through the same models as the web server, so that operators do not have
+// to write SQL by hand. It reads the database settings the way the server
+// does: from the file given with -config, then from the environment.
+//
+// Usage:
+//
+//	snippetctl [-config file] [-dsn dsn] command [arguments]
+//
+// Run snippetctl with no command for the list of commands.
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"snippetbox.jmorelli.dev/internal/config"
+)
+
+// command is a subcommand of snippetctl. run gets the arguments that follow
+// the name of the command.
+type command struct {
+	usage   string
+	summary string
+	run     func(app *application, args []string) error
+}
+
+// commands is filled in by init, as the commands refer back to it for their
+// usage.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"create-admin": {
+			usage:   "create-admin -name name -email email",
+			summary: "Create a verified admin user, with the password read from standard input",
+			run:     (*application).createAdmin,
+		},
+		"reset-password": {
+			usage:   "reset-password -email email",
+			summary: "Set a user's password to the one read from standard input",
+			run:     (*application).resetPassword,
+		},
+		"purge-expired": {
+			usage:   "purge-expired",
+			summary: "Remove the snippets that expired long enough ago, with their comments and votes",
+			run:     (*application).purgeExpired,
+		},
+		"recount-votes": {
+			usage:   "recount-votes",
+			summary: "Recount the votes of every snippet and comment from the votes cast",
+			run:     (*application).recountVotes,
+		},
+		"export": {
+			usage:   "export -user id [-o file]",
+			summary: "Write a user's snippets as JSON, in the format of snippets.json in an account export",
+			run:     (*application).exportSnippets,
+		},
+		"import": {
+			usage:   "import -user id file",
+			summary: "Add the snippets in a JSON file, in the format of snippets.json in an account export, to a user",
+			run:     (*application).importSnippets,
+		},
+	}
+}
+
+func main() {
+	cfg := config.Default()
+
+	fs := flag.NewFlagSet("snippetctl", flag.ExitOnError)
+	fs.Usage = func() { usage(fs.Output()) }
+	configFile := fs.String("config", "", "TOML file to read the settings from; "+config.EnvPrefix+"* environment variables override it")
+	dsn := fs.String("dsn", "", "MySQL data source name; overrides the settings")
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "snippetctl: unknown command %q\n\n", fs.Arg(0))
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	if err := loadConfig(cfg, *configFile, *dsn); err != nil {
+		fatal(err)
+	}
+
+	db, err := openDB(cfg.Database.DSN)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+
+	app := newApplication(db, os.Stdin, os.Stdout)
+
+	if err := cmd.run(app, fs.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// usage writes the flags and commands of snippetctl to w.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: snippetctl [-config file] [-dsn dsn] command [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n        %s\n", commands[name].usage, commands[name].summary)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "snippetctl:", err)
+	os.Exit(1)
+}
+
+// loadConfig reads the config file at path, if any, and then the environment
+// into cfg, with dsn, if set, in place of the database settings. Only the
+// database settings are checked, as they are all snippetctl uses.
+func loadConfig(cfg *config.Config, path, dsn string) error {
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.LoadEnv(os.LookupEnv); err != nil {
+		return err
+	}
+
+	if dsn != "" {
+		cfg.Database.DSN = dsn
+	}
+	if cfg.Database.Driver != "mysql" {
+		return fmt.Errorf("config: database.driver must be mysql, got %q", cfg.Database.Driver)
+	}
+	if cfg.Database.DSN == "" {
+		return errors.New("config: database.dsn must not be empty")
+	}
+
+	return nil
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// readPassword reads a password from the first line of r, so that it stays
+// out of the shell history and the process list.
+func readPassword(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", errors.New("no password given on standard input")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/syntax"
+	"snippetbox.jmorelli.dev/internal/validator"
+)
+
+// exportBatchSize is how many snippets are read from the database at a time
+// during an export.
+const exportBatchSize = 100
+
+// exportSnippet is a snippet as written to snippets.json in an account
+// export by the web server.
+type exportSnippet struct {
+	ID         int          `json:"id"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	Format     string       `json:"format"`
+	Language   string       `json:"language"`
+	Visibility string       `json:"visibility"`
+	Tags       []string     `json:"tags"`
+	Files      []exportFile `json:"files"`
+	Created    time.Time    `json:"created"`
+	Expires    time.Time    `json:"expires"`
+}
+
+type exportFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// exportSnippets writes all of a user's snippets, along with their tags and
+// files, as a JSON array.
+func (app *application) exportSnippets(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	userID := fs.Int("user", 0, "ID of the user whose snippets are exported")
+	output := fs.String("o", "", "File to write to instead of standard output")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *userID < 1 {
+		return errors.New("export: a user ID is needed")
+	}
+
+	snippets := []exportSnippet{}
+	afterID := 0
+
+	for {
+		batch, err := app.exports.Snippets(*userID, afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range batch {
+			e, err := app.newExportSnippet(s)
+			if err != nil {
+				return err
+			}
+			snippets = append(snippets, e)
+			afterID = s.ID
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+	}
+
+	b, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if *output == "" {
+		_, err = app.stdout.Write(b)
+		return err
+	}
+
+	if err = os.WriteFile(*output, b, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.stdout, "Exported %d snippet(s) to %s\n", len(snippets), *output)
+	return nil
+}
+
+func (app *application) newExportSnippet(s *models.Snippet) (exportSnippet, error) {
+	tags, err := app.tags.GetBySnippet(s.ID)
+	if err != nil {
+		return exportSnippet{}, err
+	}
+
+	files, err := app.snippetFiles.ForSnippet(s.ID)
+	if err != nil {
+		return exportSnippet{}, err
+	}
+
+	e := exportSnippet{
+		ID:         s.ID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Format:     s.Format,
+		Language:   s.Language,
+		Visibility: s.Visibility,
+		Tags:       make([]string, len(tags)),
+		Files:      make([]exportFile, len(files)),
+		Created:    s.Created,
+		Expires:    s.Expires,
+	}
+
+	for i, t := range tags {
+		e.Tags[i] = t.Name
+	}
+	for i, f := range files {
+		e.Files[i] = exportFile{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	return e, nil
+}
+
+// importSnippets adds the snippets in a JSON array, as written by export, to
+// a user. Snippets that the create form would turn down, or that have
+// expired, are skipped and reported.
+func (app *application) importSnippets(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	userID := fs.Int("user", 0, "ID of the user the snippets are added to")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if *userID < 1 {
+		return errors.New("import: a user ID is needed")
+	}
+
+	snippets, err := readImport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	imported, skipped := 0, 0
+
+	for i, s := range snippets {
+		var v validator.Validator
+
+		tags, files := checkImportSnippet(&v, &s, now)
+		if !v.Valid() {
+			fmt.Fprintf(app.stdout, "Skipped snippet %d (%q): %s\n", i+1, s.Title, describeErrors(v.FieldErrors))
+			skipped++
+			continue
+		}
+
+		opts := models.SnippetOptions{Format: s.Format, Language: s.Language, Visibility: s.Visibility, Expires: s.Expires}
+
+		id, err := app.snippets.InsertWithOptions(s.Title, s.Content, opts, 0, *userID)
+		if err != nil {
+			return err
+		}
+
+		if err = app.tags.AttachToSnippet(id, tags); err != nil {
+			return err
+		}
+
+		if len(files) > 0 {
+			if err = app.snippetFiles.Replace(id, files); err != nil {
+				return err
+			}
+		}
+
+		imported++
+	}
+
+	fmt.Fprintf(app.stdout, "Imported %d snippet(s), skipped %d\n", imported, skipped)
+	return nil
+}
+
+// readImport reads the JSON array of snippets in the file at path.
+func readImport(path string) ([]exportSnippet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snippets []exportSnippet
+
+	dec := json.NewDecoder(f)
+	if err = dec.Decode(&snippets); err != nil {
+		return nil, fmt.Errorf("import: %s is not a list of snippets: %w", path, err)
+	}
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("import: %s has data after the list of snippets", path)
+	}
+
+	return snippets, nil
+}
+
+// checkImportSnippet checks an imported snippet as the create form would,
+// filling in the defaults of the fields left empty, and returns its tags and
+// files. A snippet that expires before now is not valid.
+func checkImportSnippet(v *validator.Validator, s *exportSnippet, now time.Time) ([]string, []*models.SnippetFile) {
+	if s.Format == "" {
+		s.Format = models.FormatPlain
+	}
+	if s.Visibility == "" {
+		s.Visibility = models.VisibilityPublic
+	}
+	if s.Expires.IsZero() {
+		s.Expires = models.NeverExpires
+	}
+
+	v.CheckField(validator.NotBlank(s.Title), "title", "it cannot be blank")
+	v.CheckField(validator.MaxChars(s.Title, 100), "title", "it cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(s.Content), "content", "it cannot be blank")
+	v.CheckField(validator.PermittedValue(s.Format, models.FormatPlain, models.FormatMarkdown), "format", "it must be plain or markdown")
+	v.CheckField(syntax.Supported(s.Language), "language", "it is not supported")
+	v.CheckField(validator.PermittedValue(s.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "it must be public, unlisted or private")
+	v.CheckField(s.Expires.After(now), "expires", "the snippet has expired")
+
+	tags := models.ParseTags(strings.Join(s.Tags, ","))
+	v.CheckField(len(tags) <= models.MaxTagsPerSnippet, "tags", fmt.Sprintf("there cannot be more than %d", models.MaxTagsPerSnippet))
+	for _, tag := range tags {
+		v.CheckField(validator.MaxChars(tag, models.MaxTagLength), "tags", fmt.Sprintf("they cannot be more than %d characters long", models.MaxTagLength))
+		v.CheckField(validator.Matches(tag, validator.TagRX), "tags", "they can only contain letters, digits, _, + and -")
+	}
+
+	files := []*models.SnippetFile{}
+	seen := make(map[string]bool)
+
+	for _, f := range s.Files {
+		name := strings.TrimSpace(f.Name)
+
+		v.CheckField(validator.MaxChars(name, models.MaxFileNameLength), "files", fmt.Sprintf("names cannot be more than %d characters long", models.MaxFileNameLength))
+		v.CheckField(validator.Matches(name, validator.FileNameRX), "files", "names can only contain letters, digits, ., _ and -")
+		// Names are compared the way the database collation does
+		v.CheckField(!seen[strings.ToLower(name)], "files", "names must be unique")
+		v.CheckField(validator.NotBlank(f.Content), "files", "they cannot be blank")
+		v.CheckField(syntax.Supported(f.Language), "files", "a language is not supported")
+
+		seen[strings.ToLower(name)] = true
+		files = append(files, &models.SnippetFile{Name: name, Language: f.Language, Content: f.Content})
+	}
+
+	v.CheckField(len(files) <= models.MaxSnippetFiles, "files", fmt.Sprintf("there cannot be more than %d", models.MaxSnippetFiles))
+
+	return tags, files
+}
+
+// describeErrors joins the field errors of a validator into one line, in the
+// order of the fields.
+func describeErrors(errs map[string]string) string {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		keys[i] = key + ": " + errs[key]
+	}
+
+	return strings.Join(keys, "; ")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/validator"
+)
+
+func TestCheckImportSnippet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		snippet   exportSnippet
+		wantError string
+	}{
+		{
+			name:    "Valid",
+			snippet: exportSnippet{Title: "Snail", Content: "O snail", Tags: []string{"haiku"}, Files: []exportFile{{Name: "a.txt", Content: "a"}}},
+		},
+		{
+			name:      "Blank title",
+			snippet:   exportSnippet{Content: "O snail"},
+			wantError: "title",
+		},
+		{
+			name:      "Expired",
+			snippet:   exportSnippet{Title: "Snail", Content: "O snail", Expires: now.Add(-time.Hour)},
+			wantError: "expires",
+		},
+		{
+			name:      "Bad format",
+			snippet:   exportSnippet{Title: "Snail", Content: "O snail", Format: "html"},
+			wantError: "format",
+		},
+		{
+			name:      "Duplicate file names",
+			snippet:   exportSnippet{Title: "Snail", Content: "O snail", Files: []exportFile{{Name: "a.txt", Content: "a"}, {Name: "A.txt", Content: "b"}}},
+			wantError: "files",
+		},
+		{
+			name:      "Bad file name",
+			snippet:   exportSnippet{Title: "Snail", Content: "O snail", Files: []exportFile{{Name: "../a", Content: "a"}}},
+			wantError: "files",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+
+			tags, files := checkImportSnippet(&v, &tt.snippet, now)
+
+			if tt.wantError == "" {
+				assert.Equal(t, v.Valid(), true)
+				assert.Equal(t, tt.snippet.Format, models.FormatPlain)
+				assert.Equal(t, tt.snippet.Visibility, models.VisibilityPublic)
+				assert.Equal(t, tt.snippet.Expires, models.NeverExpires)
+				assert.Equal(t, len(tags), 1)
+				assert.Equal(t, len(files), 1)
+				return
+			}
+
+			_, ok := v.FieldErrors[tt.wantError]
+			assert.Equal(t, ok, true)
+		})
+	}
+}
+
+func TestReadImport(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "snippets.json")
+	err := os.WriteFile(path, []byte(`[{"id": 1, "title": "Snail", "content": "O snail", "tags": ["haiku"]}]`), 0o600)
+	assert.NilError(t, err)
+
+	snippets, err := readImport(path)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "Snail")
+
+	path = filepath.Join(dir, "trailing.json")
+	err = os.WriteFile(path, []byte(`[] []`), 0o600)
+	assert.NilError(t, err)
+
+	_, err = readImport(path)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestReadPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Line", input: "pa$$word\nrest\n", want: "pa$$word"},
+		{name: "CRLF", input: "pa$$word\r\n", want: "pa$$word"},
+		{name: "No newline", input: "pa$$word", want: "pa$$word"},
+		{name: "Empty", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPassword(bufio.NewReader(strings.NewReader(tt.input)))
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/syntax"
+	"snippetbox.jmorelli.dev/internal/validator"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxAPIBodyBytes caps the size of a JSON request body.
+const maxAPIBodyBytes = 1 << 20
+
+// envelope wraps every JSON response under a top-level key, e.g.
+// {"snippet": {...}} or {"error": {...}}.
+type envelope map[string]any
+
+type apiSnippet struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Format     string    `json:"format"`
+	Language   string    `json:"language"`
+	ForkedFrom int       `json:"forked_from,omitempty"`
+	Visibility string    `json:"visibility"`
+	Upvotes    int       `json:"upvotes"`
+	Downvotes  int       `json:"downvotes"`
+	Stars      int       `json:"stars"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+}
+
+type apiComment struct {
+	ID        int       `json:"id"`
+	SnippetID int       `json:"snippet_id"`
+	ParentID  *int      `json:"parent_id,omitempty"`
+	AuthorID  int       `json:"author_id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	Removed   bool      `json:"removed,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Upvotes   int       `json:"upvotes"`
+	Downvotes int       `json:"downvotes"`
+	Score     int       `json:"score"`
+}
+
+type apiError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type apiSnippetInput struct {
+	Title               string `json:"title"`
+	Content             string `json:"content"`
+	Format              string `json:"format"`
+	Language            string `json:"language"`
+	Visibility          string `json:"visibility"`
+	Expires             int    `json:"expires"`
+	validator.Validator `json:"-"`
+}
+
+type apiCommentInput struct {
+	Content             string `json:"content"`
+	validator.Validator `json:"-"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Format:     s.Format,
+		Language:   s.Language,
+		ForkedFrom: s.ForkedFrom,
+		Visibility: s.Visibility,
+		Upvotes:    s.Upvotes,
+		Downvotes:  s.Downvotes,
+		Stars:      s.Stars,
+		Created:    s.Created,
+		Expires:    s.Expires,
+	}
+}
+
+// newAPIComment converts a comment for the API. The content of comments hidden
+// by a moderator is left out, as on the snippet page, and they are marked as
+// removed so replies to them keep their place in the thread.
+func newAPIComment(c *models.Comment) apiComment {
+	out := apiComment{
+		ID:        c.ID,
+		SnippetID: c.SnippetID,
+		ParentID:  c.ParentID,
+		AuthorID:  c.AuthorID,
+		Author:    c.Author,
+		Content:   c.Content,
+		Created:   c.Created,
+		Updated:   c.Updated,
+		Upvotes:   c.Upvotes,
+		Downvotes: c.Downvotes,
+		Score:     c.Score(),
+	}
+
+	if c.Hidden() {
+		out.Content = ""
+		out.Removed = true
+	}
+
+	return out
+}
+
+// writeJSON encodes data and sends it with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) {
+	js, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// readJSON decodes a single JSON object from the request body into dst. It
+// rejects unknown fields, trailing data and bodies over maxAPIBodyBytes.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains an incorrect JSON type for field %q", typeError.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON object")
+	}
+
+	return nil
+}
+
+// apiErrorResponse sends a JSON error envelope with the given status code.
+func (app *application) apiErrorResponse(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, envelope{"error": apiError{Status: status, Message: message}}, nil)
+}
+
+// apiServerError logs the error and sends a generic 500 JSON error.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, err.Error())
+
+	status := http.StatusInternalServerError
+	js, _ := json.Marshal(envelope{"error": apiError{Status: status, Message: http.StatusText(status)}})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// apiClientError sends the standard status text as a JSON error.
+func (app *application) apiClientError(w http.ResponseWriter, status int) {
+	app.apiErrorResponse(w, status, http.StatusText(status))
+}
+
+// apiNotFound is a wrapper around apiClientError for the status 404 Not Found
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiClientError(w, http.StatusNotFound)
+}
+
+// apiValidationError sends the field errors of a failed validation.
+func (app *application) apiValidationError(w http.ResponseWriter, fields map[string]string) {
+	status := http.StatusUnprocessableEntity
+	app.writeJSON(w, status, envelope{"error": apiError{Status: status, Message: "validation failed", Fields: fields}}, nil)
+}
+
+// acceptsJSON reports whether the Accept header allows a JSON response. A
+// missing header accepts anything.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasJSONBody reports whether the request body is declared as JSON.
+func hasJSONBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// apiUserID returns the ID of the user authenticated by apiAuthenticate, or 0.
+func apiUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// apiIDParam parses a positive integer route parameter.
+func apiIDParam(r *http.Request, name string) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName(name))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// apiLoadSnippet loads the snippet in the :id parameter, sending a 404 if it
+// does not exist and a 410 if it has expired. The second result is false if
+// a response was already sent.
+func (app *application) apiLoadSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, ok := apiIDParam(r, "id")
+	if !ok {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrExpired) {
+			app.apiErrorResponse(w, http.StatusGone, "This snippet has expired")
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// apiLoadComment loads the visible comment in the :id parameter, sending a
+// 404 if it does not exist or was deleted. The second result is false if a
+// response was already sent.
+func (app *application) apiLoadComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	id, ok := apiIDParam(r, "id")
+	if !ok {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	comment, err := app.comments.GetContext(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	if comment.Deleted {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	// Held comments wait for a moderator, out of sight of everyone else
+	if comment.Held() {
+		ok, err := app.canModify(r, comment.AuthorID, models.RoleModerator)
+		if err != nil {
+			app.apiServerError(w, err)
+			return nil, false
+		}
+		if !ok {
+			app.apiNotFound(w)
+			return nil, false
+		}
+	}
+
+	visible, err := app.snippetVisible(r, comment.SnippetID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return nil, false
+	}
+
+	if !visible {
+		app.apiNotFound(w)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	language := r.URL.Query().Get("language")
+	if !syntax.Supported(language) {
+		app.apiErrorResponse(w, http.StatusBadRequest, "unsupported language")
+		return
+	}
+
+	page := app.pageParam(r)
+	snippets, total, err := app.snippets.LatestByLanguage(language, page, models.DefaultSnippetPageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	out := make([]apiSnippet, len(snippets))
+	for i, s := range snippets {
+		out[i] = newAPISnippet(s)
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{
+		"snippets":  out,
+		"page":      page,
+		"page_size": models.DefaultSnippetPageSize,
+		"total":     total,
+	}, nil)
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiLoadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(snippet)}, nil)
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.CheckField(validator.NotBlank(input.Title), "title", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	input.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+	input.CheckField(validator.PermittedValue(input.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+
+	if input.Format == "" {
+		input.Format = models.FormatPlain
+	}
+	input.CheckField(validator.PermittedValue(input.Format, models.FormatPlain, models.FormatMarkdown), "format", "This field must be plain or markdown")
+	input.CheckField(syntax.Supported(input.Language), "language", "This language is not supported")
+
+	if input.Visibility == "" {
+		input.Visibility = models.VisibilityPublic
+	}
+	input.CheckField(validator.PermittedValue(input.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must be public, unlisted or private")
+
+	if !input.Valid() {
+		app.apiValidationError(w, input.FieldErrors)
+		return
+	}
+
+	opts := models.SnippetOptions{Format: input.Format, Language: input.Language, Visibility: input.Visibility}
+
+	id, err := app.snippets.InsertWithOptions(input.Title, input.Content, opts, input.Expires, apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.webhookSnippetCreated(id)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"id": id}, headers)
+}
+
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiLoadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := app.canModify(r, snippet.UserID, models.RoleAdmin)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !ok {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	var input apiSnippetInput
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.CheckField(validator.NotBlank(input.Title), "title", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	input.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+
+	if !input.Valid() {
+		app.apiValidationError(w, input.FieldErrors)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, input.Title, input.Content)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	updated := *snippet
+	updated.Title = input.Title
+	updated.Content = input.Content
+
+	app.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(&updated)}, nil)
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiLoadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := app.canModify(r, snippet.UserID, models.RoleAdmin)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !ok {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.snippets.Delete(snippet.ID, apiUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, apiUserID(r), models.AuditSnippetDelete, fmt.Sprintf("snippet %d", snippet.ID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiCommentList(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiLoadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Anonymous callers get the cached public list; signed-in ones also see
+	// their own held or shadowbanned comments, as on the snippet page
+	var comments []*models.Comment
+	var err error
+
+	if viewerID := apiUserID(r); viewerID == 0 {
+		comments, err = app.comments.GetBySnippetIDContext(r.Context(), snippet.ID)
+	} else {
+		comments, err = app.comments.GetBySnippetIDForViewerContext(r.Context(), snippet.ID, viewerID)
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	out := make([]apiComment, len(comments))
+	for i, c := range comments {
+		out[i] = newAPIComment(c)
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"comments": out}, nil)
+}
+
+func (app *application) apiCommentView(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.apiLoadComment(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"comment": newAPIComment(comment)}, nil)
+}
+
+func (app *application) apiCommentCreate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiLoadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiCommentInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.CheckText("content", input.Content, app.commentLimits)
+
+	if !input.Valid() {
+		app.apiValidationError(w, input.FieldErrors)
+		return
+	}
+
+	userID := apiUserID(r)
+
+	usr, err := app.users.Get(userID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	id, err := app.comments.InsertContext(spamContext(r), snippet.ID, userID, usr.Name, input.Content)
+	if err != nil && !errors.Is(err, models.ErrCommentHeld) {
+		if !app.apiCommentError(w, err, &input.Validator) {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/comments/%d", id))
+
+	// A held comment is not published until a moderator approves it
+	if err != nil {
+		app.writeJSON(w, http.StatusAccepted, envelope{"id": id, "status": models.StatusPending}, headers)
+		return
+	}
+
+	app.notifyComment(r, snippet.ID, 0, id, input.Content)
+	app.webhookCommentCreated(r, snippet.ID, id)
+
+	app.writeJSON(w, http.StatusCreated, envelope{"id": id}, headers)
+}
+
+func (app *application) apiCommentUpdate(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.apiLoadComment(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := app.canModify(r, comment.AuthorID, models.RoleModerator)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !ok {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	var input apiCommentInput
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	input.CheckText("content", input.Content, app.commentLimits)
+
+	if !input.Valid() {
+		app.apiValidationError(w, input.FieldErrors)
+		return
+	}
+
+	err = app.comments.UpdateContext(r.Context(), comment.ID, input.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.apiNotFound(w)
+		case app.apiCommentError(w, err, &input.Validator):
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	comment, err = app.comments.GetContext(r.Context(), comment.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"comment": newAPIComment(comment)}, nil)
+}
+
+func (app *application) apiCommentDelete(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.apiLoadComment(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := app.canModify(r, comment.AuthorID, models.RoleModerator)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !ok {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.comments.DeleteContext(r.Context(), comment.ID, apiUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, apiUserID(r), models.AuditCommentDelete, fmt.Sprintf("comment %d", comment.ID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCommentError turns the comment model's validation errors into a 422
+// response. It returns false, without writing anything, for other errors.
+func (app *application) apiCommentError(w http.ResponseWriter, err error, v *validator.Validator) bool {
+	switch {
+	case errors.Is(err, models.ErrDuplicatesSnippet):
+		v.AddFieldError("content", "Your comment repeats the snippet instead of discussing it")
+	case errors.Is(err, models.ErrCommentEmpty):
+		v.AddFieldError("content", "This field cannot be blank")
+	case errors.Is(err, models.ErrCommentTooLong):
+		v.AddFieldError("content", fmt.Sprintf("This field cannot be more than %d characters long", models.MaxCommentLength))
+	case errors.Is(err, models.ErrTooSoon):
+		app.apiErrorResponse(w, http.StatusTooManyRequests, "You are commenting too fast, please wait a moment")
+		return true
+	default:
+		return false
+	}
+
+	app.apiValidationError(w, v.FieldErrors)
+	return true
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+func TestAPI(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		method       string
+		urlPath      string
+		body         string
+		contentType  string
+		accept       string
+		auth         bool
+		user         string
+		token        string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:     "List snippets",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets",
+			wantCode: http.StatusOK,
+			wantBody: `"title": "An old silent pond"`,
+		},
+		{
+			name:     "View snippet",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			wantCode: http.StatusOK,
+			wantBody: `"content": "An old silent pond..."`,
+		},
+		{
+			name:     "Non-existent snippet",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/2",
+			wantCode: http.StatusNotFound,
+			wantBody: `"status": 404`,
+		},
+		{
+			name:     "Private snippet of another user",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private snippet of another user with credentials",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/4",
+			auth:     true,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Comments of a private snippet",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/4/comments",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "View snippet with credentials",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			auth:     true,
+			wantCode: http.StatusOK,
+			wantBody: `"visibility": "public"`,
+		},
+		{
+			name:     "Unknown route",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/unknown",
+			wantCode: http.StatusNotFound,
+			wantBody: `"message": "Not Found"`,
+		},
+		{
+			name:     "Not acceptable",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			accept:   "text/html",
+			wantCode: http.StatusNotAcceptable,
+		},
+		{
+			name:     "List comments",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1/comments",
+			wantCode: http.StatusOK,
+			wantBody: `"content": "What a lovely haiku"`,
+		},
+		{
+			name:     "View comment",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/comments/1",
+			wantCode: http.StatusOK,
+			wantBody: `"author": "John"`,
+		},
+		{
+			name:        "Create snippet without credentials",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "Title", "content": "Content", "expires": 7}`,
+			contentType: "application/json",
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:         "Create snippet",
+			method:       http.MethodPost,
+			urlPath:      "/api/v1/snippets",
+			body:         `{"title": "Title", "content": "Content", "expires": 7}`,
+			contentType:  "application/json",
+			auth:         true,
+			wantCode:     http.StatusCreated,
+			wantBody:     `"id": 2`,
+			wantLocation: "/api/v1/snippets/2",
+		},
+		{
+			name:     "Create snippet without content type",
+			method:   http.MethodPost,
+			urlPath:  "/api/v1/snippets",
+			body:     `{"title": "Title", "content": "Content", "expires": 7}`,
+			auth:     true,
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "Create snippet with malformed JSON",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "Title",`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    "badly-formed JSON",
+		},
+		{
+			name:        "Create snippet with invalid visibility",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "Title", "content": "Content", "expires": 7, "visibility": "secret"}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    "This field must be public, unlisted or private",
+		},
+		{
+			name:        "Create snippet with unknown field",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "Title", "content": "Content", "expires": 7, "user_id": 3}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    `unknown field \"user_id\"`,
+		},
+		{
+			name:        "Create invalid snippet",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "", "content": "Content", "expires": 2}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"expires": "This field must equal 1, 7 or 365"`,
+		},
+		{
+			name:        "Update snippet",
+			method:      http.MethodPut,
+			urlPath:     "/api/v1/snippets/1",
+			body:        `{"title": "New title", "content": "New content"}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusOK,
+			wantBody:    `"title": "New title"`,
+		},
+		{
+			name:     "Delete snippet",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/snippets/1",
+			auth:     true,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:         "Create comment",
+			method:       http.MethodPost,
+			urlPath:      "/api/v1/snippets/1/comments",
+			body:         `{"content": "Nice one"}`,
+			contentType:  "application/json",
+			auth:         true,
+			wantCode:     http.StatusCreated,
+			wantLocation: "/api/v1/comments/2",
+		},
+		{
+			name:        "Create comment too short",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets/1/comments",
+			body:        `{"content": " a "}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"content": "This field must be at least 2 characters long"`,
+		},
+		{
+			name:        "Create comment with control characters",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets/1/comments",
+			body:        `{"content": "Nice\u0007 one"}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"content": "This field cannot contain control characters"`,
+		},
+		{
+			name:        "Create comment on non-existent snippet",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets/2/comments",
+			body:        `{"content": "Nice one"}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "Update comment",
+			method:      http.MethodPut,
+			urlPath:     "/api/v1/comments/1",
+			body:        `{"content": "Changed my mind"}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Update comment with blank content",
+			method:      http.MethodPut,
+			urlPath:     "/api/v1/comments/1",
+			body:        `{"content": " "}`,
+			contentType: "application/json",
+			auth:        true,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"content": "This field cannot be blank"`,
+		},
+		{
+			name:     "Delete comment",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/comments/1",
+			auth:     true,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Delete comment of another user",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/comments/1",
+			user:     "unverified@email.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Delete comment as moderator",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/comments/1",
+			user:     "mod@email.com",
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:        "Update snippet as moderator",
+			method:      http.MethodPut,
+			urlPath:     "/api/v1/snippets/1",
+			body:        `{"title": "New title", "content": "New content"}`,
+			contentType: "application/json",
+			user:        "mod@email.com",
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Update snippet as admin",
+			method:      http.MethodPut,
+			urlPath:     "/api/v1/snippets/1",
+			body:        `{"title": "New title", "content": "New content"}`,
+			contentType: "application/json",
+			user:        "admin@email.com",
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:     "Too many failed logins",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			user:     "throttled@email.com",
+			wantCode: http.StatusTooManyRequests,
+			wantBody: `"message": "Too many failed logins, please wait before trying again"`,
+		},
+		{
+			name:     "Locked account",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			user:     "locked@email.com",
+			wantCode: http.StatusForbidden,
+			wantBody: `"message": "This account has been locked after too many failed logins"`,
+		},
+		{
+			name:     "Two-factor account over Basic auth",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			user:     "twofactor@email.com",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `"message": "This account uses two-factor authentication, please use an API token"`,
+		},
+		{
+			name:     "Private snippet of another user with a token",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/4",
+			token:    "sbx_read",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "View snippet with a token",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			token:    "sbx_read",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Revoked token",
+			method:   http.MethodGet,
+			urlPath:  "/api/v1/snippets/1",
+			token:    "sbx_revoked",
+			wantCode: http.StatusUnauthorized,
+			wantBody: `"message": "API token is invalid or has been revoked"`,
+		},
+		{
+			name:        "Create snippet with a read-only token",
+			method:      http.MethodPost,
+			urlPath:     "/api/v1/snippets",
+			body:        `{"title": "Title", "content": "Content", "expires": 7}`,
+			contentType: "application/json",
+			token:       "sbx_read",
+			wantCode:    http.StatusForbidden,
+			wantBody:    `"message": "This API token does not have the write scope"`,
+		},
+		{
+			name:         "Create snippet with a token",
+			method:       http.MethodPost,
+			urlPath:      "/api/v1/snippets",
+			body:         `{"title": "Title", "content": "Content", "expires": 7}`,
+			contentType:  "application/json",
+			token:        "sbx_readwrite",
+			wantCode:     http.StatusCreated,
+			wantLocation: "/api/v1/snippets/2",
+		},
+		{
+			name:     "Delete comment with a token",
+			method:   http.MethodDelete,
+			urlPath:  "/api/v1/comments/1",
+			token:    "sbx_readwrite",
+			wantCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.urlPath, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.auth {
+				req.SetBasicAuth("jay@email.com", "12345678")
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, "12345678")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			code, headers, body := srv.do(t, req)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if code != http.StatusNoContent {
+				assert.Equal(t, headers.Get("Content-Type"), "application/json; charset=utf-8")
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.wantLocation != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestNewAPIComment(t *testing.T) {
+	c := &models.Comment{ID: 1, SnippetID: 1, Author: "John", Content: "What a lovely haiku", Status: models.StatusApproved}
+
+	out := newAPIComment(c)
+	assert.Equal(t, out.Content, "What a lovely haiku")
+	assert.Equal(t, out.Removed, false)
+
+	c.Status = models.StatusRejected
+
+	out = newAPIComment(c)
+	assert.Equal(t, out.ID, 1)
+	assert.Equal(t, out.Content, "")
+	assert.Equal(t, out.Removed, true)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/storage"
+	"snippetbox.jmorelli.dev/internal/thumbnail"
+)
+
+// Limits on the images attached to comments, and on their thumbnails.
+const (
+	maxAttachmentBytes = 5 << 20
+	thumbnailSize      = 200
+)
+
+// attachmentMaxAge is how long clients may keep an attached image. Images
+// never change once uploaded, but they go away with their comment.
+const attachmentMaxAge = 24 * time.Hour
+
+// commentAttachments returns the attachments of the comments, by comment ID.
+func (app *application) commentAttachments(comments []*models.Comment) (map[int][]*models.Attachment, error) {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	return app.attachments.ForComments(ids)
+}
+
+// commentAttachPost attaches an image to one of the user's own comments. The
+// image must be a PNG, JPEG or GIF of at most maxAttachmentBytes. It is kept
+// as uploaded, along with a thumbnail to show under the comment. Problems
+// with the image are flashed, as the form lives on the snippet page.
+func (app *application) commentAttachPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if comment.AuthorID != userID || comment.Deleted || comment.Hidden() {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	redirect := fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, comment.ID)
+	fail := func(message string) {
+		app.flash(r, message)
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+	}
+
+	count, err := app.attachments.Count(comment.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if count >= models.MaxCommentAttachments {
+		fail(fmt.Sprintf("A comment can have at most %d images.", models.MaxCommentAttachments))
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			fail("Choose an image to attach.")
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	tooLarge := fmt.Sprintf("Images can be at most %d MB.", maxAttachmentBytes>>20)
+	if header.Size > maxAttachmentBytes {
+		fail(tooLarge)
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(file, maxAttachmentBytes+1))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(b) > maxAttachmentBytes {
+		fail(tooLarge)
+		return
+	}
+
+	img, err := thumbnail.Decode(b)
+	if err != nil {
+		if errors.Is(err, thumbnail.ErrTooLarge) {
+			fail("That image has too many pixels.")
+		} else {
+			fail("Only PNG, JPEG and GIF images can be attached.")
+		}
+		return
+	}
+
+	thumb, thumbType, err := img.Thumbnail(thumbnailSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	a := &models.Attachment{
+		CommentID:     comment.ID,
+		UserID:        userID,
+		Name:          attachmentName(header.Filename),
+		ContentType:   thumbnail.ContentTypes[img.Format],
+		Size:          int64(len(b)),
+		Width:         img.Bounds().Dx(),
+		Height:        img.Bounds().Dy(),
+		ThumbnailType: thumbType,
+	}
+
+	a.Key, err = app.storeUpload(b, a.ContentType)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	a.ThumbnailKey, err = app.storeUpload(thumb, thumbType)
+	if err != nil {
+		app.uploads.Delete(a.Key)
+		app.serverError(w, err)
+		return
+	}
+
+	_, err = app.attachments.Insert(a)
+	if err != nil {
+		app.uploads.Delete(a.Key)
+		app.uploads.Delete(a.ThumbnailKey)
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Image attached.")
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// storeUpload keeps b under a new key, ending in the extension for
+// contentType, and returns the key.
+func (app *application) storeUpload(b []byte, contentType string) (string, error) {
+	key, err := storage.NewKey("." + strings.TrimPrefix(contentType, "image/"))
+	if err != nil {
+		return "", err
+	}
+
+	return key, app.uploads.Put(key, bytes.NewReader(b))
+}
+
+// attachmentName returns the base of an uploaded file's name, which some
+// browsers send with the path it was uploaded from.
+func attachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	return name
+}
+
+// attachmentView sends an attached image, or its thumbnail for the thumb
+// route, to those who may see the comment's snippet.
+func (app *application) attachmentView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	a, err := app.attachments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	visible, err := app.snippetVisible(r, a.SnippetID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	key, contentType := a.Key, a.ContentType
+	if strings.HasSuffix(r.URL.Path, "/thumb") {
+		key, contentType = a.ThumbnailKey, a.ThumbnailType
+	}
+
+	f, err := app.uploads.Get(key)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Whether the snippet is public is not known here, so shared caches are
+	// kept out.
+	serveCacheable(w, r, contentType, b, false, attachmentMaxAge)
+}
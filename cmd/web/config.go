@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+
+	"snippetbox.jmorelli.dev/internal/config"
+)
+
+// configFlags defines on fs a flag for each setting of cfg that is worth
+// changing from the command line, with cfg's values as the defaults.
+func configFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "HTTP network address")
+	fs.StringVar(&cfg.BaseURL, "base-url", cfg.BaseURL, "Public URL of the site, for links in emails sent outside a request")
+	fs.StringVar(&cfg.ShareSecret, "share-secret", cfg.ShareSecret, "Secret key used to sign snippet share links")
+	fs.StringVar(&cfg.UploadDir, "upload-dir", cfg.UploadDir, "Directory where uploaded images are kept")
+	fs.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "TLS certificate file")
+	fs.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "TLS private key file")
+	fs.StringVar(&cfg.Database.Driver, "db-driver", cfg.Database.Driver, "Database driver; only mysql is supported")
+	fs.StringVar(&cfg.Database.DSN, "dsn", cfg.Database.DSN, "MySQL data source name")
+	fs.DurationVar(&cfg.Cache.TTL, "cache-ttl", cfg.Cache.TTL, "How long snippets and comments read from the database are cached; 0 disables the cache")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read-timeout", cfg.Server.ReadTimeout, "Maximum time to read a request, body included")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write-timeout", cfg.Server.WriteTimeout, "Maximum time to write a response")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle-timeout", cfg.Server.IdleTimeout, "Maximum time a keep-alive connection is kept waiting for the next request")
+	fs.IntVar(&cfg.Server.MaxHeaderBytes, "max-header-bytes", cfg.Server.MaxHeaderBytes, "Maximum size of request headers, in bytes")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "shutdown-timeout", cfg.Server.ShutdownTimeout, "Time given to requests in flight to finish when shutting down")
+	fs.StringVar(&cfg.SMTP.Addr, "smtp-addr", cfg.SMTP.Addr, "SMTP server address for outgoing mail; mail is logged if empty")
+	fs.StringVar(&cfg.SMTP.Username, "smtp-username", cfg.SMTP.Username, "SMTP username")
+	fs.StringVar(&cfg.SMTP.Password, "smtp-password", cfg.SMTP.Password, "SMTP password")
+	fs.StringVar(&cfg.SMTP.From, "smtp-from", cfg.SMTP.From, "Sender address for outgoing mail")
+	fs.StringVar(&cfg.OAuth.GitHubClientID, "github-client-id", cfg.OAuth.GitHubClientID, "GitHub OAuth client ID; GitHub login is disabled if empty")
+	fs.StringVar(&cfg.OAuth.GitHubClientSecret, "github-client-secret", cfg.OAuth.GitHubClientSecret, "GitHub OAuth client secret")
+	fs.StringVar(&cfg.OAuth.GoogleClientID, "google-client-id", cfg.OAuth.GoogleClientID, "Google OAuth client ID; Google login is disabled if empty")
+	fs.StringVar(&cfg.OAuth.GoogleClientSecret, "google-client-secret", cfg.OAuth.GoogleClientSecret, "Google OAuth client secret")
+	fs.IntVar(&cfg.Limits.Logins, "limit-logins", cfg.Limits.Logins, "Login attempts allowed per minute; 0 disables the limit")
+	fs.IntVar(&cfg.Limits.Snippets, "limit-snippets", cfg.Limits.Snippets, "Snippets that can be created per minute; 0 disables the limit")
+	fs.IntVar(&cfg.Limits.Comments, "limit-comments", cfg.Limits.Comments, "Comments that can be posted per minute; 0 disables the limit")
+	fs.IntVar(&cfg.Limits.Votes, "limit-votes", cfg.Limits.Votes, "Votes that can be cast per minute; 0 disables the limit")
+	fs.IntVar(&cfg.Limits.LockoutAfter, "lockout-after", cfg.Limits.LockoutAfter, "Failed logins in a row that lock an account for an hour; 0 disables lockout")
+	fs.IntVar(&cfg.Comments.MinLength, "comment-min-length", cfg.Comments.MinLength, "Fewest characters a comment can have")
+	fs.IntVar(&cfg.Comments.MaxLength, "comment-max-length", cfg.Comments.MaxLength, "Most characters a comment can have")
+	fs.StringVar(&cfg.Spam.BannedWords, "spam-banned-words", cfg.Spam.BannedWords, "Comma-separated words that hold a comment for moderation")
+	fs.IntVar(&cfg.Spam.MaxLinks, "spam-max-links", cfg.Spam.MaxLinks, "Links a comment may have before it is held for moderation; 0 disables the check")
+	fs.DurationVar(&cfg.Spam.DuplicateWindow, "spam-duplicate-window", cfg.Spam.DuplicateWindow, "How far back a repeated comment by the same user is held for moderation; 0 disables the check")
+	fs.StringVar(&cfg.Spam.AkismetKey, "akismet-key", cfg.Spam.AkismetKey, "Akismet API key; comments are not checked with Akismet if empty")
+	fs.StringVar(&cfg.Metrics.User, "metrics-user", cfg.Metrics.User, "Username for basic auth on /metrics")
+	fs.StringVar(&cfg.Metrics.Password, "metrics-password", cfg.Metrics.Password, "Password for basic auth on /metrics; only admins can see the metrics if empty")
+	fs.DurationVar(&cfg.Jobs.WebhookInterval, "webhook-interval", cfg.Jobs.WebhookInterval, "How often webhook deliveries that are due are sent")
+	fs.DurationVar(&cfg.Jobs.PurgeInterval, "purge-interval", cfg.Jobs.PurgeInterval, "How often expired snippets, old trash and orphaned attachments are purged")
+	fs.BoolVar(&cfg.Features.Debug, "debug", cfg.Features.Debug, "Debug mode - disabled by default")
+	fs.BoolVar(&cfg.Features.AutoMigrate, "auto-migrate", cfg.Features.AutoMigrate, "Apply the pending database migrations at startup")
+	fs.BoolVar(&cfg.Features.Digests, "digests", cfg.Features.Digests, "Send the weekly digests from the server as they fall due")
+}
+
+// loadConfig reads the config file at path, if any, and then the environment
+// through lookup, into cfg. Flags set on fs come last and win over both. The
+// result is validated.
+func loadConfig(fs *flag.FlagSet, cfg *config.Config, path string, lookup func(string) (string, bool)) error {
+	// Loading writes over the values the flags have put in cfg, so they are
+	// put back afterwards
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.LoadEnv(lookup); err != nil {
+		return err
+	}
+
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return cfg.Validate()
+}
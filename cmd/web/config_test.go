@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippetbox.toml")
+	err := os.WriteFile(path, []byte(`addr = ":5000"
+base_url = "https://file.example.com"
+
+[limits]
+votes = 30
+comments = 20
+`), 0o600)
+	assert.NilError(t, err)
+
+	env := map[string]string{
+		"SNIPPETBOX_BASE_URL":        "https://env.example.com",
+		"SNIPPETBOX_LIMITS_COMMENTS": "40",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	cfg := config.Default()
+	fs := flag.NewFlagSet("web", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFlags(fs, cfg)
+
+	assert.NilError(t, fs.Parse([]string{"-limit-comments", "50"}))
+	assert.NilError(t, loadConfig(fs, cfg, path, lookup))
+
+	// Flags win over the environment, which wins over the file
+	assert.Equal(t, cfg.Addr, ":5000")
+	assert.Equal(t, cfg.Limits.Votes, 30)
+	assert.Equal(t, cfg.BaseURL, "https://env.example.com")
+	assert.Equal(t, cfg.Limits.Comments, 50)
+
+	// and settings nobody set keep their defaults
+	assert.Equal(t, cfg.Limits.Logins, config.Default().Limits.Logins)
+
+	// The result is validated
+	cfg = config.Default()
+	fs = flag.NewFlagSet("web", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFlags(fs, cfg)
+
+	assert.NilError(t, fs.Parse([]string{"-limit-votes", "-1"}))
+	err = loadConfig(fs, cfg, "", lookup)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	assert.StringContains(t, err.Error(), "limits.votes must not be negative")
+}
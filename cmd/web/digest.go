@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+const (
+	// digestInterval is how often a user who wants the digest gets one, and
+	// how far back the new comments in it go.
+	digestInterval = 7 * 24 * time.Hour
+	// digestCheckInterval is how often the server looks for digests that are
+	// due.
+	digestCheckInterval = time.Hour
+	// digestCommentsLimit and digestTrendingLimit cap the comments and
+	// trending snippets in a digest.
+	digestCommentsLimit = 20
+	digestTrendingLimit = 5
+)
+
+// digestData is passed to the digest email templates.
+type digestData struct {
+	Name     string
+	BaseURL  string
+	Comments []*models.CommentWithContext
+	Trending []*models.Snippet
+}
+
+// sendDigests emails the digest to each user it is due for and returns how
+// many were sent. A digest that cannot be sent is logged and tried again
+// next time, rather than holding up the rest.
+func (app *application) sendDigests() (int, error) {
+	users, err := app.users.DigestsDue(digestInterval)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	trending, _, err := app.snippets.Trending(1, digestTrendingLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	since := time.Now().Add(-digestInterval)
+	sent := 0
+
+	for _, usr := range users {
+		comments, err := app.comments.ForOwnerSince(usr.ID, since, digestCommentsLimit)
+		if err != nil {
+			return sent, err
+		}
+
+		// Nothing to tell
+		if len(comments) == 0 && len(trending) == 0 {
+			continue
+		}
+
+		text, html, err := app.renderEmail("digest", &digestData{
+			Name:     usr.Name,
+			BaseURL:  app.baseURL,
+			Comments: comments,
+			Trending: trending,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		err = app.mailer.SendHTML(usr.Email, "Your weekly Snippetbox digest", text, html)
+		if err != nil {
+			app.errorLog.Print(err)
+			continue
+		}
+
+		err = app.users.DigestSent(usr.ID)
+		if err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+// sendDueDigests sends the digests that are due. It is run as a background
+// job every digestCheckInterval.
+func (app *application) sendDueDigests(ctx context.Context) {
+	sent, err := app.sendDigests()
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if sent > 0 {
+		app.infoLog.Printf("Sent %d digest(s)", sent)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// embedMaxAge is how long clients and caches may keep an embedded snippet.
+const embedMaxAge = 5 * time.Minute
+
+// Size of the frame a snippet is embedded in. The height follows the number
+// of lines of the snippet, within bounds.
+const (
+	embedWidth      = 640
+	embedMinHeight  = 100
+	embedMaxHeight  = 600
+	embedLineHeight = 21
+	embedChrome     = 64
+)
+
+// oEmbedResponse is an oEmbed 1.0 response of the rich type
+// (https://oembed.com).
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	AuthorURL    string `json:"author_url,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// embeddableSnippet returns the snippet with the given ID if it may be
+// embedded on other sites, which only public and unlisted snippets may. Other
+// sites' frames do not carry the viewer's session, so private snippets are
+// reported as not found, like missing ones.
+func (app *application) embeddableSnippet(id int) (*models.Snippet, error) {
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if snippet.Visibility == models.VisibilityPrivate {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// loadEmbeddable is like loadSnippet, for the embedding endpoints.
+func (app *application) loadEmbeddable(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.embeddableSnippet(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// embedHeight returns the height of the frame for a snippet.
+func embedHeight(s *models.Snippet) int {
+	height := (strings.Count(s.Content, "\n")+1)*embedLineHeight + embedChrome
+
+	if height < embedMinHeight {
+		return embedMinHeight
+	}
+	if height > embedMaxHeight {
+		return embedMaxHeight
+	}
+	return height
+}
+
+// snippetEmbed is the read-only page of a snippet that other sites show in a
+// frame.
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadEmbeddable(w, r)
+	if !ok {
+		return
+	}
+
+	cache := "private"
+	if snippet.Visibility == models.VisibilityPublic {
+		cache = "public"
+	}
+
+	w.Header().Del("X-Frame-Options")
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", cache, int(embedMaxAge.Seconds())))
+
+	// Embedded pages have no session, so no flash, user or CSRF token
+	app.render(w, http.StatusOK, "embed.tmpl.html", &templateData{Snippet: snippet})
+}
+
+// snippetEmbedJS is a script that puts the embedded page of a snippet in a
+// frame right after the script tag loading it, so that other sites can embed
+// snippets with a single tag.
+func (app *application) snippetEmbedJS(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadEmbeddable(w, r)
+	if !ok {
+		return
+	}
+
+	// JSON strings are valid JavaScript, and json escapes <, > and &
+	src, _ := json.Marshal(fmt.Sprintf("%s/snippet/embed/%d", baseURL(r), snippet.ID))
+	title, _ := json.Marshal(snippet.Title)
+
+	js := fmt.Sprintf(`(function () {
+  var script = document.currentScript;
+  var frame = document.createElement('iframe');
+  frame.src = %s;
+  frame.title = %s;
+  frame.width = '100%%';
+  frame.height = '%d';
+  frame.style.border = '1px solid #E4E5E7';
+  frame.style.borderRadius = '3px';
+  script.parentNode.insertBefore(frame, script.nextSibling);
+})();
+`, src, title, embedHeight(snippet))
+
+	serveCacheable(w, r, "text/javascript; charset=utf-8", []byte(js), snippet.Visibility == models.VisibilityPublic, embedMaxAge)
+}
+
+// oEmbed answers oEmbed requests for snippet pages, so that sites which
+// support oEmbed embed snippets from a link. Only the JSON format is
+// supported.
+func (app *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	u, err := url.Parse(query.Get("url"))
+	if err != nil || (u.Host != "" && u.Host != r.Host) {
+		app.notFound(w)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(u.Path, "/snippet/view/"))
+	if err != nil || id < 1 || !strings.HasPrefix(u.Path, "/snippet/view/") {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.embeddableSnippet(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	width, height := embedWidth, embedHeight(snippet)
+	if max, err := strconv.Atoi(query.Get("maxwidth")); err == nil && max > 0 && max < width {
+		width = max
+	}
+	if max, err := strconv.Atoi(query.Get("maxheight")); err == nil && max > 0 && max < height {
+		height = max
+	}
+
+	base := baseURL(r)
+
+	res := oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Snippetbox",
+		ProviderURL:  base + "/",
+		Title:        snippet.Title,
+		HTML: fmt.Sprintf(`<iframe src="%s/snippet/embed/%d" width="%d" height="%d" frameborder="0" title="%s"></iframe>`,
+			base, snippet.ID, width, height, html.EscapeString(snippet.Title)),
+		Width:    width,
+		Height:   height,
+		CacheAge: int(embedMaxAge.Seconds()),
+	}
+
+	if usr, err := app.users.Get(snippet.UserID); err == nil {
+		res.AuthorName = usr.Name
+		res.AuthorURL = fmt.Sprintf("%s/user/profile/%d", base, usr.ID)
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	js, err := json.Marshal(res)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	serveCacheable(w, r, "application/json; charset=utf-8", js, snippet.Visibility == models.VisibilityPublic, embedMaxAge)
+}
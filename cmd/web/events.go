@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// eventBuffer is how many events a stream can fall behind by before it is
+	// ended. The browser then reconnects.
+	eventBuffer = 16
+	// eventHeartbeat is how often an idle stream sends a comment line, which
+	// keeps proxies from closing it and finds clients that went away.
+	eventHeartbeat = 30 * time.Second
+	// eventStreamMaxAge is how long a stream stays open before the browser is
+	// made to reconnect, which checks again that the snippet may be seen.
+	eventStreamMaxAge = 30 * time.Minute
+	// eventRetry is how long browsers wait before reconnecting.
+	eventRetry = 5 * time.Second
+)
+
+// snippetEvents streams the new comments and vote changes of a snippet to
+// its open page as Server-Sent Events: a "comment" event for each new
+// comment and a "vote" event for each vote, both with the comment as in the
+// JSON API. Requests that do not accept an event stream, such as a link
+// followed by hand, are sent to the snippet page instead.
+func (app *application) snippetEvents(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	events, cancel := app.commentEvents.Subscribe(snippet.ID, eventBuffer)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout is meant for ordinary responses, and would
+	// cut the stream short
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	maxAge := time.NewTimer(eventStreamMaxAge)
+	defer maxAge.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-maxAge.C:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-events:
+			// The stream fell behind, or the server is shutting down
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(newAPIComment(e.Comment))
+			if err != nil {
+				app.errorLog.Print(err)
+				return
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
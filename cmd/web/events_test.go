@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+func TestSnippetEvents(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	// Without an event stream to accept, the link leads to the snippet
+	code, headers, _ := srv.get(t, "/snippet/view/1/events")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+
+	stream := func(urlPath string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/event-stream")
+
+		rs, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rs
+	}
+
+	// Private snippets stream to no one else
+	rs := stream("/snippet/view/4/events")
+	rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusNotFound)
+
+	rs = stream("/snippet/view/1/events")
+	defer rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Type"), "text/event-stream")
+
+	events := bufio.NewReader(rs.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	assert.Equal(t, readEvent(), "retry: 5000\n")
+
+	for app.commentEvents.Subscribers(1) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	app.commentEvents.Publish(2, models.CommentEvent{Type: models.CommentEventCreated, Comment: &models.Comment{ID: 9, SnippetID: 2}})
+	app.commentEvents.Publish(1, models.CommentEvent{Type: models.CommentEventVoted, Comment: &models.Comment{ID: 1, SnippetID: 1, Upvotes: 3, Downvotes: 1}})
+
+	event := readEvent()
+	assert.StringContains(t, event, "event: vote\n")
+	assert.StringContains(t, event, `"id":1,`)
+	assert.StringContains(t, event, `"score":2`)
+
+	// Shutting down ends the stream
+	app.commentEvents.Close()
+
+	_, err := io.ReadAll(events)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/syntax"
+	"snippetbox.jmorelli.dev/internal/validator"
+)
+
+const (
+	// exportBatchSize is how many records are read at a time while an export
+	// is written out.
+	exportBatchSize = 100
+	// maxImportBytes caps the size of an uploaded import, and of the
+	// snippets.json read out of an export archive.
+	maxImportBytes = 10 << 20
+	// maxImportSnippets is how many snippets one import can hold.
+	maxImportSnippets = 500
+)
+
+// exportSnippet is a snippet as written to snippets.json in an export, and as
+// read back by an import.
+type exportSnippet struct {
+	ID         int          `json:"id"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	Format     string       `json:"format"`
+	Language   string       `json:"language"`
+	Visibility string       `json:"visibility"`
+	Tags       []string     `json:"tags"`
+	Files      []exportFile `json:"files"`
+	Created    time.Time    `json:"created"`
+	Expires    time.Time    `json:"expires"`
+}
+
+type exportFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+func newExportSnippet(s *models.Snippet, tags []*models.Tag, files []*models.SnippetFile) exportSnippet {
+	e := exportSnippet{
+		ID:         s.ID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Format:     s.Format,
+		Language:   s.Language,
+		Visibility: s.Visibility,
+		Tags:       make([]string, len(tags)),
+		Files:      make([]exportFile, len(files)),
+		Created:    s.Created,
+		Expires:    s.Expires,
+	}
+
+	for i, t := range tags {
+		e.Tags[i] = t.Name
+	}
+	for i, f := range files {
+		e.Files[i] = exportFile{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	return e
+}
+
+// gist is a GitHub Gist as the GitHub API returns it. Only what an import
+// needs is read.
+type gist struct {
+	Description string              `json:"description"`
+	Public      bool                `json:"public"`
+	Files       map[string]gistFile `json:"files"`
+}
+
+type gistFile struct {
+	Filename string `json:"filename"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+var errInvalidImport = errors.New("import: not a Snippetbox export or a GitHub Gist export")
+
+func (app *application) accountImport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	app.render(w, http.StatusOK, "import.tmpl.html", data)
+}
+
+// accountExport sends the user a zip of their snippets, with their tags and
+// files, and of the comments they wrote. By default the archive holds
+// snippets.json and comments.json, and snippets.json can be imported again;
+// with ?format=csv it holds snippets.csv and comments.csv instead, for
+// spreadsheets. The archive is written out as it is read from the database,
+// a batch at a time.
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("snippetbox-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	zw := zip.NewWriter(w)
+
+	var err error
+	if format == "csv" {
+		err = app.exportCSV(zw, app.viewerID(r))
+	} else {
+		err = app.exportJSON(zw, app.viewerID(r))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+
+	// The response has started, so it is too late for an error page. Leaving
+	// the archive unfinished at least makes it unreadable.
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// exportJSON writes snippets.json and comments.json to zw, each a JSON array.
+func (app *application) exportJSON(zw *zip.Writer, userID int) error {
+	f, err := zw.Create("snippets.json")
+	if err != nil {
+		return err
+	}
+
+	snippets := &jsonArray{w: f}
+	err = app.exportSnippets(userID, func(s exportSnippet) error {
+		return snippets.add(s)
+	})
+	if err != nil {
+		return err
+	}
+	if err = snippets.close(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("comments.json")
+	if err != nil {
+		return err
+	}
+
+	comments := &jsonArray{w: f}
+	err = app.exportComments(userID, func(c *models.Comment) error {
+		// Authors get back the content of their own comments even when a
+		// moderator hid it
+		out := newAPIComment(c)
+		out.Content = c.Content
+		return comments.add(out)
+	})
+	if err != nil {
+		return err
+	}
+
+	return comments.close()
+}
+
+// exportCSV writes snippets.csv and comments.csv to zw. The extra files of
+// snippets are left out.
+func (app *application) exportCSV(zw *zip.Writer, userID int) error {
+	f, err := zw.Create("snippets.csv")
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"id", "title", "format", "language", "visibility", "tags", "created", "expires", "content"})
+
+	err = app.exportSnippets(userID, func(s exportSnippet) error {
+		return cw.Write([]string{strconv.Itoa(s.ID), s.Title, s.Format, s.Language, s.Visibility,
+			strings.Join(s.Tags, ","), s.Created.Format(time.RFC3339), s.Expires.Format(time.RFC3339), s.Content})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("comments.csv")
+	if err != nil {
+		return err
+	}
+
+	cw = csv.NewWriter(f)
+	cw.Write([]string{"id", "snippet_id", "parent_id", "created", "updated", "upvotes", "downvotes", "content"})
+
+	err = app.exportComments(userID, func(c *models.Comment) error {
+		parentID := ""
+		if c.ParentID != nil {
+			parentID = strconv.Itoa(*c.ParentID)
+		}
+
+		return cw.Write([]string{strconv.Itoa(c.ID), strconv.Itoa(c.SnippetID), parentID,
+			c.Created.Format(time.RFC3339), c.Updated.Format(time.RFC3339),
+			strconv.Itoa(c.Upvotes), strconv.Itoa(c.Downvotes), c.Content})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportSnippets calls fn with each of the user's snippets, along with its
+// tags and files, reading them exportBatchSize at a time.
+func (app *application) exportSnippets(userID int, fn func(exportSnippet) error) error {
+	afterID := 0
+
+	for {
+		snippets, err := app.exports.Snippets(userID, afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range snippets {
+			tags, err := app.tags.GetBySnippet(s.ID)
+			if err != nil {
+				return err
+			}
+
+			files, err := app.snippetFiles.ForSnippet(s.ID)
+			if err != nil {
+				return err
+			}
+
+			if err = fn(newExportSnippet(s, tags, files)); err != nil {
+				return err
+			}
+			afterID = s.ID
+		}
+
+		if len(snippets) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// exportComments calls fn with each of the comments the user wrote, reading
+// them exportBatchSize at a time.
+func (app *application) exportComments(userID int, fn func(*models.Comment) error) error {
+	afterID := 0
+
+	for {
+		comments, err := app.exports.Comments(userID, afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range comments {
+			if err = fn(c); err != nil {
+				return err
+			}
+			afterID = c.ID
+		}
+
+		if len(comments) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// jsonArray writes a JSON array to w an element at a time.
+type jsonArray struct {
+	w io.Writer
+	n int
+}
+
+func (a *jsonArray) add(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if a.n == 0 {
+		sep = "[\n"
+	}
+	a.n++
+
+	_, err = a.w.Write(append([]byte(sep), b...))
+	return err
+}
+
+func (a *jsonArray) close() error {
+	end := "\n]\n"
+	if a.n == 0 {
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// accountImportPost recreates snippets from an uploaded file, as the user's
+// own: either an archive from accountExport, or GitHub Gists as the GitHub
+// API returns them, one gist or a list. Snippets that would not pass the
+// create form, or that have expired, are skipped and counted. Comments are
+// not imported, as they were written on snippets that may not be the user's.
+func (app *application) accountImportPost(w http.ResponseWriter, r *http.Request) {
+	fail := func(message string) {
+		app.flash(r, message)
+		http.Redirect(w, r, "/account/import", http.StatusSeeOther)
+	}
+
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			fail("Choose a file to import.")
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	tooLarge := fmt.Sprintf("Imports can be at most %d MB.", maxImportBytes>>20)
+	if header.Size > maxImportBytes {
+		fail(tooLarge)
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(file, maxImportBytes+1))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(b) > maxImportBytes {
+		fail(tooLarge)
+		return
+	}
+
+	snippets, err := parseImport(b)
+	if err != nil {
+		fail("That file is not a Snippetbox export or a GitHub Gist export.")
+		return
+	}
+
+	if len(snippets) > maxImportSnippets {
+		fail(fmt.Sprintf("An import can have at most %d snippets.", maxImportSnippets))
+		return
+	}
+
+	userID := app.viewerID(r)
+	now := time.Now().UTC()
+	imported, skipped := 0, 0
+
+	for _, s := range snippets {
+		var v validator.Validator
+
+		tags, files := checkImportSnippet(&v, &s, now)
+		if !v.Valid() {
+			skipped++
+			continue
+		}
+
+		opts := models.SnippetOptions{Format: s.Format, Language: s.Language, Visibility: s.Visibility, Expires: s.Expires}
+
+		id, err := app.snippets.InsertWithOptions(s.Title, s.Content, opts, 0, userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.tags.AttachToSnippet(id, tags)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if len(files) > 0 {
+			err = app.snippetFiles.Replace(id, files)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		imported++
+	}
+
+	locale := app.locale(r)
+	message := locale.T("Imported %d snippet(s).", imported)
+	if skipped > 0 {
+		message += " " + locale.T("%d could not be imported, as they were not valid or had expired.", skipped)
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", message)
+	http.Redirect(w, r, "/account/import", http.StatusSeeOther)
+}
+
+// checkImportSnippet checks an imported snippet as the create form would,
+// filling in the defaults of the fields left empty, and returns its tags and
+// files. A snippet that expires before now is not valid.
+func checkImportSnippet(v *validator.Validator, s *exportSnippet, now time.Time) ([]string, []*models.SnippetFile) {
+	if s.Format == "" {
+		s.Format = models.FormatPlain
+	}
+	if s.Visibility == "" {
+		s.Visibility = models.VisibilityPublic
+	}
+	if s.Expires.IsZero() {
+		s.Expires = models.NeverExpires
+	}
+
+	v.CheckField(validator.NotBlank(s.Title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(s.Title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(s.Content), "content", "This field cannot be blank")
+	v.CheckField(validator.PermittedValue(s.Format, models.FormatPlain, models.FormatMarkdown), "format", "This field must be plain or markdown")
+	v.CheckField(syntax.Supported(s.Language), "language", "This language is not supported")
+	v.CheckField(validator.PermittedValue(s.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must be public, unlisted or private")
+	v.CheckField(s.Expires.After(now), "expires", "This snippet has expired")
+
+	tags := models.ParseTags(strings.Join(s.Tags, ","))
+	v.CheckField(len(tags) <= models.MaxTagsPerSnippet, "tags", fmt.Sprintf("This field cannot have more than %d tags", models.MaxTagsPerSnippet))
+	for _, tag := range tags {
+		v.CheckField(validator.MaxChars(tag, models.MaxTagLength), "tags", fmt.Sprintf("Tags cannot be more than %d characters long", models.MaxTagLength))
+		v.CheckField(validator.Matches(tag, validator.TagRX), "tags", "Tags can only contain letters, digits, _, + and -")
+	}
+
+	forms := make([]snippetFileForm, len(s.Files))
+	for i, f := range s.Files {
+		forms[i] = snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	return tags, checkSnippetFiles(v, forms)
+}
+
+// parseImport reads the snippets of an import: the snippets.json of an
+// export archive, or a gist or a JSON list of gists.
+func parseImport(b []byte) ([]exportSnippet, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err == nil {
+		return parseExportArchive(zr)
+	}
+
+	var gists []gist
+
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		var g gist
+		err = json.Unmarshal(b, &g)
+		gists = []gist{g}
+	} else {
+		err = json.Unmarshal(b, &gists)
+	}
+	if err != nil || len(gists) == 0 {
+		return nil, errInvalidImport
+	}
+
+	snippets := make([]exportSnippet, len(gists))
+	for i, g := range gists {
+		if len(g.Files) == 0 {
+			return nil, errInvalidImport
+		}
+		snippets[i] = newGistSnippet(g)
+	}
+
+	return snippets, nil
+}
+
+// parseExportArchive reads snippets.json out of an archive from
+// accountExport.
+func parseExportArchive(zr *zip.Reader) ([]exportSnippet, error) {
+	f, err := zr.Open("snippets.json")
+	if err != nil {
+		return nil, errInvalidImport
+	}
+	defer f.Close()
+
+	var snippets []exportSnippet
+
+	err = json.NewDecoder(io.LimitReader(f, maxImportBytes)).Decode(&snippets)
+	if err != nil {
+		return nil, errInvalidImport
+	}
+
+	return snippets, nil
+}
+
+// newGistSnippet turns a gist into a snippet that never expires. Its files
+// are taken in name order, as GitHub shows them: the first is the snippet's
+// content and the others its extra files. The description, or failing that
+// the first file name, is the title. Secret gists become unlisted snippets.
+func newGistSnippet(g gist) exportSnippet {
+	names := make([]string, 0, len(g.Files))
+	for name := range g.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	first := g.Files[names[0]]
+
+	s := exportSnippet{
+		Title:      strings.TrimSpace(g.Description),
+		Content:    first.Content,
+		Format:     models.FormatPlain,
+		Language:   gistLanguage(first.Language),
+		Visibility: models.VisibilityUnlisted,
+		Expires:    models.NeverExpires,
+	}
+
+	if strings.EqualFold(first.Language, "markdown") {
+		s.Format = models.FormatMarkdown
+	}
+	if g.Public {
+		s.Visibility = models.VisibilityPublic
+	}
+
+	if s.Title == "" {
+		s.Title = names[0]
+	}
+	if utf8.RuneCountInString(s.Title) > 100 {
+		s.Title = string([]rune(s.Title)[:100])
+	}
+
+	for _, name := range names[1:] {
+		f := g.Files[name]
+		s.Files = append(s.Files, exportFile{Name: name, Language: gistLanguage(f.Language), Content: f.Content})
+	}
+
+	return s
+}
+
+// gistLanguage returns the supported language GitHub calls name, e.g. "Go"
+// or "Shell", or plain text if none is.
+func gistLanguage(name string) string {
+	for _, lang := range syntax.Languages {
+		if strings.EqualFold(name, lang.Label) || strings.EqualFold(name, lang.Name) {
+			return lang.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// readZip returns the files of a zip archive by name.
+func readZip(t *testing.T, b []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+
+	return files
+}
+
+func TestAccountExport(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/account/export")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	loginAs(t, srv, "jay@email.com")
+
+	code, headers, body := srv.get(t, "/account/export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/zip")
+	assert.StringContains(t, headers.Get("Content-Disposition"), "attachment; filename=snippetbox-")
+
+	files := readZip(t, []byte(body))
+	assert.Equal(t, len(files), 2)
+	assert.StringContains(t, files["snippets.json"], `"title":"An old silent pond"`)
+	assert.StringContains(t, files["snippets.json"], `"tags":["haiku"]`)
+	assert.StringContains(t, files["snippets.json"], `"name":"frog.py"`)
+	assert.StringContains(t, files["comments.json"], `"content":"What a lovely haiku"`)
+
+	code, _, body = srv.get(t, "/account/export?format=csv")
+	assert.Equal(t, code, http.StatusOK)
+
+	files = readZip(t, []byte(body))
+	assert.Equal(t, len(files), 2)
+	assert.StringContains(t, files["snippets.csv"], "1,An old silent pond,plain,go,public,haiku,")
+	assert.StringContains(t, files["comments.csv"], ",What a lovely haiku\n")
+
+	code, _, _ = srv.get(t, "/account/export?format=xml")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestAccountImport(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	f, err := zw.Create("snippets.json")
+	assert.NilError(t, err)
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	_, err = io.WriteString(f, `[
+		{"title": "Kept", "content": "one", "language": "go", "tags": ["haiku"], "expires": "`+expires+`",
+		 "files": [{"name": "notes.txt", "content": "two"}]},
+		{"title": "Expired", "content": "three", "expires": "2020-01-01T00:00:00Z"},
+		{"title": "Unknown language", "content": "four", "language": "cobol"}
+	]`)
+	assert.NilError(t, err)
+	assert.NilError(t, zw.Close())
+
+	tests := []struct {
+		name      string
+		filename  string
+		content   []byte
+		wantFlash string
+	}{
+		{"No file", "", nil, "Choose a file to import."},
+		{"Export", "snippetbox.zip", archive.Bytes(), "Imported 1 snippet(s). 2 could not be imported, as they were not valid or had expired."},
+		{"Gist", "gist.json", []byte(`{"description": "Hello", "public": true, "files": {"hello.go": {"language": "Go", "content": "package main"}}}`), "Imported 1 snippet(s)."},
+		{"Gists", "gists.json", []byte(`[{"files": {"a.sh": {"language": "Shell", "content": "ls"}}}, {"files": {"b.txt": {"content": "b"}}}]`), "Imported 2 snippet(s)."},
+		{"Not an export", "notes.txt", []byte("Just some notes"), "That file is not a Snippetbox export or a GitHub Gist export."},
+		{"Zip without snippets", "other.zip", readmeZip(t), "That file is not a Snippetbox export or a GitHub Gist export."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := url.Values{"csrf_token": {csrfToken}}
+
+			code, headers, _ := srv.do(t, uploadRequest(t, "/account/import", fields, "archive", tt.filename, tt.content))
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/account/import")
+
+			_, _, body := srv.get(t, "/account/import")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func readmeZip(t *testing.T) []byte {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	f, err := zw.Create("README")
+	assert.NilError(t, err)
+	_, err = io.WriteString(f, "Hello")
+	assert.NilError(t, err)
+	assert.NilError(t, zw.Close())
+	return b.Bytes()
+}
+
+func TestNewGistSnippet(t *testing.T) {
+	s := newGistSnippet(gist{
+		Files: map[string]gistFile{
+			"b.py":      {Language: "Python", Content: "print(1)"},
+			"README.md": {Language: "Markdown", Content: "# Read me"},
+		},
+	})
+
+	// Files come in name order, and the first is the content
+	assert.Equal(t, s.Title, "README.md")
+	assert.Equal(t, s.Content, "# Read me")
+	assert.Equal(t, s.Format, models.FormatMarkdown)
+	assert.Equal(t, s.Language, "")
+	assert.Equal(t, s.Visibility, models.VisibilityUnlisted)
+	assert.Equal(t, s.Expires, models.NeverExpires)
+	assert.Equal(t, len(s.Files), 1)
+	assert.Equal(t, s.Files[0].Name, "b.py")
+	assert.Equal(t, s.Files[0].Language, "python")
+}
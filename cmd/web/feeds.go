@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// atomFeed is the root element of an Atom 1.0 document (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+
+	// public feeds hold nothing but what anyone can see, so shared caches
+	// may keep them.
+	public bool
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Author    atomAuthor `xml:"author"`
+	Link      atomLink   `xml:"link"`
+	Content   atomText   `xml:"content"`
+}
+
+// feedEntries is the number of snippets in the site, tag and user feeds.
+const feedEntries = 20
+
+// feedMaxAge is how long clients and caches may keep a feed before asking
+// for it again.
+const feedMaxAge = 5 * time.Minute
+
+// atomTime formats a timestamp the way Atom expects (RFC 3339, UTC).
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// baseURL returns the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// newCommentsFeed builds an Atom feed for the comments of a snippet. Comment
+// content is sent as plain text so feed readers escape it themselves.
+// Comments hidden by a moderator are left out.
+func newCommentsFeed(base string, snippet *models.Snippet, comments []*models.Comment) *atomFeed {
+	snippetURL := fmt.Sprintf("%s/snippet/view/%d", base, snippet.ID)
+
+	feed := &atomFeed{
+		ID:    snippetURL + "/comments.atom",
+		Title: fmt.Sprintf("Comments on %s", snippet.Title),
+		Link: []atomLink{
+			{Href: snippetURL + "/comments.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: snippetURL, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+		public:  snippet.Visibility == models.VisibilityPublic,
+	}
+
+	updated := snippet.Created
+	for _, c := range comments {
+		if c.Hidden() {
+			continue
+		}
+
+		if c.Updated.After(updated) {
+			updated = c.Updated
+		}
+
+		permalink := fmt.Sprintf("%s#comment-%d", snippetURL, c.ID)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        permalink,
+			Title:     fmt.Sprintf("Comment by %s", c.Author),
+			Updated:   atomTime(c.Updated),
+			Published: atomTime(c.Created),
+			Author:    atomAuthor{Name: c.Author},
+			Link:      atomLink{Href: permalink, Rel: "alternate"},
+			Content:   atomText{Type: "text", Body: c.Content},
+		})
+	}
+	feed.Updated = atomTime(updated)
+
+	return feed
+}
+
+// newSnippetsFeed builds an Atom feed of snippets, served at path and listing
+// the snippets of the page at alternate. names maps the IDs of the snippet
+// authors to their names. Snippet content is sent as plain text, like
+// comments.
+func newSnippetsFeed(base, path, alternate, title string, snippets []*models.Snippet, names map[int]string) *atomFeed {
+	feed := &atomFeed{
+		ID:    base + path,
+		Title: title,
+		Link: []atomLink{
+			{Href: base + path, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + alternate, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+		public:  true,
+	}
+
+	var updated time.Time
+	for _, s := range snippets {
+		if s.Created.After(updated) {
+			updated = s.Created
+		}
+
+		author := names[s.UserID]
+		if author == "" {
+			author = "Anonymous"
+		}
+
+		permalink := fmt.Sprintf("%s/snippet/view/%d", base, s.ID)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        permalink,
+			Title:     s.Title,
+			Updated:   atomTime(s.Created),
+			Published: atomTime(s.Created),
+			Author:    atomAuthor{Name: author},
+			Link:      atomLink{Href: permalink, Rel: "alternate"},
+			Content:   atomText{Type: "text", Body: s.Content},
+		})
+	}
+
+	// An empty feed was last updated when it was asked for
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed.Updated = atomTime(updated)
+
+	return feed
+}
+
+// authorNames returns the names of the authors of the snippets by user ID.
+// Deleted users are left out.
+func (app *application) authorNames(snippets []*models.Snippet) (map[int]string, error) {
+	names := make(map[int]string)
+
+	for _, s := range snippets {
+		if _, ok := names[s.UserID]; ok || s.UserID == 0 {
+			continue
+		}
+
+		usr, err := app.users.Get(s.UserID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				names[s.UserID] = ""
+				continue
+			}
+			return nil, err
+		}
+		names[s.UserID] = usr.Name
+	}
+
+	return names, nil
+}
+
+// writeFeed sends an Atom feed, which feed readers polling it only download
+// again once it has changed.
+func (app *application) writeFeed(w http.ResponseWriter, r *http.Request, feed *atomFeed) {
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	serveCacheable(w, r, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), out...), feed.public, feedMaxAge)
+}
@@ -1,28 +1,108 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"snippetbox.jmorelli.dev/internal/markdown"
 	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/oauth"
+	"snippetbox.jmorelli.dev/internal/syntax"
+	"snippetbox.jmorelli.dev/internal/totp"
 	"snippetbox.jmorelli.dev/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string            `form:"title"`
+	Content             string            `form:"content"`
+	Format              string            `form:"format"`
+	Language            string            `form:"language"`
+	Visibility          string            `form:"visibility"`
+	Expires             string            `form:"expires"`
+	ExpiresOn           string            `form:"expires_on"`
+	Tags                string            `form:"tags"`
+	Files               []snippetFileForm `form:"files"`
 	validator.Validator `form:"-"`
 }
 
+// snippetFileForm is one of the extra files of a snippet. Files left blank
+// are ignored.
+type snippetFileForm struct {
+	Name     string `form:"name"`
+	Language string `form:"language"`
+	Content  string `form:"content"`
+}
+
 type commentCreateForm struct {
 	Content             string `form:"content"`
 	Author              string `form:"author"`
 	Snippet_ID          int    `form:"snippet_id"`
+	Parent_ID           int    `form:"parent_id"`
+	validator.Validator `form:"-"`
+}
+
+type snippetEditForm struct {
+	Title               string            `form:"title"`
+	Content             string            `form:"content"`
+	Files               []snippetFileForm `form:"files"`
+	validator.Validator `form:"-"`
+}
+
+type snippetExpiresForm struct {
+	Expires             string `form:"expires"`
+	ExpiresOn           string `form:"expires_on"`
+	validator.Validator `form:"-"`
+}
+
+type shareLinkForm struct {
+	Expires             int `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+type moderatorNoteForm struct {
+	Note                string `form:"note"`
+	validator.Validator `form:"-"`
+}
+
+type commentReportForm struct {
+	Reason              string `form:"reason"`
+	validator.Validator `form:"-"`
+}
+
+type commentReactForm struct {
+	Reaction            string `form:"reaction"`
+	validator.Validator `form:"-"`
+}
+
+type rejectMatchingForm struct {
+	Pattern             string `form:"pattern"`
+	Reason              string `form:"reason"`
+	DryRun              bool   `form:"dry_run"`
+	validator.Validator `form:"-"`
+}
+
+type searchForm struct {
+	Query               string `form:"q"`
+	Scope               string `form:"scope"`
+	validator.Validator `form:"-"`
+}
+
+type auditFilterForm struct {
+	UserID              int    `form:"user"`
+	Action              string `form:"action"`
 	validator.Validator `form:"-"`
 }
 
@@ -39,6 +119,47 @@ type userLoginForm struct {
 	validator.Validator `form:"-"`
 }
 
+type accountSettingsForm struct {
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	Digest              bool   `form:"digest"`
+	validator.Validator `form:"-"`
+}
+
+type twoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+type apiTokenForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	validator.Validator `form:"-"`
+}
+
+// HasScope reports whether scope is ticked on the form.
+func (f apiTokenForm) HasScope(scope string) bool {
+	for _, s := range f.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type passwordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type passwordResetForm struct {
+	Token                   string `form:"token"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
 type passwordUpdateForm struct {
 	OldPassword             string `form:"oldPassword"`
 	NewPassword             string `form:"newPassword"`
@@ -46,8 +167,29 @@ type passwordUpdateForm struct {
 	validator.Validator     `form:"-"`
 }
 
+// commentsPageSize is the number of comments shown per page on the snippet
+// page.
+const commentsPageSize = 20
+
+// relatedSnippets is the number of related snippets listed on the snippet
+// page.
+const relatedSnippets = 5
+
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	language := r.URL.Query().Get("language")
+	if !syntax.Supported(language) {
+		app.notFound(w)
+		return
+	}
+
+	page := app.pageParam(r)
+	snippets, total, err := app.snippets.LatestByLanguage(language, page, models.DefaultSnippetPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.countComments(r, snippets)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -55,18 +197,38 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
-	for _, snippet := range snippets {
-		comments, err := app.comments.GetBySnippetID(snippet.ID)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		snippet.CommentsNumber = len(comments)
+	data.Language = language
+	data.FeedURL = "/feed.xml"
+	data.Pagination = &pagination{Path: "/", Page: page, PageSize: models.DefaultSnippetPageSize, Total: total}
+	if language != "" {
+		data.Pagination.Params = url.Values{"language": {language}}
 	}
 
 	app.render(w, http.StatusOK, "home.tmpl.html", data)
 }
 
+// trending lists public snippets ranked by their time-decayed score.
+func (app *application) trending(w http.ResponseWriter, r *http.Request) {
+	page := app.pageParam(r)
+	snippets, total, err := app.snippets.Trending(page, models.DefaultSnippetPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.countComments(r, snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = &pagination{Path: "/trending", Page: page, PageSize: models.DefaultSnippetPageSize, Total: total}
+
+	app.render(w, http.StatusOK, "trending.tmpl.html", data)
+}
+
 func (app *application) about(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
@@ -83,7 +245,9 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	snippet, err := app.snippets.Get(id)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
+		if errors.Is(err, models.ErrExpired) {
+			app.gone(w, r)
+		} else if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
 			app.serverError(w, err)
@@ -91,11 +255,56 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	modified, err := app.snippets.LastModified(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	tags, err := app.tags.GetBySnippet(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	forks, err := app.snippets.Forks(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	files, err := app.snippetFiles.ForSnippet(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	related, err := app.snippets.Related(id, relatedSnippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Tags = tags
+	data.Forks = forks
+	data.Related = related
+	data.Files = files
+	data.IsOwner = app.ownsSnippet(r, snippet)
+	data.FeedURL = fmt.Sprintf("/snippet/view/%d/comments.atom", snippet.ID)
+	if snippet.Visibility != models.VisibilityPrivate {
+		// These are absolute, for use on other sites, so escape the host
+		base := baseURL(r)
+		data.EmbedURL = html.EscapeString(fmt.Sprintf("%s/snippet/embed/%d/embed.js", base, snippet.ID))
+		data.OEmbedURL = html.EscapeString(base + "/oembed?url=" + url.QueryEscape(fmt.Sprintf("%s/snippet/view/%d", base, snippet.ID)))
+	}
 
 	// Comments
-	comments, err := app.comments.GetBySnippetID(id)
+	viewerID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	sort := app.commentSort(r)
+	page := app.pageParam(r)
+	comments, total, err := app.comments.GetBySnippetIDForViewerPagedContext(r.Context(), id, viewerID, sort, page, commentsPageSize)
 
 	if err != nil {
 		app.serverError(w, err)
@@ -103,6 +312,18 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data.Comments = comments
+	data.CommentSort = string(sort)
+	data.Mentions, err = app.commentMentions(comments)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Attachments, err = app.commentAttachments(comments)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Pagination = &pagination{Path: fmt.Sprintf("/snippet/view/%d", id), Page: page, PageSize: commentsPageSize, Total: total}
 
 	// User
 
@@ -120,9 +341,14 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		}
 
 		data.User = usr
+		data.Starred, err = app.favorites.Starred(id, user_id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 		data.Form = commentCreateForm{
 			Snippet_ID: id,
-			Author:		usr.Name,
+			Author:     usr.Name,
 		}
 	} else {
 		data.Form = commentCreateForm{
@@ -130,104 +356,2754 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	app.renderCacheable(w, r, "view.tmpl.html", data, modified)
 }
 
-func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	name := params.ByName("name")
+
+	snippets, err := app.tags.GetSnippetsByTag(name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	data.Tag = name
+	data.Snippets = snippets
+	data.FeedURL = "/tag/" + url.PathEscape(name) + "/feed.xml"
+
+	app.render(w, http.StatusOK, "tag.tmpl.html", data)
+}
+
+func (app *application) snippetCommentsFeed(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl.html", data)
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	comments, err := app.comments.GetBySnippetIDContext(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeFeed(w, r, newCommentsFeed(baseURL(r), snippet, comments))
 }
 
-func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	var form snippetCreateForm
+// latestFeed is the Atom feed of the latest public snippets.
+func (app *application) latestFeed(w http.ResponseWriter, r *http.Request) {
+	snippets, _, err := app.snippets.Latest(1, feedEntries)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	err := app.decodePostForm(r, &form)
+	names, err := app.authorNames(snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeFeed(w, r, newSnippetsFeed(baseURL(r), "/feed.xml", "/", "Latest snippets", snippets, names))
+}
+
+// tagFeed is the Atom feed of the latest public snippets with a tag.
+func (app *application) tagFeed(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	snippets, err := app.tags.GetSnippetsByTag(name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(snippets) > feedEntries {
+		snippets = snippets[:feedEntries]
+	}
+
+	names, err := app.authorNames(snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	path := "/tag/" + url.PathEscape(name)
+	app.writeFeed(w, r, newSnippetsFeed(baseURL(r), path+"/feed.xml", path, fmt.Sprintf("Snippets tagged %s", name), snippets, names))
+}
+
+// userFeed is the Atom feed of a user's latest public snippets.
+func (app *application) userFeed(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	snippets, _, err := app.snippets.ByAuthor(id, 1, feedEntries)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	path := fmt.Sprintf("/user/profile/%d", id)
+	names := map[int]string{id: usr.Name}
+	app.writeFeed(w, r, newSnippetsFeed(baseURL(r), path+"/feed.xml", path, fmt.Sprintf("Snippets by %s", usr.Name), snippets, names))
+}
+
+// rawMaxAge is how long clients may keep the raw content of a snippet.
+const rawMaxAge = 5 * time.Minute
+
+// snippetRaw sends the content of a snippet as plain text.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	serveCacheable(w, r, "text/plain; charset=utf-8", []byte(snippet.Content), snippet.Visibility == models.VisibilityPublic, rawMaxAge)
+}
+
+// snippetFileRaw sends the content of one of the extra files of a snippet as
+// plain text.
+func (app *application) snippetFileRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	file, err := app.snippetFiles.Get(snippet.ID, httprouter.ParamsFromContext(r.Context()).ByName("name"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	serveCacheable(w, r, "text/plain; charset=utf-8", []byte(file.Content), snippet.Visibility == models.VisibilityPublic, rawMaxAge)
+}
+
+// snippetDownload sends the content of a snippet as a file to save, named
+// after its title and language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(snippet)}))
+
+	serveCacheable(w, r, "text/plain; charset=utf-8", []byte(snippet.Content), snippet.Visibility == models.VisibilityPublic, rawMaxAge)
+}
+
+// searchResultsLimit is the maximum number of results shown by /search.
+const searchResultsLimit = 50
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	var form searchForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.PermittedValue(form.Scope, "", "snippets", "comments"), "scope", "This field must equal snippets or comments")
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "search.tmpl.html", data)
+		return
+	}
+
+	if validator.NotBlank(form.Query) {
+		results, err := app.searches.Search(form.Query, models.SearchScope(form.Scope), searchResultsLimit)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.SearchResults = results
+	}
+
+	app.render(w, http.StatusOK, "search.tmpl.html", data)
+}
+
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = snippetCreateForm{
+		Format:     models.FormatPlain,
+		Visibility: models.VisibilityPublic,
+		Expires:    "365",
+	}
+
+	app.render(w, http.StatusOK, "create.tmpl.html", data)
+}
+
+func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Format, models.FormatPlain, models.FormatMarkdown), "format", "This field must be plain or markdown")
+	form.CheckField(syntax.Supported(form.Language), "language", "This language is not supported")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must be public, unlisted or private")
+	expires := checkExpiry(&form.Validator, form.Expires, form.ExpiresOn, time.Now().UTC())
+
+	tags := models.ParseTags(form.Tags)
+	form.CheckField(len(tags) <= models.MaxTagsPerSnippet, "tags", fmt.Sprintf("This field cannot have more than %d tags", models.MaxTagsPerSnippet))
+	for _, tag := range tags {
+		form.CheckField(validator.MaxChars(tag, models.MaxTagLength), "tags", fmt.Sprintf("Tags cannot be more than %d characters long", models.MaxTagLength))
+		form.CheckField(validator.Matches(tag, validator.TagRX), "tags", "Tags can only contain letters, digits, _, + and -")
+	}
+
+	files := checkSnippetFiles(&form.Validator, form.Files)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		fmt.Println(form)
+		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	opts := models.SnippetOptions{Format: form.Format, Language: form.Language, Visibility: form.Visibility, Expires: expires}
+
+	id, err := app.snippets.InsertWithOptions(form.Title, form.Content, opts, 0, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tags.AttachToSnippet(id, tags)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if len(files) > 0 {
+		err = app.snippetFiles.Replace(id, files)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.webhookSnippetCreated(id)
+
+	app.flash(r, "Snippet successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+// loadSnippet returns the snippet named by the :id parameter, writing a 404
+// (or the 410 page, if it has expired) and returning false if there is no
+// such snippet. With mustOwn set, it writes a 403 unless the snippet belongs
+// to the authenticated user.
+func (app *application) loadSnippet(w http.ResponseWriter, r *http.Request, mustOwn bool) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrExpired) {
+			app.gone(w, r)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if mustOwn {
+		ok, err := app.canModify(r, snippet.UserID, models.RoleAdmin)
+		if err != nil {
+			app.serverError(w, err)
+			return nil, false
+		}
+		if !ok {
+			app.clientError(w, http.StatusForbidden)
+			return nil, false
+		}
+	}
+
+	return snippet, true
+}
+
+// snippetExpiresPost changes when a snippet expires, counting from now, so
+// that its author can keep it longer or get rid of it sooner. Admins can
+// change anyone's.
+func (app *application) snippetExpiresPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	var form snippetExpiresForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	expires := checkExpiry(&form.Validator, form.Expires, form.ExpiresOn, time.Now().UTC())
+
+	redirect := fmt.Sprintf("/snippet/view/%d", snippet.ID)
+
+	if !form.Valid() {
+		app.flash(r, form.FieldErrors["expires"])
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	err = app.snippets.SetExpires(snippet.ID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if expires.Equal(models.NeverExpires) {
+		app.flash(r, "The snippet will be kept until you delete it.")
+	} else {
+		app.flash(r, "The snippet now expires on %s.", app.locale(r).Date(expires))
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// snippetDeletePost deletes a snippet. Admins can delete anyone's.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.snippets.Delete(snippet.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, userID, models.AuditSnippetDelete, fmt.Sprintf("snippet %d", snippet.ID))
+
+	app.flash(r, "Snippet deleted.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// loadRevision returns the revision named by the :revision parameter of the
+// given snippet, writing a 404 and returning false if there is none.
+func (app *application) loadRevision(w http.ResponseWriter, r *http.Request, snippetID int) (*models.SnippetRevision, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("revision"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	revision, err := app.snippets.GetRevision(snippetID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	files, err := app.snippetFiles.ForSnippet(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+		Files:   snippetFileForms(files),
+	}
+
+	app.render(w, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+
+	files := checkSnippetFiles(&form.Validator, form.Files)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.snippetFiles.Replace(snippet.ID, files)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// forkExpires is the number of days a forked snippet is kept.
+const forkExpires = 365
+
+// snippetForkPost copies a snippet into a new one owned by the current user.
+func (app *application) snippetForkPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := app.snippets.Fork(snippet.ID, userID, forkExpires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.webhookSnippetCreated(id)
+
+	app.flash(r, "Snippet successfully forked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+// snippetVotePost upvotes (value 1) or downvotes (value -1) a snippet for the
+// current user. Repeating a vote removes it.
+func (app *application) snippetVotePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	value, err := strconv.Atoi(params.ByName("value"))
+	if err != nil || (value != 1 && value != -1) {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var result models.VoteResult
+
+	if value == 1 {
+		result, err = app.snippets.Upvote(snippet.ID, userID)
+	} else {
+		result, err = app.snippets.Downvote(snippet.ID, userID)
+	}
+
+	message := result.String()
+
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrSelfVote):
+			message = "You cannot vote on your own snippet"
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+			return
+		default:
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if err == nil && upvoted(result) {
+		app.notify(r, snippet.UserID, models.NotifySnippetUpvote, snippet.ID, 0)
+	}
+
+	app.flash(r, message)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetStarPost stars a snippet for the user, or unstars it if it already is.
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	starred, err := app.favorites.Toggle(snippet.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	message := "Snippet removed from your favorites"
+	if starred {
+		message = "Snippet added to your favorites"
+	}
+	app.flash(r, message)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// favoritesView lists the snippets the user has starred, most recently starred
+// first.
+func (app *application) favoritesView(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	page := app.pageParam(r)
+
+	snippets, total, err := app.favorites.List(userID, page, models.DefaultSnippetPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.countComments(r, snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = &pagination{Path: "/favorites", Page: page, PageSize: models.DefaultSnippetPageSize, Total: total}
+
+	app.render(w, http.StatusOK, "favorites.tmpl.html", data)
+}
+
+// snippetHistory lists the earlier versions of a snippet.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippets.GetRevisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.IsOwner = app.ownsSnippet(r, snippet)
+
+	app.render(w, http.StatusOK, "history.tmpl.html", data)
+}
+
+// snippetRevision shows one earlier version of a snippet.
+func (app *application) snippetRevision(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, false)
+	if !ok {
+		return
+	}
+
+	revision, ok := app.loadRevision(w, r, snippet.ID)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revision = revision
+	data.IsOwner = app.ownsSnippet(r, snippet)
+
+	app.render(w, http.StatusOK, "revision.tmpl.html", data)
+}
+
+// snippetRevisionRestorePost makes an earlier version the current one. The
+// version being replaced is kept as a new revision, so a restore can itself
+// be undone.
+func (app *application) snippetRevisionRestorePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	revision, ok := app.loadRevision(w, r, snippet.ID)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Update(snippet.ID, revision.Title, revision.Content)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Snippet restored!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetShare lists the share links of a snippet and offers a form to
+// create a new one.
+func (app *application) snippetShare(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	app.renderShare(w, r, http.StatusOK, snippet, shareLinkForm{Expires: 24})
+}
+
+func (app *application) snippetSharePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	var form shareLinkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 24, 168), "expires", "This field must equal 1, 24 or 168")
+
+	if !form.Valid() {
+		app.renderShare(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	_, err = app.shareLinks.Create(snippet.ID, time.Duration(form.Expires)*time.Hour)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Share link created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/share/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetShareRevokePost deletes a share link, so its token stops working
+// straight away.
+func (app *application) snippetShareRevokePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.loadSnippet(w, r, true)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("link"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.shareLinks.Revoke(snippet.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Share link revoked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/share/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) renderShare(w http.ResponseWriter, r *http.Request, status int, snippet *models.Snippet, form shareLinkForm) {
+	links, err := app.shareLinks.GetBySnippet(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.ShareLinks = links
+	data.BaseURL = "https://" + r.Host
+	data.Form = form
+
+	app.render(w, status, "share.tmpl.html", data)
+}
+
+// sharedSnippetView shows a snippet read-only to anyone holding a valid share
+// token, whatever the snippet's visibility.
+func (app *application) sharedSnippetView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	link, err := app.shareLinks.Resolve(params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	snippet, err := app.snippets.Get(link.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrExpired) {
+			app.gone(w, r)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	// Keep the token out of the Referer header of outgoing links.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	app.render(w, http.StatusOK, "shared.tmpl.html", data)
+}
+
+// markdownPreview renders the posted content as Markdown and returns the HTML
+// fragment, for the preview tab of the snippet form.
+func (app *application) markdownPreview(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(markdown.Render(r.PostForm.Get("content"))))
+}
+
+func (app *application) commentCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form commentCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckText("content", form.Content, app.commentLimits)
+
+	visible, err := app.snippetVisible(r, form.Snippet_ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	if !form.Valid() {
+		app.commentFormError(w, r, form)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var id int
+
+	ctx := spamContext(r)
+	if form.Parent_ID > 0 {
+		id, err = app.comments.InsertReplyContext(ctx, form.Snippet_ID, form.Parent_ID, userID, form.Author, form.Content)
+	} else {
+		id, err = app.comments.InsertContext(ctx, form.Snippet_ID, userID, form.Author, form.Content)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrCommentHeld):
+			// Nobody else sees the comment yet, so nobody is told of it
+			app.flash(r, "Your comment is awaiting moderation.")
+			http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", form.Snippet_ID, id), http.StatusSeeOther)
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("content", "The comment you replied to no longer exists")
+			app.commentFormError(w, r, form)
+		case errors.Is(err, models.ErrDuplicatesSnippet):
+			form.AddFieldError("content", "Your comment repeats the snippet instead of discussing it")
+			app.commentFormError(w, r, form)
+		case errors.Is(err, models.ErrCommentEmpty):
+			form.AddFieldError("content", "This field cannot be blank")
+			app.commentFormError(w, r, form)
+		case errors.Is(err, models.ErrCommentTooLong):
+			form.AddFieldError("content", fmt.Sprintf("This field cannot be more than %d characters long", models.MaxCommentLength))
+			app.commentFormError(w, r, form)
+		case errors.Is(err, models.ErrTooSoon):
+			form.AddFieldError("content", "You are commenting too fast, please wait a moment")
+			app.commentFormError(w, r, form)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.notifyComment(r, form.Snippet_ID, form.Parent_ID, id, form.Content)
+	app.webhookCommentCreated(r, form.Snippet_ID, id)
+
+	app.flash(r, "Comment successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", form.Snippet_ID), http.StatusSeeOther)
+}
+
+// commentFormError re-renders the snippet page with the invalid comment form.
+func (app *application) commentFormError(w http.ResponseWriter, r *http.Request, form commentCreateForm) {
+	snippet, err := app.snippets.Get(form.Snippet_ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	viewerID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	sort := app.commentSort(r)
+	comments, total, err := app.comments.GetBySnippetIDForViewerPagedContext(r.Context(), form.Snippet_ID, viewerID, sort, 1, commentsPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	mentions, err := app.commentMentions(comments)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	tags, err := app.tags.GetBySnippet(form.Snippet_ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	usr, err := app.users.Get(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Snippet = snippet
+	data.Tags = tags
+	data.Comments = comments
+	data.CommentSort = string(sort)
+	data.Mentions = mentions
+	data.Pagination = &pagination{Path: fmt.Sprintf("/snippet/view/%d", form.Snippet_ID), Page: 1, PageSize: commentsPageSize, Total: total}
+	data.User = usr
+	app.render(w, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+}
+
+func (app *application) voteComment(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	value, err := strconv.Atoi(params.ByName("value"))
+	if err != nil || (value != 1 && value != -1) {
+		app.notFound(w)
+		return
+	}
+
+	comment, err := app.comments.GetContext(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	visible, err := app.snippetVisible(r, comment.SnippetID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	user_id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var result models.VoteResult
+
+	if value == 1 {
+		result, err = app.comments.UpvoteContext(r.Context(), id, user_id)
+	} else {
+		result, err = app.comments.DownvoteContext(r.Context(), id, user_id)
+	}
+
+	message := result.String()
+
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrSelfVote):
+			message = "You cannot vote on your own comment"
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+			return
+		default:
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if err == nil && upvoted(result) {
+		app.notify(r, comment.AuthorID, models.NotifyCommentUpvote, comment.SnippetID, comment.ID)
+	}
+
+	if err == nil {
+		app.webhookCommentVoted(r, comment, result)
+	}
+
+	app.flash(r, message)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", comment.SnippetID), http.StatusSeeOther)
+}
+
+// loadComment returns the comment named by the :id parameter, writing a 404
+// and returning false if there is none.
+func (app *application) loadComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	comment, err := app.comments.GetContext(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// commentDeletePost deletes a comment. Moderators can delete anyone's.
+func (app *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := app.canModify(r, comment.AuthorID, models.RoleModerator)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.comments.DeleteContext(r.Context(), comment.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, userID, models.AuditCommentDelete, fmt.Sprintf("comment %d", comment.ID))
+
+	app.flash(r, "Comment deleted.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", comment.SnippetID), http.StatusSeeOther)
+}
+
+// commentReportPost flags a comment for the moderators' report queue.
+func (app *application) commentReportPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentReportForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	redirect := fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, comment.ID)
+
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "Please say why you are reporting this comment")
+	form.CheckField(validator.MaxChars(form.Reason, models.MaxReportReasonLength), "reason", fmt.Sprintf("The reason cannot be more than %d characters long", models.MaxReportReasonLength))
+	if !form.Valid() {
+		app.flash(r, form.FieldErrors["reason"])
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.comments.ReportContext(r.Context(), comment.ID, userID, form.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrAlreadyReported):
+			app.flash(r, "You have already reported this comment.")
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Thanks, a moderator will look at the comment.")
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// commentReactPost adds the user's emoji reaction to a comment, or takes it
+// back if they had already left that one.
+func (app *application) commentReactPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.Hidden() {
+		app.notFound(w)
+		return
+	}
+
+	visible, err := app.snippetVisible(r, comment.SnippetID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	var form commentReactForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	_, err = app.comments.ToggleReactionContext(r.Context(), comment.ID, userID, form.Reaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidReaction):
+			app.clientError(w, http.StatusBadRequest)
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, comment.ID), http.StatusSeeOther)
+}
+
+// commentPinPost pins a comment to the top of its snippet's thread, in place
+// of any comment pinned before, or unpins it if it was pinned already. Only
+// the snippet's author and moderators can pin, and only top-level comments.
+func (app *application) commentPinPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.Deleted || comment.Hidden() {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(comment.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	ok, err = app.canModify(r, snippet.UserID, models.RoleModerator)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	redirect := fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, comment.ID)
+
+	if comment.ParentID != nil {
+		app.flash(r, "Only top-level comments can be pinned.")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	message := "Comment pinned."
+	if comment.Pinned {
+		err = app.comments.UnpinContext(r.Context(), comment.ID)
+		message = "Comment unpinned."
+	} else {
+		err = app.comments.PinContext(r.Context(), comment.ID)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, message)
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (app *application) commentNotePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	var form moderatorNoteForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	comment, err := app.comments.GetContext(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	form.CheckField(validator.MaxChars(form.Note, 500), "note", "This field cannot be more than 500 characters long")
+	if !form.Valid() {
+		app.flash(r, form.FieldErrors["note"])
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, id), http.StatusSeeOther)
+		return
+	}
+
+	err = app.comments.SetModeratorNoteContext(r.Context(), id, form.Note)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditCommentNote, fmt.Sprintf("comment %d", id))
+
+	app.flash(r, "Moderator note saved!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, id), http.StatusSeeOther)
+}
+
+func (app *application) commentRejectMatching(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = rejectMatchingForm{}
+	app.render(w, http.StatusOK, "reject.tmpl.html", data)
+}
+
+func (app *application) commentRejectMatchingPost(w http.ResponseWriter, r *http.Request) {
+	var form rejectMatchingForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Pattern), "pattern", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 255), "reason", "This field cannot be more than 255 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reject.tmpl.html", data)
+		return
+	}
+
+	if form.DryRun {
+		ids, err := app.comments.MatchingIDsContext(r.Context(), form.Pattern)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.MatchedIDs = ids
+		app.render(w, http.StatusOK, "reject.tmpl.html", data)
+		return
+	}
+
+	count, err := app.comments.RejectMatchingContext(r.Context(), form.Pattern, form.Reason)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditCommentReject, fmt.Sprintf("%d comment(s) matching %q", count, form.Pattern))
+
+	app.flash(r, "%d comments rejected.", count)
+	http.Redirect(w, r, "/admin/comments/reject", http.StatusSeeOther)
+}
+
+// adminReports lists the reported comments, most reported first, along with
+// what each report said.
+func (app *application) adminReports(w http.ResponseWriter, r *http.Request) {
+	comments, err := app.comments.GetReportedContext(r.Context(), 1)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	reports, err := app.comments.ReportsByCommentIDsContext(r.Context(), ids)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	held, err := app.comments.GetHeldContext(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Comments = comments
+	data.Reports = reports
+	data.HeldComments = held
+
+	app.render(w, http.StatusOK, "reports.tmpl.html", data)
+}
+
+// adminReportApprovePost publishes a comment held by the spam checks. Those
+// who would have heard of it when it was posted hear of it now.
+func (app *application) adminReportApprovePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.comments.ApproveContext(r.Context(), comment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditCommentApprove, fmt.Sprintf("comment %d", comment.ID))
+
+	if comment.Held() {
+		parentID := 0
+		if comment.ParentID != nil {
+			parentID = *comment.ParentID
+		}
+		app.notifyComment(r, comment.SnippetID, parentID, comment.ID, comment.Content)
+		app.webhookCommentCreated(r, comment.SnippetID, comment.ID)
+	}
+
+	app.flash(r, "Comment approved.")
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// adminReportDismissPost takes a comment out of the report queue and leaves
+// it as it is.
+func (app *application) adminReportDismissPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := app.comments.DismissReportsContext(r.Context(), comment.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditReportDismiss, fmt.Sprintf("comment %d", comment.ID))
+
+	app.flash(r, "Reports dismissed.")
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// adminReportHidePost hides a reported comment. It stays in its thread, shown
+// as removed by a moderator.
+func (app *application) adminReportHidePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.comments.HideContext(r.Context(), comment.ID, r.PostForm.Get("reason"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditCommentHide, fmt.Sprintf("comment %d", comment.ID))
+
+	app.flash(r, "Comment hidden.")
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// adminReportDeletePost deletes a reported comment and its reports.
+func (app *application) adminReportDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.loadComment(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.comments.DeleteContext(r.Context(), comment.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, userID, models.AuditCommentDelete, fmt.Sprintf("comment %d", comment.ID))
+
+	_, err = app.comments.DismissReportsContext(r.Context(), comment.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Comment deleted.")
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// adminDashboardLimit is the number of recent signups and of most-voted
+// snippets and comments shown on the admin dashboard.
+const adminDashboardLimit = 10
+
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	var totals dashboardTotals
+	var err error
+
+	totals.Users, err = app.users.Count()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	totals.Snippets, err = app.snippets.Count()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	totals.Comments, err = app.comments.CountContext(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	users, err := app.users.Recent(adminDashboardLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippets, err := app.snippets.MostVoted(adminDashboardLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	comments, err := app.comments.MostVotedContext(r.Context(), adminDashboardLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Totals = totals
+	data.Users = users
+	data.Snippets = snippets
+	data.Comments = comments
+
+	app.render(w, http.StatusOK, "admin.tmpl.html", data)
+}
+
+// adminUserParam returns the user ID in the :id parameter of an admin user
+// action, writing a 404 and returning false if it is invalid, and a flash
+// and redirect if it is the admin's own.
+func (app *application) adminUserParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return 0, false
+	}
+
+	if id == app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.flash(r, "You cannot do that to your own account.")
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// adminUserBanPost bans a user, or lifts their ban when the banned field is
+// false.
+func (app *application) adminUserBanPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	banned, err := strconv.ParseBool(r.PostForm.Get("banned"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.users.SetBanned(id, banned)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	adminID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	if banned {
+		app.audit(r, adminID, models.AuditUserBan, fmt.Sprintf("user %d", id))
+		app.flash(r, "User banned.")
+	} else {
+		app.audit(r, adminID, models.AuditUserUnban, fmt.Sprintf("user %d", id))
+		app.flash(r, "User unbanned.")
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// adminUserPurgePost deletes all of a user's comments.
+func (app *application) adminUserPurgePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	adminID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	deleted, err := app.comments.DeleteByAuthorContext(r.Context(), id, adminID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, adminID, models.AuditUserPurge, fmt.Sprintf("user %d, %d comment(s)", id, deleted))
+
+	app.flash(r, "%d comment(s) deleted.", deleted)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// adminSnippetDeletePost deletes any snippet, including private ones that
+// snippetDeletePost would not find.
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.snippets.Delete(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, userID, models.AuditSnippetDelete, fmt.Sprintf("snippet %d", id))
+
+	app.flash(r, "Snippet deleted.")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// adminUsersPageSize is the number of users per page of the admin user list.
+const adminUsersPageSize = 50
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	page := app.pageParam(r)
+
+	users, total, err := app.users.List(page, adminUsersPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Roles = models.Roles
+	data.Pagination = &pagination{Path: "/admin/users", Page: page, PageSize: adminUsersPageSize, Total: total}
+
+	app.render(w, http.StatusOK, "users.tmpl.html", data)
+}
+
+// adminUserRolePost changes a user's role. Admins cannot change their own, so
+// there is always at least one admin left.
+func (app *application) adminUserRolePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if id == app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.flash(r, "You cannot change your own role.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.SetRole(id, r.PostForm.Get("role"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrInvalidRole):
+			app.clientError(w, http.StatusBadRequest)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), models.AuditUserRole, fmt.Sprintf("user %d to %s", id, r.PostForm.Get("role")))
+
+	app.flash(r, "Role updated.")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminAuditPageSize is the number of entries per page of the audit log.
+const adminAuditPageSize = 50
+
+// adminAudit shows the audit log, newest first, narrowed down to one user or
+// one action by the ?user= and ?action= parameters.
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	var form auditFilterForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(form.UserID >= 0, "user", "This field must be a user ID")
+	form.CheckField(form.Action == "" || validator.PermittedValue(form.Action, models.AuditActions...), "action", "This field must be one of the listed actions")
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditActions = models.AuditActions
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "audit.tmpl.html", data)
+		return
+	}
+
+	page := app.pageParam(r)
+	filter := models.AuditFilter{UserID: form.UserID, Action: form.Action}
+
+	entries, total, err := app.auditLog.List(filter, page, adminAuditPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	params := url.Values{}
+	if form.UserID != 0 {
+		params.Set("user", strconv.Itoa(form.UserID))
+	}
+	if form.Action != "" {
+		params.Set("action", form.Action)
+	}
+
+	data.AuditEntries = entries
+	data.Pagination = &pagination{Path: "/admin/audit", Params: params, Page: page, PageSize: adminAuditPageSize, Total: total}
+
+	app.render(w, http.StatusOK, "audit.tmpl.html", data)
+}
+
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userSignupForm{}
+	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+}
+
+func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+	var form userSignupForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be an email")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		return
+	}
+
+	err = app.users.Insert(form.Name, form.Password, form.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email address is already in use")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		} else {
+			app.serverError(w, err)
+			return
+		}
+
+		return
+	}
+
+	err = app.sendVerification(form.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "Your signup was successful. Check your email to verify your address, then log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// emailVerificationTTL is how long an emailed verification link stays valid.
+const emailVerificationTTL = 24 * time.Hour
+
+// sendVerification emails a link that verifies the address to its owner.
+func (app *application) sendVerification(email string) error {
+	token, err := app.emailVerifications.Create(email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := app.baseURL + "/user/verify?token=" + url.QueryEscape(token)
+	body := "Welcome to Snippetbox!\n\n" +
+		"To verify your email address, open this link within the next day:\n\n" + link + "\n\n" +
+		"Until you do, you won't be able to comment or vote."
+
+	return app.mailer.Send(email, "Verify your Snippetbox email address", body)
+}
+
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	_, err := app.emailVerifications.Verify(r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidVerifyToken) {
+			app.flash(r, "This verification link is invalid or has expired.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Your email address has been verified.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) userVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.sendVerification(usr.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrAlreadyVerified) {
+			app.flash(r, "Your email address is already verified.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "We have sent you a new verification link.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userLoginForm{}
+	app.render(w, http.StatusOK, "login.tmpl.html", data)
+}
+
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+	var form userLoginForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		return
+	}
+
+	wait, err := app.loginAttempts.Backoff(form.Email, clientIP(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed logins. Please wait %d seconds before trying again.", int(math.Ceil(wait.Seconds()))))
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login.tmpl.html", data)
+		return
+	}
+
+	id, err := app.users.Authenticate(form.Email, form.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			locked, err := app.loginFailed(r, form.Email)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			if locked {
+				form.AddNonFieldError(accountLockedMessage)
+			} else {
+				form.AddNonFieldError("Email or password is incorrect")
+			}
+		case errors.Is(err, models.ErrAccountLocked):
+			app.auditFailedLogin(r, form.Email)
+			form.AddNonFieldError(accountLockedMessage)
+		case errors.Is(err, models.ErrBanned):
+			app.auditFailedLogin(r, form.Email)
+			form.AddNonFieldError("This account has been banned")
+		default:
+			app.serverError(w, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		return
+	}
+
+	err = app.loginAttempts.Clear(form.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.logIn(w, r, id)
+}
+
+// loginLockout is how long an account stays locked after app.lockoutAfter
+// failed logins in a row, unless its owner unlocks it from the emailed link.
+const loginLockout = time.Hour
+
+const accountLockedMessage = "This account has been locked after too many failed logins. We have emailed a link to unlock it, or you can try again in an hour."
+
+// loginFailed records a failed login with email, locking the account and
+// emailing its owner a link to unlock it once it has failed too many times in
+// a row. It reports whether the account is now locked. The answer is the same
+// when no account has the email address, so it cannot be used to find out who
+// has signed up.
+func (app *application) loginFailed(r *http.Request, email string) (bool, error) {
+	app.auditFailedLogin(r, email)
+
+	failures, err := app.loginAttempts.Fail(email, clientIP(r))
+	if err != nil {
+		return false, err
+	}
+
+	if app.lockoutAfter <= 0 || failures < app.lockoutAfter {
+		return false, nil
+	}
+
+	token, err := app.users.Lock(email, loginLockout)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	link := app.baseURL + "/user/unlock?token=" + url.QueryEscape(token)
+	body := "Your Snippetbox account has been locked for an hour after too many failed logins.\n\n" +
+		"If it was you, open this link to unlock it now:\n\n" + link + "\n\n" +
+		"If it wasn't, someone may be trying to guess your password. Consider changing it once you are back in."
+
+	err = app.mailer.Send(email, "Your Snippetbox account has been locked", body)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// userUnlock unlocks an account from the link emailed when it was locked.
+func (app *application) userUnlock(w http.ResponseWriter, r *http.Request) {
+	err := app.users.Unlock(r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidUnlockToken) {
+			app.flash(r, "This unlock link is invalid or has expired.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Your account has been unlocked. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// twoFactorTimeout is how long a user has to enter their second factor after
+// the first, and twoFactorAttempts how many tries they get at it.
+const (
+	twoFactorTimeout  = 5 * time.Minute
+	twoFactorAttempts = 5
+)
+
+// logIn is called once a user has proven who they are with a password or an
+// OAuth provider. Users with two-factor authentication on are sent on to
+// enter their code, with the session awaiting it rather than authenticated;
+// everyone else is logged in straight away.
+func (app *application) logIn(w http.ResponseWriter, r *http.Request, id int) {
+	enabled, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !enabled {
+		app.startSession(w, r, id)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+	app.sessionManager.Put(r.Context(), "twoFactorExpires", time.Now().Add(twoFactorTimeout).Unix())
+	app.sessionManager.Put(r.Context(), "twoFactorAttempts", 0)
+	http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+}
+
+// pendingTwoFactor returns the user whose login is awaiting their second
+// factor, if it has not timed out.
+func (app *application) pendingTwoFactor(r *http.Request) (int, bool) {
+	id := app.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+	if id == 0 {
+		return 0, false
+	}
+
+	if time.Now().Unix() > app.sessionManager.GetInt64(r.Context(), "twoFactorExpires") {
+		app.clearTwoFactor(r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) clearTwoFactor(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorExpires")
+	app.sessionManager.Remove(r.Context(), "twoFactorAttempts")
+}
+
+func (app *application) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if _, ok := app.pendingTwoFactor(r); !ok {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = twoFactorForm{}
+	app.render(w, http.StatusOK, "login2fa.tmpl.html", data)
+}
+
+func (app *application) loginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.pendingTwoFactor(r)
+	if !ok {
+		app.flash(r, "Your login timed out. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form twoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if form.Valid() {
+		err = app.twoFactor.Verify(id, form.Code)
+		if err == nil {
+			app.clearTwoFactor(r)
+			app.startSession(w, r, id)
+			return
+		}
+		if !errors.Is(err, models.ErrInvalidTOTP) {
+			app.serverError(w, err)
+			return
+		}
+
+		app.audit(r, id, models.AuditLoginFailed, "Invalid two-factor code")
+
+		attempts := app.sessionManager.GetInt(r.Context(), "twoFactorAttempts") + 1
+		if attempts >= twoFactorAttempts {
+			app.clearTwoFactor(r)
+			app.flash(r, "Too many incorrect codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "twoFactorAttempts", attempts)
+
+		form.AddFieldError("code", "Invalid code")
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusUnprocessableEntity, "login2fa.tmpl.html", data)
+}
+
+// startSession authenticates the session for a user and sends them on to the
+// page they were trying to reach before logging in, if any.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, id int) {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.audit(r, id, models.AuditLogin, r.UserAgent())
+
+	// The theme the user saved follows them to this browser
+	usr, err := app.users.Get(id)
+	if err != nil {
+		app.errorLog.Print(err)
+	} else if models.ValidTheme(usr.Theme) {
+		setThemeCookie(w, usr.Theme)
+	}
+
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if path != "" {
+		http.Redirect(w, r, path, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// oauthRedirectURL is where a provider sends users back to after they log in
+// with it.
+func (app *application) oauthRedirectURL(provider string) string {
+	return app.baseURL + "/user/oauth/" + provider + "/callback"
+}
+
+// oauthLogin sends the user to an OAuth provider to log in.
+func (app *application) oauthLogin(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "oauthLinkUserID")
+	app.redirectToProvider(w, r, name, provider)
+}
+
+// oauthLinkPost sends the user to an OAuth provider to link their account
+// with it to the one they are logged in with.
+func (app *application) oauthLinkPost(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	app.sessionManager.Put(r.Context(), "oauthLinkUserID", id)
+	app.redirectToProvider(w, r, name, provider)
+}
+
+// redirectToProvider remembers a random state in the session, which the
+// provider hands back to oauthCallback so it can tell the callback belongs to
+// a flow this session started.
+func (app *application) redirectToProvider(w http.ResponseWriter, r *http.Request, name string, provider oauth.Provider) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	app.sessionManager.Put(r.Context(), "oauthState", state)
+	http.Redirect(w, r, provider.AuthCodeURL(state, app.oauthRedirectURL(name)), http.StatusSeeOther)
+}
+
+// oauthCallback finishes logging in with an OAuth provider. A provider
+// account seen for the first time gets a new user, unless its email address
+// already has one; taking over that user would trust the provider with an
+// account it never vouched for, so its owner has to link the provider from
+// their settings instead.
+func (app *application) oauthCallback(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	title := oauthProviderTitles[name]
+
+	state := app.sessionManager.PopString(r.Context(), "oauthState")
+	linkUserID := app.sessionManager.PopInt(r.Context(), "oauthLinkUserID")
+
+	query := r.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if linkUserID != 0 && linkUserID != app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("error") != "" {
+		app.flash(r, "Logging in with %s was cancelled.", title)
+		if linkUserID != 0 {
+			http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+		} else {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		}
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), app.oauthRedirectURL(name))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if linkUserID != 0 {
+		err = app.identities.Insert(linkUserID, name, identity.ID)
+		if err != nil {
+			if errors.Is(err, models.ErrIdentityTaken) {
+				app.flash(r, "That %s account is already linked to a Snippetbox account.", title)
+				http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		app.flash(r, "Your %s account has been linked.", title)
+		http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+		return
+	}
+
+	id, err := app.identities.Authenticate(name, identity.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrBanned) {
+			app.flash(r, "This account has been banned.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		id, ok = app.oauthSignup(w, r, name, identity)
+		if !ok {
+			return
+		}
+	}
+
+	app.logIn(w, r, id)
+}
+
+// oauthSignup creates a user for a provider account and returns their ID. If
+// it cannot, it responds to the request itself and returns false.
+func (app *application) oauthSignup(w http.ResponseWriter, r *http.Request, name string, identity *oauth.Identity) (int, bool) {
+	title := oauthProviderTitles[name]
+
+	if identity.Email == "" {
+		app.flash(r, "Your %s account has no email address, so we cannot sign you up with it.", title)
+		http.Redirect(w, r, "/user/signup", http.StatusSeeOther)
+		return 0, false
+	}
+
+	userName := identity.Name
+	if userName == "" {
+		userName, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	id, err := app.identities.InsertUser(name, identity.ID, userName, identity.Email, identity.EmailVerified)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			app.flash(r, "An account already uses the email address of your %s account. Log in and link %s from your account settings.", title, title)
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return 0, false
+	}
+
+	if !identity.EmailVerified {
+		err = app.sendVerification(identity.Email)
+		if err != nil {
+			app.serverError(w, err)
+			return 0, false
+		}
+	}
+
+	return id, true
+}
+
+// oauthUnlinkPost unlinks an OAuth provider from the user's account.
+func (app *application) oauthUnlinkPost(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	title, ok := oauthProviderTitles[name]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.identities.Delete(id, name)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Your %s account has been unlinked.", title)
+	http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+}
+
+// passwordResetTTL is how long an emailed password reset link stays valid.
+const passwordResetTTL = time.Hour
+
+func (app *application) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = passwordForgotForm{}
+	app.render(w, http.StatusOK, "forgot.tmpl.html", data)
+}
+
+// passwordForgotPost emails a password reset link. The response is the same
+// whether or not an account has the address, so the form cannot be used to
+// find out who has signed up.
+func (app *application) passwordForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form passwordForgotForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "forgot.tmpl.html", data)
+		return
+	}
+
+	token, err := app.passwordResets.Create(form.Email, passwordResetTTL)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if err == nil {
+		link := app.baseURL + "/user/password/reset?token=" + url.QueryEscape(token)
+		body := "Someone asked to reset the password of your Snippetbox account.\n\n" +
+			"To choose a new password, open this link within the next hour:\n\n" + link + "\n\n" +
+			"If it wasn't you, you can ignore this email."
+
+		err = app.mailer.Send(form.Email, "Reset your Snippetbox password", body)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.flash(r, "If an account uses that email, we have sent it a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) passwordReset(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	err := app.passwordResets.Check(token)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidResetToken) {
+			app.flash(r, "This password reset link is invalid or has expired.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = passwordResetForm{Token: token}
+	app.render(w, http.StatusOK, "reset.tmpl.html", data)
+}
+
+func (app *application) passwordResetPost(w http.ResponseWriter, r *http.Request) {
+	var form passwordResetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.Equals(form.NewPassword, form.NewPasswordConfirmation), "newPasswordConfirmation", "Doesn't match new password")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reset.tmpl.html", data)
+		return
+	}
+
+	userID, err := app.passwordResets.Reset(form.Token, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidResetToken) {
+			app.flash(r, "This password reset link is invalid or has expired.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, userID, models.AuditPasswordReset, "")
+
+	app.flash(r, "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	app.flash(r, "You've been logged out successfully!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// accountLoginsLimit is the number of recent logins and failed logins shown on
+// the account page.
+const accountLoginsLimit = 10
+
+func (app *application) userAccount(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	logins, err := app.auditLog.Logins(id, accountLoginsLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = usr
+	data.AuditEntries = logins
+
+	app.render(w, http.StatusOK, "account.tmpl.html", data)
+}
+
+// accountTrash lists the snippets and comments the user deleted recently
+// enough to restore.
+func (app *application) accountTrash(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippets, err := app.snippets.Trash(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	comments, err := app.comments.TrashContext(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.TrashedComments = comments
+	data.TrashDays = int(models.TrashRetention / (24 * time.Hour))
+
+	app.render(w, http.StatusOK, "trash.tmpl.html", data)
+}
+
+// accountTrashSnippetRestorePost restores a snippet from the user's trash.
+func (app *application) accountTrashSnippetRestorePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Get does not find deleted snippets, so ownership is checked against the
+	// user's trash
+	snippets, err := app.snippets.Trash(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var snippet *models.Snippet
+	for _, s := range snippets {
+		if s.ID == id {
+			snippet = s
+		}
+	}
+	if snippet == nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.snippets.Restore(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Snippet restored.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+// accountTrashCommentRestorePost restores a comment from the user's trash.
+func (app *application) accountTrashCommentRestorePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	comments, err := app.comments.TrashContext(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var comment *models.CommentWithContext
+	for _, c := range comments {
+		if c.ID == id {
+			comment = c
+		}
+	}
+	if comment == nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.comments.RestoreContext(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "Comment restored.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", comment.SnippetID, id), http.StatusSeeOther)
+}
+
+// notificationsPageSize is the number of notifications per page.
+const notificationsPageSize = 20
+
+// accountNotifications lists the user's notifications, newest first.
+func (app *application) accountNotifications(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	page := app.pageParam(r)
+
+	notifications, total, err := app.notifications.List(id, page, notificationsPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Notifications = notifications
+	data.Pagination = &pagination{Path: "/account/notifications", Page: page, PageSize: notificationsPageSize, Total: total}
+
+	app.render(w, http.StatusOK, "notifications.tmpl.html", data)
+}
+
+// accountNotificationReadPost marks one of the user's notifications as read.
+func (app *application) accountNotificationReadPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.notifications.MarkRead(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/account/notifications", http.StatusSeeOther)
+}
+
+// accountNotificationsReadPost marks all of the user's notifications as read.
+func (app *application) accountNotificationsReadPost(w http.ResponseWriter, r *http.Request) {
+	err := app.notifications.MarkAllRead(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(r, "All notifications marked as read.")
+	http.Redirect(w, r, "/account/notifications", http.StatusSeeOther)
+}
+
+// profilePageSize is the number of snippets or comments per page on a user's
+// profile.
+const profilePageSize = 10
+
+// userProfile shows a user's public snippets or, with ?tab=comments, their
+// comments, along with the votes they have received.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	tab := r.URL.Query().Get("tab")
+	if tab == "" {
+		tab = "snippets"
+	}
+	if tab != "snippets" && tab != "comments" {
+		app.notFound(w)
+		return
+	}
 
-	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		fmt.Println(form)
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	totals, err := app.users.VotesReceived(id)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
-
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
-}
-
-func (app *application) commentCreatePost(w http.ResponseWriter, r *http.Request) {
-	var form commentCreateForm
+	data := app.newTemplateData(r)
+	data.Profile = usr
+	data.ProfileTab = tab
+	data.VoteTotals = totals
+	data.FeedURL = fmt.Sprintf("/user/profile/%d/feed.xml", id)
 
-	err := app.decodePostForm(r, &form)
+	data.Followers, data.Following, err = app.follows.Counts(id)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.serverError(w, err)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Content, 200), "content", "This field cannot be more than 200 characters long")
-
-	if !form.Valid() {
-		snippet, err := app.snippets.Get(form.Snippet_ID)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-
-		comments, err := app.comments.GetBySnippetID(form.Snippet_ID)
+	if data.IsAuthenticated {
+		viewerID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		data.IsOwner = viewerID == id
+		data.IsFollowing, err = app.follows.Following(viewerID, id)
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
+	}
 
-		usr, err := app.users.Get(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+	page := app.pageParam(r)
+	var total int
 
-		data := app.newTemplateData(r)
-		data.Form = form
-		data.Snippet = snippet
-		data.Comments = comments
-		data.User = usr
-		app.render(w, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+	if tab == "comments" {
+		data.ProfileComments, total, err = app.comments.ByAuthorContext(r.Context(), id, app.viewerID(r), page, profilePageSize)
+	} else {
+		data.Snippets, total, err = app.snippets.ByAuthor(id, page, profilePageSize)
+	}
+	if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
-	_, err = app.comments.Insert(form.Snippet_ID, form.Author, form.Content)
+	contents := make([]string, len(data.ProfileComments))
+	for i, c := range data.ProfileComments {
+		contents[i] = c.Content
+	}
+
+	data.Mentions, err = app.mentionedUsers(contents...)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Comment successfully created!")
+	data.Pagination = &pagination{Path: fmt.Sprintf("/user/profile/%d", id), Page: page, PageSize: profilePageSize, Total: total}
+	if tab == "comments" {
+		data.Pagination.Params = url.Values{"tab": {tab}}
+	}
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", form.Snippet_ID), http.StatusSeeOther)
+	app.render(w, http.StatusOK, "profile.tmpl.html", data)
 }
 
-func (app *application) voteComment(w http.ResponseWriter, r *http.Request) {
+// userFollowPost follows a user, or unfollows them if the user already does.
+func (app *application) userFollowPost(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 	id, err := strconv.Atoi(params.ByName("id"))
 	if err != nil || id < 1 {
@@ -235,95 +3111,230 @@ func (app *application) voteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	value, err := strconv.Atoi(params.ByName("value"))
-	if err != nil || (value != 1 && value != -1) {
-		app.notFound(w)
+	following, err := app.follows.Toggle(app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrSelfFollow):
+			app.clientError(w, http.StatusBadRequest)
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
 
-	user_id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	message := "You no longer follow this user"
+	if following {
+		message = "You now follow this user"
+	}
+	app.flash(r, message)
 
-	var message string
+	http.Redirect(w, r, fmt.Sprintf("/user/profile/%d", id), http.StatusSeeOther)
+}
 
-	if value == 1 {
-		message, err = app.comments.Upvote(id, user_id)
-	} else {
-		message, err = app.comments.Downvote(id, user_id)
-	}
+// feedPageSize is the number of snippets and comments per page of the feed.
+const feedPageSize = 20
+
+// feedView shows the latest snippets and comments of the users the user
+// follows.
+func (app *application) feedView(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	page := app.pageParam(r)
 
+	items, total, err := app.follows.Feed(id, page, feedPageSize)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	comment, err := app.comments.Get(id)
+	contents := make([]string, len(items))
+	for i, item := range items {
+		contents[i] = item.Content
+	}
 
+	data := app.newTemplateData(r)
+	data.FeedItems = items
+	data.Mentions, err = app.mentionedUsers(contents...)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	data.Pagination = &pagination{Path: "/feed", Page: page, PageSize: feedPageSize, Total: total}
 
-	app.sessionManager.Put(r.Context(), "flash", message)
-
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", comment.SnippetID), http.StatusSeeOther)
+	app.render(w, http.StatusOK, "feed.tmpl.html", data)
 }
 
-func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+func (app *application) accountSettings(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	identities, err := app.identities.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+	data.Form = accountSettingsForm{
+		Name:   usr.Name,
+		Email:  usr.Email,
+		Digest: usr.Digest,
+	}
+	data.OAuthProviders = app.oauthProviderLinks(identities)
+
+	app.render(w, http.StatusOK, "settings.tmpl.html", data)
 }
 
-func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
-	var form userSignupForm
+// accountSettingsPost saves a new display name, email address and digest
+// preference. Changing the email address requires the current password.
+func (app *application) accountSettingsPost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	err := app.decodePostForm(r, &form)
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	var form accountSettingsForm
+
+	err = app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
+	emailChanged := form.Email != usr.Email
+
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be an email")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	if emailChanged {
+		form.CheckField(validator.NotBlank(form.Password), "password", "Enter your current password to change your email address")
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "settings.tmpl.html", data)
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Password, form.Email)
-	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			form.AddFieldError("email", "Email address is already in use")
+	// The email address goes first, since it is the change that can fail
+	if emailChanged {
+		err = app.users.UpdateEmail(id, form.Email, form.Password)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrInvalidCredentials):
+				form.AddFieldError("password", "Invalid password")
+			case errors.Is(err, models.ErrDuplicateEmail):
+				form.AddFieldError("email", "Email address is already in use")
+			default:
+				app.serverError(w, err)
+				return
+			}
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
-		} else {
+			app.render(w, http.StatusUnprocessableEntity, "settings.tmpl.html", data)
+			return
+		}
+
+		err = app.sendVerification(form.Email)
+		if err != nil {
 			app.serverError(w, err)
 			return
 		}
+	}
 
-		return
+	if form.Name != usr.Name {
+		err = app.users.UpdateName(id, form.Name)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	if form.Digest != usr.Digest {
+		err = app.users.SetDigest(id, form.Digest)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.flash(r, "Your settings have been updated.")
+	http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
 }
 
-func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+// totpIssuer names the site in users' authenticator apps.
+const totpIssuer = "Snippetbox"
+
+func (app *application) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	app.renderTwoFactor(w, r, http.StatusOK, twoFactorForm{})
+}
+
+// accountTwoFactorEnablePost turns two-factor authentication on once the user
+// enters a code from their app, and shows them their backup codes. This is
+// the only time they are shown.
+func (app *application) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var form twoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(id, form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidTOTP):
+			form.AddFieldError("code", "Invalid code")
+			app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		case errors.Is(err, models.ErrTwoFactorEnabled), errors.Is(err, models.ErrNoRecord):
+			http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl.html", data)
+	data.BackupCodes = codes
+	app.render(w, http.StatusOK, "backupcodes.tmpl.html", data)
 }
 
-func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
-	var form userLoginForm
+// accountTwoFactorDisablePost turns two-factor authentication off. It asks for
+// a code, so a session left logged in is not enough to do it.
+func (app *application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var form twoFactorForm
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
@@ -331,63 +3342,123 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
 	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form)
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	err = app.twoFactor.Disable(id, form.Code)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Email or password is incorrect")
+		if errors.Is(err, models.ErrInvalidTOTP) {
+			form.AddFieldError("code", "Invalid code")
+			app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
 
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+	app.flash(r, "Two-factor authentication has been turned off.")
+	http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+}
+
+// renderTwoFactor shows the user's two-factor authentication settings: how
+// many backup codes they have left if it is on, or the secret to add to their
+// app if it is not.
+func (app *application) renderTwoFactor(w http.ResponseWriter, r *http.Request, status int, form twoFactorForm) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
-	err = app.sessionManager.RenewToken(r.Context())
+	enabled, err := app.twoFactor.Enabled(id)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
-	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
-	if path != "" {
-		http.Redirect(w, r, path, http.StatusSeeOther)
-		return
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.TwoFactorEnabled = enabled
+
+	if enabled {
+		data.BackupCodesLeft, err = app.twoFactor.BackupCodesLeft(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	} else {
+		data.TOTPSecret, err = app.twoFactor.Setup(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.TOTPURI = totp.URI(totpIssuer, usr.Email, data.TOTPSecret)
 	}
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+
+	app.render(w, status, "twofactor.tmpl.html", data)
 }
 
-func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	err := app.sessionManager.RenewToken(r.Context())
+func (app *application) accountAPITokens(w http.ResponseWriter, r *http.Request) {
+	app.renderAPITokens(w, r, http.StatusOK, apiTokenForm{Scopes: []string{models.ScopeRead}}, "")
+}
+
+// accountAPITokensPost makes a personal access token for the JSON API and
+// shows it to the user. This is the only time it is shown.
+func (app *application) accountAPITokensPost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var form apiTokenForm
+
+	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Choose at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, models.Scopes...), "scopes", "Unknown scope")
+	}
 
-	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+	if !form.Valid() {
+		app.renderAPITokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	token, err := app.apiTokens.Insert(id, strings.TrimSpace(form.Name), form.Scopes)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderAPITokens(w, r, http.StatusOK, apiTokenForm{Scopes: []string{models.ScopeRead}}, token)
 }
 
-func (app *application) userAccount(w http.ResponseWriter, r *http.Request) {
+// accountAPITokenRevokePost deletes one of the user's API tokens, after which
+// requests made with it are refused.
+func (app *application) accountAPITokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	tokenID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || tokenID < 1 {
+		app.notFound(w)
+		return
+	}
+
 	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	usr, err := app.users.Get(id)
+	err = app.apiTokens.Delete(id, tokenID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -397,10 +3468,27 @@ func (app *application) userAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.User = usr
+	app.flash(r, "The API token has been revoked.")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
 
-	app.render(w, http.StatusOK, "account.tmpl.html", data)
+// renderAPITokens shows the user's API tokens and the form to make one, along
+// with newToken if one was just made.
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, form apiTokenForm, newToken string) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	tokens, err := app.apiTokens.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.APITokens = tokens
+	data.NewAPIToken = newToken
+	data.Scopes = models.Scopes
+	app.render(w, status, "apitokens.tmpl.html", data)
 }
 
 func (app *application) passwordUpdate(w http.ResponseWriter, r *http.Request) {
@@ -444,6 +3532,8 @@ func (app *application) passwordUpdatePost(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Your password have been updated.")
+	app.audit(r, id, models.AuditPasswordChange, "")
+
+	app.flash(r, "Your password have been updated.")
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
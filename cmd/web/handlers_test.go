@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"snippetbox.jmorelli.dev/internal/assert"
 	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/models/mocks"
+	"snippetbox.jmorelli.dev/internal/spam"
+	"snippetbox.jmorelli.dev/internal/validator"
 )
 
 func TestSnippetView(t *testing.T) {
@@ -29,6 +38,40 @@ func TestSnippetView(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Related snippets",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/7'>Over the wintry forest</a>",
+		},
+		{
+			name:     "Tags",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a class='tag' href='/tag/haiku'>haiku</a>",
+		},
+		{
+			name:     "Forks",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<h3>1 Fork</h3>",
+		},
+		{
+			name:     "Language",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<code class='language-go'>",
+		},
+		{
+			name:     "Private snippet of another user",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "History of a private snippet",
+			urlPath:  "/snippet/history/4",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -64,182 +107,638 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
-func TestUserSignup(t *testing.T) {
+func TestSnippetViewConditional(t *testing.T) {
 	app := newTestApplication(t)
 
 	srv := newTestServer(t, app.routes())
 	defer srv.Close()
 
-	_, _, body := srv.get(t, "/user/signup")
-	validCSRFToken := extractCSRFToken(t, body)
+	code, headers, _ := srv.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "private, no-cache")
 
-	const (
-		validName     = "John"
-		validPassword = "12345678"
-		validEmail    = "johndoe@email.com"
-		formTag       = "<form action='/user/signup' method='POST' novalidate>"
-	)
+	etag := headers.Get("ETag")
+	lastModified := headers.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("got ETag %q and Last-Modified %q", etag, lastModified)
+	}
+
+	getWith := func(header, value string) int {
+		req, err := http.NewRequest(http.MethodGet, "/snippet/view/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(header, value)
+
+		code, _, _ := srv.do(t, req)
+		return code
+	}
+
+	// The CSRF token changes on every request but the ETag does not
+	assert.Equal(t, getWith("If-None-Match", etag), http.StatusNotModified)
+	assert.Equal(t, getWith("If-None-Match", `"stale"`), http.StatusOK)
+	assert.Equal(t, getWith("If-Modified-Since", lastModified), http.StatusNotModified)
+	assert.Equal(t, getWith("If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)), http.StatusOK)
+
+	// A page with a flash message is a new page
+	csrfToken := loginAs(t, srv, "jay@email.com")
+	_, headers, _ = srv.get(t, "/snippet/view/1")
+	etag = headers.Get("ETag")
+
+	srv.post(t, "/snippet/star/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, getWith("If-None-Match", etag), http.StatusOK)
+}
+
+func TestSnippetViewCommentSort(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
 
 	tests := []struct {
-		name         string
-		userName     string
-		userEmail    string
-		userPassword string
-		csrfToken    string
-		wantCode     int
-		wantFormTag  string
+		name     string
+		urlPath  string
+		wantLive string
+	}{
+		{name: "Default", urlPath: "/snippet/view/1", wantLive: "oldest"},
+		{name: "Query string", urlPath: "/snippet/view/1?sort=top", wantLive: "top"},
+		{name: "Remembered", urlPath: "/snippet/view/1", wantLive: "top"},
+		{name: "Invalid value", urlPath: "/snippet/view/1?sort=bogus", wantLive: "top"},
+		{name: "Changed", urlPath: "/snippet/view/1?sort=newest", wantLive: "newest"},
+	}
+
+	// The subtests share the cookie jar, so they must run in order.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, "?sort="+tt.wantLive+"' class='live'>")
+		})
+	}
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
 	}{
 		{
-			name:         "Valid submission",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusSeeOther,
+			name:     "Tag with snippets",
+			urlPath:  "/tag/haiku",
+			wantBody: "<a href='/snippet/view/1'>An old silent pond</a>",
 		},
 		{
-			name:         "Invalid CSRF Token",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    "wrongToken",
-			wantCode:     http.StatusBadRequest,
+			name:     "Unknown tag",
+			urlPath:  "/tag/unknown",
+			wantBody: "There are no snippets with this tag.",
 		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetCommentsFeed(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
 		{
-			name:         "Empty name",
-			userName:     "",
-			userEmail:    validEmail,
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Valid ID",
+			urlPath:  "/snippet/view/1/comments.atom",
+			wantCode: http.StatusOK,
+			wantBody: "<content type=\"text\">What a lovely haiku</content>",
 		},
 		{
-			name:         "Empty email",
-			userName:     validName,
-			userEmail:    "",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/2/comments.atom",
+			wantCode: http.StatusNotFound,
 		},
 		{
-			name:         "Empty password",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "String ID",
+			urlPath:  "/snippet/view/test/comments.atom",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "application/atom+xml; charset=utf-8")
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewCommentsFeedSkipsHidden(t *testing.T) {
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	snippet := &models.Snippet{ID: 1, Title: "An old silent pond", Visibility: models.VisibilityPublic, Created: created}
+
+	comments := []*models.Comment{
+		{ID: 1, Author: "John", Content: "What a lovely haiku", Status: models.StatusApproved, Created: created, Updated: created},
+		{ID: 2, Author: "Una", Content: "Spam", Status: models.StatusRejected, Created: created, Updated: created.Add(time.Hour)},
+	}
+
+	feed := newCommentsFeed("https://snippetbox.test", snippet, comments)
+
+	assert.Equal(t, len(feed.Entries), 1)
+	assert.Equal(t, feed.Entries[0].Content.Body, "What a lovely haiku")
+	assert.Equal(t, feed.Updated, atomTime(created))
+}
+
+func TestSnippetFeeds(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantTitle string
+	}{
+		{
+			name:      "Latest",
+			urlPath:   "/feed.xml",
+			wantCode:  http.StatusOK,
+			wantTitle: "<title>Latest snippets</title>",
 		},
 		{
-			name:         "Invalid email",
-			userName:     validName,
-			userEmail:    "bob@example.",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:      "Tag",
+			urlPath:   "/tag/haiku/feed.xml",
+			wantCode:  http.StatusOK,
+			wantTitle: "<title>Snippets tagged haiku</title>",
 		},
 		{
-			name:         "Short password",
-			userName:     validName,
-			userEmail:    validEmail,
-			userPassword: "pa$$",
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:      "User",
+			urlPath:   "/user/profile/1/feed.xml",
+			wantCode:  http.StatusOK,
+			wantTitle: "<title>Snippets by John</title>",
 		},
 		{
-			name:         "Duplicate email",
-			userName:     validName,
-			userEmail:    "dupe@example.com",
-			userPassword: validPassword,
-			csrfToken:    validCSRFToken,
-			wantCode:     http.StatusUnprocessableEntity,
-			wantFormTag:  formTag,
+			name:     "Non-existent user",
+			urlPath:  "/user/profile/99/feed.xml",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String user ID",
+			urlPath:  "/user/profile/test/feed.xml",
+			wantCode: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			form := url.Values{}
-			form.Add("name", tt.userName)
-			form.Add("email", tt.userEmail)
-			form.Add("password", tt.userPassword)
-			form.Add("csrf_token", tt.csrfToken)
-
-			code, _, body := srv.post(t, "/user/signup", form)
+			code, headers, body := srv.get(t, tt.urlPath)
 
 			assert.Equal(t, code, tt.wantCode)
 
-			if tt.wantFormTag != "" {
-				assert.StringContains(t, body, tt.wantFormTag)
+			if tt.wantTitle != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "application/atom+xml; charset=utf-8")
+				assert.Equal(t, headers.Get("Cache-Control"), "public, max-age=300")
+				assert.StringContains(t, body, tt.wantTitle)
+				assert.StringContains(t, body, "<title>An old silent pond</title>")
+				assert.StringContains(t, body, "<name>John</name>")
+
+				// Feed readers that have the feed already get a 304
+				req, _ := http.NewRequest(http.MethodGet, tt.urlPath, nil)
+				req.Header.Set("If-None-Match", headers.Get("ETag"))
+				code, _, _ = srv.do(t, req)
+				assert.Equal(t, code, http.StatusNotModified)
 			}
 		})
 	}
+
+	// Pages link to their feed
+	_, _, body := srv.get(t, "/")
+	assert.StringContains(t, body, "<link rel='alternate' type='application/atom+xml' href='/feed.xml'>")
 }
 
-func TestHome(t *testing.T) {
+func TestSnippetRaw(t *testing.T) {
 	app := newTestApplication(t)
-	appError := &application{
-		infoLog:  log.New(io.Discard, "", 0),
-		errorLog: log.New(io.Discard, "", 0),
-		snippets: &models.SnippetModel{},
-	}
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
 
 	tests := []struct {
-		name     string
-		urlPath  string
-		app      *application
-		wantCode int
-		wantBody string
+		name            string
+		urlPath         string
+		wantCode        int
+		wantDisposition string
 	}{
 		{
-			name:     "Home page",
-			urlPath:  "/",
-			app:      app,
+			name:     "Raw",
+			urlPath:  "/snippet/raw/1",
 			wantCode: http.StatusOK,
-			wantBody: "Latest Snippets",
 		},
 		{
-			name:     "Show latest snippets",
-			urlPath:  "/",
-			app:      app,
-			wantCode: http.StatusOK,
-			wantBody: "An old silent pond",
+			name:            "Download",
+			urlPath:         "/snippet/download/1",
+			wantCode:        http.StatusOK,
+			wantDisposition: "attachment; filename=an-old-silent-pond.go",
 		},
 		{
-			name:     "Internal Error",
-			urlPath:  "/",
-			app:      appError,
-			wantCode: http.StatusInternalServerError,
+			name:     "Private",
+			urlPath:  "/snippet/raw/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/download/2",
+			wantCode: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			srv := newTestServer(t, tt.app.routes())
-			defer srv.Close()
-
-			code, _, body := srv.get(t, tt.urlPath)
+			code, headers, body := srv.get(t, tt.urlPath)
 
 			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Content-Disposition"), tt.wantDisposition)
 
-			if tt.wantBody != "" {
-				assert.StringContains(t, body, tt.wantBody)
+			if code == http.StatusOK {
+				assert.Equal(t, body, "An old silent pond...")
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.Equal(t, headers.Get("Cache-Control"), "public, max-age=300")
 			}
 		})
 	}
 }
 
-func TestSnippetCreate(t *testing.T) {
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Language",
+			snippet: &models.Snippet{ID: 1, Title: "Hello, World!", Language: "python"},
+			want:    "hello-world.py",
+		},
+		{
+			name:    "Plain text",
+			snippet: &models.Snippet{ID: 1, Title: "  Notes  "},
+			want:    "notes.txt",
+		},
+		{
+			name:    "Markdown",
+			snippet: &models.Snippet{ID: 1, Title: "README", Format: models.FormatMarkdown},
+			want:    "readme.md",
+		},
+		{
+			name:    "No ASCII",
+			snippet: &models.Snippet{ID: 7, Title: "古池や", Language: "go"},
+			want:    "snippet-7.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.want)
+		})
+	}
+}
+
+func TestSnippetEmbed(t *testing.T) {
 	app := newTestApplication(t)
 
 	srv := newTestServer(t, app.routes())
 	defer srv.Close()
 
-	t.Run("Unauthenticated", func(t *testing.T) {
+	code, headers, body := srv.get(t, "/snippet/embed/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-Frame-Options"), "")
+	assert.Equal(t, headers.Get("Cache-Control"), "public, max-age=300")
+	assert.StringContains(t, body, "<link rel='stylesheet' href='"+staticPath("/static/css/embed.css")+"'>")
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.Equal(t, strings.Contains(body, "<nav>"), false)
+
+	code, headers, body = srv.get(t, "/snippet/embed/1/embed.js")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/javascript; charset=utf-8")
+	assert.StringContains(t, body, fmt.Sprintf("frame.src = \"%s/snippet/embed/1\";", srv.URL))
+	assert.StringContains(t, body, "frame.title = \"An old silent pond\";")
+
+	// Private snippets cannot be embedded, even by their owner
+	code, _, _ = srv.get(t, "/snippet/embed/4")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = srv.get(t, "/snippet/embed/4/embed.js")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// The snippet page offers the script and links to the oEmbed endpoint
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, fmt.Sprintf("&lt;script src=\"%s/snippet/embed/1/embed.js\"&gt;&lt;/script&gt;", srv.URL))
+	assert.StringContains(t, body, "type='application/json+oembed'")
+}
+
+func TestOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	snippetURL := srv.URL + "/snippet/view/1"
+
+	tests := []struct {
+		name     string
+		query    url.Values
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			query:    url.Values{"url": {snippetURL}},
+			wantCode: http.StatusOK,
+			wantBody: `"author_name":"John"`,
+		},
+		{
+			name:     "Max width",
+			query:    url.Values{"url": {snippetURL}, "format": {"json"}, "maxwidth": {"300"}},
+			wantCode: http.StatusOK,
+			wantBody: `"width":300`,
+		},
+		{
+			name:     "XML",
+			query:    url.Values{"url": {snippetURL}, "format": {"xml"}},
+			wantCode: http.StatusNotImplemented,
+		},
+		{
+			name:     "Private",
+			query:    url.Values{"url": {srv.URL + "/snippet/view/4"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Other site",
+			query:    url.Values{"url": {"https://example.com/snippet/view/1"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Not a snippet",
+			query:    url.Values{"url": {srv.URL + "/about"}},
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := srv.get(t, "/oembed?"+tt.query.Encode())
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "application/json; charset=utf-8")
+				assert.StringContains(t, body, `"type":"rich"`)
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "John"
+		validPassword = "12345678"
+		validEmail    = "johndoe@email.com"
+		formTag       = "<form action='/user/signup' method='POST' novalidate>"
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+	}{
+		{
+			name:         "Valid submission",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusSeeOther,
+		},
+		{
+			name:         "Invalid CSRF Token",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    "wrongToken",
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:         "Empty name",
+			userName:     "",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty email",
+			userName:     validName,
+			userEmail:    "",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Empty password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid email",
+			userName:     validName,
+			userEmail:    "bob@example.",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Short password",
+			userName:     validName,
+			userEmail:    validEmail,
+			userPassword: "pa$$",
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate email",
+			userName:     validName,
+			userEmail:    "dupe@example.com",
+			userPassword: validPassword,
+			csrfToken:    validCSRFToken,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := srv.post(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	appError := &application{
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+		snippets: &models.SnippetModel{},
+		metrics:  newServerMetrics(),
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		app      *application
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Home page",
+			urlPath:  "/",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "Latest Snippets",
+		},
+		{
+			name:     "Show latest snippets",
+			urlPath:  "/",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Total in heading",
+			urlPath:  "/",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "Latest Snippets (1)",
+		},
+		{
+			name:     "Page past the end",
+			urlPath:  "/?page=2",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/?page=abc",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Language filter",
+			urlPath:  "/?language=go",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/?language=go'>Go</a>",
+		},
+		{
+			name:     "Language filter without matches",
+			urlPath:  "/?language=python",
+			app:      app,
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name:     "Unsupported language",
+			urlPath:  "/?language=cobol",
+			app:      app,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Internal Error",
+			urlPath:  "/",
+			app:      appError,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.app.routes())
+			defer srv.Close()
+
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
 		code, headers, _ := srv.get(t, "/snippet/create")
 
 		assert.Equal(t, code, http.StatusSeeOther)
@@ -266,5 +765,2504 @@ func TestSnippetCreate(t *testing.T) {
 
 		assert.Equal(t, code, http.StatusOK)
 		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
+		assert.StringContains(t, body, "value='plain' checked")
+
+		form = url.Values{}
+		form.Add("title", "Markdown")
+		form.Add("content", "# Hello")
+		form.Add("format", "html")
+		form.Add("visibility", "private")
+		form.Add("expires", "7")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, body = srv.post(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must be plain or markdown")
+
+		form.Set("format", "markdown")
+		code, headers, _ := srv.post(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/2")
 	})
 }
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, _, body = srv.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='title' value='An old silent pond'>")
+
+	code, _, _ = srv.get(t, "/snippet/edit/2")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			title:        "An old pond",
+			content:      "A frog jumps in",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:     "Empty title",
+			content:  "A frog jumps in",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := srv.post(t, "/snippet/edit/1", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := srv.post(t, "/snippet/fork/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, headers, _ = srv.post(t, "/snippet/fork/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/3")
+
+	code, _, _ = srv.post(t, "/snippet/fork/2", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetVote(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := srv.post(t, "/snippet/vote/1/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, headers, _ = srv.post(t, "/snippet/vote/1/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "You cannot vote on your own snippet")
+
+	code, _, _ = srv.post(t, "/snippet/vote/1/2", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.post(t, "/snippet/vote/4/1", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestTrending(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, _, body := srv.get(t, "/trending")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+
+	code, _, body = srv.get(t, "/trending?page=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Nothing is trending yet.")
+}
+
+func TestSharedSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Private snippet",
+			urlPath:  "/s/valid-token",
+			wantCode: http.StatusOK,
+			wantBody: "A private pond",
+		},
+		{
+			name:     "Unknown token",
+			urlPath:  "/s/forged-token",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				assert.Equal(t, headers.Get("Referrer-Policy"), "no-referrer")
+			}
+		})
+	}
+}
+
+func TestSnippetShare(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/snippet/share/1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, _, body = srv.get(t, "/snippet/share/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/s/own-token")
+
+	// Someone else's private snippet stays hidden
+	code, _, _ = srv.get(t, "/snippet/share/4")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		expires      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Create",
+			urlPath:      "/snippet/share/1",
+			expires:      "24",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/share/1",
+		},
+		{
+			name:     "Invalid expiry",
+			urlPath:  "/snippet/share/1",
+			expires:  "5",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Revoke",
+			urlPath:      "/snippet/share/1/revoke/2",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/share/1",
+		},
+		{
+			name:     "Revoke other snippet's link",
+			urlPath:  "/snippet/share/1/revoke/1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "History",
+			urlPath:  "/snippet/history/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/history/1/1'>An old pond</a>",
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/history/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Revision",
+			urlPath:  "/snippet/history/1/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old pond...",
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/snippet/history/1/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Restore", func(t *testing.T) {
+		_, _, body := srv.get(t, "/user/login")
+		csrfToken := extractCSRFToken(t, body)
+
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := srv.post(t, "/snippet/history/1/1/restore", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		login := url.Values{}
+		login.Add("email", "jay@email.com")
+		login.Add("password", "12345678")
+		login.Add("csrf_token", csrfToken)
+		srv.post(t, "/user/login", login)
+
+		_, _, body = srv.get(t, "/snippet/history/1")
+		assert.StringContains(t, body, "value='Restore'")
+
+		code, headers, _ = srv.post(t, "/snippet/history/1/1/restore", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+	})
+}
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Snippets tab",
+			urlPath:  "/user/profile/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Votes received",
+			urlPath:  "/user/profile/1",
+			wantCode: http.StatusOK,
+			wantBody: "Votes received: +3 / -1 (score 2)",
+		},
+		{
+			name:     "Comments tab",
+			urlPath:  "/user/profile/1?tab=comments",
+			wantCode: http.StatusOK,
+			wantBody: "What a lovely haiku",
+		},
+		{
+			name:     "Unknown tab",
+			urlPath:  "/user/profile/1?tab=votes",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/user/profile/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/user/profile/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountSettings(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/account/settings")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, _, body = srv.get(t, "/account/settings")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='name' value='John'>")
+	assert.StringContains(t, body, "<input type='checkbox' name='digest' value='true' checked>")
+
+	tests := []struct {
+		name         string
+		userName     string
+		email        string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			userName:     "Jay",
+			email:        "jay@example.com",
+			password:     "12345678",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/settings",
+		},
+		{
+			name:     "Empty name",
+			email:    "jay@example.com",
+			password: "12345678",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Invalid email",
+			userName: "Jay",
+			email:    "jay@",
+			password: "12345678",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a valid email address",
+		},
+		{
+			name:     "Email change without password",
+			userName: "Jay",
+			email:    "jay@example.com",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Enter your current password to change your email address",
+		},
+		{
+			name:     "Wrong password",
+			userName: "Jay",
+			email:    "jay@example.com",
+			password: "wrongpassword",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Invalid password",
+		},
+		{
+			name:     "Duplicate email",
+			userName: "Jay",
+			email:    "dupe@example.com",
+			password: "12345678",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Email address is already in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := srv.post(t, "/account/settings", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPasswordForgot(t *testing.T) {
+	app := newTestApplication(t)
+	mail := app.mailer.(*fakeMailer)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		email        string
+		wantCode     int
+		wantLocation string
+		wantSent     int
+	}{
+		{
+			name:         "Known email",
+			email:        "jay@email.com",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+			wantSent:     1,
+		},
+		{
+			name:         "Unknown email",
+			email:        "nobody@example.com",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+			wantSent:     1,
+		},
+		{
+			name:     "Invalid email",
+			email:    "jay@",
+			wantCode: http.StatusUnprocessableEntity,
+			wantSent: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, "/user/password/forgot", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			assert.Equal(t, len(mail.sent), tt.wantSent)
+		})
+	}
+
+	assert.Equal(t, mail.sent[0].To, "jay@email.com")
+	assert.StringContains(t, mail.sent[0].Body, "https://snippetbox.test/user/password/reset?token=valid-token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/user/password/reset?token=forged")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/password/forgot")
+
+	code, _, body := srv.get(t, "/user/password/reset?token=valid-token")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='hidden' name='token' value='valid-token'>")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		token        string
+		password     string
+		confirmation string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			token:        "valid-token",
+			password:     "newpassword",
+			confirmation: "newpassword",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:         "Mismatched confirmation",
+			token:        "valid-token",
+			password:     "newpassword",
+			confirmation: "otherpassword",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantBody:     "Doesn't match new password",
+		},
+		{
+			name:         "Short password",
+			token:        "valid-token",
+			password:     "short",
+			confirmation: "short",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantBody:     "This field must be at least 8 characters long",
+		},
+		{
+			name:         "Invalid token",
+			token:        "forged",
+			password:     "newpassword",
+			confirmation: "newpassword",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/password/forgot",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("newPassword", tt.password)
+			form.Add("newPasswordConfirmation", tt.confirmation)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := srv.post(t, "/user/password/reset", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/user/verify?token=forged")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
+
+	_, _, body := srv.get(t, "/")
+	assert.StringContains(t, body, "This verification link is invalid or has expired.")
+
+	code, headers, _ = srv.get(t, "/user/verify?token=verify-token")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
+
+	_, _, body = srv.get(t, "/")
+	assert.StringContains(t, body, "Your email address has been verified.")
+}
+
+func TestUnverifiedUser(t *testing.T) {
+	app := newTestApplication(t)
+	mail := app.mailer.(*fakeMailer)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	login := url.Values{}
+	login.Add("email", "unverified@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, headers, _ := srv.post(t, "/snippet/vote/1/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	comment := url.Values{}
+	comment.Add("content", "Nice snippet")
+	comment.Add("snippet_id", "1")
+	comment.Add("csrf_token", csrfToken)
+
+	code, headers, _ = srv.post(t, "/comment/create", comment)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	_, _, body = srv.get(t, "/account/view")
+	assert.StringContains(t, body, "Please verify your email address first.")
+	assert.StringContains(t, body, "(not verified)")
+
+	code, headers, _ = srv.post(t, "/user/verify/resend", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+	assert.Equal(t, len(mail.sent), 1)
+	assert.Equal(t, mail.sent[0].To, "unverified@email.com")
+	assert.StringContains(t, mail.sent[0].Body, "https://snippetbox.test/user/verify?token=verify-token")
+}
+
+// startOAuth begins logging in with the fake GitHub provider and returns the
+// state it was sent.
+func startOAuth(t *testing.T, srv *testServer) string {
+	code, headers, _ := srv.get(t, "/user/oauth/github")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	location, err := url.Parse(headers.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, location.Host, "provider.example")
+	assert.Equal(t, location.Query().Get("redirect_uri"), "https://snippetbox.test/user/oauth/github/callback")
+
+	return location.Query().Get("state")
+}
+
+func TestOAuthLogin(t *testing.T) {
+	app := newTestApplication(t)
+	mail := app.mailer.(*fakeMailer)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	assert.StringContains(t, body, "<a href='/user/oauth/github'>Log in with GitHub</a>")
+
+	code, _, _ := srv.get(t, "/user/oauth/myspace")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.get(t, "/user/oauth/github/callback?code=linked-code&state=forged")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	tests := []struct {
+		name         string
+		code         string
+		wantLocation string
+		wantFlash    string
+		wantSent     int
+	}{
+		{
+			name:         "Linked account",
+			code:         "linked-code",
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:         "New account",
+			code:         "new-code",
+			wantLocation: "/snippet/create",
+			wantSent:     1,
+		},
+		{
+			name:         "Email already in use",
+			code:         "taken-code",
+			wantLocation: "/user/login",
+			wantFlash:    "Log in and link GitHub from your account settings.",
+			wantSent:     1,
+		},
+		{
+			name:         "No email",
+			code:         "no-email-code",
+			wantLocation: "/user/signup",
+			wantFlash:    "Your GitHub account has no email address",
+			wantSent:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := startOAuth(t, srv)
+
+			code, headers, _ := srv.get(t, "/user/oauth/github/callback?code="+tt.code+"&state="+url.QueryEscape(state))
+
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			assert.Equal(t, len(mail.sent), tt.wantSent)
+
+			if tt.wantFlash != "" {
+				_, _, body := srv.get(t, tt.wantLocation)
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+
+			// The state is single use
+			code, _, _ = srv.get(t, "/user/oauth/github/callback?code="+tt.code+"&state="+url.QueryEscape(state))
+			assert.Equal(t, code, http.StatusBadRequest)
+		})
+	}
+
+	assert.Equal(t, mail.sent[0].To, "newcomer@example.com")
+}
+
+func TestOAuthLink(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := srv.post(t, "/account/oauth/unlink/github", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	_, _, body = srv.get(t, "/account/settings")
+	assert.StringContains(t, body, "<form action='/account/oauth/unlink/github' method='POST'>")
+
+	code, headers, _ = srv.post(t, "/account/oauth/unlink/github", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/settings")
+
+	code, _, _ = srv.post(t, "/account/oauth/unlink/google", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.post(t, "/account/oauth/link/myspace", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, headers, _ = srv.post(t, "/account/oauth/link/github", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	location, err := url.Parse(headers.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := location.Query().Get("state")
+
+	// The mock links GitHub account 1001 to user 1 already
+	code, headers, _ = srv.get(t, "/user/oauth/github/callback?code=new-code&state="+url.QueryEscape(state))
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/settings")
+
+	_, _, body = srv.get(t, "/account/settings")
+	assert.StringContains(t, body, "That GitHub account is already linked to a Snippetbox account.")
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/user/login/2fa")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "twofactor@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+
+	code, headers, _ = srv.post(t, "/user/login", login)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login/2fa")
+
+	// The password alone does not authenticate the session
+	code, headers, _ = srv.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	code, _, body = srv.get(t, "/user/login/2fa")
+	assert.Equal(t, code, http.StatusOK)
+	csrfToken = extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("code", "000000")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = srv.post(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Invalid code")
+
+	// Logging in goes on to the page that asked for it
+	form.Set("code", "aaaa-bbbb")
+
+	code, headers, _ = srv.post(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/view")
+
+	code, _, body = srv.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "twofactor@email.com")
+}
+
+func TestLoginTwoFactorAttempts(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "twofactor@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	_, _, body = srv.get(t, "/user/login/2fa")
+	csrfToken = extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("code", "000000")
+	form.Add("csrf_token", csrfToken)
+
+	for i := 1; i < twoFactorAttempts; i++ {
+		code, _, _ := srv.post(t, "/user/login/2fa", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	code, headers, _ := srv.post(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// The right code is no use once the attempts have run out
+	form.Set("code", "123456")
+
+	code, headers, _ = srv.post(t, "/user/login/2fa", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	code, _, _ = srv.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestAccountTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, _, body := srv.get(t, "/account/2fa")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "otpauth://totp/Snippetbox:jay@email.com?")
+	assert.StringContains(t, body, "<code>JBSWY3DPEHPK3PXP</code>")
+
+	form := url.Values{}
+	form.Add("code", "654321")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = srv.post(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Invalid code")
+
+	form.Set("code", "123456")
+
+	code, _, body = srv.post(t, "/account/2fa/enable", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<li><code>aaaa-bbbb</code></li>")
+}
+
+func TestAccountTwoFactorDisable(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", "twofactor@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	_, _, body = srv.get(t, "/user/login/2fa")
+	csrfToken = extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("code", "123456")
+	form.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login/2fa", form)
+
+	code, _, body := srv.get(t, "/account/2fa")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You have 9 unused backup codes left.")
+
+	form.Set("code", "")
+
+	code, _, body = srv.post(t, "/account/2fa/disable", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	form.Set("code", "123456")
+
+	code, headers, _ := srv.post(t, "/account/2fa/disable", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/2fa")
+}
+
+func TestAccountAPITokens(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, body := srv.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>Deploy script</td>")
+	assert.StringContains(t, body, "<td>read, write</td>")
+	assert.StringContains(t, body, "<td>Never</td>")
+
+	form := url.Values{}
+	form.Add("name", "")
+	form.Add("scopes", "admin")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = srv.post(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+	assert.StringContains(t, body, "Unknown scope")
+
+	form.Set("name", "Laptop")
+	form.Del("scopes")
+
+	code, _, body = srv.post(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Choose at least one scope")
+
+	form.Add("scopes", "read")
+	form.Add("scopes", "write")
+
+	code, _, body = srv.post(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Your new token is <code>sbx_new</code>")
+
+	code, headers, _ := srv.post(t, "/account/tokens/2/revoke", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/tokens")
+
+	code, _, _ = srv.post(t, "/account/tokens/3/revoke", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+// loginAs logs the test server's client in as the mock user with email and
+// returns a CSRF token for its session.
+func loginAs(t *testing.T, srv *testServer, email string) string {
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := url.Values{}
+	login.Add("email", email)
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+
+	code, _, _ := srv.post(t, "/user/login", login)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	return csrfToken
+}
+
+func TestSnippetDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Owner",
+			email:    "jay@email.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Other user",
+			email:    "unverified@email.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Moderator",
+			email:    "mod@email.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			email:    "admin@email.com",
+			urlPath:  "/snippet/delete/1",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Non-existent snippet",
+			email:    "admin@email.com",
+			urlPath:  "/snippet/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			srv := newTestServer(t, app.routes())
+			defer srv.Close()
+
+			form := url.Values{}
+			form.Add("csrf_token", loginAs(t, srv, tt.email))
+
+			code, _, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestCommentDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{
+			name:     "Author",
+			email:    "jay@email.com",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Other user",
+			email:    "unverified@email.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Moderator",
+			email:    "mod@email.com",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			srv := newTestServer(t, app.routes())
+			defer srv.Close()
+
+			form := url.Values{}
+			form.Add("csrf_token", loginAs(t, srv, tt.email))
+
+			code, headers, _ := srv.post(t, "/comment/delete/1", form)
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+			}
+		})
+	}
+}
+
+func TestAccountTrash(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, body := srv.get(t, "/account/trash")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "can be restored for 30 days")
+	assert.StringContains(t, body, "<form action='/account/trash/snippets/5/restore' method='POST'>")
+	assert.StringContains(t, body, "<form action='/account/trash/comments/1/restore' method='POST'>")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Restore snippet",
+			urlPath:      "/account/trash/snippets/5/restore",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/5",
+		},
+		{
+			name:     "Snippet not in trash",
+			urlPath:  "/account/trash/snippets/1/restore",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Restore comment",
+			urlPath:      "/account/trash/comments/1/restore",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1#comment-1",
+		},
+		{
+			name:     "Comment not in trash",
+			urlPath:  "/account/trash/comments/2/restore",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	// Someone else's trash is not theirs to restore
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "admin@email.com")
+
+	code, _, _ = srv.post(t, "/account/trash/snippets/5/restore", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCommentReport(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "admin@email.com")
+
+	_, _, body := srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/comment/report/1' method='POST'>")
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		reason    string
+		wantCode  int
+		wantFlash string
+	}{
+		{
+			name:      "Valid report",
+			urlPath:   "/comment/report/1",
+			reason:    "Spam",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Thanks, a moderator will look at the comment.",
+		},
+		{
+			name:      "Blank reason",
+			urlPath:   "/comment/report/1",
+			reason:    "  ",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Please say why you are reporting this comment",
+		},
+		{
+			name:     "Missing comment",
+			urlPath:  "/comment/report/2",
+			reason:   "Spam",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("reason", tt.reason)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFlash != "" {
+				assert.Equal(t, headers.Get("Location"), "/snippet/view/1#comment-1")
+
+				_, _, body := srv.get(t, "/snippet/view/1")
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "mod@email.com")
+
+	form := url.Values{}
+	form.Add("reason", "Spam")
+	form.Add("csrf_token", csrfToken)
+
+	srv.post(t, "/comment/report/1", form)
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "You have already reported this comment.")
+}
+
+func TestCommentReact(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	// Anyone can see the counts, but only users can react
+	_, _, body := srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<span>👍 2</span>")
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/comment/react/1' method='POST'>")
+	assert.StringContains(t, body, "<button type='submit' name='reaction' value='thumbsup'>👍 2</button>")
+	assert.StringContains(t, body, "<button type='submit' name='reaction' value='tada'>🎉</button>")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		reaction string
+		wantCode int
+	}{
+		{"Valid reaction", "/comment/react/1", "heart", http.StatusSeeOther},
+		{"Unknown reaction", "/comment/react/1", "rocket", http.StatusBadRequest},
+		{"Missing comment", "/comment/react/2", "heart", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("reaction", tt.reaction)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if code == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/snippet/view/1#comment-1")
+			}
+		})
+	}
+}
+
+func TestCommentPin(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	// The snippet's author is badged on their own comments
+	_, _, body := srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<span class='badge author'>Author</span>")
+
+	// Others cannot pin comments on the snippet
+	csrfToken := loginAs(t, srv, "unverified@email.com")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.Equal(t, strings.Contains(body, "/comment/pin/1"), false)
+
+	code, _, _ := srv.post(t, "/comment/pin/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "jay@email.com")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form class='comment-pin-form' action='/comment/pin/1' method='POST'>")
+
+	code, headers, _ := srv.post(t, "/comment/pin/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/1#comment-1")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Comment pinned.")
+
+	code, _, _ = srv.post(t, "/comment/pin/2", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCommentCreateHeld(t *testing.T) {
+	app := newTestApplication(t)
+	app.comments = models.NewFilteringCommentModel(app.comments, spam.ParseWords("casino"))
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	tests := []struct {
+		name         string
+		content      string
+		wantLocation string
+		wantFlash    string
+	}{
+		{"Clean", "Nice snippet", "/snippet/view/1", "Comment successfully created!"},
+		{"Banned word", "Visit my casino", "/snippet/view/1#comment-2", "Your comment is awaiting moderation."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("snippet_id", "1")
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, "/comment/create", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			_, _, body := srv.get(t, "/snippet/view/1")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestCommentCreateLimits(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	tests := []struct {
+		name      string
+		content   string
+		wantError string
+	}{
+		{"Too short", "a", "This field must be at least 2 characters long"},
+		{"Too long", strings.Repeat("é", 2001), "This field cannot be more than 2000 characters long"},
+		{"Invalid UTF-8", "Nice \xff one", "This field must be valid UTF-8 text"},
+		{"Control characters", "Nice\x00 one", "This field cannot contain control characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("content", tt.content)
+			form.Add("snippet_id", "1")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := srv.post(t, "/comment/create", form)
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, tt.wantError)
+		})
+	}
+
+	// Line breaks and tabs are fine, and the longest comment allowed too
+	for _, content := range []string{"Line one\r\n\tline two", strings.Repeat("é", 2000)} {
+		form := url.Values{"content": {content}, "snippet_id": {"1"}, "csrf_token": {csrfToken}}
+		code, _, _ := srv.post(t, "/comment/create", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+}
+
+func TestAdminReports(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, _ := srv.get(t, "/admin/reports")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "mod@email.com")
+
+	code, _, body := srv.get(t, "/admin/reports")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<li>Spam <time>")
+	assert.StringContains(t, body, "<form action='/admin/reports/1/hide' method='POST'>")
+	assert.StringContains(t, body, "<form action='/admin/reports/1/approve' method='POST'>")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Dismiss", "/admin/reports/1/dismiss", http.StatusSeeOther},
+		{"Approve", "/admin/reports/1/approve", http.StatusSeeOther},
+		{"Hide", "/admin/reports/1/hide", http.StatusSeeOther},
+		{"Delete", "/admin/reports/1/delete", http.StatusSeeOther},
+		{"Missing comment", "/admin/reports/2/hide", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/admin/reports")
+			}
+		})
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "mod@email.com")
+
+	code, _, _ := srv.get(t, "/admin/users")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "admin@email.com")
+
+	code, _, body := srv.get(t, "/admin/users")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/admin/users/6/role' method='POST'>")
+	assert.StringContains(t, body, "<option value='moderator' selected>moderator</option>")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		role         string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Promote",
+			urlPath:      "/admin/users/1/role",
+			role:         "moderator",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin/users",
+		},
+		{
+			name:     "Unknown role",
+			urlPath:  "/admin/users/1/role",
+			role:     "superuser",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown user",
+			urlPath:  "/admin/users/99/role",
+			role:     "user",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Own role",
+			urlPath:      "/admin/users/7/role",
+			role:         "user",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin/users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("role", tt.role)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	_, _, body = srv.get(t, "/admin/users")
+	assert.StringContains(t, body, "You cannot change your own role.")
+}
+
+func TestAdminDashboard(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, _ := srv.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "admin@email.com")
+
+	code, _, body := srv.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<span>Users: 6</span>")
+	assert.StringContains(t, body, "<span>Snippets: 1</span>")
+	assert.StringContains(t, body, "<span>Comments: 1</span>")
+	assert.StringContains(t, body, "<a href='/user/profile/8'>Bo</a> (banned)")
+	assert.StringContains(t, body, "<form action='/admin/snippets/1/delete' method='POST'>")
+	assert.StringContains(t, body, "<form action='/comment/delete/1' method='POST'>")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		form         url.Values
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Ban",
+			urlPath:      "/admin/users/1/ban",
+			form:         url.Values{"banned": {"true"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin",
+		},
+		{
+			name:         "Unban",
+			urlPath:      "/admin/users/8/ban",
+			form:         url.Values{"banned": {"false"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin",
+		},
+		{
+			name:     "Invalid ban value",
+			urlPath:  "/admin/users/1/ban",
+			form:     url.Values{"banned": {"maybe"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Ban unknown user",
+			urlPath:  "/admin/users/99/ban",
+			form:     url.Values{"banned": {"true"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Purge comments",
+			urlPath:      "/admin/users/1/purge",
+			form:         url.Values{},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin",
+		},
+		{
+			name:         "Delete snippet",
+			urlPath:      "/admin/snippets/1/delete",
+			form:         url.Values{},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin",
+		},
+		{
+			name:     "Delete missing snippet",
+			urlPath:  "/admin/snippets/2/delete",
+			form:     url.Values{},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Ban self",
+			urlPath:      "/admin/users/7/ban",
+			form:         url.Values{"banned": {"true"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, tt.form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	_, _, body = srv.get(t, "/admin")
+	assert.StringContains(t, body, "You cannot do that to your own account.")
+}
+
+func TestAuditLog(t *testing.T) {
+	app := newTestApplication(t)
+	auditLog := app.auditLog.(*mocks.AuditModel)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "jay@email.com")
+	form.Add("password", "wrongpassword")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := srv.post(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, _ = srv.post(t, "/snippet/delete/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	assert.Equal(t, len(auditLog.Entries), 3)
+	assert.Equal(t, auditLog.Entries[0].Action, models.AuditLoginFailed)
+	assert.Equal(t, auditLog.Entries[0].UserID, 1)
+	assert.Equal(t, auditLog.Entries[0].Detail, "jay@email.com")
+	assert.Equal(t, auditLog.Entries[0].IP, "127.0.0.1")
+	assert.Equal(t, auditLog.Entries[1].Action, models.AuditLogin)
+	assert.Equal(t, auditLog.Entries[2].Action, models.AuditSnippetDelete)
+	assert.Equal(t, auditLog.Entries[2].Detail, "snippet 1")
+
+	// Users see their own login history
+	code, _, body = srv.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>Logged in</td>")
+	assert.StringContains(t, body, "<td>Failed</td>")
+
+	code, _, _ = srv.get(t, "/admin/audit")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	loginAs(t, srv, "admin@email.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+		skipBody string
+	}{
+		{
+			name:     "Everything",
+			urlPath:  "/admin/audit",
+			wantCode: http.StatusOK,
+			wantBody: "<td>snippet 1</td>",
+		},
+		{
+			name:     "By action",
+			urlPath:  "/admin/audit?action=login_failed",
+			wantCode: http.StatusOK,
+			wantBody: "<td>jay@email.com</td>",
+			skipBody: "<td>snippet 1</td>",
+		},
+		{
+			name:     "By user",
+			urlPath:  "/admin/audit?user=7",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/admin/audit?user=7'>Ada</a>",
+			skipBody: "<td>snippet 1</td>",
+		},
+		{
+			name:     "Unknown action",
+			urlPath:  "/admin/audit?action=bogus",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be one of the listed actions",
+		},
+		{
+			name:     "Invalid user",
+			urlPath:  "/admin/audit?user=abc",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+			if tt.skipBody != "" {
+				assert.Equal(t, strings.Contains(body, tt.skipBody), false)
+			}
+		})
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	app := newTestApplication(t)
+	notifications := app.notifications.(*mocks.NotificationModel)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "mod@email.com")
+
+	comment := url.Values{}
+	comment.Add("content", "Nice snippet")
+	comment.Add("snippet_id", "1")
+	comment.Add("csrf_token", csrfToken)
+
+	code, _, _ := srv.post(t, "/comment/create", comment)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The snippet owner wrote the comment replied to, and is told only once
+	comment.Add("parent_id", "1")
+	code, _, _ = srv.post(t, "/comment/create", comment)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = srv.post(t, "/snippet/vote/1/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = srv.post(t, "/snippet/vote/1/-1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = srv.get(t, "/comment/vote/1/1")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	assert.Equal(t, len(notifications.Notifications), 4)
+	for i, kind := range []string{
+		models.NotifySnippetComment, models.NotifyCommentReply,
+		models.NotifySnippetUpvote, models.NotifyCommentUpvote,
+	} {
+		assert.Equal(t, notifications.Notifications[i].Kind, kind)
+		assert.Equal(t, notifications.Notifications[i].UserID, 1)
+		assert.Equal(t, notifications.Notifications[i].ActorID, 6)
+	}
+	assert.Equal(t, notifications.Notifications[1].CommentID, 2)
+
+	// Nobody is notified of what they do themselves
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "jay@email.com")
+
+	comment.Set("csrf_token", csrfToken)
+	code, _, _ = srv.post(t, "/comment/create", comment)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, len(notifications.Notifications), 4)
+
+	code, _, body := srv.get(t, "/account/notifications")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<span class='unread-count'>4</span>")
+	assert.StringContains(t, body, "<a href='/user/profile/6'>Mo</a>")
+	assert.StringContains(t, body, "replied to your comment on")
+	assert.StringContains(t, body, "<a href='/snippet/view/1#comment-2'>")
+
+	code, headers, _ := srv.post(t, "/account/notifications/read/2", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/notifications")
+	assert.Equal(t, notifications.Notifications[1].Read, true)
+
+	_, _, body = srv.get(t, "/account/view")
+	assert.StringContains(t, body, "<span class='unread-count'>3</span>")
+
+	code, _, _ = srv.post(t, "/account/notifications/read/99", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.post(t, "/account/notifications/read", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/account/notifications")
+	assert.StringContains(t, body, "All notifications marked as read.")
+	assert.Equal(t, strings.Contains(body, "unread-count"), false)
+
+	// Others cannot mark someone else's notifications
+	notifications.Notifications[0].Read = false
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = loginAs(t, srv, "mod@email.com")
+
+	code, _, _ = srv.post(t, "/account/notifications/read/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, notifications.Notifications[0].Read, false)
+}
+
+func TestMentions(t *testing.T) {
+	app := newTestApplication(t)
+	notifications := app.notifications.(*mocks.NotificationModel)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "mod@email.com")
+
+	// The snippet owner is mentioned too, and told once. Banned users and
+	// names nobody has are not mentions.
+	comment := url.Values{}
+	comment.Add("content", "Thanks @ada, @ADA and @John. cc @Bo @nobody @Mo")
+	comment.Add("snippet_id", "1")
+	comment.Add("csrf_token", csrfToken)
+
+	code, _, _ := srv.post(t, "/comment/create", comment)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	assert.Equal(t, len(notifications.Notifications), 2)
+	assert.Equal(t, notifications.Notifications[0].UserID, 7)
+	assert.Equal(t, notifications.Notifications[0].Kind, models.NotifyCommentMention)
+	assert.Equal(t, notifications.Notifications[1].UserID, 1)
+	assert.Equal(t, notifications.Notifications[1].Kind, models.NotifyCommentMention)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	loginAs(t, srv, "admin@email.com")
+
+	code, _, body := srv.get(t, "/account/notifications")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "mentioned you in a comment on")
+}
+
+func TestSendDigests(t *testing.T) {
+	app := newTestApplication(t)
+	mail := app.mailer.(*fakeMailer)
+
+	sent, err := app.sendDigests()
+	assert.NilError(t, err)
+
+	// John and Ada want the digest, and only John has comments on his snippets
+	assert.Equal(t, sent, 2)
+	assert.Equal(t, len(mail.sent), 2)
+
+	john := mail.sent[0]
+	assert.Equal(t, john.To, "jay@email.com")
+	assert.Equal(t, john.Subject, "Your weekly Snippetbox digest")
+	assert.StringContains(t, john.Body, "New comments on your snippets:")
+	assert.StringContains(t, john.Body, "https://snippetbox.test/snippet/view/1#comment-1")
+	assert.StringContains(t, john.Body, "Trending snippets:")
+	assert.StringContains(t, john.HTML, "<a href='https://snippetbox.test/snippet/view/1'>An old silent pond</a>")
+	assert.StringContains(t, john.HTML, "<a href='https://snippetbox.test/account/settings'>settings</a>")
+
+	ada := mail.sent[1]
+	assert.Equal(t, ada.To, "admin@email.com")
+	assert.Equal(t, strings.Contains(ada.Body, "New comments on your snippets"), false)
+	assert.StringContains(t, ada.Body, "Trending snippets:")
+}
+
+func TestBannedUserLogin(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "banned@email.com")
+	form.Add("password", "12345678")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body := srv.post(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This account has been banned")
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newTestApplication(t)
+	mailer := app.mailer.(*fakeMailer)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	login := func(email, password string) (int, string) {
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+
+		code, _, body := srv.post(t, "/user/login", form)
+		return code, body
+	}
+
+	// The test application locks accounts after three failures in a row
+	for i := 0; i < 2; i++ {
+		code, body := login("jay@email.com", "wrongpassword")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Email or password is incorrect")
+	}
+	assert.Equal(t, len(mailer.sent), 0)
+
+	code, body := login("jay@email.com", "wrongpassword")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This account has been locked after too many failed logins")
+
+	assert.Equal(t, len(mailer.sent), 1)
+	assert.Equal(t, mailer.sent[0].To, "jay@email.com")
+	assert.StringContains(t, mailer.sent[0].Body, "https://snippetbox.test/user/unlock?token=unlock-token")
+
+	// Unknown addresses look locked too, but nobody is emailed
+	for i := 0; i < 3; i++ {
+		code, body = login("nobody@example.com", "wrongpassword")
+	}
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This account has been locked after too many failed logins")
+	assert.Equal(t, len(mailer.sent), 1)
+
+	code, body = login("locked@email.com", "12345678")
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This account has been locked after too many failed logins")
+
+	code, body = login("throttled@email.com", "12345678")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many failed logins. Please wait 30 seconds before trying again.")
+
+	code, headers, _ := srv.get(t, "/user/unlock?token=bad-token")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body = srv.get(t, "/user/login")
+	assert.StringContains(t, body, "This unlock link is invalid or has expired.")
+
+	code, headers, _ = srv.get(t, "/user/unlock?token=unlock-token")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body = srv.get(t, "/user/login")
+	assert.StringContains(t, body, "Your account has been unlocked. Please log in.")
+}
+
+func TestMarkdownPreview(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("content", "**bold** <script>")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := srv.post(t, "/markdown/preview", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	login := url.Values{}
+	login.Add("email", "jay@email.com")
+	login.Add("password", "12345678")
+	login.Add("csrf_token", csrfToken)
+	srv.post(t, "/user/login", login)
+
+	code, _, body = srv.post(t, "/markdown/preview", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "<p><strong>bold</strong> &lt;script&gt;</p>")
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    string
+		wantMissing string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "<h2>Search</h2>",
+		},
+		{
+			name:     "Snippet and comment",
+			urlPath:  "/search?q=pond+haiku",
+			wantCode: http.StatusOK,
+			wantBody: "<h2>2 results</h2>",
+		},
+		{
+			name:        "Snippets only",
+			urlPath:     "/search?q=pond&scope=snippets",
+			wantCode:    http.StatusOK,
+			wantBody:    "An old silent <mark>pond</mark>...",
+			wantMissing: "Comment by",
+		},
+		{
+			name:     "Comments only",
+			urlPath:  "/search?q=haiku&scope=comments",
+			wantCode: http.StatusOK,
+			wantBody: "What a lovely <mark>haiku</mark>",
+		},
+		{
+			name:     "No results",
+			urlPath:  "/search?q=nothing",
+			wantCode: http.StatusOK,
+			wantBody: "Nothing matched your search.",
+		},
+		{
+			name:     "Invalid scope",
+			urlPath:  "/search?q=pond&scope=users",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must equal snippets or comments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := srv.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if tt.wantMissing != "" && strings.Contains(body, tt.wantMissing) {
+				t.Errorf("got body containing %q", tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestFavorites(t *testing.T) {
+	app := newTestApplication(t)
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/favorites")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	csrfToken := loginAs(t, srv, "mod@email.com")
+	form := url.Values{"csrf_token": {csrfToken}}
+
+	_, _, body := srv.get(t, "/favorites")
+	assert.StringContains(t, body, "You haven't starred any snippets yet.")
+
+	code, headers, _ = srv.post(t, "/snippet/star/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Snippet added to your favorites")
+	assert.StringContains(t, body, "value='Unstar'")
+
+	_, _, body = srv.get(t, "/favorites")
+	assert.StringContains(t, body, "<a href='/snippet/view/1'>An old silent pond</a>")
+
+	// Starring again takes the star back
+	code, _, _ = srv.post(t, "/snippet/star/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Snippet removed from your favorites")
+	assert.StringContains(t, body, "value='Star'")
+
+	// Private snippets of others cannot be starred
+	code, _, _ = srv.post(t, "/snippet/star/4", form)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.post(t, "/snippet/star/99", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestFollows(t *testing.T) {
+	app := newTestApplication(t)
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, _ := srv.get(t, "/feed")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// Anonymous visitors see the counts but no button
+	_, _, body := srv.get(t, "/user/profile/1")
+	assert.StringContains(t, body, "0 followers · 0 following")
+	assert.Equal(t, strings.Contains(body, "/user/follow/1"), false)
+
+	csrfToken := loginAs(t, srv, "mod@email.com")
+	form := url.Values{"csrf_token": {csrfToken}}
+
+	_, _, body = srv.get(t, "/feed")
+	assert.StringContains(t, body, "Nothing here yet.")
+
+	_, _, body = srv.get(t, "/user/profile/1")
+	assert.StringContains(t, body, "value='Follow'")
+
+	code, headers, _ = srv.post(t, "/user/follow/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/profile/1")
+
+	_, _, body = srv.get(t, "/user/profile/1")
+	assert.StringContains(t, body, "You now follow this user")
+	assert.StringContains(t, body, "1 follower · 0 following")
+	assert.StringContains(t, body, "value='Unfollow'")
+
+	_, _, body = srv.get(t, "/feed")
+	assert.StringContains(t, body, "<a href='/user/profile/1'>John</a>")
+	assert.StringContains(t, body, "<a href='/snippet/view/1'>An old silent pond</a>")
+
+	// Following again unfollows
+	code, _, _ = srv.post(t, "/user/follow/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/user/profile/1")
+	assert.StringContains(t, body, "You no longer follow this user")
+	assert.StringContains(t, body, "0 followers · 0 following")
+
+	// Users cannot follow themselves, nor anyone who does not exist
+	_, _, body = srv.get(t, "/user/profile/6")
+	assert.Equal(t, strings.Contains(body, "/user/follow/6"), false)
+
+	code, _, _ = srv.post(t, "/user/follow/6", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, _ = srv.post(t, "/user/follow/99", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	files := app.snippetFiles.(*mocks.SnippetFileModel)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<strong>frog.py</strong>")
+	assert.StringContains(t, body, "<a href='/snippet/raw/1/frog.py'>Raw</a>")
+
+	code, _, body := srv.get(t, "/snippet/raw/1/frog.py")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "print('plop')")
+
+	code, _, _ = srv.get(t, "/snippet/raw/1/missing.py")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = srv.get(t, "/snippet/raw/4/frog.py")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	_, _, body = srv.get(t, "/snippet/edit/1")
+	assert.StringContains(t, body, "<input type='text' name='files[0].name' value='frog.py' placeholder='File name'>")
+	assert.StringContains(t, body, "<button type='button' data-add-file='1'>Add file</button>")
+
+	snippet := url.Values{}
+	snippet.Add("title", "Two files")
+	snippet.Add("content", "package main")
+	snippet.Add("format", "plain")
+	snippet.Add("language", "go")
+	snippet.Add("visibility", "public")
+	snippet.Add("expires", "7")
+	snippet.Add("csrf_token", csrfToken)
+	snippet.Add("files[0].name", "query.sql")
+	snippet.Add("files[0].language", "sql")
+	snippet.Add("files[0].content", "SELECT 1")
+	// Blank files are dropped
+	snippet.Add("files[1].name", "")
+	snippet.Add("files[1].content", "")
+	snippet.Add("files[2].name", "QUERY.sql")
+	snippet.Add("files[2].content", "SELECT 2")
+
+	code, _, body = srv.post(t, "/snippet/create", snippet)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "File names must be unique")
+
+	snippet.Set("files[2].name", "../etc/passwd")
+	code, _, body = srv.post(t, "/snippet/create", snippet)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "File names can only contain letters, digits, ., _ and -")
+
+	snippet.Set("files[2].name", "README")
+	code, headers, _ := srv.post(t, "/snippet/create", snippet)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/2")
+
+	saved, _ := files.ForSnippet(2)
+	assert.Equal(t, len(saved), 2)
+	assert.Equal(t, saved[0].Name, "query.sql")
+	assert.Equal(t, saved[0].Language, "sql")
+	assert.Equal(t, saved[1].Name, "README")
+
+	// Editing replaces the files, so leaving them all out removes them
+	edit := url.Values{}
+	edit.Add("title", "An old silent pond")
+	edit.Add("content", "An old silent pond...")
+	edit.Add("csrf_token", csrfToken)
+
+	code, _, _ = srv.post(t, "/snippet/edit/1", edit)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	saved, _ = files.ForSnippet(1)
+	assert.Equal(t, len(saved), 0)
+}
+
+func TestCommentAttachments(t *testing.T) {
+	app := newTestApplication(t)
+	attachments := app.attachments.(*mocks.AttachmentModel)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	var upload bytes.Buffer
+	err := png.Encode(&upload, image.NewRGBA(image.Rect(0, 0, 800, 400)))
+	assert.NilError(t, err)
+
+	attach := func(csrfToken, commentID, filename string, content []byte) (int, http.Header) {
+		fields := url.Values{"csrf_token": {csrfToken}}
+		code, headers, _ := srv.do(t, uploadRequest(t, "/comment/attach/"+commentID, fields, "image", filename, content))
+		return code, headers
+	}
+
+	// Only the author of a comment can attach to it
+	csrfToken := loginAs(t, srv, "mod@email.com")
+	code, _ := attach(csrfToken, "1", "frog.png", upload.Bytes())
+	assert.Equal(t, code, http.StatusForbidden)
+
+	csrfToken = loginAs(t, srv, "jay@email.com")
+
+	_, _, body := srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/comment/attach/1' method='POST' enctype='multipart/form-data'>")
+
+	code, _ = attach(csrfToken, "99", "frog.png", upload.Bytes())
+	assert.Equal(t, code, http.StatusNotFound)
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		flash    string
+	}{
+		{"No file", "", nil, "Choose an image to attach."},
+		{"Not an image", "frog.svg", []byte("<svg onload='alert(1)'></svg>"), "Only PNG, JPEG and GIF images can be attached."},
+		{"Too big", "frog.png", append(upload.Bytes(), make([]byte, maxAttachmentBytes)...), "Images can be at most 5 MB."},
+		{"Image", `C:\Users\john\frog.png`, upload.Bytes(), "Image attached."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers := attach(csrfToken, "1", tt.filename, tt.content)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/snippet/view/1#comment-1")
+
+			_, _, body := srv.get(t, "/snippet/view/1")
+			assert.StringContains(t, body, tt.flash)
+		})
+	}
+
+	assert.Equal(t, len(attachments.Attachments), 1)
+	a := attachments.Attachments[0]
+	assert.Equal(t, a.Name, "frog.png")
+	assert.Equal(t, a.ContentType, "image/png")
+	assert.Equal(t, a.Width, 800)
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<a href='/attachment/1' title='frog.png'><img src='/attachment/1/thumb' alt='frog.png' loading='lazy'></a>")
+
+	code, headers, body := srv.get(t, "/attachment/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "image/png")
+	assert.Equal(t, body, strings.TrimSpace(upload.String()))
+
+	code, headers, body = srv.get(t, "/attachment/1/thumb")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "image/png")
+
+	thumb, err := png.Decode(strings.NewReader(body))
+	assert.NilError(t, err)
+	assert.Equal(t, thumb.Bounds().Dx(), thumbnailSize)
+	assert.Equal(t, thumb.Bounds().Dy(), thumbnailSize/2)
+
+	code, _, _ = srv.get(t, "/attachment/2")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Attachments on private snippets are only for those who can see them
+	attachments.Attachments[0].SnippetID = 4
+	code, _, _ = srv.get(t, "/attachment/1")
+	assert.Equal(t, code, http.StatusNotFound)
+	attachments.Attachments[0].SnippetID = 1
+
+	for i := 1; i < models.MaxCommentAttachments; i++ {
+		attach(csrfToken, "1", "frog.png", upload.Bytes())
+	}
+	attach(csrfToken, "1", "frog.png", upload.Bytes())
+	assert.Equal(t, len(attachments.Attachments), models.MaxCommentAttachments)
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "A comment can have at most 4 images.")
+}
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		choice  string
+		date    string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "Days", choice: "7", want: time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)},
+		{name: "Never", choice: "never", want: models.NeverExpires},
+		{name: "Custom", choice: "custom", date: "2024-12-25", want: time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC)},
+		{name: "Today", choice: "custom", date: "2024-03-01", want: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "Far future", choice: "custom", date: "9999-12-31", want: models.NeverExpires},
+		{name: "Past", choice: "custom", date: "2024-02-29", wantErr: "This date has passed"},
+		{name: "No date", choice: "custom", wantErr: "Pick the date to delete the snippet on"},
+		{name: "Other days", choice: "30", wantErr: "This field must equal 1, 7, 365, never or custom"},
+		{name: "Blank", choice: "", wantErr: "This field must equal 1, 7, 365, never or custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+
+			got := checkExpiry(&v, tt.choice, tt.date, now)
+			assert.Equal(t, v.FieldErrors["expires"], tt.wantErr)
+			if tt.wantErr == "" {
+				assert.Equal(t, got.Equal(tt.want), true)
+			}
+		})
+	}
+}
+
+func TestSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, _, body := srv.get(t, "/snippet/view/6")
+	assert.Equal(t, code, http.StatusGone)
+	assert.StringContains(t, body, "This snippet has expired")
+
+	code, _, _ = srv.get(t, "/snippet/raw/6")
+	assert.Equal(t, code, http.StatusGone)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/snippets/6", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Accept", "application/json")
+
+	code, _, body = srv.do(t, req)
+	assert.Equal(t, code, http.StatusGone)
+	assert.StringContains(t, body, "This snippet has expired")
+
+	code, _, _ = srv.get(t, "/snippet/view/99")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	_, _, body = srv.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='never' > Never")
+	assert.StringContains(t, body, "<input type='date' name='expires_on' value=''>")
+
+	form := url.Values{}
+	form.Add("title", "Forever")
+	form.Add("content", "Content")
+	form.Add("format", "plain")
+	form.Add("visibility", "public")
+	form.Add("expires", "custom")
+	form.Add("expires_on", "2000-01-01")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = srv.post(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This date has passed")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='custom' checked> At the end of")
+
+	form.Set("expires", "never")
+	code, _, _ = srv.post(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/snippet/expires/1' method='POST'>")
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		expires   string
+		expiresOn string
+		wantCode  int
+		wantFlash string
+	}{
+		{"Never", "/snippet/expires/1", "never", "", http.StatusSeeOther, "The snippet will be kept until you delete it."},
+		{"Custom", "/snippet/expires/1", "custom", "9000-01-01", http.StatusSeeOther, "The snippet now expires on 02 Jan 9000 at 00:00."},
+		{"Invalid", "/snippet/expires/1", "custom", "", http.StatusSeeOther, "Pick the date to delete the snippet on"},
+		{"Not the author", "/snippet/expires/4", "never", "", http.StatusNotFound, ""},
+		{"Expired", "/snippet/expires/6", "never", "", http.StatusGone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("expires_on", tt.expiresOn)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := srv.post(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFlash != "" {
+				assert.Equal(t, headers.Get("Location"), "/snippet/view/1")
+
+				_, _, body := srv.get(t, "/snippet/view/1")
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Build information, compiled in with
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD)" ./cmd/web
+//
+// Without it, the commit is taken from the VCS information Go stamps into
+// binaries built inside a checkout.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildCommit returns the commit the binary was built from, or "unknown".
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// readinessTimeout bounds the time all the readiness checks can take
+// together, so that probes get an answer before they give up.
+const readinessTimeout = 2 * time.Second
+
+// healthCheck is a component that must work for the server to be ready.
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthz tells that the process is up and serving. It checks nothing else,
+// so that a database outage does not get the server restarted.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, http.StatusOK, envelope{
+		"status":  "ok",
+		"version": version,
+		"commit":  buildCommit(),
+	}, nil)
+}
+
+// readyz runs the readiness checks and answers with the status of each, and
+// a 503 if any failed, so that load balancers stop sending requests. Errors
+// are logged rather than sent, as the endpoint is public.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status, code := "ok", http.StatusOK
+	components := make(map[string]string, len(app.readiness))
+
+	for _, c := range app.readiness {
+		if err := c.check(ctx); err != nil {
+			app.errorLog.Printf("readiness check %s: %v", c.name, err)
+			components[c.name] = "fail"
+			status, code = "fail", http.StatusServiceUnavailable
+			continue
+		}
+
+		components[c.name] = "ok"
+	}
+
+	app.writeJSON(w, code, envelope{
+		"status":     status,
+		"components": components,
+		"version":    version,
+		"commit":     buildCommit(),
+	}, nil)
+}
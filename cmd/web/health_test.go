@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	code, headers, body := srv.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json; charset=utf-8")
+
+	var health map[string]string
+	assert.NilError(t, json.Unmarshal([]byte(body), &health))
+	assert.Equal(t, health["status"], "ok")
+	assert.Equal(t, health["version"], version)
+	assert.Equal(t, health["commit"] != "", true)
+}
+
+func TestReadyz(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	var dbErr error
+	app.readiness = []healthCheck{
+		{"database", func(context.Context) error { return dbErr }},
+		{"sessions", app.sessions.Ping},
+	}
+
+	type readiness struct {
+		Status     string            `json:"status"`
+		Components map[string]string `json:"components"`
+	}
+
+	code, _, body := srv.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusOK)
+
+	var ready readiness
+	assert.NilError(t, json.Unmarshal([]byte(body), &ready))
+	assert.Equal(t, ready.Status, "ok")
+	assert.Equal(t, ready.Components["database"], "ok")
+	assert.Equal(t, ready.Components["sessions"], "ok")
+
+	// One failing component makes the server unready, without saying why
+	dbErr = errors.New("dial tcp: connection refused")
+
+	code, _, body = srv.get(t, "/readyz")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+
+	ready = readiness{}
+	assert.NilError(t, json.Unmarshal([]byte(body), &ready))
+	assert.Equal(t, ready.Status, "fail")
+	assert.Equal(t, ready.Components["database"], "fail")
+	assert.Equal(t, ready.Components["sessions"], "ok")
+}
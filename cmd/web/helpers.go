@@ -2,12 +2,23 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/go-playground/form/v4"
+	"snippetbox.jmorelli.dev/internal/mention"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/syntax"
+	"snippetbox.jmorelli.dev/internal/validator"
 )
 
 // serverError writes the stack error message.
@@ -34,26 +45,99 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// gone renders the page for a snippet that has expired, with the status 410
+// Gone, so that links to it are known to be dead rather than mistyped.
+func (app *application) gone(w http.ResponseWriter, r *http.Request) {
+	app.render(w, http.StatusGone, "gone.tmpl.html", app.newTemplateData(r))
+}
+
 // render will use the in memory cached template and execute the given page
 func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
-	ts, ok := app.templateCache[page]
-	if !ok {
-		err := fmt.Errorf("the template %s does not exit", page)
+	b, err := app.executePage(page, data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.WriteHeader(status)
+
+	b.WriteTo(w)
+}
+
+// renderCacheable renders a page like render, but lets the browser keep it
+// as long as it checks back first: the page goes out with an ETag and with
+// modified as its Last-Modified, and a browser that has it already gets a
+// 304. Pages are drawn for their viewer, so only the browser may keep them.
+func (app *application) renderCacheable(w http.ResponseWriter, r *http.Request, page string, data *templateData, modified time.Time) {
+	b, err := app.executePage(page, data)
+	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	// The CSRF token is masked anew on every request, so it is left out of
+	// the ETag; the token in a page the browser kept stays valid
+	content := b.Bytes()
+	if data.CSRFToken != "" {
+		content = bytes.ReplaceAll(content, []byte(data.CSRFToken), nil)
+	}
+	sum := sha256.Sum256(content)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", modified, bytes.NewReader(b.Bytes()))
+}
+
+// executePage executes the page template with data, in the locale of data.
+func (app *application) executePage(page string, data *templateData) (*bytes.Buffer, error) {
+	ts, ok := app.templateCache[page]
+	if !ok {
+		return nil, fmt.Errorf("the template %s does not exit", page)
+	}
+
+	// The cached templates are shared, so the functions of the locale go on
+	// a copy
+	if data.Locale != nil {
+		var err error
+		ts, err = ts.Clone()
+		if err != nil {
+			return nil, err
+		}
+		ts.Funcs(template.FuncMap{"T": data.Locale.T, "humanDate": data.Locale.Date})
+	}
+
 	// trial render to check for runtime errors
 	b := &bytes.Buffer{}
 	err := ts.ExecuteTemplate(b, "base", data)
 	if err != nil {
-		app.serverError(w, err)
-		return
+		return nil, err
 	}
 
-	w.WriteHeader(status)
+	return b, nil
+}
 
-	b.WriteTo(w)
+// renderEmail executes the plain text and HTML templates of an email.
+func (app *application) renderEmail(name string, data any) (string, string, error) {
+	t, ok := app.emailTemplates[name]
+	if !ok {
+		return "", "", fmt.Errorf("the email template %s does not exist", name)
+	}
+
+	var text, html bytes.Buffer
+
+	err := t.text.Execute(&text, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = t.html.Execute(&html, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return text.String(), html.String(), nil
 }
 
 // decodePostForm will parse the requested form to a given destination
@@ -77,6 +161,145 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// audit records an action by, or a login to, the user with the given ID in
+// the audit log. The action has already happened by the time it is recorded,
+// so a failure to record it is logged rather than failing the request.
+func (app *application) audit(r *http.Request, userID int, action, detail string) {
+	err := app.auditLog.Insert(userID, action, detail, clientIP(r))
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// auditFailedLogin records a failed attempt to log in with an email address.
+func (app *application) auditFailedLogin(r *http.Request, email string) {
+	err := app.auditLog.InsertFailedLogin(email, clientIP(r))
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// notify tells userID that the current user did kind to a snippet or comment
+// of theirs. Like audit, it only logs a failure, as the request itself
+// succeeded.
+func (app *application) notify(r *http.Request, userID int, kind string, snippetID, commentID int) {
+	err := app.notifications.Insert(userID, app.viewerID(r), kind, snippetID, commentID)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// notifyComment tells the author of the comment replied to, if any, the users
+// mentioned in it and the owner of the snippet about a new comment. Someone
+// who is more than one of these is told once, in that order.
+func (app *application) notifyComment(r *http.Request, snippetID, parentID, commentID int, content string) {
+	snippet, err := app.snippets.Get(snippetID)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	told := make(map[int]bool)
+	tell := func(userID int, kind string) {
+		if userID == 0 || told[userID] {
+			return
+		}
+		told[userID] = true
+		app.notify(r, userID, kind, snippetID, commentID)
+	}
+
+	if parentID > 0 {
+		parent, err := app.comments.GetContext(r.Context(), parentID)
+		if err != nil {
+			app.errorLog.Print(err)
+		} else {
+			tell(parent.AuthorID, models.NotifyCommentReply)
+		}
+	}
+
+	users, err := app.mentionedUsers(content)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+	for _, name := range mention.Parse(content) {
+		id, ok := users[strings.ToLower(name)]
+		// Only the owner can see a private snippet, so nobody else hears of it
+		if ok && (snippet.Visibility != models.VisibilityPrivate || id == snippet.UserID) {
+			tell(id, models.NotifyCommentMention)
+		}
+	}
+
+	tell(snippet.UserID, models.NotifySnippetComment)
+}
+
+// mentionedUsers returns the IDs of the users mentioned in contents, keyed by
+// lower case name. Names shared by several users, and banned users, are left
+// out, as there is nobody to link to.
+func (app *application) mentionedUsers(contents ...string) (map[string]int, error) {
+	var names []string
+	for _, content := range contents {
+		names = append(names, mention.Parse(content)...)
+	}
+
+	users, err := app.users.GetByNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int)
+	shared := make(map[string]bool)
+	for _, usr := range users {
+		key := strings.ToLower(usr.Name)
+		if _, ok := ids[key]; ok {
+			shared[key] = true
+		}
+		if !usr.Banned {
+			ids[key] = usr.ID
+		}
+	}
+	for key := range shared {
+		delete(ids, key)
+	}
+
+	return ids, nil
+}
+
+// commentMentions returns the users mentioned in comments, for linking.
+func (app *application) commentMentions(comments []*models.Comment) (map[string]int, error) {
+	contents := make([]string, len(comments))
+	for i, c := range comments {
+		contents[i] = c.Content
+	}
+
+	return app.mentionedUsers(contents...)
+}
+
+// upvoted reports whether a vote left an upvote that was not there before, so
+// that taking a vote back notifies nobody.
+func upvoted(result models.VoteResult) bool {
+	return result.Type == "upvote" && result.Action != models.ActionRemoved
+}
+
+// unreadNotifications returns the current user's unread notification count,
+// or 0 if it cannot be had, so that the nav bar never breaks a page.
+func (app *application) unreadNotifications(r *http.Request) int {
+	count, err := app.notifications.Unread(app.viewerID(r))
+	if err != nil {
+		app.errorLog.Print(err)
+		return 0
+	}
+	return count
+}
+
+// clientIP returns the IP address a request came from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // isAuthenticated returns true if a user is logged in
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
@@ -86,3 +309,244 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// ownsSnippet reports whether the authenticated user wrote the snippet.
+func (app *application) ownsSnippet(r *http.Request, snippet *models.Snippet) bool {
+	return app.isAuthenticated(r) && snippet.UserID == app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// hasRole reports whether the user making the request has role or a role
+// ranked above it. Anonymous visitors have none.
+func (app *application) hasRole(r *http.Request, role string) (bool, error) {
+	id := app.viewerID(r)
+	if id == 0 {
+		return false, nil
+	}
+
+	usr, err := app.users.Get(id)
+	if err != nil {
+		return false, err
+	}
+
+	return usr.HasRole(role), nil
+}
+
+// canModify reports whether the user making the request may change or delete
+// something that ownerID owns: they must be the owner or have role.
+func (app *application) canModify(r *http.Request, ownerID int, role string) (bool, error) {
+	id := app.viewerID(r)
+	if id != 0 && id == ownerID {
+		return true, nil
+	}
+
+	return app.hasRole(r, role)
+}
+
+// viewerID returns the ID of the user making the request, taken from the API
+// credentials or the session, or 0 for an anonymous visitor.
+func (app *application) viewerID(r *http.Request) int {
+	if id := apiUserID(r); id != 0 {
+		return id
+	}
+
+	if app.isAuthenticated(r) {
+		return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	}
+
+	return 0
+}
+
+// serveCacheable sends body with an ETag, so that clients which have it
+// already get a 304, and lets them keep it for maxAge. Only public responses
+// may be kept by shared caches.
+func serveCacheable(w http.ResponseWriter, r *http.Request, contentType string, body []byte, public bool, maxAge time.Duration) {
+	sum := sha256.Sum256(body)
+
+	cache := "private"
+	if public {
+		cache = "public"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", cache, int(maxAge.Seconds())))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// snippetFilename returns the name a snippet is downloaded as: its title in
+// lower case, with everything but ASCII letters and digits turned into
+// hyphens, and the extension of its language.
+func snippetFilename(s *models.Snippet) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(s.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+
+	if s.Format == models.FormatMarkdown {
+		return name + ".md"
+	}
+	return name + syntax.Extension(s.Language)
+}
+
+// Expiry choices of the snippet forms, besides a number of days.
+const (
+	expiresNever  = "never"
+	expiresCustom = "custom"
+)
+
+// checkExpiry validates the expiry chosen on a snippet form, adding any error
+// to v under "expires", and returns when the snippet should expire: a number
+// of days from now, never, or at the end of a custom date (UTC), which must
+// not have passed.
+func checkExpiry(v *validator.Validator, choice, date string, now time.Time) time.Time {
+	switch choice {
+	case expiresNever:
+		return models.NeverExpires
+	case expiresCustom:
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			v.AddFieldError("expires", "Pick the date to delete the snippet on")
+			return time.Time{}
+		}
+
+		expires := day.AddDate(0, 0, 1)
+		if !expires.After(now) {
+			v.AddFieldError("expires", "This date has passed")
+			return time.Time{}
+		}
+		if expires.After(models.NeverExpires) {
+			expires = models.NeverExpires
+		}
+
+		return expires
+	}
+
+	days, err := strconv.Atoi(choice)
+	if err != nil || !validator.PermittedValue(days, 1, 7, 365) {
+		v.AddFieldError("expires", "This field must equal 1, 7, 365, never or custom")
+		return time.Time{}
+	}
+
+	return now.AddDate(0, 0, days)
+}
+
+// checkSnippetFiles validates the extra files of a snippet form, adding any
+// errors to v under "files", and returns the files that are not left blank.
+func checkSnippetFiles(v *validator.Validator, forms []snippetFileForm) []*models.SnippetFile {
+	files := []*models.SnippetFile{}
+	seen := make(map[string]bool)
+
+	for _, f := range forms {
+		name := strings.TrimSpace(f.Name)
+		if name == "" && strings.TrimSpace(f.Content) == "" {
+			continue
+		}
+
+		v.CheckField(validator.NotBlank(name), "files", "Every file needs a name")
+		v.CheckField(validator.MaxChars(name, models.MaxFileNameLength), "files", fmt.Sprintf("File names cannot be more than %d characters long", models.MaxFileNameLength))
+		v.CheckField(name == "" || validator.Matches(name, validator.FileNameRX), "files", "File names can only contain letters, digits, ., _ and -")
+		// Names are compared the way the database collation does
+		v.CheckField(!seen[strings.ToLower(name)], "files", "File names must be unique")
+		v.CheckField(validator.NotBlank(f.Content), "files", "Files cannot be blank")
+		v.CheckField(syntax.Supported(f.Language), "files", "This language is not supported")
+
+		seen[strings.ToLower(name)] = true
+		files = append(files, &models.SnippetFile{Name: name, Language: f.Language, Content: f.Content})
+	}
+
+	v.CheckField(len(files) <= models.MaxSnippetFiles, "files", fmt.Sprintf("A snippet cannot have more than %d extra files", models.MaxSnippetFiles))
+
+	return files
+}
+
+// snippetFileForms returns the extra files of a snippet as form fields.
+func snippetFileForms(files []*models.SnippetFile) []snippetFileForm {
+	forms := make([]snippetFileForm, len(files))
+	for i, f := range files {
+		forms[i] = snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+	return forms
+}
+
+// snippetVisible reports whether the user making the request may open the
+// snippet with the given ID. A snippet that does not exist counts as visible,
+// so that callers report it the way they already do.
+func (app *application) snippetVisible(r *http.Request, id int) (bool, error) {
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return snippet.VisibleTo(app.viewerID(r)), nil
+}
+
+// commentSort returns the comment order for the snippet page. A valid ?sort=
+// value is remembered in the session and used until the user picks another.
+func (app *application) commentSort(r *http.Request) models.SortOrder {
+	permitted := []models.SortOrder{models.SortOldest, models.SortNewest, models.SortTop, models.SortControversial}
+
+	order := models.SortOrder(r.URL.Query().Get("sort"))
+	if validator.PermittedValue(order, permitted...) {
+		app.sessionManager.Put(r.Context(), "commentSort", string(order))
+		return order
+	}
+
+	order = models.SortOrder(app.sessionManager.GetString(r.Context(), "commentSort"))
+	if validator.PermittedValue(order, permitted...) {
+		return order
+	}
+
+	return models.SortOldest
+}
+
+// pageParam returns the page number from the ?page= query parameter, or 1 when
+// it is missing or not a positive integer.
+func (app *application) pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+
+	return page
+}
+
+// countComments sets CommentsNumber on each of the snippets.
+func (app *application) countComments(r *http.Request, snippets []*models.Snippet) error {
+	ids := make([]int, len(snippets))
+	for i, snippet := range snippets {
+		ids[i] = snippet.ID
+	}
+
+	counts, err := app.comments.CountBySnippetIDsContext(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	for _, snippet := range snippets {
+		snippet.CommentsNumber = counts[snippet.ID]
+	}
+
+	return nil
+}
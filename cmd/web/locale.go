@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+
+	"snippetbox.jmorelli.dev/internal/i18n"
+)
+
+// locale returns the locale r is answered in: the one the user picked for
+// the session, or else the one that best fits the languages their browser
+// accepts.
+func (app *application) locale(r *http.Request) *i18n.Locale {
+	if l := i18n.Get(app.sessionManager.GetString(r.Context(), "locale")); l != nil {
+		return l
+	}
+
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// flash stores message, translated for the user, to be shown on the next
+// page they see. Any args are formatted into the translation as by
+// fmt.Sprintf.
+func (app *application) flash(r *http.Request, message string, args ...any) {
+	app.sessionManager.Put(r.Context(), "flash", app.locale(r).T(message, args...))
+}
+
+// localePost switches the session to the locale in the form, or back to
+// following the browser if it is empty, then returns to the page the user
+// was on.
+func (app *application) localePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	tag := r.PostForm.Get("locale")
+	if tag == "" {
+		app.sessionManager.Remove(r.Context(), "locale")
+		http.Redirect(w, r, refererPath(r), http.StatusSeeOther)
+		return
+	}
+
+	l := i18n.Get(tag)
+	if l == nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "locale", l.Tag)
+
+	http.Redirect(w, r, refererPath(r), http.StatusSeeOther)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/i18n"
+	"snippetbox.jmorelli.dev/ui"
+)
+
+func TestLocale(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	getIn := func(acceptLanguage string) string {
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", acceptLanguage)
+
+		_, _, body := srv.do(t, req)
+		return body
+	}
+
+	body := getIn("")
+	assert.StringContains(t, body, "<html lang='en'>")
+	assert.StringContains(t, body, "Latest Snippets")
+
+	body = getIn("pt-BR,pt;q=0.9,en;q=0.8")
+	assert.StringContains(t, body, "<html lang='pt-BR'>")
+	assert.StringContains(t, body, "Snippets recentes")
+	assert.StringContains(t, body, "<option value='pt-BR' selected>Português (Brasil)</option>")
+
+	// Dates are written the way the locale writes them
+	if !ptDateRX.MatchString(body) {
+		t.Errorf("no date in pt-BR format in %q", body)
+	}
+
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, _ := srv.post(t, "/locale", url.Values{"locale": {"xx"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// The locale picked for the session wins over the browser's
+	code, headers, _ := srv.post(t, "/locale", url.Values{"locale": {"pt-br"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
+
+	body = getIn("en-US")
+	assert.StringContains(t, body, "<html lang='pt-BR'>")
+
+	// Flash messages are translated
+	csrfToken = loginAs(t, srv, "jay@email.com")
+	code, _, _ = srv.post(t, "/comment/delete/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Comentário excluído.")
+
+	// An empty locale goes back to following the browser
+	code, _, _ = srv.post(t, "/locale", url.Values{"locale": {""}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	body = getIn("en-US")
+	assert.StringContains(t, body, "<html lang='en'>")
+}
+
+var ptDateRX = regexp.MustCompile(`<td>\d{2} (jan|fev|mar|abr|mai|jun|jul|ago|set|out|nov|dez) \d{4} às \d{2}:\d{2}</td>`)
+
+var (
+	templateMessageRX = regexp.MustCompile(`\{\{T ("(?:[^"\\]|\\.)*")`)
+	flashMessageRX    = regexp.MustCompile(`app\.flash\(r, ("(?:[^"\\]|\\.)*")`)
+)
+
+// TestTranslations checks that every catalog translates the messages of the
+// templates and the flash messages.
+func TestTranslations(t *testing.T) {
+	messages := make(map[string]string)
+
+	err := fs.WalkDir(ui.Files, "html", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(ui.Files, path)
+		if err != nil {
+			return err
+		}
+		for _, m := range templateMessageRX.FindAllSubmatch(b, -1) {
+			messages[string(m[1])] = path
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	sources, err := filepath.Glob("*.go")
+	assert.NilError(t, err)
+	for _, path := range sources {
+		b, err := os.ReadFile(path)
+		assert.NilError(t, err)
+		for _, m := range flashMessageRX.FindAllSubmatch(b, -1) {
+			messages[string(m[1])] = path
+		}
+	}
+
+	for _, l := range i18n.All() {
+		if l == i18n.Default {
+			continue
+		}
+
+		for quoted, path := range messages {
+			msg, err := strconv.Unquote(quoted)
+			assert.NilError(t, err)
+
+			if !l.Has(msg) {
+				t.Errorf("%s: %s has no translation of %q", path, l.Tag, msg)
+			}
+		}
+	}
+}
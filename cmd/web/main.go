@@ -1,55 +1,163 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"snippetbox.jmorelli.dev/internal/config"
+	"snippetbox.jmorelli.dev/internal/mailer"
+	"snippetbox.jmorelli.dev/internal/metrics"
+	"snippetbox.jmorelli.dev/internal/migrations"
 	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/oauth"
+	"snippetbox.jmorelli.dev/internal/pubsub"
+	"snippetbox.jmorelli.dev/internal/ratelimit"
+	"snippetbox.jmorelli.dev/internal/storage"
+	"snippetbox.jmorelli.dev/internal/validator"
 )
 
 // Application hold application-wide dependencies for the web application
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	debug          bool
-	snippets       models.SnippetModelInterface
-	comments 		 	 models.CommentModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	errorLog           *log.Logger
+	infoLog            *log.Logger
+	debug              bool
+	snippets           models.SnippetModelInterface
+	comments           models.CommentModelInterface
+	users              models.UserModelInterface
+	searches           models.SearchModelInterface
+	tags               models.TagModelInterface
+	shareLinks         models.ShareLinkModelInterface
+	passwordResets     models.PasswordResetModelInterface
+	emailVerifications models.EmailVerificationModelInterface
+	identities         models.UserIdentityModelInterface
+	apiTokens          models.APITokenModelInterface
+	webhooks           models.WebhookModelInterface
+	webhookClient      *http.Client
+	exports            models.ExportModelInterface
+	commentEvents      *models.CommentHub
+	twoFactor          models.TwoFactorModelInterface
+	auditLog           models.AuditModelInterface
+	loginAttempts      models.LoginAttemptModelInterface
+	notifications      models.NotificationModelInterface
+	favorites          models.FavoriteModelInterface
+	follows            models.FollowModelInterface
+	snippetFiles       models.SnippetFileModelInterface
+	attachments        models.AttachmentModelInterface
+	uploads            storage.Store
+	sessions           models.SessionModelInterface
+	metrics            *serverMetrics
+	metricsUser        string
+	metricsPassword    string
+	readiness          []healthCheck
+	lockoutAfter       int
+	oauthProviders     map[string]oauth.Provider
+	limits             rateLimits
+	commentLimits      validator.TextLimits
+	mailer             mailer.Mailer
+	baseURL            string
+	templateCache      map[string]*template.Template
+	emailTemplates     map[string]*emailTemplate
+	formDecoder        *form.Decoder
+	sessionManager     *scs.SessionManager
+}
+
+// rateLimits holds the limiters of the write endpoints most worth abusing.
+// Each counts requests per IP address and per user separately.
+type rateLimits struct {
+	logins   *ratelimit.Limiter
+	snippets *ratelimit.Limiter
+	comments *ratelimit.Limiter
+	votes    *ratelimit.Limiter
 }
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
-	debug := flag.Bool("debug", false, "Debug mode - disabled by default")
+	cfg := config.Default()
+	configFlags(flag.CommandLine, cfg)
+
+	configFile := flag.String("config", "", "TOML file to read the settings from; "+config.EnvPrefix+"* environment variables override it")
+	printConfig := flag.Bool("print-config", false, "Print the settings in effect, secrets redacted, and exit")
+	migrate := flag.Bool("migrate", false, "Apply the pending database migrations and exit")
+	digest := flag.Bool("digest", false, "Send the weekly digests that are due and exit")
 	flag.Parse()
 
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 
-	db, err := openDB(*dsn)
+	if err := loadConfig(flag.CommandLine, cfg, *configFile, os.LookupEnv); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	if *printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	appMetrics := newServerMetrics()
+
+	db, err := openDB(cfg.Database.DSN, appMetrics.queries)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	defer db.Close()
 
+	if *migrate || cfg.Features.AutoMigrate {
+		if err := runMigrations(cfg.Database.DSN, infoLog); err != nil {
+			errorLog.Fatal(err)
+		}
+		if *migrate {
+			return
+		}
+	}
+
 	tc, err := newTemplateCache()
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
+	etc, err := newEmailTemplateCache()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	secret := []byte(cfg.ShareSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Print("No -share-secret given, share links will not survive a restart")
+	}
+
+	var mail mailer.Mailer = &mailer.Log{Logger: infoLog}
+	if cfg.SMTP.Addr != "" {
+		mail = &mailer.SMTP{Addr: cfg.SMTP.Addr, Username: cfg.SMTP.Username, Password: cfg.SMTP.Password, From: cfg.SMTP.From}
+	}
+
+	oauthProviders := map[string]oauth.Provider{}
+	if cfg.OAuth.GitHubClientID != "" {
+		oauthProviders["github"] = oauth.NewGitHub(cfg.OAuth.GitHubClientID, cfg.OAuth.GitHubClientSecret)
+	}
+	if cfg.OAuth.GoogleClientID != "" {
+		oauthProviders["google"] = oauth.NewGoogle(cfg.OAuth.GoogleClientID, cfg.OAuth.GoogleClientSecret)
+	}
+
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
@@ -57,47 +165,178 @@ func main() {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	sessions := &models.SessionModel{DB: db}
+
+	var snippets models.SnippetModelInterface = &models.SnippetModel{DB: db}
+	var comments models.CommentModelInterface = &models.CommentModel{DB: db}
+	var tags models.TagModelInterface = &models.TagModel{DB: db}
+	comments = models.NewFilteringCommentModel(comments, newSpamFilter(cfg.Spam, cfg.BaseURL, comments))
+	if cfg.Cache.TTL > 0 {
+		cache := models.NewMemoryCache()
+		snippets = models.NewCachingSnippetModel(snippets, cache, cfg.Cache.TTL)
+		comments = models.NewCachingCommentModel(comments, cache, cfg.Cache.TTL)
+		tags = &models.CachingTagModel{TagModelInterface: tags, Cache: cache}
+	}
+
+	// Live updates of snippet pages hear of new comments and votes here
+	commentEvents := pubsub.New[int, models.CommentEvent]()
+	comments = models.NewPublishingCommentModel(comments, commentEvents)
+
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		debug:          *debug,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		comments:			 	&models.CommentModel{DB: db},
+		errorLog:           errorLog,
+		infoLog:            infoLog,
+		debug:              cfg.Features.Debug,
+		snippets:           snippets,
+		users:              &models.UserModel{DB: db},
+		searches:           &models.SearchModel{DB: db},
+		tags:               tags,
+		comments:           comments,
+		shareLinks:         &models.ShareLinkModel{DB: db, Secret: secret},
+		passwordResets:     &models.PasswordResetModel{DB: db},
+		emailVerifications: &models.EmailVerificationModel{DB: db},
+		identities:         &models.UserIdentityModel{DB: db},
+		apiTokens:          &models.APITokenModel{DB: db},
+		webhooks:           &models.WebhookModel{DB: db},
+		webhookClient:      newWebhookClient(),
+		exports:            &models.ExportModel{DB: db},
+		commentEvents:      commentEvents,
+		twoFactor:          &models.TwoFactorModel{DB: db},
+		auditLog:           &models.AuditModel{DB: db},
+		loginAttempts:      &models.LoginAttemptModel{DB: db},
+		favorites:          &models.FavoriteModel{DB: db},
+		follows:            &models.FollowModel{DB: db},
+		snippetFiles:       &models.SnippetFileModel{DB: db},
+		attachments:        &models.AttachmentModel{DB: db},
+		uploads:            &storage.Disk{Dir: cfg.UploadDir},
+		sessions:           sessions,
+		metrics:            appMetrics,
+		metricsUser:        cfg.Metrics.User,
+		metricsPassword:    cfg.Metrics.Password,
+		notifications:      &models.NotificationModel{DB: db},
+		lockoutAfter:       cfg.Limits.LockoutAfter,
+		oauthProviders:     oauthProviders,
+		limits: rateLimits{
+			logins:   ratelimit.New(cfg.Limits.Logins, time.Minute),
+			snippets: ratelimit.New(cfg.Limits.Snippets, time.Minute),
+			comments: ratelimit.New(cfg.Limits.Comments, time.Minute),
+			votes:    ratelimit.New(cfg.Limits.Votes, time.Minute),
+		},
+		commentLimits: validator.TextLimits{
+			Min: cfg.Comments.MinLength,
+			Max: cfg.Comments.MaxLength,
+		},
+		readiness: []healthCheck{
+			{"database", db.PingContext},
+			{"sessions", sessions.Ping},
+			{"migrations", func(ctx context.Context) error { return migrations.Check(ctx, db) }},
+		},
+		mailer:         mail,
+		baseURL:        strings.TrimSuffix(cfg.BaseURL, "/"),
 		templateCache:  tc,
+		emailTemplates: etc,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
 
+	if *digest {
+		sent, err := app.sendDigests()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Sent %d digest(s)", sent)
+		return
+	}
+
 	// For better performance under heavy workload
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 	}
 
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
-		Handler:      app.routes(),
-		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-	}
-
-	infoLog.Printf("Starting server on %s", *addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+		Addr:           cfg.Addr,
+		ErrorLog:       errorLog,
+		Handler:        app.routes(),
+		TLSConfig:      tlsConfig,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	// Shutting down waits for requests to finish, which event streams only
+	// do when told to
+	srv.RegisterOnShutdown(commentEvents.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var jobs workers
+	jobs.start(ctx, cfg.Jobs.PurgeInterval, app.purge)
+	jobs.start(ctx, cfg.Jobs.WebhookInterval, app.deliverWebhooks)
+	if cfg.Features.Digests {
+		jobs.start(ctx, digestCheckInterval, app.sendDueDigests)
+	}
+
+	infoLog.Printf("Starting server on %s", cfg.Addr)
+	err = app.serve(ctx, srv, func() error {
+		return srv.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key)
+	}, cfg.Server.ShutdownTimeout)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// A second signal kills the process rather than waiting
+	stop()
+
+	infoLog.Print("Waiting for background jobs to finish")
+	jobs.wait()
+
+	infoLog.Print("Server stopped")
 }
 
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// openDB connects to MySQL, timing every statement into queries.
+func openDB(dsn string, queries *metrics.Histogram) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := mysql.NewConnector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	db := sql.OpenDB(metrics.InstrumentConnector(connector, queries))
+
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// runMigrations applies the pending migrations over a connection pool of its
+// own, as migrations need several statements to a query and the server's pool
+// is better off without that.
+func runMigrations(dsn string, infoLog *log.Logger) error {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	cfg.MultiStatements = true
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return err
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	applied, err := migrations.Up(context.Background(), db)
+	for _, m := range applied {
+		infoLog.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	return err
+}
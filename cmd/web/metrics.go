@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.jmorelli.dev/internal/metrics"
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// serverMetrics are the metrics served at /metrics.
+type serverMetrics struct {
+	registry  *metrics.Registry
+	requests  *metrics.Counter
+	durations *metrics.Histogram
+	queries   *metrics.Histogram
+	sessions  *metrics.Gauge
+}
+
+func newServerMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+
+	return &serverMetrics{
+		registry: r,
+		requests: r.NewCounter("snippetbox_http_requests_total",
+			"HTTP requests served, by route and status code.", "method", "route", "status"),
+		durations: r.NewHistogram("snippetbox_http_request_duration_seconds",
+			"Time taken to serve HTTP requests, by route.", metrics.DefaultBuckets, "method", "route"),
+		queries: r.NewHistogram("snippetbox_db_query_duration_seconds",
+			"Time taken by database statements, by operation.", metrics.DefaultBuckets, "operation"),
+		sessions: r.NewGauge("snippetbox_active_sessions",
+			"Sessions that have not expired."),
+	}
+}
+
+// unmatchedRoute labels the requests that matched no route, so that
+// scanners cannot add a series per path they try.
+const unmatchedRoute = "unmatched"
+
+type contextKeyRoute struct{}
+
+// routeRouter is an httprouter.Router that labels each request with the
+// route it matched, for instrument to count it under.
+type routeRouter struct {
+	*httprouter.Router
+}
+
+func (rr routeRouter) Handler(method, path string, handler http.Handler) {
+	rr.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(contextKeyRoute{}).(*string); ok {
+			*route = path
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// statusWriter remembers the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// instrument counts and times every request, by the route it matched in a
+// routeRouter and its status code.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := unmatchedRoute
+		sw := &statusWriter{ResponseWriter: w}
+
+		// Panics are recovered further in, so this always runs
+		defer func() {
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			app.metrics.requests.Inc(r.Method, route, strconv.Itoa(status))
+			app.metrics.durations.Observe(time.Since(start).Seconds(), r.Method, route)
+		}()
+
+		ctx := context.WithValue(r.Context(), contextKeyRoute{}, &route)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+	})
+}
+
+// requireMetricsAccess lets through requests with the -metrics-user and
+// -metrics-password credentials, if set, and admins. Others are asked for
+// the credentials if there are any, or get a 403.
+func (app *application) requireMetricsAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.metricsPassword != "" {
+			user, password, ok := r.BasicAuth()
+			if ok && secureEqual(user, app.metricsUser) && secureEqual(password, app.metricsPassword) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		ok, err := app.hasRole(r, models.RoleAdmin)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if !ok {
+			if app.metricsPassword != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="metrics", charset="UTF-8"`)
+				app.clientError(w, http.StatusUnauthorized)
+			} else {
+				app.clientError(w, http.StatusForbidden)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secureEqual compares a and b in constant time.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// metricsView serves the metrics in the Prometheus text format.
+func (app *application) metricsView(w http.ResponseWriter, r *http.Request) {
+	active, err := app.sessions.Active()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.metrics.sessions.Set(float64(active))
+
+	var buf bytes.Buffer
+	if err := app.metrics.registry.Write(&buf); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
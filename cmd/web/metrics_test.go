@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	app.metricsUser = "metrics"
+	app.metricsPassword = "s3cret"
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	srv.get(t, "/snippet/view/1")
+	srv.get(t, "/snippet/view/999")
+	srv.get(t, "/no/such/page")
+
+	// Anonymous visitors are asked for credentials
+	code, headers, _ := srv.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.StringContains(t, headers.Get("WWW-Authenticate"), `Basic realm="metrics"`)
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("metrics", "wrong")
+	code, _, _ = srv.do(t, req)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	req, _ = http.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("metrics", "s3cret")
+	code, headers, body := srv.do(t, req)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, headers.Get("Content-Type"), "text/plain; version=0.0.4")
+
+	// Requests are counted by route, not by path
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="200"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="/snippet/view/:id",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.StringContains(t, body, `snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/view/:id"} 2`)
+	assert.StringContains(t, body, "snippetbox_active_sessions 3")
+
+	// Admins can see the metrics without the credentials, other users cannot
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, _ = srv.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	app.metricsPassword = ""
+
+	code, _, _ = srv.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	srv.post(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	loginAs(t, srv, "admin@email.com")
+
+	code, _, _ = srv.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+}
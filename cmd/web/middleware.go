@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/ratelimit"
 )
 
 // secureHeaders add HTTP security headers based on the OWASP guide.
@@ -48,6 +56,29 @@ func (app *application) recoverFromPanic(next http.Handler) http.Handler {
 	})
 }
 
+// loadSession is like sessionManager.LoadAndSave for handlers that stream
+// their response, which LoadAndSave would hold back until the handler
+// returns. Changes to the session are not saved, so such handlers, and the
+// middleware in front of them, should only read it.
+func (app *application) loadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if cookie, err := r.Cookie(app.sessionManager.Cookie.Name); err == nil {
+			token = cookie.Value
+		}
+
+		ctx, err := app.sessionManager.Load(r.Context(), token)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		w.Header().Add("Vary", "Cookie")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // requireAuthentication middleware guards against not authenticated users and
 // will redirect them to the login page.
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
@@ -64,9 +95,124 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireVerified blocks users who have not verified their email address
+// yet: HTML pages redirect to the account page with a flash message and the
+// JSON API answers with a 403. It must run after requireAuthentication or
+// apiAuthenticate.
+func (app *application) requireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api := strings.HasPrefix(r.URL.Path, "/api/")
+
+		usr, err := app.users.Get(app.viewerID(r))
+		if err != nil {
+			if api {
+				app.apiServerError(w, err)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		if !usr.Verified {
+			if api {
+				app.apiErrorResponse(w, http.StatusForbidden, "Verify your email address first")
+				return
+			}
+			app.flash(r, "Please verify your email address first.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole returns middleware that only lets users with role, or a role
+// ranked above it, through. Everyone else gets a 403, as JSON on the API. It
+// must run after requireAuthentication or apiAuthenticate.
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			api := strings.HasPrefix(r.URL.Path, "/api/")
+
+			ok, err := app.hasRole(r, role)
+			if err != nil {
+				if api {
+					app.apiServerError(w, err)
+				} else {
+					app.serverError(w, err)
+				}
+				return
+			}
+
+			if !ok {
+				if api {
+					app.apiClientError(w, http.StatusForbidden)
+				} else {
+					app.clientError(w, http.StatusForbidden)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimit returns middleware that lets requests through as fast as l
+// allows, counting them per IP address and, once the user is known, per user.
+// Requests over the limit get a 429 on the JSON API; HTML forms are sent back
+// to the page they came from with a flash message. It must run after
+// authenticate or apiIdentify.
+func (app *application) rateLimit(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.Allow("ip:" + clientIP(r))
+			if ok {
+				if id := app.viewerID(r); id != 0 {
+					ok, wait = l.Allow("user:" + strconv.Itoa(id))
+				}
+			}
+
+			if !ok {
+				seconds := int(math.Ceil(wait.Seconds()))
+
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					app.apiErrorResponse(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+					return
+				}
+
+				app.flash(r, "You are doing that too often. Please try again in %d seconds.", seconds)
+				http.Redirect(w, r, refererPath(r), http.StatusSeeOther)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// refererPath returns the path of the page a request came from on this site,
+// or "/" if there is none. Only the path is kept, so it is safe to redirect
+// to whatever the Referer header says.
+func refererPath(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") || strings.HasPrefix(u.Path, "/\\") {
+		return "/"
+	}
+
+	return (&url.URL{Path: u.Path, RawQuery: u.RawQuery}).String()
+}
+
 // noSurf uses a customized CRSF cookie for protection agaisnt CRSF attacks.
 func (app *application) noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
+	// The JSON API authenticates every request itself and only accepts JSON
+	// bodies, which a cross-site form cannot send.
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, "/api/")
+	})
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
@@ -98,3 +244,203 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireSnippetAccess answers with a 404 when the snippet named by the :id
+// route parameter is private to another user, for both the HTML pages and the
+// JSON API. It must run after authenticate or apiIdentify.
+func (app *application) requireSnippetAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		id, err := strconv.Atoi(params.ByName("id"))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		api := strings.HasPrefix(r.URL.Path, "/api/")
+
+		visible, err := app.snippetVisible(r, id)
+		if err != nil {
+			if api {
+				app.apiServerError(w, err)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		if !visible {
+			if api {
+				app.apiNotFound(w)
+			} else {
+				app.notFound(w)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRequireJSON rejects API requests that cannot accept a JSON response
+// (406) or that send a body which is not JSON (415).
+func (app *application) apiRequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r) {
+			app.apiErrorResponse(w, http.StatusNotAcceptable, "This endpoint only produces application/json")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			if !hasJSONBody(r) {
+				app.apiErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiAuthenticate checks the credentials of an API request, either an API
+// token sent as a Bearer token or an email and password sent as HTTP Basic
+// credentials, and stores the user's ID in the request context. Requests
+// without valid credentials get a 401, as do Basic credentials of accounts
+// with two-factor authentication.
+func (app *application) apiAuthenticate(next http.Handler) http.Handler {
+	identify := app.apiIdentify(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, bearer := bearerToken(r)
+		if _, _, basic := r.BasicAuth(); !bearer && !basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="snippetbox", charset="UTF-8"`)
+			w.Header().Add("WWW-Authenticate", `Bearer realm="snippetbox"`)
+			app.apiClientError(w, http.StatusUnauthorized)
+			return
+		}
+
+		identify.ServeHTTP(w, r)
+	})
+}
+
+// apiIdentify is like apiAuthenticate, but lets requests without credentials
+// through anonymously. It is used by read-only routes, where signing in only
+// matters for private snippets.
+func (app *application) apiIdentify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := bearerToken(r); ok {
+			app.apiIdentifyToken(w, r, token, next)
+			return
+		}
+
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		wait, err := app.loginAttempts.Backoff(email, clientIP(r))
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			app.apiErrorResponse(w, http.StatusTooManyRequests, "Too many failed logins, please wait before trying again")
+			return
+		}
+
+		id, err := app.users.Authenticate(email, password)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrInvalidCredentials):
+				locked, err := app.loginFailed(r, email)
+				if err != nil {
+					app.apiServerError(w, err)
+				} else if locked {
+					app.apiErrorResponse(w, http.StatusForbidden, "This account has been locked after too many failed logins")
+				} else {
+					w.Header().Set("WWW-Authenticate", `Basic realm="snippetbox", charset="UTF-8"`)
+					app.apiErrorResponse(w, http.StatusUnauthorized, "Email or password is incorrect")
+				}
+			case errors.Is(err, models.ErrAccountLocked):
+				app.auditFailedLogin(r, email)
+				app.apiErrorResponse(w, http.StatusForbidden, "This account has been locked after too many failed logins")
+			case errors.Is(err, models.ErrBanned):
+				app.auditFailedLogin(r, email)
+				app.apiErrorResponse(w, http.StatusForbidden, "This account has been banned")
+			default:
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		// A password alone is not enough for accounts with two-factor
+		// authentication, which must use an API token instead
+		enabled, err := app.twoFactor.Enabled(id)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		if enabled {
+			app.apiErrorResponse(w, http.StatusUnauthorized, "This account uses two-factor authentication, please use an API token")
+			return
+		}
+
+		err = app.loginAttempts.Clear(email)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiIdentifyToken identifies the user of an API token for apiIdentify. The
+// token needs the read scope for GET and HEAD requests and the write scope
+// for the others.
+func (app *application) apiIdentifyToken(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	t, err := app.apiTokens.Authenticate(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidAPIToken):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox", error="invalid_token"`)
+			app.apiErrorResponse(w, http.StatusUnauthorized, "API token is invalid or has been revoked")
+		case errors.Is(err, models.ErrBanned):
+			app.apiErrorResponse(w, http.StatusForbidden, "This account has been banned")
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	scope := models.ScopeWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		scope = models.ScopeRead
+	}
+
+	if !t.HasScope(scope) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="snippetbox", error="insufficient_scope", scope="%s"`, scope))
+		app.apiErrorResponse(w, http.StatusForbidden, fmt.Sprintf("This API token does not have the %s scope", scope))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), authenticatedUserIDContextKey, t.UserID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -8,8 +8,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/alexedwards/scs/v2"
 	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/models/mocks"
+	"snippetbox.jmorelli.dev/internal/ratelimit"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -110,10 +115,17 @@ func TestRequireAuthentication(t *testing.T) {
 			})
 
 			app := &application{
-				infoLog:  log.New(io.Discard, "", 0),
-				errorLog: log.New(io.Discard, "", 0),
+				infoLog:        log.New(io.Discard, "", 0),
+				errorLog:       log.New(io.Discard, "", 0),
+				sessionManager: scs.New(),
 			}
 
+			ctx, err := app.sessionManager.Load(r.Context(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = r.WithContext(ctx)
+
 			app.requireAuthentication(next).ServeHTTP(rr, r)
 
 			res := rr.Result()
@@ -128,3 +140,181 @@ func TestRequireAuthentication(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   int
+		wantCode int
+		wantNext bool
+	}{
+		{
+			name:     "Anonymous",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "User",
+			userID:   1,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Moderator",
+			userID:   6,
+			wantCode: http.StatusOK,
+			wantNext: true,
+		},
+		{
+			name:     "Admin outranks moderator",
+			userID:   7,
+			wantCode: http.StatusOK,
+			wantNext: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			})
+
+			app := &application{
+				infoLog:        log.New(io.Discard, "", 0),
+				errorLog:       log.New(io.Discard, "", 0),
+				users:          &mocks.UserModel{},
+				sessionManager: scs.New(),
+			}
+
+			ctx, err := app.sessionManager.Load(r.Context(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.userID != 0 {
+				app.sessionManager.Put(ctx, "authenticatedUserID", tt.userID)
+				ctx = context.WithValue(ctx, isAuthenticatedContextKey, true)
+			}
+			r = r.WithContext(ctx)
+
+			app.requireRole(models.RoleModerator)(next).ServeHTTP(rr, r)
+
+			res := rr.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, res.StatusCode, tt.wantCode)
+
+			if tt.wantNext {
+				assert.NextHandler(t, res.Body)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		remoteAddrs  []string
+		userIDs      []int
+		wantCode     int
+		wantLocation string
+		wantRetry    string
+	}{
+		{
+			name:         "Form from one IP",
+			path:         "/comment/create",
+			remoteAddrs:  []string{"192.0.2.1:1234", "192.0.2.1:1235", "192.0.2.1:1236"},
+			userIDs:      []int{0, 0, 0},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1?page=2",
+		},
+		{
+			name:        "API from one IP",
+			path:        "/api/v1/snippets",
+			remoteAddrs: []string{"192.0.2.1:1234", "192.0.2.1:1234", "192.0.2.1:1234"},
+			userIDs:     []int{0, 0, 0},
+			wantCode:    http.StatusTooManyRequests,
+			wantRetry:   "30",
+		},
+		{
+			name:         "One user from many IPs",
+			path:         "/comment/create",
+			remoteAddrs:  []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.3:1234"},
+			userIDs:      []int{1, 1, 1},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1?page=2",
+		},
+		{
+			name:        "Many users from many IPs",
+			path:        "/comment/create",
+			remoteAddrs: []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.3:1234"},
+			userIDs:     []int{1, 3, 5},
+			wantCode:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &application{
+				infoLog:        log.New(io.Discard, "", 0),
+				errorLog:       log.New(io.Discard, "", 0),
+				sessionManager: scs.New(),
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			})
+
+			handler := app.rateLimit(ratelimit.New(2, time.Minute))(next)
+
+			var res *http.Response
+
+			for i, addr := range tt.remoteAddrs {
+				rr := httptest.NewRecorder()
+
+				r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+				r.RemoteAddr = addr
+				r.Header.Set("Referer", "https://elsewhere.example/snippet/view/1?page=2")
+
+				ctx, err := app.sessionManager.Load(r.Context(), "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.userIDs[i] != 0 {
+					app.sessionManager.Put(ctx, "authenticatedUserID", tt.userIDs[i])
+					ctx = context.WithValue(ctx, isAuthenticatedContextKey, true)
+				}
+
+				handler.ServeHTTP(rr, r.WithContext(ctx))
+				res = rr.Result()
+			}
+
+			assert.Equal(t, res.StatusCode, tt.wantCode)
+			assert.Equal(t, res.Header.Get("Location"), tt.wantLocation)
+			assert.Equal(t, res.Header.Get("Retry-After"), tt.wantRetry)
+		})
+	}
+}
+
+func TestRefererPath(t *testing.T) {
+	tests := []struct {
+		referer string
+		want    string
+	}{
+		{"https://snippetbox.example/snippet/view/1", "/snippet/view/1"},
+		{"https://snippetbox.example/search?q=go", "/search?q=go"},
+		{"", "/"},
+		{"//evil.example/path", "/path"},
+		{"https://snippetbox.example//evil.example", "/"},
+		{"https://snippetbox.example/\\evil.example", "/"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/comment/create", nil)
+		r.Header.Set("Referer", tt.referer)
+
+		assert.Equal(t, refererPath(r), tt.want)
+	}
+}
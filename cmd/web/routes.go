@@ -2,20 +2,24 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
-	"snippetbox.jmorelli.dev/ui"
+	"snippetbox.jmorelli.dev/internal/models"
 )
 
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	router := routeRouter{httprouter.New()}
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiNotFound(w)
+			return
+		}
 		app.notFound(w)
 	})
 
-	fileServer := http.FileServer(http.FS(ui.Files))
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
+	router.Handler(http.MethodGet, "/static/*filepath", serveStatic())
 
 	router.Handler(
 		http.MethodGet, "/",
@@ -23,16 +27,69 @@ func (app *application) routes() http.Handler {
 			app.authenticate(http.HandlerFunc(app.home)),
 		),
 	)
+	router.Handler(
+		http.MethodGet, "/trending",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.trending)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/feed",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.feedView))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/favorites",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.favoritesView))),
+		),
+	)
 	router.Handler(
 		http.MethodGet, "/about",
 		app.sessionManager.LoadAndSave(
 			app.authenticate(http.HandlerFunc(app.about)),
 		),
 	)
+	router.Handler(
+		http.MethodPost, "/locale",
+		app.sessionManager.LoadAndSave(http.HandlerFunc(app.localePost)),
+	)
+	router.Handler(
+		http.MethodPost, "/theme",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.themePost)),
+		),
+	)
 	router.Handler(
 		http.MethodGet, "/snippet/view/:id",
 		app.sessionManager.LoadAndSave(
-			app.authenticate(http.HandlerFunc(app.snippetView)),
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetView))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/view/:id/events",
+		app.loadSession(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetEvents))),
+		),
+	)
+	router.Handler(http.MethodGet, "/snippet/view/:id/comments.atom", app.requireSnippetAccess(http.HandlerFunc(app.snippetCommentsFeed)))
+	router.Handler(http.MethodGet, "/feed.xml", http.HandlerFunc(app.latestFeed))
+	router.Handler(http.MethodGet, "/snippet/embed/:id", http.HandlerFunc(app.snippetEmbed))
+	router.Handler(http.MethodGet, "/snippet/embed/:id/embed.js", http.HandlerFunc(app.snippetEmbedJS))
+	router.Handler(http.MethodGet, "/oembed", http.HandlerFunc(app.oEmbed))
+	router.Handler(http.MethodGet, "/tag/:name/feed.xml", http.HandlerFunc(app.tagFeed))
+	router.Handler(http.MethodGet, "/user/profile/:id/feed.xml", http.HandlerFunc(app.userFeed))
+	router.Handler(
+		http.MethodGet, "/tag/:name",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.tagView)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/search",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.search)),
 		),
 	)
 	router.Handler(
@@ -44,19 +101,255 @@ func (app *application) routes() http.Handler {
 	router.Handler(
 		http.MethodPost, "/snippet/create",
 		app.sessionManager.LoadAndSave(
-			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.snippetCreatePost))),
+			app.authenticate(app.requireAuthentication(app.rateLimit(app.limits.snippets)(http.HandlerFunc(app.snippetCreatePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/edit/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetEdit)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/edit/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetEditPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/expires/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetExpiresPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/delete/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetDeletePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/fork/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetForkPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/vote/:id/:value",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(app.requireVerified(app.rateLimit(app.limits.votes)(http.HandlerFunc(app.snippetVotePost)))))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/star/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(app.rateLimit(app.limits.votes)(http.HandlerFunc(app.snippetStarPost))))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/raw/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetRaw))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/raw/:id/:name",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetFileRaw))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/download/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetDownload))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/history/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetHistory))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/history/:id/:revision",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireSnippetAccess(http.HandlerFunc(app.snippetRevision))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/history/:id/:revision/restore",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetRevisionRestorePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/snippet/share/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetShare)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/share/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetSharePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/snippet/share/:id/revoke/:link",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireSnippetAccess(http.HandlerFunc(app.snippetShareRevokePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/s/:token",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.sharedSnippetView)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/markdown/preview",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.markdownPreview))),
 		),
 	)
 	router.Handler(
 		http.MethodPost, "/comment/create",
 		app.sessionManager.LoadAndSave(
-			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.commentCreatePost))),
+			app.authenticate(app.requireAuthentication(app.requireVerified(app.rateLimit(app.limits.comments)(http.HandlerFunc(app.commentCreatePost))))),
 		),
 	)
 	router.Handler(
 		http.MethodGet, "/comment/vote/:id/:value",
 		app.sessionManager.LoadAndSave(
-			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.voteComment))),
+			app.authenticate(app.requireAuthentication(app.requireVerified(app.rateLimit(app.limits.votes)(http.HandlerFunc(app.voteComment))))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/delete/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.commentDeletePost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/report/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireVerified(http.HandlerFunc(app.commentReportPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/react/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireVerified(app.rateLimit(app.limits.votes)(http.HandlerFunc(app.commentReactPost))))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/pin/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.commentPinPost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/note/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.commentNotePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/comment/attach/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireVerified(app.rateLimit(app.limits.comments)(http.HandlerFunc(app.commentAttachPost))))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/attachment/:id",
+		app.sessionManager.LoadAndSave(app.authenticate(http.HandlerFunc(app.attachmentView))),
+	)
+	router.Handler(
+		http.MethodGet, "/attachment/:id/thumb",
+		app.sessionManager.LoadAndSave(app.authenticate(http.HandlerFunc(app.attachmentView))),
+	)
+	router.Handler(
+		http.MethodGet, "/admin/reports",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.adminReports)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/reports/:id/dismiss",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.adminReportDismissPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/reports/:id/approve",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.adminReportApprovePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/reports/:id/hide",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.adminReportHidePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/reports/:id/delete",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleModerator)(http.HandlerFunc(app.adminReportDeletePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/admin/comments/reject",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.commentRejectMatching)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/comments/reject",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.commentRejectMatchingPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/admin",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminDashboard)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/snippets/:id/delete",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminSnippetDeletePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/admin/audit",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminAudit)))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/admin/users",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminUsers)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/users/:id/role",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminUserRolePost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/users/:id/ban",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminUserBanPost)))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/admin/users/:id/purge",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.requireRole(models.RoleAdmin)(http.HandlerFunc(app.adminUserPurgePost)))),
 		),
 	)
 	router.Handler(
@@ -79,7 +372,73 @@ func (app *application) routes() http.Handler {
 	router.Handler(
 		http.MethodPost, "/user/login",
 		app.sessionManager.LoadAndSave(
-			app.authenticate(http.HandlerFunc(app.userLoginPost)),
+			app.authenticate(app.rateLimit(app.limits.logins)(http.HandlerFunc(app.userLoginPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/unlock",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.userUnlock)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/login/2fa",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.loginTwoFactor)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/user/login/2fa",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.rateLimit(app.limits.logins)(http.HandlerFunc(app.loginTwoFactorPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/oauth/:provider",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.oauthLogin)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/oauth/:provider/callback",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.oauthCallback)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/verify",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.userVerify)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/user/verify/resend",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.userVerifyResendPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/password/forgot",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.passwordForgot)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/user/password/forgot",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.passwordForgotPost)),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/user/password/reset",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.passwordReset)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/user/password/reset",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.passwordResetPost)),
 		),
 	)
 	router.Handler(
@@ -88,12 +447,162 @@ func (app *application) routes() http.Handler {
 			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.userLogoutPost))),
 		),
 	)
+	router.Handler(
+		http.MethodGet, "/user/profile/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(http.HandlerFunc(app.userProfile)),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/user/follow/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(app.rateLimit(app.limits.votes)(http.HandlerFunc(app.userFollowPost)))),
+		),
+	)
 	router.Handler(
 		http.MethodGet, "/account/view",
 		app.sessionManager.LoadAndSave(
 			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.userAccount))),
 		),
 	)
+	router.Handler(
+		http.MethodGet, "/account/trash",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTrash))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/trash/snippets/:id/restore",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTrashSnippetRestorePost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/trash/comments/:id/restore",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTrashCommentRestorePost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/notifications",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountNotifications))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/notifications/read",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountNotificationsReadPost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/notifications/read/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountNotificationReadPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/settings",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountSettings))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/settings",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountSettingsPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/2fa",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTwoFactor))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/2fa/enable",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTwoFactorEnablePost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/2fa/disable",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountTwoFactorDisablePost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/tokens",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountAPITokens))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/tokens",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountAPITokensPost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/tokens/:id/revoke",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountAPITokenRevokePost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/webhooks",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountWebhooks))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/webhooks",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountWebhooksPost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/webhooks/:id",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountWebhookView))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/webhooks/:id/delete",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountWebhookDeletePost))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/export",
+		app.loadSession(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountExport))),
+		),
+	)
+	router.Handler(
+		http.MethodGet, "/account/import",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountImport))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/import",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.accountImportPost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/oauth/link/:provider",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.oauthLinkPost))),
+		),
+	)
+	router.Handler(
+		http.MethodPost, "/account/oauth/unlink/:provider",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.oauthUnlinkPost))),
+		),
+	)
 	router.Handler(
 		http.MethodGet, "/account/password/update",
 		app.sessionManager.LoadAndSave(
@@ -106,5 +615,27 @@ func (app *application) routes() http.Handler {
 			app.authenticate(app.requireAuthentication(http.HandlerFunc(app.passwordUpdatePost))),
 		),
 	)
-	return app.recoverFromPanic(app.logRequest(app.noSurf(secureHeaders(router))))
+
+	router.Handler(http.MethodGet, "/healthz", http.HandlerFunc(app.healthz))
+	router.Handler(http.MethodGet, "/readyz", http.HandlerFunc(app.readyz))
+	router.Handler(
+		http.MethodGet, "/metrics",
+		app.sessionManager.LoadAndSave(
+			app.authenticate(app.requireMetricsAccess(http.HandlerFunc(app.metricsView))),
+		),
+	)
+
+	// JSON API
+	router.Handler(http.MethodGet, "/api/v1/snippets", app.apiRequireJSON(http.HandlerFunc(app.apiSnippetList)))
+	router.Handler(http.MethodPost, "/api/v1/snippets", app.apiRequireJSON(app.apiAuthenticate(app.rateLimit(app.limits.snippets)(http.HandlerFunc(app.apiSnippetCreate)))))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", app.apiRequireJSON(app.apiIdentify(app.requireSnippetAccess(http.HandlerFunc(app.apiSnippetView)))))
+	router.Handler(http.MethodPut, "/api/v1/snippets/:id", app.apiRequireJSON(app.apiAuthenticate(app.requireSnippetAccess(http.HandlerFunc(app.apiSnippetUpdate)))))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", app.apiRequireJSON(app.apiAuthenticate(app.requireSnippetAccess(http.HandlerFunc(app.apiSnippetDelete)))))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id/comments", app.apiRequireJSON(app.apiIdentify(app.requireSnippetAccess(http.HandlerFunc(app.apiCommentList)))))
+	router.Handler(http.MethodPost, "/api/v1/snippets/:id/comments", app.apiRequireJSON(app.apiAuthenticate(app.requireSnippetAccess(app.requireVerified(app.rateLimit(app.limits.comments)(http.HandlerFunc(app.apiCommentCreate)))))))
+	router.Handler(http.MethodGet, "/api/v1/comments/:id", app.apiRequireJSON(app.apiIdentify(http.HandlerFunc(app.apiCommentView))))
+	router.Handler(http.MethodPut, "/api/v1/comments/:id", app.apiRequireJSON(app.apiAuthenticate(http.HandlerFunc(app.apiCommentUpdate))))
+	router.Handler(http.MethodDelete, "/api/v1/comments/:id", app.apiRequireJSON(app.apiAuthenticate(http.HandlerFunc(app.apiCommentDelete))))
+
+	return app.instrument(app.recoverFromPanic(app.logRequest(app.noSurf(secureHeaders(router)))))
 }
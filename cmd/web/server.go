@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// serve runs listen, which starts srv, until it fails or ctx is done. Then
+// srv stops accepting connections and is given until shutdownTimeout to
+// finish the requests in flight, after which the connections left are
+// closed. It returns the error listen failed with, or nil once srv has
+// stopped.
+func (app *application) serve(ctx context.Context, srv *http.Server, listen func() error, shutdownTimeout time.Duration) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- listen()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.infoLog.Printf("Shutting down, waiting up to %s for requests to finish", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		app.errorLog.Printf("Requests still running after %s were cut short: %v", shutdownTimeout, err)
+		srv.Close()
+	}
+
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestServeShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		work     time.Duration
+		timeout  time.Duration
+		wantBody bool
+	}{
+		{"Requests in flight finish", 50 * time.Millisecond, time.Second, true},
+		{"Slow requests are cut short", time.Second, 50 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+
+			started := make(chan struct{})
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.work):
+					w.Write([]byte("done"))
+				case <-r.Context().Done():
+				}
+			})}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			served := make(chan error, 1)
+			go func() {
+				served <- app.serve(ctx, srv, func() error { return srv.Serve(ln) }, tt.timeout)
+			}()
+
+			got := make(chan string, 1)
+			go func() {
+				rs, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					got <- ""
+					return
+				}
+				defer rs.Body.Close()
+				body, _ := io.ReadAll(rs.Body)
+				got <- string(body)
+			}()
+
+			<-started
+			cancel()
+
+			assert.NilError(t, <-served)
+			assert.Equal(t, <-got == "done", tt.wantBody)
+
+			// No new connections are accepted
+			_, err = http.Get("http://" + ln.Addr().String())
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	app := newTestApplication(t)
+
+	listenErr := errors.New("address already in use")
+	err := app.serve(context.Background(), &http.Server{}, func() error { return listenErr }, time.Second)
+	assert.Equal(t, err, listenErr)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/config"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/spam"
+)
+
+const (
+	// duplicateLookback is how many of a user's latest comments a new one is
+	// compared with.
+	duplicateLookback = 20
+
+	// akismetTimeout caps how long a comment waits on Akismet.
+	akismetTimeout = 5 * time.Second
+)
+
+// newSpamFilter returns the filters the spam settings ask for, in the order
+// they run: the cheap checks first, Akismet last. Earlier comments are read
+// from comments.
+func newSpamFilter(cfg config.Spam, baseURL string, comments models.CommentModelInterface) spam.Pipeline {
+	p := spam.Pipeline{spam.ParseWords(cfg.BannedWords)}
+
+	if cfg.MaxLinks > 0 {
+		p = append(p, spam.LinkLimit(cfg.MaxLinks))
+	}
+
+	if cfg.DuplicateWindow > 0 {
+		p = append(p, spam.Duplicates{Recent: recentComments(comments, cfg.DuplicateWindow)})
+	}
+
+	if cfg.AkismetKey != "" {
+		p = append(p, spam.External{Checker: &spam.Akismet{
+			Key:    cfg.AkismetKey,
+			Site:   baseURL,
+			Client: &http.Client{Timeout: akismetTimeout},
+		}})
+	}
+
+	return p
+}
+
+// recentComments returns a function that gives the content of the comments
+// the author of c posted within window.
+func recentComments(comments models.CommentModelInterface, window time.Duration) func(context.Context, spam.Comment) ([]string, error) {
+	return func(ctx context.Context, c spam.Comment) ([]string, error) {
+		latest, err := comments.GetRecentByAuthorContext(ctx, c.Author, duplicateLookback)
+		if err != nil {
+			return nil, err
+		}
+
+		since := time.Now().Add(-window)
+
+		var contents []string
+		for _, l := range latest {
+			// Names are not unique, so the author is checked by ID
+			if l.AuthorID == c.AuthorID && l.Created.After(since) {
+				contents = append(contents, l.Content)
+			}
+		}
+
+		return contents, nil
+	}
+}
+
+// spamContext returns the context of r with what the spam checks need to
+// know about the request.
+func spamContext(r *http.Request) context.Context {
+	return spam.NewContext(r.Context(), spam.Source{
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	})
+}
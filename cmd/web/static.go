@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"snippetbox.jmorelli.dev/ui"
+)
+
+// staticMaxAge is how long browsers keep a static file requested by its
+// fingerprinted name, which names that content only.
+const staticMaxAge = 365 * 24 * time.Hour
+
+// staticFile is a file under ui/static.
+type staticFile struct {
+	// name is the path of the file in ui.Files.
+	name string
+	// url is the fingerprinted path the file is linked to by, such as
+	// "/static/css/main.0123456789.css".
+	url  string
+	etag string
+}
+
+// staticFiles holds the static files keyed both by their plain path, such as
+// "/static/css/main.css", and by their fingerprinted one.
+var staticFiles = mustFingerprint(ui.Files)
+
+func mustFingerprint(fsys fs.FS) map[string]*staticFile {
+	files := make(map[string]*staticFile)
+
+	err := fs.WalkDir(fsys, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		hash := hex.EncodeToString(sum[:5])
+
+		ext := path.Ext(name)
+		f := &staticFile{
+			name: name,
+			url:  "/" + strings.TrimSuffix(name, ext) + "." + hash + ext,
+			etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+
+		files["/"+name] = f
+		files[f.url] = f
+
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Errorf("static files: %w", err))
+	}
+
+	return files
+}
+
+// staticPath returns the fingerprinted path of the static file at p, or p if
+// there is no such file. Pages link to static files through it, so that a
+// new version of a file gets a new URL and browsers can keep each one for
+// good.
+func staticPath(p string) string {
+	if f, ok := staticFiles[p]; ok {
+		return f.url
+	}
+	return p
+}
+
+// serveStatic serves the files under ui/static by either name. Fingerprinted
+// names are cached for staticMaxAge; plain ones must be revalidated, as their
+// content changes with each release.
+func serveStatic() http.Handler {
+	fileServer := http.FileServer(http.FS(ui.Files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := staticFiles[r.URL.Path]
+		if !ok {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		if r.URL.Path == f.url {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(staticMaxAge.Seconds())))
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		w.Header().Set("ETag", f.etag)
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + f.name
+		fileServer.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestServeStatic(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	url := staticPath("/static/css/main.css")
+	if !regexp.MustCompile(`^/static/css/main\.[0-9a-f]{10}\.css$`).MatchString(url) {
+		t.Fatalf("got fingerprinted path %q", url)
+	}
+	assert.Equal(t, staticPath("/static/css/missing.css"), "/static/css/missing.css")
+
+	_, _, body := srv.get(t, "/")
+	assert.StringContains(t, body, "<link rel='stylesheet' href='"+url+"'>")
+
+	code, headers, fingerprinted := srv.get(t, url)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "public, max-age=31536000, immutable")
+	assert.StringContains(t, headers.Get("Content-Type"), "text/css")
+
+	code, headers, plain := srv.get(t, "/static/css/main.css")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Cache-Control"), "no-cache")
+	assert.Equal(t, plain, fingerprinted)
+
+	req, err := http.NewRequest(http.MethodGet, "/static/css/main.css", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", headers.Get("ETag"))
+	code, _, _ = srv.do(t, req)
+	assert.Equal(t, code, http.StatusNotModified)
+
+	code, _, _ = srv.get(t, "/static/css/main.0000000000.css")
+	assert.Equal(t, code, http.StatusNotFound)
+}
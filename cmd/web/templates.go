@@ -1,48 +1,311 @@
 package main
 
 import (
+	"html"
+	htmltemplate "html/template"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/justinas/nosurf"
+	"snippetbox.jmorelli.dev/internal/i18n"
+	"snippetbox.jmorelli.dev/internal/markdown"
+	"snippetbox.jmorelli.dev/internal/mention"
 	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/syntax"
 	"snippetbox.jmorelli.dev/ui"
 )
 
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Comments				[]*models.Comment
-	User            *models.User
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear      int
+	Snippet          *models.Snippet
+	Snippets         []*models.Snippet
+	Forks            []*models.Snippet
+	Related          []*models.Snippet
+	Files            []*models.SnippetFile
+	Comments         []*models.Comment
+	Attachments      map[int][]*models.Attachment
+	Reports          map[int][]*models.CommentReport
+	HeldComments     []*models.Comment
+	CommentSort      string
+	Language         string
+	Languages        []*syntax.Language
+	Pagination       *pagination
+	MatchedIDs       []int
+	SearchResults    []*models.SearchResult
+	Tags             []*models.Tag
+	Revisions        []*models.SnippetRevision
+	Revision         *models.SnippetRevision
+	ShareLinks       []*models.ShareLink
+	BaseURL          string
+	Tag              string
+	User             *models.User
+	Users            []*models.User
+	Roles            []string
+	Totals           dashboardTotals
+	Profile          *models.User
+	ProfileTab       string
+	ProfileComments  []*models.CommentWithContext
+	TrashedComments  []*models.CommentWithContext
+	TrashDays        int
+	AuditEntries     []*models.AuditEntry
+	AuditActions     []string
+	Notifications    []*models.Notification
+	Mentions         map[string]int
+	VoteTotals       models.VoteTotals
+	Followers        int
+	Following        int
+	IsFollowing      bool
+	FeedItems        []*models.FeedItem
+	OAuthProviders   []oauthProviderLink
+	TOTPSecret       string
+	TOTPURI          string
+	TwoFactorEnabled bool
+	BackupCodes      []string
+	BackupCodesLeft  int
+	APITokens        []*models.APIToken
+	NewAPIToken      string
+	Scopes           []string
+	Webhooks         []*models.Webhook
+	Webhook          *models.Webhook
+	WebhookEvents    []string
+	Deliveries       []*models.WebhookDelivery
+	Form             any
+	Flash            string
+	FeedURL          string
+	EmbedURL         string
+	OEmbedURL        string
+	IsAuthenticated  bool
+	IsOwner          bool
+	Starred          bool
+	// Locale is the language the page is in, and Locales those it can be
+	// switched to.
+	Locale  *i18n.Locale
+	Locales []*i18n.Locale
+	// Theme is the colour scheme the page is drawn in.
+	Theme string
+	// UnreadNotifications is shown next to the bell in the nav bar.
+	UnreadNotifications int
+	CSRFToken           string
+}
+
+// dashboardTotals are the counts shown at the top of the admin dashboard.
+type dashboardTotals struct {
+	Users    int
+	Snippets int
+	Comments int
 }
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
+	data := &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
+		Languages:       syntax.Languages,
+		OAuthProviders:  app.oauthProviderLinks(nil),
+		Locale:          app.locale(r),
+		Locales:         i18n.All(),
+		Theme:           app.theme(r),
+	}
+
+	if data.IsAuthenticated {
+		data.UnreadNotifications = app.unreadNotifications(r)
+	}
+
+	return data
+}
+
+// oauthProviderTitles are the names users see for the OAuth providers they
+// can log in with.
+var oauthProviderTitles = map[string]string{
+	"github": "GitHub",
+	"google": "Google",
+}
+
+// oauthProviderLink is a configured OAuth provider as listed on the login and
+// account settings pages.
+type oauthProviderLink struct {
+	Name   string
+	Title  string
+	Linked bool
+}
+
+// oauthProviderLinks lists the configured OAuth providers by name, marking
+// those that have one of identities.
+func (app *application) oauthProviderLinks(identities []*models.UserIdentity) []oauthProviderLink {
+	names := make([]string, 0, len(app.oauthProviders))
+	for name := range app.oauthProviders {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	links := make([]oauthProviderLink, len(names))
+	for i, name := range names {
+		links[i] = oauthProviderLink{Name: name, Title: oauthProviderTitles[name]}
+		for _, identity := range identities {
+			if identity.Provider == name {
+				links[i].Linked = true
+			}
+		}
+	}
+
+	return links
+}
+
+// pagination describes the current page of a paged list and links to its
+// neighbours under Path, keeping any other query parameters in Params.
+type pagination struct {
+	Path     string
+	Params   url.Values
+	Page     int
+	PageSize int
+	Total    int
 }
 
-func humanDate(time time.Time) string {
-	if time.IsZero() {
+// LastPage returns the number of the last page, which is 1 for an empty list.
+func (p *pagination) LastPage() int {
+	if p.Total <= p.PageSize {
+		return 1
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+func (p *pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p *pagination) HasNext() bool {
+	return p.Page < p.LastPage()
+}
+
+func (p *pagination) PrevURL() string {
+	return p.pageURL(p.Page - 1)
+}
+
+func (p *pagination) NextURL() string {
+	return p.pageURL(p.Page + 1)
+}
+
+func (p *pagination) pageURL(page int) string {
+	query := url.Values{}
+	for k, v := range p.Params {
+		query[k] = v
+	}
+	query.Set("page", strconv.Itoa(page))
+
+	return p.Path + "?" + query.Encode()
+}
+
+// languageLabel returns the display name of a snippet language.
+func languageLabel(name string) string {
+	if lang := syntax.Lookup(name); lang != nil {
+		return lang.Label
+	}
+	return name
+}
+
+// linkMentions links the @name mentions in rendered HTML to the profiles of
+// the users, keyed by lower case name, as found by mentionedUsers.
+func linkMentions(html string, users map[string]int) string {
+	return mention.Link(html, func(name string) string {
+		if id, ok := users[strings.ToLower(name)]; ok {
+			return "/user/profile/" + strconv.Itoa(id)
+		}
 		return ""
+	})
+}
+
+// humanDate formats t in English. Pages are rendered with a humanDate in
+// the locale of the user instead.
+func humanDate(t time.Time) string {
+	return i18n.Default.Date(t)
+}
+
+// excerptLength is the number of characters highlight keeps around the first
+// match of a search.
+const excerptLength = 160
+
+// highlight returns an HTML-escaped excerpt of text around the first match of
+// any word in query, with every match wrapped in a <mark> tag.
+func highlight(text, query string) string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, regexp.QuoteMeta(term))
+	}
+
+	if len(terms) == 0 {
+		return html.EscapeString(excerpt(text, 0))
+	}
+
+	rx := regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+
+	start := 0
+	if loc := rx.FindStringIndex(text); loc != nil {
+		start = utf8.RuneCountInString(text[:loc[0]]) - excerptLength/4
 	}
+	text = excerpt(text, start)
 
-	return time.UTC().Format("02 Jan 2006 at 15:04")
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[loc[0]:loc[1]]) + "</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+// excerpt cuts text to excerptLength characters starting near start, marking
+// the cuts with an ellipsis.
+func excerpt(text string, start int) string {
+	runes := []rune(text)
+	if len(runes) <= excerptLength {
+		return text
+	}
+
+	end := start + excerptLength
+	if end > len(runes) {
+		end = len(runes)
+		start = end - excerptLength
+	}
+	if start < 0 {
+		start = 0
+		end = excerptLength
+	}
+
+	out := string(runes[start:end])
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+
+	return out
 }
 
+// functions are the functions of every template. render replaces T and
+// humanDate with those of the locale of the page.
 var functions = template.FuncMap{
+	"T":         i18n.Default.T,
 	"humanDate": humanDate,
+	"highlight": highlight,
+	"markdown":  markdown.Render,
+	"syntax":    syntax.Render,
+	"language":  languageLabel,
+	"mentions":  linkMentions,
+	"static":    staticPath,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -70,5 +333,61 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts
 	}
 
+	// Standalone pages, such as the embedded snippet, define their own
+	// base instead of using the site's layout.
+	standalone, err := fs.Glob(ui.Files, "html/standalone/*.tmpl.html")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, page := range standalone {
+		name := filepath.Base(page)
+
+		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, page)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[name] = ts
+	}
+
+	return cache, nil
+}
+
+// emailTemplate holds the plain text and HTML bodies of an email.
+type emailTemplate struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// newEmailTemplateCache parses the emails in html/email, keyed by name. Each
+// email has a name.tmpl.txt plain text body and a name.tmpl.html HTML body.
+// Unlike pages, the HTML is escaped by html/template, as emails have no
+// markdown to render.
+func newEmailTemplateCache() (map[string]*emailTemplate, error) {
+	cache := make(map[string]*emailTemplate)
+
+	texts, err := fs.Glob(ui.Files, "html/email/*.tmpl.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, text := range texts {
+		name := strings.TrimSuffix(filepath.Base(text), ".tmpl.txt")
+		html := "html/email/" + name + ".tmpl.html"
+
+		tt, err := template.New(filepath.Base(text)).Funcs(functions).ParseFS(ui.Files, text)
+		if err != nil {
+			return nil, err
+		}
+
+		ht, err := htmltemplate.New(filepath.Base(html)).Funcs(htmltemplate.FuncMap{"humanDate": humanDate}).ParseFS(ui.Files, html)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[name] = &emailTemplate{text: tt, html: ht}
+	}
+
 	return cache, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -39,3 +41,96 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a ", 100) + "pond " + strings.Repeat("b ", 100)
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Single match",
+			text:  "An old silent pond",
+			query: "pond",
+			want:  "An old silent <mark>pond</mark>",
+		},
+		{
+			name:  "Case insensitive, several words",
+			text:  "An old silent Pond",
+			query: "old pond",
+			want:  "An <mark>old</mark> silent <mark>Pond</mark>",
+		},
+		{
+			name:  "Escapes HTML",
+			text:  "<b>pond</b>",
+			query: "pond",
+			want:  "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;",
+		},
+		{
+			name:  "Regexp characters",
+			text:  "a+b = c",
+			query: "a+b",
+			want:  "<mark>a+b</mark> = c",
+		},
+		{
+			name:  "Empty query",
+			text:  "An old silent pond",
+			query: "",
+			want:  "An old silent pond",
+		},
+		{
+			name:  "Excerpt around match",
+			text:  long,
+			query: "pond",
+			want:  "…" + strings.Repeat("a ", 20) + "<mark>pond</mark> " + strings.Repeat("b ", 57) + "b…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, highlight(tt.text, tt.query), tt.want)
+		})
+	}
+}
+
+func TestLinkMentions(t *testing.T) {
+	users := map[string]int{"ada": 7}
+
+	got := linkMentions("<p>Thanks @Ada and @Bob</p>", users)
+	assert.Equal(t, got, `<p>Thanks <a href="/user/profile/7" class="mention">@Ada</a> and @Bob</p>`)
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        pagination
+		wantLast int
+		wantPrev bool
+		wantNext bool
+	}{
+		{name: "Empty", p: pagination{Page: 1, PageSize: 10}, wantLast: 1},
+		{name: "Single page", p: pagination{Page: 1, PageSize: 10, Total: 10}, wantLast: 1},
+		{name: "First of many", p: pagination{Page: 1, PageSize: 10, Total: 11}, wantLast: 2, wantNext: true},
+		{name: "Middle", p: pagination{Page: 2, PageSize: 10, Total: 25}, wantLast: 3, wantPrev: true, wantNext: true},
+		{name: "Last", p: pagination{Page: 3, PageSize: 10, Total: 25}, wantLast: 3, wantPrev: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.p.LastPage(), tt.wantLast)
+			assert.Equal(t, tt.p.HasPrev(), tt.wantPrev)
+			assert.Equal(t, tt.p.HasNext(), tt.wantNext)
+		})
+	}
+
+	p := pagination{Path: "/snippet/view/1", Page: 2, PageSize: 10, Total: 25}
+	assert.Equal(t, p.PrevURL(), "/snippet/view/1?page=1")
+	assert.Equal(t, p.NextURL(), "/snippet/view/1?page=3")
+
+	p = pagination{Path: "/", Params: url.Values{"language": {"go"}}, Page: 1, PageSize: 10, Total: 25}
+	assert.Equal(t, p.NextURL(), "/?language=go&page=2")
+	assert.Equal(t, p.Params.Get("page"), "")
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"html"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
@@ -15,7 +18,13 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"snippetbox.jmorelli.dev/internal/models"
 	"snippetbox.jmorelli.dev/internal/models/mocks"
+	"snippetbox.jmorelli.dev/internal/oauth"
+	"snippetbox.jmorelli.dev/internal/pubsub"
+	"snippetbox.jmorelli.dev/internal/ratelimit"
+	"snippetbox.jmorelli.dev/internal/storage"
+	"snippetbox.jmorelli.dev/internal/validator"
 )
 
 func newTestApplication(t *testing.T) *application {
@@ -24,6 +33,11 @@ func newTestApplication(t *testing.T) *application {
 		t.Fatal(err)
 	}
 
+	emailTemplates, err := newEmailTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
@@ -31,16 +45,126 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Cookie.Secure = true
 
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
+		errorLog:           log.New(io.Discard, "", 0),
+		infoLog:            log.New(io.Discard, "", 0),
+		snippets:           &mocks.SnippetModel{},
+		comments:           &mocks.CommentModel{},
+		users:              &mocks.UserModel{},
+		searches:           &mocks.SearchModel{},
+		tags:               &mocks.TagModel{},
+		shareLinks:         &mocks.ShareLinkModel{},
+		passwordResets:     &mocks.PasswordResetModel{},
+		emailVerifications: &mocks.EmailVerificationModel{},
+		identities:         &mocks.UserIdentityModel{},
+		apiTokens:          &mocks.APITokenModel{},
+		webhooks:           &mocks.WebhookModel{},
+		webhookClient:      &http.Client{},
+		exports:            &mocks.ExportModel{},
+		commentEvents:      pubsub.New[int, models.CommentEvent](),
+		twoFactor:          &mocks.TwoFactorModel{},
+		auditLog:           &mocks.AuditModel{},
+		loginAttempts:      &mocks.LoginAttemptModel{},
+		notifications:      &mocks.NotificationModel{},
+		favorites:          &mocks.FavoriteModel{},
+		follows:            &mocks.FollowModel{},
+		snippetFiles:       &mocks.SnippetFileModel{},
+		attachments:        &mocks.AttachmentModel{},
+		uploads:            &fakeStore{},
+		sessions:           &mocks.SessionModel{},
+		metrics:            newServerMetrics(),
+		lockoutAfter:       3,
+		oauthProviders:     map[string]oauth.Provider{"github": &fakeOAuth{}},
+		limits: rateLimits{
+			logins:   ratelimit.New(0, time.Minute),
+			snippets: ratelimit.New(0, time.Minute),
+			comments: ratelimit.New(0, time.Minute),
+			votes:    ratelimit.New(0, time.Minute),
+		},
+		commentLimits:  validator.TextLimits{Min: 2, Max: 2000},
+		mailer:         &fakeMailer{},
+		baseURL:        "https://snippetbox.test",
 		templateCache:  templateCache,
+		emailTemplates: emailTemplates,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
 }
 
+// fakeMailer records the messages it is asked to send.
+type fakeMailer struct {
+	sent []fakeMail
+}
+
+type fakeMail struct {
+	To, Subject, Body, HTML string
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	m.sent = append(m.sent, fakeMail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func (m *fakeMailer) SendHTML(to, subject, body, htmlBody string) error {
+	m.sent = append(m.sent, fakeMail{To: to, Subject: subject, Body: body, HTML: htmlBody})
+	return nil
+}
+
+// fakeStore keeps the files it is given in memory.
+type fakeStore struct {
+	files map[string][]byte
+}
+
+func (s *fakeStore) Put(key string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if s.files == nil {
+		s.files = make(map[string][]byte)
+	}
+	s.files[key] = b
+
+	return nil
+}
+
+func (s *fakeStore) Get(key string) (io.ReadCloser, error) {
+	b, ok := s.files[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *fakeStore) Delete(key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+// fakeOAuth is a provider that logs in whoever has an identity in
+// fakeIdentities under the code it is given.
+type fakeOAuth struct{}
+
+var fakeIdentities = map[string]*oauth.Identity{
+	"linked-code":   {ID: "1001", Name: "John", Email: "jay@email.com", EmailVerified: true},
+	"new-code":      {ID: "2002", Name: "", Email: "newcomer@example.com", EmailVerified: false},
+	"taken-code":    {ID: "3003", Name: "Jay", Email: "jay@email.com", EmailVerified: true},
+	"no-email-code": {ID: "4004", Name: "Anon"},
+}
+
+func (p *fakeOAuth) AuthCodeURL(state, redirectURL string) string {
+	return "https://provider.example/auth?state=" + url.QueryEscape(state) + "&redirect_uri=" + url.QueryEscape(redirectURL)
+}
+
+func (p *fakeOAuth) Exchange(ctx context.Context, code, redirectURL string) (*oauth.Identity, error) {
+	identity, ok := fakeIdentities[code]
+	if !ok {
+		return nil, errors.New("fakeOAuth: unknown code")
+	}
+	return identity, nil
+}
+
 type testServer struct {
 	*httptest.Server
 }
@@ -97,6 +221,62 @@ func (srv *testServer) post(t *testing.T, urlPath string, form url.Values) (int,
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+func (srv *testServer) do(t *testing.T, req *http.Request) (int, http.Header, string) {
+	req.URL, _ = url.Parse(srv.URL + req.URL.String())
+
+	rs, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(rs.Body)
+	defer rs.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// uploadRequest returns a POST of a multipart form with fields and, unless
+// filename is empty, a file.
+func uploadRequest(t *testing.T, urlPath string, fields url.Values, field, filename string, content []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for name, values := range fields {
+		for _, value := range values {
+			if err := mw.WriteField(name, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if filename != "" {
+		fw, err := mw.CreateFormFile(field, filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, urlPath, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req
+}
+
 var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
 
 func extractCSRFToken(t testing.TB, body string) string {
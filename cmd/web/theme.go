@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+const (
+	// themeCookie holds the theme of the browser, so that pages can be drawn
+	// in it without looking the user up.
+	themeCookie = "theme"
+
+	themeCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// theme returns the theme r is answered in: the one in the theme cookie, or
+// else the one saved for the logged in user, or else the light theme.
+func (app *application) theme(r *http.Request) string {
+	if c, err := r.Cookie(themeCookie); err == nil && models.ValidTheme(c.Value) {
+		return c.Value
+	}
+
+	if app.isAuthenticated(r) {
+		usr, err := app.users.Get(app.viewerID(r))
+		if err == nil && models.ValidTheme(usr.Theme) {
+			return usr.Theme
+		}
+	}
+
+	return models.ThemeLight
+}
+
+// setThemeCookie remembers theme in the browser.
+func setThemeCookie(w http.ResponseWriter, theme string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     themeCookie,
+		Value:    theme,
+		Path:     "/",
+		MaxAge:   themeCookieMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// themePost switches to the theme in the form, or to the other one if it is
+// empty, then returns to the page the user was on. Logged in users keep the
+// theme on every browser they log in with.
+func (app *application) themePost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	theme := r.PostForm.Get("theme")
+	if theme == "" {
+		theme = models.ThemeDark
+		if app.theme(r) == models.ThemeDark {
+			theme = models.ThemeLight
+		}
+	}
+
+	if !models.ValidTheme(theme) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if app.isAuthenticated(r) {
+		err = app.users.SetTheme(app.viewerID(r), theme)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	setThemeCookie(w, theme)
+
+	http.Redirect(w, r, refererPath(r), http.StatusSeeOther)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestTheme(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	_, _, body := srv.get(t, "/about")
+	assert.StringContains(t, body, "<body class='theme-light'>")
+	assert.StringContains(t, body, "Switch to dark mode")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, _ := srv.post(t, "/theme", url.Values{"theme": {"sepia"}, "csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Anonymous visitors keep their theme in a cookie
+	code, headers, _ := srv.post(t, "/theme", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/")
+
+	_, _, body = srv.get(t, "/about")
+	assert.StringContains(t, body, "<body class='theme-dark'>")
+	assert.StringContains(t, body, "Switch to light mode")
+
+	// With no theme in the form, the other one is picked
+	code, _, _ = srv.post(t, "/theme", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = srv.get(t, "/about")
+	assert.StringContains(t, body, "<body class='theme-light'>")
+
+	// Logging in brings in the theme the user saved
+	loginAs(t, srv, "mod@email.com")
+
+	_, _, body = srv.get(t, "/about")
+	assert.StringContains(t, body, "<body class='theme-dark'>")
+}
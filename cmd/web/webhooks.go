@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/validator"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// webhookBatchSize is how many due deliveries are fetched at a time.
+	webhookBatchSize = 50
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// given up on.
+	webhookMaxAttempts = 10
+	// webhookRetryBase is the wait before the first retry. It doubles with
+	// every failed attempt, so the last retry comes about eight and a half
+	// hours after the first attempt.
+	webhookRetryBase = time.Minute
+	// webhookTimeout caps each attempt, from connecting to reading the
+	// response.
+	webhookTimeout = 10 * time.Second
+	// webhookLogSize is how many deliveries the delivery log shows.
+	webhookLogSize = 50
+)
+
+// webhookPayload is the JSON body sent to webhooks. Data holds what the event
+// is about, under a top-level key as in the JSON API.
+type webhookPayload struct {
+	Event   string    `json:"event"`
+	Created time.Time `json:"created"`
+	Data    envelope  `json:"data"`
+}
+
+type webhookVote struct {
+	CommentID int    `json:"comment_id"`
+	SnippetID int    `json:"snippet_id"`
+	UserID    int    `json:"user_id"`
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	Score     int    `json:"score"`
+}
+
+var voteActions = map[models.VoteAction]string{
+	models.ActionAdded:   "added",
+	models.ActionRemoved: "removed",
+	models.ActionChanged: "changed",
+}
+
+type webhookForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"events"`
+	validator.Validator `form:"-"`
+}
+
+// HasEvent reports whether event is ticked on the form.
+func (f webhookForm) HasEvent(event string) bool {
+	for _, e := range f.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEvent queues event, which happened on snippet, for the webhooks that
+// hear of it. Like notify, it only logs a failure, as the request itself
+// succeeded.
+func (app *application) webhookEvent(snippet *models.Snippet, event string, data envelope) {
+	payload, err := json.Marshal(webhookPayload{Event: event, Created: time.Now().UTC(), Data: data})
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	_, err = app.webhooks.Enqueue(event, snippet.UserID, snippet.Visibility == models.VisibilityPublic, payload)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// webhookSnippetCreated queues a snippet.created event for a new snippet.
+func (app *application) webhookSnippetCreated(id int) {
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	app.webhookEvent(snippet, models.EventSnippetCreated, envelope{"snippet": newAPISnippet(snippet)})
+}
+
+// webhookCommentCreated queues a comment.created event for a new comment.
+func (app *application) webhookCommentCreated(r *http.Request, snippetID, commentID int) {
+	snippet, err := app.snippets.Get(snippetID)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	comment, err := app.comments.GetContext(r.Context(), commentID)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	app.webhookEvent(snippet, models.EventCommentCreated, envelope{"comment": newAPIComment(comment)})
+}
+
+// webhookCommentVoted queues a comment.voted event for a vote on comment.
+func (app *application) webhookCommentVoted(r *http.Request, comment *models.Comment, result models.VoteResult) {
+	snippet, err := app.snippets.Get(comment.SnippetID)
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+
+	app.webhookEvent(snippet, models.EventCommentVoted, envelope{"vote": webhookVote{
+		CommentID: comment.ID,
+		SnippetID: comment.SnippetID,
+		UserID:    app.viewerID(r),
+		Type:      result.Type,
+		Action:    voteActions[result.Action],
+		Score:     result.Score,
+	}})
+}
+
+// deliverWebhooks sends the deliveries that are due, a batch at a time, until
+// none are left or ctx is done.
+func (app *application) deliverWebhooks(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := app.webhooks.Due(webhookBatchSize)
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+
+		for _, d := range due {
+			app.deliverWebhook(ctx, d)
+		}
+
+		if len(due) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliverWebhook makes one attempt at a delivery and records the outcome. A
+// failed delivery is retried after webhookBackoff, up to webhookMaxAttempts
+// times in all. An attempt cut short by ctx is not counted.
+func (app *application) deliverWebhook(ctx context.Context, d *models.WebhookDelivery) {
+	code, err := app.sendWebhook(ctx, d)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		if err := app.webhooks.Delivered(d.ID, code); err != nil {
+			app.errorLog.Print(err)
+		}
+		return
+	}
+
+	var retryAt time.Time
+	if attempts := d.Attempts + 1; attempts < webhookMaxAttempts {
+		retryAt = time.Now().Add(webhookBackoff(attempts))
+	}
+
+	if err := app.webhooks.Failed(d.ID, code, err.Error(), retryAt); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// webhookBackoff returns how long to wait after a delivery failed for the
+// given number of times.
+func webhookBackoff(attempts int) time.Duration {
+	return webhookRetryBase << (attempts - 1)
+}
+
+// sendWebhook posts a delivery's payload to its webhook and returns the
+// response code, or 0 if there was no response. Any response but a 2xx is an
+// error.
+func (app *application) sendWebhook(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhook/"+version)
+	req.Header.Set("X-Snippetbox-Event", d.Event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Snippetbox-Signature", signWebhook(d.Secret, []byte(d.Payload)))
+
+	resp, err := app.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Reading some of the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New(resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the X-Snippetbox-Signature of a payload: "sha256="
+// followed by the hex HMAC-SHA256 of the payload, keyed with the webhook's
+// secret. Receivers compute the same to check that a payload came from us.
+func signWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookClient returns the client webhooks are sent with. It does not
+// follow redirects, and it refuses to connect to loopback, private and other
+// internal addresses, so that webhooks cannot be used to reach the servers
+// behind the site. The address is checked after the name is resolved, which
+// also rules out names that resolve to one of them.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("webhook: %s is not a public address", host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   webhookTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicIP reports whether ip is reachable on the public internet.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, http.StatusOK, webhookForm{}, nil)
+}
+
+// accountWebhooksPost registers a webhook and shows the user its secret. This
+// is the only time it is shown.
+func (app *application) accountWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.URL = strings.TrimSpace(form.URL)
+
+	u, err := url.Parse(form.URL)
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	form.CheckField(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", "url", "This field must be an http or https URL")
+	form.CheckField(len(form.Events) > 0, "events", "Choose at least one event")
+	for _, event := range form.Events {
+		form.CheckField(validator.PermittedValue(event, models.WebhookEvents...), "events", "Unknown event")
+	}
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	webhook, err := app.webhooks.Insert(app.viewerID(r), form.URL, form.Events)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderWebhooks(w, r, http.StatusOK, webhookForm{}, webhook)
+}
+
+// accountWebhookDeletePost removes one of the user's webhooks. Deliveries
+// still pending are dropped with it.
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.webhooks.Delete(app.viewerID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(r, "The webhook has been deleted.")
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// accountWebhookView shows the latest deliveries of one of the user's
+// webhooks.
+func (app *application) accountWebhookView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	webhook, err := app.webhooks.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if webhook.UserID != app.viewerID(r) {
+		app.notFound(w)
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(webhook.ID, webhookLogSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = webhook
+	data.Deliveries = deliveries
+	app.render(w, http.StatusOK, "webhook.tmpl.html", data)
+}
+
+// renderWebhooks shows the user's webhooks and the form to register one,
+// along with newWebhook if one was just registered.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, form webhookForm, newWebhook *models.Webhook) {
+	webhooks, err := app.webhooks.ForUser(app.viewerID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	usr, err := app.users.Get(app.viewerID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Webhooks = webhooks
+	data.Webhook = newWebhook
+	data.WebhookEvents = models.WebhookEvents
+	data.User = usr
+	app.render(w, status, "webhooks.tmpl.html", data)
+}
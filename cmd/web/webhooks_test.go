@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/models/mocks"
+)
+
+// recordingWebhooks records the outcome of the deliveries it is told about.
+type recordingWebhooks struct {
+	mocks.WebhookModel
+	delivered map[int64]int
+	failed    map[int64]time.Time
+	reasons   map[int64]string
+}
+
+func (m *recordingWebhooks) Delivered(id int64, responseCode int) error {
+	m.delivered[id] = responseCode
+	return nil
+}
+
+func (m *recordingWebhooks) Failed(id int64, responseCode int, reason string, retryAt time.Time) error {
+	m.failed[id] = retryAt
+	m.reasons[id] = reason
+	return nil
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	var got *http.Request
+	var body string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	app := newTestApplication(t)
+	webhooks := &recordingWebhooks{delivered: map[int64]int{}, failed: map[int64]time.Time{}, reasons: map[int64]string{}}
+	app.webhooks = webhooks
+
+	payload := `{"event":"comment.created"}`
+
+	app.deliverWebhook(context.Background(), &models.WebhookDelivery{
+		ID: 1, URL: ts.URL + "/ok", Secret: "s3cret", Event: models.EventCommentCreated, Payload: payload,
+	})
+	assert.Equal(t, webhooks.delivered[1], http.StatusNoContent)
+	assert.Equal(t, body, payload)
+	assert.Equal(t, got.Header.Get("Content-Type"), "application/json")
+	assert.Equal(t, got.Header.Get("X-Snippetbox-Event"), "comment.created")
+	assert.Equal(t, got.Header.Get("X-Snippetbox-Delivery"), "1")
+	assert.Equal(t, got.Header.Get("X-Snippetbox-Signature"), signWebhook("s3cret", []byte(payload)))
+
+	// A failure is retried after the backoff
+	before := time.Now()
+	app.deliverWebhook(context.Background(), &models.WebhookDelivery{
+		ID: 2, URL: ts.URL + "/fail", Event: models.EventCommentCreated, Payload: payload, Attempts: 2,
+	})
+	retryAt := webhooks.failed[2]
+	assert.Equal(t, webhooks.reasons[2], "500 Internal Server Error")
+	assert.Equal(t, retryAt.Before(before.Add(webhookBackoff(3))), false)
+	assert.Equal(t, retryAt.After(time.Now().Add(webhookBackoff(3))), false)
+
+	// until the last attempt, after which it is given up on
+	app.deliverWebhook(context.Background(), &models.WebhookDelivery{
+		ID: 3, URL: ts.URL + "/fail", Event: models.EventCommentCreated, Payload: payload, Attempts: webhookMaxAttempts - 1,
+	})
+	retryAt, ok := webhooks.failed[3]
+	assert.Equal(t, ok, true)
+	assert.Equal(t, retryAt.IsZero(), true)
+
+	// An attempt cut short by shutdown is not recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.deliverWebhook(ctx, &models.WebhookDelivery{ID: 4, URL: ts.URL + "/ok", Payload: payload})
+	_, ok = webhooks.failed[4]
+	assert.Equal(t, ok, false)
+	_, ok = webhooks.delivered[4]
+	assert.Equal(t, ok, false)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, webhookBackoff(1), time.Minute)
+	assert.Equal(t, webhookBackoff(2), 2*time.Minute)
+	assert.Equal(t, webhookBackoff(9), 256*time.Minute)
+}
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '{"event":"snippet.created"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, signWebhook("secret", []byte(`{"event":"snippet.created"}`)),
+		"sha256=067ca9dc5f4a28861688510200f89aa0162bf0001bb6e910f7113e8142a1b630")
+}
+
+func TestWebhookClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// The test server is on loopback, which webhooks may not reach
+	_, err := newWebhookClient().Post(ts.URL, "application/json", nil)
+	if err == nil {
+		t.Fatal("want an error posting to a loopback address")
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, publicIP(net.ParseIP(tt.ip)), tt.want)
+		})
+	}
+}
+
+func TestAccountWebhooks(t *testing.T) {
+	app := newTestApplication(t)
+
+	srv := newTestServer(t, app.routes())
+	defer srv.Close()
+
+	csrfToken := loginAs(t, srv, "jay@email.com")
+
+	code, _, body := srv.get(t, "/account/webhooks")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "https://hooks.example.com/snippetbox</a>")
+	assert.StringContains(t, body, "<td>comment.created, comment.voted</td>")
+
+	form := url.Values{}
+	form.Add("url", "ftp://example.com")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = srv.post(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be an http or https URL")
+	assert.StringContains(t, body, "Choose at least one event")
+
+	form.Set("url", "https://example.com/hook")
+	form.Add("events", "snippet.deleted")
+
+	code, _, body = srv.post(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Unknown event")
+
+	form.Set("events", "snippet.created")
+
+	code, _, body = srv.post(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "signed with the secret <code>new-secret</code>")
+
+	code, _, body = srv.get(t, "/account/webhooks/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>500 Internal Server Error</td>")
+
+	code, _, _ = srv.get(t, "/account/webhooks/2")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, headers, _ := srv.post(t, "/account/webhooks/1/delete", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/webhooks")
+
+	code, _, _ = srv.post(t, "/account/webhooks/2/delete", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
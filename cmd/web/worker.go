@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// orphanBatchSize is how many orphaned attachments are removed at a time.
+const orphanBatchSize = 100
+
+// workers runs the background jobs of the server, each in its own goroutine,
+// until the context they were started with is done. Jobs only stop between
+// runs, so that wait never cuts one short.
+type workers struct {
+	wg sync.WaitGroup
+}
+
+// start runs job now and then every interval until ctx is done.
+func (ws *workers) start(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	ws.wg.Add(1)
+
+	go func() {
+		defer ws.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			job(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// wait blocks until every job has stopped.
+func (ws *workers) wait() {
+	ws.wg.Wait()
+}
+
+// purge removes for good the snippets and comments that have been in the
+// trash for longer than models.TrashRetention, the snippets that expired
+// more than models.ExpiredRetention ago along with their comments and votes,
+// then the images of the comments that are gone, and the webhook deliveries
+// that finished more than models.WebhookDeliveryRetention ago. What was
+// removed is logged.
+func (app *application) purge(ctx context.Context) {
+	snippets, err := app.snippets.PurgeDeleted()
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	comments, err := app.comments.PurgeDeletedContext(ctx)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if snippets > 0 || comments > 0 {
+		app.infoLog.Printf("Purged %d snippet(s) and %d comment(s) from the trash", snippets, comments)
+	}
+
+	expired, err := app.snippets.PurgeExpired()
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if expired.Snippets > 0 {
+		app.infoLog.Printf("Purged %d expired snippet(s), %d comment(s) and %d vote(s)", expired.Snippets, expired.Comments, expired.Votes)
+	}
+
+	attachments, err := app.purgeAttachments(ctx)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if attachments > 0 {
+		app.infoLog.Printf("Purged %d orphaned attachment(s)", attachments)
+	}
+
+	deliveries, err := app.webhooks.PurgeDeliveries()
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if deliveries > 0 {
+		app.infoLog.Printf("Purged %d old webhook deliveries", deliveries)
+	}
+}
+
+// purgeAttachments removes the files of the attachments whose comment has
+// been purged, and then their records, and returns how many went. It stops
+// at the first file that cannot be removed, leaving the rest for next time.
+func (app *application) purgeAttachments(ctx context.Context) (int, error) {
+	purged := 0
+
+	for ctx.Err() == nil {
+		orphans, err := app.attachments.Orphans(orphanBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, a := range orphans {
+			if err := errors.Join(app.uploads.Delete(a.Key), app.uploads.Delete(a.ThumbnailKey)); err != nil {
+				return purged, err
+			}
+
+			if err := app.attachments.Delete(a.ID); err != nil {
+				return purged, err
+			}
+
+			purged++
+		}
+
+		if len(orphans) < orphanBatchSize {
+			break
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/models"
+	"snippetbox.jmorelli.dev/internal/models/mocks"
+)
+
+func TestWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs atomic.Int32
+	ran := make(chan struct{}, 1)
+
+	var jobs workers
+	jobs.start(ctx, time.Millisecond, func(context.Context) {
+		if runs.Add(1) == 3 {
+			ran <- struct{}{}
+		}
+	})
+
+	<-ran
+	cancel()
+	jobs.wait()
+
+	// No job runs once wait has returned
+	n := runs.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, runs.Load(), n)
+}
+
+func TestPurgeAttachments(t *testing.T) {
+	app := newTestApplication(t)
+
+	store := &fakeStore{files: map[string][]byte{
+		"a.png": {1}, "a-thumb.png": {2},
+		"b.png": {3}, "b-thumb.png": {4},
+		"c.png": {5}, "c-thumb.png": {6},
+	}}
+	app.uploads = store
+
+	attachments := &mocks.AttachmentModel{Attachments: []*models.Attachment{
+		{ID: 1, CommentID: 1, Key: "a.png", ThumbnailKey: "a-thumb.png"},
+		{ID: 2, Key: "b.png", ThumbnailKey: "b-thumb.png"},
+		{ID: 3, Key: "c.png", ThumbnailKey: "c-thumb.png"},
+	}}
+	app.attachments = attachments
+
+	purged, err := app.purgeAttachments(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, purged, 2)
+
+	// Only the attachment that still has a comment is left, with its files
+	assert.Equal(t, len(attachments.Attachments), 1)
+	assert.Equal(t, attachments.Attachments[0].ID, 1)
+	assert.Equal(t, len(store.files), 2)
+
+	_, ok := store.files["a.png"]
+	assert.Equal(t, ok, true)
+
+	// A cancelled context leaves the orphans for next time
+	attachments.Attachments = append(attachments.Attachments, &models.Attachment{ID: 4, Key: "d.png"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	purged, err = app.purgeAttachments(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, purged, 0)
+	assert.Equal(t, len(attachments.Attachments), 2)
+}
@@ -0,0 +1,402 @@
+// Package config holds the settings of the web server. They start from
+// Default, can be read from a TOML file, and can be overridden from the
+// environment, each setting by a variable named after its place in the file:
+// smtp.password is SNIPPETBOX_SMTP_PASSWORD.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the names of the environment variables read by LoadEnv.
+const EnvPrefix = "SNIPPETBOX_"
+
+// Config is every setting of the web server. The toml tags name the keys in
+// the file; fields tagged secret are redacted by Write.
+type Config struct {
+	Addr        string `toml:"addr"`
+	BaseURL     string `toml:"base_url"`
+	ShareSecret string `toml:"share_secret" secret:"true"`
+	UploadDir   string `toml:"upload_dir"`
+
+	TLS      TLS      `toml:"tls"`
+	Database Database `toml:"database"`
+	Cache    Cache    `toml:"cache"`
+	Server   Server   `toml:"server"`
+	SMTP     SMTP     `toml:"smtp"`
+	OAuth    OAuth    `toml:"oauth"`
+	Limits   Limits   `toml:"limits"`
+	Comments Comments `toml:"comments"`
+	Spam     Spam     `toml:"spam"`
+	Metrics  Metrics  `toml:"metrics"`
+	Jobs     Jobs     `toml:"jobs"`
+	Features Features `toml:"features"`
+}
+
+type TLS struct {
+	Cert string `toml:"cert"`
+	Key  string `toml:"key"`
+}
+
+// Database is the database the models read and write. Driver must be one of
+// Drivers.
+type Database struct {
+	Driver string `toml:"driver"`
+	DSN    string `toml:"dsn" secret:"true"`
+}
+
+// Drivers are the database drivers the models have queries for. The queries
+// are written for MySQL, and other databases need their own.
+var Drivers = []string{"mysql"}
+
+// Cache is how long snippets and comment lists read from the database are
+// kept in memory. Zero turns caching off.
+type Cache struct {
+	TTL time.Duration `toml:"ttl"`
+}
+
+type Server struct {
+	ReadTimeout     time.Duration `toml:"read_timeout"`
+	WriteTimeout    time.Duration `toml:"write_timeout"`
+	IdleTimeout     time.Duration `toml:"idle_timeout"`
+	MaxHeaderBytes  int           `toml:"max_header_bytes"`
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout"`
+}
+
+// SMTP is the server outgoing mail is sent through. Mail is logged instead
+// if Addr is empty.
+type SMTP struct {
+	Addr     string `toml:"addr"`
+	Username string `toml:"username"`
+	Password string `toml:"password" secret:"true"`
+	From     string `toml:"from"`
+}
+
+// OAuth holds the OAuth apps for logging in with GitHub and Google. A
+// provider is disabled if its client ID is empty.
+type OAuth struct {
+	GitHubClientID     string `toml:"github_client_id"`
+	GitHubClientSecret string `toml:"github_client_secret" secret:"true"`
+	GoogleClientID     string `toml:"google_client_id"`
+	GoogleClientSecret string `toml:"google_client_secret" secret:"true"`
+}
+
+// Limits are per minute, except LockoutAfter, the failed logins in a row
+// that lock an account. Zero disables a limit.
+type Limits struct {
+	Logins       int `toml:"logins"`
+	Snippets     int `toml:"snippets"`
+	Comments     int `toml:"comments"`
+	Votes        int `toml:"votes"`
+	LockoutAfter int `toml:"lockout_after"`
+}
+
+// Comments bound the length of comments, in characters.
+type Comments struct {
+	MinLength int `toml:"min_length"`
+	MaxLength int `toml:"max_length"`
+}
+
+// MaxCommentLength is the most characters a comment column holds: a TEXT
+// column takes 65,535 bytes, and a character takes up to 4 of them.
+const MaxCommentLength = 16383
+
+// Spam decides which new comments are held for a moderator. BannedWords is a
+// comma-separated list. Comments with more than MaxLinks links, or repeating
+// one the same user posted within DuplicateWindow, are held too; zero turns
+// either check off. Comments are also checked with Akismet if AkismetKey is
+// set.
+type Spam struct {
+	BannedWords     string        `toml:"banned_words"`
+	MaxLinks        int           `toml:"max_links"`
+	DuplicateWindow time.Duration `toml:"duplicate_window"`
+	AkismetKey      string        `toml:"akismet_key" secret:"true"`
+}
+
+// Metrics are the basic auth credentials for /metrics. Only admins can see
+// the metrics if Password is empty.
+type Metrics struct {
+	User     string `toml:"user"`
+	Password string `toml:"password" secret:"true"`
+}
+
+type Jobs struct {
+	PurgeInterval   time.Duration `toml:"purge_interval"`
+	WebhookInterval time.Duration `toml:"webhook_interval"`
+}
+
+// Features turn parts of the server on and off.
+type Features struct {
+	Debug       bool `toml:"debug"`
+	AutoMigrate bool `toml:"auto_migrate"`
+	Digests     bool `toml:"digests"`
+}
+
+// Default returns the settings used when nothing else is given.
+func Default() *Config {
+	return &Config{
+		Addr:      ":4000",
+		BaseURL:   "https://localhost:4000",
+		UploadDir: "./uploads",
+		TLS: TLS{
+			Cert: "./tls/cert.pem",
+			Key:  "./tls/key.pem",
+		},
+		Database: Database{
+			Driver: "mysql",
+			DSN:    "web:pass@/snippetbox?parseTime=true",
+		},
+		Cache: Cache{
+			TTL: 30 * time.Second,
+		},
+		Server: Server{
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     time.Minute,
+			MaxHeaderBytes:  1 << 20,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		SMTP: SMTP{
+			From: "Snippetbox <no-reply@snippetbox.jmorelli.dev>",
+		},
+		Limits: Limits{
+			Logins:       10,
+			Snippets:     5,
+			Comments:     10,
+			Votes:        60,
+			LockoutAfter: 10,
+		},
+		Comments: Comments{
+			MinLength: 1,
+			MaxLength: 2000,
+		},
+		Spam: Spam{
+			MaxLinks:        3,
+			DuplicateWindow: 24 * time.Hour,
+		},
+		Metrics: Metrics{
+			User: "metrics",
+		},
+		Jobs: Jobs{
+			PurgeInterval:   time.Hour,
+			WebhookInterval: 10 * time.Second,
+		},
+		Features: Features{
+			AutoMigrate: true,
+			Digests:     true,
+		},
+	}
+}
+
+// LoadFile sets the settings found in the TOML file at path. Keys that are
+// not settings are errors, so that typos do not go unnoticed.
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := decodeTOML(string(b), c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// LoadEnv sets the settings that have an environment variable, as returned
+// by lookup; use os.LookupEnv.
+func (c *Config) LoadEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+
+	eachSetting(c, func(key string, v reflect.Value, _ reflect.StructField) {
+		name := EnvVar(key)
+
+		s, ok := lookup(name)
+		if !ok {
+			return
+		}
+
+		if err := setString(v, s); err != nil {
+			errs = append(errs, fmt.Errorf("config: %s: %w", name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// EnvVar returns the environment variable for the setting at key, such as
+// "smtp.password".
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Validate reports every setting that cannot work, all at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: "+format, args...))
+		}
+	}
+
+	check(c.Addr != "", "addr must not be empty")
+	supported := false
+	for _, d := range Drivers {
+		supported = supported || d == c.Database.Driver
+	}
+	check(supported, "database.driver must be one of %s, got %q", strings.Join(Drivers, ", "), c.Database.Driver)
+	check(c.Database.DSN != "", "database.dsn must not be empty")
+	check(c.UploadDir != "", "upload_dir must not be empty")
+	check(c.TLS.Cert != "" && c.TLS.Key != "", "tls.cert and tls.key must both be set")
+
+	u, err := url.Parse(c.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"base_url must be an http or https URL, got %q", c.BaseURL)
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"jobs.purge_interval", c.Jobs.PurgeInterval},
+		{"jobs.webhook_interval", c.Jobs.WebhookInterval},
+	} {
+		check(d.value > 0, "%s must be more than zero, got %s", d.key, d.value)
+	}
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes must be more than zero")
+	check(c.Cache.TTL >= 0, "cache.ttl must not be negative, got %s", c.Cache.TTL)
+	check(c.Comments.MinLength >= 1, "comments.min_length must be at least 1, got %d", c.Comments.MinLength)
+	check(c.Comments.MaxLength >= c.Comments.MinLength && c.Comments.MaxLength <= MaxCommentLength,
+		"comments.max_length must be between comments.min_length and %d, got %d", MaxCommentLength, c.Comments.MaxLength)
+	check(c.Spam.DuplicateWindow >= 0, "spam.duplicate_window must not be negative, got %s", c.Spam.DuplicateWindow)
+
+	for _, n := range []struct {
+		key   string
+		value int
+	}{
+		{"limits.logins", c.Limits.Logins},
+		{"limits.snippets", c.Limits.Snippets},
+		{"limits.comments", c.Limits.Comments},
+		{"limits.votes", c.Limits.Votes},
+		{"limits.lockout_after", c.Limits.LockoutAfter},
+		{"spam.max_links", c.Spam.MaxLinks},
+	} {
+		check(n.value >= 0, "%s must not be negative", n.key)
+	}
+
+	check(c.SMTP.Addr == "" || c.SMTP.From != "", "smtp.from must be set to send mail")
+	check(c.OAuth.GitHubClientID == "" || c.OAuth.GitHubClientSecret != "",
+		"oauth.github_client_secret must be set with oauth.github_client_id")
+	check(c.OAuth.GoogleClientID == "" || c.OAuth.GoogleClientSecret != "",
+		"oauth.google_client_secret must be set with oauth.google_client_id")
+	check(c.Metrics.Password == "" || c.Metrics.User != "", "metrics.user must be set with metrics.password")
+
+	return errors.Join(errs...)
+}
+
+// Write writes the settings to w as a TOML file that LoadFile can read back,
+// with secrets that are set replaced by "REDACTED".
+func (c *Config) Write(w io.Writer) error {
+	var b strings.Builder
+	section := ""
+
+	eachSetting(c, func(key string, v reflect.Value, field reflect.StructField) {
+		if name, _, ok := strings.Cut(key, "."); ok && name != section {
+			section = name
+			fmt.Fprintf(&b, "\n[%s]\n", section)
+		}
+
+		value := formatValue(v)
+		if field.Tag.Get("secret") == "true" && !v.IsZero() {
+			value = quote("REDACTED")
+		}
+
+		fmt.Fprintf(&b, "%s = %s\n", key[strings.LastIndex(key, ".")+1:], value)
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// eachSetting calls f with the key, value and field of every setting of c,
+// the top-level ones first.
+func eachSetting(c *Config, f func(key string, v reflect.Value, field reflect.StructField)) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var sections []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			sections = append(sections, i)
+			continue
+		}
+		f(t.Field(i).Tag.Get("toml"), v.Field(i), t.Field(i))
+	}
+
+	for _, i := range sections {
+		section := t.Field(i).Tag.Get("toml")
+		sv, st := v.Field(i), t.Field(i).Type
+		for j := 0; j < st.NumField(); j++ {
+			f(section+"."+st.Field(j).Tag.Get("toml"), sv.Field(j), st.Field(j))
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setString sets v from the text of an environment variable or flag.
+func setString(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 1h", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	default:
+		panic("config: unsupported setting type " + v.Type().String())
+	}
+
+	return nil
+}
+
+// formatValue returns v as a TOML value.
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return quote(time.Duration(v.Int()).String())
+	case v.Kind() == reflect.String:
+		return quote(v.String())
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	default:
+		panic("config: unsupported setting type " + v.Type().String())
+	}
+}
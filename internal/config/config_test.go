@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	doc := `# Snippetbox
+addr = ":8080"   # behind the proxy
+base_url = 'https://snippets.example.com'
+
+[database]
+dsn = "web:p#ss\"word@/snippetbox?parseTime=true"
+
+[server]
+read_timeout = "15s"
+max_header_bytes = 65_536
+
+[features]
+debug = true
+`
+
+	c := Default()
+	assert.NilError(t, decodeTOML(doc, c))
+
+	assert.Equal(t, c.Addr, ":8080")
+	assert.Equal(t, c.BaseURL, "https://snippets.example.com")
+	assert.Equal(t, c.Database.DSN, `web:p#ss"word@/snippetbox?parseTime=true`)
+	assert.Equal(t, c.Server.ReadTimeout, 15*time.Second)
+	assert.Equal(t, c.Server.MaxHeaderBytes, 65536)
+	assert.Equal(t, c.Features.Debug, true)
+
+	// Settings not in the file keep their defaults
+	assert.Equal(t, c.Server.WriteTimeout, 10*time.Second)
+	assert.Equal(t, c.Limits.Votes, 60)
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"Unknown setting", "adr = \":80\"", "line 1: unknown setting adr"},
+		{"Unknown table", "\n[smpt]\naddr = \"x\"", "line 2: unknown table [smpt]"},
+		{"Setting in the wrong table", "[smtp]\ndsn = \"x\"", "line 2: unknown setting smtp.dsn"},
+		{"Wrong type", "[limits]\nvotes = \"many\"", "line 2: limits.votes: must be an integer"},
+		{"Bad duration", "[server]\nread_timeout = \"5 seconds\"", `line 2: server.read_timeout: "5 seconds" is not a duration`},
+		{"Duration without quotes", "[server]\nread_timeout = 5", "must be a duration"},
+		{"Set twice", "addr = \":1\"\naddr = \":2\"", "line 2: addr is set twice"},
+		{"Unterminated string", "addr = \":1", "line 1: addr: unterminated string"},
+		{"Trailing garbage", "addr = \":1\" \":2\"", "line 1: unexpected"},
+		{"No value", "addr", "line 1: expected key = value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeTOML(tt.doc, Default())
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"SNIPPETBOX_ADDR":                    ":9000",
+		"SNIPPETBOX_SMTP_PASSWORD":           "hunter2",
+		"SNIPPETBOX_SERVER_SHUTDOWN_TIMEOUT": "1m",
+		"SNIPPETBOX_FEATURES_DIGESTS":        "false",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	c := Default()
+	assert.NilError(t, c.LoadEnv(lookup))
+
+	assert.Equal(t, c.Addr, ":9000")
+	assert.Equal(t, c.SMTP.Password, "hunter2")
+	assert.Equal(t, c.Server.ShutdownTimeout, time.Minute)
+	assert.Equal(t, c.Features.Digests, false)
+
+	env["SNIPPETBOX_LIMITS_VOTES"] = "lots"
+	err := Default().LoadEnv(lookup)
+	assert.StringContains(t, err.Error(), `SNIPPETBOX_LIMITS_VOTES: "lots" is not a whole number`)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NilError(t, Default().Validate())
+
+	c := Default()
+	c.Addr = ""
+	c.BaseURL = "localhost:4000"
+	c.Server.IdleTimeout = 0
+	c.Limits.Logins = -1
+	c.Cache.TTL = -time.Second
+	c.Spam.MaxLinks = -1
+	c.Comments.MaxLength = 20000
+	c.Database.Driver = "sqlite"
+	c.OAuth.GitHubClientID = "abc"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	for _, want := range []string{
+		"addr must not be empty",
+		`base_url must be an http or https URL, got "localhost:4000"`,
+		"server.idle_timeout must be more than zero",
+		"limits.logins must not be negative",
+		"cache.ttl must not be negative, got -1s",
+		"spam.max_links must not be negative",
+		"comments.max_length must be between comments.min_length and 16383, got 20000",
+		`database.driver must be one of mysql, got "sqlite"`,
+		"oauth.github_client_secret must be set with oauth.github_client_id",
+	} {
+		assert.StringContains(t, err.Error(), want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := Default()
+	c.SMTP.Addr = "smtp.example.com:587"
+	c.SMTP.Password = "hunter2"
+	c.SMTP.From = "Snippetbox \"Robot\" <robot@example.com>"
+
+	var buf bytes.Buffer
+	assert.NilError(t, c.Write(&buf))
+
+	out := buf.String()
+	assert.StringContains(t, out, "[smtp]\naddr = \"smtp.example.com:587\"")
+	assert.StringContains(t, out, `password = "REDACTED"`)
+	assert.StringContains(t, out, "read_timeout = \"5s\"")
+	assert.Equal(t, bytes.Contains(buf.Bytes(), []byte("hunter2")), false)
+
+	// What is written can be read back
+	path := filepath.Join(t.TempDir(), "snippetbox.toml")
+	assert.NilError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	read := Default()
+	assert.NilError(t, read.LoadFile(path))
+	assert.Equal(t, read.SMTP.From, c.SMTP.From)
+	assert.Equal(t, read.SMTP.Password, "REDACTED")
+	assert.Equal(t, read.Server, c.Server)
+	assert.Equal(t, read.Limits, c.Limits)
+	assert.Equal(t, read.Features, c.Features)
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeTOML sets the settings of c found in doc, a TOML document limited to
+// what Config needs: tables one level deep holding keys set to strings,
+// integers or booleans. Durations are strings such as "30s".
+func decodeTOML(doc string, c *Config) error {
+	settings := make(map[string]reflect.Value)
+	sections := make(map[string]bool)
+	eachSetting(c, func(key string, v reflect.Value, _ reflect.StructField) {
+		settings[key] = v
+		if section, _, ok := strings.Cut(key, "."); ok {
+			sections[section] = true
+		}
+	})
+
+	section := ""
+	seen := make(map[string]bool)
+
+	for i, line := range strings.Split(doc, "\n") {
+		n := i + 1
+		line = strings.TrimSpace(line)
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			header, _, _ := strings.Cut(line, "#")
+			header = strings.TrimSpace(header)
+			if !strings.HasSuffix(header, "]") {
+				return fmt.Errorf("line %d: table header must end with ]", n)
+			}
+
+			section = strings.TrimSpace(header[1 : len(header)-1])
+			if !sections[section] {
+				return fmt.Errorf("line %d: unknown table [%s]", n, section)
+			}
+			continue
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		key := strings.TrimSpace(name)
+		if section != "" {
+			key = section + "." + key
+		}
+
+		v, ok := settings[key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown setting %s", n, key)
+		}
+		if seen[key] {
+			return fmt.Errorf("line %d: %s is set twice", n, key)
+		}
+		seen[key] = true
+
+		value, rest, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" && rest[0] != '#' {
+			return fmt.Errorf("line %d: unexpected %q after the value of %s", n, rest, key)
+		}
+
+		if err := setValue(v, value); err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+	}
+
+	return nil
+}
+
+// parseValue reads the value at the start of s: a string, integer or
+// boolean. It returns the value and what follows it.
+func parseValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", errors.New("missing value")
+	case s[0] == '"':
+		return parseBasicString(s)
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+
+	word, rest := s, ""
+	if i := strings.IndexAny(s, " \t#"); i >= 0 {
+		word, rest = s[:i], s[i:]
+	}
+
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+
+	n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("%q is not a string, integer or boolean", word)
+	}
+
+	return int(n), rest, nil
+}
+
+// parseBasicString reads a double-quoted string, with its escapes, from the
+// start of s.
+func parseBasicString(s string) (any, string, error) {
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return nil, "", errors.New("unterminated string")
+			}
+			i++
+
+			switch s[i] {
+			case '"', '\\':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u', 'U':
+				size := 4
+				if s[i] == 'U' {
+					size = 8
+				}
+				if i+size >= len(s) {
+					return nil, "", errors.New("unterminated string")
+				}
+
+				r, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return nil, "", fmt.Errorf("bad escape \\%s", s[i:i+1+size])
+				}
+				b.WriteRune(rune(r))
+				i += size
+			default:
+				return nil, "", fmt.Errorf("bad escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return nil, "", errors.New("unterminated string")
+}
+
+// setValue sets v to a parsed value, which must be of the setting's type.
+func setValue(v reflect.Value, value any) error {
+	switch x := value.(type) {
+	case string:
+		if v.Type() == durationType {
+			return setString(v, x)
+		}
+		if v.Kind() == reflect.String {
+			v.SetString(x)
+			return nil
+		}
+	case int:
+		if v.Kind() == reflect.Int && v.Type() != durationType {
+			v.SetInt(int64(x))
+			return nil
+		}
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(x)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be %s", typeName(v))
+}
+
+func typeName(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return "a duration such as \"30s\""
+	case v.Kind() == reflect.String:
+		return "a string"
+	case v.Kind() == reflect.Int:
+		return "an integer"
+	default:
+		return "true or false"
+	}
+}
+
+// quote returns s as a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,253 @@
+// Package i18n translates the user interface. Messages are looked up by
+// their English text in the catalog of a locale, so English needs no
+// translations and a message missing from a catalog is shown in English.
+// The catalogs are the JSON files in the locales directory, one per locale,
+// named after its tag.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Locale is a language the interface can be shown in.
+type Locale struct {
+	// Tag is the BCP 47 tag of the locale, such as "pt-BR".
+	Tag string
+	// Name is the name of the language in that language, for users to pick
+	// it by.
+	Name string
+
+	dateLayout string
+	months     []string
+	messages   map[string]string
+	formats    []format
+}
+
+// format matches messages formatted from a catalog message with verbs in
+// it, so that messages formatted before they get to T, such as validation
+// errors, can still be translated.
+type format struct {
+	rx  *regexp.Regexp
+	msg string
+}
+
+var verbRX = regexp.MustCompile(`%(\[\d+\])?[ds]`)
+
+// catalog is the content of a locale file.
+type catalog struct {
+	Name string `json:"name"`
+	// DateLayout is the time.Format layout of dates, in which "Jan" stands
+	// for the month as named in Months.
+	DateLayout string            `json:"dateLayout"`
+	Months     []string          `json:"months"`
+	Messages   map[string]string `json:"messages"`
+}
+
+// Default is the locale of users who accept none of the others.
+var Default *Locale
+
+// locales holds the loaded locales keyed by lower case tag.
+var locales = mustLoad()
+
+func mustLoad() map[string]*Locale {
+	names, err := fs.Glob(files, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+
+	locales := make(map[string]*Locale)
+	for _, name := range names {
+		b, err := files.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+
+		var c catalog
+		err = json.Unmarshal(b, &c)
+		if err != nil {
+			panic(fmt.Errorf("i18n: %s: %w", name, err))
+		}
+		if len(c.Months) != 0 && len(c.Months) != 12 {
+			panic(fmt.Errorf("i18n: %s: months must name all 12 months", name))
+		}
+
+		tag := strings.TrimSuffix(path.Base(name), ".json")
+		l := &Locale{
+			Tag:        tag,
+			Name:       c.Name,
+			dateLayout: c.DateLayout,
+			months:     c.Months,
+			messages:   c.Messages,
+		}
+
+		for msg, tr := range c.Messages {
+			if tr == "" || !verbRX.MatchString(msg) || strings.Contains(msg, "%[") {
+				continue
+			}
+
+			parts := verbRX.Split(msg, -1)
+			verbs := verbRX.FindAllString(msg, -1)
+
+			var pattern strings.Builder
+			pattern.WriteString("^" + regexp.QuoteMeta(parts[0]))
+			for i, verb := range verbs {
+				if verb == "%d" {
+					pattern.WriteString(`(-?\d+)`)
+				} else {
+					pattern.WriteString(`(.+?)`)
+				}
+				pattern.WriteString(regexp.QuoteMeta(parts[i+1]))
+			}
+			pattern.WriteString("$")
+			rx := regexp.MustCompile(pattern.String())
+
+			// What the verbs matched is text now, so they all become %s
+			tr = verbRX.ReplaceAllStringFunc(tr, func(verb string) string {
+				return verb[:len(verb)-1] + "s"
+			})
+
+			l.formats = append(l.formats, format{rx: rx, msg: tr})
+		}
+
+		// The longest messages are the most specific, so they are tried first
+		sort.Slice(l.formats, func(i, j int) bool {
+			return len(l.formats[i].rx.String()) > len(l.formats[j].rx.String())
+		})
+
+		locales[strings.ToLower(tag)] = l
+	}
+
+	Default = locales["en"]
+
+	return locales
+}
+
+// Get returns the locale with the given tag, regardless of case, or nil if
+// there is none.
+func Get(tag string) *Locale {
+	return locales[strings.ToLower(tag)]
+}
+
+// All returns the locales ordered by tag.
+func All() []*Locale {
+	all := make([]*Locale, 0, len(locales))
+	for _, l := range locales {
+		all = append(all, l)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Tag < all[j].Tag })
+
+	return all
+}
+
+// Match returns the locale that best fits an Accept-Language header, or
+// Default if none does. Languages are tried by preference; one that is not
+// available as such is matched by its base language, so "pt-PT" and "pt"
+// both get "pt-BR".
+func Match(acceptLanguage string) *Locale {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []accepted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, accepted{tag, q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if l := Get(t.tag); l != nil {
+			return l
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(t.tag), "-")
+		for _, l := range All() {
+			lbase, _, _ := strings.Cut(strings.ToLower(l.Tag), "-")
+			if lbase == base {
+				return l
+			}
+		}
+	}
+
+	return Default
+}
+
+// T translates msg, which is then formatted with args as by fmt.Sprintf if
+// there are any.
+//
+// A msg that is not in the catalog but was formatted from a message that is
+// gets the translation of that message, with the same values.
+func (l *Locale) T(msg string, args ...any) string {
+	if tr, ok := l.messages[msg]; ok && tr != "" {
+		msg = tr
+	} else if len(args) == 0 {
+		for _, f := range l.formats {
+			if m := f.rx.FindStringSubmatch(msg); m != nil {
+				values := make([]any, len(m)-1)
+				for i, v := range m[1:] {
+					values[i] = v
+				}
+				return fmt.Sprintf(f.msg, values...)
+			}
+		}
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Has reports whether the catalog of the locale translates msg.
+func (l *Locale) Has(msg string) bool {
+	return l.messages[msg] != ""
+}
+
+// Date formats t in UTC the way the locale writes dates, or returns "" if t
+// is zero.
+func (l *Locale) Date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	t = t.UTC()
+	if len(l.months) == 0 {
+		return t.Format(l.dateLayout)
+	}
+
+	// Go only knows the English month names, so the month is left out of
+	// the layout and filled in afterwards
+	out := t.Format(strings.Replace(l.dateLayout, "Jan", "\x00", 1))
+	return strings.Replace(out, "\x00", l.months[t.Month()-1], 1)
+}
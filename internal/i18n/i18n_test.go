@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{"Empty", "", "en"},
+		{"Exact", "pt-BR", "pt-BR"},
+		{"Case", "PT-br", "pt-BR"},
+		{"Base language", "pt", "pt-BR"},
+		{"Other region", "pt-PT,en;q=0.5", "pt-BR"},
+		{"Quality", "en;q=0.4, pt-BR;q=0.8", "pt-BR"},
+		{"Unsupported first", "de-DE, en;q=0.9", "en"},
+		{"Refused", "pt-BR;q=0, fr", "en"},
+		{"Wildcard", "*", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Match(tt.acceptLanguage).Tag, tt.want)
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	pt := Get("pt-BR")
+
+	assert.Equal(t, Default.T("Comment deleted."), "Comment deleted.")
+	assert.Equal(t, pt.T("Comment deleted."), "Comentário excluído.")
+	assert.Equal(t, pt.T("Page %d of %d", 2, 5), "Página 2 de 5")
+
+	// Messages missing from the catalog are left in English
+	assert.Equal(t, pt.T("Not translated"), "Not translated")
+	assert.Equal(t, pt.T("100% sure"), "100% sure")
+
+	// Messages formatted before they get to T are still translated
+	assert.Equal(t, pt.T("This field cannot be more than 100 characters long"), "Este campo não pode ter mais de 100 caracteres")
+	assert.Equal(t, pt.T("This field cannot be more than many characters long"), "This field cannot be more than many characters long")
+}
+
+func TestDate(t *testing.T) {
+	tm := time.Date(2023, 4, 16, 11, 11, 0, 0, time.FixedZone("CET", 1*60*60))
+
+	assert.Equal(t, Default.Date(tm), "16 Apr 2023 at 10:11")
+	assert.Equal(t, Get("pt-BR").Date(tm), "16 abr 2023 às 10:11")
+	assert.Equal(t, Get("pt-BR").Date(time.Time{}), "")
+}
@@ -0,0 +1,140 @@
+// Package mailer sends plain text email, optionally with an HTML version.
+// Handlers depend on the Mailer interface so tests can swap in a fake.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer sends a message to a single recipient. SendHTML sends an HTML body
+// along with the plain text one, for clients that cannot show HTML.
+type Mailer interface {
+	Send(to, subject, body string) error
+	SendHTML(to, subject, body, htmlBody string) error
+}
+
+// SMTP sends mail through an SMTP server. Username and Password are optional;
+// when set, PLAIN authentication is used, which net/smtp only allows over TLS
+// or to localhost.
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message through the server at m.Addr.
+func (m *SMTP) Send(to, subject, body string) error {
+	return m.send(to, subject, message(m.From, to, subject, body))
+}
+
+// SendHTML delivers the message, with both bodies, through the server at
+// m.Addr.
+func (m *SMTP) SendHTML(to, subject, body, htmlBody string) error {
+	msg, err := htmlMessage(m.From, to, subject, body, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	return m.send(to, subject, msg)
+}
+
+func (m *SMTP) send(to, subject string, msg []byte) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("mailer: header contains a line break")
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, msg)
+}
+
+// headers writes the headers every message has.
+func headers(b *bytes.Buffer, from, to, subject string) {
+	fmt.Fprintf(b, "From: %s\r\n", from)
+	fmt.Fprintf(b, "To: %s\r\n", to)
+	fmt.Fprintf(b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+}
+
+// message formats the headers and body of an email.
+func message(from, to, subject, body string) []byte {
+	var b bytes.Buffer
+	headers(&b, from, to, subject)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(crlf(body))
+	return b.Bytes()
+}
+
+// htmlMessage formats the headers and a multipart/alternative body of an
+// email, with the plain text part first as RFC 2046 asks.
+func htmlMessage(from, to, subject, body, htmlBody string) ([]byte, error) {
+	var parts bytes.Buffer
+	w := multipart.NewWriter(&parts)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", body},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write([]byte(crlf(part.content))); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	headers(&b, from, to, subject)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n", w.Boundary())
+	b.WriteString("\r\n")
+	b.Write(parts.Bytes())
+	return b.Bytes(), nil
+}
+
+// crlf converts line endings to the CRLF that mail requires.
+func crlf(s string) string {
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// Log writes messages to a logger instead of sending them, for development
+// without an SMTP server.
+type Log struct {
+	Logger *log.Logger
+}
+
+// Send logs the message.
+func (m *Log) Send(to, subject, body string) error {
+	m.Logger.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// SendHTML logs the plain text version of the message.
+func (m *Log) SendHTML(to, subject, body, htmlBody string) error {
+	return m.Send(to, subject, body)
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestMessage(t *testing.T) {
+	msg := string(message("from@example.com", "to@example.com", "Hello", "Line one\nLine two"))
+
+	assert.StringContains(t, msg, "From: from@example.com\r\n")
+	assert.StringContains(t, msg, "To: to@example.com\r\n")
+	assert.StringContains(t, msg, "Subject: Hello\r\n")
+	assert.Equal(t, strings.HasSuffix(msg, "\r\n\r\nLine one\r\nLine two"), true)
+}
+
+func TestHTMLMessage(t *testing.T) {
+	b, err := htmlMessage("from@example.com", "to@example.com", "Hello", "Plain\ntext", "<p>HTML</p>")
+	assert.NilError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	assert.NilError(t, err)
+	assert.Equal(t, msg.Header.Get("Subject"), "Hello")
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NilError(t, err)
+	assert.Equal(t, mediaType, "multipart/alternative")
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+
+	for _, want := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", "Plain\r\ntext"},
+		{"text/html; charset=UTF-8", "<p>HTML</p>"},
+	} {
+		part, err := r.NextPart()
+		assert.NilError(t, err)
+		assert.Equal(t, part.Header.Get("Content-Type"), want.contentType)
+
+		body, err := io.ReadAll(part)
+		assert.NilError(t, err)
+		assert.Equal(t, string(body), want.body)
+	}
+
+	_, err = r.NextPart()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestSMTPSendRejectsHeaderInjection(t *testing.T) {
+	m := &SMTP{Addr: "localhost:25", From: "from@example.com"}
+
+	err := m.Send("to@example.com\r\nBcc: other@example.com", "Hello", "Body")
+	assert.Equal(t, err != nil, true)
+}
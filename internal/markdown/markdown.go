@@ -0,0 +1,191 @@
+// Package markdown renders a small subset of Markdown to HTML: paragraphs,
+// headings, block quotes, lists, fenced code blocks, horizontal rules, code
+// spans, emphasis and links.
+//
+// The renderer never passes raw HTML through. All text is escaped before it
+// is wrapped in tags, and links are only kept for http, https and mailto URLs
+// and for relative paths, so the output is safe to embed in a page.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRX  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleRX     = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	bulletRX   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedRX  = regexp.MustCompile(`^\s*\d{1,9}[.)]\s+(.*)$`)
+	fenceRX    = regexp.MustCompile("^\\s*(```|~~~)\\s*([A-Za-z0-9_+-]*)\\s*$")
+	linkRX     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRX   = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	emphasisRX = regexp.MustCompile(`\*([^*]+)\*|(^|[^\w])_([^_]+)_($|[^\w])`)
+	schemeRX   = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*):`)
+)
+
+// Render converts Markdown source to HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// renderBlocks writes the HTML for lines, one block element per line of
+// output.
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRX.MatchString(line):
+			m := fenceRX.FindStringSubmatch(line)
+			i++
+			start := i
+			for i < len(lines) && strings.TrimSpace(lines[i]) != m[1] {
+				i++
+			}
+			code := strings.Join(lines[start:i], "\n")
+			if i < len(lines) {
+				i++ // closing fence
+			}
+
+			b.WriteString("<pre><code")
+			if m[2] != "" {
+				b.WriteString(` class="language-` + m[2] + `"`)
+			}
+			b.WriteString(">" + html.EscapeString(code) + "</code></pre>\n")
+
+		case headingRX.MatchString(line):
+			m := headingRX.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case ruleRX.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(strings.TrimSpace(line), ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case bulletRX.MatchString(line):
+			i = renderList(b, lines, i, "ul", bulletRX)
+
+		case orderedRX.MatchString(line):
+			i = renderList(b, lines, i, "ol", orderedRX)
+
+		default:
+			var para []string
+			for i < len(lines) && !startsBlock(lines[i]) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// renderList writes the list starting at lines[i] and returns the index of
+// the first line after it.
+func renderList(b *strings.Builder, lines []string, i int, tag string, rx *regexp.Regexp) int {
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) && rx.MatchString(lines[i]) {
+		item := rx.FindStringSubmatch(lines[i])[1]
+		b.WriteString("<li>" + renderInline(item) + "</li>\n")
+		i++
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// startsBlock reports whether line ends a paragraph.
+func startsBlock(line string) bool {
+	return strings.TrimSpace(line) == "" ||
+		fenceRX.MatchString(line) ||
+		headingRX.MatchString(line) ||
+		ruleRX.MatchString(line) ||
+		strings.HasPrefix(strings.TrimSpace(line), ">") ||
+		bulletRX.MatchString(line) ||
+		orderedRX.MatchString(line)
+}
+
+// renderInline renders code spans, links and emphasis in a run of text.
+func renderInline(text string) string {
+	var b strings.Builder
+
+	// Odd parts are code spans; an unmatched backtick is kept as text.
+	parts := strings.Split(text, "`")
+	if len(parts)%2 == 0 {
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	for i, part := range parts {
+		if i%2 == 1 {
+			b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		b.WriteString(renderLinks(html.EscapeString(part)))
+	}
+
+	return b.String()
+}
+
+// renderLinks turns [text](url) into anchors and applies emphasis to the
+// text around and inside them, but not to the URLs. text must already be
+// escaped.
+func renderLinks(text string) string {
+	var b strings.Builder
+
+	last := 0
+	for _, m := range linkRX.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(renderEmphasis(text[last:m[0]]))
+
+		label, url := text[m[2]:m[3]], text[m[4]:m[5]]
+		if safeURL(html.UnescapeString(url)) {
+			b.WriteString(`<a href="` + url + `" rel="nofollow">` + renderEmphasis(label) + "</a>")
+		} else {
+			b.WriteString(renderEmphasis(label))
+		}
+
+		last = m[1]
+	}
+	b.WriteString(renderEmphasis(text[last:]))
+
+	return b.String()
+}
+
+func renderEmphasis(text string) string {
+	text = strongRX.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return emphasisRX.ReplaceAllString(text, "$2<em>$1$3</em>$4")
+}
+
+// safeURL reports whether url is relative or uses the http, https or mailto
+// scheme.
+func safeURL(url string) bool {
+	m := schemeRX.FindStringSubmatch(url)
+	if m == nil {
+		return true
+	}
+
+	switch strings.ToLower(m[1]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
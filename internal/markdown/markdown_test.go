@@ -0,0 +1,87 @@
+package markdown
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Paragraphs",
+			src:  "One\ntwo\n\nThree",
+			want: "<p>One\ntwo</p>\n<p>Three</p>",
+		},
+		{
+			name: "Heading",
+			src:  "## Title ##",
+			want: "<h2>Title</h2>",
+		},
+		{
+			name: "Emphasis",
+			src:  "**bold**, *em* and _em_ but not snake_case_name",
+			want: "<p><strong>bold</strong>, <em>em</em> and <em>em</em> but not snake_case_name</p>",
+		},
+		{
+			name: "Code span",
+			src:  "Use `a *b* <c>` here",
+			want: "<p>Use <code>a *b* &lt;c&gt;</code> here</p>",
+		},
+		{
+			name: "Fenced code",
+			src:  "```go\nif a < b {\n}\n```",
+			want: "<pre><code class=\"language-go\">if a &lt; b {\n}</code></pre>",
+		},
+		{
+			name: "Lists",
+			src:  "- one\n- two\n\n1. first\n2. second",
+			want: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>",
+		},
+		{
+			name: "Block quote",
+			src:  "> quoted\n> text",
+			want: "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>",
+		},
+		{
+			name: "Rule",
+			src:  "a\n\n---\n\nb",
+			want: "<p>a</p>\n<hr>\n<p>b</p>",
+		},
+		{
+			name: "Link",
+			src:  "[the_docs](https://go.dev/a_b_c?x=1&y=2)",
+			want: `<p><a href="https://go.dev/a_b_c?x=1&amp;y=2" rel="nofollow">the_docs</a></p>`,
+		},
+		{
+			name: "Relative link",
+			src:  "[home](/snippet/view/1)",
+			want: `<p><a href="/snippet/view/1" rel="nofollow">home</a></p>`,
+		},
+		{
+			name: "Unsafe link",
+			src:  "[click](javascript:alert(1))",
+			want: "<p>click)</p>",
+		},
+		{
+			name: "Raw HTML",
+			src:  "<script>alert('x')</script>",
+			want: "<p>&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;</p>",
+		},
+		{
+			name: "Quote in link",
+			src:  `[x](/a"onmouseover="alert(1))`,
+			want: `<p><a href="/a&#34;onmouseover=&#34;alert(1" rel="nofollow">x</a>)</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Render(tt.src), tt.want)
+		})
+	}
+}
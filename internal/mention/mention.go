@@ -0,0 +1,145 @@
+// Package mention finds @name mentions in text and links them in HTML.
+//
+// A name is made of letters, digits, underscores, dots and hyphens, and does
+// not end with a dot or hyphen, so that "@bob." at the end of a sentence
+// mentions bob. An @ straight after a letter or digit, as in an email
+// address, is not a mention.
+package mention
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Max is the most mentions Parse returns for one text, so that a comment
+// cannot notify the whole site.
+const Max = 10
+
+var nameRX = regexp.MustCompile(`@[\p{L}\p{N}_][\p{L}\p{N}_.-]*`)
+
+// Parse returns the names mentioned in src, without the @, in the order they
+// first appear. Names differing only in case count once.
+func Parse(src string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+
+	for _, m := range find(src) {
+		name := src[m[0]+1 : m[1]]
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		names = append(names, name)
+		if len(names) == Max {
+			break
+		}
+	}
+
+	return names
+}
+
+// Link turns the mentions in the text of an HTML fragment into links to the
+// URL href returns for the name. Mentions for which href returns "" are left
+// alone, as is everything in tags, links and code.
+func Link(src string, href func(name string) string) string {
+	var b strings.Builder
+	skip := 0
+
+	for len(src) > 0 {
+		if src[0] == '<' {
+			end := strings.IndexByte(src, '>')
+			if end < 0 {
+				end = len(src) - 1
+			}
+			tag := src[:end+1]
+			b.WriteString(tag)
+			src = src[end+1:]
+
+			switch tagName(tag) {
+			case "a", "code", "pre":
+				skip++
+			case "/a", "/code", "/pre":
+				if skip > 0 {
+					skip--
+				}
+			}
+			continue
+		}
+
+		end := strings.IndexByte(src, '<')
+		if end < 0 {
+			end = len(src)
+		}
+		text := src[:end]
+		src = src[end:]
+
+		if skip > 0 {
+			b.WriteString(text)
+			continue
+		}
+
+		last := 0
+		for _, m := range find(text) {
+			url := href(text[m[0]+1 : m[1]])
+			if url == "" {
+				continue
+			}
+			b.WriteString(text[last:m[0]])
+			b.WriteString(`<a href="` + html.EscapeString(url) + `" class="mention">` + text[m[0]:m[1]] + `</a>`)
+			last = m[1]
+		}
+		b.WriteString(text[last:])
+	}
+
+	return b.String()
+}
+
+// find returns the start and end of each mention in s, including the @.
+func find(s string) [][2]int {
+	var found [][2]int
+
+	for _, m := range nameRX.FindAllStringIndex(s, -1) {
+		if r, _ := utf8.DecodeLastRuneInString(s[:m[0]]); isNameRune(r) {
+			continue
+		}
+
+		end := m[1]
+		for end > m[0]+1 && (s[end-1] == '.' || s[end-1] == '-') {
+			end--
+		}
+		if end == m[0]+1 {
+			continue
+		}
+
+		found = append(found, [2]int{m[0], end})
+	}
+
+	return found
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// tagName returns the lower case name of an HTML tag, with a leading slash
+// for end tags.
+func tagName(tag string) string {
+	name := strings.TrimPrefix(tag, "<")
+	end := strings.HasPrefix(name, "/")
+	name = strings.TrimPrefix(name, "/")
+
+	if i := strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.ToLower(name)
+
+	if end {
+		return "/" + name
+	}
+	return name
+}
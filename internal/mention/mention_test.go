@@ -0,0 +1,118 @@
+package mention
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "None",
+			src:  "No mentions here",
+			want: []string{},
+		},
+		{
+			name: "Several",
+			src:  "@alice and @bob.smith, thanks",
+			want: []string{"alice", "bob.smith"},
+		},
+		{
+			name: "End of sentence",
+			src:  "Ask @carol.",
+			want: []string{"carol"},
+		},
+		{
+			name: "Repeated in another case",
+			src:  "@Dave @dave @DAVE",
+			want: []string{"Dave"},
+		},
+		{
+			name: "Email address",
+			src:  "Mail jay@email.com or @jay",
+			want: []string{"jay"},
+		},
+		{
+			name: "Bare at sign",
+			src:  "Meet @ noon, @.",
+			want: []string{},
+		},
+		{
+			name: "Unicode",
+			src:  "Obrigado @João!",
+			want: []string{"João"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.src)
+			assert.Equal(t, strings.Join(got, ","), strings.Join(tt.want, ","))
+		})
+	}
+}
+
+func TestParseMax(t *testing.T) {
+	var src []string
+	for i := 0; i < Max+5; i++ {
+		src = append(src, fmt.Sprintf("@user%d", i))
+	}
+
+	got := Parse(strings.Join(src, " "))
+	assert.Equal(t, len(got), Max)
+	assert.Equal(t, got[Max-1], fmt.Sprintf("user%d", Max-1))
+}
+
+func TestLink(t *testing.T) {
+	href := func(name string) string {
+		if strings.EqualFold(name, "alice") {
+			return "/user/profile/1"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Known name",
+			src:  "<p>Hi @Alice.</p>",
+			want: `<p>Hi <a href="/user/profile/1" class="mention">@Alice</a>.</p>`,
+		},
+		{
+			name: "Unknown name",
+			src:  "<p>Hi @bob</p>",
+			want: "<p>Hi @bob</p>",
+		},
+		{
+			name: "Inside a link",
+			src:  `<p><a href="https://example.com/@alice">@alice</a></p>`,
+			want: `<p><a href="https://example.com/@alice">@alice</a></p>`,
+		},
+		{
+			name: "Inside code",
+			src:  "<p><code>@alice</code> but @alice</p>",
+			want: `<p><code>@alice</code> but <a href="/user/profile/1" class="mention">@alice</a></p>`,
+		},
+		{
+			name: "Inside emphasis",
+			src:  "<p><em>@alice</em></p>",
+			want: `<p><em><a href="/user/profile/1" class="mention">@alice</a></em></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Link(tt.src, href), tt.want)
+		})
+	}
+}
@@ -0,0 +1,282 @@
+// Package metrics keeps counters, gauges and histograms, each split by a set
+// of labels, and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the histogram buckets
+// used for request and query durations.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics in the order they were added. Metrics are safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric to w in the Prometheus text format, with series
+// sorted by their label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// vec is what every kind of metric shares: a name, help text, label names
+// and one series of values per combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+	newT   func() *T
+}
+
+func newVec[T any](name, help, kind string, labels []string, newT func() *T) *vec[T] {
+	return &vec[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*T),
+		values: make(map[string][]string),
+		newT:   newT,
+	}
+}
+
+// with calls f with the series for the label values, which are given in the
+// order the labels were, creating it if needed. It panics if the number of
+// values is wrong, as that is a programming error.
+func (v *vec[T]) with(values []string, f func(*T)) {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.series[key]
+	if !ok {
+		s = v.newT()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+
+	f(s)
+}
+
+// each calls f for every series, sorted by label values, while holding the
+// lock.
+func (v *vec[T]) each(w *bufio.Writer, f func(labels string, s *T)) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f(formatLabels(v.labels, v.values[key]), v.series[key])
+	}
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct {
+	*vec[float64]
+}
+
+// NewCounter adds a counter with the given label names to the registry.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	r.add(c)
+	return c
+}
+
+// Inc adds one to the series for the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds n, which must not be negative, to the series for the label values.
+func (c *Counter) Add(n float64, values ...string) {
+	c.with(values, func(v *float64) { *v += n })
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.each(w, func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatFloat(*v))
+	})
+}
+
+// Gauge is a value that can go up and down, such as a number of sessions.
+type Gauge struct {
+	*vec[float64]
+}
+
+// NewGauge adds a gauge with the given label names to the registry.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels, func() *float64 { return new(float64) })}
+	r.add(g)
+	return g
+}
+
+// Set sets the series for the label values to n.
+func (g *Gauge) Set(n float64, values ...string) {
+	g.with(values, func(v *float64) { *v = n })
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.each(w, func(labels string, v *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatFloat(*v))
+	})
+}
+
+// Histogram counts observations, such as durations, into buckets.
+type Histogram struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram adds a histogram with the given bucket upper bounds, in
+// increasing order, and label names to the registry.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{buckets: buckets}
+	h.vec = newVec(name, help, "histogram", labels, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	r.add(h)
+	return h
+}
+
+// Observe records v in the series for the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.with(values, func(s *histogram) {
+		for i, upper := range h.buckets {
+			if v <= upper {
+				s.counts[i]++
+			}
+		}
+		s.count++
+		s.sum += v
+	})
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.each(w, func(labels string, s *histogram) {
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(labels, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, s.count)
+	})
+}
+
+// formatLabels returns the labels as {name="value",...}, or nothing if there
+// are none.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// withLabel adds one more label to labels formatted by formatLabels.
+func withLabel(labels, name, value string) string {
+	label := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + label + "}"
+	}
+
+	return strings.TrimSuffix(labels, "}") + "," + label + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("requests_total", "Requests served.", "route", "status")
+	sessions := r.NewGauge("sessions", "Active sessions.")
+	durations := r.NewHistogram("duration_seconds", "Time taken.", []float64{0.1, 1}, "route")
+
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "200")
+	requests.Add(2, "/a", "200")
+	requests.Inc(`/"quoted"`, "404")
+	sessions.Set(7)
+	durations.Observe(0.05, "/a")
+	durations.Observe(0.5, "/a")
+	durations.Observe(3, "/a")
+
+	var buf bytes.Buffer
+	assert.NilError(t, r.Write(&buf))
+
+	want := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"",status="404"} 1
+requests_total{route="/a",status="200"} 3
+requests_total{route="/b",status="200"} 1
+# HELP sessions Active sessions.
+# TYPE sessions gauge
+sessions 7
+# HELP duration_seconds Time taken.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/a",le="0.1"} 1
+duration_seconds_bucket{route="/a",le="1"} 2
+duration_seconds_bucket{route="/a",le="+Inf"} 3
+duration_seconds_sum{route="/a"} 3.55
+duration_seconds_count{route="/a"} 3
+`
+	assert.Equal(t, buf.String(), want)
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT id FROM snippets", "select"},
+		{"\n\tINSERT INTO snippets", "insert"},
+		{"(SELECT 1) UNION (SELECT 2)", "select"},
+		{"update\nsnippets SET", "update"},
+		{"DELETE cv FROM comment_votes cv", "delete"},
+		{"SET NAMES utf8mb4", "other"},
+		{"", "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, Operation(tt.query), tt.want)
+		})
+	}
+}
+
+func TestInstrumentConnector(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("query_seconds", "Query time.", DefaultBuckets, "operation")
+
+	db := sql.OpenDB(InstrumentConnector(fakeConnector{}, h))
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO t VALUES (?)", 1)
+	assert.NilError(t, err)
+
+	_, err = db.Exec("UPDATE t SET a = ?", 2)
+	assert.NilError(t, err)
+
+	var buf bytes.Buffer
+	assert.NilError(t, r.Write(&buf))
+
+	assert.StringContains(t, buf.String(), `query_seconds_count{operation="insert"} 1`)
+	assert.StringContains(t, buf.String(), `query_seconds_count{operation="update"} 1`)
+}
+
+// fakeConnector makes connections that accept any statement and do nothing.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, io.EOF }
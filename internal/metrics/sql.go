@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// InstrumentConnector wraps c so that every statement run on its connections
+// is timed into h, which must take a single label: the statement's operation,
+// such as "select" or "insert". Queries are timed until the driver returns
+// their rows, not until the rows have been read.
+func InstrumentConnector(c driver.Connector, h *Histogram) driver.Connector {
+	return &connector{Connector: c, h: h}
+}
+
+// Operation returns the lower-cased first word of a statement, or "other" if
+// it is not one of the usual ones, to keep the number of series down.
+func Operation(query string) string {
+	separator := func(r rune) bool { return unicode.IsSpace(r) || r == '(' }
+
+	query = strings.TrimLeftFunc(query, separator)
+	if i := strings.IndexFunc(query, separator); i >= 0 {
+		query = query[:i]
+	}
+
+	switch op := strings.ToLower(query); op {
+	case "select", "insert", "update", "delete", "replace":
+		return op
+	default:
+		return "other"
+	}
+}
+
+type connector struct {
+	driver.Connector
+	h *Histogram
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedConn{Conn: conn, h: c.h}, nil
+}
+
+// timedConn times the statements run on a connection, and passes everything
+// else through. Interfaces the wrapped connection does not implement are
+// reported to database/sql with driver.ErrSkip, or their default behaviour.
+type timedConn struct {
+	driver.Conn
+	h *Histogram
+}
+
+func observe(h *Histogram, query string, start time.Time) {
+	h.Observe(time.Since(start).Seconds(), Operation(query))
+}
+
+func (c *timedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedStmt{Stmt: stmt, query: query, h: c.h}, nil
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	defer observe(c.h, query, time.Now())
+
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	defer observe(c.h, query, time.Now())
+
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *timedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *timedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+// timedStmt times the runs of a prepared statement.
+type timedStmt struct {
+	driver.Stmt
+	query string
+	h     *Histogram
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer observe(s.h, s.query, time.Now())
+
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stmt.Exec(values)
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer observe(s.h, s.query, time.Now())
+
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Stmt.Query(values)
+}
+
+func (s *timedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// namedValues turns args back into plain values for drivers that predate
+// named ones, which cannot take names.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("metrics: driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,7 @@
+--
+-- Record when each comment vote was cast
+--
+
+ALTER TABLE `comment_votes`
+  ADD COLUMN `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD KEY `idx_comment_votes_created` (`comment_id`,`created`);
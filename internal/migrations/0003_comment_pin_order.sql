@@ -0,0 +1,5 @@
+--
+-- Keep pinned comments in the order moderators chose
+--
+
+ALTER TABLE `comments` ADD COLUMN `pin_order` int DEFAULT NULL;
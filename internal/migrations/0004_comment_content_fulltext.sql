@@ -0,0 +1,5 @@
+--
+-- Index comment content for similarity and full-text search
+--
+
+ALTER TABLE `comments` ADD FULLTEXT KEY `idx_comments_content` (`content`);
@@ -0,0 +1,5 @@
+--
+-- Add the moderation status of comments
+--
+
+ALTER TABLE `comments` ADD COLUMN `status` enum('approved','pending','rejected') COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'approved';
@@ -0,0 +1,13 @@
+--
+-- Link comments and snippets to the users who wrote them
+--
+
+ALTER TABLE `comments`
+  ADD COLUMN `author_id` int DEFAULT NULL,
+  ADD KEY `author_id` (`author_id`),
+  ADD CONSTRAINT `comments_ibfk_2` FOREIGN KEY (`author_id`) REFERENCES `users` (`id`) ON DELETE SET NULL;
+
+ALTER TABLE `snippets`
+  ADD COLUMN `user_id` int DEFAULT NULL,
+  ADD KEY `user_id` (`user_id`),
+  ADD CONSTRAINT `snippets_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE SET NULL;
@@ -0,0 +1,8 @@
+--
+-- Let comments reply to other comments
+--
+
+ALTER TABLE `comments`
+  ADD COLUMN `parent_id` int DEFAULT NULL,
+  ADD KEY `parent_id` (`parent_id`),
+  ADD CONSTRAINT `comments_ibfk_3` FOREIGN KEY (`parent_id`) REFERENCES `comments` (`id`) ON DELETE CASCADE;
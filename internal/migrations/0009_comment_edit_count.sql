@@ -0,0 +1,5 @@
+--
+-- Count how many times each comment was edited
+--
+
+ALTER TABLE `comments` ADD COLUMN `edit_count` int NOT NULL DEFAULT '0';
@@ -0,0 +1,5 @@
+--
+-- Let moderators lock comments against edits
+--
+
+ALTER TABLE `comments` ADD COLUMN `locked` tinyint(1) NOT NULL DEFAULT '0';
@@ -0,0 +1,5 @@
+--
+-- Index comment votes by time for spike detection
+--
+
+ALTER TABLE `comment_votes` ADD KEY `idx_comment_votes_window` (`created`);
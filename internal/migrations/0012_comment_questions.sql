@@ -0,0 +1,7 @@
+--
+-- Mark comments as questions and their answers as accepted
+--
+
+ALTER TABLE `comments`
+  ADD COLUMN `is_question` tinyint(1) NOT NULL DEFAULT '0',
+  ADD COLUMN `accepted` tinyint(1) NOT NULL DEFAULT '0';
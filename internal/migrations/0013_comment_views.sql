@@ -0,0 +1,5 @@
+--
+-- Count the views of each comment
+--
+
+ALTER TABLE `comments` ADD COLUMN `views` int NOT NULL DEFAULT '0';
@@ -0,0 +1,5 @@
+--
+-- Add user roles
+--
+
+ALTER TABLE `users` ADD COLUMN `role` enum('user','moderator','admin') COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'user';
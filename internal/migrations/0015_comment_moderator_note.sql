@@ -0,0 +1,5 @@
+--
+-- Let moderators attach a note to a comment
+--
+
+ALTER TABLE `comments` ADD COLUMN `moderator_note` varchar(500) COLLATE utf8mb4_unicode_ci DEFAULT NULL;
@@ -0,0 +1,5 @@
+--
+-- Record why the status of a comment was changed
+--
+
+ALTER TABLE `comments` ADD COLUMN `status_note` varchar(255) COLLATE utf8mb4_unicode_ci DEFAULT NULL;
@@ -0,0 +1,30 @@
+--
+-- Table structure for table `comment_changes`
+--
+
+CREATE TABLE IF NOT EXISTS `comment_changes` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `comment_id` int NOT NULL,
+  `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `comment_id` (`comment_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Triggers for table `comments`
+--
+-- Every insert, content/status edit and delete is appended to
+-- comment_changes so the search indexer can resume from a watermark.
+-- Rows removed by the snippets foreign key cascade do not fire triggers.
+--
+
+CREATE TRIGGER `comments_after_insert` AFTER INSERT ON `comments` FOR EACH ROW
+  INSERT INTO `comment_changes` (`comment_id`) VALUES (NEW.`id`);
+CREATE TRIGGER `comments_after_update` AFTER UPDATE ON `comments` FOR EACH ROW
+BEGIN
+  IF NOT (NEW.`content` <=> OLD.`content` AND NEW.`author` <=> OLD.`author` AND NEW.`status` <=> OLD.`status`) THEN
+    INSERT INTO `comment_changes` (`comment_id`) VALUES (NEW.`id`);
+  END IF;
+END;
+CREATE TRIGGER `comments_after_delete` AFTER DELETE ON `comments` FOR EACH ROW
+  INSERT INTO `comment_changes` (`comment_id`) VALUES (OLD.`id`);
@@ -0,0 +1,5 @@
+--
+-- Let moderators shadow-ban users
+--
+
+ALTER TABLE `users` ADD COLUMN `shadowbanned` tinyint(1) NOT NULL DEFAULT '0';
@@ -0,0 +1,20 @@
+--
+-- Soft-delete comments
+--
+
+ALTER TABLE `comments`
+  ADD COLUMN `deleted` tinyint(1) NOT NULL DEFAULT '0',
+  ADD COLUMN `deleted_at` datetime DEFAULT NULL;
+
+--
+-- Soft deletes and restores are changes for the search indexer too
+--
+
+DROP TRIGGER IF EXISTS `comments_after_update`;
+CREATE TRIGGER `comments_after_update` AFTER UPDATE ON `comments` FOR EACH ROW
+BEGIN
+  IF NOT (NEW.`content` <=> OLD.`content` AND NEW.`author` <=> OLD.`author` AND NEW.`status` <=> OLD.`status`
+          AND NEW.`deleted` <=> OLD.`deleted`) THEN
+    INSERT INTO `comment_changes` (`comment_id`) VALUES (NEW.`id`);
+  END IF;
+END;
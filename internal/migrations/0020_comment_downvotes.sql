@@ -0,0 +1,5 @@
+--
+-- Count downvotes apart from upvotes
+--
+
+ALTER TABLE `comments` ADD COLUMN `downvotes` int NOT NULL DEFAULT '0';
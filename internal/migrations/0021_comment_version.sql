@@ -0,0 +1,5 @@
+--
+-- Add a version to comments for optimistic locking of edits
+--
+
+ALTER TABLE `comments` ADD COLUMN `version` int NOT NULL DEFAULT '0';
@@ -0,0 +1,5 @@
+--
+-- Keep downvotes that the score floor left out of the count
+--
+
+ALTER TABLE `comment_votes` ADD COLUMN `counted` tinyint(1) NOT NULL DEFAULT '1';
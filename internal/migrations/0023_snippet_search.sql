@@ -0,0 +1,5 @@
+--
+-- Index snippets for full-text search
+--
+
+ALTER TABLE `snippets` ADD FULLTEXT KEY `idx_snippets_search` (`title`,`content`);
@@ -0,0 +1,23 @@
+--
+-- Table structure for table `tags`
+--
+
+CREATE TABLE IF NOT EXISTS `tags` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `name` varchar(30) COLLATE utf8mb4_unicode_ci NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tags_uc_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Table structure for table `snippet_tags`
+--
+
+CREATE TABLE IF NOT EXISTS `snippet_tags` (
+  `snippet_id` int NOT NULL,
+  `tag_id` int NOT NULL,
+  PRIMARY KEY (`snippet_id`,`tag_id`),
+  KEY `tag_id` (`tag_id`),
+  CONSTRAINT `snippet_tags_ibfk_1` FOREIGN KEY (`snippet_id`) REFERENCES `snippets` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `snippet_tags_ibfk_2` FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
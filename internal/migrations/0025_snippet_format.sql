@@ -0,0 +1,5 @@
+--
+-- Let snippets be written in Markdown
+--
+
+ALTER TABLE `snippets` ADD COLUMN `format` enum('plain','markdown') COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'plain';
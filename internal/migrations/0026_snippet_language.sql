@@ -0,0 +1,7 @@
+--
+-- Add the language snippets are highlighted in
+--
+
+ALTER TABLE `snippets`
+  ADD COLUMN `language` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  ADD KEY `idx_snippets_language` (`language`);
@@ -0,0 +1,14 @@
+--
+-- Table structure for table `snippet_revisions`
+--
+
+CREATE TABLE IF NOT EXISTS `snippet_revisions` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `snippet_id` int NOT NULL,
+  `title` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `content` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_snippet_revisions_snippet` (`snippet_id`),
+  CONSTRAINT `snippet_revisions_ibfk_1` FOREIGN KEY (`snippet_id`) REFERENCES `snippets` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
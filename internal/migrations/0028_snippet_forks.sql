@@ -0,0 +1,8 @@
+--
+-- Link forks to the snippets they were forked from
+--
+
+ALTER TABLE `snippets`
+  ADD COLUMN `forked_from` int DEFAULT NULL,
+  ADD KEY `forked_from` (`forked_from`),
+  ADD CONSTRAINT `snippets_ibfk_2` FOREIGN KEY (`forked_from`) REFERENCES `snippets` (`id`) ON DELETE SET NULL;
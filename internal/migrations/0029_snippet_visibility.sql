@@ -0,0 +1,5 @@
+--
+-- Add the visibility of snippets
+--
+
+ALTER TABLE `snippets` ADD COLUMN `visibility` enum('public','unlisted','private') COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'public';
@@ -0,0 +1,15 @@
+--
+-- Table structure for table `share_links`
+--
+
+CREATE TABLE IF NOT EXISTS `share_links` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `snippet_id` int NOT NULL,
+  `nonce` char(22) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  `expires` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `share_links_uc_nonce` (`nonce`),
+  KEY `idx_share_links_snippet` (`snippet_id`),
+  CONSTRAINT `share_links_ibfk_1` FOREIGN KEY (`snippet_id`) REFERENCES `snippets` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
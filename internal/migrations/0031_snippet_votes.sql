@@ -0,0 +1,24 @@
+--
+-- Table structure for table `snippet_votes`
+--
+
+CREATE TABLE IF NOT EXISTS `snippet_votes` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `snippet_id` int NOT NULL,
+  `user_id` int NOT NULL,
+  `vote_type` enum('upvote','downvote') COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `snippet_id` (`snippet_id`,`user_id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `snippet_votes_ibfk_1` FOREIGN KEY (`snippet_id`) REFERENCES `snippets` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `snippet_votes_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Count the votes of each snippet
+--
+
+ALTER TABLE `snippets`
+  ADD COLUMN `upvotes` int NOT NULL DEFAULT '0',
+  ADD COLUMN `downvotes` int NOT NULL DEFAULT '0';
@@ -0,0 +1,21 @@
+--
+-- Table structure for table `email_verifications`
+--
+
+CREATE TABLE IF NOT EXISTS `email_verifications` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `token_hash` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  `expires` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `email_verifications_uc_token_hash` (`token_hash`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `email_verifications_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Mark the users whose email address is verified
+--
+
+ALTER TABLE `users` ADD COLUMN `verified` tinyint(1) NOT NULL DEFAULT '0';
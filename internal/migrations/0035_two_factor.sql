@@ -0,0 +1,22 @@
+--
+-- Table structure for table `backup_codes`
+--
+
+CREATE TABLE IF NOT EXISTS `backup_codes` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `code_hash` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `backup_codes_uc_user_code` (`user_id`,`code_hash`),
+  CONSTRAINT `backup_codes_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Add the TOTP settings of users
+--
+
+ALTER TABLE `users`
+  ADD COLUMN `totp_secret` varchar(32) COLLATE utf8mb4_unicode_ci DEFAULT NULL,
+  ADD COLUMN `totp_enabled` tinyint(1) NOT NULL DEFAULT '0',
+  ADD COLUMN `totp_last_step` bigint NOT NULL DEFAULT '0';
@@ -0,0 +1,5 @@
+--
+-- Let admins ban users
+--
+
+ALTER TABLE `users` ADD COLUMN `banned` tinyint(1) NOT NULL DEFAULT '0';
@@ -0,0 +1,10 @@
+--
+-- Soft-delete snippets, and record who deleted snippets and comments
+--
+
+ALTER TABLE `snippets`
+  ADD COLUMN `deleted_at` datetime DEFAULT NULL,
+  ADD COLUMN `deleted_by` int DEFAULT NULL,
+  ADD KEY `idx_snippets_deleted_at` (`deleted_at`);
+
+ALTER TABLE `comments` ADD COLUMN `deleted_by` int DEFAULT NULL;
@@ -0,0 +1,25 @@
+--
+-- Table structure for table `login_attempts`
+--
+
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `user_id` int DEFAULT NULL,
+  `email` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `ip` varchar(45) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_login_attempts_email` (`email`,`created`),
+  KEY `idx_login_attempts_ip` (`ip`,`created`),
+  KEY `idx_login_attempts_created` (`created`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `login_attempts_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Lock accounts after repeated failed logins
+--
+
+ALTER TABLE `users`
+  ADD COLUMN `locked_until` datetime DEFAULT NULL,
+  ADD COLUMN `unlock_token_hash` char(64) COLLATE utf8mb4_unicode_ci DEFAULT NULL;
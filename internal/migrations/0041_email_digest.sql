@@ -0,0 +1,7 @@
+--
+-- Let users opt in to the weekly digest
+--
+
+ALTER TABLE `users`
+  ADD COLUMN `digest` tinyint(1) NOT NULL DEFAULT '0',
+  ADD COLUMN `digest_sent` datetime DEFAULT NULL;
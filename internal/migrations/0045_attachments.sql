@@ -0,0 +1,23 @@
+--
+-- Table structure for table `attachments`
+--
+
+CREATE TABLE IF NOT EXISTS `attachments` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `comment_id` int DEFAULT NULL,
+  `user_id` int DEFAULT NULL,
+  `name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `content_type` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `size` bigint NOT NULL,
+  `width` int NOT NULL,
+  `height` int NOT NULL,
+  `storage_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `thumbnail_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `thumbnail_type` varchar(32) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `comment_id` (`comment_id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `attachments_ibfk_1` FOREIGN KEY (`comment_id`) REFERENCES `comments` (`id`) ON DELETE SET NULL,
+  CONSTRAINT `attachments_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,37 @@
+--
+-- Table structure for table `webhooks`
+--
+
+CREATE TABLE IF NOT EXISTS `webhooks` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `user_id` int NOT NULL,
+  `url` varchar(2048) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `secret` char(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `events` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `webhooks_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+--
+-- Table structure for table `webhook_deliveries`
+--
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `webhook_id` int NOT NULL,
+  `event` varchar(50) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `payload` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL,
+  `status` enum('pending','delivered','failed') COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'pending',
+  `attempts` int NOT NULL DEFAULT '0',
+  `response_code` int NOT NULL DEFAULT '0',
+  `error` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `created` datetime NOT NULL,
+  `next_attempt` datetime NOT NULL,
+  `finished` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_webhook_deliveries_due` (`status`,`next_attempt`),
+  KEY `idx_webhook_deliveries_webhook` (`webhook_id`,`id`),
+  CONSTRAINT `webhook_deliveries_ibfk_1` FOREIGN KEY (`webhook_id`) REFERENCES `webhooks` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+--
+-- Table structure for table `comment_reactions`
+--
+
+CREATE TABLE IF NOT EXISTS `comment_reactions` (
+  `comment_id` int NOT NULL,
+  `user_id` int NOT NULL,
+  `reaction` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL,
+  PRIMARY KEY (`comment_id`,`user_id`,`reaction`),
+  KEY `user_id` (`user_id`),
+  CONSTRAINT `comment_reactions_ibfk_1` FOREIGN KEY (`comment_id`) REFERENCES `comments` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `comment_reactions_ibfk_2` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
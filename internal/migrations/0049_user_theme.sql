@@ -0,0 +1,5 @@
+--
+-- Add the theme each user sees the site in
+--
+
+ALTER TABLE `users` ADD COLUMN `theme` varchar(8) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'light';
@@ -0,0 +1,209 @@
+// Package migrations keeps the database schema as numbered SQL files embedded
+// in the binary, and applies to a database those it has not had yet. Each
+// file is named NNNN_description.sql and run as a whole, so it may hold any
+// number of statements, triggers included, without DELIMITER lines.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var (
+	// ErrLocked is returned by Up when another process kept the migrations
+	// lock for longer than lockTimeout.
+	ErrLocked = errors.New("migrations: timed out waiting for the migrations lock")
+	// ErrPending is returned by Check when migrations are left to apply.
+	ErrPending = errors.New("migrations: the database schema is not up to date")
+)
+
+// lockName is the MySQL advisory lock held while migrations are applied, so
+// that servers starting together do not run them twice.
+const (
+	lockName    = "snippetbox_migrations"
+	lockTimeout = 60 // seconds
+)
+
+// errNoSuchTable is the MySQL error for a missing table.
+const errNoSuchTable = 1146
+
+// Migration is one SQL file.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+var fileRX = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.sql$`)
+
+// All returns every migration, in the order they are applied.
+func All() ([]Migration, error) {
+	return load(files)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+
+	for _, e := range entries {
+		m := fileRX.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migrations: %s is not named NNNN_description.sql", e.Name())
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations: %s and %s have the same version", other, e.Name())
+		}
+		seen[version] = e.Name()
+
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: m[2], SQL: string(b)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// querier is a *sql.DB or a *sql.Conn.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Pending returns the migrations that have not been applied to db, in the
+// order they are to be.
+func Pending(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	return pending(ctx, db)
+}
+
+func pending(ctx context.Context, q querier) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool)
+
+	rows, err := q.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == errNoSuchTable {
+			return all, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, m := range all {
+		if !applied[m.Version] {
+			migrations = append(migrations, m)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Check returns ErrPending if any migration has not been applied to db.
+func Check(ctx context.Context, db *sql.DB) error {
+	migrations, err := Pending(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) > 0 {
+		return fmt.Errorf("%w: %d migration(s) pending", ErrPending, len(migrations))
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations in order, recording each in the
+// schema_migrations table, and returns those it applied. db must let a
+// statement hold several, with multiStatements=true in its DSN.
+//
+// MySQL commits schema changes as they are made, so a migration that fails
+// halfway is not rolled back; what it did must be undone by hand before it is
+// run again.
+func Up(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// The lock belongs to the connection, so everything runs on conn
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, lockTimeout).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		return nil, ErrLocked
+	}
+	defer conn.ExecContext(context.Background(), `DO RELEASE_LOCK(?)`, lockName)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := pending(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range migrations {
+		if _, err := conn.ExecContext(ctx, m.SQL); err != nil {
+			return migrations[:i], fmt.Errorf("migrations: %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied)
+		VALUES(?, ?, UTC_TIMESTAMP())`, m.Version, m.Name)
+		if err != nil {
+			return migrations[:i], err
+		}
+	}
+
+	return migrations, nil
+}
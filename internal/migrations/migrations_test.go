@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	assert.NilError(t, err)
+
+	assert.Equal(t, migrations[0].Version, 1)
+	assert.Equal(t, migrations[0].Name, "initial")
+
+	for i, m := range migrations {
+		// Versions leave no gaps, so a missing file is noticed
+		assert.Equal(t, m.Version, i+1)
+
+		// DELIMITER is a client command the server does not understand
+		assert.Equal(t, strings.Contains(m.SQL, "DELIMITER"), false)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int
+		wantErr string
+	}{
+		{
+			name: "Sorted by version",
+			files: fstest.MapFS{
+				"0010_tags.sql":    {Data: []byte("SELECT 10")},
+				"0002_votes.sql":   {Data: []byte("SELECT 2")},
+				"0001_initial.sql": {Data: []byte("SELECT 1")},
+			},
+			want: []int{1, 2, 10},
+		},
+		{
+			name: "Bad name",
+			files: fstest.MapFS{
+				"1_initial.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: "1_initial.sql is not named NNNN_description.sql",
+		},
+		{
+			name: "Same version",
+			files: fstest.MapFS{
+				"0001_initial.sql": {Data: []byte("SELECT 1")},
+				"0001_other.sql":   {Data: []byte("SELECT 1")},
+			},
+			wantErr: "0001_initial.sql and 0001_other.sql have the same version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.files)
+			if tt.wantErr != "" {
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(migrations), len(tt.want))
+			for i, m := range migrations {
+				assert.Equal(t, m.Version, tt.want[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+type APITokenModelInterface interface {
+	Insert(userID int, name string, scopes []string) (string, error)
+	Authenticate(token string) (*APIToken, error)
+	ForUser(userID int) ([]*APIToken, error)
+	Delete(userID, id int) error
+}
+
+// API token scopes. Read covers the GET requests of the JSON API and write
+// the requests that change something.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Scopes are every scope a token can be given.
+var Scopes = []string{ScopeRead, ScopeWrite}
+
+// APITokenPrefix starts every API token, so they are easy to recognise in
+// configs and logs, and by secret scanners.
+const APITokenPrefix = "sbx_"
+
+// APIToken is a personal access token a user made for programs to use the
+// JSON API as them. The token itself is only known when it is made.
+type APIToken struct {
+	ID      int
+	UserID  int
+	Name    string
+	Scopes  []string
+	Created time.Time
+	// LastUsed is zero for a token that was never used.
+	LastUsed time.Time
+}
+
+// HasScope reports whether the token was given scope.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenModel wraps a sql.DB conn pool. Like password reset tokens, only a
+// SHA-256 hash of each API token is stored.
+type APITokenModel struct {
+	DB *sql.DB
+}
+
+// Insert makes a token for the user with the given name and scopes, and
+// returns it. It returns ErrInvalidScope if scopes is empty or holds a scope
+// not in Scopes.
+func (m *APITokenModel) Insert(userID int, name string, scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", ErrInvalidScope
+	}
+	for _, s := range scopes {
+		if s != ScopeRead && s != ScopeWrite {
+			return "", ErrInvalidScope
+		}
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(token), strings.Join(scopes, " "))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate returns the token, with its user and scopes, and records that
+// it was used. It returns ErrInvalidAPIToken if the token is unknown or was
+// revoked, and ErrBanned if its user is banned.
+func (m *APITokenModel) Authenticate(token string) (*APIToken, error) {
+	t := &APIToken{}
+	var scopes string
+	var lastUsed sql.NullTime
+	var banned bool
+
+	stmt := `SELECT t.id, t.user_id, t.name, t.scopes, t.created, t.last_used, u.banned FROM api_tokens t
+	INNER JOIN users u ON u.id = t.user_id
+	WHERE t.token_hash = ?`
+
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &lastUsed, &banned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+
+	if banned {
+		return nil, ErrBanned
+	}
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Scopes = strings.Fields(scopes)
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
+
+// ForUser returns the user's tokens, the most recent first.
+func (m *APITokenModel) ForUser(userID int) ([]*APIToken, error) {
+	stmt := `SELECT id, user_id, name, scopes, created, last_used FROM api_tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+
+	for rows.Next() {
+		t := &APIToken{}
+		var scopes string
+		var lastUsed sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Scopes = strings.Fields(scopes)
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes one of the user's tokens. It returns ErrNoRecord if the user
+// has no token with that ID.
+func (m *APITokenModel) Delete(userID, id int) error {
+	result, err := m.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAPITokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	users := &UserModel{db}
+	m := &APITokenModel{DB: db}
+
+	_, err := m.Insert(1, "CI", nil)
+	assert.Equal(t, err, ErrInvalidScope)
+	_, err = m.Insert(1, "CI", []string{ScopeRead, "admin"})
+	assert.Equal(t, err, ErrInvalidScope)
+
+	token, err := m.Insert(1, "CI", []string{ScopeRead})
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(token, APITokenPrefix), true)
+
+	// Only the hash is stored
+	var stored int
+	err = db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE token_hash = ?`, token).Scan(&stored)
+	assert.NilError(t, err)
+	assert.Equal(t, stored, 0)
+
+	tokens, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), true)
+
+	got, err := m.Authenticate(token)
+	assert.NilError(t, err)
+	assert.Equal(t, got.UserID, 1)
+	assert.Equal(t, got.HasScope(ScopeRead), true)
+	assert.Equal(t, got.HasScope(ScopeWrite), false)
+
+	tokens, err = m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), false)
+
+	_, err = m.Authenticate(token + "x")
+	assert.Equal(t, err, ErrInvalidAPIToken)
+
+	// Tokens of banned users do not work
+	assert.NilError(t, users.SetBanned(1, true))
+	_, err = m.Authenticate(token)
+	assert.Equal(t, err, ErrBanned)
+	assert.NilError(t, users.SetBanned(1, false))
+
+	// Only the token's user can revoke it
+	assert.Equal(t, m.Delete(2, tokens[0].ID), ErrNoRecord)
+	assert.NilError(t, m.Delete(1, tokens[0].ID))
+
+	_, err = m.Authenticate(token)
+	assert.Equal(t, err, ErrInvalidAPIToken)
+}
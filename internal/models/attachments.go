@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxCommentAttachments is the most images one comment can have.
+const MaxCommentAttachments = 4
+
+type AttachmentModelInterface interface {
+	Insert(a *Attachment) (int, error)
+	Get(id int) (*Attachment, error)
+	Count(commentID int) (int, error)
+	ForComments(commentIDs []int) (map[int][]*Attachment, error)
+	Orphans(limit int) ([]*Attachment, error)
+	Delete(id int) error
+}
+
+// Attachment is an image attached to a comment. The image and its thumbnail
+// are kept in a storage.Store under Key and ThumbnailKey; the table only
+// knows about them. SnippetID is the snippet of the comment, for checking
+// who may see the image.
+type Attachment struct {
+	ID            int
+	CommentID     int
+	SnippetID     int
+	UserID        int
+	Name          string
+	ContentType   string
+	Size          int64
+	Width         int
+	Height        int
+	Key           string
+	ThumbnailKey  string
+	ThumbnailType string
+	Created       time.Time
+}
+
+// AttachmentModel wraps a sql.DB conn pool. Deleted comments keep their
+// attachments, hidden, until they are purged. Purged comments leave them
+// orphaned, with no comment, as their files have to be removed from the store
+// before they can go.
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+const attachmentColumns = `a.id, a.comment_id, c.snippet_id, a.user_id, a.name, a.content_type, a.size,
+	a.width, a.height, a.storage_key, a.thumbnail_key, a.thumbnail_type, a.created`
+
+// Insert records an attachment whose files have been stored, and returns its
+// ID.
+func (m *AttachmentModel) Insert(a *Attachment) (int, error) {
+	stmt := `INSERT INTO attachments (comment_id, user_id, name, content_type, size, width, height,
+	storage_key, thumbnail_key, thumbnail_type, created)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, a.CommentID, nullInt(a.UserID), truncate(a.Name, 255), a.ContentType, a.Size, a.Width, a.Height,
+		a.Key, a.ThumbnailKey, a.ThumbnailType)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get returns an attachment of a comment that has been neither deleted nor
+// rejected by the moderators, or ErrNoRecord.
+func (m *AttachmentModel) Get(id int) (*Attachment, error) {
+	stmt := `SELECT ` + attachmentColumns + `
+	FROM attachments a
+	INNER JOIN comments c ON c.id = a.comment_id
+	WHERE a.id = ? AND c.deleted = FALSE AND c.status <> 'rejected'`
+
+	a, err := scanAttachment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Count returns how many attachments a comment has.
+func (m *AttachmentModel) Count(commentID int) (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM attachments WHERE comment_id = ?`, commentID).Scan(&count)
+
+	return count, err
+}
+
+// ForComments returns the attachments of the comments, by comment ID, in the
+// order they were attached.
+func (m *AttachmentModel) ForComments(commentIDs []int) (map[int][]*Attachment, error) {
+	attachments := make(map[int][]*Attachment)
+
+	if len(commentIDs) == 0 {
+		return attachments, nil
+	}
+
+	args := make([]any, len(commentIDs))
+	for i, id := range commentIDs {
+		args[i] = id
+	}
+
+	stmt := `SELECT ` + attachmentColumns + `
+	FROM attachments a
+	INNER JOIN comments c ON c.id = a.comment_id
+	WHERE a.comment_id IN (?` + strings.Repeat(", ?", len(commentIDs)-1) + `)
+	ORDER BY a.id`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments[a.CommentID] = append(attachments[a.CommentID], a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// scanAttachment reads a row of attachmentColumns. UserID is 0 once the user
+// who attached the image has been deleted.
+func scanAttachment(row interface{ Scan(...any) error }) (*Attachment, error) {
+	a := &Attachment{}
+	var userID sql.NullInt64
+
+	err := row.Scan(&a.ID, &a.CommentID, &a.SnippetID, &userID, &a.Name, &a.ContentType, &a.Size,
+		&a.Width, &a.Height, &a.Key, &a.ThumbnailKey, &a.ThumbnailType, &a.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	a.UserID = int(userID.Int64)
+
+	return a, nil
+}
+
+// Orphans returns up to limit attachments whose comment has been purged. Only
+// their ID and keys are set.
+func (m *AttachmentModel) Orphans(limit int) ([]*Attachment, error) {
+	stmt := `SELECT id, storage_key, thumbnail_key FROM attachments
+	WHERE comment_id IS NULL ORDER BY id LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+
+	for rows.Next() {
+		a := &Attachment{}
+
+		err = rows.Scan(&a.ID, &a.Key, &a.ThumbnailKey)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// Delete removes the record of an attachment, once its files are gone.
+func (m *AttachmentModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+
+	return err
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAttachmentModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &AttachmentModel{DB: db}
+	comments := &CommentModel{DB: db}
+
+	commentID, err := comments.Insert(1, 1, "Alice", "Look at this")
+	assert.NilError(t, err)
+
+	count, err := m.Count(commentID)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+
+	id, err := m.Insert(&Attachment{
+		CommentID:     commentID,
+		UserID:        1,
+		Name:          "frog.png",
+		ContentType:   "image/png",
+		Size:          1234,
+		Width:         640,
+		Height:        480,
+		Key:           "abc.png",
+		ThumbnailKey:  "def.png",
+		ThumbnailType: "image/png",
+	})
+	assert.NilError(t, err)
+
+	a, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, a.CommentID, commentID)
+	assert.Equal(t, a.SnippetID, 1)
+	assert.Equal(t, a.Name, "frog.png")
+	assert.Equal(t, a.Key, "abc.png")
+
+	count, err = m.Count(commentID)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+
+	byComment, err := m.ForComments([]int{commentID, 999})
+	assert.NilError(t, err)
+	assert.Equal(t, len(byComment[commentID]), 1)
+	assert.Equal(t, len(byComment[999]), 0)
+
+	// Attachments of deleted comments cannot be had
+	assert.NilError(t, comments.Delete(commentID, 1))
+
+	_, err = m.Get(id)
+	assert.Equal(t, err, ErrNoRecord)
+}
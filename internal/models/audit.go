@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type AuditModelInterface interface {
+	Insert(userID int, action, detail, ip string) error
+	InsertFailedLogin(email, ip string) error
+	List(filter AuditFilter, page, pageSize int) ([]*AuditEntry, int, error)
+	Logins(userID, limit int) ([]*AuditEntry, error)
+}
+
+// Audited actions. Logins and failed logins are recorded against the user
+// whose account they were for; everything else against the user who did it.
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login_failed"
+	AuditPasswordChange = "password_change"
+	AuditPasswordReset  = "password_reset"
+	AuditSnippetDelete  = "snippet_delete"
+	AuditCommentDelete  = "comment_delete"
+	AuditCommentHide    = "comment_hide"
+	AuditCommentApprove = "comment_approve"
+	AuditCommentNote    = "comment_note"
+	AuditCommentReject  = "comment_reject"
+	AuditReportDismiss  = "report_dismiss"
+	AuditUserBan        = "user_ban"
+	AuditUserUnban      = "user_unban"
+	AuditUserPurge      = "user_purge"
+	AuditUserRole       = "user_role"
+)
+
+// AuditActions lists every audited action, for filtering the log.
+var AuditActions = []string{
+	AuditLogin, AuditLoginFailed, AuditPasswordChange, AuditPasswordReset,
+	AuditSnippetDelete, AuditCommentDelete, AuditCommentHide, AuditCommentApprove,
+	AuditCommentNote, AuditCommentReject, AuditReportDismiss, AuditUserBan,
+	AuditUserUnban, AuditUserPurge, AuditUserRole,
+}
+
+// MaxAuditDetailLength is the longest detail an audit entry keeps; longer
+// ones are cut short.
+const MaxAuditDetailLength = 255
+
+// AuditEntry is one row of the audit log. UserID is 0, and UserName empty,
+// when the entry is about no known user, such as a failed login with an
+// email address nobody has.
+type AuditEntry struct {
+	ID       int
+	UserID   int
+	UserName string
+	Action   string
+	Detail   string
+	IP       string
+	Created  time.Time
+}
+
+// AuditFilter narrows down the entries List returns. Zero fields match
+// everything.
+type AuditFilter struct {
+	UserID int
+	Action string
+}
+
+// AuditModel wraps a sql.DB conn pool. Deleting a user sets the user_id of
+// their entries to NULL, so the log outlives the accounts in it.
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Insert records that a user did action. detail says what to, such as
+// "snippet 3", and ip where the request came from.
+func (m *AuditModel) Insert(userID int, action, detail, ip string) error {
+	stmt := `INSERT INTO audit_log (user_id, action, detail, ip, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, nullInt(userID), action, truncate(detail, MaxAuditDetailLength), ip)
+
+	return err
+}
+
+// InsertFailedLogin records a failed login with email. The entry belongs to
+// the user with that address, if there is one, so it shows in their login
+// history.
+func (m *AuditModel) InsertFailedLogin(email, ip string) error {
+	stmt := `INSERT INTO audit_log (user_id, action, detail, ip, created)
+	VALUES((SELECT id FROM users WHERE email = ?), ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, email, AuditLoginFailed, truncate(email, MaxAuditDetailLength), ip)
+
+	return err
+}
+
+// List returns a page of the entries matching filter, newest first, and the
+// total number of matching entries.
+func (m *AuditModel) List(filter AuditFilter, page, pageSize int) ([]*AuditEntry, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var conds []string
+	var args []any
+
+	if filter.UserID != 0 {
+		conds = append(conds, "a.user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM audit_log a `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT a.id, a.user_id, u.name, a.action, a.detail, a.ip, a.created FROM audit_log a
+	LEFT JOIN users u ON u.id = a.user_id ` + where + `
+	ORDER BY a.id DESC LIMIT ? OFFSET ?`
+
+	entries, err := m.query(stmt, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// Logins returns the user's most recent logins and failed logins, newest
+// first.
+func (m *AuditModel) Logins(userID, limit int) ([]*AuditEntry, error) {
+	stmt := `SELECT a.id, a.user_id, u.name, a.action, a.detail, a.ip, a.created FROM audit_log a
+	LEFT JOIN users u ON u.id = a.user_id
+	WHERE a.user_id = ? AND a.action IN (?, ?)
+	ORDER BY a.id DESC LIMIT ?`
+
+	return m.query(stmt, userID, AuditLogin, AuditLoginFailed, limit)
+}
+
+func (m *AuditModel) query(stmt string, args ...any) ([]*AuditEntry, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		e := &AuditEntry{}
+		var userID sql.NullInt64
+		var userName sql.NullString
+
+		err = rows.Scan(&e.ID, &userID, &userName, &e.Action, &e.Detail, &e.IP, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		e.UserID = int(userID.Int64)
+		e.UserName = userName.String
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// nullInt maps the zero ID to NULL.
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// truncate cuts s down to at most n characters.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAuditModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := &AuditModel{DB: newTestDB(t)}
+
+	assert.NilError(t, m.Insert(1, AuditLogin, "Firefox", "192.0.2.1"))
+	assert.NilError(t, m.InsertFailedLogin("alice@example.com", "192.0.2.2"))
+	assert.NilError(t, m.InsertFailedLogin("nobody@example.com", "192.0.2.3"))
+	assert.NilError(t, m.Insert(1, AuditSnippetDelete, "snippet 1", "192.0.2.1"))
+
+	entries, total, err := m.List(AuditFilter{}, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 4)
+	assert.Equal(t, entries[0].Action, AuditSnippetDelete)
+	assert.Equal(t, entries[0].UserName, "Alice Jones")
+
+	// A failed login with an unknown address belongs to nobody
+	assert.Equal(t, entries[1].UserID, 0)
+	assert.Equal(t, entries[1].Detail, "nobody@example.com")
+
+	entries, total, err = m.List(AuditFilter{UserID: 1, Action: AuditLoginFailed}, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, entries[0].IP, "192.0.2.2")
+
+	entries, total, err = m.List(AuditFilter{}, 2, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 4)
+	assert.Equal(t, len(entries), 1)
+
+	_, _, err = m.List(AuditFilter{}, 0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+
+	logins, err := m.Logins(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(logins), 2)
+	assert.Equal(t, logins[0].Action, AuditLoginFailed)
+	assert.Equal(t, logins[1].Action, AuditLogin)
+}
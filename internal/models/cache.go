@@ -0,0 +1,154 @@
+package models
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Cache is where CachingSnippetModel and CachingCommentModel keep what they
+// read. Values are bytes with an expiry, so that an external server such as
+// Redis can be used in place of MemoryCache and shared between instances.
+// Implementations must be safe for concurrent use; a server failure can be
+// treated as a missing key.
+type Cache interface {
+	// Get returns the value stored under key, if still valid.
+	Get(key string) ([]byte, bool)
+	// Set stores value under key for ttl.
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete drops the value stored under key, if any.
+	Delete(key string)
+}
+
+// sweepInterval is the minimum interval between the sweeps in which MemoryCache
+// drops expired entries.
+const sweepInterval = time.Minute
+
+// MemoryCache is a Cache in process memory. Callers of Set must not modify
+// value afterwards.
+type MemoryCache struct {
+	mu        sync.RWMutex
+	entries   map[string]memoryEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value stored under key, if still valid.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// Set stores value under key for ttl. Every so often it also drops expired
+// entries, which would otherwise stay in memory until they were replaced.
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+
+	if now.After(c.nextSweep) {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(sweepInterval)
+	}
+}
+
+// Delete drops the value stored under key, if any.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// Len returns the number of stored entries, including expired ones that have
+// not been dropped yet.
+func (c *MemoryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// versionTTL is how long a version is kept. It must be well above the TTL of
+// the values: if the version expires first, values that are still valid can no
+// longer be found and are read again.
+const versionTTL = 24 * time.Hour
+
+// cacheVersion returns the current version stored under key, creating one if
+// there is none. The keys of the values include the version, so bumping it with
+// bumpVersion invalidates all of them at once, including those a read started
+// before the bump has yet to store.
+func cacheVersion(c Cache, key string) string {
+	if v, ok := c.Get(key); ok {
+		return string(v)
+	}
+
+	v := newCacheVersion()
+	c.Set(key, []byte(v), versionTTL)
+	return v
+}
+
+// bumpVersion replaces the version stored under key.
+func bumpVersion(c Cache, key string) {
+	c.Set(key, []byte(newCacheVersion()), versionTTL)
+}
+
+func newCacheVersion() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// cacheLoad returns the value stored under key or, if there is none, whatever
+// load returns, storing it for ttl. Errors from load are not stored. Each call
+// decodes its own value, so callers are free to modify it.
+func cacheLoad[T any](c Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	if b, ok := c.Get(key); ok {
+		var v T
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err == nil {
+			return v, nil
+		}
+	}
+
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err == nil {
+		c.Set(key, buf.Bytes(), ttl)
+	}
+
+	return v, nil
+}
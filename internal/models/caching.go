@@ -0,0 +1,552 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultCacheTTL is how long CachingSnippetModel and CachingCommentModel keep
+// a result when no TTL is given.
+const DefaultCacheTTL = 30 * time.Second
+
+// CachingSnippetModel wraps a SnippetModelInterface and keeps the results of
+// Get, Latest and Related in Cache for up to TTL. The writes made through it
+// drop what they changed: Update, SetExpires, Delete, Restore, Upvote and
+// Downvote drop the snippet and the pages of Latest; Insert, InsertWithOptions
+// and Fork drop the pages of Latest. All of them drop the lists of Related, as
+// do tags attached by a CachingTagModel sharing the same Cache. Whatever is
+// written some other way, such as favorites, and snippets that expire on a page
+// of Latest or in a list of Related show up when the entry expires.
+type CachingSnippetModel struct {
+	SnippetModelInterface
+	Cache Cache
+	TTL   time.Duration
+
+	now func() time.Time
+}
+
+// latestPage is a page of Latest as kept in the cache.
+type latestPage struct {
+	Snippets []*Snippet
+	Total    int
+}
+
+// NewCachingSnippetModel returns a CachingSnippetModel over inner. A nil cache
+// uses a new MemoryCache, and a ttl of zero or less uses DefaultCacheTTL.
+func NewCachingSnippetModel(inner SnippetModelInterface, cache Cache, ttl time.Duration) *CachingSnippetModel {
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &CachingSnippetModel{
+		SnippetModelInterface: inner,
+		Cache:                 cache,
+		TTL:                   ttl,
+		now:                   time.Now,
+	}
+}
+
+const latestVersionKey = "snippets:latest:version"
+
+// relatedVersionKey holds the version of every list of Related, since a write
+// to one snippet can change the list of any other.
+const relatedVersionKey = "snippets:related:version"
+
+func snippetVersionKey(id int) string {
+	return fmt.Sprintf("snippet:%d:version", id)
+}
+
+// Get returns the cached snippet, if still valid, or fetches it from the
+// wrapped model and caches the result. A cached snippet that has expired
+// returns ErrExpired, as the wrapped model does.
+func (m *CachingSnippetModel) Get(id int) (*Snippet, error) {
+	key := fmt.Sprintf("snippet:%d:%s", id, cacheVersion(m.Cache, snippetVersionKey(id)))
+
+	s, err := cacheLoad(m.Cache, key, m.TTL, func() (*Snippet, error) {
+		return m.SnippetModelInterface.Get(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.now().Before(s.Expires) {
+		return nil, ErrExpired
+	}
+
+	return s, nil
+}
+
+// Latest returns the cached page, if still valid, or fetches it from the
+// wrapped model and caches the result.
+func (m *CachingSnippetModel) Latest(page, pageSize int) ([]*Snippet, int, error) {
+	key := fmt.Sprintf("snippets:latest:%s:%d:%d", cacheVersion(m.Cache, latestVersionKey), page, pageSize)
+
+	p, err := cacheLoad(m.Cache, key, m.TTL, func() (latestPage, error) {
+		snippets, total, err := m.SnippetModelInterface.Latest(page, pageSize)
+		return latestPage{Snippets: snippets, Total: total}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// gob does not tell an empty list from nil
+	if p.Snippets == nil {
+		p.Snippets = []*Snippet{}
+	}
+
+	return p.Snippets, p.Total, nil
+}
+
+// Related returns the cached list, if still valid, or fetches it from the
+// wrapped model and caches the result.
+func (m *CachingSnippetModel) Related(id int, limit int) ([]*Snippet, error) {
+	key := fmt.Sprintf("snippets:related:%s:%d:%d", cacheVersion(m.Cache, relatedVersionKey), id, limit)
+
+	snippets, err := cacheLoad(m.Cache, key, m.TTL, func() ([]*Snippet, error) {
+		return m.SnippetModelInterface.Related(id, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// gob does not tell an empty list from nil
+	if snippets == nil {
+		snippets = []*Snippet{}
+	}
+
+	return snippets, nil
+}
+
+// Insert passes the insert on and drops the cached pages of Latest.
+func (m *CachingSnippetModel) Insert(title string, content string, expires int, userID int) (int, error) {
+	defer m.invalidateLatest()
+	return m.SnippetModelInterface.Insert(title, content, expires, userID)
+}
+
+// InsertWithOptions passes the insert on and drops the cached pages of Latest.
+func (m *CachingSnippetModel) InsertWithOptions(title string, content string, opts SnippetOptions, expires int, userID int) (int, error) {
+	defer m.invalidateLatest()
+	return m.SnippetModelInterface.InsertWithOptions(title, content, opts, expires, userID)
+}
+
+// Fork passes the fork on and drops the cached pages of Latest.
+func (m *CachingSnippetModel) Fork(id int, userID int, expires int) (int, error) {
+	defer m.invalidateLatest()
+	return m.SnippetModelInterface.Fork(id, userID, expires)
+}
+
+// Update passes the edit on and drops the cached snippet and pages of Latest.
+func (m *CachingSnippetModel) Update(id int, title string, content string) error {
+	defer m.invalidate(id)
+	return m.SnippetModelInterface.Update(id, title, content)
+}
+
+// SetExpires passes the change on and drops the cached snippet and pages of
+// Latest.
+func (m *CachingSnippetModel) SetExpires(id int, expires time.Time) error {
+	defer m.invalidate(id)
+	return m.SnippetModelInterface.SetExpires(id, expires)
+}
+
+// Delete passes the deletion on and drops the cached snippet and pages of
+// Latest.
+func (m *CachingSnippetModel) Delete(id int, deletedBy int) error {
+	defer m.invalidate(id)
+	return m.SnippetModelInterface.Delete(id, deletedBy)
+}
+
+// Restore passes the restore on and drops the cached snippet and pages of
+// Latest.
+func (m *CachingSnippetModel) Restore(id int) error {
+	defer m.invalidate(id)
+	return m.SnippetModelInterface.Restore(id)
+}
+
+// Upvote passes the vote on and drops the cached snippet and pages of Latest.
+func (m *CachingSnippetModel) Upvote(snippetID, userID int) (VoteResult, error) {
+	defer m.invalidate(snippetID)
+	return m.SnippetModelInterface.Upvote(snippetID, userID)
+}
+
+// Downvote passes the vote on and drops the cached snippet and pages of Latest.
+func (m *CachingSnippetModel) Downvote(snippetID, userID int) (VoteResult, error) {
+	defer m.invalidate(snippetID)
+	return m.SnippetModelInterface.Downvote(snippetID, userID)
+}
+
+// invalidate drops a cached snippet and the cached pages of Latest.
+func (m *CachingSnippetModel) invalidate(id int) {
+	bumpVersion(m.Cache, snippetVersionKey(id))
+	m.invalidateLatest()
+}
+
+// invalidateLatest drops the cached pages of Latest and lists of Related.
+func (m *CachingSnippetModel) invalidateLatest() {
+	bumpVersion(m.Cache, latestVersionKey)
+	bumpVersion(m.Cache, relatedVersionKey)
+}
+
+// CachingTagModel wraps a TagModelInterface so that AttachToSnippet drops the
+// lists of Related kept by a CachingSnippetModel in the same Cache, which
+// depend on tags. It caches nothing itself.
+type CachingTagModel struct {
+	TagModelInterface
+	Cache Cache
+}
+
+// AttachToSnippet passes the tags on and drops the cached lists of Related.
+func (m *CachingTagModel) AttachToSnippet(snippetID int, names []string) error {
+	defer bumpVersion(m.Cache, relatedVersionKey)
+	return m.TagModelInterface.AttachToSnippet(snippetID, names)
+}
+
+// CachingCommentModel wraps a CommentModelInterface and keeps the result of
+// GetBySnippetID in Cache for up to TTL. Every write that changes that list
+// drops the list of the snippet it touched or, when the snippet is not known,
+// as with RejectMatching, DeleteByAuthor and SetShadowban, every list.
+// IncrementViews is passed on without dropping anything, and new views show up
+// when the entry expires.
+type CachingCommentModel struct {
+	CommentModelInterface
+	Cache Cache
+	TTL   time.Duration
+}
+
+// NewCachingCommentModel returns a CachingCommentModel over inner. A nil cache
+// uses a new MemoryCache, and a ttl of zero or less uses DefaultCacheTTL.
+func NewCachingCommentModel(inner CommentModelInterface, cache Cache, ttl time.Duration) *CachingCommentModel {
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &CachingCommentModel{
+		CommentModelInterface: inner,
+		Cache:                 cache,
+		TTL:                   ttl,
+	}
+}
+
+// commentsVersionKey holds the version of every list, bumped when it is not
+// known which list a write changed.
+const commentsVersionKey = "comments:version"
+
+func snippetCommentsVersionKey(snippetID int) string {
+	return fmt.Sprintf("comments:snippet:%d:version", snippetID)
+}
+
+// GetBySnippetID returns the snippet's cached list, if still valid, or fetches
+// it from the wrapped model and caches the result.
+func (m *CachingCommentModel) GetBySnippetID(snippetID int) ([]*Comment, error) {
+	return m.GetBySnippetIDContext(context.Background(), snippetID)
+}
+
+// GetBySnippetIDContext is like GetBySnippetID, but uses ctx for the database queries.
+func (m *CachingCommentModel) GetBySnippetIDContext(ctx context.Context, snippetID int) ([]*Comment, error) {
+	key := fmt.Sprintf("comments:snippet:%d:%s:%s", snippetID,
+		cacheVersion(m.Cache, commentsVersionKey), cacheVersion(m.Cache, snippetCommentsVersionKey(snippetID)))
+
+	comments, err := cacheLoad(m.Cache, key, m.TTL, func() ([]*Comment, error) {
+		return m.CommentModelInterface.GetBySnippetIDContext(ctx, snippetID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// gob does not tell an empty list from nil
+	if comments == nil {
+		comments = []*Comment{}
+	}
+
+	return comments, nil
+}
+
+// Insert passes the insert on and drops the snippet's cached list.
+func (m *CachingCommentModel) Insert(snippetID int, authorID int, author string, content string) (int, error) {
+	return m.InsertContext(context.Background(), snippetID, authorID, author, content)
+}
+
+// InsertContext is like Insert, but uses ctx for the database queries.
+func (m *CachingCommentModel) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	defer m.invalidate(snippetID)
+	return m.CommentModelInterface.InsertContext(ctx, snippetID, authorID, author, content)
+}
+
+// Update passes the edit on and drops the cached list of the comment's snippet.
+func (m *CachingCommentModel) Update(id int, content string) error {
+	return m.UpdateContext(context.Background(), id, content)
+}
+
+// UpdateContext is like Update, but uses ctx for the database queries.
+func (m *CachingCommentModel) UpdateContext(ctx context.Context, id int, content string) error {
+	defer m.invalidate(m.snippetOf(ctx, id))
+	return m.CommentModelInterface.UpdateContext(ctx, id, content)
+}
+
+// Delete passes the deletion on and drops the cached list of the comment's
+// snippet.
+func (m *CachingCommentModel) Delete(id int, deletedBy int) error {
+	return m.DeleteContext(context.Background(), id, deletedBy)
+}
+
+// DeleteContext is like Delete, but uses ctx for the database queries.
+func (m *CachingCommentModel) DeleteContext(ctx context.Context, id int, deletedBy int) error {
+	defer m.invalidate(m.snippetOf(ctx, id))
+	return m.CommentModelInterface.DeleteContext(ctx, id, deletedBy)
+}
+
+// Upvote passes the vote on and drops the cached list of the comment's snippet.
+func (m *CachingCommentModel) Upvote(commentID, userID int) (VoteResult, error) {
+	return m.UpvoteContext(context.Background(), commentID, userID)
+}
+
+// UpvoteContext is like Upvote, but uses ctx for the database queries.
+func (m *CachingCommentModel) UpvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error) {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.UpvoteContext(ctx, commentID, userID)
+}
+
+// Downvote passes the vote on and drops the cached list of the comment's
+// snippet.
+func (m *CachingCommentModel) Downvote(commentID, userID int) (VoteResult, error) {
+	return m.DownvoteContext(context.Background(), commentID, userID)
+}
+
+// DownvoteContext is like Downvote, but uses ctx for the database queries.
+func (m *CachingCommentModel) DownvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error) {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.DownvoteContext(ctx, commentID, userID)
+}
+
+// Pin passes the pin on and drops the cached list of the comment's snippet,
+// whose order changes.
+func (m *CachingCommentModel) Pin(commentID int) error {
+	return m.PinContext(context.Background(), commentID)
+}
+
+// PinContext is like Pin, but uses ctx for the database queries.
+func (m *CachingCommentModel) PinContext(ctx context.Context, commentID int) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.PinContext(ctx, commentID)
+}
+
+// Unpin passes the unpin on and drops the cached list of the comment's snippet.
+func (m *CachingCommentModel) Unpin(commentID int) error {
+	return m.UnpinContext(context.Background(), commentID)
+}
+
+// UnpinContext is like Unpin, but uses ctx for the database queries.
+func (m *CachingCommentModel) UnpinContext(ctx context.Context, commentID int) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.UnpinContext(ctx, commentID)
+}
+
+// Approve passes the approval on and drops the cached list of the comment's
+// snippet, where it now shows up.
+func (m *CachingCommentModel) Approve(commentID int) error {
+	return m.ApproveContext(context.Background(), commentID)
+}
+
+// ApproveContext is like Approve, but uses ctx for the database queries.
+func (m *CachingCommentModel) ApproveContext(ctx context.Context, commentID int) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.ApproveContext(ctx, commentID)
+}
+
+// ToggleReaction passes the reaction on and drops the cached list of the
+// comment's snippet.
+func (m *CachingCommentModel) ToggleReaction(commentID, userID int, reaction string) (bool, error) {
+	return m.ToggleReactionContext(context.Background(), commentID, userID, reaction)
+}
+
+// ToggleReactionContext is like ToggleReaction, but uses ctx for the database queries.
+func (m *CachingCommentModel) ToggleReactionContext(ctx context.Context, commentID, userID int, reaction string) (bool, error) {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.ToggleReactionContext(ctx, commentID, userID, reaction)
+}
+
+// InsertReply passes the reply on and drops the snippet's cached list.
+func (m *CachingCommentModel) InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return m.InsertReplyContext(context.Background(), snippetID, parentID, authorID, author, content)
+}
+
+// InsertReplyContext is like InsertReply, but uses ctx for the database queries.
+func (m *CachingCommentModel) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	defer m.invalidate(snippetID)
+	return m.CommentModelInterface.InsertReplyContext(ctx, snippetID, parentID, authorID, author, content)
+}
+
+// InsertBatch passes the insert on and drops the cached lists of the snippets
+// the comments were added to.
+func (m *CachingCommentModel) InsertBatch(comments []CommentInput) ([]int, error) {
+	return m.InsertBatchContext(context.Background(), comments)
+}
+
+// InsertBatchContext is like InsertBatch, but uses ctx for the database queries.
+func (m *CachingCommentModel) InsertBatchContext(ctx context.Context, comments []CommentInput) ([]int, error) {
+	defer func() {
+		seen := map[int]bool{}
+		for _, c := range comments {
+			if !seen[c.SnippetID] {
+				seen[c.SnippetID] = true
+				m.invalidate(c.SnippetID)
+			}
+		}
+	}()
+	return m.CommentModelInterface.InsertBatchContext(ctx, comments)
+}
+
+// UpdateWithVersion passes the edit on and drops the cached list of the
+// comment's snippet.
+func (m *CachingCommentModel) UpdateWithVersion(id int, content string, expectedVersion int) error {
+	return m.UpdateWithVersionContext(context.Background(), id, content, expectedVersion)
+}
+
+// UpdateWithVersionContext is like UpdateWithVersion, but uses ctx for the database queries.
+func (m *CachingCommentModel) UpdateWithVersionContext(ctx context.Context, id int, content string, expectedVersion int) error {
+	defer m.invalidate(m.snippetOf(ctx, id))
+	return m.CommentModelInterface.UpdateWithVersionContext(ctx, id, content, expectedVersion)
+}
+
+// RecalculateVotes passes the recount on and drops the cached list of the
+// comment's snippet.
+func (m *CachingCommentModel) RecalculateVotes(commentID int) error {
+	return m.RecalculateVotesContext(context.Background(), commentID)
+}
+
+// RecalculateVotesContext is like RecalculateVotes, but uses ctx for the database queries.
+func (m *CachingCommentModel) RecalculateVotesContext(ctx context.Context, commentID int) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.RecalculateVotesContext(ctx, commentID)
+}
+
+// DeleteBySnippetID passes the deletion on and drops the snippet's cached list.
+func (m *CachingCommentModel) DeleteBySnippetID(snippetID int) (int, error) {
+	return m.DeleteBySnippetIDContext(context.Background(), snippetID)
+}
+
+// DeleteBySnippetIDContext is like DeleteBySnippetID, but uses ctx for the database queries.
+func (m *CachingCommentModel) DeleteBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	defer m.invalidate(snippetID)
+	return m.CommentModelInterface.DeleteBySnippetIDContext(ctx, snippetID)
+}
+
+// SetPinOrder passes the new order on and drops the snippet's cached list.
+func (m *CachingCommentModel) SetPinOrder(snippetID int, orderedCommentIDs []int) error {
+	return m.SetPinOrderContext(context.Background(), snippetID, orderedCommentIDs)
+}
+
+// SetPinOrderContext is like SetPinOrder, but uses ctx for the database queries.
+func (m *CachingCommentModel) SetPinOrderContext(ctx context.Context, snippetID int, orderedCommentIDs []int) error {
+	defer m.invalidate(snippetID)
+	return m.CommentModelInterface.SetPinOrderContext(ctx, snippetID, orderedCommentIDs)
+}
+
+// Restore passes the restore on and drops the cached list of the comment's
+// snippet, where it shows up again.
+func (m *CachingCommentModel) Restore(id int) error {
+	return m.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext is like Restore, but uses ctx for the database queries.
+func (m *CachingCommentModel) RestoreContext(ctx context.Context, id int) error {
+	defer m.invalidate(m.snippetOf(ctx, id))
+	return m.CommentModelInterface.RestoreContext(ctx, id)
+}
+
+// Hide passes the hide on and drops the cached list of the comment's snippet.
+func (m *CachingCommentModel) Hide(commentID int, reason string) error {
+	return m.HideContext(context.Background(), commentID, reason)
+}
+
+// HideContext is like Hide, but uses ctx for the database queries.
+func (m *CachingCommentModel) HideContext(ctx context.Context, commentID int, reason string) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.HideContext(ctx, commentID, reason)
+}
+
+// Hold passes the hold on and drops the cached list of the comment's snippet,
+// which it leaves.
+func (m *CachingCommentModel) Hold(commentID int, reason string) error {
+	return m.HoldContext(context.Background(), commentID, reason)
+}
+
+// HoldContext is like Hold, but uses ctx for the database queries.
+func (m *CachingCommentModel) HoldContext(ctx context.Context, commentID int, reason string) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.HoldContext(ctx, commentID, reason)
+}
+
+// SetModeratorNote passes the note on and drops the cached list of the
+// comment's snippet.
+func (m *CachingCommentModel) SetModeratorNote(commentID int, note string) error {
+	return m.SetModeratorNoteContext(context.Background(), commentID, note)
+}
+
+// SetModeratorNoteContext is like SetModeratorNote, but uses ctx for the database queries.
+func (m *CachingCommentModel) SetModeratorNoteContext(ctx context.Context, commentID int, note string) error {
+	defer m.invalidate(m.snippetOf(ctx, commentID))
+	return m.CommentModelInterface.SetModeratorNoteContext(ctx, commentID, note)
+}
+
+// RejectMatching passes the rejection on and drops every cached list, since the
+// rejected comments can be on any snippet.
+func (m *CachingCommentModel) RejectMatching(pattern string, reason string) (int, error) {
+	return m.RejectMatchingContext(context.Background(), pattern, reason)
+}
+
+// RejectMatchingContext is like RejectMatching, but uses ctx for the database queries.
+func (m *CachingCommentModel) RejectMatchingContext(ctx context.Context, pattern string, reason string) (int, error) {
+	defer m.invalidate(0)
+	return m.CommentModelInterface.RejectMatchingContext(ctx, pattern, reason)
+}
+
+// DeleteByAuthor passes the deletion on and drops every cached list, since the
+// author may have commented on any snippet.
+func (m *CachingCommentModel) DeleteByAuthor(authorID int, deletedBy int) (int, error) {
+	return m.DeleteByAuthorContext(context.Background(), authorID, deletedBy)
+}
+
+// DeleteByAuthorContext is like DeleteByAuthor, but uses ctx for the database queries.
+func (m *CachingCommentModel) DeleteByAuthorContext(ctx context.Context, authorID int, deletedBy int) (int, error) {
+	defer m.invalidate(0)
+	return m.CommentModelInterface.DeleteByAuthorContext(ctx, authorID, deletedBy)
+}
+
+// SetShadowban passes the change on and drops every cached list, which the
+// author's comments leave or come back to.
+func (m *CachingCommentModel) SetShadowban(authorUserID int, banned bool) error {
+	return m.SetShadowbanContext(context.Background(), authorUserID, banned)
+}
+
+// SetShadowbanContext is like SetShadowban, but uses ctx for the database queries.
+func (m *CachingCommentModel) SetShadowbanContext(ctx context.Context, authorUserID int, banned bool) error {
+	defer m.invalidate(0)
+	return m.CommentModelInterface.SetShadowbanContext(ctx, authorUserID, banned)
+}
+
+// snippetOf returns the snippet of a comment, or 0 if it cannot be found out.
+// It is called before the write, while the comment can still be found.
+func (m *CachingCommentModel) snippetOf(ctx context.Context, commentID int) int {
+	c, err := m.CommentModelInterface.GetContext(ctx, commentID)
+	if err != nil {
+		return 0
+	}
+	return c.SnippetID
+}
+
+// invalidate drops the cached list of a snippet. For snippet 0, it drops every
+// list.
+func (m *CachingCommentModel) invalidate(snippetID int) {
+	if snippetID == 0 {
+		bumpVersion(m.Cache, commentsVersionKey)
+		return
+	}
+	bumpVersion(m.Cache, snippetCommentsVersionKey(snippetID))
+}
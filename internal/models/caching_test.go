@@ -0,0 +1,616 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+// countingComments keeps comments in memory, hands out copies of them and
+// counts the GetBySnippetID reads that reach it.
+type countingComments struct {
+	CommentModelInterface
+
+	mu           sync.Mutex
+	comments     map[int]*Comment
+	shadowbanned map[int]bool
+	reads        int
+}
+
+func (m *countingComments) GetBySnippetIDContext(ctx context.Context, snippetID int) ([]*Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reads++
+	comments := []*Comment{}
+	for _, c := range m.comments {
+		if c.SnippetID == snippetID {
+			cc := *c
+			comments = append(comments, &cc)
+		}
+	}
+	return comments, nil
+}
+
+func (m *countingComments) GetContext(ctx context.Context, id int) (*Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.comments[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	cc := *c
+	return &cc, nil
+}
+
+func (m *countingComments) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := len(m.comments) + 1
+	m.comments[id] = &Comment{ID: id, SnippetID: snippetID, AuthorID: authorID, Author: author, Content: content}
+	return id, nil
+}
+
+func (m *countingComments) UpvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.comments[commentID].Upvotes++
+	return VoteResult{Action: ActionAdded, Type: "upvote", Score: m.comments[commentID].Score()}, nil
+}
+
+func (m *countingComments) ToggleReactionContext(ctx context.Context, commentID, userID int, reaction string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.comments[commentID]
+	c.Reactions = append(c.Reactions, ReactionCount{Reaction: Reaction{Name: reaction}, Count: 1})
+	return true, nil
+}
+
+func (m *countingComments) PinContext(ctx context.Context, commentID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.comments {
+		if c.SnippetID == m.comments[commentID].SnippetID {
+			c.Pinned = false
+		}
+	}
+	m.comments[commentID].Pinned = true
+	return nil
+}
+
+func (m *countingComments) HoldContext(ctx context.Context, commentID int, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.comments[commentID].Status = StatusPending
+	m.comments[commentID].StatusNote = reason
+	return nil
+}
+
+func (m *countingComments) ApproveContext(ctx context.Context, commentID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.comments[commentID].Status = StatusApproved
+	m.comments[commentID].StatusNote = ""
+	return nil
+}
+
+func (m *countingComments) DeleteContext(ctx context.Context, id int, deletedBy int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.comments, id)
+	return nil
+}
+
+func (m *countingComments) ShadowbannedContext(ctx context.Context, authorUserID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.shadowbanned[authorUserID], nil
+}
+
+func (m *countingComments) readCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reads
+}
+
+func TestCachingCommentModel(t *testing.T) {
+	inner := &countingComments{comments: map[int]*Comment{}}
+	cache := NewMemoryCache()
+	m := NewCachingCommentModel(inner, cache, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+
+	// The second read comes from the cache
+	_, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 1)
+
+	// Modifying the returned list does not modify the cache
+	comments[0].Content = "Changed"
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Content, "First!")
+
+	// A vote drops the snippet's entry, but not those of other snippets
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 2)
+
+	_, err = m.Upvote(id, 2)
+	assert.NilError(t, err)
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Upvotes, 1)
+	assert.Equal(t, inner.readCount(), 3)
+
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 3)
+
+	// An insert drops the snippet's entry
+	_, err = m.Insert(1, 1, "Bob", "Second!")
+	assert.NilError(t, err)
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, inner.readCount(), 4)
+
+	// The entry expires after the TTL
+	now = now.Add(time.Minute)
+	_, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 5)
+
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 6)
+
+	// A deletion drops the snippet's entry, even though the comment can no
+	// longer be found after it
+	assert.NilError(t, m.Delete(id, 1))
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, inner.readCount(), 7)
+
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 7)
+
+	// A write to an unknown comment drops every entry
+	assert.NilError(t, m.Delete(99, 1))
+
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 8)
+
+	// A reaction drops the entry of the comment's snippet
+	_, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 9)
+
+	_, err = m.ToggleReaction(2, 1, "heart")
+	assert.NilError(t, err)
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments[0].Reactions), 1)
+	assert.Equal(t, inner.readCount(), 10)
+
+	_, err = m.GetBySnippetID(2)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.readCount(), 10)
+
+	// Pinning a comment changes the order of the snippet's list
+	assert.NilError(t, m.Pin(2))
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Pinned, true)
+	assert.Equal(t, inner.readCount(), 11)
+}
+
+// writingComments accepts, without doing anything, the writes that need not
+// change the comments of countingComments for the invalidation test.
+type writingComments struct {
+	*countingComments
+}
+
+func (m *writingComments) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return 2, nil
+}
+
+func (m *writingComments) InsertBatchContext(ctx context.Context, comments []CommentInput) ([]int, error) {
+	return []int{2}, nil
+}
+
+func (m *writingComments) UpdateWithVersionContext(ctx context.Context, id int, content string, expectedVersion int) error {
+	return nil
+}
+
+func (m *writingComments) RecalculateVotesContext(ctx context.Context, commentID int) error {
+	return nil
+}
+
+func (m *writingComments) DeleteBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	return 1, nil
+}
+
+func (m *writingComments) SetPinOrderContext(ctx context.Context, snippetID int, orderedCommentIDs []int) error {
+	return nil
+}
+
+func (m *writingComments) RestoreContext(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *writingComments) HideContext(ctx context.Context, commentID int, reason string) error {
+	return nil
+}
+
+func (m *writingComments) SetModeratorNoteContext(ctx context.Context, commentID int, note string) error {
+	return nil
+}
+
+func (m *writingComments) RejectMatchingContext(ctx context.Context, pattern string, reason string) (int, error) {
+	return 1, nil
+}
+
+func (m *writingComments) DeleteByAuthorContext(ctx context.Context, authorID int, deletedBy int) (int, error) {
+	return 1, nil
+}
+
+func (m *writingComments) SetShadowbanContext(ctx context.Context, authorUserID int, banned bool) error {
+	return nil
+}
+
+func TestCachingCommentModelInvalidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(m *CachingCommentModel) error
+		// allSnippets reports whether the write also drops the list of snippet
+		// 2, which does not have comment 1
+		allSnippets bool
+	}{
+		{
+			name: "InsertReply",
+			write: func(m *CachingCommentModel) error {
+				_, err := m.InsertReply(1, 1, 2, "Bob", "Reply")
+				return err
+			},
+		},
+		{
+			name: "InsertBatch",
+			write: func(m *CachingCommentModel) error {
+				_, err := m.InsertBatch([]CommentInput{{SnippetID: 1, Author: "Bob", Content: "Imported"}})
+				return err
+			},
+		},
+		{
+			name:  "UpdateWithVersion",
+			write: func(m *CachingCommentModel) error { return m.UpdateWithVersion(1, "Edited", 1) },
+		},
+		{
+			name:  "RecalculateVotes",
+			write: func(m *CachingCommentModel) error { return m.RecalculateVotes(1) },
+		},
+		{
+			name: "DeleteBySnippetID",
+			write: func(m *CachingCommentModel) error {
+				_, err := m.DeleteBySnippetID(1)
+				return err
+			},
+		},
+		{
+			name:  "SetPinOrder",
+			write: func(m *CachingCommentModel) error { return m.SetPinOrder(1, []int{1}) },
+		},
+		{
+			name:  "Restore",
+			write: func(m *CachingCommentModel) error { return m.Restore(1) },
+		},
+		{
+			name:  "Hide",
+			write: func(m *CachingCommentModel) error { return m.Hide(1, "Spam") },
+		},
+		{
+			name:  "Hold",
+			write: func(m *CachingCommentModel) error { return m.Hold(1, "Spam") },
+		},
+		{
+			name:  "SetModeratorNote",
+			write: func(m *CachingCommentModel) error { return m.SetModeratorNote(1, "Edited") },
+		},
+		{
+			name: "RejectMatching",
+			write: func(m *CachingCommentModel) error {
+				_, err := m.RejectMatching("spam", "Spam")
+				return err
+			},
+			allSnippets: true,
+		},
+		{
+			name: "DeleteByAuthor",
+			write: func(m *CachingCommentModel) error {
+				_, err := m.DeleteByAuthor(1, 1)
+				return err
+			},
+			allSnippets: true,
+		},
+		{
+			name:        "SetShadowban",
+			write:       func(m *CachingCommentModel) error { return m.SetShadowban(1, true) },
+			allSnippets: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingComments{comments: map[int]*Comment{
+				1: {ID: 1, SnippetID: 1, AuthorID: 1, Author: "Alice", Content: "First!", Status: StatusApproved},
+			}}
+			m := NewCachingCommentModel(&writingComments{inner}, nil, time.Minute)
+
+			_, err := m.GetBySnippetID(1)
+			assert.NilError(t, err)
+			_, err = m.GetBySnippetID(2)
+			assert.NilError(t, err)
+			assert.Equal(t, inner.readCount(), 2)
+
+			assert.NilError(t, tt.write(m))
+
+			_, err = m.GetBySnippetID(1)
+			assert.NilError(t, err)
+			assert.Equal(t, inner.readCount(), 3)
+
+			_, err = m.GetBySnippetID(2)
+			assert.NilError(t, err)
+
+			want := 3
+			if tt.allSnippets {
+				want = 4
+			}
+			assert.Equal(t, inner.readCount(), want)
+		})
+	}
+}
+
+func TestCachingCommentModelConcurrent(t *testing.T) {
+	inner := &countingComments{comments: map[int]*Comment{}}
+	m := NewCachingCommentModel(inner, nil, time.Minute)
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if i == 0 && j%10 == 0 {
+					m.Upvote(id, 2)
+					continue
+				}
+				comments, err := m.GetBySnippetID(1)
+				if err != nil || len(comments) != 1 {
+					t.Errorf("got %d comments, err %v; want 1", len(comments), err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Upvotes, 5)
+}
+
+// countingSnippets keeps snippets in memory and counts the Get and Latest reads
+// that reach it.
+type countingSnippets struct {
+	SnippetModelInterface
+
+	snippets map[int]*Snippet
+	gets     int
+	latests  int
+	relateds int
+}
+
+func (m *countingSnippets) Related(id int, limit int) ([]*Snippet, error) {
+	m.relateds++
+	snippets := []*Snippet{}
+	for other, s := range m.snippets {
+		if other != id && len(snippets) < limit {
+			sc := *s
+			snippets = append(snippets, &sc)
+		}
+	}
+	return snippets, nil
+}
+
+func (m *countingSnippets) Get(id int) (*Snippet, error) {
+	m.gets++
+	s, ok := m.snippets[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	sc := *s
+	return &sc, nil
+}
+
+func (m *countingSnippets) Latest(page, pageSize int) ([]*Snippet, int, error) {
+	m.latests++
+	snippets := []*Snippet{}
+	for id := len(m.snippets); id > 0; id-- {
+		sc := *m.snippets[id]
+		snippets = append(snippets, &sc)
+	}
+	return snippets, len(snippets), nil
+}
+
+func (m *countingSnippets) Insert(title string, content string, expires int, userID int) (int, error) {
+	id := len(m.snippets) + 1
+	m.snippets[id] = &Snippet{ID: id, UserID: userID, Title: title, Content: content,
+		Expires: time.Now().AddDate(0, 0, expires)}
+	return id, nil
+}
+
+func (m *countingSnippets) Upvote(snippetID, userID int) (VoteResult, error) {
+	m.snippets[snippetID].Upvotes++
+	return VoteResult{Action: ActionAdded, Type: "upvote", Score: m.snippets[snippetID].Score()}, nil
+}
+
+func TestCachingSnippetModel(t *testing.T) {
+	inner := &countingSnippets{snippets: map[int]*Snippet{}}
+	cache := NewMemoryCache()
+	m := NewCachingSnippetModel(inner, cache, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	m.now = func() time.Time { return now }
+
+	// An empty page stays empty, not nil, coming out of the cache
+	for i := 0; i < 2; i++ {
+		snippets, total, err := m.Latest(1, 10)
+		assert.NilError(t, err)
+		assert.Equal(t, snippets != nil, true)
+		assert.Equal(t, total, 0)
+	}
+	assert.Equal(t, inner.latests, 1)
+
+	// An insert drops the pages
+	id, err := m.Insert("O snail", "Climb Mount Fuji", 1, 1)
+	assert.NilError(t, err)
+
+	snippets, total, err := m.Latest(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Title, "O snail")
+	assert.Equal(t, inner.latests, 2)
+
+	// The second read comes from the cache, and modifying the returned snippet
+	// does not modify the cache
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	s.Title = "Changed"
+
+	s, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "O snail")
+	assert.Equal(t, inner.gets, 1)
+
+	// Errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err = m.Get(99)
+		assert.Equal(t, err, ErrNoRecord)
+	}
+	assert.Equal(t, inner.gets, 3)
+
+	// A vote drops the snippet and the pages
+	_, err = m.Upvote(id, 2)
+	assert.NilError(t, err)
+
+	s, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Upvotes, 1)
+	assert.Equal(t, inner.gets, 4)
+
+	snippets, _, err = m.Latest(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, snippets[0].Upvotes, 1)
+	assert.Equal(t, inner.latests, 3)
+
+	// A cached snippet that expires returns ErrExpired
+	now = s.Expires
+	_, err = m.Get(id)
+	assert.Equal(t, err, ErrExpired)
+}
+
+func TestCachingSnippetModelRelated(t *testing.T) {
+	inner := &countingSnippets{snippets: map[int]*Snippet{}}
+	cache := NewMemoryCache()
+	m := NewCachingSnippetModel(inner, cache, time.Minute)
+	tags := &CachingTagModel{TagModelInterface: &TagModel{}, Cache: cache}
+
+	id, err := m.Insert("O snail", "Climb Mount Fuji", 1, 1)
+	assert.NilError(t, err)
+
+	// An empty list stays empty, not nil, coming out of the cache
+	for i := 0; i < 2; i++ {
+		related, err := m.Related(id, 5)
+		assert.NilError(t, err)
+		assert.Equal(t, related != nil, true)
+		assert.Equal(t, len(related), 0)
+	}
+	assert.Equal(t, inner.relateds, 1)
+
+	// A new snippet can be related to any other
+	_, err = m.Insert("Slowly, slowly", "But surely", 1, 1)
+	assert.NilError(t, err)
+
+	related, err := m.Related(id, 5)
+	assert.NilError(t, err)
+	assert.Equal(t, len(related), 1)
+	assert.Equal(t, inner.relateds, 2)
+
+	// So does a change to the tags, even with no tag to attach
+	assert.NilError(t, tags.AttachToSnippet(id, nil))
+
+	_, err = m.Related(id, 5)
+	assert.NilError(t, err)
+	assert.Equal(t, inner.relateds, 3)
+}
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"), time.Minute)
+	c.Set("b", []byte("2"), time.Hour)
+
+	v, ok := c.Get("a")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, string(v), "1")
+
+	c.Delete("b")
+	_, ok = c.Get("b")
+	assert.Equal(t, ok, false)
+
+	// An entry expires after the TTL
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, c.Len(), 1)
+
+	// and is dropped on the next sweep
+	now = now.Add(sweepInterval)
+	c.Set("c", []byte("3"), time.Minute)
+	assert.Equal(t, c.Len(), 1)
+}
@@ -1,43 +1,531 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"math"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type CommentModelInterface interface {
-	Insert(snippetID int, author string, content string) (int, error)
+	Insert(snippetID int, authorID int, author string, content string) (int, error)
+	InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error)
+	InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error)
+	InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error)
+	InsertBatch(comments []CommentInput) ([]int, error)
+	InsertBatchContext(ctx context.Context, comments []CommentInput) ([]int, error)
 	GetBySnippetID(snippetID int) ([]*Comment, error)
+	GetBySnippetIDContext(ctx context.Context, snippetID int) ([]*Comment, error)
+	GetBySnippetIDPaginated(snippetID, limit, offset int) ([]*Comment, int, error)
+	GetBySnippetIDPaginatedContext(ctx context.Context, snippetID, limit, offset int) ([]*Comment, int, error)
+	GetBySnippetIDPaged(snippetID, page, pageSize int) ([]*Comment, int, error)
+	GetBySnippetIDPagedContext(ctx context.Context, snippetID, page, pageSize int) ([]*Comment, int, error)
+	GetBySnippetIDSorted(snippetID int, order SortOrder) ([]*Comment, error)
+	GetBySnippetIDSortedContext(ctx context.Context, snippetID int, order SortOrder) ([]*Comment, error)
+	GetBySnippetIDSince(snippetID int, since time.Time) ([]*Comment, error)
+	GetBySnippetIDSinceContext(ctx context.Context, snippetID int, since time.Time) ([]*Comment, error)
+	CountBySnippetID(snippetID int) (int, error)
+	CountBySnippetIDContext(ctx context.Context, snippetID int) (int, error)
+	CountBySnippetIDs(ids []int) (map[int]int, error)
+	CountBySnippetIDsContext(ctx context.Context, ids []int) (map[int]int, error)
 	Get(id int) (*Comment, error)
+	GetContext(ctx context.Context, id int) (*Comment, error)
+	GetByIDs(ids []int) (map[int]*Comment, error)
+	GetByIDsContext(ctx context.Context, ids []int) (map[int]*Comment, error)
 	Update(id int, content string) error
-	Upvote(commentID, userID int) (string, error)
-	Downvote(commentID, userID int) (string, error)
-	Delete(id int) error
+	UpdateContext(ctx context.Context, id int, content string) error
+	UpdateWithVersion(id int, content string, expectedVersion int) error
+	UpdateWithVersionContext(ctx context.Context, id int, content string, expectedVersion int) error
+	Upvote(commentID, userID int) (VoteResult, error)
+	UpvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error)
+	Downvote(commentID, userID int) (VoteResult, error)
+	DownvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error)
+	RecalculateVotes(commentID int) error
+	RecalculateVotesContext(ctx context.Context, commentID int) error
+	Delete(id int, deletedBy int) error
+	DeleteContext(ctx context.Context, id int, deletedBy int) error
+	DeleteBySnippetID(snippetID int) (int, error)
+	DeleteBySnippetIDContext(ctx context.Context, snippetID int) (int, error)
+	VoteTimeline(commentID int) ([]VoteEvent, error)
+	VoteTimelineContext(ctx context.Context, commentID int) ([]VoteEvent, error)
+	SetPinOrder(snippetID int, orderedCommentIDs []int) error
+	SetPinOrderContext(ctx context.Context, snippetID int, orderedCommentIDs []int) error
+	Pin(commentID int) error
+	PinContext(ctx context.Context, commentID int) error
+	Unpin(commentID int) error
+	UnpinContext(ctx context.Context, commentID int) error
+	SimilarTo(commentID int, limit int) ([]*Comment, error)
+	SimilarToContext(ctx context.Context, commentID int, limit int) ([]*Comment, error)
+	CountByStatus() (map[string]int, error)
+	CountByStatusContext(ctx context.Context) (map[string]int, error)
+	AuthorCommentsOnOwner(authorUserID, ownerUserID int) ([]*CommentWithContext, error)
+	AuthorCommentsOnOwnerContext(ctx context.Context, authorUserID, ownerUserID int) ([]*CommentWithContext, error)
+	ByAuthor(authorID, viewerID, page, pageSize int) ([]*CommentWithContext, int, error)
+	ByAuthorContext(ctx context.Context, authorID, viewerID, page, pageSize int) ([]*CommentWithContext, int, error)
+	ForOwnerSince(ownerID int, since time.Time, limit int) ([]*CommentWithContext, error)
+	ForOwnerSinceContext(ctx context.Context, ownerID int, since time.Time, limit int) ([]*CommentWithContext, error)
+	ThreadHealth(snippetID int) (ThreadHealth, error)
+	ThreadHealthContext(ctx context.Context, snippetID int) (ThreadHealth, error)
+	PreviewReplyPlacement(parentID int) (depth int, ancestors []int, atMaxDepth bool, err error)
+	PreviewReplyPlacementContext(ctx context.Context, parentID int) (depth int, ancestors []int, atMaxDepth bool, err error)
+	MostEdited(limit int) ([]*Comment, error)
+	MostEditedContext(ctx context.Context, limit int) ([]*Comment, error)
+	EditableBy(userID int) ([]*Comment, error)
+	EditableByContext(ctx context.Context, userID int) ([]*Comment, error)
+	VoteSpikes(window time.Duration, minVotes int) ([]SpikeAlert, error)
+	VoteSpikesContext(ctx context.Context, window time.Duration, minVotes int) ([]SpikeAlert, error)
+	UnresolvedQuestions(limit, offset int) ([]*CommentWithContext, error)
+	UnresolvedQuestionsContext(ctx context.Context, limit, offset int) ([]*CommentWithContext, error)
+	IncrementViews(id int) error
+	IncrementViewsContext(ctx context.Context, id int) error
+	GetBySnippetIDWithStaff(snippetID int) ([]*Comment, error)
+	GetBySnippetIDWithStaffContext(ctx context.Context, snippetID int) ([]*Comment, error)
+	RecentByOwner(ownerUserID int, perSnippet int) (map[int][]*Comment, error)
+	RecentByOwnerContext(ctx context.Context, ownerUserID int, perSnippet int) (map[int][]*Comment, error)
+	SetModeratorNote(commentID int, note string) error
+	SetModeratorNoteContext(ctx context.Context, commentID int, note string) error
+	RejectMatching(pattern string, reason string) (int, error)
+	RejectMatchingContext(ctx context.Context, pattern string, reason string) (int, error)
+	MatchingIDs(pattern string) ([]int, error)
+	MatchingIDsContext(ctx context.Context, pattern string) ([]int, error)
+	AuthorActivitySpan(authorUserID int) (first, last time.Time, count int, err error)
+	AuthorActivitySpanContext(ctx context.Context, authorUserID int) (first, last time.Time, count int, err error)
+	ChangedForIndex(sinceID int, limit int) ([]*Comment, int, error)
+	ChangedForIndexContext(ctx context.Context, sinceID int, limit int) ([]*Comment, int, error)
+	GetBySnippetIDForViewer(snippetID int, viewerID int) ([]*Comment, error)
+	GetBySnippetIDForViewerContext(ctx context.Context, snippetID int, viewerID int) ([]*Comment, error)
+	GetBySnippetIDForViewerSorted(snippetID int, viewerID int, order SortOrder) ([]*Comment, error)
+	GetBySnippetIDForViewerSortedContext(ctx context.Context, snippetID int, viewerID int, order SortOrder) ([]*Comment, error)
+	GetBySnippetIDForViewerPaged(snippetID int, viewerID int, order SortOrder, page, pageSize int) ([]*Comment, int, error)
+	GetBySnippetIDForViewerPagedContext(ctx context.Context, snippetID int, viewerID int, order SortOrder, page, pageSize int) ([]*Comment, int, error)
+	SetShadowban(authorUserID int, banned bool) error
+	SetShadowbanContext(ctx context.Context, authorUserID int, banned bool) error
+	Shadowbanned(authorUserID int) (bool, error)
+	ShadowbannedContext(ctx context.Context, authorUserID int) (bool, error)
+	ParticipatedThreads(userID int, limit, offset int) ([]ThreadSummary, error)
+	ParticipatedThreadsContext(ctx context.Context, userID int, limit, offset int) ([]ThreadSummary, error)
+	RankDeltas(snippetID int, before map[int]int) ([]RankChange, error)
+	RankDeltasContext(ctx context.Context, snippetID int, before map[int]int) ([]RankChange, error)
+	GetBySnippetIDIncludingDeleted(snippetID int) ([]*Comment, error)
+	GetBySnippetIDIncludingDeletedContext(ctx context.Context, snippetID int) ([]*Comment, error)
+	GetUserVotes(userID int, commentIDs []int) (map[int]string, error)
+	GetUserVotesContext(ctx context.Context, userID int, commentIDs []int) (map[int]string, error)
+	Search(author string, query string, limit int) ([]*Comment, error)
+	SearchContext(ctx context.Context, author string, query string, limit int) ([]*Comment, error)
+	GetRecentByAuthor(author string, limit int) ([]*Comment, error)
+	GetRecentByAuthorContext(ctx context.Context, author string, limit int) ([]*Comment, error)
+	GetMostDiscussedSnippets(since time.Duration, limit int) ([]SnippetCommentCount, error)
+	GetMostDiscussedSnippetsContext(ctx context.Context, since time.Duration, limit int) ([]SnippetCommentCount, error)
+	TopAuthorsBySnippet(snippetID int, limit int) ([]AuthorScore, error)
+	TopAuthorsBySnippetContext(ctx context.Context, snippetID int, limit int) ([]AuthorScore, error)
+	Restore(id int) error
+	RestoreContext(ctx context.Context, id int) error
+	Trash(authorID int) ([]*CommentWithContext, error)
+	TrashContext(ctx context.Context, authorID int) ([]*CommentWithContext, error)
+	PurgeDeleted() (int, error)
+	PurgeDeletedContext(ctx context.Context) (int, error)
+	Report(commentID, reporterID int, reason string) error
+	ReportContext(ctx context.Context, commentID, reporterID int, reason string) error
+	GetReported(minReports int) ([]*Comment, error)
+	GetReportedContext(ctx context.Context, minReports int) ([]*Comment, error)
+	ReportsByCommentIDs(ids []int) (map[int][]*CommentReport, error)
+	ReportsByCommentIDsContext(ctx context.Context, ids []int) (map[int][]*CommentReport, error)
+	DismissReports(commentID int) (int, error)
+	DismissReportsContext(ctx context.Context, commentID int) (int, error)
+	Hide(commentID int, reason string) error
+	HideContext(ctx context.Context, commentID int, reason string) error
+	Count() (int, error)
+	CountContext(ctx context.Context) (int, error)
+	MostVoted(limit int) ([]*Comment, error)
+	MostVotedContext(ctx context.Context, limit int) ([]*Comment, error)
+	DeleteByAuthor(authorID int, deletedBy int) (int, error)
+	DeleteByAuthorContext(ctx context.Context, authorID int, deletedBy int) (int, error)
+	ToggleReaction(commentID, userID int, reaction string) (bool, error)
+	ToggleReactionContext(ctx context.Context, commentID, userID int, reaction string) (bool, error)
+	Hold(commentID int, reason string) error
+	HoldContext(ctx context.Context, commentID int, reason string) error
+	Approve(commentID int) error
+	ApproveContext(ctx context.Context, commentID int) error
+	GetHeld() ([]*Comment, error)
+	GetHeldContext(ctx context.Context) ([]*Comment, error)
 }
 
 // Comment representa um comentário no banco de dados.
 type Comment struct {
 	ID        int
 	SnippetID int
+	ParentID  *int
+	AuthorID  int
 	Author    string
 	Content   string
 	Created   time.Time
 	Updated   time.Time
 	Upvotes   int
+	Downvotes int
+	PinOrder  int
+	// Pinned indica que o comentário está fixado (PinOrder maior que zero).
+	Pinned        bool
+	Status        string
+	StatusNote    string
+	EditCount     int
+	Version       int
+	Locked        bool
+	IsQuestion    bool
+	Accepted      bool
+	Views         int
+	ModeratorNote string
+	IsStaff       bool
+	// Deleted marca um comentário removido por Delete. Nos marcadores que
+	// ChangedForIndex gera para linhas que não existem mais, apenas ID é
+	// válido.
+	Deleted   bool
+	DeletedAt sql.NullTime
+	// Reactions traz uma contagem para cada tipo de ReactionTypes, na mesma
+	// ordem. Só é preenchido por GetBySnippetID, GetBySnippetIDPaginated e
+	// GetBySnippetIDForViewerPaged.
+	Reactions []ReactionCount
+	// Replies só é preenchido por NestReplies.
+	Replies []*Comment
+}
+
+// Hidden informa se a moderação ocultou o comentário. Ele continua na
+// discussão, para não quebrar as respostas, mas sem o conteúdo.
+func (c *Comment) Hidden() bool {
+	return c.Status == StatusRejected
+}
+
+// Held informa se o comentário está retido, esperando a moderação aprová-lo.
+// Até lá, só o autor e a moderação o veem.
+func (c *Comment) Held() bool {
+	return c.Status == StatusPending
+}
+
+// Score retorna o saldo de votos do comentário.
+func (c *Comment) Score() int {
+	return c.Upvotes - c.Downvotes
+}
+
+// ControversyScore mede o quanto o comentário divide opiniões: o total de
+// votos multiplicado pelo equilíbrio entre upvotes e downvotes (a razão entre
+// o menor e o maior). Sem votos de algum dos lados, o resultado é 0.
+func (c *Comment) ControversyScore() float64 {
+	if c.Upvotes <= 0 || c.Downvotes <= 0 {
+		return 0
+	}
+
+	up, down := float64(c.Upvotes), float64(c.Downvotes)
+	magnitude := up + down
+	balance := math.Min(up, down) / math.Max(up, down)
+
+	return magnitude * balance
+}
+
+// Edited informa se o conteúdo do comentário já foi alterado por Update.
+// Comparar Created e Updated não serve: outras alterações, como votos e notas
+// da moderação, também mudam Updated.
+func (c *Comment) Edited() bool {
+	return c.EditCount > 0
+}
+
+// EditWindow é o tempo, contado a partir da criação, durante o qual o autor
+// ainda pode editar um comentário.
+const EditWindow = 15 * time.Minute
+
+// IsEditableBy informa se o usuário pode editar o comentário no instante now:
+// ele precisa ser o autor, o comentário não pode estar bloqueado e a janela de
+// edição ainda deve estar aberta.
+func (c *Comment) IsEditableBy(userID int, now time.Time) bool {
+	if c.AuthorID == 0 || c.AuthorID != userID || c.Locked {
+		return false
+	}
+	return now.Before(c.Created.Add(EditWindow))
+}
+
+// CommentWithContext acompanha um comentário com dados do snippet ao qual
+// ele pertence.
+type CommentWithContext struct {
+	Comment
+	SnippetTitle string
+}
+
+// MaxReplyDepth é a profundidade máxima de uma resposta. Comentários de
+// primeiro nível têm profundidade 0; respostas a um comentário que já está
+// na profundidade máxima são colocadas ao lado dele, sob o mesmo pai.
+const MaxReplyDepth = 1
+
+// Estados de moderação de um comentário.
+const (
+	StatusApproved = "approved"
+	StatusPending  = "pending"
+	StatusRejected = "rejected"
+)
+
+// commentStatuses lista todos os estados de moderação possíveis.
+var commentStatuses = []string{StatusApproved, StatusPending, StatusRejected}
+
+// commentColumns são as colunas lidas por scanComment, na mesma ordem.
+const commentColumns = `c.id, c.snippet_id, COALESCE(c.author_id, 0), c.author, c.content, c.created,
+	c.updated, c.upvotes, c.downvotes, COALESCE(c.pin_order, 0), c.pin_order IS NOT NULL, c.status, COALESCE(c.status_note, ''), c.edit_count, c.locked, c.is_question,
+	c.accepted, c.views, COALESCE(c.moderator_note, ''), c.deleted, c.deleted_at, c.parent_id, c.version`
+
+// threadJoin junta a cada comentário, com o alias r, o comentário de primeiro
+// nível da sua discussão (ele mesmo, se não for uma resposta).
+const threadJoin = `LEFT JOIN comments r ON r.id = COALESCE(c.parent_id, c.id)`
+
+// SortOrder é a ordem em que as discussões de um snippet são listadas.
+type SortOrder string
+
+// Ordens aceitas por GetBySnippetIDSorted.
+const (
+	SortOldest SortOrder = "oldest"
+	SortNewest SortOrder = "newest"
+	SortTop    SortOrder = "top"
+	// SortControversial lista primeiro as discussões com muitos votos e
+	// equilíbrio entre upvotes e downvotes (ver Comment.ControversyScore).
+	SortControversial SortOrder = "controversial"
+)
+
+// threadOrderBy monta a cláusula ORDER BY para order a partir de uma lista
+// fixa de opções; o valor de order nunca entra na consulta. Comentários
+// fixados vêm sempre primeiro e as respostas logo depois do pai. Requer
+// threadJoin.
+func threadOrderBy(order SortOrder) (string, error) {
+	var roots string
+	switch order {
+	case SortOldest:
+		return threadOrder, nil
+	case SortNewest:
+		roots = `r.created DESC, r.id DESC`
+	case SortTop:
+		roots = `r.upvotes - r.downvotes DESC, r.created ASC, r.id ASC`
+	case SortControversial:
+		roots = `CASE WHEN r.upvotes > 0 AND r.downvotes > 0
+		           THEN (r.upvotes + r.downvotes) * LEAST(r.upvotes, r.downvotes) / GREATEST(r.upvotes, r.downvotes)
+		           ELSE 0 END DESC, r.created ASC, r.id ASC`
+	default:
+		return "", ErrInvalidSortOrder
+	}
+	return `r.pin_order IS NULL, r.pin_order ASC, ` + roots + `,
+	c.parent_id IS NOT NULL, c.created ASC, c.id ASC`, nil
+}
+
+// threadOrder ordena as discussões pelo comentário de primeiro nível (fixados
+// primeiro) e coloca as respostas logo depois do seu pai. Requer threadJoin.
+const threadOrder = `r.pin_order IS NULL, r.pin_order ASC, r.created ASC, r.id ASC,
+	c.parent_id IS NOT NULL, c.created ASC, c.id ASC`
+
+// rowScanner é satisfeito tanto por *sql.Row quanto por *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanComment lê uma linha selecionada com commentColumns sobre a tabela
+// comments com o alias c. Colunas adicionais selecionadas depois de
+// commentColumns são lidas em extra.
+func scanComment(row rowScanner, extra ...any) (*Comment, error) {
+	c := &Comment{}
+	var parentID sql.NullInt64
+	dest := []any{&c.ID, &c.SnippetID, &c.AuthorID, &c.Author, &c.Content, &c.Created,
+		&c.Updated, &c.Upvotes, &c.Downvotes, &c.PinOrder, &c.Pinned, &c.Status, &c.StatusNote, &c.EditCount, &c.Locked, &c.IsQuestion,
+		&c.Accepted, &c.Views, &c.ModeratorNote, &c.Deleted, &c.DeletedAt, &parentID, &c.Version}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
+
+// VoteEvent representa um voto registrado em um comentário.
+type VoteEvent struct {
+	Type    string
+	Created time.Time
+}
+
+// VoteAction é o efeito de um Upvote ou Downvote sobre o voto do usuário.
+type VoteAction int
+
+const (
+	// ActionAdded indica um voto novo.
+	ActionAdded VoteAction = iota
+	// ActionRemoved indica que o usuário repetiu o voto e ele foi retirado.
+	ActionRemoved
+	// ActionChanged indica que o voto trocou de upvote para downvote ou o
+	// contrário.
+	ActionChanged
+)
+
+// VoteResult descreve o resultado de um Upvote ou Downvote.
+type VoteResult struct {
+	Action VoteAction
+	// Type é o tipo de voto pedido: "upvote" ou "downvote".
+	Type  string
+	Score int
+}
+
+// String retorna a mensagem exibida ao usuário para o resultado.
+func (r VoteResult) String() string {
+	switch r.Action {
+	case ActionRemoved:
+		return "Vote removed!"
+	case ActionChanged:
+		return "Vote updated to " + r.Type + "!"
+	default:
+		return "Vote successfully registered!"
+	}
+}
+
+// Reaction é um tipo de reação que os usuários podem deixar em um comentário.
+// Name é o que fica gravado no banco; Emoji é o que se mostra.
+type Reaction struct {
+	Name  string
+	Emoji string
+}
+
+// ReactionTypes lista as reações aceitas por ToggleReaction, na ordem em que
+// são exibidas.
+var ReactionTypes = []Reaction{
+	{Name: "thumbsup", Emoji: "👍"},
+	{Name: "heart", Emoji: "❤️"},
+	{Name: "smile", Emoji: "😄"},
+	{Name: "tada", Emoji: "🎉"},
+}
+
+// ReactionCount é o total de um tipo de reação em um comentário. Mine indica
+// que o usuário que está vendo a discussão deixou essa reação.
+type ReactionCount struct {
+	Reaction
+	Count int
+	Mine  bool
+}
+
+// validReaction informa se name é um dos tipos de ReactionTypes.
+func validReaction(name string) bool {
+	for _, r := range ReactionTypes {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ThreadHealth reúne os indicadores de saúde da discussão de um snippet.
+// As taxas variam entre 0 e 1 e Score vai de 0 (discussão problemática) a
+// 1 (discussão saudável).
+type ThreadHealth struct {
+	SnippetID     int
+	Comments      int
+	Votes         int
+	DownvoteRatio float64
+	ReportDensity float64
+	DeletionRate  float64
+	Score         float64
+}
+
+// HealthWeights define o peso de cada indicador no cálculo de ThreadHealth.Score.
+type HealthWeights struct {
+	Downvotes float64
+	Reports   float64
+	Deletions float64
+}
+
+// DefaultHealthWeights é usado quando CommentModel.HealthWeights não é definido.
+var DefaultHealthWeights = HealthWeights{Downvotes: 1, Reports: 2, Deletions: 1}
+
+// SpikeAlert aponta um comentário que recebeu muitos votos em pouco tempo.
+type SpikeAlert struct {
+	CommentID int
+	SnippetID int
+	Votes     int
 }
 
 // CommentModel encapsula uma pool de conexões sql.DB.
 type CommentModel struct {
-	DB *sql.DB
+	DB            *sql.DB
+	HealthWeights HealthWeights
+	// SnippetOverlapThreshold, quando maior que zero, faz Insert rejeitar com
+	// ErrDuplicatesSnippet comentários cuja proporção de palavras repetidas do
+	// snippet for igual ou maior que o valor (entre 0 e 1).
+	SnippetOverlapThreshold float64
+	// MinInterval, quando maior que zero, faz Insert e InsertReply rejeitarem
+	// com ErrTooSoon um comentário do mesmo autor feito antes desse intervalo
+	// desde o último.
+	MinInterval time.Duration
+	// MinScore, quando não for nil, é o piso do saldo (upvotes - downvotes)
+	// de um comentário. Um downvote que deixaria o saldo abaixo do piso é
+	// registrado em comment_votes com counted = FALSE, mas não altera o
+	// contador de downvotes; remover ou trocar esse voto depois também não
+	// mexe no contador. O padrão (nil) não limita o saldo.
+	MinScore *int
+}
+
+// MaxCommentLength é o tamanho máximo do conteúdo de um comentário, em runas:
+// o que cabe numa coluna TEXT (65.535 bytes) com até 4 bytes por runa. O
+// servidor web impõe um limite menor, configurável.
+const MaxCommentLength = 16383
+
+// validateContent retorna ErrCommentEmpty para conteúdo vazio ou só com
+// espaços e ErrCommentTooLong para conteúdo com mais de MaxCommentLength
+// runas.
+func validateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrCommentEmpty
+	}
+	if utf8.RuneCountInString(content) > MaxCommentLength {
+		return ErrCommentTooLong
+	}
+	return nil
 }
 
 // Insert insere um novo comentário no banco de dados.
-func (m *CommentModel) Insert(snippetID int, author string, content string) (int, error) {
-	stmt := `INSERT INTO comments (snippet_id, content, author, created, updated, upvotes)
-	         VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), 0)`
+//
+// Insert usa context.Background(); para informar um contexto, use
+// InsertContext.
+func (m *CommentModel) Insert(snippetID int, authorID int, author string, content string) (int, error) {
+	return m.InsertContext(context.Background(), snippetID, authorID, author, content)
+}
 
-	result, err := m.DB.Exec(stmt, snippetID, author, content)
+// InsertContext é como Insert, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	err := validateContent(content)
+	if err != nil {
+		return 0, err
+	}
+
+	err = m.checkInterval(ctx, author)
+	if err != nil {
+		return 0, err
+	}
+
+	if m.SnippetOverlapThreshold > 0 {
+		var snippetContent string
+		err := m.DB.QueryRowContext(ctx, `SELECT content FROM snippets WHERE id = ?`, snippetID).Scan(&snippetContent)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, ErrNoRecord
+			}
+			return 0, err
+		}
+
+		if contentOverlap(content, snippetContent) >= m.SnippetOverlapThreshold {
+			return 0, ErrDuplicatesSnippet
+		}
+	}
+
+	stmt := `INSERT INTO comments (snippet_id, author_id, content, author, created, updated, upvotes, downvotes)
+	         VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), 0, 0)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, snippetID, authorID, content, author)
 	if err != nil {
 		return 0, err
 	}
@@ -50,173 +538,3451 @@ func (m *CommentModel) Insert(snippetID int, author string, content string) (int
 	return int(id), nil
 }
 
-// GetBySnippetID retorna todos os comentários associados a um snippet específico.
-func (m *CommentModel) GetBySnippetID(snippetID int) ([]*Comment, error) {
-	stmt := `SELECT id, snippet_id, author, content, created, updated, upvotes 
-	         FROM comments WHERE snippet_id = ? ORDER BY created ASC`
+// checkInterval retorna ErrTooSoon se o autor comentou há menos de
+// MinInterval. Não faz nada quando MinInterval não é definido.
+func (m *CommentModel) checkInterval(ctx context.Context, author string) error {
+	if m.MinInterval <= 0 {
+		return nil
+	}
+
+	stmt := `SELECT COALESCE(MAX(created) > UTC_TIMESTAMP() - INTERVAL ? MICROSECOND, FALSE)
+	         FROM comments WHERE author = ?`
 
-	rows, err := m.DB.Query(stmt, snippetID)
+	var tooSoon bool
+	err := m.DB.QueryRowContext(ctx, stmt, m.MinInterval.Microseconds(), author).Scan(&tooSoon)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	comments := []*Comment{}
+	if tooSoon {
+		return ErrTooSoon
+	}
 
-	for rows.Next() {
-		c := &Comment{}
-		err = rows.Scan(&c.ID, &c.SnippetID, &c.Author, &c.Content, &c.Created, &c.Updated, &c.Upvotes)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, c)
+	return nil
+}
+
+// InsertReply insere uma resposta ao comentário parentID. O pai precisa
+// existir, não ter sido removido e pertencer ao mesmo snippet; caso
+// contrário retorna ErrNoRecord. Respostas a uma resposta ficam sob o mesmo
+// comentário de primeiro nível, respeitando MaxReplyDepth.
+//
+// InsertReply usa context.Background(); para informar um contexto, use
+// InsertReplyContext.
+func (m *CommentModel) InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return m.InsertReplyContext(context.Background(), snippetID, parentID, authorID, author, content)
+}
+
+// InsertReplyContext é como InsertReply, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	err := validateContent(content)
+	if err != nil {
+		return 0, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	err = m.checkInterval(ctx, author)
+	if err != nil {
+		return 0, err
 	}
 
-	return comments, nil
-}
+	var rootID int
+	stmt := `SELECT COALESCE(parent_id, id) FROM comments
+	         WHERE id = ? AND snippet_id = ? AND deleted = FALSE`
+	err = m.DB.QueryRowContext(ctx, stmt, parentID, snippetID).Scan(&rootID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
 
-// Update atualiza o conteúdo de um comentário existente.
-func (m *CommentModel) Update(id int, content string) error {
-	stmt := `UPDATE comments SET content = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+	stmt = `INSERT INTO comments (snippet_id, parent_id, author_id, content, author, created, updated, upvotes, downvotes)
+	        VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), 0, 0)`
 
-	_, err := m.DB.Exec(stmt, content, id)
+	result, err := m.DB.ExecContext(ctx, stmt, snippetID, rootID, authorID, content, author)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
-// Upvote altera o número de votos de um comentário.
-func (m *CommentModel) Upvote(commentID int, userID int) (string, error) {
-	// Verifica o tipo de voto do usuário
-	var voteType string
-	err := m.DB.QueryRow(`SELECT vote_type FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID).Scan(&voteType)
-	if err != nil && err != sql.ErrNoRows {
-		return "", err
+// NestReplies organiza uma lista plana de comentários, como a retornada por
+// GetBySnippetID, em uma árvore: cada comentário de primeiro nível recebe suas
+// respostas em Replies, na ordem da lista. Respostas cujo pai não está na
+// lista aparecem no primeiro nível.
+func NestReplies(flat []*Comment) []*Comment {
+	byID := make(map[int]*Comment, len(flat))
+	for _, c := range flat {
+		c.Replies = nil
+		byID[c.ID] = c
 	}
 
-	switch voteType {
-	case "upvote":
-		// Remove o upvote
-		_, err = m.DB.Exec(`DELETE FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID)
-		if err != nil {
-			return "", err
-		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes - 1 WHERE id = ?`, commentID)
-		if err != nil {
-			return "", err
-		}
-		return "Vote removed!", nil
-	case "downvote":
-		// Atualiza o voto para upvote
-		_, err = m.DB.Exec(`UPDATE comment_votes SET vote_type = 'upvote' WHERE comment_id = ? AND user_id = ?`, commentID, userID)
-		if err != nil {
-			return "", err
-		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes + 2 WHERE id = ?`, commentID)
-		if err != nil {
-			return "", err
-		}
-		return "Vote updated to upvote!", nil
-	default:
-		// Adiciona o upvote
-		_, err = m.DB.Exec(`INSERT INTO comment_votes (comment_id, user_id, vote_type) VALUES (?, ?, 'upvote')`, commentID, userID)
-		if err != nil {
-			return "", err
-		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes + 1 WHERE id = ?`, commentID)
-		if err != nil {
-			return "", err
+	roots := []*Comment{}
+	for _, c := range flat {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok && parent != c {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
 		}
-		return "Vote successfully registered!", nil
+		roots = append(roots, c)
 	}
+
+	return roots
 }
 
-// Downvote altera o número de votos de um comentário.
-func (m *CommentModel) Downvote(commentID int, userID int) (string, error) {
-	// Verifica o tipo de voto do usuário
-	var voteType string
-	err := m.DB.QueryRow(`SELECT vote_type FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID).Scan(&voteType)
-	if err != nil && err != sql.ErrNoRows {
-		return "", err
+// MaxBatchSize é o número máximo de comentários aceito por InsertBatch.
+const MaxBatchSize = 500
+
+// CommentInput é um comentário a ser importado por InsertBatch. Um Created
+// zerado usa a data atual.
+type CommentInput struct {
+	SnippetID int
+	AuthorID  int
+	Author    string
+	Content   string
+	Created   time.Time
+}
+
+// InsertBatch insere vários comentários dentro de uma única transação e
+// retorna os IDs na mesma ordem da entrada. Lotes com mais de MaxBatchSize
+// comentários retornam ErrBatchTooLarge; o conteúdo de cada comentário é
+// validado como em Insert antes de qualquer inserção. As linhas são inseridas
+// uma a uma, já que o InnoDB não garante IDs consecutivos para um INSERT de
+// várias linhas com innodb_autoinc_lock_mode=2, o padrão do MySQL 8.
+//
+// InsertBatch usa context.Background(); para informar um contexto, use
+// InsertBatchContext.
+func (m *CommentModel) InsertBatch(comments []CommentInput) ([]int, error) {
+	return m.InsertBatchContext(context.Background(), comments)
+}
+
+// InsertBatchContext é como InsertBatch, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) InsertBatchContext(ctx context.Context, comments []CommentInput) ([]int, error) {
+	ids := []int{}
+	if len(comments) == 0 {
+		return ids, nil
+	}
+	if len(comments) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
 	}
 
-	switch voteType {
-	case "downvote":
-		// Remove o downvote
-		_, err = m.DB.Exec(`DELETE FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID)
-		if err != nil {
-			return "", err
-		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes + 1 WHERE id = ?`, commentID)
-		if err != nil {
-			return "", err
-		}
-		return "Vote removed!", nil
-	case "upvote":
-		// Atualiza o voto para downvote
-		_, err = m.DB.Exec(`UPDATE comment_votes SET vote_type = 'downvote' WHERE comment_id = ? AND user_id = ?`, commentID, userID)
+	for _, c := range comments {
+		err := validateContent(c.Content)
 		if err != nil {
-			return "", err
-		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes - 2 WHERE id = ?`, commentID)
-		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return "Vote updated to downvote!", nil
-	default:
-		// Adiciona o downvote
-		_, err = m.DB.Exec(`INSERT INTO comment_votes (comment_id, user_id, vote_type) VALUES (?, ?, 'downvote')`, commentID, userID)
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO comments (snippet_id, author_id, content, author, created, updated, upvotes, downvotes)
+	         VALUES(?, ?, ?, ?, COALESCE(?, UTC_TIMESTAMP()), COALESCE(?, UTC_TIMESTAMP()), 0, 0)`
+
+	for _, c := range comments {
+		created := sql.NullTime{Time: c.Created.UTC(), Valid: !c.Created.IsZero()}
+
+		result, err := tx.ExecContext(ctx, stmt, c.SnippetID, c.AuthorID, c.Content, c.Author, created, created)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		// Atualiza o número de upvotes
-		_, err = m.DB.Exec(`UPDATE comments SET upvotes = upvotes - 1 WHERE id = ?`, commentID)
+
+		id, err := result.LastInsertId()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return "Vote successfully registered!", nil
+		ids = append(ids, int(id))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
 	}
+
+	return ids, nil
 }
 
+// GetBySnippetID retorna todos os comentários associados a um snippet
+// específico como vistos por um visitante: sem os removidos, os retidos para
+// moderação e os de autores com shadowban. Para mostrá-los ao próprio autor e
+// aos moderadores, use GetBySnippetIDForViewer. Para listas longas, use
+// GetBySnippetIDPaginated.
+//
+// GetBySnippetID usa context.Background(); para informar um contexto, use
+// GetBySnippetIDContext.
+func (m *CommentModel) GetBySnippetID(snippetID int) ([]*Comment, error) {
+	return m.GetBySnippetIDContext(context.Background(), snippetID)
+}
 
-// Delete remove um comentário do banco de dados.
-func (m *CommentModel) Delete(id int) error {
-	stmt := `DELETE FROM comments WHERE id = ?`
+// GetBySnippetIDContext é como GetBySnippetID, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDContext(ctx context.Context, snippetID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c ` + viewerJoin + ` ` + threadJoin + `
+	         WHERE c.snippet_id = ? AND c.deleted = FALSE AND c.status <> 'pending'
+	           AND (u.shadowbanned IS NULL OR u.shadowbanned = FALSE)
+	         ORDER BY ` + threadOrder
 
-	_, err := m.DB.Exec(stmt, id)
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadReactions(ctx, comments, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
 }
 
-// Get retorna um comentário específico pelo seu ID.
-func (m *CommentModel) Get(id int) (*Comment, error) {
-	stmt := `SELECT id, snippet_id, author, content, created, updated, upvotes 
-	         FROM comments WHERE id = ?`
+// GetBySnippetIDPaginated retorna uma página de comentários de um snippet,
+// sem os removidos e os retidos para moderação, junto com o total de comentários visíveis. O total vem na
+// mesma consulta com COUNT(*) OVER (); só quando a página sai vazia é feita
+// uma contagem separada. Retorna ErrInvalidPagination para limit ou offset
+// negativos.
+//
+// GetBySnippetIDPaginated usa context.Background(); para informar um contexto,
+// use GetBySnippetIDPaginatedContext.
+func (m *CommentModel) GetBySnippetIDPaginated(snippetID, limit, offset int) ([]*Comment, int, error) {
+	return m.GetBySnippetIDPaginatedContext(context.Background(), snippetID, limit, offset)
+}
 
-	c := &Comment{}
+// GetBySnippetIDPaginatedContext é como GetBySnippetIDPaginated, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDPaginatedContext(ctx context.Context, snippetID, limit, offset int) ([]*Comment, int, error) {
+	if limit < 0 || offset < 0 {
+		return nil, 0, ErrInvalidPagination
+	}
 
-	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.SnippetID, &c.Author, &c.Content, &c.Created, &c.Updated, &c.Upvotes)
+	stmt := `SELECT ` + commentColumns + `, COUNT(*) OVER ()
+	         FROM comments c ` + threadJoin + `
+	         WHERE c.snippet_id = ? AND c.deleted = FALSE AND c.status <> 'pending'
+	         ORDER BY ` + threadOrder + `
+	         LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID, limit, offset)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		} else {
-			return nil, err
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	total := 0
+
+	for rows.Next() {
+		c, err := scanComment(rows, &total)
+		if err != nil {
+			return nil, 0, err
 		}
+		comments = append(comments, c)
 	}
 
-	return c, nil
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(comments) == 0 {
+		stmt = `SELECT COUNT(*) FROM comments WHERE snippet_id = ? AND deleted = FALSE AND status <> 'pending'`
+		err = m.DB.QueryRowContext(ctx, stmt, snippetID).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	err = m.loadReactions(ctx, comments, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// GetBySnippetIDPaged é como GetBySnippetIDPaginated, mas recebe o número da
+// página (a partir de 1) e o tamanho dela. Retorna ErrInvalidPagination para
+// page ou pageSize menores que 1.
+//
+// GetBySnippetIDPaged usa context.Background(); para informar um contexto, use
+// GetBySnippetIDPagedContext.
+func (m *CommentModel) GetBySnippetIDPaged(snippetID, page, pageSize int) ([]*Comment, int, error) {
+	return m.GetBySnippetIDPagedContext(context.Background(), snippetID, page, pageSize)
+}
+
+// GetBySnippetIDPagedContext é como GetBySnippetIDPaged, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDPagedContext(ctx context.Context, snippetID, page, pageSize int) ([]*Comment, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	return m.GetBySnippetIDPaginatedContext(ctx, snippetID, pageSize, (page-1)*pageSize)
+}
+
+// GetBySnippetIDSorted retorna os comentários visíveis de um snippet na
+// ordem pedida, sem os removidos e os retidos para moderação. Retorna ErrInvalidSortOrder para uma ordem desconhecida.
+//
+// GetBySnippetIDSorted usa context.Background(); para informar um contexto,
+// use GetBySnippetIDSortedContext.
+func (m *CommentModel) GetBySnippetIDSorted(snippetID int, order SortOrder) ([]*Comment, error) {
+	return m.GetBySnippetIDSortedContext(context.Background(), snippetID, order)
+}
+
+// GetBySnippetIDSortedContext é como GetBySnippetIDSorted, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDSortedContext(ctx context.Context, snippetID int, order SortOrder) ([]*Comment, error) {
+	orderBy, err := threadOrderBy(order)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c ` + threadJoin + `
+	         WHERE c.snippet_id = ? AND c.deleted = FALSE AND c.status <> 'pending'
+	         ORDER BY ` + orderBy
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetBySnippetIDSince retorna os comentários visíveis de um snippet criados
+// depois de since (exclusive), sem os removidos e os retidos para moderação. As datas são gravadas em UTC, então since é
+// convertido para UTC antes da comparação. Um since zerado equivale a
+// GetBySnippetID.
+//
+// GetBySnippetIDSince usa context.Background(); para informar um contexto, use
+// GetBySnippetIDSinceContext.
+func (m *CommentModel) GetBySnippetIDSince(snippetID int, since time.Time) ([]*Comment, error) {
+	return m.GetBySnippetIDSinceContext(context.Background(), snippetID, since)
+}
+
+// GetBySnippetIDSinceContext é como GetBySnippetIDSince, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDSinceContext(ctx context.Context, snippetID int, since time.Time) ([]*Comment, error) {
+	if since.IsZero() {
+		return m.GetBySnippetIDContext(ctx, snippetID)
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c ` + threadJoin + `
+	         WHERE c.snippet_id = ? AND c.deleted = FALSE AND c.status <> 'pending' AND c.created > ?
+	         ORDER BY ` + threadOrder
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CountBySnippetID retorna quantos comentários visíveis um snippet tem, sem
+// os removidos e os retidos para moderação, como em GetBySnippetID.
+//
+// CountBySnippetID usa context.Background(); para informar um contexto, use
+// CountBySnippetIDContext.
+func (m *CommentModel) CountBySnippetID(snippetID int) (int, error) {
+	return m.CountBySnippetIDContext(context.Background(), snippetID)
+}
+
+// CountBySnippetIDContext é como CountBySnippetID, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) CountBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM comments WHERE snippet_id = ? AND deleted = FALSE AND status <> 'pending'`
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, stmt, snippetID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountBySnippetIDs retorna, em uma única consulta, quantos comentários
+// visíveis cada snippet informado tem, contados como em CountBySnippetID.
+// Snippets sem comentários aparecem no mapa com zero.
+//
+// CountBySnippetIDs usa context.Background(); para informar um contexto, use
+// CountBySnippetIDsContext.
+func (m *CommentModel) CountBySnippetIDs(ids []int) (map[int]int, error) {
+	return m.CountBySnippetIDsContext(context.Background(), ids)
+}
+
+// CountBySnippetIDsContext é como CountBySnippetIDs, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) CountBySnippetIDsContext(ctx context.Context, ids []int) (map[int]int, error) {
+	counts := make(map[int]int, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		counts[id] = 0
+		args[i] = id
+	}
+
+	stmt := `SELECT snippet_id, COUNT(*) FROM comments
+	         WHERE deleted = FALSE AND status <> 'pending' AND snippet_id IN (` + placeholders(len(ids)) + `)
+	         GROUP BY snippet_id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, count int
+		err = rows.Scan(&id, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Update atualiza o conteúdo de um comentário existente. Retorna
+// ErrNoRecord se o comentário não existir.
+//
+// Update usa context.Background(); para informar um contexto, use
+// UpdateContext.
+func (m *CommentModel) Update(id int, content string) error {
+	return m.UpdateContext(context.Background(), id, content)
+}
+
+// UpdateContext é como Update, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) UpdateContext(ctx context.Context, id int, content string) error {
+	err := validateContent(content)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE comments SET content = ?, updated = UTC_TIMESTAMP(), edit_count = edit_count + 1,
+	         version = version + 1
+	         WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, content, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// UpdateWithVersion atualiza o conteúdo de um comentário apenas se a versão
+// gravada ainda for expectedVersion, evitando que uma edição sobrescreva
+// outra feita ao mesmo tempo. Retorna ErrStaleVersion se a versão mudou e
+// ErrNoRecord se o comentário não existir.
+//
+// UpdateWithVersion usa context.Background(); para informar um contexto, use
+// UpdateWithVersionContext.
+func (m *CommentModel) UpdateWithVersion(id int, content string, expectedVersion int) error {
+	return m.UpdateWithVersionContext(context.Background(), id, content, expectedVersion)
+}
+
+// UpdateWithVersionContext é como UpdateWithVersion, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) UpdateWithVersionContext(ctx context.Context, id int, content string, expectedVersion int) error {
+	err := validateContent(content)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE comments SET content = ?, updated = UTC_TIMESTAMP(), edit_count = edit_count + 1,
+	         version = version + 1
+	         WHERE id = ? AND version = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, content, id, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		var exists bool
+		err = m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ?)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+		return ErrStaleVersion
+	}
+
+	return nil
+}
+
+// Upvote altera o número de votos de um comentário e retorna o que foi feito
+// com o voto do usuário e o novo saldo do comentário. O registro do voto e a
+// contagem no comentário são alterados na mesma transação.
+// Retorna ErrSelfVote se o usuário for o autor do comentário.
+//
+// Upvote usa context.Background(); para informar um contexto, use
+// UpvoteContext.
+func (m *CommentModel) Upvote(commentID int, userID int) (VoteResult, error) {
+	return m.UpvoteContext(context.Background(), commentID, userID)
+}
+
+// UpvoteContext é como Upvote, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) UpvoteContext(ctx context.Context, commentID int, userID int) (VoteResult, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	defer tx.Rollback()
+
+	err = checkVoter(ctx, tx, commentID, userID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	// Verifica o tipo de voto do usuário
+	var voteType string
+	var counted bool
+	err = tx.QueryRowContext(ctx, `SELECT vote_type, counted FROM comment_votes WHERE comment_id = ? AND user_id = ? FOR UPDATE`, commentID, userID).Scan(&voteType, &counted)
+	if err != nil && err != sql.ErrNoRows {
+		return VoteResult{}, err
+	}
+
+	var action VoteAction
+
+	switch voteType {
+	case "upvote":
+		// Remove o upvote
+		_, err = tx.ExecContext(ctx, `DELETE FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Atualiza o número de upvotes
+		_, err = tx.ExecContext(ctx, `UPDATE comments SET upvotes = upvotes - 1 WHERE id = ?`, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		action = ActionRemoved
+	case "downvote":
+		// Atualiza o voto para upvote
+		_, err = tx.ExecContext(ctx, `UPDATE comment_votes SET vote_type = 'upvote', counted = TRUE, created = UTC_TIMESTAMP() WHERE comment_id = ? AND user_id = ?`, commentID, userID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Move o voto de downvotes para upvotes; um downvote que não foi
+		// contado por causa de MinScore não sai do contador
+		stmt := `UPDATE comments SET upvotes = upvotes + 1 WHERE id = ?`
+		if counted {
+			stmt = `UPDATE comments SET upvotes = upvotes + 1, downvotes = downvotes - 1 WHERE id = ?`
+		}
+		_, err = tx.ExecContext(ctx, stmt, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		action = ActionChanged
+	default:
+		// Adiciona o upvote
+		_, err = tx.ExecContext(ctx, `INSERT INTO comment_votes (comment_id, user_id, vote_type, created) VALUES (?, ?, 'upvote', UTC_TIMESTAMP())`, commentID, userID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Atualiza o número de upvotes
+		_, err = tx.ExecContext(ctx, `UPDATE comments SET upvotes = upvotes + 1 WHERE id = ?`, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		action = ActionAdded
+	}
+
+	score, err := voteScore(ctx, tx, commentID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	return VoteResult{Action: action, Type: "upvote", Score: score}, nil
+}
+
+// Downvote altera o número de votos de um comentário e retorna o que foi
+// feito com o voto do usuário e o novo saldo do comentário. O registro do voto e a
+// contagem no comentário são alterados na mesma transação.
+// Retorna ErrSelfVote se o usuário for o autor do comentário.
+//
+// Downvote usa context.Background(); para informar um contexto, use
+// DownvoteContext.
+func (m *CommentModel) Downvote(commentID int, userID int) (VoteResult, error) {
+	return m.DownvoteContext(context.Background(), commentID, userID)
+}
+
+// DownvoteContext é como Downvote, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) DownvoteContext(ctx context.Context, commentID int, userID int) (VoteResult, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	defer tx.Rollback()
+
+	err = checkVoter(ctx, tx, commentID, userID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	// Verifica o tipo de voto do usuário
+	var voteType string
+	var counted bool
+	err = tx.QueryRowContext(ctx, `SELECT vote_type, counted FROM comment_votes WHERE comment_id = ? AND user_id = ? FOR UPDATE`, commentID, userID).Scan(&voteType, &counted)
+	if err != nil && err != sql.ErrNoRows {
+		return VoteResult{}, err
+	}
+
+	var action VoteAction
+
+	switch voteType {
+	case "downvote":
+		// Remove o downvote
+		_, err = tx.ExecContext(ctx, `DELETE FROM comment_votes WHERE comment_id = ? AND user_id = ?`, commentID, userID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Atualiza o número de downvotes, se o voto tiver sido contado
+		if counted {
+			_, err = tx.ExecContext(ctx, `UPDATE comments SET downvotes = downvotes - 1 WHERE id = ?`, commentID)
+			if err != nil {
+				return VoteResult{}, err
+			}
+		}
+		action = ActionRemoved
+	case "upvote":
+		// Remove o upvote do contador antes de conferir o piso
+		_, err = tx.ExecContext(ctx, `UPDATE comments SET upvotes = upvotes - 1 WHERE id = ?`, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		counted, err = m.countDownvote(ctx, tx, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Atualiza o voto para downvote
+		_, err = tx.ExecContext(ctx, `UPDATE comment_votes SET vote_type = 'downvote', counted = ?, created = UTC_TIMESTAMP() WHERE comment_id = ? AND user_id = ?`, counted, commentID, userID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		if counted {
+			_, err = tx.ExecContext(ctx, `UPDATE comments SET downvotes = downvotes + 1 WHERE id = ?`, commentID)
+			if err != nil {
+				return VoteResult{}, err
+			}
+		}
+		action = ActionChanged
+	default:
+		counted, err = m.countDownvote(ctx, tx, commentID)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Adiciona o downvote
+		_, err = tx.ExecContext(ctx, `INSERT INTO comment_votes (comment_id, user_id, vote_type, counted, created) VALUES (?, ?, 'downvote', ?, UTC_TIMESTAMP())`, commentID, userID, counted)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		// Atualiza o número de downvotes, se o piso permitir
+		if counted {
+			_, err = tx.ExecContext(ctx, `UPDATE comments SET downvotes = downvotes + 1 WHERE id = ?`, commentID)
+			if err != nil {
+				return VoteResult{}, err
+			}
+		}
+		action = ActionAdded
+	}
+
+	score, err := voteScore(ctx, tx, commentID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	return VoteResult{Action: action, Type: "downvote", Score: score}, nil
+}
+
+// RecalculateVotes refaz os contadores upvotes e downvotes de um comentário a
+// partir de comment_votes, para reparar contagens que tenham se desencontrado.
+// Downvotes que não foram contados por causa de MinScore continuam fora do
+// contador. Retorna ErrNoRecord se o comentário não existir.
+//
+// RecalculateVotes usa context.Background(); para informar um contexto, use
+// RecalculateVotesContext.
+func (m *CommentModel) RecalculateVotes(commentID int) error {
+	return m.RecalculateVotesContext(context.Background(), commentID)
+}
+
+// RecalculateVotesContext é como RecalculateVotes, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RecalculateVotesContext(ctx context.Context, commentID int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Bloqueia o comentário para que nenhum voto entre durante a recontagem
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM comments WHERE id = ? FOR UPDATE`, commentID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	stmt := `UPDATE comments SET
+		upvotes = (SELECT COUNT(*) FROM comment_votes WHERE comment_id = ? AND vote_type = 'upvote'),
+		downvotes = (SELECT COUNT(*) FROM comment_votes WHERE comment_id = ? AND vote_type = 'downvote' AND counted)
+	WHERE id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, commentID, commentID, commentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecalculateAllVotes é como RecalculateVotes, mas para todos os
+// comentários de uma vez, e retorna quantos estavam com a contagem errada.
+//
+// RecalculateAllVotes usa context.Background(); para informar um contexto,
+// use RecalculateAllVotesContext.
+func (m *CommentModel) RecalculateAllVotes() (int, error) {
+	return m.RecalculateAllVotesContext(context.Background())
+}
+
+// RecalculateAllVotesContext é como RecalculateAllVotes, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RecalculateAllVotesContext(ctx context.Context) (int, error) {
+	stmt := `UPDATE comments c
+	LEFT JOIN (SELECT comment_id, SUM(vote_type = 'upvote') AS up, SUM(vote_type = 'downvote' AND counted) AS down
+	           FROM comment_votes GROUP BY comment_id) v ON v.comment_id = c.id
+	SET c.upvotes = COALESCE(v.up, 0), c.downvotes = COALESCE(v.down, 0)
+	WHERE NOT (c.upvotes <=> COALESCE(v.up, 0)) OR c.downvotes <> COALESCE(v.down, 0)`
+
+	result, err := m.DB.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// countDownvote informa se um novo downvote pode entrar no contador do
+// comentário sem deixar o saldo abaixo de MinScore. Sem MinScore, sempre
+// retorna true.
+func (m *CommentModel) countDownvote(ctx context.Context, tx *sql.Tx, commentID int) (bool, error) {
+	if m.MinScore == nil {
+		return true, nil
+	}
+
+	var score int
+	err := tx.QueryRowContext(ctx, `SELECT upvotes - downvotes FROM comments WHERE id = ?`, commentID).Scan(&score)
+	if err != nil {
+		return false, err
+	}
+
+	return score-1 >= *m.MinScore, nil
+}
+
+// checkVoter confere, dentro da transação do voto, se o comentário existe e
+// se o usuário não é o autor dele. Retorna ErrNoRecord ou ErrSelfVote.
+//
+// A linha do comentário fica bloqueada até o fim da transação, então votos
+// simultâneos no mesmo comentário são aplicados um de cada vez: o piso de
+// MinScore e os contadores sempre partem do saldo já atualizado, e dois
+// primeiros votos do mesmo usuário não disputam o INSERT em comment_votes.
+func checkVoter(ctx context.Context, tx *sql.Tx, commentID, userID int) error {
+	var authorID int
+	err := tx.QueryRowContext(ctx, `SELECT COALESCE(author_id, 0) FROM comments WHERE id = ? FOR UPDATE`, commentID).Scan(&authorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	if authorID != 0 && authorID == userID {
+		return ErrSelfVote
+	}
+	return nil
+}
+
+// voteScore lê, dentro da transação do voto, o saldo atualizado do
+// comentário. Retorna ErrNoRecord se o comentário não existir.
+func voteScore(ctx context.Context, tx *sql.Tx, commentID int) (int, error) {
+	var score int
+	err := tx.QueryRowContext(ctx, `SELECT upvotes - downvotes FROM comments WHERE id = ?`, commentID).Scan(&score)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+	return score, nil
+}
+
+// ToggleReaction inclui a reação do usuário no comentário ou, se ele já
+// tiver deixado essa reação, a retira. Cada usuário deixa no máximo uma
+// reação de cada tipo por comentário, e pode reagir aos próprios comentários.
+// Retorna true se a reação foi incluída, ErrInvalidReaction se reaction não
+// estiver em ReactionTypes e ErrNoRecord se não houver um comentário ainda
+// não removido com esse ID.
+//
+// ToggleReaction usa context.Background(); para informar um contexto, use
+// ToggleReactionContext.
+func (m *CommentModel) ToggleReaction(commentID, userID int, reaction string) (bool, error) {
+	return m.ToggleReactionContext(context.Background(), commentID, userID, reaction)
+}
+
+// ToggleReactionContext é como ToggleReaction, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ToggleReactionContext(ctx context.Context, commentID, userID int, reaction string) (bool, error) {
+	if !validReaction(reaction) {
+		return false, ErrInvalidReaction
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Bloqueia o comentário para que dois cliques seguidos do mesmo usuário
+	// não disputem o INSERT
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT TRUE FROM comments WHERE id = ? AND deleted = FALSE FOR UPDATE`, commentID).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		}
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM comment_reactions WHERE comment_id = ? AND user_id = ? AND reaction = ?`, commentID, userID, reaction)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if removed == 0 {
+		_, err = tx.ExecContext(ctx, `INSERT INTO comment_reactions (comment_id, user_id, reaction, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`, commentID, userID, reaction)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return removed == 0, nil
+}
+
+// Delete marca um comentário como removido por deletedBy. A linha e os votos
+// associados são mantidos para a moderação, mas o comentário deixa de
+// aparecer nas listagens e seus votos deixam de contar nos agregados. Se
+// quem removeu foi o próprio autor, o comentário vai para a lixeira dele (veja
+// Trash). Retorna ErrNoRecord se não houver um comentário ainda não removido
+// com esse ID.
+//
+// Delete usa context.Background(); para informar um contexto, use
+// DeleteContext.
+func (m *CommentModel) Delete(id int, deletedBy int) error {
+	return m.DeleteContext(context.Background(), id, deletedBy)
+}
+
+// DeleteContext é como Delete, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) DeleteContext(ctx context.Context, id int, deletedBy int) error {
+	stmt := `UPDATE comments SET deleted = TRUE, deleted_at = UTC_TIMESTAMP(), deleted_by = ?
+	         WHERE id = ? AND deleted = FALSE`
+
+	result, err := m.DB.ExecContext(ctx, stmt, deletedBy, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// DeleteByAuthor apaga, como Delete, todos os comentários ainda visíveis de
+// um autor e retorna quantos foram apagados. Um autor sem comentários resulta
+// em 0 e erro nil.
+//
+// DeleteByAuthor usa context.Background(); para informar um contexto, use
+// DeleteByAuthorContext.
+func (m *CommentModel) DeleteByAuthor(authorID int, deletedBy int) (int, error) {
+	return m.DeleteByAuthorContext(context.Background(), authorID, deletedBy)
+}
+
+// DeleteByAuthorContext é como DeleteByAuthor, mas usa ctx nas consultas ao
+// banco.
+func (m *CommentModel) DeleteByAuthorContext(ctx context.Context, authorID int, deletedBy int) (int, error) {
+	stmt := `UPDATE comments SET deleted = TRUE, deleted_at = UTC_TIMESTAMP(), deleted_by = ?
+	         WHERE author_id = ? AND deleted = FALSE`
+
+	result, err := m.DB.ExecContext(ctx, stmt, deletedBy, authorID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// DeleteBySnippetID apaga definitivamente todos os comentários de um snippet,
+// junto com seus votos, e retorna quantos comentários foram apagados. Deve
+// ser chamado ao remover o snippet. Um snippet sem comentários resulta em 0 e
+// erro nil.
+//
+// DeleteBySnippetID usa context.Background(); para informar um contexto, use
+// DeleteBySnippetIDContext.
+func (m *CommentModel) DeleteBySnippetID(snippetID int) (int, error) {
+	return m.DeleteBySnippetIDContext(context.Background(), snippetID)
+}
+
+// DeleteBySnippetIDContext é como DeleteBySnippetID, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) DeleteBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE v FROM comment_votes v
+	                  INNER JOIN comments c ON c.id = v.comment_id
+	                  WHERE c.snippet_id = ?`, snippetID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Apaga as respostas antes dos pais para que todas entrem na contagem,
+	// já que linhas removidas em cascata não são contadas
+	deleted := 0
+	for _, stmt := range []string{
+		`DELETE FROM comments WHERE snippet_id = ? AND parent_id IS NOT NULL`,
+		`DELETE FROM comments WHERE snippet_id = ?`,
+	} {
+		result, err := tx.ExecContext(ctx, stmt, snippetID)
+		if err != nil {
+			return 0, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(affected)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// Restore desfaz a remoção de um comentário. Retorna ErrNoRecord se não
+// houver um comentário removido com esse ID há menos de TrashRetention.
+//
+// Restore usa context.Background(); para informar um contexto, use
+// RestoreContext.
+func (m *CommentModel) Restore(id int) error {
+	return m.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext é como Restore, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RestoreContext(ctx context.Context, id int) error {
+	stmt := `UPDATE comments SET deleted = FALSE, deleted_at = NULL, deleted_by = NULL
+	         WHERE id = ? AND deleted = TRUE
+	           AND deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id, int(TrashRetention/time.Second))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Trash retorna a lixeira de um autor: os comentários que ele mesmo removeu
+// há menos de TrashRetention, do removido mais recentemente para o mais
+// antigo, junto com o título do snippet.
+//
+// Trash usa context.Background(); para informar um contexto, use
+// TrashContext.
+func (m *CommentModel) Trash(authorID int) ([]*CommentWithContext, error) {
+	return m.TrashContext(context.Background(), authorID)
+}
+
+// TrashContext é como Trash, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) TrashContext(ctx context.Context, authorID int) ([]*CommentWithContext, error) {
+	stmt := `SELECT ` + commentColumns + `, s.title
+	         FROM comments c
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE c.author_id = ? AND c.deleted = TRUE AND c.deleted_by = c.author_id
+	           AND c.deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	           AND s.deleted_at IS NULL
+	         ORDER BY c.deleted_at DESC, c.id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, authorID, int(TrashRetention/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*CommentWithContext{}
+
+	for rows.Next() {
+		var title string
+		c, err := scanComment(rows, &title)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &CommentWithContext{Comment: *c, SnippetTitle: title})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// PurgeDeleted apaga definitivamente os comentários removidos há mais de
+// TrashRetention e retorna quantos foram apagados. Comentários com respostas
+// ficam para uma próxima execução, depois que as respostas forem apagadas,
+// já que apagar o pai levaria junto, em cascata, respostas ainda visíveis.
+//
+// PurgeDeleted usa context.Background(); para informar um contexto, use
+// PurgeDeletedContext.
+func (m *CommentModel) PurgeDeleted() (int, error) {
+	return m.PurgeDeletedContext(context.Background())
+}
+
+// PurgeDeletedContext é como PurgeDeleted, mas usa ctx nas consultas ao
+// banco.
+func (m *CommentModel) PurgeDeletedContext(ctx context.Context) (int, error) {
+	// A tabela derivada é materializada antes do DELETE, o que o MySQL exige
+	// para consultar a mesma tabela que está sendo alterada
+	stmt := `DELETE FROM comments
+	         WHERE deleted = TRUE AND deleted_at <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	           AND id NOT IN (
+	             SELECT parent_id FROM (
+	               SELECT DISTINCT parent_id FROM comments WHERE parent_id IS NOT NULL
+	             ) p
+	           )`
+
+	result, err := m.DB.ExecContext(ctx, stmt, int(TrashRetention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// GetBySnippetIDIncludingDeleted retorna todos os comentários de um snippet,
+// inclusive os removidos, para a moderação.
+//
+// GetBySnippetIDIncludingDeleted usa context.Background(); para informar um
+// contexto, use GetBySnippetIDIncludingDeletedContext.
+func (m *CommentModel) GetBySnippetIDIncludingDeleted(snippetID int) ([]*Comment, error) {
+	return m.GetBySnippetIDIncludingDeletedContext(context.Background(), snippetID)
+}
+
+// GetBySnippetIDIncludingDeletedContext é como GetBySnippetIDIncludingDeleted, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDIncludingDeletedContext(ctx context.Context, snippetID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c ` + threadJoin + `
+	         WHERE c.snippet_id = ?
+	         ORDER BY ` + threadOrder
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Get retorna um comentário específico pelo seu ID.
+//
+// Get usa context.Background(); para informar um contexto, use GetContext.
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	return m.GetContext(context.Background(), id)
+}
+
+// GetContext é como Get, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetContext(ctx context.Context, id int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// GetByIDs retorna, em uma única consulta, os comentários com os IDs
+// informados, indexados pelo ID. IDs que não existirem simplesmente não
+// aparecem no mapa.
+//
+// GetByIDs usa context.Background(); para informar um contexto, use
+// GetByIDsContext.
+func (m *CommentModel) GetByIDs(ids []int) (map[int]*Comment, error) {
+	return m.GetByIDsContext(context.Background(), ids)
+}
+
+// GetByIDsContext é como GetByIDs, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetByIDsContext(ctx context.Context, ids []int) (map[int]*Comment, error) {
+	comments := make(map[int]*Comment, len(ids))
+	if len(ids) == 0 {
+		return comments, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c WHERE c.id IN (` + placeholders(len(ids)) + `)`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments[c.ID] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetUserVotes retorna o voto ("upvote" ou "downvote") do usuário em cada um
+// dos comentários informados, em uma única consulta. Comentários em que ele
+// não votou não aparecem no mapa.
+//
+// GetUserVotes usa context.Background(); para informar um contexto, use
+// GetUserVotesContext.
+func (m *CommentModel) GetUserVotes(userID int, commentIDs []int) (map[int]string, error) {
+	return m.GetUserVotesContext(context.Background(), userID, commentIDs)
+}
+
+// GetUserVotesContext é como GetUserVotes, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetUserVotesContext(ctx context.Context, userID int, commentIDs []int) (map[int]string, error) {
+	votes := map[int]string{}
+	if len(commentIDs) == 0 {
+		return votes, nil
+	}
+
+	args := make([]any, 0, len(commentIDs)+1)
+	args = append(args, userID)
+	for _, id := range commentIDs {
+		args = append(args, id)
+	}
+
+	stmt := `SELECT comment_id, vote_type FROM comment_votes
+	         WHERE user_id = ? AND comment_id IN (` + placeholders(len(commentIDs)) + `)`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var voteType string
+		err = rows.Scan(&id, &voteType)
+		if err != nil {
+			return nil, err
+		}
+		votes[id] = voteType
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return votes, nil
+}
+
+// VoteTimeline retorna os votos de um comentário em ordem cronológica.
+//
+// VoteTimeline usa context.Background(); para informar um contexto, use
+// VoteTimelineContext.
+func (m *CommentModel) VoteTimeline(commentID int) ([]VoteEvent, error) {
+	return m.VoteTimelineContext(context.Background(), commentID)
+}
+
+// VoteTimelineContext é como VoteTimeline, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) VoteTimelineContext(ctx context.Context, commentID int) ([]VoteEvent, error) {
+	stmt := `SELECT vote_type, created FROM comment_votes
+	         WHERE comment_id = ? ORDER BY created ASC, id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []VoteEvent{}
+
+	for rows.Next() {
+		var e VoteEvent
+		err = rows.Scan(&e.Type, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// SetPinOrder fixa os comentários informados no topo de um snippet, na ordem
+// em que aparecem na lista. Os comentários fixados anteriormente que não
+// estiverem na lista deixam de ser fixados. Uma lista vazia remove todas as
+// fixações do snippet. Retorna ErrNoRecord se algum ID não pertencer ao snippet.
+//
+// SetPinOrder usa context.Background(); para informar um contexto, use
+// SetPinOrderContext.
+func (m *CommentModel) SetPinOrder(snippetID int, orderedCommentIDs []int) error {
+	return m.SetPinOrderContext(context.Background(), snippetID, orderedCommentIDs)
+}
+
+// SetPinOrderContext é como SetPinOrder, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) SetPinOrderContext(ctx context.Context, snippetID int, orderedCommentIDs []int) error {
+	seen := make(map[int]bool, len(orderedCommentIDs))
+	args := []any{snippetID}
+	for _, id := range orderedCommentIDs {
+		if seen[id] {
+			return ErrDuplicatePin
+		}
+		seen[id] = true
+		args = append(args, id)
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(orderedCommentIDs) > 0 {
+		// Confere se todos os IDs pertencem ao snippet
+		var count int
+		stmt := `SELECT COUNT(*) FROM comments WHERE snippet_id = ? AND id IN (` + placeholders(len(orderedCommentIDs)) + `)`
+		err = tx.QueryRowContext(ctx, stmt, args...).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != len(orderedCommentIDs) {
+			return ErrNoRecord
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE comments SET pin_order = NULL WHERE snippet_id = ? AND pin_order IS NOT NULL`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range orderedCommentIDs {
+		_, err = tx.ExecContext(ctx, `UPDATE comments SET pin_order = ? WHERE id = ?`, i+1, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Pin fixa o comentário no topo do seu snippet como o único fixado,
+// desafixando na mesma transação os que estavam fixados antes. Retorna
+// ErrNoRecord se o comentário não existir.
+//
+// Pin usa context.Background(); para informar um contexto, use PinContext.
+func (m *CommentModel) Pin(commentID int) error {
+	return m.PinContext(context.Background(), commentID)
+}
+
+// PinContext é como Pin, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) PinContext(ctx context.Context, commentID int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var snippetID int
+	err = tx.QueryRowContext(ctx, `SELECT snippet_id FROM comments WHERE id = ? FOR UPDATE`, commentID).Scan(&snippetID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE comments SET pin_order = NULL WHERE snippet_id = ? AND pin_order IS NOT NULL`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE comments SET pin_order = 1 WHERE id = ?`, commentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Unpin desafixa o comentário. Desafixar um comentário que não está fixado não
+// é um erro; retorna ErrNoRecord apenas se o comentário não existir.
+//
+// Unpin usa context.Background(); para informar um contexto, use UnpinContext.
+func (m *CommentModel) Unpin(commentID int) error {
+	return m.UnpinContext(context.Background(), commentID)
+}
+
+// UnpinContext é como Unpin, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) UnpinContext(ctx context.Context, commentID int) error {
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ?)`, commentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = m.DB.ExecContext(ctx, `UPDATE comments SET pin_order = NULL WHERE id = ?`, commentID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SimilarTo retorna até limit comentários com conteúdo parecido com o do
+// comentário informado, ordenados pela relevância do índice FULLTEXT. O próprio
+// comentário não é incluído no resultado, nem os removidos, os retidos para
+// moderação ou os ocultados por ela.
+//
+// SimilarTo usa context.Background(); para informar um contexto, use
+// SimilarToContext.
+func (m *CommentModel) SimilarTo(commentID int, limit int) ([]*Comment, error) {
+	return m.SimilarToContext(context.Background(), commentID, limit)
+}
+
+// SimilarToContext é como SimilarTo, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) SimilarToContext(ctx context.Context, commentID int, limit int) ([]*Comment, error) {
+	source, err := m.GetContext(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         WHERE c.id <> ? AND c.deleted = FALSE AND c.status = ?
+	           AND MATCH(c.content) AGAINST (? IN NATURAL LANGUAGE MODE) > 0
+	         ORDER BY MATCH(c.content) AGAINST (? IN NATURAL LANGUAGE MODE) DESC, c.id ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, commentID, StatusApproved, source.Content, source.Content, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CountByStatus retorna o número de comentários não removidos em cada estado
+// de moderação. Estados sem nenhum comentário aparecem com valor zero.
+//
+// CountByStatus usa context.Background(); para informar um contexto, use
+// CountByStatusContext.
+func (m *CommentModel) CountByStatus() (map[string]int, error) {
+	return m.CountByStatusContext(context.Background())
+}
+
+// CountByStatusContext é como CountByStatus, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) CountByStatusContext(ctx context.Context) (map[string]int, error) {
+	stmt := `SELECT status, COUNT(*) FROM comments WHERE deleted = FALSE GROUP BY status`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int, len(commentStatuses))
+	for _, status := range commentStatuses {
+		counts[status] = 0
+	}
+
+	for rows.Next() {
+		var status string
+		var count int
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// AuthorCommentsOnOwner retorna os comentários escritos por um usuário nos
+// snippets de outro usuário, do mais recente para o mais antigo.
+//
+// AuthorCommentsOnOwner usa context.Background(); para informar um contexto,
+// use AuthorCommentsOnOwnerContext.
+func (m *CommentModel) AuthorCommentsOnOwner(authorUserID, ownerUserID int) ([]*CommentWithContext, error) {
+	return m.AuthorCommentsOnOwnerContext(context.Background(), authorUserID, ownerUserID)
+}
+
+// AuthorCommentsOnOwnerContext é como AuthorCommentsOnOwner, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) AuthorCommentsOnOwnerContext(ctx context.Context, authorUserID, ownerUserID int) ([]*CommentWithContext, error) {
+	stmt := `SELECT ` + commentColumns + `, s.title
+	         FROM comments c
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE c.author_id = ? AND s.user_id = ? AND c.deleted = FALSE AND s.deleted_at IS NULL
+	         ORDER BY c.created DESC, c.id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, authorUserID, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*CommentWithContext{}
+
+	for rows.Next() {
+		var title string
+		c, err := scanComment(rows, &title)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &CommentWithContext{Comment: *c, SnippetTitle: title})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// ThreadHealth calcula os indicadores de saúde da discussão de um snippet:
+// proporção de downvotes, denúncias por comentário e proporção de
+// comentários removidos ou rejeitados pela moderação.
+//
+// ThreadHealth usa context.Background(); para informar um contexto, use
+// ThreadHealthContext.
+func (m *CommentModel) ThreadHealth(snippetID int) (ThreadHealth, error) {
+	return m.ThreadHealthContext(context.Background(), snippetID)
+}
+
+// ThreadHealthContext é como ThreadHealth, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ThreadHealthContext(ctx context.Context, snippetID int) (ThreadHealth, error) {
+	stmt := `SELECT
+	           (SELECT COUNT(*) FROM comments WHERE snippet_id = ?),
+	           (SELECT COUNT(*) FROM comments WHERE snippet_id = ? AND (deleted = TRUE OR status = 'rejected')),
+	           (SELECT COUNT(*) FROM comment_votes v INNER JOIN comments c ON c.id = v.comment_id
+	            WHERE c.snippet_id = ? AND c.deleted = FALSE),
+	           (SELECT COUNT(*) FROM comment_votes v INNER JOIN comments c ON c.id = v.comment_id
+	            WHERE c.snippet_id = ? AND c.deleted = FALSE AND v.vote_type = 'downvote'),
+	           (SELECT COUNT(*) FROM comment_reports r INNER JOIN comments c ON c.id = r.comment_id
+	            WHERE c.snippet_id = ?)`
+
+	var stats threadStats
+	err := m.DB.QueryRowContext(ctx, stmt, snippetID, snippetID, snippetID, snippetID, snippetID).
+		Scan(&stats.comments, &stats.removed, &stats.votes, &stats.downvotes, &stats.reports)
+	if err != nil {
+		return ThreadHealth{}, err
+	}
+
+	weights := m.HealthWeights
+	if weights == (HealthWeights{}) {
+		weights = DefaultHealthWeights
+	}
+
+	health := stats.health(weights)
+	health.SnippetID = snippetID
+
+	return health, nil
+}
+
+// PreviewReplyPlacement calcula onde uma resposta ao comentário parentID
+// apareceria, sem gravar nada: a profundidade da resposta, a cadeia de
+// ancestrais a partir do comentário de primeiro nível e se ela ficaria na
+// profundidade máxima. Retorna ErrNoRecord se o comentário não existir.
+//
+// PreviewReplyPlacement usa context.Background(); para informar um contexto,
+// use PreviewReplyPlacementContext.
+func (m *CommentModel) PreviewReplyPlacement(parentID int) (depth int, ancestors []int, atMaxDepth bool, err error) {
+	return m.PreviewReplyPlacementContext(context.Background(), parentID)
+}
+
+// PreviewReplyPlacementContext é como PreviewReplyPlacement, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) PreviewReplyPlacementContext(ctx context.Context, parentID int) (depth int, ancestors []int, atMaxDepth bool, err error) {
+	stmt := `WITH RECURSIVE chain (id, parent_id, lvl) AS (
+	           SELECT id, parent_id, 0 FROM comments WHERE id = ?
+	           UNION ALL
+	           SELECT c.id, c.parent_id, chain.lvl + 1
+	           FROM comments c INNER JOIN chain ON c.id = chain.parent_id
+	         )
+	         SELECT id FROM chain ORDER BY lvl DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, parentID)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	defer rows.Close()
+
+	ancestors = []int{}
+
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		ancestors = append(ancestors, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, nil, false, err
+	}
+
+	if len(ancestors) == 0 {
+		return 0, nil, false, ErrNoRecord
+	}
+
+	depth, ancestors = placeReply(ancestors)
+
+	return depth, ancestors, depth >= MaxReplyDepth, nil
+}
+
+// placeReply recebe a cadeia de ancestrais do comentário respondido, do
+// primeiro nível até ele, e devolve a profundidade da resposta e a cadeia
+// ajustada a MaxReplyDepth.
+func placeReply(chain []int) (int, []int) {
+	if len(chain) > MaxReplyDepth {
+		chain = chain[:MaxReplyDepth]
+	}
+	return len(chain), chain
+}
+
+// MostEdited retorna até limit comentários editados, do que recebeu mais
+// edições para o que recebeu menos.
+//
+// MostEdited usa context.Background(); para informar um contexto, use
+// MostEditedContext.
+func (m *CommentModel) MostEdited(limit int) ([]*Comment, error) {
+	return m.MostEditedContext(context.Background(), limit)
+}
+
+// MostEditedContext é como MostEdited, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) MostEditedContext(ctx context.Context, limit int) ([]*Comment, error) {
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c WHERE c.edit_count > 0 AND c.deleted = FALSE
+	         ORDER BY c.edit_count DESC, c.id ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// MostVoted retorna até limit comentários não apagados com ao menos um voto,
+// do que recebeu mais votos, positivos ou negativos, para o que recebeu menos.
+//
+// MostVoted usa context.Background(); para informar um contexto, use
+// MostVotedContext.
+func (m *CommentModel) MostVoted(limit int) ([]*Comment, error) {
+	return m.MostVotedContext(context.Background(), limit)
+}
+
+// MostVotedContext é como MostVoted, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) MostVotedContext(ctx context.Context, limit int) ([]*Comment, error) {
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c WHERE c.upvotes + c.downvotes > 0 AND c.deleted = FALSE
+	         ORDER BY c.upvotes + c.downvotes DESC, c.id ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Count retorna o número de comentários não apagados.
+//
+// Count usa context.Background(); para informar um contexto, use
+// CountContext.
+func (m *CommentModel) Count() (int, error) {
+	return m.CountContext(context.Background())
+}
+
+// CountContext é como Count, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) CountContext(ctx context.Context) (int, error) {
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM comments WHERE deleted = FALSE`).Scan(&count)
+
+	return count, err
+}
+
+// EditableBy retorna os comentários do usuário que ainda estão dentro da
+// janela de edição, do mais recente para o mais antigo.
+//
+// EditableBy usa context.Background(); para informar um contexto, use
+// EditableByContext.
+func (m *CommentModel) EditableBy(userID int) ([]*Comment, error) {
+	return m.EditableByContext(context.Background(), userID)
+}
+
+// EditableByContext é como EditableBy, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) EditableByContext(ctx context.Context, userID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         WHERE c.author_id = ? AND c.locked = FALSE AND c.deleted = FALSE
+	           AND c.created > UTC_TIMESTAMP() - INTERVAL ? SECOND
+	         ORDER BY c.created DESC, c.id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, int(EditWindow.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	now := time.Now()
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		// A consulta já filtra pela janela; a regra final fica em IsEditableBy
+		if c.IsEditableBy(userID, now) {
+			comments = append(comments, c)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// VoteSpikes retorna os comentários que receberam mais de minVotes votos
+// dentro da janela informada, do maior para o menor número de votos.
+//
+// A consulta percorre o índice de comment_votes.created apenas dentro da
+// janela, então o custo cresce com o volume de votos recentes e não com o
+// tamanho total da tabela. Janelas muito longas numa tabela grande acabam
+// agrupando boa parte dos votos e devem ser evitadas em verificações
+// frequentes.
+//
+// VoteSpikes usa context.Background(); para informar um contexto, use
+// VoteSpikesContext.
+func (m *CommentModel) VoteSpikes(window time.Duration, minVotes int) ([]SpikeAlert, error) {
+	return m.VoteSpikesContext(context.Background(), window, minVotes)
+}
+
+// VoteSpikesContext é como VoteSpikes, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) VoteSpikesContext(ctx context.Context, window time.Duration, minVotes int) ([]SpikeAlert, error) {
+	stmt := `SELECT v.comment_id, c.snippet_id, COUNT(*) AS votes
+	         FROM comment_votes v
+	         INNER JOIN comments c ON c.id = v.comment_id
+	         WHERE v.created >= UTC_TIMESTAMP() - INTERVAL ? SECOND AND c.deleted = FALSE
+	         GROUP BY v.comment_id, c.snippet_id
+	         HAVING votes > ?
+	         ORDER BY votes DESC, v.comment_id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, int(window.Seconds()), minVotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []SpikeAlert{}
+
+	for rows.Next() {
+		var a SpikeAlert
+		err = rows.Scan(&a.CommentID, &a.SnippetID, &a.Votes)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// UnresolvedQuestions retorna os comentários marcados como pergunta em
+// snippets que ainda não têm nenhuma resposta aceita, do mais antigo para o
+// mais recente.
+//
+// UnresolvedQuestions usa context.Background(); para informar um contexto, use
+// UnresolvedQuestionsContext.
+func (m *CommentModel) UnresolvedQuestions(limit, offset int) ([]*CommentWithContext, error) {
+	return m.UnresolvedQuestionsContext(context.Background(), limit, offset)
+}
+
+// UnresolvedQuestionsContext é como UnresolvedQuestions, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) UnresolvedQuestionsContext(ctx context.Context, limit, offset int) ([]*CommentWithContext, error) {
+	if limit < 0 || offset < 0 {
+		return nil, ErrInvalidPagination
+	}
+
+	stmt := `SELECT ` + commentColumns + `, s.title
+	         FROM comments c
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE c.is_question = TRUE AND c.deleted = FALSE AND s.deleted_at IS NULL
+	           AND NOT EXISTS (
+	             SELECT 1 FROM comments a WHERE a.snippet_id = c.snippet_id AND a.accepted = TRUE AND a.deleted = FALSE
+	           )
+	         ORDER BY c.created ASC, c.id ASC
+	         LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*CommentWithContext{}
+
+	for rows.Next() {
+		var title string
+		c, err := scanComment(rows, &title)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &CommentWithContext{Comment: *c, SnippetTitle: title})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// IncrementViews soma uma visualização ao comentário. O incremento é feito
+// pelo próprio UPDATE, então acessos simultâneos não perdem contagens.
+// Retorna ErrNoRecord se o comentário não existir.
+//
+// IncrementViews usa context.Background(); para informar um contexto, use
+// IncrementViewsContext.
+func (m *CommentModel) IncrementViews(id int) error {
+	return m.IncrementViewsContext(context.Background(), id)
+}
+
+// IncrementViewsContext é como IncrementViews, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) IncrementViewsContext(ctx context.Context, id int) error {
+	stmt := `UPDATE comments SET views = views + 1 WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetBySnippetIDWithStaff retorna os comentários de um snippet, como
+// GetBySnippetID, marcando com IsStaff os que foram escritos por moderadores
+// ou administradores.
+//
+// GetBySnippetIDWithStaff usa context.Background(); para informar um contexto,
+// use GetBySnippetIDWithStaffContext.
+func (m *CommentModel) GetBySnippetIDWithStaff(snippetID int) ([]*Comment, error) {
+	return m.GetBySnippetIDWithStaffContext(context.Background(), snippetID)
+}
+
+// GetBySnippetIDWithStaffContext é como GetBySnippetIDWithStaff, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDWithStaffContext(ctx context.Context, snippetID int) ([]*Comment, error) {
+	comments, err := m.GetBySnippetIDContext(ctx, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.markStaff(ctx, comments)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetBySnippetIDForViewer retorna os comentários de um snippet como vistos
+// pelo usuário viewerID (0 para visitantes). Comentários de autores com
+// shadowban só aparecem para o próprio autor e para moderadores e
+// administradores. Os comentários da equipe vêm marcados com IsStaff.
+//
+// GetBySnippetIDForViewer usa context.Background(); para informar um contexto,
+// use GetBySnippetIDForViewerContext.
+func (m *CommentModel) GetBySnippetIDForViewer(snippetID int, viewerID int) ([]*Comment, error) {
+	return m.GetBySnippetIDForViewerContext(context.Background(), snippetID, viewerID)
+}
+
+// GetBySnippetIDForViewerContext é como GetBySnippetIDForViewer, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDForViewerContext(ctx context.Context, snippetID int, viewerID int) ([]*Comment, error) {
+	return m.GetBySnippetIDForViewerSortedContext(ctx, snippetID, viewerID, SortOldest)
+}
+
+// GetBySnippetIDForViewerSorted é como GetBySnippetIDForViewer, mas ordena as
+// discussões por order. Retorna ErrInvalidSortOrder para uma ordem
+// desconhecida.
+//
+// GetBySnippetIDForViewerSorted usa context.Background(); para informar um
+// contexto, use GetBySnippetIDForViewerSortedContext.
+func (m *CommentModel) GetBySnippetIDForViewerSorted(snippetID int, viewerID int, order SortOrder) ([]*Comment, error) {
+	return m.GetBySnippetIDForViewerSortedContext(context.Background(), snippetID, viewerID, order)
+}
+
+// GetBySnippetIDForViewerSortedContext é como GetBySnippetIDForViewerSorted, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDForViewerSortedContext(ctx context.Context, snippetID int, viewerID int, order SortOrder) ([]*Comment, error) {
+	orderBy, err := threadOrderBy(order)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c ` + viewerJoin + ` ` + threadJoin + `
+	         WHERE ` + viewerVisible + `
+	         ORDER BY ` + orderBy
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID, viewerID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.markStaff(ctx, comments)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// ByAuthor retorna uma página dos comentários de um usuário, do mais recente
+// para o mais antigo, com o título do snippet de cada um, junto com o total.
+// Só entram comentários não removidos em snippets públicos e não expirados; os
+// de um autor com shadowban só aparecem para ele mesmo e para moderadores e
+// administradores. Retorna ErrInvalidPagination para page ou pageSize menores
+// que 1.
+//
+// ByAuthor usa context.Background(); para informar um contexto, use
+// ByAuthorContext.
+func (m *CommentModel) ByAuthor(authorID, viewerID, page, pageSize int) ([]*CommentWithContext, int, error) {
+	return m.ByAuthorContext(context.Background(), authorID, viewerID, page, pageSize)
+}
+
+// ByAuthorContext é como ByAuthor, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ByAuthorContext(ctx context.Context, authorID, viewerID, page, pageSize int) ([]*CommentWithContext, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	stmt := `SELECT ` + commentColumns + `, s.title, COUNT(*) OVER ()
+	         FROM comments c ` + viewerJoin + `
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE c.author_id = ? AND ` + authorVisible + `
+	         ORDER BY c.created DESC, c.id DESC
+	         LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, authorID, viewerID, viewerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*CommentWithContext{}
+	total := 0
+
+	for rows.Next() {
+		var title string
+		c, err := scanComment(rows, &title, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, &CommentWithContext{Comment: *c, SnippetTitle: title})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(comments) == 0 {
+		stmt = `SELECT COUNT(*)
+		        FROM comments c ` + viewerJoin + `
+		        INNER JOIN snippets s ON s.id = c.snippet_id
+		        WHERE c.author_id = ? AND ` + authorVisible
+		err = m.DB.QueryRowContext(ctx, stmt, authorID, viewerID, viewerID).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return comments, total, nil
+}
+
+// ForOwnerSince retorna até limit comentários feitos desde since nos snippets
+// de ownerID, do mais recente para o mais antigo, com o título do snippet de
+// cada um. Ficam de fora os do próprio dono, os removidos, os que não foram
+// aprovados e os de autores com shadowban.
+//
+// ForOwnerSince usa context.Background(); para informar um contexto, use
+// ForOwnerSinceContext.
+func (m *CommentModel) ForOwnerSince(ownerID int, since time.Time, limit int) ([]*CommentWithContext, error) {
+	return m.ForOwnerSinceContext(context.Background(), ownerID, since, limit)
+}
+
+// ForOwnerSinceContext é como ForOwnerSince, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ForOwnerSinceContext(ctx context.Context, ownerID int, since time.Time, limit int) ([]*CommentWithContext, error) {
+	if limit < 1 {
+		return []*CommentWithContext{}, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `, s.title
+	         FROM comments c ` + viewerJoin + `
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE s.user_id = ? AND (c.author_id IS NULL OR c.author_id <> ?) AND c.created >= ?
+	           AND c.deleted = FALSE AND c.status = ? AND s.deleted_at IS NULL
+	           AND (u.shadowbanned IS NULL OR u.shadowbanned = FALSE)
+	         ORDER BY c.created DESC, c.id DESC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, ownerID, ownerID, since.UTC(), StatusApproved, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*CommentWithContext{}
+
+	for rows.Next() {
+		var title string
+		c, err := scanComment(rows, &title)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &CommentWithContext{Comment: *c, SnippetTitle: title})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// authorVisible é como viewerVisible, para os comentários de um autor em
+// snippets públicos e não expirados. Requer viewerJoin e o alias s para
+// snippets; os parâmetros são, duas vezes, o ID de quem vê.
+const authorVisible = `c.deleted = FALSE AND s.visibility = 'public' AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL
+	  AND ((c.status <> 'pending' AND (u.shadowbanned IS NULL OR u.shadowbanned = FALSE)) OR c.author_id = ?
+	       OR EXISTS (SELECT 1 FROM users v WHERE v.id = ? AND v.role IN ('moderator', 'admin')))`
+
+// viewerJoin e viewerVisible filtram os comentários de um snippet como vistos
+// por um usuário: sem os removidos e sem os de autores com shadowban ou
+// retidos para moderação, a não ser para o próprio autor e para moderadores
+// e administradores. Os
+// parâmetros são o snippet e, duas vezes, o ID de quem vê.
+const (
+	viewerJoin    = `LEFT JOIN users u ON u.id = c.author_id`
+	viewerVisible = `c.snippet_id = ? AND c.deleted = FALSE
+	  AND ((c.status <> 'pending' AND (u.shadowbanned IS NULL OR u.shadowbanned = FALSE)) OR c.author_id = ?
+	       OR EXISTS (SELECT 1 FROM users v WHERE v.id = ? AND v.role IN ('moderator', 'admin')))`
+)
+
+// GetBySnippetIDForViewerPaged é como GetBySnippetIDForViewerSorted, mas
+// retorna só a página page (a partir de 1) com pageSize comentários, junto com
+// o total de comentários visíveis para viewerID. Retorna ErrInvalidPagination
+// para page ou pageSize menores que 1.
+//
+// GetBySnippetIDForViewerPaged usa context.Background(); para informar um
+// contexto, use GetBySnippetIDForViewerPagedContext.
+func (m *CommentModel) GetBySnippetIDForViewerPaged(snippetID int, viewerID int, order SortOrder, page, pageSize int) ([]*Comment, int, error) {
+	return m.GetBySnippetIDForViewerPagedContext(context.Background(), snippetID, viewerID, order, page, pageSize)
+}
+
+// GetBySnippetIDForViewerPagedContext é como GetBySnippetIDForViewerPaged, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetBySnippetIDForViewerPagedContext(ctx context.Context, snippetID int, viewerID int, order SortOrder, page, pageSize int) ([]*Comment, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	orderBy, err := threadOrderBy(order)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + commentColumns + `, COUNT(*) OVER ()
+	         FROM comments c ` + viewerJoin + ` ` + threadJoin + `
+	         WHERE ` + viewerVisible + `
+	         ORDER BY ` + orderBy + `
+	         LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID, viewerID, viewerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	total := 0
+
+	for rows.Next() {
+		c, err := scanComment(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(comments) == 0 {
+		stmt = `SELECT COUNT(*) FROM comments c ` + viewerJoin + ` WHERE ` + viewerVisible
+		err = m.DB.QueryRowContext(ctx, stmt, snippetID, viewerID, viewerID).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	err = m.markStaff(ctx, comments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = m.loadReactions(ctx, comments, viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// SetShadowban liga ou desliga o shadowban de um usuário. Os comentários
+// continuam sendo aceitos normalmente, mas ficam ocultos para os demais.
+// Retorna ErrNoRecord se o usuário não existir.
+//
+// SetShadowban usa context.Background(); para informar um contexto, use
+// SetShadowbanContext.
+func (m *CommentModel) SetShadowban(authorUserID int, banned bool) error {
+	return m.SetShadowbanContext(context.Background(), authorUserID, banned)
+}
+
+// SetShadowbanContext é como SetShadowban, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) SetShadowbanContext(ctx context.Context, authorUserID int, banned bool) error {
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`, authorUserID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt := `UPDATE users SET shadowbanned = ? WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, stmt, banned, authorUserID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Shadowbanned informa se o usuário está com shadowban. Para um usuário que
+// não existe, ou para o ID 0 de visitantes, retorna false.
+//
+// Shadowbanned usa context.Background(); para informar um contexto, use
+// ShadowbannedContext.
+func (m *CommentModel) Shadowbanned(authorUserID int) (bool, error) {
+	return m.ShadowbannedContext(context.Background(), authorUserID)
+}
+
+// ShadowbannedContext é como Shadowbanned, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ShadowbannedContext(ctx context.Context, authorUserID int) (bool, error) {
+	var banned bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT shadowbanned FROM users WHERE id = ?`, authorUserID).Scan(&banned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return banned, nil
+}
+
+// markStaff preenche IsStaff dos comentários buscando, em uma única
+// consulta, quais autores são moderadores ou administradores.
+func (m *CommentModel) markStaff(ctx context.Context, comments []*Comment) error {
+	seen := map[int]bool{}
+	args := []any{}
+	for _, c := range comments {
+		if c.AuthorID != 0 && !seen[c.AuthorID] {
+			seen[c.AuthorID] = true
+			args = append(args, c.AuthorID)
+		}
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	stmt := `SELECT id FROM users WHERE role IN ('moderator', 'admin') AND id IN (` + placeholders(len(args)) + `)`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	staff := map[int]bool{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		staff[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		c.IsStaff = staff[c.AuthorID]
+	}
+
+	return nil
+}
+
+// loadReactions preenche Reactions dos comentários com a contagem de cada
+// tipo de reação, buscada em uma única consulta. Mine é marcado nas reações
+// deixadas por viewerID; com viewerID 0, em nenhuma.
+func (m *CommentModel) loadReactions(ctx context.Context, comments []*Comment, viewerID int) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	args := []any{viewerID}
+	for _, c := range comments {
+		args = append(args, c.ID)
+	}
+
+	stmt := `SELECT comment_id, reaction, COUNT(*), SUM(user_id = ?) FROM comment_reactions
+	WHERE comment_id IN (` + placeholders(len(comments)) + `)
+	GROUP BY comment_id, reaction`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type key struct {
+		commentID int
+		reaction  string
+	}
+	counts := map[key]ReactionCount{}
+
+	for rows.Next() {
+		var k key
+		var rc ReactionCount
+		err = rows.Scan(&k.commentID, &k.reaction, &rc.Count, &rc.Mine)
+		if err != nil {
+			return err
+		}
+		counts[k] = rc
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range comments {
+		c.Reactions = make([]ReactionCount, len(ReactionTypes))
+		for i, r := range ReactionTypes {
+			rc := counts[key{c.ID, r.Name}]
+			rc.Reaction = r
+			c.Reactions[i] = rc
+		}
+	}
+
+	return nil
+}
+
+// RecentByOwner retorna, para cada snippet do usuário, até perSnippet
+// comentários mais recentes, agrupados pelo ID do snippet. Snippets sem
+// comentários não aparecem no mapa. A limitação por snippet é feita no banco
+// com ROW_NUMBER(), então o custo não depende de quantos comentários cada
+// snippet tem.
+//
+// RecentByOwner usa context.Background(); para informar um contexto, use
+// RecentByOwnerContext.
+func (m *CommentModel) RecentByOwner(ownerUserID int, perSnippet int) (map[int][]*Comment, error) {
+	return m.RecentByOwnerContext(context.Background(), ownerUserID, perSnippet)
+}
+
+// RecentByOwnerContext é como RecentByOwner, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RecentByOwnerContext(ctx context.Context, ownerUserID int, perSnippet int) (map[int][]*Comment, error) {
+	grouped := map[int][]*Comment{}
+	if perSnippet < 1 {
+		return grouped, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM (
+	           SELECT c.*, ROW_NUMBER() OVER (PARTITION BY c.snippet_id ORDER BY c.created DESC, c.id DESC) AS rn
+	           FROM comments c
+	           INNER JOIN snippets s ON s.id = c.snippet_id
+	           WHERE s.user_id = ? AND c.deleted = FALSE AND s.deleted_at IS NULL
+	         ) c
+	         WHERE c.rn <= ?
+	         ORDER BY c.snippet_id ASC, c.rn ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, ownerUserID, perSnippet)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		grouped[c.SnippetID] = append(grouped[c.SnippetID], c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return grouped, nil
+}
+
+// SetModeratorNote define a nota da moderação exibida junto ao comentário,
+// sem alterar o conteúdo escrito pelo autor. Uma nota vazia remove a nota
+// existente. Retorna ErrNoRecord se o comentário não existir ou tiver sido
+// removido.
+//
+// SetModeratorNote usa context.Background(); para informar um contexto, use
+// SetModeratorNoteContext.
+func (m *CommentModel) SetModeratorNote(commentID int, note string) error {
+	return m.SetModeratorNoteContext(context.Background(), commentID, note)
+}
+
+// SetModeratorNoteContext é como SetModeratorNote, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) SetModeratorNoteContext(ctx context.Context, commentID int, note string) error {
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ? AND deleted = FALSE)`, commentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt := `UPDATE comments SET moderator_note = NULLIF(?, '') WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, stmt, strings.TrimSpace(note), commentID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RejectMatching marca como rejeitados, com o motivo informado, todos os
+// comentários cujo conteúdo contém pattern, e retorna quantos foram
+// alterados. O padrão é comparado literalmente: curingas do LIKE são
+// escapados e o valor é sempre passado como parâmetro.
+//
+// RejectMatching usa context.Background(); para informar um contexto, use
+// RejectMatchingContext.
+func (m *CommentModel) RejectMatching(pattern string, reason string) (int, error) {
+	return m.RejectMatchingContext(context.Background(), pattern, reason)
+}
+
+// RejectMatchingContext é como RejectMatching, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RejectMatchingContext(ctx context.Context, pattern string, reason string) (int, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return 0, ErrEmptyPattern
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE comments SET status = 'rejected', status_note = ?
+	         WHERE status <> 'rejected' AND content LIKE ? ESCAPE '\\'`
+
+	result, err := tx.ExecContext(ctx, stmt, reason, likeContains(pattern))
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// MatchingIDs retorna os IDs dos comentários que RejectMatching rejeitaria
+// com o mesmo padrão, sem alterar nada.
+//
+// MatchingIDs usa context.Background(); para informar um contexto, use
+// MatchingIDsContext.
+func (m *CommentModel) MatchingIDs(pattern string) ([]int, error) {
+	return m.MatchingIDsContext(context.Background(), pattern)
+}
+
+// MatchingIDsContext é como MatchingIDs, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) MatchingIDsContext(ctx context.Context, pattern string) ([]int, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, ErrEmptyPattern
+	}
+
+	stmt := `SELECT id FROM comments
+	         WHERE status <> 'rejected' AND content LIKE ? ESCAPE '\\'
+	         ORDER BY id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, likeContains(pattern))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// likeContains escapa os curingas de value e o envolve em % para uma busca
+// por substring com LIKE ... ESCAPE '\\'.
+func likeContains(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// AuthorActivitySpan retorna as datas do primeiro e do último comentário do
+// usuário e o total de comentários dele. Para quem nunca comentou, retorna
+// datas zeradas, contagem 0 e erro nil.
+//
+// AuthorActivitySpan usa context.Background(); para informar um contexto, use
+// AuthorActivitySpanContext.
+func (m *CommentModel) AuthorActivitySpan(authorUserID int) (first, last time.Time, count int, err error) {
+	return m.AuthorActivitySpanContext(context.Background(), authorUserID)
+}
+
+// AuthorActivitySpanContext é como AuthorActivitySpan, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) AuthorActivitySpanContext(ctx context.Context, authorUserID int) (first, last time.Time, count int, err error) {
+	stmt := `SELECT MIN(created), MAX(created), COUNT(*) FROM comments WHERE author_id = ?`
+
+	var minCreated, maxCreated sql.NullTime
+	err = m.DB.QueryRowContext(ctx, stmt, authorUserID).Scan(&minCreated, &maxCreated, &count)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+
+	return minCreated.Time, maxCreated.Time, count, nil
+}
+
+// ChangedForIndex retorna até limit comentários criados, editados ou
+// removidos depois da marca sinceID do registro comment_changes, junto com a
+// nova marca a partir da qual continuar. Comentários removidos voltam com
+// Deleted verdadeiro para que o índice externo possa descartá-los. Quando não
+// há mudanças, a marca devolvida é a própria sinceID.
+//
+// ChangedForIndex usa context.Background(); para informar um contexto, use
+// ChangedForIndexContext.
+func (m *CommentModel) ChangedForIndex(sinceID int, limit int) ([]*Comment, int, error) {
+	return m.ChangedForIndexContext(context.Background(), sinceID, limit)
+}
+
+// ChangedForIndexContext é como ChangedForIndex, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ChangedForIndexContext(ctx context.Context, sinceID int, limit int) ([]*Comment, int, error) {
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, sinceID, nil
+	}
+
+	stmt := `SELECT comment_id, MAX(id) AS seq FROM comment_changes
+	         WHERE id > ?
+	         GROUP BY comment_id
+	         ORDER BY seq ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, sinceID, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	watermark := sinceID
+	ids := []int{}
+	for rows.Next() {
+		var id, seq int
+		err = rows.Scan(&id, &seq)
+		if err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+		watermark = seq
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(ids) == 0 {
+		return comments, watermark, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	stmt = `SELECT ` + commentColumns + ` FROM comments c WHERE c.id IN (` + placeholders(len(ids)) + `)`
+
+	rows, err = m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]*Comment, len(ids))
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		found[c.ID] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Mantém a ordem das mudanças e gera marcadores para os removidos
+	for _, id := range ids {
+		c, ok := found[id]
+		if !ok {
+			c = &Comment{ID: id, Deleted: true}
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, watermark, nil
+}
+
+// threadStats são as contagens brutas usadas no cálculo de ThreadHealth.
+type threadStats struct {
+	comments  int
+	removed   int
+	votes     int
+	downvotes int
+	reports   int
+}
+
+// health combina as contagens em taxas e calcula a nota ponderada.
+func (s threadStats) health(w HealthWeights) ThreadHealth {
+	h := ThreadHealth{Comments: s.comments, Votes: s.votes}
+
+	if s.votes > 0 {
+		h.DownvoteRatio = float64(s.downvotes) / float64(s.votes)
+	}
+	if s.comments > 0 {
+		h.ReportDensity = math.Min(float64(s.reports)/float64(s.comments), 1)
+		h.DeletionRate = float64(s.removed) / float64(s.comments)
+	}
+
+	total := w.Downvotes + w.Reports + w.Deletions
+	if total <= 0 {
+		h.Score = 1
+		return h
+	}
+
+	penalty := w.Downvotes*h.DownvoteRatio + w.Reports*h.ReportDensity + w.Deletions*h.DeletionRate
+	h.Score = 1 - penalty/total
+
+	return h
+}
+
+// contentOverlap retorna a proporção das palavras distintas de comment que
+// também aparecem em snippet, ignorando maiúsculas e pontuação. Um comentário
+// sem palavras tem sobreposição 0.
+func contentOverlap(comment, snippet string) float64 {
+	commentTokens := tokenize(comment)
+	if len(commentTokens) == 0 {
+		return 0
+	}
+
+	snippetTokens := tokenize(snippet)
+
+	shared := 0
+	for token := range commentTokens {
+		if snippetTokens[token] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(commentTokens))
+}
+
+// tokenize normaliza um texto em um conjunto de palavras em minúsculas.
+func tokenize(text string) map[string]bool {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		tokens[f] = true
+	}
+	return tokens
+}
+
+// placeholders retorna n marcadores "?" separados por vírgula para uso em
+// cláusulas IN (...).
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// ThreadSummary resume a participação de um usuário na discussão de um
+// snippet.
+type ThreadSummary struct {
+	SnippetID    int
+	SnippetTitle string
+	CommentCount int
+	LastActivity time.Time
+}
+
+// ParticipatedThreads retorna os snippets em que o usuário tem comentários
+// não removidos, com a quantidade deles em cada um e a última atividade da
+// discussão (de qualquer autor), dos mais recentes para os mais antigos.
+//
+// ParticipatedThreads usa context.Background(); para informar um contexto, use
+// ParticipatedThreadsContext.
+func (m *CommentModel) ParticipatedThreads(userID int, limit, offset int) ([]ThreadSummary, error) {
+	return m.ParticipatedThreadsContext(context.Background(), userID, limit, offset)
+}
+
+// ParticipatedThreadsContext é como ParticipatedThreads, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ParticipatedThreadsContext(ctx context.Context, userID int, limit, offset int) ([]ThreadSummary, error) {
+	if limit < 0 || offset < 0 {
+		return nil, ErrInvalidPagination
+	}
+
+	stmt := `SELECT s.id, s.title, COUNT(*),
+	           (SELECT MAX(GREATEST(a.created, COALESCE(a.updated, a.created)))
+	            FROM comments a WHERE a.snippet_id = s.id AND a.deleted = FALSE) AS last_activity
+	         FROM comments c
+	         INNER JOIN snippets s ON s.id = c.snippet_id
+	         WHERE c.author_id = ? AND c.deleted = FALSE AND s.deleted_at IS NULL
+	         GROUP BY s.id, s.title
+	         ORDER BY last_activity DESC, s.id DESC
+	         LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []ThreadSummary{}
+
+	for rows.Next() {
+		var t ThreadSummary
+		err = rows.Scan(&t.SnippetID, &t.SnippetTitle, &t.CommentCount, &t.LastActivity)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return threads, nil
+}
+
+// RankTopN é o tamanho do topo da discussão considerado por RankDeltas.
+// Entrar ou sair dele é o que justifica renotificar ou limpar caches.
+const RankTopN = 10
+
+// RankChange descreve a mudança de posição de um comentário no ranking da
+// discussão. As posições começam em 1; 0 indica que o comentário não
+// estava (Before) ou não está mais (After) no ranking.
+type RankChange struct {
+	CommentID  int
+	Before     int
+	After      int
+	EnteredTop bool
+	LeftTop    bool
+}
+
+// RankDeltas compara um retrato anterior do ranking de um snippet
+// (comentário -> posição) com a ordem atual, por votos, e retorna os
+// comentários que mudaram de posição.
+//
+// RankDeltas usa context.Background(); para informar um contexto, use
+// RankDeltasContext.
+func (m *CommentModel) RankDeltas(snippetID int, before map[int]int) ([]RankChange, error) {
+	return m.RankDeltasContext(context.Background(), snippetID, before)
+}
+
+// RankDeltasContext é como RankDeltas, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) RankDeltasContext(ctx context.Context, snippetID int, before map[int]int) ([]RankChange, error) {
+	stmt := `SELECT id FROM comments
+	         WHERE snippet_id = ? AND deleted = FALSE
+	         ORDER BY upvotes - downvotes DESC, created ASC, id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	after := map[int]int{}
+
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		after[id] = len(after) + 1
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rankDeltas(before, after, RankTopN), nil
+}
+
+// rankDeltas calcula as mudanças entre dois rankings. O resultado vem na
+// ordem atual, seguido dos comentários que saíram do ranking.
+func rankDeltas(before, after map[int]int, topN int) []RankChange {
+	inTop := func(rank int) bool {
+		return rank > 0 && rank <= topN
+	}
+
+	changes := []RankChange{}
+
+	add := func(id int) {
+		b, a := before[id], after[id]
+		if b == a {
+			return
+		}
+		changes = append(changes, RankChange{
+			CommentID:  id,
+			Before:     b,
+			After:      a,
+			EnteredTop: !inTop(b) && inTop(a),
+			LeftTop:    inTop(b) && !inTop(a),
+		})
+	}
+
+	for id := range after {
+		add(id)
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			add(id)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		ci, cj := changes[i], changes[j]
+		if (ci.After == 0) != (cj.After == 0) {
+			return cj.After == 0
+		}
+		if ci.After != cj.After {
+			return ci.After < cj.After
+		}
+		return ci.Before < cj.Before
+	})
+
+	return changes
+}
+
+// Search retorna até limit comentários cujo conteúdo contém query, do mais
+// recente para o mais antigo. Um author vazio busca entre todos os
+// comentários. A busca é literal: curingas do LIKE em query são escapados.
+// Retorna ErrEmptySearch para uma busca vazia.
+//
+// Search usa context.Background(); para informar um contexto, use
+// SearchContext.
+func (m *CommentModel) Search(author string, query string, limit int) ([]*Comment, error) {
+	return m.SearchContext(context.Background(), author, query, limit)
+}
+
+// SearchContext é como Search, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) SearchContext(ctx context.Context, author string, query string, limit int) ([]*Comment, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrEmptySearch
+	}
+
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         WHERE c.deleted = FALSE AND (? = '' OR c.author = ?)
+	           AND c.content LIKE ? ESCAPE '\\'
+	         ORDER BY c.created DESC, c.id DESC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, author, author, likeContains(query), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// GetRecentByAuthor retorna até limit comentários mais recentes do autor em
+// todos os snippets. Um autor sem comentários resulta em uma lista vazia.
+//
+// GetRecentByAuthor usa context.Background(); para informar um contexto, use
+// GetRecentByAuthorContext.
+func (m *CommentModel) GetRecentByAuthor(author string, limit int) ([]*Comment, error) {
+	return m.GetRecentByAuthorContext(context.Background(), author, limit)
+}
+
+// GetRecentByAuthorContext é como GetRecentByAuthor, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetRecentByAuthorContext(ctx context.Context, author string, limit int) ([]*Comment, error) {
+	comments := []*Comment{}
+	if limit < 1 {
+		return comments, nil
+	}
+
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         WHERE c.author = ? AND c.deleted = FALSE
+	         ORDER BY c.created DESC, c.id DESC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, author, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// SnippetCommentCount é o número de comentários de um snippet.
+type SnippetCommentCount struct {
+	SnippetID int
+	Count     int
+}
+
+// GetMostDiscussedSnippets retorna até limit snippets com mais comentários
+// visíveis criados dentro do período since, do mais comentado para o menos
+// comentado.
+//
+// GetMostDiscussedSnippets usa context.Background(); para informar um
+// contexto, use GetMostDiscussedSnippetsContext.
+func (m *CommentModel) GetMostDiscussedSnippets(since time.Duration, limit int) ([]SnippetCommentCount, error) {
+	return m.GetMostDiscussedSnippetsContext(context.Background(), since, limit)
+}
+
+// GetMostDiscussedSnippetsContext é como GetMostDiscussedSnippets, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetMostDiscussedSnippetsContext(ctx context.Context, since time.Duration, limit int) ([]SnippetCommentCount, error) {
+	counts := []SnippetCommentCount{}
+	if limit < 1 {
+		return counts, nil
+	}
+
+	stmt := `SELECT snippet_id, COUNT(*) AS total
+	         FROM comments
+	         WHERE deleted = FALSE AND created >= UTC_TIMESTAMP() - INTERVAL ? SECOND
+	         GROUP BY snippet_id
+	         ORDER BY total DESC, snippet_id ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, int(since.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c SnippetCommentCount
+		err = rows.Scan(&c.SnippetID, &c.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// MaxReportReasonLength é o tamanho máximo do motivo de uma denúncia, em
+// runas.
+const MaxReportReasonLength = 255
+
+// Report registra a denúncia de um usuário contra um comentário. Cada usuário
+// denuncia um comentário uma única vez; uma segunda denúncia retorna
+// ErrAlreadyReported. O motivo é obrigatório e limitado a
+// MaxReportReasonLength runas (ErrInvalidReason). Retorna ErrNoRecord se o
+// comentário não existir.
+//
+// Report usa context.Background(); para informar um contexto, use
+// ReportContext.
+func (m *CommentModel) Report(commentID, reporterID int, reason string) error {
+	return m.ReportContext(context.Background(), commentID, reporterID, reason)
+}
+
+// ReportContext é como Report, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ReportContext(ctx context.Context, commentID, reporterID int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxReportReasonLength {
+		return ErrInvalidReason
+	}
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ? AND deleted = FALSE)`, commentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt := `INSERT INTO comment_reports (comment_id, user_id, reason, created)
+	         VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.ExecContext(ctx, stmt, commentID, reporterID, reason)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 {
+			return ErrAlreadyReported
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetReported retorna a fila da moderação: os comentários com pelo menos
+// minReports denúncias, do mais denunciado para o menos denunciado.
+//
+// GetReported usa context.Background(); para informar um contexto, use
+// GetReportedContext.
+func (m *CommentModel) GetReported(minReports int) ([]*Comment, error) {
+	return m.GetReportedContext(context.Background(), minReports)
+}
+
+// GetReportedContext é como GetReported, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetReportedContext(ctx context.Context, minReports int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         INNER JOIN (
+	           SELECT comment_id, COUNT(*) AS reports FROM comment_reports GROUP BY comment_id
+	         ) r ON r.comment_id = c.id
+	         WHERE c.deleted = FALSE AND r.reports >= ?
+	         ORDER BY r.reports DESC, c.id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, minReports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CommentReport é uma denúncia feita com Report.
+type CommentReport struct {
+	ID        int
+	CommentID int
+	UserID    int
+	Reason    string
+	Created   time.Time
+}
+
+// ReportsByCommentIDs retorna as denúncias de cada um dos comentários
+// informados, da mais antiga para a mais recente, indexadas pelo ID do
+// comentário. Comentários sem denúncias não aparecem no mapa.
+//
+// ReportsByCommentIDs usa context.Background(); para informar um contexto,
+// use ReportsByCommentIDsContext.
+func (m *CommentModel) ReportsByCommentIDs(ids []int) (map[int][]*CommentReport, error) {
+	return m.ReportsByCommentIDsContext(context.Background(), ids)
+}
+
+// ReportsByCommentIDsContext é como ReportsByCommentIDs, mas usa ctx nas
+// consultas ao banco.
+func (m *CommentModel) ReportsByCommentIDsContext(ctx context.Context, ids []int) (map[int][]*CommentReport, error) {
+	reports := map[int][]*CommentReport{}
+	if len(ids) == 0 {
+		return reports, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	stmt := `SELECT id, comment_id, user_id, reason, created FROM comment_reports
+	         WHERE comment_id IN (` + placeholders(len(ids)) + `)
+	         ORDER BY created ASC, id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &CommentReport{}
+		err = rows.Scan(&r.ID, &r.CommentID, &r.UserID, &r.Reason, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		reports[r.CommentID] = append(reports[r.CommentID], r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// DismissReports descarta as denúncias de um comentário, tirando-o da fila
+// da moderação, e retorna quantas foram descartadas.
+//
+// DismissReports usa context.Background(); para informar um contexto, use
+// DismissReportsContext.
+func (m *CommentModel) DismissReports(commentID int) (int, error) {
+	return m.DismissReportsContext(context.Background(), commentID)
+}
+
+// DismissReportsContext é como DismissReports, mas usa ctx nas consultas ao
+// banco.
+func (m *CommentModel) DismissReportsContext(ctx context.Context, commentID int) (int, error) {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM comment_reports WHERE comment_id = ?`, commentID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// Hide marca um comentário como rejeitado, com o motivo informado, e
+// descarta as suas denúncias. Retorna ErrNoRecord se o comentário não existir
+// ou já tiver sido removido.
+//
+// Hide usa context.Background(); para informar um contexto, use HideContext.
+func (m *CommentModel) Hide(commentID int, reason string) error {
+	return m.HideContext(context.Background(), commentID, reason)
+}
+
+// HideContext é como Hide, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) HideContext(ctx context.Context, commentID int, reason string) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ? AND deleted = FALSE)`, commentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt := `UPDATE comments SET status = 'rejected', status_note = NULLIF(?, '') WHERE id = ?`
+
+	_, err = tx.ExecContext(ctx, stmt, strings.TrimSpace(reason), commentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM comment_reports WHERE comment_id = ?`, commentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Hold retém um comentário para a moderação, com o motivo informado. Um
+// comentário retido só aparece para o autor e para a moderação até ser
+// aprovado com Approve ou rejeitado com Hide. Retorna ErrNoRecord se o
+// comentário não existir ou já tiver sido removido.
+//
+// Hold usa context.Background(); para informar um contexto, use HoldContext.
+func (m *CommentModel) Hold(commentID int, reason string) error {
+	return m.HoldContext(context.Background(), commentID, reason)
+}
+
+// HoldContext é como Hold, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) HoldContext(ctx context.Context, commentID int, reason string) error {
+	return m.setStatus(ctx, commentID, StatusPending, reason)
+}
+
+// Approve publica um comentário retido por Hold, ou devolve à discussão um
+// rejeitado. Retorna ErrNoRecord se o comentário não existir ou já tiver
+// sido removido.
+//
+// Approve usa context.Background(); para informar um contexto, use
+// ApproveContext.
+func (m *CommentModel) Approve(commentID int) error {
+	return m.ApproveContext(context.Background(), commentID)
+}
+
+// ApproveContext é como Approve, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) ApproveContext(ctx context.Context, commentID int) error {
+	return m.setStatus(ctx, commentID, StatusApproved, "")
+}
+
+// setStatus muda o estado de moderação de um comentário não removido.
+func (m *CommentModel) setStatus(ctx context.Context, commentID int, status, note string) error {
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM comments WHERE id = ? AND deleted = FALSE)`, commentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	stmt := `UPDATE comments SET status = ?, status_note = NULLIF(?, '') WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, stmt, status, strings.TrimSpace(note), commentID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetHeld retorna os comentários retidos para a moderação, dos mais antigos
+// para os mais novos.
+//
+// GetHeld usa context.Background(); para informar um contexto, use
+// GetHeldContext.
+func (m *CommentModel) GetHeld() ([]*Comment, error) {
+	return m.GetHeldContext(context.Background())
+}
+
+// GetHeldContext é como GetHeld, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) GetHeldContext(ctx context.Context) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	         FROM comments c
+	         WHERE c.deleted = FALSE AND c.status = 'pending'
+	         ORDER BY c.created ASC, c.id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// AuthorScore é o total de upvotes dos comentários de um autor.
+type AuthorScore struct {
+	Author       string
+	TotalUpvotes int
+}
+
+// TopAuthorsBySnippet retorna até limit autores com mais upvotes somados nos
+// seus comentários visíveis de um snippet, do maior total para o menor.
+// Retorna uma lista vazia se o snippet não tiver comentários.
+//
+// TopAuthorsBySnippet usa context.Background(); para informar um contexto, use
+// TopAuthorsBySnippetContext.
+func (m *CommentModel) TopAuthorsBySnippet(snippetID int, limit int) ([]AuthorScore, error) {
+	return m.TopAuthorsBySnippetContext(context.Background(), snippetID, limit)
+}
+
+// TopAuthorsBySnippetContext é como TopAuthorsBySnippet, mas usa ctx nas consultas ao banco.
+func (m *CommentModel) TopAuthorsBySnippetContext(ctx context.Context, snippetID int, limit int) ([]AuthorScore, error) {
+	scores := []AuthorScore{}
+	if limit < 1 {
+		return scores, nil
+	}
+
+	stmt := `SELECT author, SUM(upvotes) AS total
+	         FROM comments
+	         WHERE snippet_id = ? AND deleted = FALSE
+	         GROUP BY author
+	         ORDER BY total DESC, author ASC
+	         LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s AuthorScore
+		err = rows.Scan(&s.Author, &s.TotalUpvotes)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
 }
@@ -0,0 +1,2615 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestThreadStatsHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   threadStats
+		weights HealthWeights
+		want    float64
+	}{
+		{
+			name:    "Empty thread",
+			stats:   threadStats{},
+			weights: DefaultHealthWeights,
+			want:    1,
+		},
+		{
+			name:    "Only upvotes",
+			stats:   threadStats{comments: 4, votes: 10},
+			weights: DefaultHealthWeights,
+			want:    1,
+		},
+		{
+			name:    "Half downvotes",
+			stats:   threadStats{comments: 4, votes: 10, downvotes: 5},
+			weights: HealthWeights{Downvotes: 1},
+			want:    0.5,
+		},
+		{
+			name:    "Blended signals",
+			stats:   threadStats{comments: 4, removed: 2, votes: 4, downvotes: 2, reports: 2},
+			weights: HealthWeights{Downvotes: 1, Reports: 2, Deletions: 1},
+			want:    0.5,
+		},
+		{
+			name:    "Report density capped",
+			stats:   threadStats{comments: 1, reports: 5},
+			weights: HealthWeights{Reports: 1},
+			want:    0,
+		},
+		{
+			name:    "No weights",
+			stats:   threadStats{comments: 1, removed: 1},
+			weights: HealthWeights{},
+			want:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.stats.health(tt.weights)
+
+			assert.Equal(t, h.Score, tt.want)
+		})
+	}
+}
+
+func TestPlaceReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		chain     []int
+		wantDepth int
+		wantLast  int
+	}{
+		{
+			name:      "Reply to top-level comment",
+			chain:     []int{1},
+			wantDepth: 1,
+			wantLast:  1,
+		},
+		{
+			name:      "Reply beyond max depth",
+			chain:     []int{1, 4, 7},
+			wantDepth: MaxReplyDepth,
+			wantLast:  MaxReplyDepth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depth, ancestors := placeReply(tt.chain)
+
+			assert.Equal(t, depth, tt.wantDepth)
+			assert.Equal(t, len(ancestors), tt.wantDepth)
+			assert.Equal(t, ancestors[len(ancestors)-1], tt.chain[tt.wantLast-1])
+		})
+	}
+}
+
+func TestCommentIsEditableBy(t *testing.T) {
+	created := time.Date(2023, 4, 16, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		comment Comment
+		userID  int
+		now     time.Time
+		want    bool
+	}{
+		{
+			name:    "Author within window",
+			comment: Comment{AuthorID: 1, Created: created},
+			userID:  1,
+			now:     created.Add(EditWindow - time.Second),
+			want:    true,
+		},
+		{
+			name:    "Exactly at window expiry",
+			comment: Comment{AuthorID: 1, Created: created},
+			userID:  1,
+			now:     created.Add(EditWindow),
+			want:    false,
+		},
+		{
+			name:    "Another user",
+			comment: Comment{AuthorID: 1, Created: created},
+			userID:  2,
+			now:     created,
+			want:    false,
+		},
+		{
+			name:    "Locked comment",
+			comment: Comment{AuthorID: 1, Created: created, Locked: true},
+			userID:  1,
+			now:     created,
+			want:    false,
+		},
+		{
+			name:    "Unknown author",
+			comment: Comment{Created: created},
+			userID:  0,
+			now:     created,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.comment.IsEditableBy(tt.userID, tt.now), tt.want)
+		})
+	}
+}
+
+func TestCommentScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment Comment
+		want    int
+	}{
+		{
+			name:    "No votes",
+			comment: Comment{},
+			want:    0,
+		},
+		{
+			name:    "More upvotes",
+			comment: Comment{Upvotes: 12, Downvotes: 3},
+			want:    9,
+		},
+		{
+			name:    "More downvotes",
+			comment: Comment{Upvotes: 1, Downvotes: 4},
+			want:    -3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.comment.Score(), tt.want)
+		})
+	}
+}
+
+func TestCommentControversyScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment Comment
+		want    float64
+	}{
+		{
+			name:    "No votes",
+			comment: Comment{},
+			want:    0,
+		},
+		{
+			name:    "Only upvotes",
+			comment: Comment{Upvotes: 50},
+			want:    0,
+		},
+		{
+			name:    "Evenly split",
+			comment: Comment{Upvotes: 10, Downvotes: 10},
+			want:    20,
+		},
+		{
+			name:    "Mostly upvotes",
+			comment: Comment{Upvotes: 12, Downvotes: 3},
+			want:    3.75,
+		},
+		{
+			name:    "Mostly downvotes",
+			comment: Comment{Upvotes: 2, Downvotes: 8},
+			want:    2.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.comment.ControversyScore(), tt.want)
+		})
+	}
+}
+
+func TestVoteResultString(t *testing.T) {
+	tests := []struct {
+		name   string
+		result VoteResult
+		want   string
+	}{
+		{
+			name:   "Added",
+			result: VoteResult{Action: ActionAdded, Type: "upvote"},
+			want:   "Vote successfully registered!",
+		},
+		{
+			name:   "Removed",
+			result: VoteResult{Action: ActionRemoved, Type: "downvote"},
+			want:   "Vote removed!",
+		},
+		{
+			name:   "Changed to upvote",
+			result: VoteResult{Action: ActionChanged, Type: "upvote"},
+			want:   "Vote updated to upvote!",
+		},
+		{
+			name:   "Changed to downvote",
+			result: VoteResult{Action: ActionChanged, Type: "downvote"},
+			want:   "Vote updated to downvote!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.result.String(), tt.want)
+		})
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    error
+	}{
+		{
+			name:    "Valid",
+			content: "What a lovely haiku",
+			want:    nil,
+		},
+		{
+			name:    "Empty",
+			content: "",
+			want:    ErrCommentEmpty,
+		},
+		{
+			name:    "Whitespace only",
+			content: " \t\n ",
+			want:    ErrCommentEmpty,
+		},
+		{
+			name:    "Exactly at the limit in multibyte runes",
+			content: strings.Repeat("é", MaxCommentLength),
+			want:    nil,
+		},
+		{
+			name:    "Over the limit",
+			content: strings.Repeat("a", MaxCommentLength+1),
+			want:    ErrCommentTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, validateContent(tt.content), tt.want)
+		})
+	}
+}
+
+func TestNestReplies(t *testing.T) {
+	ids := func(comments []*Comment) string {
+		var b strings.Builder
+		for _, c := range comments {
+			fmt.Fprintf(&b, "%d[", c.ID)
+			for _, r := range c.Replies {
+				fmt.Fprintf(&b, "%d", r.ID)
+			}
+			b.WriteString("]")
+		}
+		return b.String()
+	}
+
+	parent := func(id int) *int {
+		return &id
+	}
+
+	tests := []struct {
+		name string
+		flat []*Comment
+		want string
+	}{
+		{
+			name: "Empty",
+			flat: []*Comment{},
+			want: "",
+		},
+		{
+			name: "Top-level only",
+			flat: []*Comment{{ID: 1}, {ID: 2}},
+			want: "1[]2[]",
+		},
+		{
+			name: "Replies follow their parent",
+			flat: []*Comment{{ID: 1}, {ID: 3, ParentID: parent(1)}, {ID: 2}, {ID: 4, ParentID: parent(2)}, {ID: 5, ParentID: parent(2)}},
+			want: "1[3]2[45]",
+		},
+		{
+			name: "Orphaned reply",
+			flat: []*Comment{{ID: 1}, {ID: 3, ParentID: parent(9)}},
+			want: "1[]3[]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ids(NestReplies(tt.flat)), tt.want)
+		})
+	}
+}
+
+func TestThreadOrderBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    SortOrder
+		contains string
+		wantErr  error
+	}{
+		{
+			name:     "Oldest",
+			order:    SortOldest,
+			contains: "r.created ASC",
+		},
+		{
+			name:     "Newest",
+			order:    SortNewest,
+			contains: "r.created DESC",
+		},
+		{
+			name:     "Top breaks ties by creation",
+			order:    SortTop,
+			contains: "r.upvotes - r.downvotes DESC, r.created ASC",
+		},
+		{
+			name:     "Controversial",
+			order:    SortControversial,
+			contains: "ELSE 0 END DESC, r.created ASC",
+		},
+		{
+			name:    "Unknown order",
+			order:   SortOrder("created; DROP TABLE comments"),
+			wantErr: ErrInvalidSortOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderBy, err := threadOrderBy(tt.order)
+
+			assert.Equal(t, err, tt.wantErr)
+			assert.StringContains(t, orderBy, tt.contains)
+		})
+	}
+}
+
+func TestContentOverlap(t *testing.T) {
+	const snippet = "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again."
+
+	tests := []struct {
+		name    string
+		comment string
+		want    float64
+	}{
+		{
+			name:    "Verbatim copy",
+			comment: snippet,
+			want:    1,
+		},
+		{
+			name:    "Copy with different case and punctuation",
+			comment: "an OLD silent pond - a frog jumps into the pond; splash, silence again",
+			want:    1,
+		},
+		{
+			name:    "Half overlapping",
+			comment: "frog pond lovely haiku",
+			want:    0.5,
+		},
+		{
+			name:    "Unrelated",
+			comment: "Lovely haiku indeed",
+			want:    0,
+		},
+		{
+			name:    "Only punctuation",
+			comment: "!!!",
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, contentOverlap(tt.comment, snippet), tt.want)
+		})
+	}
+}
+
+func TestLikeContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "Plain text",
+			value: "buy now",
+			want:  "%buy now%",
+		},
+		{
+			name:  "Wildcards",
+			value: "100%_off",
+			want:  `%100\%\_off%`,
+		},
+		{
+			name:  "Backslash",
+			value: `C:\temp`,
+			want:  `%C:\\temp%`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, likeContains(tt.value), tt.want)
+		})
+	}
+}
+
+func TestRankDeltas(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[int]int
+		after  map[int]int
+		topN   int
+		want   []RankChange
+	}{
+		{
+			name:   "Unchanged",
+			before: map[int]int{1: 1, 2: 2},
+			after:  map[int]int{1: 1, 2: 2},
+			topN:   10,
+			want:   []RankChange{},
+		},
+		{
+			name:   "Swap inside top",
+			before: map[int]int{1: 1, 2: 2},
+			after:  map[int]int{2: 1, 1: 2},
+			topN:   10,
+			want: []RankChange{
+				{CommentID: 2, Before: 2, After: 1},
+				{CommentID: 1, Before: 1, After: 2},
+			},
+		},
+		{
+			name:   "Crossing the top boundary",
+			before: map[int]int{1: 1, 2: 2, 3: 3},
+			after:  map[int]int{3: 1, 1: 2, 2: 3},
+			topN:   2,
+			want: []RankChange{
+				{CommentID: 3, Before: 3, After: 1, EnteredTop: true},
+				{CommentID: 1, Before: 1, After: 2},
+				{CommentID: 2, Before: 2, After: 3, LeftTop: true},
+			},
+		},
+		{
+			name:   "New and removed comments",
+			before: map[int]int{1: 1, 2: 2},
+			after:  map[int]int{3: 1, 1: 2},
+			topN:   10,
+			want: []RankChange{
+				{CommentID: 3, Before: 0, After: 1, EnteredTop: true},
+				{CommentID: 1, Before: 1, After: 2},
+				{CommentID: 2, Before: 2, After: 0, LeftTop: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rankDeltas(tt.before, tt.after, tt.topN)
+
+			assert.Equal(t, len(got), len(tt.want))
+			for i := range tt.want {
+				if i < len(got) {
+					assert.Equal(t, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCommentModelShadowban(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name     string
+		viewerID int
+		want     int
+	}{
+		{
+			name:     "Anonymous viewer",
+			viewerID: 0,
+			want:     1,
+		},
+		{
+			name:     "Other user",
+			viewerID: 1,
+			want:     1,
+		},
+		{
+			name:     "Banned author",
+			viewerID: 2,
+			want:     2,
+		},
+		{
+			name:     "Moderator",
+			viewerID: 3,
+			want:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created, role) VALUES
+				('Bob', 'bob@example.com', '', UTC_TIMESTAMP(), 'user'),
+				('Carol', 'carol@example.com', '', UTC_TIMESTAMP(), 'moderator')`)
+			assert.NilError(t, err)
+
+			m := &CommentModel{DB: db}
+
+			_, err = m.Insert(1, 1, "Alice", "First!")
+			assert.NilError(t, err)
+
+			err = m.SetShadowban(2, true)
+			assert.NilError(t, err)
+
+			_, err = m.Insert(1, 2, "Bob", "Buy cheap watches")
+			assert.NilError(t, err)
+
+			comments, err := m.GetBySnippetIDForViewer(1, tt.viewerID)
+			assert.NilError(t, err)
+			assert.Equal(t, len(comments), tt.want)
+		})
+	}
+}
+
+func TestCommentModelGetBySnippetIDSkipsShadowbanned(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	_, err = m.Insert(1, 2, "Bob", "Buy cheap watches")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.SetShadowban(2, true))
+
+	// A lista pública, usada pelo feed e pela API, é a de um visitante
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, first)
+
+	assert.NilError(t, m.SetShadowban(2, false))
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+}
+
+func TestCommentModelByAuthor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	m := &CommentModel{DB: db}
+
+	public, err := snippets.Insert("Public", "Content", 7, 1)
+	assert.NilError(t, err)
+	private, err := snippets.InsertWithOptions("Private", "Content", SnippetOptions{Visibility: VisibilityPrivate}, 7, 1)
+	assert.NilError(t, err)
+
+	first, err := m.Insert(public, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	second, err := m.Insert(public, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+	_, err = m.Insert(private, 1, "Alice", "Hidden")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(public, 1, "Alice", "Deleted")
+	assert.NilError(t, err)
+	assert.NilError(t, m.Delete(deleted, 1))
+
+	comments, total, err := m.ByAuthor(1, 0, 1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, second)
+	assert.Equal(t, comments[0].SnippetTitle, "Public")
+
+	comments, total, err = m.ByAuthor(1, 0, 2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, comments[0].ID, first)
+
+	comments, total, err = m.ByAuthor(1, 0, 3, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, len(comments), 0)
+
+	_, _, err = m.ByAuthor(1, 0, 0, 1)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
+
+func TestCommentModelGetBySnippetIDForViewerSorted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	second, err := m.Insert(1, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+
+	third, err := m.Insert(1, 1, "Alice", "Third!")
+	assert.NilError(t, err)
+
+	_, err = m.Upvote(second, 2)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		order SortOrder
+		want  []int
+	}{
+		{order: SortOldest, want: []int{first, second, third}},
+		{order: SortNewest, want: []int{third, second, first}},
+		{order: SortTop, want: []int{second, first, third}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			comments, err := m.GetBySnippetIDForViewerSorted(1, 0, tt.order)
+			assert.NilError(t, err)
+			assert.Equal(t, len(comments), len(tt.want))
+			for i, c := range comments {
+				assert.Equal(t, c.ID, tt.want[i])
+			}
+		})
+	}
+
+	_, err = m.GetBySnippetIDForViewerSorted(1, 0, SortOrder("random"))
+	assert.Equal(t, err, ErrInvalidSortOrder)
+}
+
+func TestCommentModelSoftDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	_, err = m.Upvote(id, 2)
+	assert.NilError(t, err)
+
+	err = m.Delete(id, 1)
+	assert.NilError(t, err)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+
+	comments, err = m.GetBySnippetIDIncludingDeleted(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].Deleted, true)
+	assert.Equal(t, comments[0].DeletedAt.Valid, true)
+
+	var votes int
+	err = db.QueryRow(`SELECT COUNT(*) FROM comment_votes WHERE comment_id = ?`, id).Scan(&votes)
+	assert.NilError(t, err)
+	assert.Equal(t, votes, 1)
+
+	err = m.Restore(id)
+	assert.NilError(t, err)
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+
+	assert.Equal(t, m.Restore(id), ErrNoRecord)
+}
+
+func TestCommentModelChangedForIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	kept, err := m.Insert(1, 1, "Alice", "Kept")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(1, 1, "Alice", "Deleted")
+	assert.NilError(t, err)
+
+	comments, watermark, err := m.ChangedForIndex(0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, kept)
+	assert.Equal(t, comments[1].ID, deleted)
+
+	// Sem mudanças, a marca não avança
+	comments, next, err := m.ChangedForIndex(watermark, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+	assert.Equal(t, next, watermark)
+
+	err = m.Delete(deleted, 1)
+	assert.NilError(t, err)
+
+	comments, watermark, err = m.ChangedForIndex(watermark, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, deleted)
+	assert.Equal(t, comments[0].Deleted, true)
+
+	err = m.Restore(deleted)
+	assert.NilError(t, err)
+
+	comments, _, err = m.ChangedForIndex(watermark, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, deleted)
+	assert.Equal(t, comments[0].Deleted, false)
+}
+
+func TestCommentModelCountByStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	counts, err := m.CountByStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, len(counts), 3)
+	assert.Equal(t, counts[StatusApproved], 0)
+	assert.Equal(t, counts[StatusPending], 0)
+	assert.Equal(t, counts[StatusRejected], 0)
+
+	_, err = m.Insert(1, 1, "Alice", "Approved")
+	assert.NilError(t, err)
+	held, err := m.Insert(1, 1, "Alice", "Held")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(1, 1, "Alice", "Deleted")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Hold(held, "Spam"))
+	assert.NilError(t, m.Delete(deleted, 1))
+
+	counts, err = m.CountByStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, counts[StatusApproved], 1)
+	assert.Equal(t, counts[StatusPending], 1)
+	assert.Equal(t, counts[StatusRejected], 0)
+}
+
+func TestCommentModelParticipatedThreads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	m := &CommentModel{DB: db}
+
+	kept, err := snippets.Insert("Kept", "Content", 7, 1)
+	assert.NilError(t, err)
+	left, err := snippets.Insert("Left", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	_, err = m.Insert(kept, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(kept, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+	only, err := m.Insert(left, 1, "Alice", "Only one")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Delete(deleted, 1))
+	assert.NilError(t, m.Delete(only, 1))
+
+	threads, err := m.ParticipatedThreads(1, 10, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(threads), 1)
+	assert.Equal(t, threads[0].SnippetID, kept)
+	assert.Equal(t, threads[0].SnippetTitle, "Kept")
+	assert.Equal(t, threads[0].CommentCount, 1)
+
+	_, err = m.ParticipatedThreads(1, -1, 0)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
+
+func TestCommentModelThreadHealth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	ids := make([]int, 4)
+	for i := range ids {
+		id, err := m.Insert(1, 1, "Alice", fmt.Sprintf("Comment %d", i))
+		assert.NilError(t, err)
+		ids[i] = id
+	}
+
+	assert.NilError(t, m.Delete(ids[0], 1))
+	assert.NilError(t, m.Hide(ids[1], "Off topic"))
+
+	h, err := m.ThreadHealth(1)
+	assert.NilError(t, err)
+	assert.Equal(t, h.Comments, 4)
+	assert.Equal(t, h.DeletionRate, 0.5)
+}
+
+func TestCommentModelGetBySnippetIDUnbounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	const n = 150
+	for i := 0; i < n; i++ {
+		_, err := m.Insert(1, 1, "Alice", fmt.Sprintf("Comment %d", i))
+		assert.NilError(t, err)
+	}
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), n)
+}
+
+func TestCommentModelGetBySnippetIDPaginated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		offset  int
+		want    int
+		wantErr error
+	}{
+		{
+			name:  "First page",
+			limit: 2,
+			want:  2,
+		},
+		{
+			name:   "Last page",
+			limit:  2,
+			offset: 2,
+			want:   1,
+		},
+		{
+			name:   "Past the end",
+			limit:  2,
+			offset: 10,
+			want:   0,
+		},
+		{
+			name:    "Negative offset",
+			limit:   2,
+			offset:  -1,
+			wantErr: ErrInvalidPagination,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := &CommentModel{DB: db}
+
+			for _, content := range []string{"One", "Two", "Three"} {
+				_, err := m.Insert(1, 1, "Alice", content)
+				assert.NilError(t, err)
+			}
+
+			comments, total, err := m.GetBySnippetIDPaginated(1, tt.limit, tt.offset)
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.Equal(t, len(comments), tt.want)
+			assert.Equal(t, total, 3)
+		})
+	}
+}
+
+func TestCommentModelGetBySnippetIDPaged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	for _, content := range []string{"One", "Two", "Three"} {
+		_, err := m.Insert(1, 1, "Alice", content)
+		assert.NilError(t, err)
+	}
+
+	comments, total, err := m.GetBySnippetIDPaged(1, 2, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, total, 3)
+
+	_, _, err = m.GetBySnippetIDPaged(1, 0, 2)
+	assert.Equal(t, err, ErrInvalidPagination)
+
+	// The viewer variant counts only what the viewer can see, even past the
+	// last page.
+	comments, total, err = m.GetBySnippetIDForViewerPaged(1, 0, SortNewest, 1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].Content, "Three")
+	assert.Equal(t, total, 3)
+
+	comments, total, err = m.GetBySnippetIDForViewerPaged(1, 0, SortNewest, 5, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+	assert.Equal(t, total, 3)
+}
+
+func TestCommentModelGetUserVotes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	up, err := m.Insert(1, 1, "Alice", "One")
+	assert.NilError(t, err)
+	down, err := m.Insert(1, 1, "Alice", "Two")
+	assert.NilError(t, err)
+	none, err := m.Insert(1, 1, "Alice", "Three")
+	assert.NilError(t, err)
+
+	_, err = m.Upvote(up, 2)
+	assert.NilError(t, err)
+	_, err = m.Downvote(down, 2)
+	assert.NilError(t, err)
+
+	votes, err := m.GetUserVotes(2, []int{up, down, none})
+	assert.NilError(t, err)
+	assert.Equal(t, len(votes), 2)
+	assert.Equal(t, votes[up], "upvote")
+	assert.Equal(t, votes[down], "downvote")
+
+	votes, err = m.GetUserVotes(1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(votes), 0)
+}
+
+func TestCommentModelGetByIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "One")
+	assert.NilError(t, err)
+	second, err := m.Insert(2, 1, "Alice", "Two")
+	assert.NilError(t, err)
+
+	comments, err := m.GetByIDs([]int{first, second, second + 100})
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[first].Content, "One")
+	assert.Equal(t, comments[second].SnippetID, 2)
+
+	comments, err = m.GetByIDs(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelInsertReply(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	second, err := m.Insert(1, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+
+	reply, err := m.InsertReply(1, first, 1, "Alice", "Reply to first")
+	assert.NilError(t, err)
+
+	// Uma resposta a uma resposta fica sob o mesmo comentário de primeiro nível
+	nested, err := m.InsertReply(1, reply, 1, "Alice", "Reply to reply")
+	assert.NilError(t, err)
+
+	_, err = m.InsertReply(2, first, 1, "Alice", "Wrong snippet")
+	assert.Equal(t, err, ErrNoRecord)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 4)
+
+	want := []int{first, reply, nested, second}
+	for i, c := range comments {
+		assert.Equal(t, c.ID, want[i])
+	}
+	assert.Equal(t, *comments[2].ParentID, first)
+}
+
+func TestCommentModelVoteScore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	steps := []struct {
+		upvote  bool
+		score   int
+		message string
+	}{
+		{upvote: true, score: 1, message: "Vote successfully registered!"},
+		{upvote: false, score: -1, message: "Vote updated to downvote!"},
+		{upvote: false, score: 0, message: "Vote removed!"},
+		{upvote: true, score: 1, message: "Vote successfully registered!"},
+		{upvote: true, score: 0, message: "Vote removed!"},
+	}
+
+	for _, step := range steps {
+		var result VoteResult
+		if step.upvote {
+			result, err = m.Upvote(id, 2)
+		} else {
+			result, err = m.Downvote(id, 2)
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, result.Score, step.score)
+		assert.Equal(t, result.String(), step.message)
+	}
+}
+
+func TestCommentModelConcurrentVotes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+
+	vote := func(userID int, upvote bool) {
+		defer wg.Done()
+		var err error
+		if upvote {
+			_, err = m.Upvote(id, userID)
+		} else {
+			_, err = m.Downvote(id, userID)
+		}
+		errs <- err
+	}
+
+	// Ten users upvote, five downvote, and one user toggles an upvote an
+	// even number of times, all at once.
+	for userID := 2; userID <= 11; userID++ {
+		wg.Add(1)
+		go vote(userID, true)
+	}
+	for userID := 12; userID <= 16; userID++ {
+		wg.Add(1)
+		go vote(userID, false)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go vote(17, true)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+
+	counts := func() (upvotes, downvotes, rows int) {
+		err := db.QueryRow(`SELECT upvotes, downvotes FROM comments WHERE id = ?`, id).Scan(&upvotes, &downvotes)
+		assert.NilError(t, err)
+		err = db.QueryRow(`SELECT COUNT(*) FROM comment_votes WHERE comment_id = ?`, id).Scan(&rows)
+		assert.NilError(t, err)
+		return upvotes, downvotes, rows
+	}
+
+	upvotes, downvotes, rows := counts()
+	assert.Equal(t, upvotes, 10)
+	assert.Equal(t, downvotes, 5)
+	assert.Equal(t, rows, 15)
+
+	// RecalculateVotes repairs counters that drifted from comment_votes
+	_, err = db.Exec(`UPDATE comments SET upvotes = 99, downvotes = 0 WHERE id = ?`, id)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.RecalculateVotes(id))
+
+	upvotes, downvotes, _ = counts()
+	assert.Equal(t, upvotes, 10)
+	assert.Equal(t, downvotes, 5)
+
+	assert.Equal(t, m.RecalculateVotes(id+1), ErrNoRecord)
+
+	// RecalculateAllVotes repairs every comment, and only counts those it fixed
+	_, err = db.Exec(`UPDATE comments SET upvotes = 0, downvotes = 99 WHERE id = ?`, id)
+	assert.NilError(t, err)
+
+	fixed, err := m.RecalculateAllVotes()
+	assert.NilError(t, err)
+	assert.Equal(t, fixed, 1)
+
+	upvotes, downvotes, _ = counts()
+	assert.Equal(t, upvotes, 10)
+	assert.Equal(t, downvotes, 5)
+}
+
+func TestCommentModelMinScore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	floor := -1
+	m := &CommentModel{DB: db, MinScore: &floor}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	steps := []struct {
+		name   string
+		userID int
+		upvote bool
+		score  int
+		action VoteAction
+	}{
+		{name: "Down to floor", userID: 2, score: -1, action: ActionAdded},
+		{name: "Below floor", userID: 3, score: -1, action: ActionAdded},
+		{name: "Remove uncounted", userID: 3, score: -1, action: ActionRemoved},
+		{name: "Below floor again", userID: 3, score: -1, action: ActionAdded},
+		{name: "Change counted", userID: 2, upvote: true, score: 1, action: ActionChanged},
+		{name: "Change uncounted", userID: 3, upvote: true, score: 2, action: ActionChanged},
+		{name: "Change back down", userID: 2, score: 0, action: ActionChanged},
+	}
+
+	for _, step := range steps {
+		var result VoteResult
+		if step.upvote {
+			result, err = m.Upvote(id, step.userID)
+		} else {
+			result, err = m.Downvote(id, step.userID)
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, result.Score, step.score)
+		assert.Equal(t, result.Action, step.action)
+	}
+
+	// Os votos abaixo do piso continuam registrados
+	votes, err := m.GetUserVotes(3, []int{id})
+	assert.NilError(t, err)
+	assert.Equal(t, votes[id], "upvote")
+
+	_, err = m.Downvote(id, 3)
+	assert.NilError(t, err)
+
+	votes, err = m.GetUserVotes(3, []int{id})
+	assert.NilError(t, err)
+	assert.Equal(t, votes[id], "downvote")
+
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Score(), -1)
+}
+
+func TestCommentModelCountBySnippetIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	for _, snippetID := range []int{1, 1, 2} {
+		_, err := m.Insert(snippetID, 1, "Alice", "Hello")
+		assert.NilError(t, err)
+	}
+
+	// Comentários retidos para moderação não são contados
+	held, err := m.Insert(1, 1, "Alice", "Buy cheap watches")
+	assert.NilError(t, err)
+	assert.NilError(t, m.Hold(held, "Spam"))
+	held, err = m.Insert(2, 1, "Alice", "Buy cheap watches")
+	assert.NilError(t, err)
+	assert.NilError(t, m.Hold(held, "Spam"))
+
+	count, err := m.CountBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+
+	counts, err := m.CountBySnippetIDs([]int{1, 2, 3})
+	assert.NilError(t, err)
+	assert.Equal(t, len(counts), 3)
+	assert.Equal(t, counts[1], 2)
+	assert.Equal(t, counts[2], 1)
+	assert.Equal(t, counts[3], 0)
+}
+
+func TestCommentModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		author  string
+		query   string
+		want    int
+		wantErr error
+	}{
+		{
+			name:  "Any author",
+			query: "haiku",
+			want:  2,
+		},
+		{
+			name:   "Single author",
+			author: "Bob",
+			query:  "haiku",
+			want:   1,
+		},
+		{
+			name:  "Wildcards are literal",
+			query: "100%",
+			want:  1,
+		},
+		{
+			name:    "Empty query",
+			query:   "  ",
+			wantErr: ErrEmptySearch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := &CommentModel{DB: db}
+
+			for _, c := range []struct{ author, content string }{
+				{"Alice", "Lovely haiku"},
+				{"Bob", "Another haiku"},
+				{"Bob", "100% agree"},
+				{"Bob", "1000 times yes"},
+			} {
+				_, err := m.Insert(1, 1, c.author, c.content)
+				assert.NilError(t, err)
+			}
+
+			comments, err := m.Search(tt.author, tt.query, 10)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, len(comments), tt.want)
+		})
+	}
+}
+
+func TestCommentModelSelfVote(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	_, err = m.Upvote(id, 1)
+	assert.Equal(t, err, ErrSelfVote)
+
+	_, err = m.Downvote(id, 1)
+	assert.Equal(t, err, ErrSelfVote)
+
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Upvotes, 0)
+	assert.Equal(t, c.Downvotes, 0)
+
+	votes, err := m.GetUserVotes(1, []int{id})
+	assert.NilError(t, err)
+	assert.Equal(t, len(votes), 0)
+}
+
+func TestCommentModelGetRecentByAuthor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	var ids []int
+	for _, snippetID := range []int{1, 2, 3} {
+		id, err := m.Insert(snippetID, 1, "Alice", "Hello")
+		assert.NilError(t, err)
+		ids = append(ids, id)
+	}
+
+	comments, err := m.GetRecentByAuthor("Alice", 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, ids[2])
+	assert.Equal(t, comments[0].SnippetID, 3)
+
+	comments, err = m.GetRecentByAuthor("Nobody", 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelUpdateMarksEdited(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "Frist!")
+	assert.NilError(t, err)
+
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Edited(), false)
+
+	err = m.SetModeratorNote(id, "Typo")
+	assert.NilError(t, err)
+
+	c, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Edited(), false)
+
+	err = m.Update(id, "First!")
+	assert.NilError(t, err)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Edited(), true)
+	assert.Equal(t, comments[0].EditCount, 1)
+}
+
+func TestCommentModelMinInterval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name        string
+		minInterval time.Duration
+		wantErr     error
+	}{
+		{
+			name:        "Disabled",
+			minInterval: 0,
+			wantErr:     nil,
+		},
+		{
+			name:        "Within the interval",
+			minInterval: time.Hour,
+			wantErr:     ErrTooSoon,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := &CommentModel{DB: db, MinInterval: tt.minInterval}
+
+			_, err := m.Insert(1, 1, "Alice", "First!")
+			assert.NilError(t, err)
+
+			_, err = m.Insert(1, 1, "Alice", "Second!")
+			assert.Equal(t, err, tt.wantErr)
+
+			// Outros autores não são afetados
+			_, err = m.Insert(1, 2, "Bob", "Hello")
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestCommentModelDeleteBySnippetID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	_, err = m.InsertReply(1, first, 1, "Alice", "Reply")
+	assert.NilError(t, err)
+	other, err := m.Insert(2, 1, "Alice", "Elsewhere")
+	assert.NilError(t, err)
+
+	_, err = m.Upvote(first, 2)
+	assert.NilError(t, err)
+	_, err = m.Upvote(other, 2)
+	assert.NilError(t, err)
+
+	deleted, err := m.DeleteBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, deleted, 2)
+
+	var votes int
+	err = db.QueryRow(`SELECT COUNT(*) FROM comment_votes`).Scan(&votes)
+	assert.NilError(t, err)
+	assert.Equal(t, votes, 1)
+
+	deleted, err = m.DeleteBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, deleted, 0)
+}
+
+func TestCommentModelUpdateAndDeleteMissing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		exists  bool
+		wantErr error
+	}{
+		{
+			name:    "Existing comment",
+			exists:  true,
+			wantErr: nil,
+		},
+		{
+			name:    "Missing comment",
+			exists:  false,
+			wantErr: ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := &CommentModel{DB: db}
+
+			id := 99
+			if tt.exists {
+				var err error
+				id, err = m.Insert(1, 1, "Alice", "First!")
+				assert.NilError(t, err)
+			}
+
+			assert.Equal(t, m.Update(id, "Edited"), tt.wantErr)
+			assert.Equal(t, m.Delete(id, 1), tt.wantErr)
+		})
+	}
+}
+
+func TestCommentModelGetMostDiscussedSnippets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	for _, snippetID := range []int{1, 2, 2, 3, 3, 3} {
+		_, err := m.Insert(snippetID, 1, "Alice", "Hello")
+		assert.NilError(t, err)
+	}
+
+	_, err := db.Exec(`UPDATE comments SET created = UTC_TIMESTAMP() - INTERVAL 2 DAY WHERE snippet_id = 3`)
+	assert.NilError(t, err)
+
+	counts, err := m.GetMostDiscussedSnippets(24*time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(counts), 2)
+	assert.Equal(t, counts[0], SnippetCommentCount{SnippetID: 2, Count: 2})
+	assert.Equal(t, counts[1], SnippetCommentCount{SnippetID: 1, Count: 1})
+}
+
+func TestCommentModelUpdateWithVersion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+
+	// Duas edições partindo da mesma versão: só a primeira vale
+	err = m.UpdateWithVersion(id, "Edit from moderator A", c.Version)
+	assert.NilError(t, err)
+
+	err = m.UpdateWithVersion(id, "Edit from moderator B", c.Version)
+	assert.Equal(t, err, ErrStaleVersion)
+
+	c, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Content, "Edit from moderator A")
+	assert.Equal(t, c.Version, 1)
+
+	err = m.UpdateWithVersion(99, "Missing", 0)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestCommentModelReport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	once, err := m.Insert(1, 1, "Alice", "Reported once")
+	assert.NilError(t, err)
+	twice, err := m.Insert(1, 1, "Alice", "Reported twice")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Report(once, 2, "Spam"))
+	assert.NilError(t, m.Report(twice, 2, "Spam"))
+	assert.NilError(t, m.Report(twice, 3, "Rude"))
+
+	assert.Equal(t, m.Report(twice, 3, "Rude again"), ErrAlreadyReported)
+	assert.Equal(t, m.Report(once, 3, "   "), ErrInvalidReason)
+	assert.Equal(t, m.Report(once, 3, strings.Repeat("a", MaxReportReasonLength+1)), ErrInvalidReason)
+	assert.Equal(t, m.Report(99, 3, "Spam"), ErrNoRecord)
+
+	comments, err := m.GetReported(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, twice)
+
+	comments, err = m.GetReported(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+}
+
+func TestCommentModelTrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	own, err := m.Insert(1, 1, "Alice", "Deleted by Alice")
+	assert.NilError(t, err)
+	moderated, err := m.Insert(1, 1, "Alice", "Deleted by a moderator")
+	assert.NilError(t, err)
+	parent, err := m.Insert(1, 1, "Alice", "Old parent")
+	assert.NilError(t, err)
+	reply, err := m.InsertReply(1, parent, 1, "Alice", "Reply")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Delete(own, 1))
+	assert.NilError(t, m.Delete(moderated, 2))
+	assert.NilError(t, m.Delete(parent, 1))
+
+	trash, err := m.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trash), 2)
+	assert.Equal(t, trash[0].SnippetTitle != "", true)
+
+	_, err = db.Exec(`UPDATE comments SET deleted_at = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 31 DAY) WHERE id IN (?, ?)`, own, parent)
+	assert.NilError(t, err)
+
+	assert.Equal(t, m.Restore(own), ErrNoRecord)
+
+	// The parent still has a reply, so only own is purged
+	purged, err := m.PurgeDeleted()
+	assert.NilError(t, err)
+	assert.Equal(t, purged, 1)
+
+	_, err = m.Get(reply)
+	assert.NilError(t, err)
+}
+
+func TestCommentModelReportQueue(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	dismissed, err := m.Insert(1, 1, "Alice", "Harmless")
+	assert.NilError(t, err)
+	hidden, err := m.Insert(1, 1, "Alice", "Rude")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Report(dismissed, 2, "Spam"))
+	assert.NilError(t, m.Report(hidden, 2, "Rude"))
+	assert.NilError(t, m.Report(hidden, 3, "Very rude"))
+
+	reports, err := m.ReportsByCommentIDs([]int{dismissed, hidden, 99})
+	assert.NilError(t, err)
+	assert.Equal(t, len(reports), 2)
+	assert.Equal(t, len(reports[hidden]), 2)
+	assert.Equal(t, reports[hidden][0].Reason, "Rude")
+
+	n, err := m.DismissReports(dismissed)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	assert.NilError(t, m.Hide(hidden, "Personal attack"))
+	assert.Equal(t, m.Hide(99, ""), ErrNoRecord)
+
+	c, err := m.Get(hidden)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Hidden(), true)
+	assert.Equal(t, c.StatusNote, "Personal attack")
+
+	comments, err := m.GetReported(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelGetBySnippetIDSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	old, err := m.Insert(1, 1, "Alice", "Old")
+	assert.NilError(t, err)
+	boundary, err := m.Insert(1, 1, "Alice", "Boundary")
+	assert.NilError(t, err)
+	recent, err := m.Insert(1, 1, "Alice", "Recent")
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE comments SET created = CASE id
+		WHEN ? THEN '2024-01-01 09:00:00'
+		WHEN ? THEN '2024-01-01 10:00:00'
+		ELSE '2024-01-01 11:00:00' END`, old, boundary)
+	assert.NilError(t, err)
+
+	since := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	comments, err := m.GetBySnippetIDSince(1, since)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, recent)
+
+	// O mesmo instante em outro fuso não muda o resultado
+	comments, err = m.GetBySnippetIDSince(1, since.In(time.FixedZone("BRT", -3*60*60)))
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+
+	comments, err = m.GetBySnippetIDSince(1, time.Time{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 3)
+}
+
+func TestCommentModelSortedAndSinceSkipHeld(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	visible, err := m.Insert(1, 1, "Alice", "Visible")
+	assert.NilError(t, err)
+	held, err := m.Insert(1, 1, "Alice", "Held")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Hold(held, "Spam"))
+
+	comments, err := m.GetBySnippetIDSorted(1, SortNewest)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, visible)
+
+	comments, err = m.GetBySnippetIDSince(1, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, visible)
+}
+
+func TestCommentModelInsertBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	created := time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+
+	input := []CommentInput{
+		{SnippetID: 1, AuthorID: 1, Author: "Alice", Content: "Imported", Created: created},
+		{SnippetID: 2, AuthorID: 2, Author: "Bob", Content: "Imported too"},
+		{SnippetID: 1, AuthorID: 1, Author: "Alice", Content: "Imported last"},
+	}
+
+	ids, err := m.InsertBatch(input)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), len(input))
+
+	for i, id := range ids {
+		c, err := m.Get(id)
+		assert.NilError(t, err)
+		assert.Equal(t, c.SnippetID, input[i].SnippetID)
+		assert.Equal(t, c.AuthorID, input[i].AuthorID)
+		assert.Equal(t, c.Author, input[i].Author)
+		assert.Equal(t, c.Content, input[i].Content)
+	}
+
+	c, err := m.Get(ids[0])
+	assert.NilError(t, err)
+	assert.Equal(t, c.Created.Equal(created), true)
+
+	// Um conteúdo inválido descarta o lote inteiro
+	_, err = m.InsertBatch([]CommentInput{
+		{SnippetID: 1, Author: "Alice", Content: "Valid"},
+		{SnippetID: 1, Author: "Alice", Content: " "},
+	})
+	assert.Equal(t, err, ErrCommentEmpty)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+
+	_, err = m.InsertBatch(make([]CommentInput, MaxBatchSize+1))
+	assert.Equal(t, err, ErrBatchTooLarge)
+}
+
+func TestCommentModelPin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	second, err := m.Insert(1, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Pin(first))
+	assert.NilError(t, m.Pin(second))
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].ID, second)
+	assert.Equal(t, comments[0].Pinned, true)
+	assert.Equal(t, comments[1].ID, first)
+	assert.Equal(t, comments[1].Pinned, false)
+
+	assert.NilError(t, m.Unpin(second))
+
+	c, err := m.Get(second)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Pinned, false)
+
+	assert.Equal(t, m.Pin(99), ErrNoRecord)
+}
+
+func TestCommentModelSetPinOrder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+	second, err := m.Insert(1, 1, "Alice", "Second!")
+	assert.NilError(t, err)
+	third, err := m.Insert(1, 1, "Alice", "Third!")
+	assert.NilError(t, err)
+	elsewhere, err := m.Insert(2, 1, "Alice", "On another snippet")
+	assert.NilError(t, err)
+
+	pinned := func() []int {
+		comments, err := m.GetBySnippetID(1)
+		assert.NilError(t, err)
+
+		ids := []int{}
+		for _, c := range comments {
+			if c.Pinned {
+				ids = append(ids, c.ID)
+			}
+		}
+		return ids
+	}
+
+	assert.NilError(t, m.SetPinOrder(1, []int{first, second}))
+
+	assert.NilError(t, m.SetPinOrder(1, []int{third, first}))
+	assert.Equal(t, fmt.Sprint(pinned()), fmt.Sprint([]int{third, first}))
+
+	c, err := m.Get(third)
+	assert.NilError(t, err)
+	assert.Equal(t, c.PinOrder, 1)
+
+	c, err = m.Get(second)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Pinned, false)
+
+	// IDs de outro snippet ou inexistentes não mudam nada
+	assert.Equal(t, m.SetPinOrder(1, []int{second, elsewhere}), ErrNoRecord)
+	assert.Equal(t, m.SetPinOrder(1, []int{second, 99}), ErrNoRecord)
+	assert.Equal(t, fmt.Sprint(pinned()), fmt.Sprint([]int{third, first}))
+
+	assert.Equal(t, m.SetPinOrder(1, []int{first, first}), ErrDuplicatePin)
+
+	assert.NilError(t, m.SetPinOrder(1, nil))
+	assert.Equal(t, len(pinned()), 0)
+}
+
+func TestCommentModelTopAuthorsBySnippet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	comments := []struct {
+		author  string
+		upvotes int
+		deleted bool
+	}{
+		{author: "Alice", upvotes: 2},
+		{author: "Alice", upvotes: 1},
+		{author: "Bob", upvotes: 4},
+		{author: "Bob", upvotes: 5, deleted: true},
+		{author: "Carol", upvotes: 1},
+	}
+
+	for _, c := range comments {
+		id, err := m.Insert(1, 1, c.author, "Hello")
+		assert.NilError(t, err)
+		_, err = db.Exec(`UPDATE comments SET upvotes = ? WHERE id = ?`, c.upvotes, id)
+		assert.NilError(t, err)
+		if c.deleted {
+			assert.NilError(t, m.Delete(id, 1))
+		}
+	}
+
+	scores, err := m.TopAuthorsBySnippet(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(scores), 2)
+	assert.Equal(t, scores[0], AuthorScore{Author: "Bob", TotalUpvotes: 4})
+	assert.Equal(t, scores[1], AuthorScore{Author: "Alice", TotalUpvotes: 3})
+
+	scores, err = m.TopAuthorsBySnippet(2, 5)
+	assert.NilError(t, err)
+	assert.Equal(t, len(scores), 0)
+}
+
+func TestCommentModelForOwnerSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	snippetID, err := (&SnippetModel{DB: db}).Insert("Alice's snippet", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	m := &CommentModel{DB: db}
+
+	_, err = m.Insert(snippetID, 1, "Alice", "Her own comment")
+	assert.NilError(t, err)
+	old, err := m.Insert(snippetID, 2, "Bob", "An old comment")
+	assert.NilError(t, err)
+	recent, err := m.Insert(snippetID, 2, "Bob", "A new comment")
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE comments SET created = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 8 DAY) WHERE id = ?`, old)
+	assert.NilError(t, err)
+
+	comments, err := m.ForOwnerSince(1, time.Now().Add(-7*24*time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, recent)
+	assert.Equal(t, comments[0].SnippetTitle, "Alice's snippet")
+
+	comments, err = m.ForOwnerSince(2, time.Now().Add(-7*24*time.Hour), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelToggleReaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	// Authors may react to their own comments
+	added, err := m.ToggleReaction(id, 1, "heart")
+	assert.NilError(t, err)
+	assert.Equal(t, added, true)
+
+	added, err = m.ToggleReaction(id, 2, "heart")
+	assert.NilError(t, err)
+	assert.Equal(t, added, true)
+
+	added, err = m.ToggleReaction(id, 2, "tada")
+	assert.NilError(t, err)
+	assert.Equal(t, added, true)
+
+	// Reacting again takes the reaction back
+	added, err = m.ToggleReaction(id, 2, "tada")
+	assert.NilError(t, err)
+	assert.Equal(t, added, false)
+
+	_, err = m.ToggleReaction(id, 2, "rocket")
+	assert.Equal(t, err, ErrInvalidReaction)
+
+	_, err = m.ToggleReaction(99, 2, "heart")
+	assert.Equal(t, err, ErrNoRecord)
+
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, len(comments[0].Reactions), len(ReactionTypes))
+	assert.Equal(t, comments[0].Reactions[1].Name, "heart")
+	assert.Equal(t, comments[0].Reactions[1].Count, 2)
+	assert.Equal(t, comments[0].Reactions[1].Mine, false)
+	assert.Equal(t, comments[0].Reactions[3].Count, 0)
+
+	comments, _, err = m.GetBySnippetIDForViewerPaged(1, 1, SortOldest, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, comments[0].Reactions[1].Mine, true)
+	assert.Equal(t, comments[0].Reactions[0].Mine, false)
+
+	assert.NilError(t, m.Delete(id, 1))
+
+	_, err = m.ToggleReaction(id, 2, "heart")
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestCommentModelHold(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 2, "Bob", "Buy now")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Hold(id, "Uses a banned word"))
+
+	held, err := m.GetHeld()
+	assert.NilError(t, err)
+	assert.Equal(t, len(held), 1)
+	assert.Equal(t, held[0].Held(), true)
+	assert.Equal(t, held[0].StatusNote, "Uses a banned word")
+
+	// Only the author sees a held comment
+	comments, err := m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+
+	comments, _, err = m.GetBySnippetIDForViewerPaged(1, 1, SortOldest, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+
+	comments, _, err = m.GetBySnippetIDForViewerPaged(1, 2, SortOldest, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+
+	assert.NilError(t, m.Approve(id))
+
+	comments, err = m.GetBySnippetID(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].StatusNote, "")
+
+	held, err = m.GetHeld()
+	assert.NilError(t, err)
+	assert.Equal(t, len(held), 0)
+
+	assert.Equal(t, m.Hold(99, "Spam"), ErrNoRecord)
+	assert.Equal(t, m.Approve(99), ErrNoRecord)
+}
+
+func TestCommentModelIncrementViews(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.IncrementViews(id))
+	assert.NilError(t, m.IncrementViews(id))
+
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Views, 2)
+
+	assert.Equal(t, m.IncrementViews(99), ErrNoRecord)
+}
+
+func TestCommentModelSimilarTo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &CommentModel{DB: db}
+
+	source, err := m.Insert(1, 1, "Alice", "Autumn moonlight over the quiet pond")
+	assert.NilError(t, err)
+	similar, err := m.Insert(2, 1, "Alice", "The pond under autumn moonlight")
+	assert.NilError(t, err)
+	_, err = m.Insert(1, 1, "Alice", "Snails climbing Mount Fuji")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(1, 1, "Alice", "Moonlight on the pond in autumn")
+	assert.NilError(t, err)
+
+	held, err := m.Insert(1, 1, "Alice", "Quiet autumn pond in the moonlight")
+	assert.NilError(t, err)
+	hidden, err := m.Insert(1, 1, "Alice", "Moonlight, autumn, a quiet pond")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Delete(deleted, 1))
+	assert.NilError(t, m.Hold(held, "Spam"))
+	assert.NilError(t, m.Hide(hidden, "Spam"))
+
+	comments, err := m.SimilarTo(source, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, similar)
+
+	comments, err = m.SimilarTo(source, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+
+	_, err = m.SimilarTo(99, 10)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestCommentModelAuthorCommentsOnOwner(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	snippets := &SnippetModel{DB: db}
+	m := &CommentModel{DB: db}
+
+	alices, err := snippets.Insert("Alice's snippet", "Content", 7, 1)
+	assert.NilError(t, err)
+	bobs, err := snippets.Insert("Bob's snippet", "Content", 7, 2)
+	assert.NilError(t, err)
+
+	older, err := m.Insert(alices, 2, "Bob", "Older")
+	assert.NilError(t, err)
+	newer, err := m.Insert(alices, 2, "Bob", "Newer")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(alices, 2, "Bob", "Deleted")
+	assert.NilError(t, err)
+	_, err = m.Insert(bobs, 2, "Bob", "On his own snippet")
+	assert.NilError(t, err)
+	_, err = m.Insert(alices, 1, "Alice", "On her own snippet")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Delete(deleted, 2))
+
+	_, err = db.Exec(`UPDATE comments SET created = '2024-01-01 10:00:00' WHERE id = ?`, older)
+	assert.NilError(t, err)
+
+	comments, err := m.AuthorCommentsOnOwner(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, newer)
+	assert.Equal(t, comments[0].SnippetTitle, "Alice's snippet")
+	assert.Equal(t, comments[1].ID, older)
+
+	comments, err = m.AuthorCommentsOnOwner(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelMostEdited(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := &CommentModel{DB: newTestDB(t)}
+
+	twice, err := m.Insert(1, 1, "Alice", "Twice")
+	assert.NilError(t, err)
+	once, err := m.Insert(1, 1, "Alice", "Once")
+	assert.NilError(t, err)
+	_, err = m.Insert(1, 1, "Alice", "Never")
+	assert.NilError(t, err)
+	deleted, err := m.Insert(1, 1, "Alice", "Deleted")
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Update(twice, "Twice, first edit"))
+	assert.NilError(t, m.Update(twice, "Twice, second edit"))
+	assert.NilError(t, m.Update(once, "Once, edited"))
+	assert.NilError(t, m.Update(deleted, "Deleted, edited"))
+	assert.NilError(t, m.Update(deleted, "Deleted, edited again"))
+	assert.NilError(t, m.Update(deleted, "Deleted, edited once more"))
+	assert.NilError(t, m.Delete(deleted, 1))
+
+	// Comentários apagados e nunca editados ficam de fora
+	comments, err := m.MostEdited(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, twice)
+	assert.Equal(t, comments[0].EditCount, 2)
+	assert.Equal(t, comments[1].ID, once)
+	assert.Equal(t, comments[1].EditCount, 1)
+
+	comments, err = m.MostEdited(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, twice)
+
+	comments, err = m.MostEdited(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelVoteSpikes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &CommentModel{DB: db}
+
+	atLimit, err := m.Insert(1, 1, "Alice", "Exactly minVotes")
+	assert.NilError(t, err)
+	overLimit, err := m.Insert(1, 1, "Alice", "One vote over")
+	assert.NilError(t, err)
+	old, err := m.Insert(2, 1, "Alice", "Votes outside the window")
+	assert.NilError(t, err)
+
+	vote := func(commentID, userID int, created string) {
+		_, err := db.Exec(`INSERT INTO comment_votes (comment_id, user_id, vote_type, created) VALUES (?, ?, 'upvote', `+created+`)`, commentID, userID)
+		assert.NilError(t, err)
+	}
+
+	for userID := 2; userID <= 4; userID++ {
+		vote(atLimit, userID, "UTC_TIMESTAMP()")
+		vote(old, userID, "UTC_TIMESTAMP() - INTERVAL 2 HOUR")
+	}
+	for userID := 2; userID <= 5; userID++ {
+		vote(overLimit, userID, "UTC_TIMESTAMP()")
+	}
+
+	// Um comentário com exatamente minVotes votos não gera alerta
+	alerts, err := m.VoteSpikes(time.Hour, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0], SpikeAlert{CommentID: overLimit, SnippetID: 1, Votes: 4})
+
+	alerts, err = m.VoteSpikes(time.Hour, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(alerts), 2)
+	assert.Equal(t, alerts[0].CommentID, overLimit)
+	assert.Equal(t, alerts[1].CommentID, atLimit)
+
+	alerts, err = m.VoteSpikes(3*time.Hour, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(alerts), 3)
+
+	assert.NilError(t, m.Delete(overLimit, 1))
+
+	alerts, err = m.VoteSpikes(time.Hour, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(alerts), 0)
+}
+
+func TestCommentModelUnresolvedQuestions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	snippets := &SnippetModel{DB: db}
+	m := &CommentModel{DB: db}
+
+	open, err := snippets.Insert("Open", "Content", 7, 1)
+	assert.NilError(t, err)
+	answered, err := snippets.Insert("Answered", "Content", 7, 1)
+	assert.NilError(t, err)
+	reopened, err := snippets.Insert("Reopened", "Content", 7, 1)
+	assert.NilError(t, err)
+	trashed, err := snippets.Insert("Trashed", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	question := func(snippetID int) int {
+		id, err := m.Insert(snippetID, 1, "Alice", "How?")
+		assert.NilError(t, err)
+		_, err = db.Exec(`UPDATE comments SET is_question = TRUE WHERE id = ?`, id)
+		assert.NilError(t, err)
+		return id
+	}
+	answer := func(snippetID int) int {
+		id, err := m.Insert(snippetID, 1, "Alice", "Like this.")
+		assert.NilError(t, err)
+		_, err = db.Exec(`UPDATE comments SET accepted = TRUE WHERE id = ?`, id)
+		assert.NilError(t, err)
+		return id
+	}
+
+	first := question(open)
+	question(answered)
+	answer(answered)
+	second := question(reopened)
+	assert.NilError(t, m.Delete(answer(reopened), 1))
+	question(trashed)
+	assert.NilError(t, snippets.Delete(trashed, 1))
+	assert.NilError(t, m.Delete(question(open), 1))
+	_, err = m.Insert(open, 1, "Alice", "Not a question")
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE comments SET created = '2024-01-01 10:00:00' WHERE id = ?`, second)
+	assert.NilError(t, err)
+
+	// Uma resposta aceita apagada deixa a pergunta sem resposta de novo
+	comments, err := m.UnresolvedQuestions(10, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, second)
+	assert.Equal(t, comments[0].SnippetTitle, "Reopened")
+	assert.Equal(t, comments[1].ID, first)
+	assert.Equal(t, comments[1].SnippetTitle, "Open")
+
+	comments, err = m.UnresolvedQuestions(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, first)
+
+	_, err = m.UnresolvedQuestions(-1, 0)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
+
+func TestCommentModelGetBySnippetIDWithStaff(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP()),
+		('Carol', 'carol@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	users := &UserModel{DB: db}
+	assert.NilError(t, users.SetRole(2, RoleModerator))
+	assert.NilError(t, users.SetRole(3, RoleAdmin))
+
+	m := &CommentModel{DB: db}
+
+	byAlice, err := m.Insert(1, 1, "Alice", "From a user")
+	assert.NilError(t, err)
+	byBob, err := m.Insert(1, 2, "Bob", "From a moderator")
+	assert.NilError(t, err)
+	byCarol, err := m.Insert(1, 3, "Carol", "From an admin")
+	assert.NilError(t, err)
+	anonymous, err := m.Insert(1, 0, "Guest", "From nobody in particular")
+	assert.NilError(t, err)
+
+	comments, err := m.GetBySnippetIDWithStaff(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 4)
+
+	staff := map[int]bool{}
+	for _, c := range comments {
+		staff[c.ID] = c.IsStaff
+	}
+	assert.Equal(t, staff[byAlice], false)
+	assert.Equal(t, staff[byBob], true)
+	assert.Equal(t, staff[byCarol], true)
+	assert.Equal(t, staff[anonymous], false)
+
+	// Um snippet sem comentários retorna uma lista vazia
+	comments, err = m.GetBySnippetIDWithStaff(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
+
+func TestCommentModelRecentByOwner(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+		('Bob', 'bob@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	snippets := &SnippetModel{DB: db}
+	m := &CommentModel{DB: db}
+
+	busy, err := snippets.Insert("Busy", "Content", 7, 1)
+	assert.NilError(t, err)
+	quiet, err := snippets.Insert("Quiet", "Content", 7, 1)
+	assert.NilError(t, err)
+	_, err = snippets.Insert("Silent", "Content", 7, 1)
+	assert.NilError(t, err)
+	trashed, err := snippets.Insert("Trashed", "Content", 7, 1)
+	assert.NilError(t, err)
+	bobs, err := snippets.Insert("Bob's", "Content", 7, 2)
+	assert.NilError(t, err)
+
+	ids := []int{}
+	for _, content := range []string{"First", "Second", "Third"} {
+		id, err := m.Insert(busy, 2, "Bob", content)
+		assert.NilError(t, err)
+		ids = append(ids, id)
+	}
+	deleted, err := m.Insert(busy, 2, "Bob", "Deleted")
+	assert.NilError(t, err)
+	assert.NilError(t, m.Delete(deleted, 2))
+
+	only, err := m.Insert(quiet, 2, "Bob", "Only")
+	assert.NilError(t, err)
+	_, err = m.Insert(trashed, 2, "Bob", "On a trashed snippet")
+	assert.NilError(t, err)
+	assert.NilError(t, snippets.Delete(trashed, 1))
+	_, err = m.Insert(bobs, 1, "Alice", "On someone else's snippet")
+	assert.NilError(t, err)
+
+	// Snippets sem comentários ou na lixeira não aparecem no mapa
+	grouped, err := m.RecentByOwner(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(grouped), 2)
+	assert.Equal(t, len(grouped[busy]), 2)
+	assert.Equal(t, grouped[busy][0].ID, ids[2])
+	assert.Equal(t, grouped[busy][1].ID, ids[1])
+	assert.Equal(t, len(grouped[quiet]), 1)
+	assert.Equal(t, grouped[quiet][0].ID, only)
+
+	grouped, err = m.RecentByOwner(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(grouped), 0)
+
+	grouped, err = m.RecentByOwner(3, 5)
+	assert.NilError(t, err)
+	assert.Equal(t, len(grouped), 0)
+}
+
+func TestCommentModelSetModeratorNote(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := &CommentModel{DB: newTestDB(t)}
+
+	id, err := m.Insert(1, 1, "Alice", "First!")
+	assert.NilError(t, err)
+
+	err = m.SetModeratorNote(id, "  Edited to remove a link  ")
+	assert.NilError(t, err)
+
+	// O conteúdo escrito pelo autor não muda
+	c, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.ModeratorNote, "Edited to remove a link")
+	assert.Equal(t, c.Content, "First!")
+	assert.Equal(t, c.EditCount, 0)
+
+	err = m.SetModeratorNote(id, "   ")
+	assert.NilError(t, err)
+
+	c, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.ModeratorNote, "")
+
+	var note *string
+	err = m.DB.QueryRow(`SELECT moderator_note FROM comments WHERE id = ?`, id).Scan(&note)
+	assert.NilError(t, err)
+	assert.Equal(t, note == nil, true)
+
+	assert.Equal(t, m.SetModeratorNote(99, "Note"), ErrNoRecord)
+
+	assert.NilError(t, m.Delete(id, 1))
+	assert.Equal(t, m.SetModeratorNote(id, "Note"), ErrNoRecord)
+}
+
+func TestCommentModelAuthorActivitySpan(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &CommentModel{DB: db}
+
+	first, err := m.Insert(1, 1, "Alice", "First")
+	assert.NilError(t, err)
+	_, err = m.Insert(2, 1, "Alice", "Middle")
+	assert.NilError(t, err)
+	last, err := m.Insert(1, 1, "Alice", "Last")
+	assert.NilError(t, err)
+	_, err = m.Insert(1, 2, "Bob", "Someone else")
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE comments SET created = '2024-01-01 10:00:00' WHERE id = ?`, first)
+	assert.NilError(t, err)
+	_, err = db.Exec(`UPDATE comments SET created = '2024-03-01 10:00:00' WHERE id = ?`, last)
+	assert.NilError(t, err)
+	_, err = db.Exec(`UPDATE comments SET created = '2024-02-01 10:00:00' WHERE id NOT IN (?, ?)`, first, last)
+	assert.NilError(t, err)
+
+	from, to, count, err := m.AuthorActivitySpan(1)
+	assert.NilError(t, err)
+	assert.Equal(t, from, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, to, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, count, 3)
+
+	// Quem nunca comentou recebe datas zeradas e nenhum erro
+	from, to, count, err = m.AuthorActivitySpan(3)
+	assert.NilError(t, err)
+	assert.Equal(t, from.IsZero(), true)
+	assert.Equal(t, to.IsZero(), true)
+	assert.Equal(t, count, 0)
+}
+
+func TestCommentModelShadowbanned(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := &CommentModel{DB: newTestDB(t)}
+
+	banned, err := m.Shadowbanned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, banned, false)
+
+	assert.NilError(t, m.SetShadowban(1, true))
+
+	banned, err = m.Shadowbanned(1)
+	assert.NilError(t, err)
+	assert.Equal(t, banned, true)
+
+	// Visitantes e usuários desconhecidos não têm shadowban
+	banned, err = m.Shadowbanned(0)
+	assert.NilError(t, err)
+	assert.Equal(t, banned, false)
+
+	banned, err = m.Shadowbanned(99)
+	assert.NilError(t, err)
+	assert.Equal(t, banned, false)
+
+	assert.Equal(t, m.SetShadowban(99, true), ErrNoRecord)
+}
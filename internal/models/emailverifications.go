@@ -0,0 +1,99 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+type EmailVerificationModelInterface interface {
+	Create(email string, ttl time.Duration) (string, error)
+	Verify(token string) (int, error)
+}
+
+// EmailVerificationModel wraps a sql.DB conn pool. Like password reset
+// tokens, verification tokens are stored as SHA-256 hashes.
+type EmailVerificationModel struct {
+	DB *sql.DB
+}
+
+// Create makes a verification token for the user with the given email
+// address that expires after ttl, and returns the token. It returns
+// ErrNoRecord if no user has that address and ErrAlreadyVerified if the
+// address has already been verified.
+func (m *EmailVerificationModel) Create(email string, ttl time.Duration) (string, error) {
+	var userID int
+	var verified bool
+
+	err := m.DB.QueryRow(`SELECT id, verified FROM users WHERE email = ?`, email).Scan(&userID, &verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	if verified {
+		return "", ErrAlreadyVerified
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO email_verifications (user_id, token_hash, created, expires)
+	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err = m.DB.Exec(stmt, userID, hashToken(token), int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Verify marks the user a token was made for as verified and returns their
+// ID. The user's verification tokens are deleted, so each can be used once.
+// It returns ErrInvalidVerifyToken if the token is unknown, used or expired.
+func (m *EmailVerificationModel) Verify(token string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM email_verifications
+	WHERE token_hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidVerifyToken
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET verified = TRUE WHERE id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM email_verifications WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestEmailVerificationModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	users := &UserModel{db}
+	m := &EmailVerificationModel{DB: db}
+
+	_, err := m.Create("nobody@example.com", time.Hour)
+	assert.Equal(t, err, ErrNoRecord)
+
+	token, err := m.Create("alice@example.com", time.Hour)
+	assert.NilError(t, err)
+	expired, err := m.Create("alice@example.com", -time.Hour)
+	assert.NilError(t, err)
+
+	_, err = m.Verify(expired)
+	assert.Equal(t, err, ErrInvalidVerifyToken)
+	_, err = m.Verify("forged")
+	assert.Equal(t, err, ErrInvalidVerifyToken)
+
+	id, err := m.Verify(token)
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	user, err := users.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, true)
+
+	_, err = m.Verify(token)
+	assert.Equal(t, err, ErrInvalidVerifyToken)
+
+	_, err = m.Create("alice@example.com", time.Hour)
+	assert.Equal(t, err, ErrAlreadyVerified)
+}
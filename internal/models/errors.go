@@ -1,9 +1,48 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrDuplicatePin       = errors.New("models: comment pinned more than once")
+	ErrInvalidPagination  = errors.New("models: limit and offset must not be negative")
+	ErrDuplicatesSnippet  = errors.New("models: comment repeats the snippet content")
+	ErrEmptyPattern       = errors.New("models: empty match pattern")
+	ErrCommentTooLong     = errors.New("models: comment content too long")
+	ErrCommentEmpty       = errors.New("models: comment content is empty")
+	ErrInvalidSortOrder   = errors.New("models: unknown sort order")
+	ErrEmptySearch        = errors.New("models: empty search query")
+	ErrSelfVote           = errors.New("models: users cannot vote on their own comments or snippets")
+	ErrSelfFollow         = errors.New("models: users cannot follow themselves")
+	ErrTooSoon            = errors.New("models: comment posted too soon after the previous one")
+	ErrStaleVersion       = errors.New("models: comment was changed by someone else")
+	ErrAlreadyReported    = errors.New("models: comment already reported by this user")
+	ErrInvalidReason      = errors.New("models: report reason is empty or too long")
+	ErrBatchTooLarge      = errors.New("models: too many comments in a single batch")
+	ErrInvalidResetToken  = errors.New("models: password reset token is invalid or expired")
+	ErrInvalidVerifyToken = errors.New("models: email verification token is invalid or expired")
+	ErrAlreadyVerified    = errors.New("models: email address already verified")
+	ErrIdentityTaken      = errors.New("models: provider account already linked")
+	ErrInvalidTOTP        = errors.New("models: invalid two-factor code")
+	ErrTwoFactorEnabled   = errors.New("models: two-factor authentication already enabled")
+	ErrInvalidRole        = errors.New("models: unknown user role")
+	ErrInvalidTheme       = errors.New("models: unknown theme")
+	ErrBanned             = errors.New("models: user is banned")
+	ErrAccountLocked      = errors.New("models: account locked after too many failed logins")
+	ErrInvalidUnlockToken = errors.New("models: account unlock token is invalid or expired")
+	ErrInvalidAPIToken    = errors.New("models: API token is invalid or revoked")
+	ErrInvalidScope       = errors.New("models: unknown API token scope")
+	ErrInvalidEvent       = errors.New("models: unknown webhook event")
+	ErrInvalidReaction    = errors.New("models: unknown comment reaction")
+	ErrCommentHeld        = errors.New("models: comment held for moderation")
 )
+
+// ErrExpired is returned for a snippet that existed but has expired. It wraps
+// ErrNoRecord, so callers that do not tell the two apart need not check for
+// it.
+var ErrExpired = fmt.Errorf("models: snippet expired: %w", ErrNoRecord)
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type ExportModelInterface interface {
+	Snippets(userID, afterID, limit int) ([]*Snippet, error)
+	Comments(userID, afterID, limit int) ([]*Comment, error)
+}
+
+// ExportModel reads all of a user's data for them to download. Each method
+// returns the next batch of records after the one with ID afterID, in ID
+// order, so that an export can be written out a batch at a time however much
+// there is.
+type ExportModel struct {
+	DB *sql.DB
+}
+
+// Snippets returns up to limit of the user's snippets with an ID above
+// afterID, whatever their visibility. Snippets in the trash are left out;
+// expired ones that have not been purged yet are not.
+func (m *ExportModel) Snippets(userID, afterID, limit int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.user_id = ? AND s.id > ? AND s.deleted_at IS NULL
+	ORDER BY s.id LIMIT ?`
+
+	return querySnippets(m.DB, stmt, userID, afterID, limit)
+}
+
+// Comments returns up to limit of the comments the user wrote with an ID
+// above afterID. Deleted comments are left out.
+func (m *ExportModel) Comments(userID, afterID, limit int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c
+	WHERE c.author_id = ? AND c.id > ? AND c.deleted = FALSE
+	ORDER BY c.id LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestExportModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &ExportModel{DB: db}
+
+	snippets := &SnippetModel{DB: db}
+	comments := &CommentModel{DB: db}
+
+	first, err := snippets.Insert("First", "one", 7, 1)
+	assert.NilError(t, err)
+	second, err := snippets.InsertWithOptions("Second", "two", SnippetOptions{Visibility: VisibilityPrivate}, 7, 1)
+	assert.NilError(t, err)
+	trashed, err := snippets.Insert("Trashed", "three", 7, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, snippets.Delete(trashed, 1))
+	_, err = snippets.Insert("Someone else's", "four", 7, 2)
+	assert.NilError(t, err)
+
+	// Private snippets are exported, trashed ones are not
+	got, err := m.Snippets(1, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0].ID, first)
+	assert.Equal(t, got[1].ID, second)
+
+	// Batches pick up after the last ID
+	got, err = m.Snippets(1, 0, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got), 1)
+	got, err = m.Snippets(1, got[0].ID, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0].ID, second)
+
+	kept, err := comments.Insert(first, 1, "Alice Jones", "Mine")
+	assert.NilError(t, err)
+	deleted, err := comments.Insert(first, 1, "Alice Jones", "Gone")
+	assert.NilError(t, err)
+	assert.NilError(t, comments.Delete(deleted, 1))
+	_, err = comments.Insert(first, 2, "Bob", "Not mine")
+	assert.NilError(t, err)
+
+	cs, err := m.Comments(1, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cs), 1)
+	assert.Equal(t, cs[0].ID, kept)
+
+	cs, err = m.Comments(1, kept, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cs), 0)
+}
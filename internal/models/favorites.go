@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type FavoriteModelInterface interface {
+	Toggle(snippetID, userID int) (bool, error)
+	Starred(snippetID, userID int) (bool, error)
+	List(userID, page, pageSize int) ([]*Snippet, int, error)
+}
+
+// FavoriteModel wraps a sql.DB conn pool. A favorite is a star a user has put
+// on a snippet; Snippet.Stars counts them.
+type FavoriteModel struct {
+	DB *sql.DB
+}
+
+// Toggle stars the snippet for the user, or takes the star back if there
+// already is one, and reports whether the snippet is now starred. It returns
+// ErrNoRecord if the snippet does not exist.
+func (m *FavoriteModel) Toggle(snippetID, userID int) (bool, error) {
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM snippets
+	WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL)`, snippetID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrNoRecord
+	}
+
+	result, err := m.DB.Exec(`DELETE FROM favorites WHERE snippet_id = ? AND user_id = ?`, snippetID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if removed > 0 {
+		return false, nil
+	}
+
+	// IGNORE, so that starring twice at once still ends up starred
+	_, err = m.DB.Exec(`INSERT IGNORE INTO favorites (snippet_id, user_id, created)
+	VALUES (?, ?, UTC_TIMESTAMP())`, snippetID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Starred reports whether the user has starred the snippet.
+func (m *FavoriteModel) Starred(snippetID, userID int) (bool, error) {
+	var starred bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM favorites WHERE snippet_id = ? AND user_id = ?)`,
+		snippetID, userID).Scan(&starred)
+
+	return starred, err
+}
+
+// List returns a page of the snippets the user has starred, most recently
+// starred first, along with the total number of them. Snippets that have
+// since expired, been deleted or been made private by someone else are left
+// out.
+func (m *FavoriteModel) List(userID, page, pageSize int) ([]*Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	const where = `WHERE f.user_id = ? AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL
+	AND (s.visibility <> 'private' OR s.user_id = f.user_id)`
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM favorites f
+	INNER JOIN snippets s ON s.id = f.snippet_id `+where, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM favorites f
+	INNER JOIN snippets s ON s.id = f.snippet_id ` + where + `
+	ORDER BY f.created DESC, f.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := querySnippets(m.DB, stmt, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestFavoriteModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &FavoriteModel{DB: db}
+
+	snippets := &SnippetModel{DB: db}
+	first, err := snippets.Insert("First", "Content", 7, 1)
+	assert.NilError(t, err)
+	second, err := snippets.Insert("Second", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	starred, err := m.Toggle(first, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+
+	starred, err = m.Toggle(second, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+
+	starred, err = m.Starred(first, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+
+	s, err := snippets.Get(first)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Stars, 1)
+
+	favorites, total, err := m.List(1, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, len(favorites), 2)
+	assert.Equal(t, favorites[0].Stars, 1)
+
+	_, _, err = m.List(1, 0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+
+	// Toggling again takes the star back
+	starred, err = m.Toggle(first, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, false)
+
+	s, err = snippets.Get(first)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Stars, 0)
+
+	// Deleted snippets drop out of the list and cannot be starred
+	assert.NilError(t, snippets.Delete(second, 1))
+
+	_, total, err = m.List(1, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 0)
+
+	_, err = m.Toggle(second, 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	_, err = m.Toggle(999, 1)
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+
+	"snippetbox.jmorelli.dev/internal/spam"
+)
+
+// FilteringCommentModel wraps a CommentModelInterface and runs every comment
+// added by Insert or InsertReply through Filter before adding it. A flagged
+// comment is added and then held with Hold, with the reason as its note; in
+// that case the insert returns the comment's ID along with ErrCommentHeld. If
+// Filter fails, the comment is held too, so that moderation can decide. All
+// other calls are passed on.
+//
+// It should wrap the CommentModel directly, beneath the cache and event
+// publishing, so that they only ever see the comment once it is held.
+type FilteringCommentModel struct {
+	CommentModelInterface
+	Filter spam.Filter
+}
+
+// NewFilteringCommentModel returns a FilteringCommentModel over inner.
+func NewFilteringCommentModel(inner CommentModelInterface, filter spam.Filter) *FilteringCommentModel {
+	return &FilteringCommentModel{CommentModelInterface: inner, Filter: filter}
+}
+
+// Insert filters the comment and passes the insert on.
+func (m *FilteringCommentModel) Insert(snippetID int, authorID int, author string, content string) (int, error) {
+	return m.InsertContext(context.Background(), snippetID, authorID, author, content)
+}
+
+// InsertContext is like Insert, but uses ctx for the database queries and
+// for Filter.
+func (m *FilteringCommentModel) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	reason := m.check(ctx, spam.Comment{SnippetID: snippetID, AuthorID: authorID, Author: author, Content: content})
+
+	id, err := m.CommentModelInterface.InsertContext(ctx, snippetID, authorID, author, content)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.hold(ctx, id, reason)
+}
+
+// InsertReply filters the reply and passes the insert on.
+func (m *FilteringCommentModel) InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return m.InsertReplyContext(context.Background(), snippetID, parentID, authorID, author, content)
+}
+
+// InsertReplyContext is like InsertReply, but uses ctx for the database
+// queries and for Filter.
+func (m *FilteringCommentModel) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	reason := m.check(ctx, spam.Comment{SnippetID: snippetID, AuthorID: authorID, Author: author, Content: content})
+
+	id, err := m.CommentModelInterface.InsertReplyContext(ctx, snippetID, parentID, authorID, author, content)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.hold(ctx, id, reason)
+}
+
+// maxStatusNoteLength is the size of the status_note column, in characters.
+const maxStatusNoteLength = 255
+
+// check returns the reason to hold the comment, or "" if it can be
+// published.
+func (m *FilteringCommentModel) check(ctx context.Context, c spam.Comment) string {
+	reason, err := m.Filter.Check(ctx, c)
+	if err != nil {
+		reason = "Spam check failed: " + err.Error()
+	}
+	return truncate(reason, maxStatusNoteLength)
+}
+
+// hold holds the newly added comment if there is a reason to.
+func (m *FilteringCommentModel) hold(ctx context.Context, id int, reason string) (int, error) {
+	if reason == "" {
+		return id, nil
+	}
+
+	err := m.CommentModelInterface.HoldContext(ctx, id, reason)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, ErrCommentHeld
+}
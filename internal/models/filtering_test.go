@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/spam"
+)
+
+// filterFunc adapts a function to spam.Filter.
+type filterFunc func(ctx context.Context, c spam.Comment) (string, error)
+
+func (f filterFunc) Check(ctx context.Context, c spam.Comment) (string, error) {
+	return f(ctx, c)
+}
+
+func TestFilteringCommentModel(t *testing.T) {
+	inner := &countingComments{comments: map[int]*Comment{}}
+	m := NewFilteringCommentModel(inner, filterFunc(func(ctx context.Context, c spam.Comment) (string, error) {
+		switch c.Content {
+		case "Buy now":
+			return "Uses a banned word", nil
+		case "Broken":
+			return "", errors.New("checker is down")
+		}
+		return "", nil
+	}))
+
+	id, err := m.Insert(1, 2, "Bob", "Hello")
+	assert.NilError(t, err)
+	assert.Equal(t, inner.comments[id].Status, "")
+
+	// Flagged comments are kept, but held
+	id, err = m.Insert(1, 2, "Bob", "Buy now")
+	assert.Equal(t, err, ErrCommentHeld)
+	assert.Equal(t, inner.comments[id].Status, StatusPending)
+	assert.Equal(t, inner.comments[id].StatusNote, "Uses a banned word")
+
+	// So are comments the filter could not check
+	id, err = m.Insert(1, 2, "Bob", "Broken")
+	assert.Equal(t, err, ErrCommentHeld)
+	assert.Equal(t, inner.comments[id].StatusNote, "Spam check failed: checker is down")
+}
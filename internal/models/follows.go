@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type FollowModelInterface interface {
+	Toggle(followerID, userID int) (bool, error)
+	Following(followerID, userID int) (bool, error)
+	Counts(userID int) (int, int, error)
+	Feed(userID, page, pageSize int) ([]*FeedItem, int, error)
+}
+
+// Kinds of feed item.
+const (
+	FeedSnippet = "snippet"
+	FeedComment = "comment"
+)
+
+// FeedItem is a snippet or a comment by a followed user. CommentID and
+// Content are only set for comments.
+type FeedItem struct {
+	Kind         string
+	UserID       int
+	UserName     string
+	SnippetID    int
+	SnippetTitle string
+	CommentID    int
+	Content      string
+	Created      time.Time
+}
+
+// FollowModel wraps a sql.DB conn pool. Follows go away with either user.
+type FollowModel struct {
+	DB *sql.DB
+}
+
+// Toggle makes followerID follow userID, or stop following them if they
+// already do, and reports whether followerID now follows userID. It returns
+// ErrNoRecord if userID does not exist and ErrSelfFollow if the two are the
+// same user.
+func (m *FollowModel) Toggle(followerID, userID int) (bool, error) {
+	if followerID == userID {
+		return false, ErrSelfFollow
+	}
+
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE id = ?)`, userID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrNoRecord
+	}
+
+	result, err := m.DB.Exec(`DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`, followerID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if removed > 0 {
+		return false, nil
+	}
+
+	_, err = m.DB.Exec(`INSERT IGNORE INTO follows (follower_id, followee_id, created)
+	VALUES (?, ?, UTC_TIMESTAMP())`, followerID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Following reports whether followerID follows userID.
+func (m *FollowModel) Following(followerID, userID int) (bool, error) {
+	var following bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM follows WHERE follower_id = ? AND followee_id = ?)`,
+		followerID, userID).Scan(&following)
+
+	return following, err
+}
+
+// Counts returns how many users follow the user, and how many the user
+// follows.
+func (m *FollowModel) Counts(userID int) (int, int, error) {
+	var followers, following int
+
+	err := m.DB.QueryRow(`SELECT
+	(SELECT COUNT(*) FROM follows WHERE followee_id = ?),
+	(SELECT COUNT(*) FROM follows WHERE follower_id = ?)`, userID, userID).Scan(&followers, &following)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return followers, following, nil
+}
+
+// feedItems selects the public snippets and the approved comments on them by
+// the users a user follows. Its parameters are the user's ID, twice.
+const feedItems = `
+	SELECT 'snippet' AS kind, s.user_id, u.name, s.id AS snippet_id, s.title, 0 AS comment_id, '' AS content, s.created
+	FROM follows f
+	INNER JOIN snippets s ON s.user_id = f.followee_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE f.follower_id = ? AND s.visibility = 'public' AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL
+	UNION ALL
+	SELECT 'comment', c.author_id, u.name, s.id, s.title, c.id, c.content, c.created
+	FROM follows f
+	INNER JOIN comments c ON c.author_id = f.followee_id
+	INNER JOIN users u ON u.id = c.author_id
+	INNER JOIN snippets s ON s.id = c.snippet_id
+	WHERE f.follower_id = ? AND c.deleted = FALSE AND c.status = 'approved' AND u.shadowbanned = FALSE
+	AND s.visibility = 'public' AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL`
+
+// Feed returns a page of the snippets and comments of the users the user
+// follows, newest first, and the total number of them. Only what anyone
+// could see is included: public snippets, and approved comments on them.
+func (m *FollowModel) Feed(userID, page, pageSize int) ([]*FeedItem, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM (`+feedItems+`) feed`, userID, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT kind, user_id, name, snippet_id, title, comment_id, content, created
+	FROM (` + feedItems + `) feed
+	ORDER BY created DESC, comment_id DESC, snippet_id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []*FeedItem{}
+
+	for rows.Next() {
+		i := &FeedItem{}
+
+		err = rows.Scan(&i.Kind, &i.UserID, &i.UserName, &i.SnippetID, &i.SnippetTitle, &i.CommentID, &i.Content, &i.Created)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		items = append(items, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestFollowModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &FollowModel{DB: db}
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+	('Bob', 'bob@example.com', 'x', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	_, err = m.Toggle(1, 1)
+	assert.Equal(t, err, ErrSelfFollow)
+
+	_, err = m.Toggle(1, 999)
+	assert.Equal(t, err, ErrNoRecord)
+
+	following, err := m.Toggle(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, following, true)
+
+	following, err = m.Following(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, following, true)
+
+	followers, followed, err := m.Counts(1)
+	assert.NilError(t, err)
+	assert.Equal(t, followers, 1)
+	assert.Equal(t, followed, 0)
+
+	snippets := &SnippetModel{DB: db}
+	snippetID, err := snippets.Insert("Hello", "Content", 7, 1)
+	assert.NilError(t, err)
+	_, err = snippets.InsertWithOptions("Hidden", "Content", SnippetOptions{Visibility: VisibilityPrivate}, 7, 1)
+	assert.NilError(t, err)
+
+	comments := &CommentModel{DB: db}
+	commentID, err := comments.Insert(snippetID, 1, "Alice", "Nice, @Bob")
+	assert.NilError(t, err)
+
+	items, total, err := m.Feed(2, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, items[0].Kind, FeedComment)
+	assert.Equal(t, items[0].CommentID, commentID)
+	assert.Equal(t, items[0].Content, "Nice, @Bob")
+	assert.Equal(t, items[0].UserName, "Alice Jones")
+	assert.Equal(t, items[0].SnippetTitle, "Hello")
+
+	// The feed only has what the user follows
+	_, total, err = m.Feed(1, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 0)
+
+	_, _, err = m.Feed(2, 0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+
+	following, err = m.Toggle(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, following, false)
+
+	followers, _, err = m.Counts(1)
+	assert.NilError(t, err)
+	assert.Equal(t, followers, 0)
+}
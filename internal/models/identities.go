@@ -0,0 +1,194 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserIdentityModelInterface interface {
+	Authenticate(provider, providerID string) (int, error)
+	Insert(userID int, provider, providerID string) error
+	InsertUser(provider, providerID, name, email string, verified bool) (int, error)
+	Delete(userID int, provider string) error
+	ForUser(userID int) ([]*UserIdentity, error)
+}
+
+// UserIdentity links a user to their account with an OAuth provider, such
+// as "github" or "google", so they can log in through it.
+type UserIdentity struct {
+	ID         int
+	UserID     int
+	Provider   string
+	ProviderID string
+	Created    time.Time
+}
+
+type UserIdentityModel struct {
+	DB *sql.DB
+}
+
+// Authenticate returns the ID of the user linked to a provider account. It
+// returns ErrNoRecord if no user is and ErrBanned if the user is banned.
+func (m *UserIdentityModel) Authenticate(provider, providerID string) (int, error) {
+	var userID int
+	var banned bool
+
+	stmt := `SELECT i.user_id, u.banned FROM user_identities i
+	INNER JOIN users u ON u.id = i.user_id
+	WHERE i.provider = ? AND i.provider_id = ?`
+
+	err := m.DB.QueryRow(stmt, provider, providerID).Scan(&userID, &banned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	if banned {
+		return 0, ErrBanned
+	}
+
+	return userID, nil
+}
+
+// Insert links a provider account to an existing user. It returns
+// ErrIdentityTaken if the provider account is already linked to a user, or
+// the user already has an account with that provider linked.
+func (m *UserIdentityModel) Insert(userID int, provider, providerID string) error {
+	return insertIdentity(m.DB, userID, provider, providerID)
+}
+
+// InsertUser creates a user for someone logging in with a provider account
+// for the first time and links the account to them. The user gets a random
+// password, which they can replace through a password reset if they want to
+// log in with the form too. It returns ErrDuplicateEmail if another user
+// already has the email address.
+func (m *UserIdentityModel) InsertUser(provider, providerID, name, email string, verified bool) (int, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return 0, err
+	}
+
+	hashPass, err := bcrypt.GenerateFromPassword(b, 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created, verified)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	result, err := tx.Exec(stmt, name, email, hashPass, verified)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return 0, ErrDuplicateEmail
+			}
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertIdentity(tx, int(id), provider, providerID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Delete unlinks a user's account with a provider. It returns ErrNoRecord if
+// the user has none linked.
+func (m *UserIdentityModel) Delete(userID int, provider string) error {
+	result, err := m.DB.Exec(`DELETE FROM user_identities WHERE user_id = ? AND provider = ?`, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// ForUser returns the provider accounts linked to a user, ordered by
+// provider.
+func (m *UserIdentityModel) ForUser(userID int) ([]*UserIdentity, error) {
+	stmt := `SELECT id, user_id, provider, provider_id, created FROM user_identities
+	WHERE user_id = ? ORDER BY provider`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*UserIdentity{}
+
+	for rows.Next() {
+		i := &UserIdentity{}
+
+		err = rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.ProviderID, &i.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertIdentity(db execer, userID int, provider, providerID string) error {
+	stmt := `INSERT INTO user_identities (user_id, provider, provider_id, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := db.Exec(stmt, userID, provider, providerID)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 {
+			return ErrIdentityTaken
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestUserIdentityModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	users := &UserModel{db}
+	m := &UserIdentityModel{DB: db}
+
+	_, err := m.Authenticate("github", "1001")
+	assert.Equal(t, err, ErrNoRecord)
+
+	assert.NilError(t, m.Insert(1, "github", "1001"))
+	assert.Equal(t, m.Insert(1, "github", "1002"), ErrIdentityTaken)
+
+	id, err := m.Authenticate("github", "1001")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	_, err = m.InsertUser("google", "42", "Alice", "alice@example.com", true)
+	assert.Equal(t, err, ErrDuplicateEmail)
+
+	newID, err := m.InsertUser("google", "42", "Jane", "jane@example.com", true)
+	assert.NilError(t, err)
+
+	user, err := users.Get(newID)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Email, "jane@example.com")
+	assert.Equal(t, user.Verified, true)
+
+	_, err = m.InsertUser("github", "1001", "Mallory", "mallory@example.com", true)
+	assert.Equal(t, err, ErrIdentityTaken)
+
+	identities, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(identities), 1)
+	assert.Equal(t, identities[0].Provider, "github")
+
+	assert.NilError(t, m.Delete(1, "github"))
+	assert.Equal(t, m.Delete(1, "github"), ErrNoRecord)
+
+	_, err = m.Authenticate("github", "1001")
+	assert.Equal(t, err, ErrNoRecord)
+}
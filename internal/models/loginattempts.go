@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptModelInterface interface {
+	Fail(email, ip string) (int, error)
+	Clear(email string) error
+	Backoff(email, ip string) (time.Duration, error)
+}
+
+const (
+	// LoginAttemptWindow is how long a failed login counts against an
+	// account and an IP address.
+	LoginAttemptWindow = time.Hour
+	// MaxLoginBackoff caps the wait between attempts.
+	MaxLoginBackoff = 5 * time.Minute
+)
+
+// Failed logins allowed within the window before each further attempt has
+// to wait, per account and per IP address. Many users can share an IP
+// address, so it gets more.
+const (
+	accountBackoffAfter = 3
+	ipBackoffAfter      = 10
+)
+
+// LoginAttemptModel wraps a sql.DB conn pool. It keeps the failed logins of
+// the last LoginAttemptWindow, by the email address tried and the IP address
+// it was tried from, whether or not an account has the email address.
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+// Fail records a failed login and returns the number of failures in a row for
+// email, counting it.
+func (m *LoginAttemptModel) Fail(email, ip string) (int, error) {
+	_, err := m.DB.Exec(`DELETE FROM login_attempts WHERE created < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`, int(LoginAttemptWindow.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO login_attempts (user_id, email, ip, created)
+	VALUES((SELECT id FROM users WHERE email = ?), ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, email, email, ip)
+	if err != nil {
+		return 0, err
+	}
+
+	count, _, err := m.failures(`email = ?`, email)
+
+	return count, err
+}
+
+// Clear forgets the failed logins for email, after a successful one.
+func (m *LoginAttemptModel) Clear(email string) error {
+	_, err := m.DB.Exec(`DELETE FROM login_attempts WHERE email = ?`, email)
+
+	return err
+}
+
+// Backoff returns how long to wait before logging in with email from ip may
+// be tried again. The wait starts at a second once either has failed too
+// often, and doubles with each further failure up to MaxLoginBackoff.
+func (m *LoginAttemptModel) Backoff(email, ip string) (time.Duration, error) {
+	var wait time.Duration
+
+	for _, by := range []struct {
+		where string
+		arg   string
+		after int
+	}{
+		{`email = ?`, email, accountBackoffAfter},
+		{`ip = ?`, ip, ipBackoffAfter},
+	} {
+		count, last, err := m.failures(by.where, by.arg)
+		if err != nil {
+			return 0, err
+		}
+
+		if w := time.Until(last.Add(backoff(count, by.after))); w > wait {
+			wait = w
+		}
+	}
+
+	return wait, nil
+}
+
+// failures counts the failed logins within the window that match where, and
+// returns when the last one was.
+func (m *LoginAttemptModel) failures(where, arg string) (int, time.Time, error) {
+	var count int
+	var last sql.NullTime
+
+	stmt := `SELECT COUNT(*), MAX(created) FROM login_attempts
+	WHERE ` + where + ` AND created >= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	err := m.DB.QueryRow(stmt, arg, int(LoginAttemptWindow.Seconds())).Scan(&count, &last)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, last.Time, nil
+}
+
+// backoff returns the wait after count failures when after are allowed.
+func backoff(count, after int) time.Duration {
+	if count < after {
+		return 0
+	}
+
+	wait := time.Second
+	for i := after; i < count && wait < MaxLoginBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxLoginBackoff {
+		wait = MaxLoginBackoff
+	}
+
+	return wait
+}
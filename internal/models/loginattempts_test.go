@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{20, MaxLoginBackoff},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, backoff(tt.count, 3), tt.want)
+	}
+}
+
+func TestLoginAttemptModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := &LoginAttemptModel{DB: newTestDB(t)}
+
+	for i := 1; i <= 2; i++ {
+		failures, err := m.Fail("alice@example.com", "192.0.2.1")
+		assert.NilError(t, err)
+		assert.Equal(t, failures, i)
+	}
+
+	wait, err := m.Backoff("alice@example.com", "192.0.2.1")
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Duration(0))
+
+	// Four failures make the account wait two seconds, from any IP address
+	for i := 0; i < 2; i++ {
+		_, err = m.Fail("alice@example.com", "192.0.2.1")
+		assert.NilError(t, err)
+	}
+
+	wait, err = m.Backoff("alice@example.com", "192.0.2.2")
+	assert.NilError(t, err)
+	assert.Equal(t, wait > 0 && wait <= 2*time.Second, true)
+
+	// The IP address has not failed often enough to slow down other accounts
+	wait, err = m.Backoff("bob@example.com", "192.0.2.1")
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Duration(0))
+
+	assert.NilError(t, m.Clear("alice@example.com"))
+
+	failures, err := m.Fail("alice@example.com", "192.0.2.1")
+	assert.NilError(t, err)
+	assert.Equal(t, failures, 1)
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+var mockAPITokens = map[string]*models.APIToken{
+	"sbx_read": {
+		ID:      1,
+		UserID:  1,
+		Name:    "Read only",
+		Scopes:  []string{models.ScopeRead},
+		Created: time.Now(),
+	},
+	"sbx_readwrite": {
+		ID:       2,
+		UserID:   1,
+		Name:     "Deploy script",
+		Scopes:   []string{models.ScopeRead, models.ScopeWrite},
+		Created:  time.Now(),
+		LastUsed: time.Now(),
+	},
+}
+
+type APITokenModel struct{}
+
+func (m *APITokenModel) Insert(userID int, name string, scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", models.ErrInvalidScope
+	}
+
+	return "sbx_new", nil
+}
+
+func (m *APITokenModel) Authenticate(token string) (*models.APIToken, error) {
+	if t, ok := mockAPITokens[token]; ok {
+		return t, nil
+	}
+
+	return nil, models.ErrInvalidAPIToken
+}
+
+func (m *APITokenModel) ForUser(userID int) ([]*models.APIToken, error) {
+	if userID == 1 {
+		return []*models.APIToken{mockAPITokens["sbx_readwrite"], mockAPITokens["sbx_read"]}, nil
+	}
+
+	return []*models.APIToken{}, nil
+}
+
+func (m *APITokenModel) Delete(userID, id int) error {
+	for _, t := range mockAPITokens {
+		if t.UserID == userID && t.ID == id {
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
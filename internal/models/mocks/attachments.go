@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// AttachmentModel keeps the attachments it is given in memory, all of them on
+// comments of the mock snippet. Those with no CommentID are orphans.
+type AttachmentModel struct {
+	Attachments []*models.Attachment
+}
+
+func (m *AttachmentModel) Insert(a *models.Attachment) (int, error) {
+	a.ID = len(m.Attachments) + 1
+	a.SnippetID = mockSnippet.ID
+	a.Created = time.Now()
+
+	m.Attachments = append(m.Attachments, a)
+
+	return a.ID, nil
+}
+
+func (m *AttachmentModel) Get(id int) (*models.Attachment, error) {
+	for _, a := range m.Attachments {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *AttachmentModel) Count(commentID int) (int, error) {
+	var count int
+	for _, a := range m.Attachments {
+		if a.CommentID == commentID {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *AttachmentModel) ForComments(commentIDs []int) (map[int][]*models.Attachment, error) {
+	attachments := make(map[int][]*models.Attachment)
+
+	for _, id := range commentIDs {
+		for _, a := range m.Attachments {
+			if a.CommentID == id {
+				attachments[id] = append(attachments[id], a)
+			}
+		}
+	}
+
+	return attachments, nil
+}
+
+func (m *AttachmentModel) Orphans(limit int) ([]*models.Attachment, error) {
+	orphans := []*models.Attachment{}
+	for _, a := range m.Attachments {
+		if a.CommentID == 0 && len(orphans) < limit {
+			orphans = append(orphans, a)
+		}
+	}
+
+	return orphans, nil
+}
+
+func (m *AttachmentModel) Delete(id int) error {
+	for i, a := range m.Attachments {
+		if a.ID == id {
+			m.Attachments = append(m.Attachments[:i], m.Attachments[i+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
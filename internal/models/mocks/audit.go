@@ -0,0 +1,86 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// AuditModel keeps the entries it is given in memory, so tests can check
+// what was recorded.
+type AuditModel struct {
+	Entries []*models.AuditEntry
+}
+
+func (m *AuditModel) Insert(userID int, action, detail, ip string) error {
+	e := &models.AuditEntry{
+		ID:      len(m.Entries) + 1,
+		UserID:  userID,
+		Action:  action,
+		Detail:  detail,
+		IP:      ip,
+		Created: time.Now(),
+	}
+	for _, usr := range mockUsers() {
+		if usr.ID == userID {
+			e.UserName = usr.Name
+		}
+	}
+
+	m.Entries = append(m.Entries, e)
+
+	return nil
+}
+
+func (m *AuditModel) InsertFailedLogin(email, ip string) error {
+	var userID int
+	for _, usr := range mockUsers() {
+		if usr.Email == email {
+			userID = usr.ID
+		}
+	}
+
+	return m.Insert(userID, models.AuditLoginFailed, email, ip)
+}
+
+func (m *AuditModel) List(filter models.AuditFilter, page, pageSize int) ([]*models.AuditEntry, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+
+	entries := []*models.AuditEntry{}
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		e := m.Entries[i]
+		if filter.UserID != 0 && e.UserID != filter.UserID {
+			continue
+		}
+		if filter.Action != "" && e.Action != filter.Action {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	total := len(entries)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return entries[start:end], total, nil
+}
+
+func (m *AuditModel) Logins(userID, limit int) ([]*models.AuditEntry, error) {
+	entries := []*models.AuditEntry{}
+	for i := len(m.Entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		e := m.Entries[i]
+		if e.UserID == userID && (e.Action == models.AuditLogin || e.Action == models.AuditLoginFailed) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
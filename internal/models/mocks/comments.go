@@ -0,0 +1,827 @@
+package mocks
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	AuthorID:  1,
+	Author:    "John",
+	Content:   "What a lovely haiku",
+	Created:   time.Now(),
+	Updated:   time.Now(),
+	Status:    models.StatusApproved,
+	Reactions: []models.ReactionCount{
+		{Reaction: models.ReactionTypes[0], Count: 2},
+		{Reaction: models.ReactionTypes[1]},
+		{Reaction: models.ReactionTypes[2]},
+		{Reaction: models.ReactionTypes[3]},
+	},
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID int, authorID int, author string, content string) (int, error) {
+	if strings.TrimSpace(content) == "" {
+		return 0, models.ErrCommentEmpty
+	}
+	if utf8.RuneCountInString(content) > models.MaxCommentLength {
+		return 0, models.ErrCommentTooLong
+	}
+	return 2, nil
+}
+
+func (m *CommentModel) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	return m.Insert(snippetID, authorID, author, content)
+}
+
+func (m *CommentModel) GetBySnippetID(snippetID int) ([]*models.Comment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Comment{mockComment}, nil
+	default:
+		return []*models.Comment{}, nil
+	}
+}
+
+func (m *CommentModel) GetBySnippetIDContext(ctx context.Context, snippetID int) ([]*models.Comment, error) {
+	return m.GetBySnippetID(snippetID)
+}
+
+func (m *CommentModel) InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error) {
+	if snippetID != 1 || parentID != 1 {
+		return 0, models.ErrNoRecord
+	}
+	return m.Insert(snippetID, authorID, author, content)
+}
+
+func (m *CommentModel) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return m.InsertReply(snippetID, parentID, authorID, author, content)
+}
+
+func (m *CommentModel) InsertBatch(comments []models.CommentInput) ([]int, error) {
+	if len(comments) > models.MaxBatchSize {
+		return nil, models.ErrBatchTooLarge
+	}
+	ids := []int{}
+	for i := range comments {
+		ids = append(ids, 2+i)
+	}
+	return ids, nil
+}
+
+func (m *CommentModel) InsertBatchContext(ctx context.Context, comments []models.CommentInput) ([]int, error) {
+	return m.InsertBatch(comments)
+}
+
+func (m *CommentModel) GetBySnippetIDPaginated(snippetID, limit, offset int) ([]*models.Comment, int, error) {
+	if limit < 0 || offset < 0 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+	comments, _ := m.GetBySnippetID(snippetID)
+	total := len(comments)
+	if offset >= total {
+		return []*models.Comment{}, total, nil
+	}
+	comments = comments[offset:]
+	if limit < len(comments) {
+		comments = comments[:limit]
+	}
+	return comments, total, nil
+}
+
+func (m *CommentModel) GetBySnippetIDPaginatedContext(ctx context.Context, snippetID, limit, offset int) ([]*models.Comment, int, error) {
+	return m.GetBySnippetIDPaginated(snippetID, limit, offset)
+}
+
+func (m *CommentModel) GetBySnippetIDPaged(snippetID, page, pageSize int) ([]*models.Comment, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+	return m.GetBySnippetIDPaginated(snippetID, pageSize, (page-1)*pageSize)
+}
+
+func (m *CommentModel) GetBySnippetIDPagedContext(ctx context.Context, snippetID, page, pageSize int) ([]*models.Comment, int, error) {
+	return m.GetBySnippetIDPaged(snippetID, page, pageSize)
+}
+
+func (m *CommentModel) GetBySnippetIDSorted(snippetID int, order models.SortOrder) ([]*models.Comment, error) {
+	switch order {
+	case models.SortOldest, models.SortNewest, models.SortTop, models.SortControversial:
+		return m.GetBySnippetID(snippetID)
+	default:
+		return nil, models.ErrInvalidSortOrder
+	}
+}
+
+func (m *CommentModel) GetBySnippetIDSortedContext(ctx context.Context, snippetID int, order models.SortOrder) ([]*models.Comment, error) {
+	return m.GetBySnippetIDSorted(snippetID, order)
+}
+
+func (m *CommentModel) GetBySnippetIDSince(snippetID int, since time.Time) ([]*models.Comment, error) {
+	comments, err := m.GetBySnippetID(snippetID)
+	if err != nil || since.IsZero() {
+		return comments, err
+	}
+	recent := []*models.Comment{}
+	for _, c := range comments {
+		if c.Created.After(since) {
+			recent = append(recent, c)
+		}
+	}
+	return recent, nil
+}
+
+func (m *CommentModel) GetBySnippetIDSinceContext(ctx context.Context, snippetID int, since time.Time) ([]*models.Comment, error) {
+	return m.GetBySnippetIDSince(snippetID, since)
+}
+
+func (m *CommentModel) CountBySnippetID(snippetID int) (int, error) {
+	comments, _ := m.GetBySnippetID(snippetID)
+	return len(comments), nil
+}
+
+func (m *CommentModel) CountBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	return m.CountBySnippetID(snippetID)
+}
+
+func (m *CommentModel) CountBySnippetIDs(ids []int) (map[int]int, error) {
+	counts := make(map[int]int, len(ids))
+	for _, id := range ids {
+		counts[id], _ = m.CountBySnippetID(id)
+	}
+	return counts, nil
+}
+
+func (m *CommentModel) CountBySnippetIDsContext(ctx context.Context, ids []int) (map[int]int, error) {
+	return m.CountBySnippetIDs(ids)
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case 1:
+		return mockComment, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) GetContext(ctx context.Context, id int) (*models.Comment, error) {
+	return m.Get(id)
+}
+
+func (m *CommentModel) Update(id int, content string) error {
+	if id != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) UpdateContext(ctx context.Context, id int, content string) error {
+	return m.Update(id, content)
+}
+
+func (m *CommentModel) UpdateWithVersion(id int, content string, expectedVersion int) error {
+	if id != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	if expectedVersion != mockComment.Version {
+		return models.ErrStaleVersion
+	}
+	return nil
+}
+
+func (m *CommentModel) UpdateWithVersionContext(ctx context.Context, id int, content string, expectedVersion int) error {
+	return m.UpdateWithVersion(id, content, expectedVersion)
+}
+
+func (m *CommentModel) Upvote(commentID, userID int) (models.VoteResult, error) {
+	if commentID == mockComment.ID && userID == mockComment.AuthorID {
+		return models.VoteResult{}, models.ErrSelfVote
+	}
+	return models.VoteResult{Action: models.ActionAdded, Type: "upvote", Score: 1}, nil
+}
+
+func (m *CommentModel) UpvoteContext(ctx context.Context, commentID, userID int) (models.VoteResult, error) {
+	return m.Upvote(commentID, userID)
+}
+
+func (m *CommentModel) Downvote(commentID, userID int) (models.VoteResult, error) {
+	if commentID == mockComment.ID && userID == mockComment.AuthorID {
+		return models.VoteResult{}, models.ErrSelfVote
+	}
+	return models.VoteResult{Action: models.ActionAdded, Type: "downvote", Score: 1}, nil
+}
+
+func (m *CommentModel) DownvoteContext(ctx context.Context, commentID, userID int) (models.VoteResult, error) {
+	return m.Downvote(commentID, userID)
+}
+
+func (m *CommentModel) Delete(id int, deletedBy int) error {
+	if id != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) DeleteContext(ctx context.Context, id int, deletedBy int) error {
+	return m.Delete(id, deletedBy)
+}
+
+func (m *CommentModel) DeleteBySnippetID(snippetID int) (int, error) {
+	comments, _ := m.GetBySnippetID(snippetID)
+	return len(comments), nil
+}
+
+func (m *CommentModel) DeleteBySnippetIDContext(ctx context.Context, snippetID int) (int, error) {
+	return m.DeleteBySnippetID(snippetID)
+}
+
+func (m *CommentModel) VoteTimeline(commentID int) ([]models.VoteEvent, error) {
+	return []models.VoteEvent{}, nil
+}
+
+func (m *CommentModel) VoteTimelineContext(ctx context.Context, commentID int) ([]models.VoteEvent, error) {
+	return m.VoteTimeline(commentID)
+}
+
+func (m *CommentModel) SetPinOrder(snippetID int, orderedCommentIDs []int) error {
+	for _, id := range orderedCommentIDs {
+		if id != 1 {
+			return models.ErrNoRecord
+		}
+	}
+	return nil
+}
+
+func (m *CommentModel) SetPinOrderContext(ctx context.Context, snippetID int, orderedCommentIDs []int) error {
+	return m.SetPinOrder(snippetID, orderedCommentIDs)
+}
+
+func (m *CommentModel) Pin(commentID int) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) PinContext(ctx context.Context, commentID int) error {
+	return m.Pin(commentID)
+}
+
+func (m *CommentModel) Unpin(commentID int) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) UnpinContext(ctx context.Context, commentID int) error {
+	return m.Unpin(commentID)
+}
+
+func (m *CommentModel) SimilarTo(commentID int, limit int) ([]*models.Comment, error) {
+	switch commentID {
+	case 1:
+		return []*models.Comment{}, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) SimilarToContext(ctx context.Context, commentID int, limit int) ([]*models.Comment, error) {
+	return m.SimilarTo(commentID, limit)
+}
+
+func (m *CommentModel) CountByStatus() (map[string]int, error) {
+	return map[string]int{
+		models.StatusApproved: 1,
+		models.StatusPending:  0,
+		models.StatusRejected: 0,
+	}, nil
+}
+
+func (m *CommentModel) CountByStatusContext(ctx context.Context) (map[string]int, error) {
+	return m.CountByStatus()
+}
+
+func (m *CommentModel) AuthorCommentsOnOwner(authorUserID, ownerUserID int) ([]*models.CommentWithContext, error) {
+	if authorUserID == 1 && ownerUserID == 1 {
+		return []*models.CommentWithContext{{Comment: *mockComment, SnippetTitle: mockSnippet.Title}}, nil
+	}
+	return []*models.CommentWithContext{}, nil
+}
+
+func (m *CommentModel) AuthorCommentsOnOwnerContext(ctx context.Context, authorUserID, ownerUserID int) ([]*models.CommentWithContext, error) {
+	return m.AuthorCommentsOnOwner(authorUserID, ownerUserID)
+}
+
+func (m *CommentModel) ThreadHealth(snippetID int) (models.ThreadHealth, error) {
+	return models.ThreadHealth{SnippetID: snippetID, Score: 1}, nil
+}
+
+func (m *CommentModel) ThreadHealthContext(ctx context.Context, snippetID int) (models.ThreadHealth, error) {
+	return m.ThreadHealth(snippetID)
+}
+
+func (m *CommentModel) PreviewReplyPlacement(parentID int) (int, []int, bool, error) {
+	switch parentID {
+	case 1:
+		return 1, []int{1}, true, nil
+	default:
+		return 0, nil, false, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) PreviewReplyPlacementContext(ctx context.Context, parentID int) (int, []int, bool, error) {
+	return m.PreviewReplyPlacement(parentID)
+}
+
+func (m *CommentModel) MostEdited(limit int) ([]*models.Comment, error) {
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) MostEditedContext(ctx context.Context, limit int) ([]*models.Comment, error) {
+	return m.MostEdited(limit)
+}
+
+func (m *CommentModel) EditableBy(userID int) ([]*models.Comment, error) {
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) EditableByContext(ctx context.Context, userID int) ([]*models.Comment, error) {
+	return m.EditableBy(userID)
+}
+
+func (m *CommentModel) VoteSpikes(window time.Duration, minVotes int) ([]models.SpikeAlert, error) {
+	return []models.SpikeAlert{}, nil
+}
+
+func (m *CommentModel) VoteSpikesContext(ctx context.Context, window time.Duration, minVotes int) ([]models.SpikeAlert, error) {
+	return m.VoteSpikes(window, minVotes)
+}
+
+func (m *CommentModel) UnresolvedQuestions(limit, offset int) ([]*models.CommentWithContext, error) {
+	if limit < 0 || offset < 0 {
+		return nil, models.ErrInvalidPagination
+	}
+	return []*models.CommentWithContext{}, nil
+}
+
+func (m *CommentModel) UnresolvedQuestionsContext(ctx context.Context, limit, offset int) ([]*models.CommentWithContext, error) {
+	return m.UnresolvedQuestions(limit, offset)
+}
+
+func (m *CommentModel) IncrementViews(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) IncrementViewsContext(ctx context.Context, id int) error {
+	return m.IncrementViews(id)
+}
+
+func (m *CommentModel) GetBySnippetIDWithStaff(snippetID int) ([]*models.Comment, error) {
+	return m.GetBySnippetID(snippetID)
+}
+
+func (m *CommentModel) GetBySnippetIDWithStaffContext(ctx context.Context, snippetID int) ([]*models.Comment, error) {
+	return m.GetBySnippetIDWithStaff(snippetID)
+}
+
+func (m *CommentModel) RecentByOwner(ownerUserID int, perSnippet int) (map[int][]*models.Comment, error) {
+	if ownerUserID == 1 && perSnippet > 0 {
+		return map[int][]*models.Comment{1: {mockComment}}, nil
+	}
+	return map[int][]*models.Comment{}, nil
+}
+
+func (m *CommentModel) RecentByOwnerContext(ctx context.Context, ownerUserID int, perSnippet int) (map[int][]*models.Comment, error) {
+	return m.RecentByOwner(ownerUserID, perSnippet)
+}
+
+func (m *CommentModel) SetModeratorNote(commentID int, note string) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) SetModeratorNoteContext(ctx context.Context, commentID int, note string) error {
+	return m.SetModeratorNote(commentID, note)
+}
+
+func (m *CommentModel) RejectMatching(pattern string, reason string) (int, error) {
+	if pattern == "" {
+		return 0, models.ErrEmptyPattern
+	}
+	return 1, nil
+}
+
+func (m *CommentModel) RejectMatchingContext(ctx context.Context, pattern string, reason string) (int, error) {
+	return m.RejectMatching(pattern, reason)
+}
+
+func (m *CommentModel) MatchingIDs(pattern string) ([]int, error) {
+	if pattern == "" {
+		return nil, models.ErrEmptyPattern
+	}
+	return []int{1}, nil
+}
+
+func (m *CommentModel) MatchingIDsContext(ctx context.Context, pattern string) ([]int, error) {
+	return m.MatchingIDs(pattern)
+}
+
+func (m *CommentModel) AuthorActivitySpan(authorUserID int) (time.Time, time.Time, int, error) {
+	if authorUserID == 1 {
+		return mockComment.Created, mockComment.Created, 1, nil
+	}
+	return time.Time{}, time.Time{}, 0, nil
+}
+
+func (m *CommentModel) AuthorActivitySpanContext(ctx context.Context, authorUserID int) (time.Time, time.Time, int, error) {
+	return m.AuthorActivitySpan(authorUserID)
+}
+
+func (m *CommentModel) ChangedForIndex(sinceID int, limit int) ([]*models.Comment, int, error) {
+	if sinceID < 1 && limit > 0 {
+		return []*models.Comment{mockComment}, 1, nil
+	}
+	return []*models.Comment{}, sinceID, nil
+}
+
+func (m *CommentModel) ChangedForIndexContext(ctx context.Context, sinceID int, limit int) ([]*models.Comment, int, error) {
+	return m.ChangedForIndex(sinceID, limit)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewer(snippetID int, viewerID int) ([]*models.Comment, error) {
+	return m.GetBySnippetID(snippetID)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewerContext(ctx context.Context, snippetID int, viewerID int) ([]*models.Comment, error) {
+	return m.GetBySnippetIDForViewer(snippetID, viewerID)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewerSorted(snippetID int, viewerID int, order models.SortOrder) ([]*models.Comment, error) {
+	return m.GetBySnippetIDSorted(snippetID, order)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewerSortedContext(ctx context.Context, snippetID int, viewerID int, order models.SortOrder) ([]*models.Comment, error) {
+	return m.GetBySnippetIDForViewerSorted(snippetID, viewerID, order)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewerPaged(snippetID int, viewerID int, order models.SortOrder, page, pageSize int) ([]*models.Comment, int, error) {
+	if _, err := m.GetBySnippetIDSorted(snippetID, order); err != nil {
+		return nil, 0, err
+	}
+	return m.GetBySnippetIDPaged(snippetID, page, pageSize)
+}
+
+func (m *CommentModel) GetBySnippetIDForViewerPagedContext(ctx context.Context, snippetID int, viewerID int, order models.SortOrder, page, pageSize int) ([]*models.Comment, int, error) {
+	return m.GetBySnippetIDForViewerPaged(snippetID, viewerID, order, page, pageSize)
+}
+
+func (m *CommentModel) SetShadowban(authorUserID int, banned bool) error {
+	for _, usr := range mockUsers() {
+		if usr.ID == authorUserID {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CommentModel) SetShadowbanContext(ctx context.Context, authorUserID int, banned bool) error {
+	return m.SetShadowban(authorUserID, banned)
+}
+
+func (m *CommentModel) Shadowbanned(authorUserID int) (bool, error) {
+	return false, nil
+}
+
+func (m *CommentModel) ShadowbannedContext(ctx context.Context, authorUserID int) (bool, error) {
+	return m.Shadowbanned(authorUserID)
+}
+
+func (m *CommentModel) ParticipatedThreads(userID int, limit, offset int) ([]models.ThreadSummary, error) {
+	if limit < 0 || offset < 0 {
+		return nil, models.ErrInvalidPagination
+	}
+	if userID == 1 && offset == 0 && limit > 0 {
+		return []models.ThreadSummary{{
+			SnippetID:    mockSnippet.ID,
+			SnippetTitle: mockSnippet.Title,
+			CommentCount: 1,
+			LastActivity: mockComment.Updated,
+		}}, nil
+	}
+	return []models.ThreadSummary{}, nil
+}
+
+func (m *CommentModel) ParticipatedThreadsContext(ctx context.Context, userID int, limit, offset int) ([]models.ThreadSummary, error) {
+	return m.ParticipatedThreads(userID, limit, offset)
+}
+
+func (m *CommentModel) RankDeltas(snippetID int, before map[int]int) ([]models.RankChange, error) {
+	return []models.RankChange{}, nil
+}
+
+func (m *CommentModel) RankDeltasContext(ctx context.Context, snippetID int, before map[int]int) ([]models.RankChange, error) {
+	return m.RankDeltas(snippetID, before)
+}
+
+func (m *CommentModel) GetBySnippetIDIncludingDeleted(snippetID int) ([]*models.Comment, error) {
+	return m.GetBySnippetID(snippetID)
+}
+
+func (m *CommentModel) GetBySnippetIDIncludingDeletedContext(ctx context.Context, snippetID int) ([]*models.Comment, error) {
+	return m.GetBySnippetIDIncludingDeleted(snippetID)
+}
+
+func (m *CommentModel) Restore(id int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) RestoreContext(ctx context.Context, id int) error {
+	return m.Restore(id)
+}
+
+func (m *CommentModel) Trash(authorID int) ([]*models.CommentWithContext, error) {
+	if authorID != mockComment.AuthorID {
+		return []*models.CommentWithContext{}, nil
+	}
+	c := *mockComment
+	c.Deleted = true
+	return []*models.CommentWithContext{{Comment: c, SnippetTitle: "An old silent pond"}}, nil
+}
+
+func (m *CommentModel) TrashContext(ctx context.Context, authorID int) ([]*models.CommentWithContext, error) {
+	return m.Trash(authorID)
+}
+
+func (m *CommentModel) PurgeDeleted() (int, error) {
+	return 0, nil
+}
+
+func (m *CommentModel) PurgeDeletedContext(ctx context.Context) (int, error) {
+	return m.PurgeDeleted()
+}
+
+func (m *CommentModel) GetByIDs(ids []int) (map[int]*models.Comment, error) {
+	comments := make(map[int]*models.Comment, len(ids))
+	for _, id := range ids {
+		if id == mockComment.ID {
+			comments[id] = mockComment
+		}
+	}
+	return comments, nil
+}
+
+func (m *CommentModel) GetByIDsContext(ctx context.Context, ids []int) (map[int]*models.Comment, error) {
+	return m.GetByIDs(ids)
+}
+
+func (m *CommentModel) GetUserVotes(userID int, commentIDs []int) (map[int]string, error) {
+	return map[int]string{}, nil
+}
+
+func (m *CommentModel) GetUserVotesContext(ctx context.Context, userID int, commentIDs []int) (map[int]string, error) {
+	return m.GetUserVotes(userID, commentIDs)
+}
+
+func (m *CommentModel) Search(author string, query string, limit int) ([]*models.Comment, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, models.ErrEmptySearch
+	}
+	if limit > 0 && (author == "" || author == mockComment.Author) && strings.Contains(mockComment.Content, query) {
+		return []*models.Comment{mockComment}, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) SearchContext(ctx context.Context, author string, query string, limit int) ([]*models.Comment, error) {
+	return m.Search(author, query, limit)
+}
+
+func (m *CommentModel) GetRecentByAuthor(author string, limit int) ([]*models.Comment, error) {
+	if author == mockComment.Author && limit > 0 {
+		return []*models.Comment{mockComment}, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) GetRecentByAuthorContext(ctx context.Context, author string, limit int) ([]*models.Comment, error) {
+	return m.GetRecentByAuthor(author, limit)
+}
+
+func (m *CommentModel) GetMostDiscussedSnippets(since time.Duration, limit int) ([]models.SnippetCommentCount, error) {
+	if limit > 0 {
+		return []models.SnippetCommentCount{{SnippetID: mockComment.SnippetID, Count: 1}}, nil
+	}
+	return []models.SnippetCommentCount{}, nil
+}
+
+func (m *CommentModel) GetMostDiscussedSnippetsContext(ctx context.Context, since time.Duration, limit int) ([]models.SnippetCommentCount, error) {
+	return m.GetMostDiscussedSnippets(since, limit)
+}
+
+func (m *CommentModel) Report(commentID, reporterID int, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return models.ErrInvalidReason
+	}
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	if reporterID == 6 {
+		return models.ErrAlreadyReported
+	}
+	return nil
+}
+
+func (m *CommentModel) ReportContext(ctx context.Context, commentID, reporterID int, reason string) error {
+	return m.Report(commentID, reporterID, reason)
+}
+
+func (m *CommentModel) GetReported(minReports int) ([]*models.Comment, error) {
+	if minReports > 1 {
+		return []*models.Comment{}, nil
+	}
+	return []*models.Comment{mockComment}, nil
+}
+
+func (m *CommentModel) GetReportedContext(ctx context.Context, minReports int) ([]*models.Comment, error) {
+	return m.GetReported(minReports)
+}
+
+func (m *CommentModel) TopAuthorsBySnippet(snippetID int, limit int) ([]models.AuthorScore, error) {
+	if limit > 0 && snippetID == mockComment.SnippetID {
+		return []models.AuthorScore{{Author: mockComment.Author, TotalUpvotes: mockComment.Upvotes}}, nil
+	}
+	return []models.AuthorScore{}, nil
+}
+
+func (m *CommentModel) TopAuthorsBySnippetContext(ctx context.Context, snippetID int, limit int) ([]models.AuthorScore, error) {
+	return m.TopAuthorsBySnippet(snippetID, limit)
+}
+
+func (m *CommentModel) RecalculateVotes(commentID int) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) RecalculateVotesContext(ctx context.Context, commentID int) error {
+	return m.RecalculateVotes(commentID)
+}
+
+func (m *CommentModel) ByAuthor(authorID, viewerID, page, pageSize int) ([]*models.CommentWithContext, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+	if authorID != mockComment.AuthorID {
+		return []*models.CommentWithContext{}, 0, nil
+	}
+	if page > 1 {
+		return []*models.CommentWithContext{}, 1, nil
+	}
+	return []*models.CommentWithContext{{Comment: *mockComment, SnippetTitle: "An old silent pond"}}, 1, nil
+}
+
+func (m *CommentModel) ByAuthorContext(ctx context.Context, authorID, viewerID, page, pageSize int) ([]*models.CommentWithContext, int, error) {
+	return m.ByAuthor(authorID, viewerID, page, pageSize)
+}
+
+func (m *CommentModel) ForOwnerSince(ownerID int, since time.Time, limit int) ([]*models.CommentWithContext, error) {
+	if ownerID != mockSnippet.UserID || limit < 1 {
+		return []*models.CommentWithContext{}, nil
+	}
+	return []*models.CommentWithContext{{Comment: *mockComment, SnippetTitle: mockSnippet.Title}}, nil
+}
+
+func (m *CommentModel) ForOwnerSinceContext(ctx context.Context, ownerID int, since time.Time, limit int) ([]*models.CommentWithContext, error) {
+	return m.ForOwnerSince(ownerID, since, limit)
+}
+
+func (m *CommentModel) Count() (int, error) {
+	return 1, nil
+}
+
+func (m *CommentModel) CountContext(ctx context.Context) (int, error) {
+	return m.Count()
+}
+
+func (m *CommentModel) MostVoted(limit int) ([]*models.Comment, error) {
+	if limit < 1 {
+		return []*models.Comment{}, nil
+	}
+	return []*models.Comment{mockComment}, nil
+}
+
+func (m *CommentModel) MostVotedContext(ctx context.Context, limit int) ([]*models.Comment, error) {
+	return m.MostVoted(limit)
+}
+
+func (m *CommentModel) DeleteByAuthor(authorID int, deletedBy int) (int, error) {
+	if authorID == mockComment.AuthorID {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *CommentModel) DeleteByAuthorContext(ctx context.Context, authorID int, deletedBy int) (int, error) {
+	return m.DeleteByAuthor(authorID, deletedBy)
+}
+
+func (m *CommentModel) ReportsByCommentIDs(ids []int) (map[int][]*models.CommentReport, error) {
+	reports := map[int][]*models.CommentReport{}
+	for _, id := range ids {
+		if id == mockComment.ID {
+			reports[id] = []*models.CommentReport{{ID: 1, CommentID: id, UserID: 5, Reason: "Spam", Created: time.Now()}}
+		}
+	}
+	return reports, nil
+}
+
+func (m *CommentModel) ReportsByCommentIDsContext(ctx context.Context, ids []int) (map[int][]*models.CommentReport, error) {
+	return m.ReportsByCommentIDs(ids)
+}
+
+func (m *CommentModel) DismissReports(commentID int) (int, error) {
+	if commentID == mockComment.ID {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *CommentModel) DismissReportsContext(ctx context.Context, commentID int) (int, error) {
+	return m.DismissReports(commentID)
+}
+
+func (m *CommentModel) Hide(commentID int, reason string) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) HideContext(ctx context.Context, commentID int, reason string) error {
+	return m.Hide(commentID, reason)
+}
+
+func (m *CommentModel) ToggleReaction(commentID, userID int, reaction string) (bool, error) {
+	switch {
+	case reaction != "thumbsup" && reaction != "heart" && reaction != "smile" && reaction != "tada":
+		return false, models.ErrInvalidReaction
+	case commentID != mockComment.ID:
+		return false, models.ErrNoRecord
+	}
+	return true, nil
+}
+
+func (m *CommentModel) ToggleReactionContext(ctx context.Context, commentID, userID int, reaction string) (bool, error) {
+	return m.ToggleReaction(commentID, userID, reaction)
+}
+
+func (m *CommentModel) Hold(commentID int, reason string) error {
+	return nil
+}
+
+func (m *CommentModel) HoldContext(ctx context.Context, commentID int, reason string) error {
+	return m.Hold(commentID, reason)
+}
+
+func (m *CommentModel) Approve(commentID int) error {
+	if commentID != mockComment.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *CommentModel) ApproveContext(ctx context.Context, commentID int) error {
+	return m.Approve(commentID)
+}
+
+func (m *CommentModel) GetHeld() ([]*models.Comment, error) {
+	return []*models.Comment{mockComment}, nil
+}
+
+func (m *CommentModel) GetHeldContext(ctx context.Context) ([]*models.Comment, error) {
+	return m.GetHeld()
+}
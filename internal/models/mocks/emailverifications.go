@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type EmailVerificationModel struct{}
+
+func (m *EmailVerificationModel) Create(email string, ttl time.Duration) (string, error) {
+	switch email {
+	case "jay@email.com":
+		return "", models.ErrAlreadyVerified
+	case "nobody@example.com":
+		return "", models.ErrNoRecord
+	default:
+		return "verify-token", nil
+	}
+}
+
+func (m *EmailVerificationModel) Verify(token string) (int, error) {
+	switch token {
+	case "verify-token":
+		return 3, nil
+	default:
+		return 0, models.ErrInvalidVerifyToken
+	}
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type ExportModel struct{}
+
+func (m *ExportModel) Snippets(userID, afterID, limit int) ([]*models.Snippet, error) {
+	if userID == mockSnippet.UserID && afterID < mockSnippet.ID && limit > 0 {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+
+	return []*models.Snippet{}, nil
+}
+
+func (m *ExportModel) Comments(userID, afterID, limit int) ([]*models.Comment, error) {
+	if userID == mockComment.AuthorID && afterID < mockComment.ID && limit > 0 {
+		return []*models.Comment{mockComment}, nil
+	}
+
+	return []*models.Comment{}, nil
+}
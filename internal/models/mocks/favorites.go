@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type favorite struct {
+	snippetID int
+	userID    int
+}
+
+// FavoriteModel keeps the stars it is given in memory, most recent last.
+type FavoriteModel struct {
+	favorites []favorite
+}
+
+func (m *FavoriteModel) Toggle(snippetID, userID int) (bool, error) {
+	if _, err := (&SnippetModel{}).Get(snippetID); err != nil {
+		return false, err
+	}
+
+	for i, f := range m.favorites {
+		if f.snippetID == snippetID && f.userID == userID {
+			m.favorites = append(m.favorites[:i], m.favorites[i+1:]...)
+			return false, nil
+		}
+	}
+
+	m.favorites = append(m.favorites, favorite{snippetID, userID})
+
+	return true, nil
+}
+
+func (m *FavoriteModel) Starred(snippetID, userID int) (bool, error) {
+	for _, f := range m.favorites {
+		if f.snippetID == snippetID && f.userID == userID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *FavoriteModel) List(userID, page, pageSize int) ([]*models.Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+
+	snippets := []*models.Snippet{}
+	for i := len(m.favorites) - 1; i >= 0; i-- {
+		f := m.favorites[i]
+		if f.userID != userID {
+			continue
+		}
+		s, _ := (&SnippetModel{}).Get(f.snippetID)
+		if s.Visibility == models.VisibilityPrivate && s.UserID != userID {
+			continue
+		}
+		snippets = append(snippets, s)
+	}
+
+	total := len(snippets)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return snippets[start:end], total, nil
+}
@@ -0,0 +1,90 @@
+package mocks
+
+import (
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type follow struct {
+	followerID int
+	userID     int
+}
+
+// FollowModel keeps the follows it is given in memory. The feed of a user
+// following the mock user 1 holds the mock snippet.
+type FollowModel struct {
+	follows []follow
+}
+
+func (m *FollowModel) Toggle(followerID, userID int) (bool, error) {
+	if followerID == userID {
+		return false, models.ErrSelfFollow
+	}
+	if _, err := (&UserModel{}).Get(userID); err != nil {
+		return false, err
+	}
+
+	for i, f := range m.follows {
+		if f.followerID == followerID && f.userID == userID {
+			m.follows = append(m.follows[:i], m.follows[i+1:]...)
+			return false, nil
+		}
+	}
+
+	m.follows = append(m.follows, follow{followerID, userID})
+
+	return true, nil
+}
+
+func (m *FollowModel) Following(followerID, userID int) (bool, error) {
+	for _, f := range m.follows {
+		if f.followerID == followerID && f.userID == userID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *FollowModel) Counts(userID int) (int, int, error) {
+	var followers, following int
+	for _, f := range m.follows {
+		if f.userID == userID {
+			followers++
+		}
+		if f.followerID == userID {
+			following++
+		}
+	}
+
+	return followers, following, nil
+}
+
+func (m *FollowModel) Feed(userID, page, pageSize int) ([]*models.FeedItem, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+
+	items := []*models.FeedItem{}
+	if following, _ := m.Following(userID, mockSnippet.UserID); following {
+		items = append(items, &models.FeedItem{
+			Kind:         models.FeedSnippet,
+			UserID:       mockSnippet.UserID,
+			UserName:     "John",
+			SnippetID:    mockSnippet.ID,
+			SnippetTitle: mockSnippet.Title,
+			Created:      mockSnippet.Created,
+		})
+	}
+
+	total := len(items)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return items[start:end], total, nil
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+var mockIdentity = &models.UserIdentity{
+	ID:         1,
+	UserID:     1,
+	Provider:   "github",
+	ProviderID: "1001",
+	Created:    time.Now(),
+}
+
+type UserIdentityModel struct{}
+
+func (m *UserIdentityModel) Authenticate(provider, providerID string) (int, error) {
+	if provider == mockIdentity.Provider && providerID == mockIdentity.ProviderID {
+		return mockIdentity.UserID, nil
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+func (m *UserIdentityModel) Insert(userID int, provider, providerID string) error {
+	if provider == mockIdentity.Provider && (providerID == mockIdentity.ProviderID || userID == mockIdentity.UserID) {
+		return models.ErrIdentityTaken
+	}
+
+	return nil
+}
+
+func (m *UserIdentityModel) InsertUser(provider, providerID, name, email string, verified bool) (int, error) {
+	switch email {
+	case "jay@email.com":
+		return 0, models.ErrDuplicateEmail
+	default:
+		return 3, nil
+	}
+}
+
+func (m *UserIdentityModel) Delete(userID int, provider string) error {
+	if userID == mockIdentity.UserID && provider == mockIdentity.Provider {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserIdentityModel) ForUser(userID int) ([]*models.UserIdentity, error) {
+	if userID == mockIdentity.UserID {
+		return []*models.UserIdentity{mockIdentity}, nil
+	}
+
+	return []*models.UserIdentity{}, nil
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import "time"
+
+// LoginAttemptModel counts failed logins per email address in memory. Logins
+// with throttled@email.com always have to wait.
+type LoginAttemptModel struct {
+	failures map[string]int
+}
+
+func (m *LoginAttemptModel) Fail(email, ip string) (int, error) {
+	if m.failures == nil {
+		m.failures = make(map[string]int)
+	}
+	m.failures[email]++
+
+	return m.failures[email], nil
+}
+
+func (m *LoginAttemptModel) Clear(email string) error {
+	delete(m.failures, email)
+	return nil
+}
+
+func (m *LoginAttemptModel) Backoff(email, ip string) (time.Duration, error) {
+	if email == "throttled@email.com" {
+		return 30 * time.Second, nil
+	}
+
+	return 0, nil
+}
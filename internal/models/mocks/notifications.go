@@ -0,0 +1,96 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// NotificationModel keeps the notifications it is given in memory, so tests
+// can check who was notified.
+type NotificationModel struct {
+	Notifications []*models.Notification
+}
+
+func (m *NotificationModel) Insert(userID, actorID int, kind string, snippetID, commentID int) error {
+	if userID == 0 || userID == actorID {
+		return nil
+	}
+
+	n := &models.Notification{
+		ID:           len(m.Notifications) + 1,
+		UserID:       userID,
+		ActorID:      actorID,
+		Kind:         kind,
+		SnippetID:    snippetID,
+		SnippetTitle: mockSnippet.Title,
+		CommentID:    commentID,
+		Created:      time.Now(),
+	}
+	for _, usr := range mockUsers() {
+		if usr.ID == actorID {
+			n.ActorName = usr.Name
+		}
+	}
+
+	m.Notifications = append(m.Notifications, n)
+
+	return nil
+}
+
+func (m *NotificationModel) List(userID, page, pageSize int) ([]*models.Notification, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+
+	notifications := []*models.Notification{}
+	for i := len(m.Notifications) - 1; i >= 0; i-- {
+		if m.Notifications[i].UserID == userID {
+			notifications = append(notifications, m.Notifications[i])
+		}
+	}
+
+	total := len(notifications)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return notifications[start:end], total, nil
+}
+
+func (m *NotificationModel) Unread(userID int) (int, error) {
+	var count int
+	for _, n := range m.Notifications {
+		if n.UserID == userID && !n.Read {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	for _, n := range m.Notifications {
+		if n.ID == id && n.UserID == userID {
+			n.Read = true
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	for _, n := range m.Notifications {
+		if n.UserID == userID {
+			n.Read = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type PasswordResetModel struct{}
+
+func (m *PasswordResetModel) Create(email string, ttl time.Duration) (string, error) {
+	switch email {
+	case "jay@email.com":
+		return "valid-token", nil
+	default:
+		return "", models.ErrNoRecord
+	}
+}
+
+func (m *PasswordResetModel) Check(token string) error {
+	switch token {
+	case "valid-token":
+		return nil
+	default:
+		return models.ErrInvalidResetToken
+	}
+}
+
+func (m *PasswordResetModel) Reset(token, newPassword string) (int, error) {
+	err := m.Check(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
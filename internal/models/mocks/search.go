@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"strings"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+type SearchModel struct{}
+
+func (m *SearchModel) Search(query string, scope models.SearchScope, limit int) ([]*models.SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, models.ErrEmptySearch
+	}
+
+	results := []*models.SearchResult{}
+
+	if scope != models.SearchComments && matchesAny(mockSnippet.Content, query) {
+		results = append(results, &models.SearchResult{
+			Kind:      models.ResultSnippet,
+			SnippetID: mockSnippet.ID,
+			Title:     mockSnippet.Title,
+			Content:   mockSnippet.Content,
+			Created:   mockSnippet.Created,
+		})
+	}
+
+	if scope != models.SearchSnippets && matchesAny(mockComment.Content, query) {
+		results = append(results, &models.SearchResult{
+			Kind:      models.ResultComment,
+			SnippetID: mockComment.SnippetID,
+			CommentID: mockComment.ID,
+			Title:     mockSnippet.Title,
+			Content:   mockComment.Content,
+			Author:    mockComment.Author,
+			Created:   mockComment.Created,
+		})
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matchesAny reports whether text contains any word of query.
+func matchesAny(text, query string) bool {
+	for _, word := range strings.Fields(query) {
+		if strings.Contains(strings.ToLower(text), strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
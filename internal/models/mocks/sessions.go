@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"context"
+)
+
+// SessionModel reports a fixed number of active sessions.
+type SessionModel struct{}
+
+func (m *SessionModel) Active() (int, error) {
+	return 3, nil
+}
+
+func (m *SessionModel) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// mockShareLink opens up the private mockPrivateSnippet.
+var mockShareLink = &models.ShareLink{
+	ID:        1,
+	SnippetID: 4,
+	Token:     "valid-token",
+	Created:   time.Now(),
+	Expires:   time.Now().Add(24 * time.Hour),
+}
+
+var mockOwnShareLink = &models.ShareLink{
+	ID:        2,
+	SnippetID: 1,
+	Token:     "own-token",
+	Created:   time.Now(),
+	Expires:   time.Now().Add(time.Hour),
+}
+
+type ShareLinkModel struct{}
+
+func (m *ShareLinkModel) Create(snippetID int, ttl time.Duration) (*models.ShareLink, error) {
+	return &models.ShareLink{
+		ID:        3,
+		SnippetID: snippetID,
+		Token:     "new-token",
+		Created:   time.Now(),
+		Expires:   time.Now().Add(ttl),
+	}, nil
+}
+
+func (m *ShareLinkModel) Resolve(token string) (*models.ShareLink, error) {
+	for _, l := range []*models.ShareLink{mockShareLink, mockOwnShareLink} {
+		if l.Token == token {
+			return l, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *ShareLinkModel) GetBySnippet(snippetID int) ([]*models.ShareLink, error) {
+	switch snippetID {
+	case mockShareLink.SnippetID:
+		return []*models.ShareLink{mockShareLink}, nil
+	case mockOwnShareLink.SnippetID:
+		return []*models.ShareLink{mockOwnShareLink}, nil
+	default:
+		return []*models.ShareLink{}, nil
+	}
+}
+
+func (m *ShareLinkModel) Revoke(snippetID, id int) error {
+	for _, l := range []*models.ShareLink{mockShareLink, mockOwnShareLink} {
+		if l.SnippetID == snippetID && l.ID == id {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
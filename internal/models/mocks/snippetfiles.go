@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+// SnippetFileModel keeps the files it is given in memory. The mock snippet
+// starts with one extra file.
+type SnippetFileModel struct {
+	files map[int][]*models.SnippetFile
+}
+
+var mockSnippetFile = &models.SnippetFile{
+	ID:        1,
+	SnippetID: 1,
+	Name:      "frog.py",
+	Language:  "python",
+	Content:   "print('plop')",
+}
+
+func (m *SnippetFileModel) ForSnippet(snippetID int) ([]*models.SnippetFile, error) {
+	if m.files == nil {
+		m.files = map[int][]*models.SnippetFile{mockSnippet.ID: {mockSnippetFile}}
+	}
+
+	files := m.files[snippetID]
+	if files == nil {
+		files = []*models.SnippetFile{}
+	}
+
+	return files, nil
+}
+
+func (m *SnippetFileModel) Get(snippetID int, name string) (*models.SnippetFile, error) {
+	files, _ := m.ForSnippet(snippetID)
+	for _, f := range files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetFileModel) Replace(snippetID int, files []*models.SnippetFile) error {
+	m.ForSnippet(snippetID)
+
+	for i, f := range files {
+		f.ID = i + 1
+		f.SnippetID = snippetID
+	}
+	m.files[snippetID] = files
+
+	return nil
+}
@@ -7,16 +7,52 @@ import (
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Format:     models.FormatPlain,
+	Language:   "go",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
 }
 
+// mockPrivateSnippet belongs to a user other than the mock user 1.
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	UserID:     2,
+	Title:      "A private pond",
+	Content:    "A private pond...",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// mockRelatedSnippet is related to mockSnippet by language and author.
+var mockRelatedSnippet = &models.Snippet{
+	ID:         7,
+	UserID:     1,
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest...",
+	Format:     models.FormatPlain,
+	Language:   "go",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// mockExpiredSnippetID is the ID of a snippet that has expired.
+const mockExpiredSnippetID = 6
+
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(title, content string, expires int, userID int) (int, error) {
+	return 2, nil
+}
+
+func (m *SnippetModel) InsertWithOptions(title, content string, opts models.SnippetOptions, expires int, userID int) (int, error) {
 	return 2, nil
 }
 
@@ -24,11 +60,212 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 4:
+		return mockPrivateSnippet, nil
+	case mockExpiredSnippetID:
+		return nil, models.ErrExpired
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) LastModified(id int) (time.Time, error) {
+	switch id {
+	case 1:
+		return mockSnippet.Created, nil
+	case 4:
+		return mockPrivateSnippet.Created, nil
+	default:
+		return time.Time{}, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Related(id int, limit int) ([]*models.Snippet, error) {
+	if limit < 1 {
+		return nil, models.ErrInvalidPagination
+	}
+	if id != 1 {
+		return []*models.Snippet{}, nil
+	}
+	return []*models.Snippet{mockRelatedSnippet}, nil
+}
+
+func (m *SnippetModel) Latest(page, pageSize int) ([]*models.Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, models.ErrInvalidPagination
+	}
+	if page > 1 {
+		return []*models.Snippet{}, 1, nil
+	}
+	return []*models.Snippet{mockSnippet}, 1, nil
+}
+
+func (m *SnippetModel) LatestByLanguage(language string, page, pageSize int) ([]*models.Snippet, int, error) {
+	if language != "" && language != mockSnippet.Language {
+		if page < 1 || pageSize < 1 {
+			return nil, 0, models.ErrInvalidPagination
+		}
+		return []*models.Snippet{}, 0, nil
+	}
+	return m.Latest(page, pageSize)
+}
+
+func (m *SnippetModel) ByAuthor(userID int, page, pageSize int) ([]*models.Snippet, int, error) {
+	if userID != mockSnippet.UserID {
+		if page < 1 || pageSize < 1 {
+			return nil, 0, models.ErrInvalidPagination
+		}
+		return []*models.Snippet{}, 0, nil
+	}
+	return m.Latest(page, pageSize)
+}
+
+var mockRevision = &models.SnippetRevision{
+	ID:        1,
+	SnippetID: 1,
+	Title:     "An old pond",
+	Content:   "An old pond...",
+	Created:   time.Now(),
+}
+
+func (m *SnippetModel) SetExpires(id int, expires time.Time) error {
+	switch id {
+	case 1, 4:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Update(id int, title string, content string) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) GetRevisions(snippetID int) ([]*models.SnippetRevision, error) {
+	switch snippetID {
+	case 1:
+		return []*models.SnippetRevision{mockRevision}, nil
+	default:
+		return []*models.SnippetRevision{}, nil
+	}
+}
+
+func (m *SnippetModel) GetRevision(snippetID, revisionID int) (*models.SnippetRevision, error) {
+	if snippetID == mockRevision.SnippetID && revisionID == mockRevision.ID {
+		return mockRevision, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+var mockFork = &models.Snippet{
+	ID:         3,
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Format:     models.FormatPlain,
+	ForkedFrom: 1,
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+func (m *SnippetModel) Fork(id int, userID int, expires int) (int, error) {
+	switch id {
+	case 1:
+		return 3, nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Forks(id int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockFork}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(id int, deletedBy int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+// mockTrashedSnippet is in the trash of the mock user 1.
+var mockTrashedSnippet = &models.Snippet{
+	ID:         5,
+	UserID:     1,
+	Title:      "A deleted pond",
+	Content:    "Gone...",
+	Format:     models.FormatPlain,
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	DeletedAt:  time.Now(),
+}
+
+func (m *SnippetModel) Restore(id int) error {
+	if id != mockTrashedSnippet.ID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	if userID != mockTrashedSnippet.UserID {
+		return []*models.Snippet{}, nil
+	}
+	return []*models.Snippet{mockTrashedSnippet}, nil
+}
+
+func (m *SnippetModel) PurgeDeleted() (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) PurgeExpired() (models.PurgeResult, error) {
+	return models.PurgeResult{}, nil
+}
+
+func (m *SnippetModel) Upvote(snippetID, userID int) (models.VoteResult, error) {
+	return m.vote(snippetID, userID, "upvote")
+}
+
+func (m *SnippetModel) Downvote(snippetID, userID int) (models.VoteResult, error) {
+	return m.vote(snippetID, userID, "downvote")
+}
+
+func (m *SnippetModel) vote(snippetID, userID int, voteType string) (models.VoteResult, error) {
+	s, err := m.Get(snippetID)
+	if err != nil {
+		return models.VoteResult{}, err
+	}
+	if s.UserID == userID {
+		return models.VoteResult{}, models.ErrSelfVote
+	}
+	return models.VoteResult{Action: models.ActionAdded, Type: voteType, Score: 1}, nil
+}
+
+func (m *SnippetModel) Trending(page, pageSize int) ([]*models.Snippet, int, error) {
+	return m.Latest(page, pageSize)
+}
+
+func (m *SnippetModel) MostVoted(limit int) ([]*models.Snippet, error) {
+	if limit < 1 {
+		return []*models.Snippet{}, nil
+	}
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) Count() (int, error) {
+	return 1, nil
+}
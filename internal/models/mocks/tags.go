@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+var mockTag = &models.Tag{
+	ID:   1,
+	Name: "haiku",
+}
+
+type TagModel struct{}
+
+func (m *TagModel) AttachToSnippet(snippetID int, names []string) error {
+	return nil
+}
+
+func (m *TagModel) GetBySnippet(snippetID int) ([]*models.Tag, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Tag{mockTag}, nil
+	default:
+		return []*models.Tag{}, nil
+	}
+}
+
+func (m *TagModel) GetSnippetsByTag(name string) ([]*models.Snippet, error) {
+	switch name {
+	case mockTag.Name:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
@@ -0,0 +1,51 @@
+package mocks
+
+import "snippetbox.jmorelli.dev/internal/models"
+
+// User 5 has two-factor authentication enabled. Their app code is always
+// "123456" and "aaaa-bbbb" is one of their backup codes.
+type TwoFactorModel struct{}
+
+func (m *TwoFactorModel) Setup(userID int) (string, error) {
+	if userID == 5 {
+		return "", models.ErrTwoFactorEnabled
+	}
+	return "JBSWY3DPEHPK3PXP", nil
+}
+
+func (m *TwoFactorModel) Enable(userID int, code string) ([]string, error) {
+	if userID == 5 {
+		return nil, models.ErrTwoFactorEnabled
+	}
+	if code != "123456" {
+		return nil, models.ErrInvalidTOTP
+	}
+
+	codes := make([]string, models.BackupCodeCount)
+	for i := range codes {
+		codes[i] = "aaaa-bbbb"
+	}
+	return codes, nil
+}
+
+func (m *TwoFactorModel) Disable(userID int, code string) error {
+	return m.Verify(userID, code)
+}
+
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	return userID == 5, nil
+}
+
+func (m *TwoFactorModel) Verify(userID int, code string) error {
+	if userID == 5 && (code == "123456" || code == "aaaa-bbbb") {
+		return nil
+	}
+	return models.ErrInvalidTOTP
+}
+
+func (m *TwoFactorModel) BackupCodesLeft(userID int) (int, error) {
+	if userID == 5 {
+		return 9, nil
+	}
+	return 0, nil
+}
@@ -1,6 +1,11 @@
 package mocks
 
-import "snippetbox.jmorelli.dev/internal/models"
+import (
+	"strings"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
 
 type UserModel struct{}
 
@@ -17,26 +22,55 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	if email == "jay@email.com" && password == "12345678" {
 		return 1, nil
 	}
+	if email == "unverified@email.com" && password == "12345678" {
+		return 3, nil
+	}
+	if email == "twofactor@email.com" && password == "12345678" {
+		return 5, nil
+	}
+	if email == "mod@email.com" && password == "12345678" {
+		return 6, nil
+	}
+	if email == "admin@email.com" && password == "12345678" {
+		return 7, nil
+	}
+	if email == "banned@email.com" && password == "12345678" {
+		return 0, models.ErrBanned
+	}
+	if email == "locked@email.com" {
+		return 0, models.ErrAccountLocked
+	}
 
 	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 3, 5, 6, 7:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
 
+func mockUsers() []*models.User {
+	return []*models.User{
+		{ID: 1, Name: "John", Email: "jay@email.com", Role: models.RoleUser, Verified: true, Digest: true, Theme: models.ThemeLight},
+		{ID: 3, Name: "Una", Email: "unverified@email.com", Role: models.RoleUser, Theme: models.ThemeLight},
+		{ID: 5, Name: "Tess", Email: "twofactor@email.com", Role: models.RoleUser, Verified: true, Theme: models.ThemeLight},
+		{ID: 6, Name: "Mo", Email: "mod@email.com", Role: models.RoleModerator, Verified: true, Theme: models.ThemeDark},
+		{ID: 7, Name: "Ada", Email: "admin@email.com", Role: models.RoleAdmin, Verified: true, Digest: true, Theme: models.ThemeLight},
+		{ID: 8, Name: "Bo", Email: "banned@email.com", Role: models.RoleUser, Verified: true, Banned: true, Theme: models.ThemeLight},
+	}
+}
+
 func (m *UserModel) Get(id int) (*models.User, error) {
-	switch id {
-	case 1:
-		return &models.User{Name: "John"}, nil
-	default:
-		return nil, nil
+	for _, usr := range mockUsers() {
+		if usr.ID == id {
+			return usr, nil
+		}
 	}
+	return nil, models.ErrNoRecord
 }
 
 func (m *UserModel) UpdatePassword(id int, oldPassword, newPassword string) error {
@@ -45,3 +79,120 @@ func (m *UserModel) UpdatePassword(id int, oldPassword, newPassword string) erro
 	}
 	return models.ErrInvalidCredentials
 }
+
+func (m *UserModel) UpdateName(id int, name string) error {
+	if id != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *UserModel) UpdateEmail(id int, email, password string) error {
+	if password != "12345678" {
+		return models.ErrInvalidCredentials
+	}
+	if email == "dupe@example.com" {
+		return models.ErrDuplicateEmail
+	}
+	return nil
+}
+
+func (m *UserModel) VotesReceived(id int) (models.VoteTotals, error) {
+	switch id {
+	case 1:
+		return models.VoteTotals{Upvotes: 3, Downvotes: 1}, nil
+	default:
+		return models.VoteTotals{}, nil
+	}
+}
+
+func (m *UserModel) List(page, pageSize int) ([]*models.User, int, error) {
+	users := mockUsers()
+	return users, len(users), nil
+}
+
+func (m *UserModel) SetRole(id int, role string) error {
+	if !models.ValidRole(role) {
+		return models.ErrInvalidRole
+	}
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *UserModel) SetBanned(id int, banned bool) error {
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *UserModel) Count() (int, error) {
+	return len(mockUsers()), nil
+}
+
+func (m *UserModel) Recent(limit int) ([]*models.User, error) {
+	users := mockUsers()
+	if limit < 1 {
+		return []*models.User{}, nil
+	}
+	if limit < len(users) {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (m *UserModel) GetByNames(names []string) ([]*models.User, error) {
+	users := []*models.User{}
+	for _, usr := range mockUsers() {
+		for _, name := range names {
+			if strings.EqualFold(usr.Name, name) {
+				users = append(users, usr)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (m *UserModel) SetDigest(id int, enabled bool) error {
+	return nil
+}
+
+func (m *UserModel) SetTheme(id int, theme string) error {
+	if !models.ValidTheme(theme) {
+		return models.ErrInvalidTheme
+	}
+	return nil
+}
+
+func (m *UserModel) DigestsDue(interval time.Duration) ([]*models.User, error) {
+	users := []*models.User{}
+	for _, usr := range mockUsers() {
+		if usr.Digest && usr.Verified && !usr.Banned {
+			users = append(users, usr)
+		}
+	}
+	return users, nil
+}
+
+func (m *UserModel) DigestSent(id int) error {
+	return nil
+}
+
+func (m *UserModel) Lock(email string, d time.Duration) (string, error) {
+	for _, usr := range mockUsers() {
+		if usr.Email == email {
+			return "unlock-token", nil
+		}
+	}
+	return "", models.ErrNoRecord
+}
+
+func (m *UserModel) Unlock(token string) error {
+	if token == "unlock-token" {
+		return nil
+	}
+	return models.ErrInvalidUnlockToken
+}
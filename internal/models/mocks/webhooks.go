@@ -0,0 +1,92 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/models"
+)
+
+var mockWebhook = &models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://hooks.example.com/snippetbox",
+	Secret:  "mock-secret",
+	Events:  []string{models.EventCommentCreated, models.EventCommentVoted},
+	Created: time.Now(),
+}
+
+var mockDelivery = &models.WebhookDelivery{
+	ID:           1,
+	WebhookID:    1,
+	Event:        models.EventCommentCreated,
+	Payload:      `{"event": "comment.created"}`,
+	Status:       models.DeliveryFailed,
+	Attempts:     8,
+	ResponseCode: 500,
+	Error:        "500 Internal Server Error",
+	Created:      time.Now(),
+	NextAttempt:  time.Now(),
+	Finished:     time.Now(),
+}
+
+type WebhookModel struct{}
+
+func (m *WebhookModel) Insert(userID int, url string, events []string) (*models.Webhook, error) {
+	if len(events) == 0 {
+		return nil, models.ErrInvalidEvent
+	}
+
+	return &models.Webhook{ID: 2, UserID: userID, URL: url, Secret: "new-secret", Events: events, Created: time.Now()}, nil
+}
+
+func (m *WebhookModel) Get(id int) (*models.Webhook, error) {
+	if id == mockWebhook.ID {
+		return mockWebhook, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *WebhookModel) ForUser(userID int) ([]*models.Webhook, error) {
+	if userID == mockWebhook.UserID {
+		return []*models.Webhook{mockWebhook}, nil
+	}
+
+	return []*models.Webhook{}, nil
+}
+
+func (m *WebhookModel) Delete(userID, id int) error {
+	if userID == mockWebhook.UserID && id == mockWebhook.ID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Enqueue(event string, ownerID int, public bool, payload []byte) (int, error) {
+	return 0, nil
+}
+
+func (m *WebhookModel) Due(limit int) ([]*models.WebhookDelivery, error) {
+	return []*models.WebhookDelivery{}, nil
+}
+
+func (m *WebhookModel) Delivered(id int64, responseCode int) error {
+	return nil
+}
+
+func (m *WebhookModel) Failed(id int64, responseCode int, reason string, retryAt time.Time) error {
+	return nil
+}
+
+func (m *WebhookModel) Deliveries(webhookID int, limit int) ([]*models.WebhookDelivery, error) {
+	if webhookID == mockWebhook.ID {
+		return []*models.WebhookDelivery{mockDelivery}, nil
+	}
+
+	return []*models.WebhookDelivery{}, nil
+}
+
+func (m *WebhookModel) PurgeDeliveries() (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type NotificationModelInterface interface {
+	Insert(userID, actorID int, kind string, snippetID, commentID int) error
+	List(userID, page, pageSize int) ([]*Notification, int, error)
+	Unread(userID int) (int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) error
+}
+
+// Kinds of notification.
+const (
+	NotifySnippetComment = "snippet_comment"
+	NotifyCommentReply   = "comment_reply"
+	NotifyCommentMention = "comment_mention"
+	NotifySnippetUpvote  = "snippet_upvote"
+	NotifyCommentUpvote  = "comment_upvote"
+)
+
+// Notification tells a user that someone else did something to their content.
+// CommentID is 0 for notifications about a snippet only. ActorID is 0, and
+// ActorName empty, once the user who did it has been deleted.
+type Notification struct {
+	ID           int
+	UserID       int
+	ActorID      int
+	ActorName    string
+	Kind         string
+	SnippetID    int
+	SnippetTitle string
+	CommentID    int
+	Read         bool
+	Created      time.Time
+}
+
+// NotificationModel wraps a sql.DB conn pool. Notifications go away with the
+// user, snippet or comment they are about.
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Insert notifies userID that actorID did kind to the snippet, or to the
+// comment on it. Users are not notified of what they do themselves.
+func (m *NotificationModel) Insert(userID, actorID int, kind string, snippetID, commentID int) error {
+	if userID == 0 || userID == actorID {
+		return nil
+	}
+
+	stmt := `INSERT INTO notifications (user_id, actor_id, kind, snippet_id, comment_id, is_read, created)
+	VALUES(?, ?, ?, ?, ?, FALSE, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, nullInt(actorID), kind, snippetID, nullInt(commentID))
+
+	return err
+}
+
+// List returns a page of the user's notifications, newest first, and the total
+// number of them.
+func (m *NotificationModel) List(userID, page, pageSize int) ([]*Notification, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ?`, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT n.id, n.user_id, n.actor_id, u.name, n.kind, n.snippet_id, s.title, n.comment_id, n.is_read, n.created
+	FROM notifications n
+	INNER JOIN snippets s ON s.id = n.snippet_id
+	LEFT JOIN users u ON u.id = n.actor_id
+	WHERE n.user_id = ?
+	ORDER BY n.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		n := &Notification{}
+		var actorID, commentID sql.NullInt64
+		var actorName sql.NullString
+
+		err = rows.Scan(&n.ID, &n.UserID, &actorID, &actorName, &n.Kind, &n.SnippetID, &n.SnippetTitle, &commentID, &n.Read, &n.Created)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		n.ActorID = int(actorID.Int64)
+		n.ActorName = actorName.String
+		n.CommentID = int(commentID.Int64)
+		notifications = append(notifications, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// Unread returns how many of the user's notifications are unread.
+func (m *NotificationModel) Unread(userID int) (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = FALSE`, userID).Scan(&count)
+
+	return count, err
+}
+
+// MarkRead marks one of the user's notifications as read. It returns
+// ErrNoRecord if the user has no notification with that ID.
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM notifications WHERE id = ? AND user_id = ?)`, id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = m.DB.Exec(`UPDATE notifications SET is_read = TRUE WHERE id = ?`, id)
+
+	return err
+}
+
+// MarkAllRead marks all of the user's notifications as read.
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	_, err := m.DB.Exec(`UPDATE notifications SET is_read = TRUE WHERE user_id = ? AND is_read = FALSE`, userID)
+
+	return err
+}
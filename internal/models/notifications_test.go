@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestNotificationModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &NotificationModel{DB: db}
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+	('Bob', 'bob@example.com', 'x', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	snippets := &SnippetModel{DB: db}
+	snippetID, err := snippets.Insert("Hello", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Insert(1, 2, NotifySnippetComment, snippetID, 0))
+	assert.NilError(t, m.Insert(1, 2, NotifySnippetUpvote, snippetID, 0))
+	assert.NilError(t, m.Insert(2, 1, NotifySnippetUpvote, snippetID, 0))
+
+	// Users are not notified of what they do themselves
+	assert.NilError(t, m.Insert(1, 1, NotifySnippetUpvote, snippetID, 0))
+
+	unread, err := m.Unread(1)
+	assert.NilError(t, err)
+	assert.Equal(t, unread, 2)
+
+	notifications, total, err := m.List(1, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, notifications[0].Kind, NotifySnippetUpvote)
+	assert.Equal(t, notifications[0].ActorName, "Bob")
+	assert.Equal(t, notifications[0].SnippetTitle, "Hello")
+	assert.Equal(t, notifications[0].CommentID, 0)
+	assert.Equal(t, notifications[0].Read, false)
+
+	_, _, err = m.List(1, 0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+
+	// Users can only mark their own notifications
+	assert.Equal(t, m.MarkRead(2, notifications[0].ID), ErrNoRecord)
+	assert.NilError(t, m.MarkRead(1, notifications[0].ID))
+
+	unread, err = m.Unread(1)
+	assert.NilError(t, err)
+	assert.Equal(t, unread, 1)
+
+	assert.NilError(t, m.MarkAllRead(1))
+
+	unread, err = m.Unread(1)
+	assert.NilError(t, err)
+	assert.Equal(t, unread, 0)
+
+	unread, err = m.Unread(2)
+	assert.NilError(t, err)
+	assert.Equal(t, unread, 1)
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordResetModelInterface interface {
+	Create(email string, ttl time.Duration) (string, error)
+	Check(token string) error
+	Reset(token, newPassword string) (int, error)
+}
+
+// PasswordResetModel wraps a sql.DB conn pool. Only a SHA-256 hash of each
+// reset token is stored, so the table alone cannot be used to reset a
+// password.
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+// Create makes a reset token for the user with the given email address that
+// expires after ttl, and returns the token. It returns ErrNoRecord if no user
+// has that address.
+func (m *PasswordResetModel) Create(email string, ttl time.Duration) (string, error) {
+	var userID int
+
+	err := m.DB.QueryRow(`SELECT id FROM users WHERE email = ?`, email).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expires)
+	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err = m.DB.Exec(stmt, userID, hashToken(token), int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Check returns ErrInvalidResetToken unless token is an unexpired, unused
+// reset token.
+func (m *PasswordResetModel) Check(token string) error {
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM password_resets
+	WHERE token_hash = ? AND expires > UTC_TIMESTAMP())`
+
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrInvalidResetToken
+	}
+
+	return nil
+}
+
+// Reset sets a new password for the user a reset token was made for and
+// returns their ID. Using a token deletes it along with the user's other
+// reset tokens, so each can be used once. It returns ErrInvalidResetToken if
+// the token is unknown, used or expired.
+func (m *PasswordResetModel) Reset(token, newPassword string) (int, error) {
+	hashPass, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM password_resets
+	WHERE token_hash = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET hashed_password = ? WHERE id = ?`, hashPass, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM password_resets WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
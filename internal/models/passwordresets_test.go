@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestPasswordResetModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	users := &UserModel{db}
+	m := &PasswordResetModel{DB: db}
+
+	_, err := m.Create("nobody@example.com", time.Hour)
+	assert.Equal(t, err, ErrNoRecord)
+
+	token, err := m.Create("alice@example.com", time.Hour)
+	assert.NilError(t, err)
+	other, err := m.Create("alice@example.com", time.Hour)
+	assert.NilError(t, err)
+	expired, err := m.Create("alice@example.com", -time.Hour)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Check(token))
+	assert.Equal(t, m.Check(expired), ErrInvalidResetToken)
+	assert.Equal(t, m.Check("forged"), ErrInvalidResetToken)
+	_, err = m.Reset(expired, "newpassword")
+	assert.Equal(t, err, ErrInvalidResetToken)
+
+	userID, err := m.Reset(token, "newpassword")
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	id, err := users.Authenticate("alice@example.com", "newpassword")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	// Tokens are single use, and using one voids the user's others
+	_, err = m.Reset(token, "again12345")
+	assert.Equal(t, err, ErrInvalidResetToken)
+	assert.Equal(t, m.Check(other), ErrInvalidResetToken)
+}
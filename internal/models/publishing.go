@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+
+	"snippetbox.jmorelli.dev/internal/pubsub"
+)
+
+// Types of CommentEvent.
+const (
+	CommentEventCreated = "comment"
+	CommentEventVoted   = "vote"
+)
+
+// CommentEvent is published when a comment is added or its votes change.
+// Comment is the comment as it stands after the change.
+type CommentEvent struct {
+	Type    string
+	Comment *Comment
+}
+
+// CommentHub fans out CommentEvents by the ID of the comment's snippet.
+type CommentHub = pubsub.Hub[int, CommentEvent]
+
+// PublishingCommentModel wraps a CommentModelInterface and publishes a
+// CommentEvent to Hub, on the topic of the comment's snippet, for every comment
+// added by Insert or InsertReply or approved by Approve and for every vote by
+// Upvote or Downvote. Comments hidden or held by moderation, comments by
+// shadowbanned authors and votes by shadowbanned users are not published. Other
+// writes are passed on without publishing anything.
+type PublishingCommentModel struct {
+	CommentModelInterface
+	Hub *CommentHub
+}
+
+// NewPublishingCommentModel returns a PublishingCommentModel over inner.
+func NewPublishingCommentModel(inner CommentModelInterface, hub *CommentHub) *PublishingCommentModel {
+	return &PublishingCommentModel{CommentModelInterface: inner, Hub: hub}
+}
+
+// Insert passes the insert on and publishes the new comment.
+func (m *PublishingCommentModel) Insert(snippetID int, authorID int, author string, content string) (int, error) {
+	return m.InsertContext(context.Background(), snippetID, authorID, author, content)
+}
+
+// InsertContext is like Insert, but uses ctx for the database queries.
+func (m *PublishingCommentModel) InsertContext(ctx context.Context, snippetID int, authorID int, author string, content string) (int, error) {
+	id, err := m.CommentModelInterface.InsertContext(ctx, snippetID, authorID, author, content)
+	if err != nil {
+		return 0, err
+	}
+
+	m.publish(ctx, CommentEventCreated, id, authorID)
+	return id, nil
+}
+
+// InsertReply passes the insert on and publishes the new reply.
+func (m *PublishingCommentModel) InsertReply(snippetID, parentID, authorID int, author string, content string) (int, error) {
+	return m.InsertReplyContext(context.Background(), snippetID, parentID, authorID, author, content)
+}
+
+// InsertReplyContext is like InsertReply, but uses ctx for the database queries.
+func (m *PublishingCommentModel) InsertReplyContext(ctx context.Context, snippetID, parentID, authorID int, author string, content string) (int, error) {
+	id, err := m.CommentModelInterface.InsertReplyContext(ctx, snippetID, parentID, authorID, author, content)
+	if err != nil {
+		return 0, err
+	}
+
+	m.publish(ctx, CommentEventCreated, id, authorID)
+	return id, nil
+}
+
+// Upvote passes the vote on and publishes the comment with its new votes.
+func (m *PublishingCommentModel) Upvote(commentID, userID int) (VoteResult, error) {
+	return m.UpvoteContext(context.Background(), commentID, userID)
+}
+
+// UpvoteContext is like Upvote, but uses ctx for the database queries.
+func (m *PublishingCommentModel) UpvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error) {
+	result, err := m.CommentModelInterface.UpvoteContext(ctx, commentID, userID)
+	if err != nil {
+		return result, err
+	}
+
+	m.publish(ctx, CommentEventVoted, commentID, userID)
+	return result, nil
+}
+
+// Downvote passes the vote on and publishes the comment with its new votes.
+func (m *PublishingCommentModel) Downvote(commentID, userID int) (VoteResult, error) {
+	return m.DownvoteContext(context.Background(), commentID, userID)
+}
+
+// DownvoteContext is like Downvote, but uses ctx for the database queries.
+func (m *PublishingCommentModel) DownvoteContext(ctx context.Context, commentID, userID int) (VoteResult, error) {
+	result, err := m.CommentModelInterface.DownvoteContext(ctx, commentID, userID)
+	if err != nil {
+		return result, err
+	}
+
+	m.publish(ctx, CommentEventVoted, commentID, userID)
+	return result, nil
+}
+
+// Approve passes the approval on and publishes the comment, which only now
+// shows up for everyone.
+func (m *PublishingCommentModel) Approve(commentID int) error {
+	return m.ApproveContext(context.Background(), commentID)
+}
+
+// ApproveContext is like Approve, but uses ctx for the database queries.
+func (m *PublishingCommentModel) ApproveContext(ctx context.Context, commentID int) error {
+	err := m.CommentModelInterface.ApproveContext(ctx, commentID)
+	if err != nil {
+		return err
+	}
+
+	m.publish(ctx, CommentEventCreated, commentID, 0)
+	return nil
+}
+
+// publish reads the comment and publishes an event with it, caused by the user
+// actorID (0 when there is none). The write has already been made, so a failed
+// read only skips the event.
+func (m *PublishingCommentModel) publish(ctx context.Context, eventType string, commentID, actorID int) {
+	c, err := m.CommentModelInterface.GetContext(ctx, commentID)
+	if err != nil || c.Hidden() || c.Held() {
+		return
+	}
+
+	for _, userID := range []int{c.AuthorID, actorID} {
+		if userID == 0 {
+			continue
+		}
+		banned, err := m.CommentModelInterface.ShadowbannedContext(ctx, userID)
+		if err != nil || banned {
+			return
+		}
+	}
+
+	m.Hub.Publish(c.SnippetID, CommentEvent{Type: eventType, Comment: c})
+}
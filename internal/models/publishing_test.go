@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/pubsub"
+)
+
+func TestPublishingCommentModel(t *testing.T) {
+	inner := &countingComments{comments: map[int]*Comment{
+		1: {ID: 1, SnippetID: 1, Status: StatusRejected},
+	}}
+	hub := pubsub.New[int, CommentEvent]()
+	m := NewPublishingCommentModel(inner, hub)
+
+	events, cancel := hub.Subscribe(1, 10)
+	defer cancel()
+
+	id, err := m.Insert(1, 2, "Bob", "Hello")
+	assert.NilError(t, err)
+
+	e := <-events
+	assert.Equal(t, e.Type, CommentEventCreated)
+	assert.Equal(t, e.Comment.ID, id)
+	assert.Equal(t, e.Comment.Content, "Hello")
+
+	_, err = m.Upvote(id, 3)
+	assert.NilError(t, err)
+
+	e = <-events
+	assert.Equal(t, e.Type, CommentEventVoted)
+	assert.Equal(t, e.Comment.Upvotes, 1)
+
+	// Other snippets' comments and hidden comments are not published
+	_, err = m.Insert(2, 2, "Bob", "Elsewhere")
+	assert.NilError(t, err)
+	_, err = m.Upvote(1, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	// Held comments are published once approved
+	assert.NilError(t, m.HoldContext(context.Background(), id, "Spam"))
+	_, err = m.Upvote(id, 4)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	assert.NilError(t, m.Approve(id))
+
+	e = <-events
+	assert.Equal(t, e.Type, CommentEventCreated)
+	assert.Equal(t, e.Comment.ID, id)
+}
+
+func TestPublishingCommentModelShadowban(t *testing.T) {
+	inner := &countingComments{comments: map[int]*Comment{}, shadowbanned: map[int]bool{3: true}}
+	hub := pubsub.New[int, CommentEvent]()
+	m := NewPublishingCommentModel(inner, hub)
+
+	events, cancel := hub.Subscribe(1, 10)
+	defer cancel()
+
+	// Neither the comments of a shadowbanned author nor the votes on them
+	// are published
+	banned, err := m.Insert(1, 3, "Mallory", "Buy cheap watches")
+	assert.NilError(t, err)
+	_, err = m.Upvote(banned, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	// Nor are the votes of a shadowbanned user
+	id, err := m.Insert(1, 2, "Bob", "Hello")
+	assert.NilError(t, err)
+
+	e := <-events
+	assert.Equal(t, e.Comment.ID, id)
+
+	_, err = m.Upvote(id, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	_, err = m.Upvote(id, 4)
+	assert.NilError(t, err)
+
+	e = <-events
+	assert.Equal(t, e.Type, CommentEventVoted)
+	assert.Equal(t, e.Comment.Upvotes, 2)
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SearchScope limits a search to snippets, comments or both.
+type SearchScope string
+
+const (
+	SearchAll      SearchScope = ""
+	SearchSnippets SearchScope = "snippets"
+	SearchComments SearchScope = "comments"
+)
+
+// Kinds of search results.
+const (
+	ResultSnippet = "snippet"
+	ResultComment = "comment"
+)
+
+type SearchModelInterface interface {
+	Search(query string, scope SearchScope, limit int) ([]*SearchResult, error)
+}
+
+// SearchResult is a snippet or comment matched by a search. For comments,
+// Title is the title of the snippet the comment belongs to.
+type SearchResult struct {
+	Kind      string
+	SnippetID int
+	CommentID int
+	Title     string
+	Content   string
+	Author    string
+	Created   time.Time
+	Relevance float64
+}
+
+// SearchModel wraps a sql.DB conn pool.
+type SearchModel struct {
+	DB *sql.DB
+}
+
+// Search runs a natural language full-text search over snippet titles and
+// contents and comment contents, returning up to limit results ordered by
+// relevance. Expired and non-public snippets, their comments, and comments
+// that were deleted or rejected are left out. A blank query returns ErrEmptySearch.
+func (m *SearchModel) Search(query string, scope SearchScope, limit int) ([]*SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearch
+	}
+
+	results := []*SearchResult{}
+	if limit < 1 {
+		return results, nil
+	}
+
+	snippetStmt := `SELECT 'snippet', s.id, 0, s.title, s.content, '', s.created AS created,
+	                MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS relevance
+	                FROM snippets s
+	                WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public'
+	                AND MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)`
+
+	commentStmt := `SELECT 'comment', s.id, c.id, s.title, c.content, c.author, c.created AS created,
+	                MATCH(c.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS relevance
+	                FROM comments c
+	                JOIN snippets s ON s.id = c.snippet_id
+	                WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public' AND c.deleted = FALSE AND c.status <> 'rejected'
+	                AND MATCH(c.content) AGAINST(? IN NATURAL LANGUAGE MODE)`
+
+	var stmt string
+	var args []any
+
+	switch scope {
+	case SearchSnippets:
+		stmt = snippetStmt
+		args = []any{query, query}
+	case SearchComments:
+		stmt = commentStmt
+		args = []any{query, query}
+	default:
+		stmt = snippetStmt + ` UNION ALL ` + commentStmt
+		args = []any{query, query, query, query}
+	}
+
+	stmt += ` ORDER BY relevance DESC, created DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &SearchResult{}
+
+		err = rows.Scan(&r.Kind, &r.SnippetID, &r.CommentID, &r.Title, &r.Content, &r.Author, &r.Created, &r.Relevance)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestSearchModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	comments := &CommentModel{DB: db}
+	m := &SearchModel{DB: db}
+
+	snippetID, err := snippets.Insert("Autumn haiku", "Leaves fall on the quiet garden", 7, 1)
+	assert.NilError(t, err)
+	commentID, err := comments.Insert(snippetID, 1, "Alice", "The garden imagery is lovely")
+	assert.NilError(t, err)
+	deletedID, err := comments.Insert(snippetID, 1, "Alice", "Another garden remark")
+	assert.NilError(t, err)
+	assert.NilError(t, comments.Delete(deletedID, 1))
+
+	tests := []struct {
+		name  string
+		scope SearchScope
+		want  []int
+		kinds []string
+	}{
+		{name: "Everything", scope: SearchAll, want: []int{snippetID, snippetID}},
+		{name: "Snippets only", scope: SearchSnippets, want: []int{snippetID}, kinds: []string{ResultSnippet}},
+		{name: "Comments only", scope: SearchComments, want: []int{snippetID}, kinds: []string{ResultComment}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := m.Search("garden", tt.scope, 10)
+			assert.NilError(t, err)
+			assert.Equal(t, len(results), len(tt.want))
+
+			for i, r := range results {
+				assert.Equal(t, r.SnippetID, tt.want[i])
+				if tt.kinds != nil {
+					assert.Equal(t, r.Kind, tt.kinds[i])
+				}
+				if r.Kind == ResultComment {
+					assert.Equal(t, r.CommentID, commentID)
+				}
+			}
+		})
+	}
+
+	_, err = m.Search("  ", SearchAll, 10)
+	assert.Equal(t, err, ErrEmptySearch)
+}
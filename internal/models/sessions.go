@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type SessionModelInterface interface {
+	Active() (int, error)
+	Ping(ctx context.Context) error
+}
+
+// SessionModel wraps a sql.DB conn pool. The sessions table belongs to the
+// session manager's store; this model only reads it.
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// Active returns the number of sessions that have not expired.
+func (m *SessionModel) Active() (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE expiry > UTC_TIMESTAMP(6)`).Scan(&count)
+
+	return count, err
+}
+
+// Ping checks that the sessions table can be read.
+func (m *SessionModel) Ping(ctx context.Context) error {
+	var one int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT 1 FROM sessions LIMIT 1`).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
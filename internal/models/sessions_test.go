@@ -0,0 +1,28 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestSessionModelActive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &SessionModel{DB: db}
+
+	assert.NilError(t, m.Ping(context.Background()))
+
+	_, err := db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES
+	('live', '', DATE_ADD(UTC_TIMESTAMP(6), INTERVAL 1 HOUR)),
+	('dead', '', DATE_SUB(UTC_TIMESTAMP(6), INTERVAL 1 HOUR))`)
+	assert.NilError(t, err)
+
+	count, err := m.Active()
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+}
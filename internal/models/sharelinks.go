@@ -0,0 +1,159 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+type ShareLinkModelInterface interface {
+	Create(snippetID int, ttl time.Duration) (*ShareLink, error)
+	Resolve(token string) (*ShareLink, error)
+	GetBySnippet(snippetID int) ([]*ShareLink, error)
+	Revoke(snippetID, id int) error
+}
+
+// ShareLink grants read-only access to a snippet, whatever its visibility,
+// to anyone holding Token until Expires.
+type ShareLink struct {
+	ID        int
+	SnippetID int
+	Token     string
+	Created   time.Time
+	Expires   time.Time
+}
+
+// ShareLinkModel wraps a sql.DB conn pool. Tokens are a random nonce followed
+// by an HMAC of the nonce under Secret. Only the nonce is stored, so forged
+// tokens are rejected without a query and the table alone cannot be used to
+// build working links.
+type ShareLinkModel struct {
+	DB     *sql.DB
+	Secret []byte
+}
+
+// Create makes a new share link for a snippet that expires after ttl.
+func (m *ShareLinkModel) Create(snippetID int, ttl time.Duration) (*ShareLink, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO share_links (snippet_id, nonce, created, expires)
+	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	result, err := m.DB.Exec(stmt, snippetID, nonce, int(ttl.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.get(int(id))
+}
+
+// Resolve returns the unexpired share link for a token. It returns
+// ErrNoRecord if the token is malformed, forged, expired or revoked.
+func (m *ShareLinkModel) Resolve(token string) (*ShareLink, error) {
+	nonce, _, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(token), []byte(m.token(nonce))) {
+		return nil, ErrNoRecord
+	}
+
+	stmt := `SELECT id, snippet_id, nonce, created, expires FROM share_links
+	WHERE nonce = ? AND expires > UTC_TIMESTAMP()`
+
+	return m.scan(m.DB.QueryRow(stmt, nonce))
+}
+
+// GetBySnippet returns the unexpired share links of a snippet, most recent
+// first.
+func (m *ShareLinkModel) GetBySnippet(snippetID int) ([]*ShareLink, error) {
+	stmt := `SELECT id, snippet_id, nonce, created, expires FROM share_links
+	WHERE snippet_id = ? AND expires > UTC_TIMESTAMP() ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*ShareLink{}
+
+	for rows.Next() {
+		l, err := m.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// Revoke deletes a share link of a snippet. It returns ErrNoRecord if the
+// snippet has no such link.
+func (m *ShareLinkModel) Revoke(snippetID, id int) error {
+	result, err := m.DB.Exec(`DELETE FROM share_links WHERE id = ? AND snippet_id = ?`, id, snippetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *ShareLinkModel) get(id int) (*ShareLink, error) {
+	stmt := `SELECT id, snippet_id, nonce, created, expires FROM share_links WHERE id = ?`
+
+	return m.scan(m.DB.QueryRow(stmt, id))
+}
+
+// scan reads a share link row and signs its nonce into a token.
+func (m *ShareLinkModel) scan(row rowScanner) (*ShareLink, error) {
+	l := &ShareLink{}
+
+	var nonce string
+
+	err := row.Scan(&l.ID, &l.SnippetID, &nonce, &l.Created, &l.Expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	l.Token = m.token(nonce)
+
+	return l, nil
+}
+
+// token signs a nonce with the model's secret.
+func (m *ShareLinkModel) token(nonce string) string {
+	mac := hmac.New(sha256.New, m.Secret)
+	mac.Write([]byte(nonce))
+	return nonce + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestShareLinkModelResolveForged(t *testing.T) {
+	// Forged tokens are rejected before the database is queried.
+	m := &ShareLinkModel{Secret: []byte("secret")}
+	other := &ShareLinkModel{Secret: []byte("other")}
+
+	for _, token := range []string{"", "nonce", "nonce.", other.token("nonce")} {
+		_, err := m.Resolve(token)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	}
+}
+
+func TestShareLinkModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	m := &ShareLinkModel{DB: db, Secret: []byte("secret")}
+
+	id, err := snippets.Insert("Shared", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	link, err := m.Create(id, time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, link.SnippetID, id)
+
+	resolved, err := m.Resolve(link.Token)
+	assert.NilError(t, err)
+	assert.Equal(t, resolved.ID, link.ID)
+
+	expired, err := m.Create(id, -time.Hour)
+	assert.NilError(t, err)
+	_, err = m.Resolve(expired.Token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	links, err := m.GetBySnippet(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(links), 1)
+
+	assert.NilError(t, m.Revoke(id, link.ID))
+	assert.Equal(t, errors.Is(m.Revoke(id, link.ID), ErrNoRecord), true)
+
+	_, err = m.Resolve(link.Token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
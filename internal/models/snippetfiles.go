@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Limits on the extra files of a snippet.
+const (
+	MaxSnippetFiles   = 10
+	MaxFileNameLength = 100
+)
+
+type SnippetFileModelInterface interface {
+	ForSnippet(snippetID int) ([]*SnippetFile, error)
+	Get(snippetID int, name string) (*SnippetFile, error)
+	Replace(snippetID int, files []*SnippetFile) error
+}
+
+// SnippetFile is a named file of a snippet, like the files of a Gist. The
+// snippet's own content is its first file; SnippetFile holds the others, in
+// the order they are shown.
+type SnippetFile struct {
+	ID        int
+	SnippetID int
+	Name      string
+	Language  string
+	Content   string
+}
+
+// SnippetFileModel wraps a sql.DB conn pool. Files go away with their
+// snippet.
+type SnippetFileModel struct {
+	DB *sql.DB
+}
+
+// ForSnippet returns the extra files of a snippet in order.
+func (m *SnippetFileModel) ForSnippet(snippetID int) ([]*SnippetFile, error) {
+	stmt := `SELECT id, snippet_id, name, language, content FROM snippet_files
+	WHERE snippet_id = ? ORDER BY position`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*SnippetFile{}
+
+	for rows.Next() {
+		f := &SnippetFile{}
+
+		err = rows.Scan(&f.ID, &f.SnippetID, &f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Get returns the file of a snippet with the given name, or ErrNoRecord.
+func (m *SnippetFileModel) Get(snippetID int, name string) (*SnippetFile, error) {
+	stmt := `SELECT id, snippet_id, name, language, content FROM snippet_files
+	WHERE snippet_id = ? AND name = ?`
+
+	f := &SnippetFile{}
+
+	err := m.DB.QueryRow(stmt, snippetID, name).Scan(&f.ID, &f.SnippetID, &f.Name, &f.Language, &f.Content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Replace sets the extra files of a snippet, in order, in place of the ones
+// it had. Names must be unique within the snippet.
+func (m *SnippetFileModel) Replace(snippetID int, files []*SnippetFile) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range files {
+		_, err = tx.Exec(`INSERT INTO snippet_files (snippet_id, position, name, language, content)
+		VALUES (?, ?, ?, ?, ?)`, snippetID, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
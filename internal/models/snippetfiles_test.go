@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestSnippetFileModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := &SnippetFileModel{DB: db}
+
+	snippets := &SnippetModel{DB: db}
+	snippetID, err := snippets.Insert("Hello", "package main", 7, 1)
+	assert.NilError(t, err)
+
+	files, err := m.ForSnippet(snippetID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 0)
+
+	err = m.Replace(snippetID, []*SnippetFile{
+		{Name: "query.sql", Language: "sql", Content: "SELECT 1"},
+		{Name: "README", Content: "Read me"},
+	})
+	assert.NilError(t, err)
+
+	files, err = m.ForSnippet(snippetID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+	assert.Equal(t, files[0].Name, "query.sql")
+	assert.Equal(t, files[1].Name, "README")
+
+	f, err := m.Get(snippetID, "README")
+	assert.NilError(t, err)
+	assert.Equal(t, f.Content, "Read me")
+
+	_, err = m.Get(snippetID, "missing")
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Forks get copies of the files
+	forkID, err := snippets.Fork(snippetID, 1, 7)
+	assert.NilError(t, err)
+
+	files, err = m.ForSnippet(forkID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+
+	err = m.Replace(snippetID, []*SnippetFile{{Name: "main.go", Language: "go", Content: "package main"}})
+	assert.NilError(t, err)
+
+	files, err = m.ForSnippet(snippetID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, files[0].Name, "main.go")
+}
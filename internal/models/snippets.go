@@ -7,18 +7,144 @@ import (
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(title string, content string, expires int, userID int) (int, error)
+	InsertWithOptions(title string, content string, opts SnippetOptions, expires int, userID int) (int, error)
 	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Latest(page, pageSize int) ([]*Snippet, int, error)
+	LatestByLanguage(language string, page, pageSize int) ([]*Snippet, int, error)
+	ByAuthor(userID int, page, pageSize int) ([]*Snippet, int, error)
+	Update(id int, title string, content string) error
+	SetExpires(id int, expires time.Time) error
+	GetRevisions(snippetID int) ([]*SnippetRevision, error)
+	GetRevision(snippetID, revisionID int) (*SnippetRevision, error)
+	LastModified(id int) (time.Time, error)
+	Delete(id int, deletedBy int) error
+	Restore(id int) error
+	Trash(userID int) ([]*Snippet, error)
+	PurgeDeleted() (int, error)
+	PurgeExpired() (PurgeResult, error)
+	Fork(id int, userID int, expires int) (int, error)
+	Forks(id int) ([]*Snippet, error)
+	Related(id int, limit int) ([]*Snippet, error)
+	Upvote(snippetID, userID int) (VoteResult, error)
+	Downvote(snippetID, userID int) (VoteResult, error)
+	Trending(page, pageSize int) ([]*Snippet, int, error)
+	MostVoted(limit int) ([]*Snippet, error)
+	Count() (int, error)
 }
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID             int
+	UserID         int
+	Title          string
+	Content        string
+	Format         string
+	Language       string
+	ForkedFrom     int
+	Visibility     string
+	Upvotes        int
+	Downvotes      int
+	Stars          int
+	Created        time.Time
+	Expires        time.Time
 	CommentsNumber int
+	// DeletedAt is only set on snippets returned by Trash.
+	DeletedAt time.Time
+}
+
+// NeverExpires is the expiry of snippets that are kept until deleted: the
+// latest time a MySQL DATETIME can hold.
+var NeverExpires = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// NeverExpires reports whether the snippet is kept until deleted.
+func (s *Snippet) NeverExpires() bool {
+	return !s.Expires.Before(NeverExpires)
+}
+
+// Score returns the snippet's upvotes minus its downvotes.
+func (s *Snippet) Score() int {
+	return s.Upvotes - s.Downvotes
+}
+
+// SnippetRevision is an earlier version of a snippet, saved when the snippet
+// was edited. Created is when that version was replaced.
+type SnippetRevision struct {
+	ID        int
+	SnippetID int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// Snippet visibilities. Unlisted snippets are left out of the home page,
+// tag pages and search but can be opened by anyone with the URL; private
+// snippets can only be opened by their author.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// VisibleTo reports whether the user with the given ID, or 0 for an
+// anonymous visitor, may open the snippet.
+func (s *Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || (userID != 0 && userID == s.UserID)
+}
+
+// SnippetOptions holds the optional settings of a new snippet. The zero value
+// is a public plain text snippet. Expires, when set, is used instead of the
+// number of days the snippet is kept for.
+type SnippetOptions struct {
+	Format     string
+	Language   string
+	Visibility string
+	Expires    time.Time
+}
+
+// snippetColumns lists the columns read by scanSnippet, for queries on the
+// snippets table aliased as s.
+const snippetColumns = `s.id, COALESCE(s.user_id, 0), s.title, s.content, s.format, s.language,
+	COALESCE(s.forked_from, 0), s.visibility, s.upvotes, s.downvotes,
+	(SELECT COUNT(*) FROM favorites WHERE snippet_id = s.id), s.created, s.expires`
+
+// scanSnippet reads a row selected with snippetColumns, followed by the
+// columns read into extra.
+func scanSnippet(row rowScanner, extra ...any) (*Snippet, error) {
+	s := &Snippet{}
+	dest := []any{&s.ID, &s.UserID, &s.Title, &s.Content, &s.Format, &s.Language,
+		&s.ForkedFrom, &s.Visibility, &s.Upvotes, &s.Downvotes, &s.Stars, &s.Created, &s.Expires}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// querySnippets runs a query selecting snippetColumns and returns the
+// snippets it finds.
+func querySnippets(db *sql.DB, stmt string, args ...any) ([]*Snippet, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
 }
 
 // SnippetModel wraps a sql.DB conn pool.
@@ -26,12 +152,41 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-// Insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires) 
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// Snippet content formats. Plain snippets are shown as preformatted text;
+// Markdown snippets are rendered to HTML.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// Insert a new plain text snippet into the database.
+func (m *SnippetModel) Insert(title string, content string, expires int, userID int) (int, error) {
+	return m.InsertWithOptions(title, content, SnippetOptions{}, expires, userID)
+}
+
+// InsertWithOptions inserts a new snippet with the given format, language and
+// visibility. An empty format or visibility means FormatPlain or
+// VisibilityPublic, and an empty language means plain text. The content is
+// stored as written.
+func (m *SnippetModel) InsertWithOptions(title string, content string, opts SnippetOptions, expires int, userID int) (int, error) {
+	if opts.Format == "" {
+		opts.Format = FormatPlain
+	}
+	if opts.Visibility == "" {
+		opts.Visibility = VisibilityPublic
+	}
+
+	stmt := `INSERT INTO snippets (user_id, title, content, format, language, visibility, created, expires) 
+	VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	args := []any{userID, title, content, opts.Format, opts.Language, opts.Visibility, expires}
+
+	if !opts.Expires.IsZero() {
+		stmt = `INSERT INTO snippets (user_id, title, content, format, language, visibility, created, expires) 
+		VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+		args[len(args)-1] = opts.Expires.UTC()
+	}
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := m.DB.Exec(stmt, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -44,14 +199,15 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	return int(id), nil
 }
 
-// Get a specific snippet.
+// Get a specific snippet. It returns ErrExpired for a snippet that has
+// expired but has not been purged yet.
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + `, s.expires <= UTC_TIMESTAMP() FROM snippets s
+    WHERE s.deleted_at IS NULL AND s.id = ?`
 
-	s := &Snippet{}
+	var expired bool
 
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id), &expired)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -60,15 +216,272 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	if expired {
+		return nil, ErrExpired
+	}
+
 	return s, nil
 }
 
-// Latest return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// SetExpires changes when an unexpired snippet expires, for its author to
+// keep it longer or less long than first chosen.
+func (m *SnippetModel) SetExpires(id int, expires time.Time) error {
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = m.DB.Exec(`UPDATE snippets SET expires = ? WHERE id = ?`, expires.UTC(), id)
+
+	return err
+}
+
+// DefaultSnippetPageSize is the number of snippets per page on the home page.
+const DefaultSnippetPageSize = 10
 
-	rows, err := m.DB.Query(stmt)
+// Latest returns a page of unexpired public snippets, most recent first, along
+// with the total number of them. Pages start at 1; a page or
+// pageSize below 1 returns ErrInvalidPagination.
+func (m *SnippetModel) Latest(page, pageSize int) ([]*Snippet, int, error) {
+	return m.LatestByLanguage("", page, pageSize)
+}
+
+// LatestByLanguage is like Latest, but only returns snippets written in the
+// given language. An empty language returns snippets in any language.
+func (m *SnippetModel) LatestByLanguage(language string, page, pageSize int) ([]*Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL
+	AND visibility = 'public' AND (? = '' OR language = ?)`, language, language).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public' AND (? = '' OR s.language = ?)
+	ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := querySnippets(m.DB, stmt, language, language, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// ByAuthor returns a page of a user's unexpired public snippets, most recent
+// first, along with the total number of them. Pages start at 1; a page or
+// pageSize below 1 returns ErrInvalidPagination.
+func (m *SnippetModel) ByAuthor(userID int, page, pageSize int) ([]*Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL
+	AND visibility = 'public' AND user_id = ?`, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public' AND s.user_id = ?
+	ORDER BY s.id DESC LIMIT ? OFFSET ?`
+
+	snippets, err := querySnippets(m.DB, stmt, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// Update changes the title and content of a snippet, first saving the
+// current version as a revision. An update that changes nothing saves no
+// revision. It returns ErrNoRecord if the snippet does not exist.
+func (m *SnippetModel) Update(id int, title string, content string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent string
+
+	err = tx.QueryRow(`SELECT title, content FROM snippets WHERE id = ? AND deleted_at IS NULL FOR UPDATE`, id).Scan(&oldTitle, &oldContent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	if oldTitle == title && oldContent == content {
+		return tx.Commit()
+	}
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, title, content, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(stmt, id, oldTitle, oldContent)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET title = ?, content = ? WHERE id = ?`, title, content, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRevisions returns the saved revisions of a snippet, most recent first.
+func (m *SnippetModel) GetRevisions(snippetID int) ([]*SnippetRevision, error) {
+	stmt := `SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*SnippetRevision{}
+
+	for rows.Next() {
+		r := &SnippetRevision{}
+
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a snippet. It returns ErrNoRecord if
+// the revision does not exist or belongs to another snippet.
+func (m *SnippetModel) GetRevision(snippetID, revisionID int) (*SnippetRevision, error) {
+	stmt := `SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? AND id = ?`
+
+	r := &SnippetRevision{}
+
+	err := m.DB.QueryRow(stmt, snippetID, revisionID).Scan(&r.ID, &r.SnippetID, &r.Title, &r.Content, &r.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// LastModified returns when a snippet or its comments last changed: the
+// latest of when it was created, when it was last edited, and when one of its
+// comments was last posted or edited. It returns ErrNoRecord if the snippet
+// does not exist or is deleted.
+func (m *SnippetModel) LastModified(id int) (time.Time, error) {
+	stmt := `SELECT GREATEST(s.created,
+	           COALESCE((SELECT MAX(r.created) FROM snippet_revisions r WHERE r.snippet_id = s.id), s.created),
+	           COALESCE((SELECT MAX(GREATEST(c.created, COALESCE(c.updated, c.created)))
+	                     FROM comments c WHERE c.snippet_id = s.id), s.created))
+	         FROM snippets s WHERE s.id = ? AND s.deleted_at IS NULL`
+
+	var modified time.Time
+
+	err := m.DB.QueryRow(stmt, id).Scan(&modified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrNoRecord
+		}
+		return time.Time{}, err
+	}
+
+	return modified, nil
+}
+
+// TrashRetention is how long deleted snippets and comments can still be
+// restored from their author's trash before PurgeDeleted removes them.
+const TrashRetention = 30 * 24 * time.Hour
+
+// Delete moves a snippet to the trash, recording the ID of the user who
+// deleted it. Only snippets their author deleted show up in the author's
+// trash. It returns ErrNoRecord if the snippet does not exist or is already
+// deleted.
+func (m *SnippetModel) Delete(id int, deletedBy int) error {
+	stmt := `UPDATE snippets SET deleted_at = UTC_TIMESTAMP(), deleted_by = ?
+	WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := m.DB.Exec(stmt, deletedBy, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Restore takes a snippet back out of the trash. It returns ErrNoRecord if
+// the snippet is not in the trash or was deleted more than TrashRetention ago.
+func (m *SnippetModel) Restore(id int) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL, deleted_by = NULL
+	WHERE id = ? AND deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.Exec(stmt, id, int(TrashRetention/time.Second))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Trash returns the snippets a user deleted in the last TrashRetention, most
+// recently deleted first.
+func (m *SnippetModel) Trash(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `, s.deleted_at FROM snippets s
+	WHERE s.user_id = ? AND s.deleted_by = s.user_id
+	AND s.deleted_at > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	ORDER BY s.deleted_at DESC, s.id DESC`
+
+	rows, err := m.DB.Query(stmt, userID, int(TrashRetention/time.Second))
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +491,8 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	for rows.Next() {
 		s := &Snippet{}
-
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Format, &s.Language,
+			&s.ForkedFrom, &s.Visibility, &s.Upvotes, &s.Downvotes, &s.Stars, &s.Created, &s.Expires, &s.DeletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -93,3 +506,193 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	return snippets, nil
 }
+
+// PurgeDeleted permanently removes the snippets deleted more than
+// TrashRetention ago and, through the foreign keys, their comments. It
+// returns the number of snippets removed.
+func (m *SnippetModel) PurgeDeleted() (int, error) {
+	stmt := `DELETE FROM snippets WHERE deleted_at <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.Exec(stmt, int(TrashRetention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// ExpiredRetention is how long expired snippets are kept, answering with
+// ErrExpired, before PurgeExpired removes them.
+const ExpiredRetention = 7 * 24 * time.Hour
+
+// PurgeResult counts the rows removed by PurgeExpired. Votes counts the votes
+// on both the snippets and their comments.
+type PurgeResult struct {
+	Snippets int
+	Comments int
+	Votes    int
+}
+
+// PurgeExpired permanently removes the snippets that expired more than
+// ExpiredRetention ago. Their votes and comments, and the comments' votes, are
+// removed first so that they can be counted; everything else goes through the
+// foreign keys, which leave the comments' attachments behind for the caller
+// to clean up.
+func (m *SnippetModel) PurgeExpired() (PurgeResult, error) {
+	var result PurgeResult
+
+	cutoff := time.Now().UTC().Add(-ExpiredRetention)
+	expired := `SELECT id FROM snippets WHERE expires <= ?`
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, step := range []struct {
+		stmt  string
+		count *int
+	}{
+		{`DELETE FROM snippet_votes WHERE snippet_id IN (` + expired + `)`, &result.Votes},
+		{`DELETE cv FROM comment_votes cv INNER JOIN comments c ON c.id = cv.comment_id
+		WHERE c.snippet_id IN (` + expired + `)`, &result.Votes},
+		{`DELETE FROM comments WHERE snippet_id IN (` + expired + `)`, &result.Comments},
+		{`DELETE FROM snippets WHERE expires <= ?`, &result.Snippets},
+	} {
+		res, err := tx.Exec(step.stmt, cutoff)
+		if err != nil {
+			return PurgeResult{}, err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return PurgeResult{}, err
+		}
+
+		*step.count += int(rows)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PurgeResult{}, err
+	}
+
+	return result, nil
+}
+
+// RecalculateVotes sets the upvotes and downvotes of every snippet from
+// snippet_votes, to repair counts that drifted from the votes, and returns
+// how many snippets had them wrong.
+func (m *SnippetModel) RecalculateVotes() (int, error) {
+	stmt := `UPDATE snippets s
+	LEFT JOIN (SELECT snippet_id, SUM(vote_type = 'upvote') AS up, SUM(vote_type = 'downvote') AS down
+	           FROM snippet_votes GROUP BY snippet_id) v ON v.snippet_id = s.id
+	SET s.upvotes = COALESCE(v.up, 0), s.downvotes = COALESCE(v.down, 0)
+	WHERE s.upvotes <> COALESCE(v.up, 0) OR s.downvotes <> COALESCE(v.down, 0)`
+
+	result, err := m.DB.Exec(stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Count returns the number of snippets that have not been deleted, including
+// expired and private ones.
+func (m *SnippetModel) Count() (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE deleted_at IS NULL`).Scan(&count)
+
+	return count, err
+}
+
+// Fork copies an unexpired snippet into a new one owned by userID that
+// expires in the given number of days and records the original in
+// forked_from. The fork keeps the original's visibility and files. It returns
+// the new snippet's ID, or ErrNoRecord if the original does not exist.
+func (m *SnippetModel) Fork(id int, userID int, expires int) (int, error) {
+	stmt := `INSERT INTO snippets (user_id, title, content, format, language, visibility, forked_from, created, expires)
+	SELECT ?, title, content, format, language, visibility, id, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY)
+	FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL`
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, userID, expires, id)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if rows == 0 {
+		return 0, ErrNoRecord
+	}
+
+	forkID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO snippet_files (snippet_id, position, name, language, content)
+	SELECT ?, position, name, language, content FROM snippet_files WHERE snippet_id = ?`, forkID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(forkID), nil
+}
+
+// Forks returns the unexpired public snippets forked from a snippet, most
+// recent first.
+func (m *SnippetModel) Forks(id int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.forked_from = ? AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public'
+	ORDER BY s.id DESC`
+
+	return querySnippets(m.DB, stmt, id)
+}
+
+// Related returns up to limit unexpired public snippets that have something
+// in common with a snippet, best match first. Each tag they share with it
+// scores 3, being written in the same language 2 and having the same author
+// 1; snippets that score nothing are left out, and ties go to the newest. A
+// limit below 1 returns ErrInvalidPagination.
+func (m *SnippetModel) Related(id int, limit int) ([]*Snippet, error) {
+	if limit < 1 {
+		return nil, ErrInvalidPagination
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN snippets o ON o.id = ?
+	LEFT JOIN (SELECT st.snippet_id, COUNT(*) AS shared FROM snippet_tags st
+	           INNER JOIN snippet_tags ot ON ot.tag_id = st.tag_id AND ot.snippet_id = ?
+	           GROUP BY st.snippet_id) t ON t.snippet_id = s.id
+	WHERE s.id <> o.id AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public'
+	AND (t.shared IS NOT NULL OR (s.language <> '' AND s.language = o.language) OR s.user_id = o.user_id)
+	ORDER BY 3 * COALESCE(t.shared, 0)
+	         + 2 * (s.language <> '' AND s.language = o.language)
+	         + COALESCE(s.user_id = o.user_id, 0) DESC, s.id DESC
+	LIMIT ?`
+
+	return querySnippets(m.DB, stmt, id, id, limit)
+}
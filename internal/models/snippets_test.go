@@ -0,0 +1,493 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestSnippetModelUpdateDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	id, err := m.Insert("Title", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	err = m.Update(id, "New title", "New content")
+	assert.NilError(t, err)
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "New title")
+	assert.Equal(t, s.Content, "New content")
+
+	err = m.Delete(id, 1)
+	assert.NilError(t, err)
+
+	_, err = m.Get(id)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Delete(id, 1)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelTrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	own, err := m.Insert("Own", "Content", 7, 1)
+	assert.NilError(t, err)
+	moderated, err := m.Insert("Moderated", "Content", 7, 1)
+	assert.NilError(t, err)
+	old, err := m.Insert("Old", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.Delete(own, 1))
+	assert.NilError(t, m.Delete(moderated, 2))
+	assert.NilError(t, m.Delete(old, 1))
+
+	_, err = db.Exec(`UPDATE snippets SET deleted_at = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 31 DAY) WHERE id = ?`, old)
+	assert.NilError(t, err)
+
+	// Snippets deleted by someone else or too long ago are not in the trash
+	trash, err := m.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trash), 1)
+	assert.Equal(t, trash[0].ID, own)
+
+	assert.Equal(t, m.Restore(old), ErrNoRecord)
+	assert.NilError(t, m.Restore(own))
+
+	s, err := m.Get(own)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Own")
+
+	purged, err := m.PurgeDeleted()
+	assert.NilError(t, err)
+	assert.Equal(t, purged, 1)
+
+	var exists bool
+	err = db.QueryRow(`SELECT EXISTS(SELECT true FROM snippets WHERE id = ?)`, old).Scan(&exists)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		_, err := m.Insert(title, "Content", 7, 1)
+		assert.NilError(t, err)
+	}
+
+	snippets, total, err := m.Latest(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 3)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Title, "Three")
+
+	snippets, total, err = m.Latest(2, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 3)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "One")
+
+	snippets, _, err = m.Latest(3, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+
+	_, _, err = m.Latest(0, 2)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
+
+func TestSnippetModelInsertWithOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	plain, err := m.Insert("Plain", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	md, err := m.InsertWithOptions("Markdown", "# Content", SnippetOptions{Format: FormatMarkdown}, 7, 1)
+	assert.NilError(t, err)
+
+	s, err := m.Get(plain)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Format, FormatPlain)
+
+	s, err = m.Get(md)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Format, FormatMarkdown)
+	assert.Equal(t, s.Content, "# Content")
+}
+
+func TestSnippetModelLatestByLanguage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	_, err := m.InsertWithOptions("Go", "package main", SnippetOptions{Language: "go"}, 7, 1)
+	assert.NilError(t, err)
+	_, err = m.InsertWithOptions("Python", "print(1)", SnippetOptions{Language: "python"}, 7, 1)
+	assert.NilError(t, err)
+	_, err = m.Insert("Plain", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	snippets, total, err := m.LatestByLanguage("go", 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Language, "go")
+
+	_, total, err = m.LatestByLanguage("", 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 3)
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	id, err := m.Insert("First", "One", 7, 1)
+	assert.NilError(t, err)
+
+	err = m.Update(id, "Second", "Two")
+	assert.NilError(t, err)
+
+	// An update that changes nothing saves no revision
+	err = m.Update(id, "Second", "Two")
+	assert.NilError(t, err)
+
+	err = m.Update(id, "Third", "Three")
+	assert.NilError(t, err)
+
+	revisions, err := m.GetRevisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Title, "Second")
+	assert.Equal(t, revisions[1].Title, "First")
+
+	r, err := m.GetRevision(id, revisions[1].ID)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Content, "One")
+
+	_, err = m.GetRevision(id+1, revisions[1].ID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Update(id+1, "Title", "Content")
+	assert.Equal(t, err, ErrNoRecord)
+
+	// Editing the snippet moves its last modification to the latest revision
+	modified, err := m.LastModified(id)
+	assert.NilError(t, err)
+	assert.Equal(t, modified.Equal(revisions[0].Created), true)
+
+	_, err = m.LastModified(id + 1)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelFork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	id, err := m.InsertWithOptions("Original", "# Content", SnippetOptions{Format: FormatMarkdown, Language: "go"}, 7, 1)
+	assert.NilError(t, err)
+
+	forkID, err := m.Fork(id, 2, 30)
+	assert.NilError(t, err)
+
+	fork, err := m.Get(forkID)
+	assert.NilError(t, err)
+	assert.Equal(t, fork.UserID, 2)
+	assert.Equal(t, fork.Title, "Original")
+	assert.Equal(t, fork.Format, FormatMarkdown)
+	assert.Equal(t, fork.Language, "go")
+	assert.Equal(t, fork.ForkedFrom, id)
+
+	forks, err := m.Forks(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 1)
+	assert.Equal(t, forks[0].ID, forkID)
+
+	_, err = m.Fork(forkID+1, 2, 30)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	forever, err := m.InsertWithOptions("Forever", "Content", SnippetOptions{Expires: NeverExpires}, 0, 1)
+	assert.NilError(t, err)
+
+	s, err := m.Get(forever)
+	assert.NilError(t, err)
+	assert.Equal(t, s.NeverExpires(), true)
+
+	id, err := m.Insert("Shortened", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	s, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.NeverExpires(), false)
+
+	err = m.SetExpires(id, time.Now().Add(-time.Minute))
+	assert.NilError(t, err)
+
+	// Expired snippets are told apart from missing ones, but still count as
+	// not found
+	_, err = m.Get(id)
+	assert.Equal(t, err, ErrExpired)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// and can no longer be extended
+	err = m.SetExpires(id, NeverExpires)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+	comments := &CommentModel{DB: db}
+	attachments := &AttachmentModel{DB: db}
+
+	recent, err := m.Insert("Recent", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	old, err := m.Insert("Old", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	commentID, err := comments.Insert(old, 1, "Alice", "Gone soon")
+	assert.NilError(t, err)
+
+	// There is no one else to vote in the test database
+	_, err = db.Exec(`INSERT INTO snippet_votes (snippet_id, user_id, vote_type) VALUES (?, 2, 'upvote')`, old)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`INSERT INTO comment_votes (comment_id, user_id, vote_type) VALUES (?, 2, 'upvote')`, commentID)
+	assert.NilError(t, err)
+
+	_, err = attachments.Insert(&Attachment{CommentID: commentID, UserID: 1, Name: "frog.png",
+		ContentType: "image/png", Key: "abc.png", ThumbnailKey: "def.png", ThumbnailType: "image/png"})
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE snippets SET expires = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY) WHERE id = ?`, recent)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`UPDATE snippets SET expires = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 8 DAY) WHERE id = ?`, old)
+	assert.NilError(t, err)
+
+	// Only the snippet that expired more than ExpiredRetention ago goes
+	result, err := m.PurgeExpired()
+	assert.NilError(t, err)
+	assert.Equal(t, result, PurgeResult{Snippets: 1, Comments: 1, Votes: 2})
+
+	_, err = m.Get(recent)
+	assert.Equal(t, err, ErrExpired)
+
+	_, err = m.Get(old)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// leaving the comment's attachment behind for its files to be removed
+	orphans, err := attachments.Orphans(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(orphans), 1)
+	assert.Equal(t, orphans[0].Key, "abc.png")
+
+	assert.NilError(t, attachments.Delete(orphans[0].ID))
+
+	orphans, err = attachments.Orphans(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(orphans), 0)
+}
+
+func TestSnippetVisibleTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		visibility string
+		userID     int
+		want       bool
+	}{
+		{name: "Public to anyone", visibility: VisibilityPublic, userID: 0, want: true},
+		{name: "Unlisted to anyone", visibility: VisibilityUnlisted, userID: 0, want: true},
+		{name: "Private to anonymous", visibility: VisibilityPrivate, userID: 0, want: false},
+		{name: "Private to another user", visibility: VisibilityPrivate, userID: 2, want: false},
+		{name: "Private to the author", visibility: VisibilityPrivate, userID: 1, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snippet{UserID: 1, Visibility: tt.visibility}
+			assert.Equal(t, s.VisibleTo(tt.userID), tt.want)
+		})
+	}
+
+	// A private snippet whose author was deleted is visible to no one
+	s := &Snippet{Visibility: VisibilityPrivate}
+	assert.Equal(t, s.VisibleTo(0), false)
+}
+
+func TestSnippetModelVisibility(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	_, err := m.Insert("Public", "Content", 7, 1)
+	assert.NilError(t, err)
+	unlisted, err := m.InsertWithOptions("Unlisted", "Content", SnippetOptions{Visibility: VisibilityUnlisted}, 7, 1)
+	assert.NilError(t, err)
+	private, err := m.InsertWithOptions("Private", "Content", SnippetOptions{Visibility: VisibilityPrivate}, 7, 1)
+	assert.NilError(t, err)
+
+	snippets, total, err := m.Latest(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Title, "Public")
+
+	s, err := m.Get(unlisted)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Visibility, VisibilityUnlisted)
+
+	// Forks keep the visibility of the original and stay off the fork list
+	forkID, err := m.Fork(private, 1, 7)
+	assert.NilError(t, err)
+
+	fork, err := m.Get(forkID)
+	assert.NilError(t, err)
+	assert.Equal(t, fork.Visibility, VisibilityPrivate)
+
+	forks, err := m.Forks(private)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 0)
+}
+
+func TestSnippetModelByAuthor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	first, err := m.Insert("First", "Content", 7, 1)
+	assert.NilError(t, err)
+	second, err := m.Insert("Second", "Content", 7, 1)
+	assert.NilError(t, err)
+	_, err = m.InsertWithOptions("Unlisted", "Content", SnippetOptions{Visibility: VisibilityUnlisted}, 7, 1)
+	assert.NilError(t, err)
+	_, err = m.Insert("Someone else's", "Content", 7, 2)
+	assert.NilError(t, err)
+
+	snippets, total, err := m.ByAuthor(1, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, snippets[0].ID, second)
+	assert.Equal(t, snippets[1].ID, first)
+
+	snippets, total, err = m.ByAuthor(3, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 0)
+	assert.Equal(t, len(snippets), 0)
+}
+
+func TestSnippetModelRelated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+	tags := &TagModel{DB: db}
+
+	rust := SnippetOptions{Language: "rust"}
+
+	id, err := m.InsertWithOptions("Original", "Content", rust, 7, 11)
+	assert.NilError(t, err)
+	assert.NilError(t, tags.AttachToSnippet(id, []string{"haiku", "pond"}))
+
+	// Two shared tags score 6, a tag and the language 5, the author 1
+	twoTags, err := m.Insert("Two tags", "Content", 7, 12)
+	assert.NilError(t, err)
+	assert.NilError(t, tags.AttachToSnippet(twoTags, []string{"haiku", "pond"}))
+	tagAndLanguage, err := m.InsertWithOptions("Tag and language", "Content", rust, 7, 12)
+	assert.NilError(t, err)
+	assert.NilError(t, tags.AttachToSnippet(tagAndLanguage, []string{"pond"}))
+	author, err := m.Insert("Same author", "Content", 7, 11)
+	assert.NilError(t, err)
+
+	// Unrelated, private and expired snippets are left out
+	_, err = m.Insert("Unrelated", "Content", 7, 12)
+	assert.NilError(t, err)
+	private, err := m.InsertWithOptions("Private", "Content", SnippetOptions{Language: "rust", Visibility: VisibilityPrivate}, 7, 11)
+	assert.NilError(t, err)
+	assert.NilError(t, tags.AttachToSnippet(private, []string{"haiku"}))
+	expired, err := m.InsertWithOptions("Expired", "Content", rust, 7, 11)
+	assert.NilError(t, err)
+	assert.NilError(t, m.SetExpires(expired, time.Now().Add(-time.Hour)))
+
+	related, err := m.Related(id, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(related), 3)
+	assert.Equal(t, related[0].ID, twoTags)
+	assert.Equal(t, related[1].ID, tagAndLanguage)
+	assert.Equal(t, related[2].ID, author)
+
+	related, err = m.Related(id, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(related), 1)
+
+	_, err = m.Related(id, 0)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// TrendingGravity controls how fast a snippet's votes lose weight as it ages
+// on the trending page. Higher values favour newer snippets.
+const TrendingGravity = 1.8
+
+// Upvote records a user's upvote on a snippet and returns what was done with
+// the vote and the snippet's new score. Upvoting a snippet twice removes the
+// vote, and upvoting a downvoted snippet turns the downvote into an upvote.
+// It returns ErrNoRecord if the snippet does not exist and ErrSelfVote if
+// the user wrote it.
+func (m *SnippetModel) Upvote(snippetID, userID int) (VoteResult, error) {
+	return m.vote(snippetID, userID, "upvote")
+}
+
+// Downvote is like Upvote, for downvotes.
+func (m *SnippetModel) Downvote(snippetID, userID int) (VoteResult, error) {
+	return m.vote(snippetID, userID, "downvote")
+}
+
+// vote applies an upvote or downvote in one transaction. The snippet row is
+// locked first, so concurrent votes on a snippet are applied one at a time
+// and the counters always match snippet_votes.
+func (m *SnippetModel) vote(snippetID, userID int, voteType string) (VoteResult, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return VoteResult{}, err
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	err = tx.QueryRow(`SELECT COALESCE(user_id, 0) FROM snippets
+	WHERE id = ? AND expires > UTC_TIMESTAMP() AND deleted_at IS NULL FOR UPDATE`, snippetID).Scan(&authorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return VoteResult{}, ErrNoRecord
+		}
+		return VoteResult{}, err
+	}
+	if authorID != 0 && authorID == userID {
+		return VoteResult{}, ErrSelfVote
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT vote_type FROM snippet_votes WHERE snippet_id = ? AND user_id = ?`, snippetID, userID).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return VoteResult{}, err
+	}
+
+	// The counter columns are named after the vote types
+	column, currentColumn := voteType+"s", current+"s"
+
+	var action VoteAction
+	var stmts []string
+
+	switch current {
+	case voteType:
+		action = ActionRemoved
+		stmts = []string{
+			`DELETE FROM snippet_votes WHERE snippet_id = ? AND user_id = ?`,
+			`UPDATE snippets SET ` + column + ` = ` + column + ` - 1 WHERE id = ?`,
+		}
+	case "":
+		action = ActionAdded
+		stmts = []string{
+			`INSERT INTO snippet_votes (snippet_id, user_id, vote_type, created) VALUES (?, ?, '` + voteType + `', UTC_TIMESTAMP())`,
+			`UPDATE snippets SET ` + column + ` = ` + column + ` + 1 WHERE id = ?`,
+		}
+	default:
+		action = ActionChanged
+		stmts = []string{
+			`UPDATE snippet_votes SET vote_type = '` + voteType + `', created = UTC_TIMESTAMP() WHERE snippet_id = ? AND user_id = ?`,
+			`UPDATE snippets SET ` + column + ` = ` + column + ` + 1, ` + currentColumn + ` = ` + currentColumn + ` - 1 WHERE id = ?`,
+		}
+	}
+
+	_, err = tx.Exec(stmts[0], snippetID, userID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	_, err = tx.Exec(stmts[1], snippetID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	var score int
+	err = tx.QueryRow(`SELECT upvotes - downvotes FROM snippets WHERE id = ?`, snippetID).Scan(&score)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	return VoteResult{Action: action, Type: voteType, Score: score}, nil
+}
+
+// Trending returns a page of unexpired public snippets with a positive
+// score, ranked by score divided by (age in hours + 2) ^ TrendingGravity,
+// along with the total number of them. Pages start at 1; a page or pageSize
+// below 1 returns ErrInvalidPagination.
+func (m *SnippetModel) Trending(page, pageSize int) ([]*Snippet, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND deleted_at IS NULL
+	AND visibility = 'public' AND upvotes - downvotes > 0`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public' AND s.upvotes - s.downvotes > 0
+	ORDER BY (s.upvotes - s.downvotes) / POW(TIMESTAMPDIFF(SECOND, s.created, UTC_TIMESTAMP()) / 3600 + 2, ?) DESC, s.id DESC
+	LIMIT ? OFFSET ?`
+
+	snippets, err := querySnippets(m.DB, stmt, TrendingGravity, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// MostVoted returns up to limit unexpired snippets with at least one vote,
+// whatever their visibility, ordered by the number of votes cast on them.
+func (m *SnippetModel) MostVoted(limit int) ([]*Snippet, error) {
+	if limit < 1 {
+		return []*Snippet{}, nil
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	WHERE s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.upvotes + s.downvotes > 0
+	ORDER BY s.upvotes + s.downvotes DESC, s.id DESC
+	LIMIT ?`
+
+	return querySnippets(m.DB, stmt, limit)
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestSnippetModelVote(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	id, err := m.Insert("Voted", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	steps := []struct {
+		upvote bool
+		action VoteAction
+		score  int
+	}{
+		{upvote: true, action: ActionAdded, score: 1},
+		{upvote: false, action: ActionChanged, score: -1},
+		{upvote: false, action: ActionRemoved, score: 0},
+		{upvote: true, action: ActionAdded, score: 1},
+		{upvote: true, action: ActionRemoved, score: 0},
+	}
+
+	for _, step := range steps {
+		var result VoteResult
+		if step.upvote {
+			result, err = m.Upvote(id, 2)
+		} else {
+			result, err = m.Downvote(id, 2)
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, result.Action, step.action)
+		assert.Equal(t, result.Score, step.score)
+	}
+
+	_, err = m.Upvote(id, 3)
+	assert.NilError(t, err)
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Upvotes, 1)
+	assert.Equal(t, s.Downvotes, 0)
+
+	_, err = m.Upvote(id, 1)
+	assert.Equal(t, err, ErrSelfVote)
+
+	_, err = m.Upvote(id+1, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// RecalculateVotes repairs counts that drifted from snippet_votes
+	_, err = db.Exec(`UPDATE snippets SET upvotes = 0, downvotes = 4 WHERE id = ?`, id)
+	assert.NilError(t, err)
+
+	fixed, err := m.RecalculateVotes()
+	assert.NilError(t, err)
+	assert.Equal(t, fixed, 1)
+
+	s, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Upvotes, 1)
+	assert.Equal(t, s.Downvotes, 0)
+}
+
+func TestSnippetModelTrending(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &SnippetModel{DB: db}
+
+	old, err := m.Insert("Old", "Content", 7, 1)
+	assert.NilError(t, err)
+	recent, err := m.Insert("Recent", "Content", 7, 1)
+	assert.NilError(t, err)
+	_, err = m.Insert("Unvoted", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	// An older snippet needs many more votes to outrank a recent one
+	_, err = db.Exec(`UPDATE snippets SET created = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 2 DAY) WHERE id = ?`, old)
+	assert.NilError(t, err)
+
+	for userID := 2; userID <= 4; userID++ {
+		_, err = m.Upvote(old, userID)
+		assert.NilError(t, err)
+	}
+	for userID := 2; userID <= 3; userID++ {
+		_, err = m.Upvote(recent, userID)
+		assert.NilError(t, err)
+	}
+
+	snippets, total, err := m.Trending(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, snippets[0].ID, recent)
+	assert.Equal(t, snippets[1].ID, old)
+
+	_, _, err = m.Trending(0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Limits applied by ParseTags.
+const (
+	MaxTagLength      = 30
+	MaxTagsPerSnippet = 10
+)
+
+type TagModelInterface interface {
+	AttachToSnippet(snippetID int, names []string) error
+	GetBySnippet(snippetID int) ([]*Tag, error)
+	GetSnippetsByTag(name string) ([]*Snippet, error)
+}
+
+type Tag struct {
+	ID   int
+	Name string
+}
+
+// TagModel wraps a sql.DB conn pool.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// ParseTags splits a comma-separated list of tags, trimming and lowercasing
+// each one and dropping blanks and duplicates. It does not enforce
+// MaxTagLength or MaxTagsPerSnippet, so callers can report them.
+func ParseTags(input string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(input, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// AttachToSnippet tags a snippet with the given tag names, creating the tags
+// that do not exist yet. Tags already attached to the snippet are left as
+// they are.
+func (m *TagModel) AttachToSnippet(snippetID int, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := make([]any, len(names))
+	values := make([]string, len(names))
+	for i, name := range names {
+		args[i] = name
+		values[i] = "(?)"
+	}
+
+	stmt := `INSERT IGNORE INTO tags (name) VALUES ` + strings.Join(values, ", ")
+
+	_, err = tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT IGNORE INTO snippet_tags (snippet_id, tag_id)
+	SELECT ?, id FROM tags WHERE name IN (` + placeholders(len(names)) + `)`
+
+	_, err = tx.Exec(stmt, append([]any{snippetID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetBySnippet returns the tags of a snippet ordered by name.
+func (m *TagModel) GetBySnippet(snippetID int) ([]*Tag, error) {
+	stmt := `SELECT t.id, t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	WHERE st.snippet_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+
+	for rows.Next() {
+		t := &Tag{}
+
+		err = rows.Scan(&t.ID, &t.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetSnippetsByTag returns the unexpired public snippets with the given tag,
+// most recent first.
+func (m *TagModel) GetSnippetsByTag(name string) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	JOIN snippet_tags st ON st.snippet_id = s.id
+	JOIN tags t ON t.id = st.tag_id
+	WHERE t.name = ? AND s.expires > UTC_TIMESTAMP() AND s.deleted_at IS NULL AND s.visibility = 'public'
+	ORDER BY s.id DESC`
+
+	return querySnippets(m.DB, stmt, strings.ToLower(strings.TrimSpace(name)))
+}
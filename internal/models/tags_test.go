@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "Empty", input: "", want: []string{}},
+		{name: "Blanks", input: " , ,", want: []string{}},
+		{name: "Trims and lowercases", input: " Go, HAIKU ", want: []string{"go", "haiku"}},
+		{name: "Duplicates", input: "go,Go, go", want: []string{"go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTags(tt.input)
+
+			assert.Equal(t, len(got), len(tt.want))
+			for i := range got {
+				assert.Equal(t, got[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestTagModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	m := &TagModel{DB: db}
+
+	first, err := snippets.Insert("First", "Content", 7, 1)
+	assert.NilError(t, err)
+	second, err := snippets.Insert("Second", "Content", 7, 1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, m.AttachToSnippet(first, []string{"haiku", "go"}))
+	assert.NilError(t, m.AttachToSnippet(second, []string{"haiku"}))
+	// Attaching a tag twice does not duplicate it
+	assert.NilError(t, m.AttachToSnippet(first, []string{"go"}))
+	assert.NilError(t, m.AttachToSnippet(first, nil))
+
+	tags, err := m.GetBySnippet(first)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tags), 2)
+	assert.Equal(t, tags[0].Name, "go")
+	assert.Equal(t, tags[1].Name, "haiku")
+
+	tagged, err := m.GetSnippetsByTag("haiku")
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged), 2)
+	assert.Equal(t, tagged[0].ID, second)
+	assert.Equal(t, tagged[1].ID, first)
+
+	tagged, err = m.GetSnippetsByTag("unknown")
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged), 0)
+}
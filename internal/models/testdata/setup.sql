@@ -1,26 +1,327 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER,
     title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
+    format ENUM('plain', 'markdown') NOT NULL DEFAULT 'plain',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    forked_from INTEGER,
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    downvotes INTEGER NOT NULL DEFAULT 0,
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NOT NULL,
+    deleted_at DATETIME,
+    deleted_by INTEGER
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_deleted_at ON snippets(deleted_at);
+CREATE INDEX idx_snippets_language ON snippets(language);
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets(title, content);
+
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_snippet_name UNIQUE (snippet_id, name);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet ON snippet_revisions(snippet_id);
+
+CREATE TABLE snippet_votes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    vote_type ENUM('upvote', 'downvote') NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE snippet_votes ADD CONSTRAINT snippet_votes_uc UNIQUE (snippet_id, user_id);
+
+CREATE TABLE share_links (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    nonce CHAR(22) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+ALTER TABLE share_links ADD CONSTRAINT share_links_uc_nonce UNIQUE (nonce);
+CREATE INDEX idx_share_links_snippet ON share_links(snippet_id);
 
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    created DATETIME NOT NULL,
+    role ENUM('user', 'moderator', 'admin') NOT NULL DEFAULT 'user',
+    shadowbanned BOOLEAN NOT NULL DEFAULT FALSE,
+    verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(32),
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    banned BOOLEAN NOT NULL DEFAULT FALSE,
+    locked_until DATETIME,
+    unlock_token_hash CHAR(64),
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    digest_sent DATETIME,
+    theme VARCHAR(8) NOT NULL DEFAULT 'light'
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
+CREATE TABLE audit_log (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER,
+    action VARCHAR(32) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE backup_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE backup_codes ADD CONSTRAINT backup_codes_uc_user_code UNIQUE (user_id, code_hash);
+
+CREATE TABLE email_verifications (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+ALTER TABLE email_verifications ADD CONSTRAINT email_verifications_uc_token_hash UNIQUE (token_hash);
+
+CREATE TABLE user_identities (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    provider_id VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_provider_id UNIQUE (provider, provider_id);
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_user_provider UNIQUE (user_id, provider);
+
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash);
+
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret CHAR(64) NOT NULL,
+    events VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status ENUM('pending', 'delivered', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    next_attempt DATETIME NOT NULL,
+    finished DATETIME
+);
+
+CREATE TABLE login_attempts (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE notifications (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    actor_id INTEGER,
+    kind VARCHAR(32) NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    comment_id INTEGER,
+    is_read BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, is_read);
+
+CREATE TABLE favorites (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE favorites ADD CONSTRAINT favorites_uc_snippet_user UNIQUE (snippet_id, user_id);
+
+CREATE TABLE follows (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    follower_id INTEGER NOT NULL,
+    followee_id INTEGER NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE follows ADD CONSTRAINT follows_uc_follower_followee UNIQUE (follower_id, followee_id);
+
+CREATE TABLE password_resets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+ALTER TABLE password_resets ADD CONSTRAINT password_resets_uc_token_hash UNIQUE (token_hash);
+
 INSERT INTO users (name, email, hashed_password, created) VALUES (
     'Alice Jones',
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2022-01-01 10:00:00'
-);
\ No newline at end of file
+);
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    author_id INTEGER,
+    author VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    upvotes INTEGER DEFAULT 0,
+    downvotes INTEGER NOT NULL DEFAULT 0,
+    pin_order INTEGER,
+    status ENUM('approved', 'pending', 'rejected') NOT NULL DEFAULT 'approved',
+    status_note VARCHAR(255),
+    edit_count INTEGER NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 0,
+    locked BOOLEAN NOT NULL DEFAULT FALSE,
+    is_question BOOLEAN NOT NULL DEFAULT FALSE,
+    accepted BOOLEAN NOT NULL DEFAULT FALSE,
+    views INTEGER NOT NULL DEFAULT 0,
+    moderator_note VARCHAR(500),
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at DATETIME,
+    deleted_by INTEGER
+);
+
+CREATE FULLTEXT INDEX idx_comments_content ON comments(content);
+
+CREATE TABLE comment_changes (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    comment_id INTEGER NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER comments_after_insert AFTER INSERT ON comments FOR EACH ROW
+    INSERT INTO comment_changes (comment_id) VALUES (NEW.id);
+
+CREATE TRIGGER comments_after_update AFTER UPDATE ON comments FOR EACH ROW
+BEGIN
+    IF NOT (NEW.content <=> OLD.content AND NEW.author <=> OLD.author AND NEW.status <=> OLD.status
+            AND NEW.deleted <=> OLD.deleted) THEN
+        INSERT INTO comment_changes (comment_id) VALUES (NEW.id);
+    END IF;
+END;
+
+CREATE TRIGGER comments_after_delete AFTER DELETE ON comments FOR EACH ROW
+    INSERT INTO comment_changes (comment_id) VALUES (OLD.id);
+
+CREATE TABLE attachments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    comment_id INTEGER,
+    user_id INTEGER,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(32) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    storage_key VARCHAR(64) NOT NULL,
+    thumbnail_key VARCHAR(64) NOT NULL,
+    thumbnail_type VARCHAR(32) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE comment_votes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    vote_type ENUM('upvote', 'downvote') NOT NULL,
+    counted BOOLEAN NOT NULL DEFAULT TRUE,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE comment_votes ADD CONSTRAINT comment_votes_uc UNIQUE (comment_id, user_id);
+
+CREATE TABLE comment_reactions (
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reaction VARCHAR(16) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (comment_id, user_id, reaction)
+);
+
+CREATE TABLE comment_reports (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE comment_reports ADD CONSTRAINT comment_reports_uc UNIQUE (comment_id, user_id);
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
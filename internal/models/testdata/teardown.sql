@@ -1,3 +1,53 @@
+DROP TABLE comment_reactions;
+
+DROP TABLE audit_log;
+
+DROP TABLE backup_codes;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhooks;
+
+DROP TABLE api_tokens;
+
+DROP TABLE user_identities;
+
+DROP TABLE email_verifications;
+
+DROP TABLE login_attempts;
+
+DROP TABLE notifications;
+
+DROP TABLE favorites;
+
+DROP TABLE follows;
+
+DROP TABLE password_resets;
+
+DROP TABLE snippet_votes;
+
+DROP TABLE share_links;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
+
+DROP TABLE attachments;
+
+DROP TABLE comment_reports;
+
+DROP TABLE comment_votes;
+
+DROP TABLE comments;
+
+DROP TABLE comment_changes;
+
 DROP TABLE users;
 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE snippets;
+
+DROP TABLE sessions;
\ No newline at end of file
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/totp"
+)
+
+type TwoFactorModelInterface interface {
+	Setup(userID int) (string, error)
+	Enable(userID int, code string) ([]string, error)
+	Disable(userID int, code string) error
+	Enabled(userID int) (bool, error)
+	Verify(userID int, code string) error
+	BackupCodesLeft(userID int) (int, error)
+}
+
+// BackupCodeCount is the number of backup codes a user gets when they enable
+// two-factor authentication.
+const BackupCodeCount = 10
+
+// TwoFactorModel wraps a sql.DB conn pool. A user's TOTP secret lives on
+// their row in users, and is only checked at login once totp_enabled is set.
+// Backup codes are stored as SHA-256 hashes, like password reset tokens.
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+// Setup returns the TOTP secret a user should add to their authenticator
+// app, generating one the first time. It returns ErrTwoFactorEnabled if two-
+// factor authentication is already on and ErrNoRecord if there is no such
+// user.
+func (m *TwoFactorModel) Setup(userID int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+
+	err = tx.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	if enabled {
+		return "", ErrTwoFactorEnabled
+	}
+	if secret.Valid {
+		return secret.String, nil
+	}
+
+	newSecret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_secret = ? WHERE id = ?`, newSecret, userID)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return newSecret, nil
+}
+
+// Enable turns two-factor authentication on once the user proves their app
+// has the secret from Setup by entering a code from it, and returns a fresh
+// set of backup codes. They are only ever available here, so the user must
+// be shown them now. It returns ErrInvalidTOTP if the code is wrong and
+// ErrNoRecord if Setup was not called first.
+func (m *TwoFactorModel) Enable(userID int, code string) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+
+	err = tx.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if !secret.Valid {
+		return nil, ErrNoRecord
+	}
+
+	step, ok := totp.Match(secret.String, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTP
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled = TRUE, totp_last_step = ? WHERE id = ?`, step, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM backup_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, BackupCodeCount)
+	for i := range codes {
+		codes[i], err = newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt := `INSERT INTO backup_codes (user_id, code_hash, created) VALUES(?, ?, UTC_TIMESTAMP())`
+
+		_, err = tx.Exec(stmt, userID, hashToken(normalizeBackupCode(codes[i])))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off, after checking a code as
+// Verify does, and forgets the secret and backup codes.
+func (m *TwoFactorModel) Disable(userID int, code string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = verifyTwoFactor(tx, userID, code)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?`
+
+	_, err = tx.Exec(stmt, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM backup_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Enabled reports whether a user has turned two-factor authentication on.
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	var enabled bool
+
+	err := m.DB.QueryRow(`SELECT totp_enabled FROM users WHERE id = ?`, userID).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		}
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+// Verify checks the second factor of a login: either a code from the user's
+// authenticator app or one of their backup codes. Each app code is accepted
+// once and a backup code is used up by it. It returns ErrInvalidTOTP if the
+// code is wrong or two-factor authentication is off.
+func (m *TwoFactorModel) Verify(userID int, code string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = verifyTwoFactor(tx, userID, code)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// BackupCodesLeft returns the number of unused backup codes a user has.
+func (m *TwoFactorModel) BackupCodesLeft(userID int) (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM backup_codes WHERE user_id = ?`, userID).Scan(&count)
+
+	return count, err
+}
+
+// verifyTwoFactor implements Verify inside tx. The user's row is locked so
+// the same app code cannot be accepted twice by concurrent logins.
+func verifyTwoFactor(tx *sql.Tx, userID int, code string) error {
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+
+	stmt := `SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ? FOR UPDATE`
+
+	err := tx.QueryRow(stmt, userID).Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidTOTP
+		}
+		return err
+	}
+
+	if !enabled || !secret.Valid {
+		return ErrInvalidTOTP
+	}
+
+	step, ok := totp.Match(secret.String, code, time.Now())
+	if ok {
+		if step <= lastStep {
+			return ErrInvalidTOTP
+		}
+
+		_, err = tx.Exec(`UPDATE users SET totp_last_step = ? WHERE id = ?`, step, userID)
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM backup_codes WHERE user_id = ? AND code_hash = ?`, userID, hashToken(normalizeBackupCode(code)))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrInvalidTOTP
+	}
+
+	return nil
+}
+
+// newBackupCode returns a random code like "k3vq-7dxa".
+func newBackupCode() (string, error) {
+	b := make([]byte, 5)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return code[:4] + "-" + code[4:], nil
+}
+
+// normalizeBackupCode strips the formatting users may or may not type along
+// with a backup code.
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
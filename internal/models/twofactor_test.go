@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+	"snippetbox.jmorelli.dev/internal/totp"
+)
+
+func TestTwoFactorModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &TwoFactorModel{DB: db}
+
+	enabled, err := m.Enabled(1)
+	assert.NilError(t, err)
+	assert.Equal(t, enabled, false)
+
+	_, err = m.Enable(1, "123456")
+	assert.Equal(t, err, ErrNoRecord)
+
+	secret, err := m.Setup(1)
+	assert.NilError(t, err)
+
+	// Setup hands out the same secret until two-factor is on
+	again, err := m.Setup(1)
+	assert.NilError(t, err)
+	assert.Equal(t, again, secret)
+
+	now := time.Now()
+	previous, err := totp.Code(secret, totp.Step(now)-1)
+	assert.NilError(t, err)
+	current, err := totp.Code(secret, totp.Step(now))
+	assert.NilError(t, err)
+
+	_, err = m.Enable(1, "not a code")
+	assert.Equal(t, err, ErrInvalidTOTP)
+
+	codes, err := m.Enable(1, previous)
+	assert.NilError(t, err)
+	assert.Equal(t, len(codes), BackupCodeCount)
+
+	_, err = m.Setup(1)
+	assert.Equal(t, err, ErrTwoFactorEnabled)
+
+	// The code used to enable it cannot be used to log in
+	assert.Equal(t, m.Verify(1, previous), ErrInvalidTOTP)
+	assert.NilError(t, m.Verify(1, current))
+	assert.Equal(t, m.Verify(1, current), ErrInvalidTOTP)
+
+	// Backup codes are accepted once, however they are typed
+	assert.NilError(t, m.Verify(1, " "+codes[0]+" "))
+	assert.Equal(t, m.Verify(1, codes[0]), ErrInvalidTOTP)
+
+	left, err := m.BackupCodesLeft(1)
+	assert.NilError(t, err)
+	assert.Equal(t, left, BackupCodeCount-1)
+
+	assert.Equal(t, m.Disable(1, "wrong"), ErrInvalidTOTP)
+	assert.NilError(t, m.Disable(1, codes[1]))
+
+	enabled, err = m.Enabled(1)
+	assert.NilError(t, err)
+	assert.Equal(t, enabled, false)
+
+	left, err = m.BackupCodesLeft(1)
+	assert.NilError(t, err)
+	assert.Equal(t, left, 0)
+}
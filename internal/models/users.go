@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"strings"
 	"time"
@@ -16,14 +18,91 @@ type UserModelInterface interface {
 	Exists(id int) (bool, error)
 	Get(id int) (*User, error)
 	UpdatePassword(id int, oldPassword, newPassword string) error
+	UpdateName(id int, name string) error
+	UpdateEmail(id int, email, password string) error
+	VotesReceived(id int) (VoteTotals, error)
+	List(page, pageSize int) ([]*User, int, error)
+	SetRole(id int, role string) error
+	SetBanned(id int, banned bool) error
+	Count() (int, error)
+	Recent(limit int) ([]*User, error)
+	GetByNames(names []string) ([]*User, error)
+	SetDigest(id int, enabled bool) error
+	SetTheme(id int, theme string) error
+	DigestsDue(interval time.Duration) ([]*User, error)
+	DigestSent(id int) error
+	Lock(email string, d time.Duration) (string, error)
+	Unlock(token string) error
 }
 
+// User roles. Moderators and admins are considered staff.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID             int
 	Name           string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Role           string
+	Verified       bool
+	Banned         bool
+	Digest         bool
+	Theme          string
+}
+
+// VoteTotals counts the votes a user's snippets and comments have received.
+type VoteTotals struct {
+	Upvotes   int
+	Downvotes int
+}
+
+// Score returns the upvotes minus the downvotes.
+func (v VoteTotals) Score() int {
+	return v.Upvotes - v.Downvotes
+}
+
+// roleRanks orders the roles. Each role can do everything the roles ranked
+// below it can.
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// Themes the site can be shown in.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// ValidTheme reports whether theme is ThemeLight or ThemeDark.
+func ValidTheme(theme string) bool {
+	return theme == ThemeLight || theme == ThemeDark
+}
+
+// Roles lists the roles from least to most privileged.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether the user has role or a role ranked above it.
+func (u *User) HasRole(role string) bool {
+	rank, ok := roleRanks[role]
+	return ok && roleRanks[u.Role] >= rank
+}
+
+// IsStaff returns true if the user has a moderator or admin role.
+func (u *User) IsStaff() bool {
+	return u.HasRole(RoleModerator)
 }
 
 type UserModel struct {
@@ -53,11 +132,17 @@ func (m *UserModel) Insert(name, password, email string) error {
 	return nil
 }
 
+// Authenticate returns the ID of the user with the email address and
+// password. It returns ErrInvalidCredentials if there is none, ErrBanned if
+// the user is banned and ErrAccountLocked, before the password is even
+// checked, if their account is locked.
 func (m *UserModel) Authenticate(email, password string) (id int, err error) {
 	var hashedPassword []byte
+	var banned, locked bool
 
-	stmt := `SELECT id, hashed_password FROM users WHERE email = ?`
-	err = m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	stmt := `SELECT id, hashed_password, banned, COALESCE(locked_until > UTC_TIMESTAMP(), FALSE)
+	FROM users WHERE email = ?`
+	err = m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &banned, &locked)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -66,6 +151,10 @@ func (m *UserModel) Authenticate(email, password string) (id int, err error) {
 		}
 	}
 
+	if locked {
+		return 0, ErrAccountLocked
+	}
+
 	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
@@ -75,11 +164,16 @@ func (m *UserModel) Authenticate(email, password string) (id int, err error) {
 		}
 	}
 
+	if banned {
+		return 0, ErrBanned
+	}
+
 	return id, nil
 }
 
+// Exists reports whether there is a user with the ID who has not been banned.
 func (m *UserModel) Exists(id int) (exists bool, err error) {
-	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND banned = FALSE)"
 
 	err = m.DB.QueryRow(stmt, id).Scan(&exists)
 
@@ -87,10 +181,10 @@ func (m *UserModel) Exists(id int) (exists bool, err error) {
 }
 
 func (m *UserModel) Get(id int) (*User, error) {
-	stmt := "SELECT name, email, created FROM users WHERE id = ?"
+	stmt := "SELECT id, name, email, created, role, verified, banned, digest, theme FROM users WHERE id = ?"
 
 	usr := &User{}
-	err := m.DB.QueryRow(stmt, id).Scan(&usr.Name, &usr.Email, &usr.Created)
+	err := m.DB.QueryRow(stmt, id).Scan(&usr.ID, &usr.Name, &usr.Email, &usr.Created, &usr.Role, &usr.Verified, &usr.Banned, &usr.Digest, &usr.Theme)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -129,3 +223,325 @@ func (m *UserModel) UpdatePassword(id int, oldPassword, newPassword string) erro
 
 	return nil
 }
+
+// UpdateName changes a user's display name, along with the author name shown
+// on the comments they wrote. It returns ErrNoRecord if the user does not
+// exist.
+func (m *UserModel) UpdateName(id int, name string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE id = ? FOR UPDATE)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = tx.Exec(`UPDATE users SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE comments SET author = ? WHERE author_id = ?`, name, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEmail changes a user's email address after checking their current
+// password. The new address starts out unverified. It returns ErrInvalidCredentials if the password is wrong and
+// ErrDuplicateEmail if another user already has the address.
+func (m *UserModel) UpdateEmail(id int, email, password string) error {
+	var hashedPassword []byte
+
+	err := m.DB.QueryRow(`SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		return ErrInvalidCredentials
+	}
+
+	_, err = m.DB.Exec(`UPDATE users SET email = ?, verified = FALSE WHERE id = ?`, email, id)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// VotesReceived adds up the votes on a user's snippets and on their comments
+// that have not been deleted.
+func (m *UserModel) VotesReceived(id int) (VoteTotals, error) {
+	stmt := `SELECT COALESCE(SUM(upvotes), 0), COALESCE(SUM(downvotes), 0) FROM (
+		SELECT upvotes, downvotes FROM snippets WHERE user_id = ?
+		UNION ALL
+		SELECT upvotes, downvotes FROM comments WHERE author_id = ? AND deleted = FALSE
+	) v`
+
+	var totals VoteTotals
+
+	err := m.DB.QueryRow(stmt, id, id).Scan(&totals.Upvotes, &totals.Downvotes)
+	if err != nil {
+		return VoteTotals{}, err
+	}
+
+	return totals, nil
+}
+
+// List returns a page of users, oldest account first, and the total number of
+// users.
+func (m *UserModel) List(page, pageSize int) ([]*User, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, ErrInvalidPagination
+	}
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, name, email, created, role, verified, banned, digest, theme FROM users
+	ORDER BY id LIMIT ? OFFSET ?`
+
+	users, err := m.query(stmt, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetRole changes a user's role. It returns ErrInvalidRole if role is not one
+// of Roles and ErrNoRecord if the user does not exist.
+func (m *UserModel) SetRole(id int, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = m.DB.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+
+	return err
+}
+
+// SetBanned bans or unbans a user. Banned users cannot log in and their
+// existing sessions stop being recognised. It returns ErrNoRecord if the user
+// does not exist.
+func (m *UserModel) SetBanned(id int, banned bool) error {
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = m.DB.Exec(`UPDATE users SET banned = ? WHERE id = ?`, banned, id)
+
+	return err
+}
+
+// SetDigest turns the weekly email digest on or off for a user.
+func (m *UserModel) SetDigest(id int, enabled bool) error {
+	_, err := m.DB.Exec(`UPDATE users SET digest = ? WHERE id = ?`, enabled, id)
+
+	return err
+}
+
+// SetTheme saves the theme a user sees the site in. It returns
+// ErrInvalidTheme if theme is not a valid theme.
+func (m *UserModel) SetTheme(id int, theme string) error {
+	if !ValidTheme(theme) {
+		return ErrInvalidTheme
+	}
+
+	_, err := m.DB.Exec(`UPDATE users SET theme = ? WHERE id = ?`, theme, id)
+
+	return err
+}
+
+// DigestsDue returns the users who want the digest and have not been sent one
+// within interval. Unverified and banned users get none.
+func (m *UserModel) DigestsDue(interval time.Duration) ([]*User, error) {
+	stmt := `SELECT id, name, email, created, role, verified, banned, digest, theme FROM users
+	WHERE digest = TRUE AND verified = TRUE AND banned = FALSE
+	AND (digest_sent IS NULL OR digest_sent <= DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND))
+	ORDER BY id`
+
+	return m.query(stmt, int(interval.Seconds()))
+}
+
+// DigestSent records that a user has just been sent the digest.
+func (m *UserModel) DigestSent(id int) error {
+	_, err := m.DB.Exec(`UPDATE users SET digest_sent = UTC_TIMESTAMP() WHERE id = ?`, id)
+
+	return err
+}
+
+// Lock locks the account with the email address for d, so it cannot be
+// logged in to, and returns a token that unlocks it early. It returns
+// ErrNoRecord if no user has the address.
+func (m *UserModel) Lock(email string, d time.Duration) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `UPDATE users SET locked_until = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), unlock_token_hash = ?
+	WHERE email = ?`
+
+	result, err := m.DB.Exec(stmt, int(d.Seconds()), hashToken(token), email)
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rows == 0 {
+		return "", ErrNoRecord
+	}
+
+	return token, nil
+}
+
+// Unlock unlocks the account a token from Lock was made for and forgets its
+// failed logins. It returns ErrInvalidUnlockToken if the token is unknown or
+// the lock has already run out.
+func (m *UserModel) Unlock(token string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var email string
+
+	stmt := `SELECT id, email FROM users
+	WHERE unlock_token_hash = ? AND locked_until > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&id, &email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidUnlockToken
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET locked_until = NULL, unlock_token_hash = NULL WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM login_attempts WHERE email = ?`, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Count returns the number of users, banned or not.
+func (m *UserModel) Count() (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+
+	return count, err
+}
+
+// Recent returns up to limit users, newest account first.
+func (m *UserModel) Recent(limit int) ([]*User, error) {
+	if limit < 1 {
+		return []*User{}, nil
+	}
+
+	stmt := `SELECT id, name, email, created, role, verified, banned, digest, theme FROM users
+	ORDER BY created DESC, id DESC LIMIT ?`
+
+	return m.query(stmt, limit)
+}
+
+// GetByNames returns the users with any of the names, compared without
+// regard to case. Names are not unique, so there may be more users than names.
+func (m *UserModel) GetByNames(names []string) ([]*User, error) {
+	if len(names) == 0 {
+		return []*User{}, nil
+	}
+
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+
+	stmt := `SELECT id, name, email, created, role, verified, banned, digest, theme FROM users
+	WHERE name IN (?` + strings.Repeat(", ?", len(names)-1) + `)`
+
+	return m.query(stmt, args...)
+}
+
+// query runs a query selecting the columns read by Get and returns the users
+// it finds.
+func (m *UserModel) query(stmt string, args ...any) ([]*User, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		usr := &User{}
+
+		err = rows.Scan(&usr.ID, &usr.Name, &usr.Email, &usr.Created, &usr.Role, &usr.Verified, &usr.Banned, &usr.Digest, &usr.Theme)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, usr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"snippetbox.jmorelli.dev/internal/assert"
 )
@@ -46,3 +47,320 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelVotesReceived(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := &SnippetModel{DB: db}
+	comments := &CommentModel{DB: db}
+	m := &UserModel{db}
+
+	totals, err := m.VotesReceived(1)
+	assert.NilError(t, err)
+	assert.Equal(t, totals, VoteTotals{})
+
+	snippetID, err := snippets.Insert("Voted", "Content", 7, 1)
+	assert.NilError(t, err)
+	commentID, err := comments.Insert(snippetID, 1, "Alice", "Voted")
+	assert.NilError(t, err)
+
+	_, err = snippets.Upvote(snippetID, 2)
+	assert.NilError(t, err)
+	_, err = snippets.Upvote(snippetID, 3)
+	assert.NilError(t, err)
+	_, err = comments.Downvote(commentID, 2)
+	assert.NilError(t, err)
+
+	totals, err = m.VotesReceived(1)
+	assert.NilError(t, err)
+	assert.Equal(t, totals.Upvotes, 2)
+	assert.Equal(t, totals.Downvotes, 1)
+	assert.Equal(t, totals.Score(), 1)
+}
+
+func TestUserModelUpdateNameEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+	comments := &CommentModel{DB: db}
+
+	err := m.Insert("Bob", "bob@example.com", "pa55word")
+	assert.NilError(t, err)
+
+	commentID, err := comments.Insert(1, 1, "Alice Jones", "Hello")
+	assert.NilError(t, err)
+
+	// Renaming also renames the author of the user's comments
+	assert.NilError(t, m.UpdateName(1, "Alice Smith"))
+	usr, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Name, "Alice Smith")
+	comment, err := comments.Get(commentID)
+	assert.NilError(t, err)
+	assert.Equal(t, comment.Author, "Alice Smith")
+
+	assert.Equal(t, m.UpdateName(99, "Nobody"), ErrNoRecord)
+
+	assert.Equal(t, m.UpdateEmail(2, "bobby@example.com", "wrong"), ErrInvalidCredentials)
+	assert.Equal(t, m.UpdateEmail(2, "alice@example.com", "pa55word"), ErrDuplicateEmail)
+	assert.NilError(t, m.UpdateEmail(2, "bobby@example.com", "pa55word"))
+
+	usr, err = m.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Email, "bobby@example.com")
+}
+
+func TestUserHasRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role string
+		want map[string]bool
+	}{
+		{
+			name: "User",
+			role: RoleUser,
+			want: map[string]bool{RoleUser: true, RoleModerator: false, RoleAdmin: false},
+		},
+		{
+			name: "Moderator",
+			role: RoleModerator,
+			want: map[string]bool{RoleUser: true, RoleModerator: true, RoleAdmin: false},
+		},
+		{
+			name: "Admin",
+			role: RoleAdmin,
+			want: map[string]bool{RoleUser: true, RoleModerator: true, RoleAdmin: true},
+		},
+		{
+			name: "Unknown role",
+			role: "superuser",
+			want: map[string]bool{RoleUser: true, RoleModerator: false, RoleAdmin: false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &User{Role: tt.role}
+
+			for role, want := range tt.want {
+				assert.Equal(t, u.HasRole(role), want)
+			}
+			assert.Equal(t, u.HasRole("superuser"), false)
+			assert.Equal(t, u.IsStaff(), tt.want[RoleModerator])
+		})
+	}
+}
+
+func TestUserModelSetRoleList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	assert.Equal(t, m.SetRole(1, "superuser"), ErrInvalidRole)
+	assert.Equal(t, m.SetRole(99, RoleAdmin), ErrNoRecord)
+	assert.NilError(t, m.SetRole(1, RoleModerator))
+
+	// Setting the role a user already has is not an error
+	assert.NilError(t, m.SetRole(1, RoleModerator))
+
+	users, total, err := m.List(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].Role, RoleModerator)
+
+	_, _, err = m.List(0, 10)
+	assert.Equal(t, err, ErrInvalidPagination)
+}
+
+func TestUserModelSetBanned(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	assert.Equal(t, m.SetBanned(99, true), ErrNoRecord)
+	assert.NilError(t, m.SetBanned(1, true))
+
+	exists, err := m.Exists(1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+
+	usr, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Banned, true)
+
+	assert.NilError(t, m.SetBanned(1, false))
+
+	exists, err = m.Exists(1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+}
+
+func TestUserModelGetByNames(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+	('Bob', 'bob@example.com', '', UTC_TIMESTAMP()),
+	('bob', 'bob2@example.com', '', UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	users, err := m.GetByNames([]string{"BOB", "alice jones", "nobody"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 3)
+
+	users, err = m.GetByNames(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 0)
+}
+
+func TestUserModelDigest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	_, err := db.Exec(`UPDATE users SET verified = TRUE WHERE id = 1`)
+	assert.NilError(t, err)
+
+	users, err := m.DigestsDue(7 * 24 * time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 0)
+
+	assert.NilError(t, m.SetDigest(1, true))
+
+	usr, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Digest, true)
+
+	users, err = m.DigestsDue(7 * 24 * time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 1)
+
+	// Not due again until a week has passed
+	assert.NilError(t, m.DigestSent(1))
+
+	users, err = m.DigestsDue(7 * 24 * time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 0)
+
+	_, err = db.Exec(`UPDATE users SET digest_sent = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 8 DAY) WHERE id = 1`)
+	assert.NilError(t, err)
+
+	users, err = m.DigestsDue(7 * 24 * time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 1)
+}
+
+func TestUserModelSetTheme(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	usr, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Theme, ThemeLight)
+
+	assert.NilError(t, m.SetTheme(1, ThemeDark))
+
+	usr, err = m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, usr.Theme, ThemeDark)
+
+	assert.Equal(t, m.SetTheme(1, "purple"), ErrInvalidTheme)
+}
+
+func TestUserModelLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+	attempts := &LoginAttemptModel{DB: db}
+
+	_, err := m.Lock("nobody@example.com", time.Hour)
+	assert.Equal(t, err, ErrNoRecord)
+
+	_, err = attempts.Fail("alice@example.com", "192.0.2.1")
+	assert.NilError(t, err)
+
+	token, err := m.Lock("alice@example.com", time.Hour)
+	assert.NilError(t, err)
+
+	// A locked account does not even check the password
+	_, err = m.Authenticate("alice@example.com", "wrongpassword")
+	assert.Equal(t, err, ErrAccountLocked)
+
+	assert.Equal(t, m.Unlock("forged"), ErrInvalidUnlockToken)
+	assert.NilError(t, m.Unlock(token))
+	assert.Equal(t, m.Unlock(token), ErrInvalidUnlockToken)
+
+	_, err = m.Authenticate("alice@example.com", "wrongpassword")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	// Unlocking forgets the failed logins
+	failures, err := attempts.Fail("alice@example.com", "192.0.2.1")
+	assert.NilError(t, err)
+	assert.Equal(t, failures, 1)
+
+	// Expired locks cannot be unlocked, and no longer lock
+	token, err = m.Lock("alice@example.com", -time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, m.Unlock(token), ErrInvalidUnlockToken)
+
+	_, err = m.Authenticate("alice@example.com", "wrongpassword")
+	assert.Equal(t, err, ErrInvalidCredentials)
+}
+
+func TestUserModelCountRecent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &UserModel{db}
+
+	count, err := m.Count()
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+
+	users, err := m.Recent(5)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].Email, "alice@example.com")
+
+	users, err = m.Recent(0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 0)
+}
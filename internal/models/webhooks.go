@@ -0,0 +1,340 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+type WebhookModelInterface interface {
+	Insert(userID int, url string, events []string) (*Webhook, error)
+	Get(id int) (*Webhook, error)
+	ForUser(userID int) ([]*Webhook, error)
+	Delete(userID, id int) error
+	Enqueue(event string, ownerID int, public bool, payload []byte) (int, error)
+	Due(limit int) ([]*WebhookDelivery, error)
+	Delivered(id int64, responseCode int) error
+	Failed(id int64, responseCode int, reason string, retryAt time.Time) error
+	Deliveries(webhookID int, limit int) ([]*WebhookDelivery, error)
+	PurgeDeliveries() (int, error)
+}
+
+// Webhook events.
+const (
+	EventSnippetCreated = "snippet.created"
+	EventCommentCreated = "comment.created"
+	EventCommentVoted   = "comment.voted"
+)
+
+// WebhookEvents are every event a webhook can subscribe to.
+var WebhookEvents = []string{EventSnippetCreated, EventCommentCreated, EventCommentVoted}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDeliveryRetention is how long finished deliveries are kept in the
+// log before PurgeDeliveries removes them.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+// Webhook is a URL a user registered to be sent events as they happen. A
+// user's webhooks hear of events on their own snippets; an admin's also hear
+// of events on everyone's public snippets. Secret signs the payloads.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+// Subscribed reports whether the webhook is sent event.
+func (w *Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event to send to a webhook, and what came of it.
+// URL and Secret are those of the webhook, and are only set by Due.
+type WebhookDelivery struct {
+	ID           int64
+	WebhookID    int
+	URL          string
+	Secret       string
+	Event        string
+	Payload      string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	Created      time.Time
+	NextAttempt  time.Time
+	// Finished is zero while the delivery is pending.
+	Finished time.Time
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Insert registers a webhook for the user with a new random secret and
+// returns it. It returns ErrInvalidEvent if events is empty or holds an
+// event not in WebhookEvents.
+func (m *WebhookModel) Insert(userID int, url string, events []string) (*Webhook, error) {
+	if len(events) == 0 {
+		return nil, ErrInvalidEvent
+	}
+	for _, e := range events {
+		if e != EventSnippetCreated && e != EventCommentCreated && e != EventCommentVoted {
+			return nil, ErrInvalidEvent
+		}
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO webhooks (user_id, url, secret, events, created) VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, userID, url, hex.EncodeToString(b), strings.Join(events, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(int(id))
+}
+
+// Get returns a webhook, or ErrNoRecord if there is none with that ID.
+func (m *WebhookModel) Get(id int) (*Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, events, created FROM webhooks WHERE id = ?`
+
+	w, err := scanWebhook(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// ForUser returns the user's webhooks, the most recent first.
+func (m *WebhookModel) ForUser(userID int) ([]*Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, events, created FROM webhooks
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	w := &Webhook{}
+	var events string
+
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &events, &w.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Events = strings.Fields(events)
+	return w, nil
+}
+
+// Delete removes one of the user's webhooks along with its deliveries, sent
+// or not. It returns ErrNoRecord if the user has no webhook with that ID.
+func (m *WebhookModel) Delete(userID, id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Enqueue queues payload for every webhook subscribed to event that belongs
+// to ownerID, the owner of the snippet the event happened on, or, if the
+// snippet is public, to an admin. It returns how many deliveries were
+// queued. The deliveries are due at once.
+func (m *WebhookModel) Enqueue(event string, ownerID int, public bool, payload []byte) (int, error) {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, created, next_attempt)
+	SELECT w.id, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP() FROM webhooks w
+	INNER JOIN users u ON u.id = w.user_id
+	WHERE FIND_IN_SET(?, REPLACE(w.events, ' ', ',')) > 0
+	AND (w.user_id = ? OR (? AND u.role = 'admin'))`
+
+	result, err := m.DB.Exec(stmt, event, string(payload), event, ownerID, public)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// Due returns up to limit pending deliveries whose next attempt has come,
+// the oldest first, with the URL and secret of their webhook.
+func (m *WebhookModel) Due(limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status, d.attempts,
+	d.response_code, d.error, d.created, d.next_attempt, d.finished
+	FROM webhook_deliveries d
+	INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = 'pending' AND d.next_attempt <= UTC_TIMESTAMP()
+	ORDER BY d.id LIMIT ?`
+
+	return m.queryDeliveries(stmt, limit)
+}
+
+// Delivered records that a delivery was accepted with responseCode.
+func (m *WebhookModel) Delivered(id int64, responseCode int) error {
+	stmt := `UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1,
+	response_code = ?, error = '', finished = UTC_TIMESTAMP() WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, responseCode, id)
+	return err
+}
+
+// Failed records a failed attempt at a delivery: the response code, or 0 if
+// there was no response, and why it failed. The delivery is tried again at
+// retryAt, or given up on if retryAt is zero.
+func (m *WebhookModel) Failed(id int64, responseCode int, reason string, retryAt time.Time) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+
+	if retryAt.IsZero() {
+		stmt := `UPDATE webhook_deliveries SET status = 'failed', attempts = attempts + 1,
+		response_code = ?, error = ?, finished = UTC_TIMESTAMP() WHERE id = ?`
+
+		_, err := m.DB.Exec(stmt, responseCode, reason, id)
+		return err
+	}
+
+	stmt := `UPDATE webhook_deliveries SET attempts = attempts + 1,
+	response_code = ?, error = ?, next_attempt = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, responseCode, reason, retryAt.UTC(), id)
+	return err
+}
+
+// Deliveries returns the latest limit deliveries of a webhook, the most
+// recent first.
+func (m *WebhookModel) Deliveries(webhookID int, limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT d.id, d.webhook_id, '', '', d.event, d.payload, d.status, d.attempts,
+	d.response_code, d.error, d.created, d.next_attempt, d.finished
+	FROM webhook_deliveries d
+	WHERE d.webhook_id = ?
+	ORDER BY d.id DESC LIMIT ?`
+
+	return m.queryDeliveries(stmt, webhookID, limit)
+}
+
+func (m *WebhookModel) queryDeliveries(stmt string, args ...any) ([]*WebhookDelivery, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		var finished sql.NullTime
+
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseCode, &d.Error, &d.Created, &d.NextAttempt, &finished)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Finished = finished.Time
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// PurgeDeliveries removes the deliveries that finished more than
+// WebhookDeliveryRetention ago and returns how many went.
+func (m *WebhookModel) PurgeDeliveries() (int, error) {
+	stmt := `DELETE FROM webhook_deliveries
+	WHERE status <> 'pending' AND finished < UTC_TIMESTAMP() - INTERVAL ? SECOND`
+
+	result, err := m.DB.Exec(stmt, int(WebhookDeliveryRetention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
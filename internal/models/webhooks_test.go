@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestWebhookModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := &WebhookModel{DB: db}
+
+	_, err := m.Insert(1, "https://example.com/hook", []string{"snippet.deleted"})
+	assert.Equal(t, err, ErrInvalidEvent)
+
+	w, err := m.Insert(1, "https://example.com/hook", []string{EventCommentCreated})
+	assert.NilError(t, err)
+	assert.Equal(t, len(w.Secret), 64)
+	assert.Equal(t, w.Subscribed(EventCommentCreated), true)
+	assert.Equal(t, w.Subscribed(EventSnippetCreated), false)
+
+	// A second user, an admin, with a webhook for every event
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created, role)
+	VALUES ('Bob', 'bob@example.com', '', UTC_TIMESTAMP(), 'admin')`)
+	assert.NilError(t, err)
+	admin, err := m.Insert(2, "https://example.com/admin", WebhookEvents)
+	assert.NilError(t, err)
+
+	// Only subscribed webhooks get a delivery, and the admin's only for
+	// public snippets
+	n, err := m.Enqueue(EventSnippetCreated, 1, true, []byte(`{}`))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.Enqueue(EventCommentCreated, 1, false, []byte(`{"private": true}`))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.Enqueue(EventCommentCreated, 1, true, []byte(`{"private": false}`))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	due, err := m.Due(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 4)
+	assert.Equal(t, due[0].URL, admin.URL)
+	assert.Equal(t, due[0].Secret, admin.Secret)
+
+	// A failed delivery waits for its retry, and a given up one is done
+	assert.NilError(t, m.Failed(due[0].ID, 500, "Internal Server Error", time.Now().Add(time.Hour)))
+	assert.NilError(t, m.Failed(due[1].ID, 0, "connection refused", time.Time{}))
+	for _, d := range due[2:] {
+		if d.WebhookID == admin.ID {
+			assert.NilError(t, m.Delivered(d.ID, 204))
+		}
+	}
+
+	due, err = m.Due(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+
+	log, err := m.Deliveries(w.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(log), 2)
+	assert.Equal(t, log[0].Status, DeliveryPending)
+	assert.Equal(t, log[1].Status, DeliveryFailed)
+	assert.Equal(t, log[1].Error, "connection refused")
+	assert.Equal(t, log[1].Attempts, 1)
+	assert.Equal(t, log[1].Finished.IsZero(), false)
+
+	// Recent deliveries are kept
+	n, err = m.PurgeDeliveries()
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	// Only the webhook's user can delete it
+	assert.Equal(t, m.Delete(2, w.ID), ErrNoRecord)
+	assert.NilError(t, m.Delete(1, w.ID))
+
+	_, err = m.Get(w.ID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	log, err = m.Deliveries(w.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(log), 0)
+}
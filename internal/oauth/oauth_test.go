@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	g := NewGitHub("client-id", "secret")
+
+	u, err := url.Parse(g.AuthCodeURL("some-state", "https://example.com/callback"))
+	assert.NilError(t, err)
+
+	q := u.Query()
+	assert.Equal(t, u.Host, "github.com")
+	assert.Equal(t, q.Get("client_id"), "client-id")
+	assert.Equal(t, q.Get("state"), "some-state")
+	assert.Equal(t, q.Get("redirect_uri"), "https://example.com/callback")
+	assert.Equal(t, q.Get("response_type"), "code")
+}
+
+func TestGitHubExchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("client_secret") != "secret" {
+			w.Write([]byte(`{"error":"bad_verification_code"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"access"}`))
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":1001,"login":"octocat","name":""}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"email":"old@example.com","primary":false,"verified":true},{"email":"octo@example.com","primary":true,"verified":true}]`))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	g := NewGitHub("client-id", "secret")
+	g.TokenURL = srv.URL + "/token"
+	g.APIURL = srv.URL
+
+	id, err := g.Exchange(context.Background(), "good-code", "https://example.com/callback")
+	assert.NilError(t, err)
+	assert.Equal(t, *id, Identity{ID: "1001", Name: "octocat", Email: "octo@example.com", EmailVerified: true})
+
+	_, err = g.Exchange(context.Background(), "bad-code", "https://example.com/callback")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestGoogleExchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"access"}`))
+	})
+	mux.HandleFunc("/v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sub":"42","name":"Jane","email":"jane@example.com","email_verified":true}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	g := NewGoogle("client-id", "secret")
+	g.TokenURL = srv.URL + "/token"
+	g.APIURL = srv.URL
+
+	id, err := g.Exchange(context.Background(), "code", "https://example.com/callback")
+	assert.NilError(t, err)
+	assert.Equal(t, *id, Identity{ID: "42", Name: "Jane", Email: "jane@example.com", EmailVerified: true})
+}
@@ -0,0 +1,101 @@
+// Package pubsub is an in-process publish/subscribe hub. Subscribers get the
+// messages published to a topic after they subscribed, in order, on a
+// buffered channel of their own.
+package pubsub
+
+import "sync"
+
+// Hub passes messages of type T published to topics of type K on to the
+// subscribers of each topic. Publishing never waits on a subscriber: one
+// whose buffer is full has its channel closed and is dropped, so that it can
+// tell it missed messages. The zero value is not usable; use New.
+type Hub[K comparable, T any] struct {
+	mu     sync.Mutex
+	subs   map[K]map[chan T]struct{}
+	closed bool
+}
+
+// New returns an empty hub.
+func New[K comparable, T any]() *Hub[K, T] {
+	return &Hub[K, T]{subs: make(map[K]map[chan T]struct{})}
+}
+
+// Subscribe returns a channel of the messages published to topic from now
+// on, holding up to buffer of them, and a function that cancels the
+// subscription and closes the channel. The channel is also closed when the
+// subscriber falls behind or the hub is closed. Subscribing to a closed hub
+// returns a closed channel.
+func (h *Hub[K, T]) Subscribe(topic K, buffer int) (<-chan T, func()) {
+	ch := make(chan T, buffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if h.subs[topic] == nil {
+		h.subs[topic] = make(map[chan T]struct{})
+	}
+	h.subs[topic][ch] = struct{}{}
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.drop(topic, ch)
+	}
+
+	return ch, cancel
+}
+
+// Publish sends msg to the subscribers of topic.
+func (h *Hub[K, T]) Publish(topic K, msg T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[topic] {
+		select {
+		case ch <- msg:
+		default:
+			h.drop(topic, ch)
+		}
+	}
+}
+
+// Subscribers returns how many subscribers topic has.
+func (h *Hub[K, T]) Subscribers(topic K) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs[topic])
+}
+
+// Close closes the channels of every subscriber, for them to stop, and
+// those of any that subscribe later.
+func (h *Hub[K, T]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic, subs := range h.subs {
+		for ch := range subs {
+			h.drop(topic, ch)
+		}
+	}
+	h.closed = true
+}
+
+// drop closes a subscriber's channel, unless it was dropped already. h.mu
+// must be held.
+func (h *Hub[K, T]) drop(topic K, ch chan T) {
+	if _, ok := h.subs[topic][ch]; !ok {
+		return
+	}
+
+	delete(h.subs[topic], ch)
+	if len(h.subs[topic]) == 0 {
+		delete(h.subs, topic)
+	}
+	close(ch)
+}
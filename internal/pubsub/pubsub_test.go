@@ -0,0 +1,49 @@
+package pubsub
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestHub(t *testing.T) {
+	h := New[int, string]()
+
+	a, cancelA := h.Subscribe(1, 2)
+	b, cancelB := h.Subscribe(1, 2)
+	other, cancelOther := h.Subscribe(2, 2)
+	defer cancelOther()
+
+	h.Publish(1, "hello")
+	assert.Equal(t, <-a, "hello")
+	assert.Equal(t, <-b, "hello")
+	assert.Equal(t, len(other), 0)
+
+	// A cancelled subscriber gets nothing more
+	cancelB()
+	cancelB()
+	_, ok := <-b
+	assert.Equal(t, ok, false)
+	assert.Equal(t, h.Subscribers(1), 1)
+
+	// One that falls behind is dropped
+	h.Publish(1, "one")
+	h.Publish(1, "two")
+	h.Publish(1, "three")
+	assert.Equal(t, <-a, "one")
+	assert.Equal(t, <-a, "two")
+	_, ok = <-a
+	assert.Equal(t, ok, false)
+	assert.Equal(t, h.Subscribers(1), 0)
+	cancelA()
+
+	// Closing ends every subscription, and later ones at once
+	h.Close()
+	_, ok = <-other
+	assert.Equal(t, ok, false)
+
+	c, cancelC := h.Subscribe(1, 1)
+	defer cancelC()
+	_, ok = <-c
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,93 @@
+// Package ratelimit implements token bucket rate limiting for any number of
+// keys, such as IP addresses or user IDs, each with a bucket of its own.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to n events per key at once, then refills each key's
+// bucket at n events per period. The zero value is not usable; use New.
+type Limiter struct {
+	n      int
+	period time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing n events per period for each key. If n is
+// zero or less, the limiter allows everything.
+func New(n int, period time.Duration) *Limiter {
+	return &Limiter{
+		n:       n,
+		period:  period,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether an event for key may happen now and, if so, takes a
+// token from its bucket. If not, it also returns how long until it may.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.n <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.n), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate()
+	if b.tokens > float64(l.n) {
+		b.tokens = float64(l.n)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate() * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// rate returns the number of tokens added to a bucket each second.
+func (l *Limiter) rate() float64 {
+	return float64(l.n) / l.period.Seconds()
+}
+
+// sweep forgets the buckets that have been refilled by now, as a full bucket
+// is the same as none. It runs at most once a period, so the map only holds
+// the keys seen recently.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.period {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.period {
+			delete(l.buckets, key)
+		}
+	}
+}
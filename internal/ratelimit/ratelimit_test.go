@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	// A full bucket allows a burst of n
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.Equal(t, ok, true)
+	}
+
+	ok, wait := l.Allow("a")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, wait, 20*time.Second)
+
+	// Other keys have buckets of their own
+	ok, _ = l.Allow("b")
+	assert.Equal(t, ok, true)
+
+	// One token comes back every period/n
+	now = now.Add(20 * time.Second)
+	ok, _ = l.Allow("a")
+	assert.Equal(t, ok, true)
+	ok, _ = l.Allow("a")
+	assert.Equal(t, ok, false)
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.Equal(t, ok, true)
+	}
+	ok, _ = l.Allow("a")
+	assert.Equal(t, ok, false)
+}
+
+func TestAllowDisabled(t *testing.T) {
+	l := New(0, time.Minute)
+
+	for i := 0; i < 100; i++ {
+		ok, _ := l.Allow("a")
+		assert.Equal(t, ok, true)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(30 * time.Second)
+	l.Allow("b")
+	assert.Equal(t, len(l.buckets), 2)
+
+	now = now.Add(45 * time.Second)
+	l.Allow("c")
+	assert.Equal(t, len(l.buckets), 2)
+
+	_, ok := l.buckets["a"]
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,83 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Akismet is a Checker that asks the Akismet comment-check API. It needs the
+// Source of the comment in the context, as Akismet will not check a comment
+// without the commenter's IP address.
+type Akismet struct {
+	Key string
+	// Site is the front page of the site the comments are posted on.
+	Site   string
+	Client *http.Client
+	// Endpoint overrides the comment-check URL, which is otherwise derived
+	// from Key.
+	Endpoint string
+}
+
+// IsSpam reports whether Akismet takes c for spam.
+func (a *Akismet) IsSpam(ctx context.Context, c Comment) (bool, error) {
+	src, ok := SourceFrom(ctx)
+	if !ok {
+		return false, fmt.Errorf("spam: no request source to send to Akismet")
+	}
+
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + a.Key + ".rest.akismet.com/1.1/comment-check"
+	}
+
+	form := url.Values{
+		"blog":            {a.Site},
+		"user_ip":         {src.IP},
+		"user_agent":      {src.UserAgent},
+		"referrer":        {src.Referrer},
+		"comment_type":    {"comment"},
+		"comment_author":  {c.Author},
+		"comment_content": {c.Content},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	rs, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(rs.Body, 64))
+	if err != nil {
+		return false, err
+	}
+
+	// Akismet answers "true" or "false", and anything else on an error, with
+	// the details in a header
+	switch strings.TrimSpace(string(body)) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	help := rs.Header.Get("X-akismet-debug-help")
+	if help == "" {
+		help = rs.Status
+	}
+	return false, fmt.Errorf("spam: Akismet: %s", help)
+}
@@ -0,0 +1,171 @@
+// Package spam decides whether a new comment should wait for a moderator
+// before it is published. A Pipeline runs a comment past a list of filters,
+// each of which may flag it with a reason; the first reason given wins.
+package spam
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Comment is what the filters get to see of a new comment.
+type Comment struct {
+	SnippetID int
+	AuthorID  int
+	Author    string
+	Content   string
+}
+
+// Filter checks a new comment. It returns the reason the comment looks like
+// spam, or "" if it does not.
+type Filter interface {
+	Check(ctx context.Context, c Comment) (string, error)
+}
+
+// Pipeline is a Filter that runs its filters in order and stops at the
+// first one to flag the comment or fail.
+type Pipeline []Filter
+
+// Check returns the reason of the first filter to flag c.
+func (p Pipeline) Check(ctx context.Context, c Comment) (string, error) {
+	for _, f := range p {
+		reason, err := f.Check(ctx, c)
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+
+	return "", nil
+}
+
+// BannedWords flags comments that use any of its words, which must be in
+// lower case. Words are matched whole and regardless of case, so "class" does
+// not trip over a ban on "ass".
+type BannedWords []string
+
+// ParseWords returns the comma-separated words in s as BannedWords, leaving
+// out blanks.
+func ParseWords(s string) BannedWords {
+	var words BannedWords
+	for _, w := range strings.Split(s, ",") {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// Check flags c if it uses a banned word.
+func (b BannedWords) Check(ctx context.Context, c Comment) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+
+	used := make(map[string]bool)
+	for _, w := range words(c.Content) {
+		used[w] = true
+	}
+
+	for _, w := range b {
+		if used[w] {
+			return "Uses a banned word", nil
+		}
+	}
+
+	return "", nil
+}
+
+// words splits s into lower-case words of letters and digits.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+var linkRX = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// LinkLimit flags comments with more links than its value.
+type LinkLimit int
+
+// Check flags c if it has more links than l.
+func (l LinkLimit) Check(ctx context.Context, c Comment) (string, error) {
+	if len(linkRX.FindAllStringIndex(c.Content, int(l)+1)) > int(l) {
+		return "Has too many links", nil
+	}
+
+	return "", nil
+}
+
+// Duplicates flags comments that repeat one the same author posted
+// recently. Recent returns the content of those earlier comments; what
+// counts as recent is up to it. Comments are compared ignoring case and
+// spacing.
+type Duplicates struct {
+	Recent func(ctx context.Context, c Comment) ([]string, error)
+}
+
+// Check flags c if Recent returns a comment with the same content.
+func (d Duplicates) Check(ctx context.Context, c Comment) (string, error) {
+	recent, err := d.Recent(ctx, c)
+	if err != nil {
+		return "", err
+	}
+
+	content := normalize(c.Content)
+	for _, r := range recent {
+		if normalize(r) == content {
+			return "Repeats an earlier comment", nil
+		}
+	}
+
+	return "", nil
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Checker is an outside service, such as Akismet, that tells whether a
+// comment is spam.
+type Checker interface {
+	IsSpam(ctx context.Context, c Comment) (bool, error)
+}
+
+// External is a Filter that asks a Checker.
+type External struct {
+	Checker Checker
+}
+
+// Check flags c if the checker says it is spam.
+func (e External) Check(ctx context.Context, c Comment) (string, error) {
+	spam, err := e.Checker.IsSpam(ctx, c)
+	if err != nil || !spam {
+		return "", err
+	}
+
+	return "Flagged by the spam checker", nil
+}
+
+// Source describes the request a comment came in with, for checkers that
+// take it into account.
+type Source struct {
+	IP        string
+	UserAgent string
+	Referrer  string
+}
+
+type sourceKey struct{}
+
+// NewContext returns a copy of ctx that carries src.
+func NewContext(ctx context.Context, src Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, src)
+}
+
+// SourceFrom returns the Source carried by ctx, if any.
+func SourceFrom(ctx context.Context) (Source, bool) {
+	src, ok := ctx.Value(sourceKey{}).(Source)
+	return src, ok
+}
@@ -0,0 +1,79 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	recent := Duplicates{Recent: func(ctx context.Context, c Comment) ([]string, error) {
+		if c.AuthorID == 9 {
+			return nil, errors.New("no history")
+		}
+		return []string{"Nice  haiku!"}, nil
+	}}
+
+	p := Pipeline{ParseWords(" Casino, ,viagra"), LinkLimit(1), recent}
+
+	tests := []struct {
+		name       string
+		comment    Comment
+		wantReason string
+		wantErr    bool
+	}{
+		{"Clean", Comment{Content: "A classic haiku, see https://example.com"}, "", false},
+		{"Banned word", Comment{Content: "Best CASINO in town"}, "Uses a banned word", false},
+		{"Banned word inside another", Comment{Content: "Casinos are not banned"}, "", false},
+		{"Too many links", Comment{Content: "http://a.example and www.b.example"}, "Has too many links", false},
+		{"Duplicate", Comment{Content: "nice haiku!"}, "Repeats an earlier comment", false},
+		{"Failing filter", Comment{AuthorID: 9, Content: "Hello"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := p.Check(context.Background(), tt.comment)
+			assert.Equal(t, reason, tt.wantReason)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestAkismet(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.PostFormValue("comment_author") {
+		case "spammer":
+			w.Write([]byte("true"))
+		case "ham":
+			w.Write([]byte("false"))
+		default:
+			w.Header().Set("X-akismet-debug-help", "Empty \"comment_author\" value")
+			w.Write([]byte("invalid"))
+		}
+	}))
+	defer ts.Close()
+
+	a := &Akismet{Key: "key", Site: "https://snippetbox.example", Client: ts.Client(), Endpoint: ts.URL}
+	f := External{Checker: a}
+
+	ctx := NewContext(context.Background(), Source{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"})
+
+	reason, err := f.Check(ctx, Comment{Author: "spammer", Content: "Buy now"})
+	assert.NilError(t, err)
+	assert.Equal(t, reason, "Flagged by the spam checker")
+
+	reason, err = f.Check(ctx, Comment{Author: "ham", Content: "Lovely"})
+	assert.NilError(t, err)
+	assert.Equal(t, reason, "")
+
+	_, err = f.Check(ctx, Comment{Content: "Lovely"})
+	assert.Equal(t, err.Error(), `spam: Akismet: Empty "comment_author" value`)
+
+	// Without the request it came in with, the comment cannot be checked
+	_, err = a.IsSpam(context.Background(), Comment{Author: "ham"})
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,115 @@
+// Package storage keeps uploaded files by key. Handlers depend on the Store
+// interface, so that files can move from the local disk to an object store
+// such as S3 without them changing.
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned for a key that has no file.
+var ErrNotFound = errors.New("storage: file not found")
+
+// ErrInvalidKey is returned for a key that NewKey could not have made.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+var keyRX = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Store keeps files by key. Put replaces any file already kept under the key,
+// and Delete of a key with no file is not an error.
+type Store interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// NewKey returns a new random key ending in ext, such as ".png".
+func NewKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b) + ext, nil
+}
+
+// Disk keeps each file under Dir, named after its key. Dir is created on the
+// first Put.
+type Disk struct {
+	Dir string
+}
+
+// Put writes the file to a temporary name and renames it into place, so that
+// Get never sees half of it.
+func (d *Disk) Put(key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(d.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Get opens the file kept under key. The caller must close it.
+func (d *Disk) Get(key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return f, err
+}
+
+// Delete removes the file kept under key.
+func (d *Disk) Delete(key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// path returns where the file for key lives, refusing keys that could reach
+// outside Dir.
+func (d *Disk) path(key string) (string, error) {
+	if !keyRX.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(d.Dir, key), nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestDisk(t *testing.T) {
+	d := &Disk{Dir: t.TempDir() + "/uploads"}
+
+	key, err := NewKey(".png")
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasSuffix(key, ".png"), true)
+
+	_, err = d.Get(key)
+	assert.Equal(t, err, ErrNotFound)
+
+	assert.NilError(t, d.Put(key, strings.NewReader("first")))
+	assert.NilError(t, d.Put(key, strings.NewReader("second")))
+
+	f, err := d.Get(key)
+	assert.NilError(t, err)
+	b, err := io.ReadAll(f)
+	f.Close()
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "second")
+
+	assert.NilError(t, d.Delete(key))
+	assert.NilError(t, d.Delete(key))
+
+	_, err = d.Get(key)
+	assert.Equal(t, err, ErrNotFound)
+}
+
+func TestDiskInvalidKey(t *testing.T) {
+	d := &Disk{Dir: t.TempDir()}
+
+	for _, key := range []string{"", "../secret", "a/b", ".hidden", "UPPER"} {
+		t.Run(key, func(t *testing.T) {
+			assert.Equal(t, d.Put(key, strings.NewReader("x")), ErrInvalidKey)
+
+			_, err := d.Get(key)
+			assert.Equal(t, err, ErrInvalidKey)
+			assert.Equal(t, d.Delete(key), ErrInvalidKey)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Package syntax does simple server-side syntax highlighting of code
+// snippets. It recognises comments, strings, numbers and keywords for a small
+// set of languages and wraps them in <span> tags with hl-* classes; everything
+// else is escaped and passed through unchanged.
+package syntax
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Language describes a language that can be highlighted.
+type Language struct {
+	Name      string
+	Label     string
+	Extension string
+
+	lineComments  []string
+	blockComments [][2]string
+	quotes        string
+	keywords      map[string]bool
+	caseFold      bool
+}
+
+// Languages lists the supported languages in the order they should be
+// offered to users.
+var Languages = []*Language{
+	{
+		Name:          "go",
+		Label:         "Go",
+		Extension:     ".go",
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		keywords: words(`break case chan const continue default defer else fallthrough
+			for func go goto if import interface map package range return select
+			struct switch type var true false nil iota`),
+	},
+	{
+		Name:         "python",
+		Label:        "Python",
+		Extension:    ".py",
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		keywords: words(`and as assert async await break class continue def del elif
+			else except finally for from global if import in is lambda nonlocal
+			not or pass raise return try while with yield True False None`),
+	},
+	{
+		Name:          "javascript",
+		Label:         "JavaScript",
+		Extension:     ".js",
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		keywords: words(`async await break case catch class const continue debugger
+			default delete do else export extends finally for function if import
+			in instanceof let new of return super switch this throw try typeof var
+			void while yield true false null undefined`),
+	},
+	{
+		Name:          "sql",
+		Label:         "SQL",
+		Extension:     ".sql",
+		lineComments:  []string{"--", "#"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "'\"`",
+		caseFold:      true,
+		keywords: words(`select from where and or not insert into values update set
+			delete create table drop alter index primary key foreign references
+			join left right inner outer on group by order having limit offset as
+			distinct union all null is in like between case when then else end
+			default exists`),
+	},
+	{
+		Name:         "bash",
+		Label:        "Shell",
+		Extension:    ".sh",
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		keywords: words(`if then else elif fi for while until do done case esac in
+			function return local export echo exit`),
+	},
+}
+
+// Lookup returns the language with the given name, or nil if it is not
+// supported.
+func Lookup(name string) *Language {
+	for _, lang := range Languages {
+		if lang.Name == name {
+			return lang
+		}
+	}
+	return nil
+}
+
+// Supported reports whether name is a supported language. The empty name,
+// meaning plain text, is supported.
+func Supported(name string) bool {
+	return name == "" || Lookup(name) != nil
+}
+
+// Extension returns the file name extension for the named language, or
+// ".txt" for plain text and unsupported languages.
+func Extension(name string) string {
+	if lang := Lookup(name); lang != nil {
+		return lang.Extension
+	}
+	return ".txt"
+}
+
+// Names returns the names of the supported languages.
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, lang := range Languages {
+		names[i] = lang.Name
+	}
+	return names
+}
+
+// Render returns code as escaped HTML, highlighted for the named language.
+// Code in an unsupported language is only escaped.
+func Render(code, language string) string {
+	lang := Lookup(language)
+	if lang == nil {
+		return html.EscapeString(code)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		n, class := lang.token(code[i:])
+		text := html.EscapeString(code[i : i+n])
+		if class != "" {
+			text = `<span class="hl-` + class + `">` + text + `</span>`
+		}
+		b.WriteString(text)
+		i += n
+	}
+
+	return b.String()
+}
+
+// token returns the length and class of the token at the start of s. A
+// class of "" means s starts with text that is not highlighted.
+func (lang *Language) token(s string) (int, string) {
+	for _, delim := range lang.blockComments {
+		if strings.HasPrefix(s, delim[0]) {
+			end := strings.Index(s[len(delim[0]):], delim[1])
+			if end < 0 {
+				return len(s), "comment"
+			}
+			return len(delim[0]) + end + len(delim[1]), "comment"
+		}
+	}
+
+	for _, prefix := range lang.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			end := strings.IndexByte(s, '\n')
+			if end < 0 {
+				return len(s), "comment"
+			}
+			return end, "comment"
+		}
+	}
+
+	c := s[0]
+
+	if strings.IndexByte(lang.quotes, c) >= 0 {
+		return stringLength(s), "string"
+	}
+
+	if isDigit(c) {
+		n := 1
+		for n < len(s) && (isWord(s[n]) || s[n] == '.') {
+			n++
+		}
+		return n, "number"
+	}
+
+	if isWord(c) {
+		n := 1
+		for n < len(s) && isWord(s[n]) {
+			n++
+		}
+		word := s[:n]
+		if lang.caseFold {
+			word = strings.ToLower(word)
+		}
+		if lang.keywords[word] {
+			return n, "keyword"
+		}
+		return n, ""
+	}
+
+	return 1, ""
+}
+
+// stringLength returns the length of the string literal at the start of s,
+// including its quotes. Backslash escapes are honoured except in backtick
+// strings; a string with no closing quote runs to the end of the line.
+func stringLength(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || c >= 0x80 || unicode.IsLetter(rune(c))
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
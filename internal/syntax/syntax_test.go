@@ -0,0 +1,76 @@
+package syntax
+
+import (
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		want     string
+	}{
+		{
+			name: "Plain text",
+			code: "if a < b",
+			want: "if a &lt; b",
+		},
+		{
+			name:     "Unsupported language",
+			code:     "if a < b",
+			language: "cobol",
+			want:     "if a &lt; b",
+		},
+		{
+			name:     "Go",
+			code:     "func f() int { return 42 } // done",
+			language: "go",
+			want:     `<span class="hl-keyword">func</span> f() int { <span class="hl-keyword">return</span> <span class="hl-number">42</span> } <span class="hl-comment">// done</span>`,
+		},
+		{
+			name:     "Escaped quote in string",
+			code:     `x = "a \"<b>\" c"`,
+			language: "python",
+			want:     `x = <span class="hl-string">&#34;a \&#34;&lt;b&gt;\&#34; c&#34;</span>`,
+		},
+		{
+			name:     "Unterminated string",
+			code:     "echo 'oops\nls",
+			language: "bash",
+			want:     "<span class=\"hl-keyword\">echo</span> <span class=\"hl-string\">&#39;oops</span>\nls",
+		},
+		{
+			name:     "Block comment",
+			code:     "/* a\nb */x",
+			language: "javascript",
+			want:     "<span class=\"hl-comment\">/* a\nb */</span>x",
+		},
+		{
+			name:     "Case-insensitive keywords",
+			code:     "Select id_1 FROM t",
+			language: "sql",
+			want:     `<span class="hl-keyword">Select</span> id_1 <span class="hl-keyword">FROM</span> t`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Render(tt.code, tt.language), tt.want)
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	assert.Equal(t, Supported(""), true)
+	assert.Equal(t, Supported("go"), true)
+	assert.Equal(t, Supported("cobol"), false)
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, Extension("python"), ".py")
+	assert.Equal(t, Extension(""), ".txt")
+	assert.Equal(t, Extension("cobol"), ".txt")
+}
@@ -0,0 +1,151 @@
+// Package thumbnail checks uploaded images and scales them down for
+// previews. PNG, JPEG and GIF are supported; only the first frame of an
+// animated GIF is used.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels is the largest image, in pixels, that Decode accepts, so that a
+// small file cannot ask for gigabytes of memory once decoded.
+const MaxPixels = 40_000_000
+
+var (
+	// ErrFormat is returned for data that is not a supported image.
+	ErrFormat = errors.New("thumbnail: unsupported image format")
+	// ErrTooLarge is returned for images of more than MaxPixels.
+	ErrTooLarge = errors.New("thumbnail: image too large")
+)
+
+// ContentTypes maps the formats Decode accepts to their media type.
+var ContentTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+}
+
+// Image is a decoded image and the format it was in.
+type Image struct {
+	image.Image
+	Format string
+}
+
+// Decode reads an image, checking its size before decoding all of it.
+func Decode(b []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil || ContentTypes[format] == "" {
+		return nil, ErrFormat
+	}
+
+	if cfg.Width < 1 || cfg.Height < 1 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	return &Image{Image: img, Format: format}, nil
+}
+
+// Thumbnail scales the image to fit within max by max pixels, keeping its
+// aspect ratio, and encodes it. Photos stay JPEG; everything else becomes a
+// PNG, keeping any transparency. It returns the encoded thumbnail and its
+// media type.
+func (img *Image) Thumbnail(max int) ([]byte, string, error) {
+	thumb := Scale(img.Image, max)
+
+	var b bytes.Buffer
+
+	if img.Format == "jpeg" {
+		if err := jpeg.Encode(&b, thumb, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return b.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&b, thumb); err != nil {
+		return nil, "", err
+	}
+	return b.Bytes(), "image/png", nil
+}
+
+// Scale returns a copy of src that fits within max by max pixels. Each pixel
+// of the copy averages the block of src it covers. Images that fit already
+// are copied at their size.
+func Scale(src image.Image, max int) *image.RGBA {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+
+	dw, dh := sw, sh
+	if sw > max || sh > max {
+		if sw >= sh {
+			dw, dh = max, sh*max/sw
+		} else {
+			dw, dh = sw*max/sh, max
+		}
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0 := bounds.Min.Y + y*sh/dh
+		y1 := bounds.Min.Y + (y+1)*sh/dh
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < dw; x++ {
+			x0 := bounds.Min.X + x*sw/dw
+			x1 := bounds.Min.X + (x+1)*sw/dw
+			if x1 == x0 {
+				x1++
+			}
+
+			dst.SetRGBA(x, y, average(src, x0, y0, x1, y1))
+		}
+	}
+
+	return dst
+}
+
+// average returns the mean colour of the block from x0, y0 up to x1, y1,
+// sampling at most 8 by 8 of its pixels so that huge images stay quick.
+func average(src image.Image, x0, y0, x1, y1 int) color.RGBA {
+	stepX := (x1 - x0 + 7) / 8
+	stepY := (y1 - y0 + 7) / 8
+
+	var r, g, b, a, n uint64
+
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			pr, pg, pb, pa := src.At(x, y).RGBA()
+			r += uint64(pr)
+			g += uint64(pg)
+			b += uint64(pb)
+			a += uint64(pa)
+			n++
+		}
+	}
+
+	return color.RGBA{
+		R: uint8(r / n >> 8),
+		G: uint8(g / n >> 8),
+		B: uint8(b / n >> 8),
+		A: uint8(a / n >> 8),
+	}
+}
@@ -0,0 +1,124 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func encode(t *testing.T, format string, img image.Image) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	var err error
+
+	switch format {
+	case "png":
+		err = png.Encode(&b, img)
+	case "jpeg":
+		err = jpeg.Encode(&b, img, nil)
+	case "gif":
+		err = gif.Encode(&b, img, nil)
+	}
+	assert.NilError(t, err)
+
+	return b.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		t.Run(format, func(t *testing.T) {
+			decoded, err := Decode(encode(t, format, img))
+			assert.NilError(t, err)
+			assert.Equal(t, decoded.Format, format)
+			assert.Equal(t, decoded.Bounds().Dx(), 40)
+		})
+	}
+
+	t.Run("Not an image", func(t *testing.T) {
+		_, err := Decode([]byte("<svg></svg>"))
+		assert.Equal(t, err, ErrFormat)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		// A 1x1 GIF whose header claims it is 65535x65535
+		b := encode(t, "gif", image.NewGray(image.Rect(0, 0, 1, 1)))
+		copy(b[6:10], []byte{0xff, 0xff, 0xff, 0xff})
+
+		_, err := Decode(b)
+		assert.Equal(t, err, ErrTooLarge)
+	})
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"Wide", 400, 100, 200, 50},
+		{"Tall", 100, 400, 50, 200},
+		{"Small", 30, 20, 30, 20},
+		{"Thin", 1000, 1, 200, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Scale(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), 200)
+			assert.Equal(t, got.Bounds().Dx(), tt.wantW)
+			assert.Equal(t, got.Bounds().Dy(), tt.wantH)
+		})
+	}
+}
+
+func TestScaleAverages(t *testing.T) {
+	// Black and white stripes average to grey
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	got := Scale(src, 1).RGBAAt(0, 0)
+	assert.Equal(t, got, color.RGBA{R: 127, G: 127, B: 127, A: 255})
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+	}{
+		{"jpeg", "image/jpeg"},
+		{"png", "image/png"},
+		{"gif", "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			img, err := Decode(encode(t, tt.format, image.NewRGBA(image.Rect(0, 0, 640, 480))))
+			assert.NilError(t, err)
+
+			b, contentType, err := img.Thumbnail(200)
+			assert.NilError(t, err)
+			assert.Equal(t, contentType, tt.contentType)
+
+			thumb, _, err := image.Decode(bytes.NewReader(b))
+			assert.NilError(t, err)
+			assert.Equal(t, thumb.Bounds().Dx(), 200)
+			assert.Equal(t, thumb.Bounds().Dy(), 150)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+// rfcSecret is the SHA1 key used by the test vectors in RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC vectors are eight digits long; ours are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		assert.NilError(t, err)
+		assert.Equal(t, got, tt.want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := Match(rfcSecret, "081804", now)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, step, Step(now))
+
+	// One step of clock drift either way is allowed
+	_, ok = Match(rfcSecret, "081804", now.Add(Period))
+	assert.Equal(t, ok, true)
+	_, ok = Match(rfcSecret, "081804", now.Add(-Period))
+	assert.Equal(t, ok, true)
+
+	_, ok = Match(rfcSecret, "081804", now.Add(2*Period))
+	assert.Equal(t, ok, false)
+	_, ok = Match(rfcSecret, "000000", now)
+	assert.Equal(t, ok, false)
+	_, ok = Match(rfcSecret, "0818", now)
+	assert.Equal(t, ok, false)
+	_, ok = Match("not base32!", "081804", now)
+	assert.Equal(t, ok, false)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NilError(t, err)
+	assert.Equal(t, len(secret), 32)
+
+	other, err := GenerateSecret()
+	assert.NilError(t, err)
+	assert.Equal(t, secret != other, true)
+
+	_, err = Code(secret, 1)
+	assert.NilError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Snippetbox", "jay@email.com", "JBSWY3DPEHPK3PXP")
+
+	assert.Equal(t, strings.HasPrefix(uri, "otpauth://totp/Snippetbox:jay@email.com?"), true)
+	assert.StringContains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.StringContains(t, uri, "issuer=Snippetbox")
+}
@@ -1,8 +1,10 @@
 package validator
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -13,6 +15,13 @@ type Validator struct {
 
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX matches a single tag: letters, digits, "_", "+" and "-".
+var TagRX = regexp.MustCompile(`^[\p{L}\p{N}_+-]+$`)
+
+// FileNameRX matches a file name without a directory: ASCII letters, digits,
+// ".", "_" and "-", not starting with a dot.
+var FileNameRX = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
 // Valid returns true if FieldErrors is empty
 func (v *Validator) Valid() bool {
 	return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
@@ -42,6 +51,28 @@ func (v *Validator) CheckField(ok bool, key, message string) {
 	}
 }
 
+// TextLimits bound the length of a free text field, in characters. A zero
+// Max leaves the length unbounded.
+type TextLimits struct {
+	Min int
+	Max int
+}
+
+// CheckText adds an error for the field key if value is not sane text, is
+// blank, or is outside the limits. Surrounding spaces do not count towards
+// Min.
+func (v *Validator) CheckText(key, value string, limits TextLimits) {
+	v.CheckField(ValidUTF8(value), key, "This field must be valid UTF-8 text")
+	v.CheckField(NoControlChars(value), key, "This field cannot contain control characters")
+	v.CheckField(NotBlank(value), key, "This field cannot be blank")
+	if limits.Min > 0 {
+		v.CheckField(MinChars(strings.TrimSpace(value), limits.Min), key, fmt.Sprintf("This field must be at least %d characters long", limits.Min))
+	}
+	if limits.Max > 0 {
+		v.CheckField(MaxChars(value, limits.Max), key, fmt.Sprintf("This field cannot be more than %d characters long", limits.Max))
+	}
+}
+
 // NotBlank returns true if a value is not an empty string.
 func NotBlank(value string) bool {
 	return strings.TrimSpace(value) != ""
@@ -57,6 +88,22 @@ func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
 }
 
+// ValidUTF8 returns true if a value is valid UTF-8.
+func ValidUTF8(value string) bool {
+	return utf8.ValidString(value)
+}
+
+// NoControlChars returns true if a value has no control characters other
+// than tabs and line breaks.
+func NoControlChars(value string) bool {
+	for _, r := range value {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
 // PermittedInt returns true if a value is in a list of permitted integers.
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	for i := range permittedValues {
@@ -0,0 +1,38 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.jmorelli.dev/internal/assert"
+)
+
+func TestCheckText(t *testing.T) {
+	limits := TextLimits{Min: 3, Max: 5}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Valid", "héllo", ""},
+		{"Line breaks and tabs", "a\r\n\tb", ""},
+		{"Blank", " \n ", "This field cannot be blank"},
+		{"Too short", " ab ", "This field must be at least 3 characters long"},
+		{"Too long", "abcdef", "This field cannot be more than 5 characters long"},
+		{"Invalid UTF-8", "ab\xffc", "This field must be valid UTF-8 text"},
+		{"Control character", "ab\x1bc", "This field cannot contain control characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Validator
+			v.CheckText("content", tt.value, limits)
+			assert.Equal(t, v.FieldErrors["content"], tt.want)
+		})
+	}
+
+	var v Validator
+	v.CheckText("content", strings.Repeat("a", 10000), TextLimits{})
+	assert.Equal(t, v.Valid(), true)
+}
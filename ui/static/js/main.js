@@ -22,3 +22,102 @@ document.addEventListener('DOMContentLoaded', function () {
   }
   fadeOutElement();
 });
+
+document.addEventListener('DOMContentLoaded', function () {
+  var textareas = document.querySelectorAll('textarea[data-markdown-preview]');
+  for (var i = 0; i < textareas.length; i++) {
+    setUpPreview(textareas[i]);
+  }
+
+  function setUpPreview(textarea) {
+    var field = textarea.parentNode;
+    var preview = field.querySelector('.preview');
+    var tabs = field.querySelectorAll('.editor-tabs button');
+    var csrf = textarea.form.querySelector('input[name=csrf_token]');
+
+    for (var i = 0; i < tabs.length; i++) {
+      tabs[i].addEventListener('click', function (e) {
+        for (var j = 0; j < tabs.length; j++) {
+          tabs[j].classList.toggle('live', tabs[j] === e.target);
+        }
+
+        if (e.target.dataset.tab === 'write') {
+          preview.hidden = true;
+          textarea.hidden = false;
+          return;
+        }
+
+        var body = new FormData();
+        body.append('content', textarea.value);
+        body.append('csrf_token', csrf.value);
+
+        fetch(textarea.dataset.markdownPreview, { method: 'POST', body: body })
+          .then(function (response) { return response.text(); })
+          .then(function (html) {
+            preview.innerHTML = html || '<p>Nothing to preview.</p>';
+            textarea.hidden = true;
+            preview.hidden = false;
+          });
+      });
+    }
+  }
+});
+
+document.addEventListener('DOMContentLoaded', function () {
+  var buttons = document.querySelectorAll('button[data-add-file]');
+  for (var i = 0; i < buttons.length; i++) {
+    buttons[i].addEventListener('click', addFile);
+  }
+
+  // addFile appends a blank file to the form, numbered after the others.
+  function addFile(e) {
+    var button = e.target;
+    var template = button.parentNode.querySelector('template#snippet-file');
+    var index = parseInt(button.dataset.addFile, 10);
+
+    var html = template.innerHTML.replace(/__index__/g, index);
+    button.insertAdjacentHTML('beforebegin', html);
+    button.dataset.addFile = index + 1;
+  }
+});
+
+document.addEventListener('DOMContentLoaded', function () {
+  var section = document.querySelector('.comment-section[data-events]');
+  // Browsers without Server-Sent Events keep the page as it was loaded.
+  if (!section || !window.EventSource) {
+    return;
+  }
+
+  var source = new EventSource(section.dataset.events);
+  var unseen = 0;
+
+  source.addEventListener('comment', function (e) {
+    var comment = JSON.parse(e.data);
+    if (document.getElementById('comment-' + comment.id)) {
+      return;
+    }
+
+    unseen++;
+    var notice = section.querySelector('.live-notice');
+    if (!notice) {
+      notice = document.createElement('p');
+      notice.className = 'live-notice';
+      notice.innerHTML = "<a href=''></a>";
+      notice.firstChild.addEventListener('click', function (e) {
+        e.preventDefault();
+        window.location.reload();
+      });
+      section.insertBefore(notice, section.firstChild);
+    }
+    notice.firstChild.textContent = unseen == 1 ? '1 new comment. Show it' : unseen + ' new comments. Show them';
+  });
+
+  source.addEventListener('vote', function (e) {
+    var comment = JSON.parse(e.data);
+    var score = document.querySelector('#comment-' + comment.id + ' .vote-buttons strong');
+    if (score) {
+      score.textContent = comment.score;
+      score.title = '+' + comment.upvotes + ' / -' + comment.downvotes;
+    }
+  });
+});